@@ -0,0 +1,24 @@
+/* add the column to pin the hosts of the registry to the specific IPs */
+ALTER TABLE registry ADD COLUMN resolve_override text;
+
+/* add the column to count the tasks skipped intentionally */
+ALTER TABLE replication_execution ADD COLUMN skipped int NOT NULL DEFAULT 0;
+
+/* add the columns to aggregate the failures of the replication tasks by the error category */
+ALTER TABLE replication_task ADD COLUMN error_category varchar(32) NOT NULL DEFAULT '';
+ALTER TABLE replication_execution ADD COLUMN error_summary text NOT NULL DEFAULT '';
+
+/* add the column to count the resources failed to be fetched by the best-effort fetching */
+ALTER TABLE replication_execution ADD COLUMN fetch_failed int NOT NULL DEFAULT 0;
+
+/* add the column to record the digests of the tags fetched by the incremental replication */
+ALTER TABLE replication_execution ADD COLUMN digests text NOT NULL DEFAULT '';
+
+/* add the column to count the resources dropped by each type of the filters */
+ALTER TABLE replication_execution ADD COLUMN filter_summary text NOT NULL DEFAULT '';
+
+/* add the column to store the summary of the run of the replication flow */
+ALTER TABLE replication_execution ADD COLUMN summary text NOT NULL DEFAULT '';
+
+/* add the column to store the options of the replication policy which have no columns of their own */
+ALTER TABLE replication_policy ADD COLUMN options text NOT NULL DEFAULT '';
@@ -54,6 +54,11 @@ func (l Level) string() (lvl string) {
 	return
 }
 
+// ParseLevel parses the level from its name, e.g. "debug", "info"
+func ParseLevel(lvl string) (Level, error) {
+	return parseLevel(lvl)
+}
+
 func parseLevel(lvl string) (level Level, err error) {
 
 	switch strings.ToLower(lvl) {
@@ -29,8 +29,9 @@ var (
 	blob            = regexp.MustCompile("/v2/(" + reference.NameRegexp.String() + ")/blobs/" + reference.DigestRegexp.String())
 	blobUpload      = regexp.MustCompile("/v2/(" + reference.NameRegexp.String() + ")/blobs/uploads")
 	blobUploadChunk = regexp.MustCompile("/v2/(" + reference.NameRegexp.String() + ")/blobs/uploads/[a-zA-Z0-9-_.=]+")
+	referrers       = regexp.MustCompile("/v2/(" + reference.NameRegexp.String() + ")/referrers/" + reference.DigestRegexp.String())
 
-	repoRegExps = []*regexp.Regexp{tag, manifest, blob, blobUploadChunk, blobUpload}
+	repoRegExps = []*regexp.Regexp{tag, manifest, blob, blobUploadChunk, blobUpload, referrers}
 )
 
 // parse the repository name from path, if the path doesn't match any
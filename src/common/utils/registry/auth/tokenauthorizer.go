@@ -240,6 +240,14 @@ func (t *tokenAuthorizer) updateCachedToken(scope string, token *models.Token) {
 	t.cachedTokens[scope] = token
 }
 
+// ClearCachedTokens drops all the cached tokens, so the tokens are requested again
+// for the following requests, e.g. after the credential is changed
+func (t *tokenAuthorizer) ClearCachedTokens() {
+	t.Lock()
+	defer t.Unlock()
+	t.cachedTokens = make(map[string]*models.Token)
+}
+
 // ping returns the realm, service and error
 func ping(client *http.Client, endpoint string) (string, string, error) {
 	resp, err := client.Get(endpoint)
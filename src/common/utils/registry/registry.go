@@ -20,7 +20,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 
 	commonhttp "github.com/goharbor/harbor/src/common/http"
 	"github.com/goharbor/harbor/src/common/utils"
@@ -80,12 +79,9 @@ func NewRegistry(endpoint string, client *http.Client) (*Registry, error) {
 // Catalog ...
 func (r *Registry) Catalog() ([]string, error) {
 	repos := []string{}
-	suffix := "/v2/_catalog?n=1000"
-	var url string
-
-	for len(suffix) > 0 {
-		url = r.Endpoint.String() + suffix
+	url := r.Endpoint.String() + "/v2/_catalog?n=1000"
 
+	for len(url) > 0 {
 		req, err := http.NewRequest("GET", url, nil)
 		if err != nil {
 			return repos, err
@@ -111,13 +107,7 @@ func (r *Registry) Catalog() ([]string, error) {
 			}
 
 			repos = append(repos, catalogResp.Repositories...)
-			// Link: </v2/_catalog?last=library%2Fhello-world-25&n=100>; rel="next"
-			link := resp.Header.Get("Link")
-			if strings.HasSuffix(link, `rel="next"`) && strings.Index(link, "<") >= 0 && strings.Index(link, ">") >= 0 {
-				suffix = link[strings.Index(link, "<")+1 : strings.Index(link, ">")]
-			} else {
-				suffix = ""
-			}
+			url = buildNextPageURL(r.Endpoint.String(), resp.Header.Get("Link"))
 		} else {
 			return repos, &commonhttp.Error{
 				Code:    resp.StatusCode,
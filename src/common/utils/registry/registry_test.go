@@ -140,3 +140,37 @@ func TestCatalog(t *testing.T) {
 func newRegistryClient(url string) (*Registry, error) {
 	return NewRegistry(url, &http.Client{})
 }
+
+func TestBuildNextPageURL(t *testing.T) {
+	endpoint := "http://registry"
+	cases := []struct {
+		link string
+		url  string
+	}{
+		{
+			link: "",
+			url:  "",
+		},
+		{
+			link: `</v2/_catalog?last=a&n=100>; rel="prev"`,
+			url:  "",
+		},
+		{
+			link: `</v2/_catalog?last=a&n=100>; rel="next"`,
+			url:  "http://registry/v2/_catalog?last=a&n=100",
+		},
+		{
+			link: `<https://another/v2/_catalog?last=a&n=100>; rel="next"`,
+			url:  "https://another/v2/_catalog?last=a&n=100",
+		},
+		{
+			link: `</v2/_catalog?n=100>; rel="first", </v2/_catalog?last=a&n=100>; rel="next"`,
+			url:  "http://registry/v2/_catalog?last=a&n=100",
+		},
+	}
+	for _, c := range cases {
+		if url := buildNextPageURL(endpoint, c.link); url != c.url {
+			t.Errorf("unexpected URL of the next page for %q: %s != %s", c.link, url, c.url)
+		}
+	}
+}
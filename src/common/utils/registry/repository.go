@@ -22,23 +22,27 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"sort"
 	"strconv"
 	"strings"
 	//	"time"
 
 	"github.com/docker/distribution/manifest/schema1"
 	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/image-spec/specs-go/v1"
 
 	commonhttp "github.com/goharbor/harbor/src/common/http"
 	"github.com/goharbor/harbor/src/common/utils"
+	"github.com/goharbor/harbor/src/common/utils/log"
 )
 
 // Repository holds information of a repository entity
 type Repository struct {
 	Name     string
 	Endpoint *url.URL
-	client   *http.Client
+	// MaxManifestSize is the max bytes of the manifests pulled, the
+	// larger ones are rejected without being read fully. No limit if <= 0
+	MaxManifestSize int64
+	client          *http.Client
 }
 
 // NewRepository returns an instance of Repository
@@ -69,51 +73,54 @@ func parseError(err error) error {
 }
 
 // ListTag ...
+// The tags are listed page by page by following the "Link" header until the last page
 func (r *Repository) ListTag() ([]string, error) {
 	tags := []string{}
-	req, err := http.NewRequest("GET", buildTagListURL(r.Endpoint.String(), r.Name), nil)
-	if err != nil {
-		return tags, err
-	}
-
-	resp, err := r.client.Do(req)
-	if err != nil {
-		return tags, parseError(err)
-	}
-
-	defer resp.Body.Close()
-
-	b, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return tags, err
-	}
+	url := buildTagListURL(r.Endpoint.String(), r.Name)
+	for len(url) > 0 {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return tags, err
+		}
 
-	if resp.StatusCode == http.StatusOK {
-		tagsResp := struct {
-			Tags []string `json:"tags"`
-		}{}
+		resp, err := r.client.Do(req)
+		if err != nil {
+			return tags, parseError(err)
+		}
 
-		if err := json.Unmarshal(b, &tagsResp); err != nil {
+		b, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
 			return tags, err
 		}
-		sort.Strings(tags)
-		tags = tagsResp.Tags
 
-		return tags, nil
-	} else if resp.StatusCode == http.StatusNotFound {
+		if resp.StatusCode == http.StatusOK {
+			tagsResp := struct {
+				Tags []string `json:"tags"`
+			}{}
+
+			if err := json.Unmarshal(b, &tagsResp); err != nil {
+				return tags, err
+			}
+			tags = append(tags, tagsResp.Tags...)
+			url = buildNextPageURL(r.Endpoint.String(), resp.Header.Get("Link"))
+			continue
+		}
 
-		// TODO remove the logic if the bug of registry is fixed
-		// It's a workaround for a bug of registry: when listing tags of
-		// a repository which is being pushed, a "NAME_UNKNOWN" error will
-		// been returned, while the catalog API can list this repository.
-		return tags, nil
-	}
+		if resp.StatusCode == http.StatusNotFound {
+			// TODO remove the logic if the bug of registry is fixed
+			// It's a workaround for a bug of registry: when listing tags of
+			// a repository which is being pushed, a "NAME_UNKNOWN" error will
+			// been returned, while the catalog API can list this repository.
+			return tags, nil
+		}
 
-	return tags, &commonhttp.Error{
-		Code:    resp.StatusCode,
-		Message: string(b),
+		return tags, &commonhttp.Error{
+			Code:    resp.StatusCode,
+			Message: string(b),
+		}
 	}
-
+	return tags, nil
 }
 
 // ManifestExist ...
@@ -125,6 +132,8 @@ func (r *Repository) ManifestExist(reference string) (digest string, exist bool,
 
 	req.Header.Add(http.CanonicalHeaderKey("Accept"), schema1.MediaTypeManifest)
 	req.Header.Add(http.CanonicalHeaderKey("Accept"), schema2.MediaTypeManifest)
+	// the OCI artifacts(e.g. the Helm charts) are only served as OCI manifests
+	req.Header.Add(http.CanonicalHeaderKey("Accept"), v1.MediaTypeImageManifest)
 
 	resp, err := r.client.Do(req)
 	if err != nil {
@@ -174,12 +183,25 @@ func (r *Repository) PullManifest(reference string, acceptMediaTypes []string) (
 	}
 
 	defer resp.Body.Close()
-	b, err := ioutil.ReadAll(resp.Body)
+	var body io.Reader = resp.Body
+	if resp.StatusCode == http.StatusOK && r.MaxManifestSize > 0 {
+		if resp.ContentLength > r.MaxManifestSize {
+			err = r.manifestTooLargeError(reference)
+			return
+		}
+		// the Content-Length may be absent or wrong, read one more byte to detect the oversize
+		body = io.LimitReader(resp.Body, r.MaxManifestSize+1)
+	}
+	b, err := ioutil.ReadAll(body)
 	if err != nil {
 		return
 	}
 
 	if resp.StatusCode == http.StatusOK {
+		if r.MaxManifestSize > 0 && int64(len(b)) > r.MaxManifestSize {
+			err = r.manifestTooLargeError(reference)
+			return
+		}
 		digest = resp.Header.Get(http.CanonicalHeaderKey("Docker-Content-Digest"))
 		mediaType = resp.Header.Get(http.CanonicalHeaderKey("Content-Type"))
 		payload = b
@@ -194,6 +216,10 @@ func (r *Repository) PullManifest(reference string, acceptMediaTypes []string) (
 	return
 }
 
+func (r *Repository) manifestTooLargeError(reference string) error {
+	return fmt.Errorf("the size of the manifest %s:%s exceeds the limit %d bytes", r.Name, reference, r.MaxManifestSize)
+}
+
 // PushManifest ...
 func (r *Repository) PushManifest(reference, mediaType string, payload []byte) (digest string, err error) {
 	req, err := http.NewRequest("PUT", buildManifestURL(r.Endpoint.String(), r.Name, reference),
@@ -430,13 +456,54 @@ func (r *Repository) monolithicBlobUpload(location, digest string, size int64, d
 	}
 }
 
-// PushBlob ...
+// cancel the upload session, the data uploaded partially is removed by the registry
+func (r *Repository) cancelBlobUpload(location string) error {
+	url, err := buildBlobUploadURL(r.Endpoint.String(), location)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return parseError(err)
+	}
+
+	defer resp.Body.Close()
+
+	// the session may be gone already if the registry aborts it itself
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return &commonhttp.Error{
+		Code:    resp.StatusCode,
+		Message: string(b),
+	}
+}
+
+// PushBlob pushes the blob monolithically, the upload session is cancelled
+// if the push fails to avoid leaving the partially uploaded data on the registry
 func (r *Repository) PushBlob(digest string, size int64, data io.Reader) error {
 	location, _, err := r.initiateBlobUpload(r.Name)
 	if err != nil {
 		return err
 	}
-	return r.monolithicBlobUpload(location, digest, size, data)
+	if err = r.monolithicBlobUpload(location, digest, size, data); err != nil {
+		if e := r.cancelBlobUpload(location); e != nil {
+			log.Errorf("failed to cancel the upload session of blob %s in repository %s: %v", digest, r.Name, e)
+		}
+		return err
+	}
+	return nil
 }
 
 // DeleteBlob ...
@@ -476,6 +543,29 @@ func buildTagListURL(endpoint, repoName string) string {
 	return fmt.Sprintf("%s/v2/%s/tags/list", endpoint, repoName)
 }
 
+// returns the URL of the next page in the "Link" header of the paginated
+// response, returns empty string if it's the last page. The header looks like:
+// Link: </v2/_catalog?last=library%2Fhello-world-25&n=100>; rel="next"
+// the relative URL is resolved against the endpoint
+func buildNextPageURL(endpoint, link string) string {
+	for _, value := range strings.Split(link, ",") {
+		value = strings.TrimSpace(value)
+		if !strings.HasSuffix(value, `rel="next"`) {
+			continue
+		}
+		begin, end := strings.Index(value, "<"), strings.Index(value, ">")
+		if begin < 0 || end < begin {
+			continue
+		}
+		next := value[begin+1 : end]
+		if strings.HasPrefix(next, "http://") || strings.HasPrefix(next, "https://") {
+			return next
+		}
+		return endpoint + next
+	}
+	return ""
+}
+
 func buildManifestURL(endpoint, repoName, reference string) string {
 	return fmt.Sprintf("%s/v2/%s/manifests/%s", endpoint, repoName, reference)
 }
@@ -492,7 +582,7 @@ func buildInitiateBlobUploadURL(endpoint, repoName string) string {
 	return fmt.Sprintf("%s/v2/%s/blobs/uploads/", endpoint, repoName)
 }
 
-func buildMonolithicBlobUploadURL(endpoint, location, digest string) (string, error) {
+func buildBlobUploadURL(endpoint, location string) (string, error) {
 	relative, err := isRelativeURL(location)
 	if err != nil {
 		return "", err
@@ -502,6 +592,14 @@ func buildMonolithicBlobUploadURL(endpoint, location, digest string) (string, er
 	if relative {
 		location = endpoint + location
 	}
+	return location, nil
+}
+
+func buildMonolithicBlobUploadURL(endpoint, location, digest string) (string, error) {
+	location, err := buildBlobUploadURL(endpoint, location)
+	if err != nil {
+		return "", err
+	}
 	query := ""
 	if strings.ContainsRune(location, '?') {
 		query = "&"
@@ -179,6 +179,56 @@ func TestPushBlob(t *testing.T) {
 	}
 }
 
+func TestPushBlobFailed(t *testing.T) {
+	location := ""
+	initUploadHandler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add(http.CanonicalHeaderKey("Content-Length"), "0")
+		w.Header().Add(http.CanonicalHeaderKey("Location"), location)
+		w.Header().Add(http.CanonicalHeaderKey("Docker-Upload-UUID"), uuid)
+		w.WriteHeader(http.StatusAccepted)
+	}
+	// the upload fails after the data is read partially
+	monolithicUploadHandler := func(w http.ResponseWriter, r *http.Request) {
+		r.Body.Read(make([]byte, 1))
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	cancelled := false
+	cancelUploadHandler := func(w http.ResponseWriter, r *http.Request) {
+		cancelled = true
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	server := test.NewServer(
+		&test.RequestHandlerMapping{
+			Method:  "POST",
+			Pattern: fmt.Sprintf("/v2/%s/blobs/uploads/", repository),
+			Handler: initUploadHandler,
+		},
+		&test.RequestHandlerMapping{
+			Method:  "PUT",
+			Pattern: fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, uuid),
+			Handler: monolithicUploadHandler,
+		},
+		&test.RequestHandlerMapping{
+			Method:  "DELETE",
+			Pattern: fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, uuid),
+			Handler: cancelUploadHandler,
+		})
+	defer server.Close()
+	// the relative location is resolved against the endpoint when cancelling
+	location = fmt.Sprintf("/v2/%s/blobs/uploads/%s", repository, uuid)
+
+	client, err := newRepository(server.URL)
+	require.Nil(t, err)
+
+	err = client.PushBlob(digest, int64(len(blob)), bytes.NewReader(blob))
+	require.NotNil(t, err)
+	e, ok := err.(*commonhttp.Error)
+	require.True(t, ok)
+	assert.Equal(t, http.StatusInternalServerError, e.Code)
+	assert.True(t, cancelled)
+}
+
 func TestDeleteBlob(t *testing.T) {
 	handler := test.Handler(&test.Response{
 		StatusCode: http.StatusAccepted,
@@ -287,6 +337,41 @@ func TestPullManifest(t *testing.T) {
 	}
 }
 
+func TestPullManifestTooLarge(t *testing.T) {
+	handler := test.Handler(&test.Response{
+		Headers: map[string]string{
+			"Docker-Content-Digest": digest,
+			"Content-Type":          mediaType,
+		},
+		Body: manifest,
+	})
+
+	server := test.NewServer(
+		&test.RequestHandlerMapping{
+			Method:  "GET",
+			Pattern: fmt.Sprintf("/v2/%s/manifests/%s", repository, tag),
+			Handler: handler,
+		})
+	defer server.Close()
+
+	client, err := newRepository(server.URL)
+	if err != nil {
+		t.Fatalf("failed to create client for repository: %v", err)
+	}
+
+	// the manifest within the limit
+	client.MaxManifestSize = int64(len(manifest))
+	if _, _, _, err = client.PullManifest(tag, []string{mediaType}); err != nil {
+		t.Fatalf("failed to pull manifest: %v", err)
+	}
+
+	// the manifest exceeding the limit
+	client.MaxManifestSize = int64(len(manifest)) - 1
+	if _, _, _, err = client.PullManifest(tag, []string{mediaType}); err == nil {
+		t.Errorf("expected error when the manifest exceeds the limit")
+	}
+}
+
 func TestPushManifest(t *testing.T) {
 	handler := test.Handler(&test.Response{
 		StatusCode: http.StatusCreated,
@@ -390,6 +475,37 @@ func TestListTag(t *testing.T) {
 	}
 }
 
+func TestListTagPagination(t *testing.T) {
+	pages := [][]string{{"1", "2"}, {"3", "4"}, {"5"}}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.URL.Query().Get("page"))
+		if err != nil {
+			page = 0
+		}
+		if page+1 < len(pages) {
+			w.Header().Set("Link", fmt.Sprintf("</v2/%s/tags/list?page=%d>; rel=\"next\"", repository, page+1))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(fmt.Sprintf("{\"name\": \"%s\",\"tags\": [\"%s\"]}", repository,
+			strings.Join(pages[page], "\",\""))))
+	}
+
+	server := test.NewServer(
+		&test.RequestHandlerMapping{
+			Method:  "GET",
+			Pattern: fmt.Sprintf("/v2/%s/tags/list", repository),
+			Handler: handler,
+		})
+	defer server.Close()
+
+	client, err := newRepository(server.URL)
+	require.Nil(t, err)
+
+	tags, err := client.ListTag()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3", "4", "5"}, tags)
+}
+
 func TestParseError(t *testing.T) {
 	err := &url.Error{
 		Err: &commonhttp.Error{},
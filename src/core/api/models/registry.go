@@ -9,4 +9,6 @@ type RegistryUpdateRequest struct {
 	AccessKey      *string `json:"access_key"`
 	AccessSecret   *string `json:"access_secret"`
 	Insecure       *bool   `json:"insecure"`
+	// ResolveOverride pins the hosts to the specific IPs, the empty map clears the pinning
+	ResolveOverride map[string]string `json:"resolve_override"`
 }
@@ -54,6 +54,8 @@ func (t *RegistryAPI) Ping() {
 		AccessKey      *string `json:"access_key"`
 		AccessSecret   *string `json:"access_secret"`
 		Insecure       *bool   `json:"insecure"`
+		// ResolveOverride pins the hosts to the specific IPs
+		ResolveOverride map[string]string `json:"resolve_override"`
 	}{}
 	t.DecodeJSONReq(&req)
 
@@ -105,6 +107,9 @@ func (t *RegistryAPI) Ping() {
 	if req.Insecure != nil {
 		reg.Insecure = *req.Insecure
 	}
+	if req.ResolveOverride != nil {
+		reg.ResolveOverride = req.ResolveOverride
+	}
 	if len(reg.Type) == 0 || len(reg.URL) == 0 {
 		t.SendBadRequestError(errors.New("type or url cannot be empty"))
 		return
@@ -282,6 +287,9 @@ func (t *RegistryAPI) Put() {
 	if req.Insecure != nil {
 		r.Insecure = *req.Insecure
 	}
+	if req.ResolveOverride != nil {
+		r.ResolveOverride = req.ResolveOverride
+	}
 
 	t.Validate(r)
 
@@ -135,8 +135,14 @@ func (r *ReplicationOperationAPI) CreateExecution() {
 		return
 	}
 
-	trigger := r.GetString("trigger", string(model.TriggerTypeManual))
-	executionID, err := replication.OperationCtl.StartReplication(policy, nil, model.TriggerType(trigger))
+	var executionID int64
+	// the dry run only previews the tasks without submitting them
+	if dryRun, _ := r.GetBool("dry_run", false); dryRun {
+		executionID, err = replication.OperationCtl.StartDryRun(policy)
+	} else {
+		trigger := r.GetString("trigger", string(model.TriggerTypeManual))
+		executionID, err = replication.OperationCtl.StartReplication(policy, nil, model.TriggerType(trigger))
+	}
 	if err != nil {
 		r.SendInternalServerError(fmt.Errorf("failed to start replication for policy %d: %v", execution.PolicyID, err))
 		return
@@ -29,6 +29,9 @@ type fakedOperationController struct{}
 func (f *fakedOperationController) StartReplication(policy *model.Policy, resource *model.Resource, trigger model.TriggerType) (int64, error) {
 	return 1, nil
 }
+func (f *fakedOperationController) StartDryRun(policy *model.Policy) (int64, error) {
+	return 1, nil
+}
 func (f *fakedOperationController) StopReplication(int64) error {
 	return nil
 }
@@ -69,6 +72,12 @@ func (f *fakedOperationController) GetTask(id int64) (*models.Task, error) {
 func (f *fakedOperationController) UpdateTaskStatus(id int64, status string, statusCondition ...string) error {
 	return nil
 }
+func (f *fakedOperationController) UpdateExecution(*models.Execution, ...string) error {
+	return nil
+}
+func (f *fakedOperationController) UpdateTask(task *models.Task, props ...string) error {
+	return nil
+}
 func (f *fakedOperationController) GetTaskLog(int64) ([]byte, error) {
 	return []byte("success"), nil
 }
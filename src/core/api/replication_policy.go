@@ -276,7 +276,15 @@ func isTaskRunning(task *models.Task) bool {
 	switch task.Status {
 	case models.TaskStatusSucceed,
 		models.TaskStatusStopped,
-		models.TaskStatusFailed:
+		models.TaskStatusFailed,
+		models.TaskStatusDeferred,
+		models.TaskStatusDenied,
+		models.TaskStatusOverQuota,
+		models.TaskStatusTimedOut,
+		models.TaskStatusRateLimited,
+		models.TaskStatusSkipped,
+		models.TaskStatusWouldCopy,
+		models.TaskStatusWouldDelete:
 		return false
 	}
 	return true
@@ -44,6 +44,7 @@ type Handler struct {
 	id        int64
 	status    string
 	rawStatus string
+	checkIn   string
 }
 
 // Prepare ...
@@ -64,6 +65,7 @@ func (h *Handler) Prepare() {
 		return
 	}
 	h.rawStatus = data.Status
+	h.checkIn = data.CheckIn
 	status, ok := statusMap[data.Status]
 	if !ok {
 		log.Debugf("drop the job status update event: job id-%d, status-%s", id, status)
@@ -96,6 +98,13 @@ func (h *Handler) HandleReplicationScheduleJob() {
 // HandleReplicationTask handles the webhook of replication task
 func (h *Handler) HandleReplicationTask() {
 	log.Debugf("received replication task status update event: task-%d, status-%s", h.id, h.status)
+	if len(h.checkIn) > 0 {
+		if err := hook.CheckInTask(replication.OperationCtl, h.id, h.checkIn); err != nil {
+			log.Errorf("Failed to handle the check in of replication task, id: %d, check in: %s", h.id, h.checkIn)
+			h.SendInternalServerError(err)
+		}
+		return
+	}
 	if err := hook.UpdateTask(replication.OperationCtl, h.id, h.rawStatus); err != nil {
 		log.Errorf("Failed to update replication task status, id: %d, status: %s", h.id, h.status)
 		h.SendInternalServerError(err)
@@ -17,8 +17,11 @@ package replication
 import (
 	"encoding/json"
 	"fmt"
+	"sync/atomic"
+	"time"
 
 	"github.com/goharbor/harbor/src/jobservice/job"
+	"github.com/goharbor/harbor/src/replication/classifier"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/transfer"
 
@@ -77,7 +80,17 @@ func (r *Replication) Run(ctx job.Context, params job.Parameters) error {
 		return err
 	}
 
+	// the transfer is stopped once the deadline of the task is exceeded
+	var deadline time.Time
+	if dst.TaskDeadline > 0 {
+		deadline = time.Now().Add(time.Duration(dst.TaskDeadline) * time.Second)
+	}
+	var timedOut int32
 	stopFunc := func() bool {
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			atomic.StoreInt32(&timedOut, 1)
+			return true
+		}
 		cmd, exist := ctx.OPCommand()
 		if !exist {
 			return false
@@ -90,7 +103,26 @@ func (r *Replication) Run(ctx job.Context, params job.Parameters) error {
 		return err
 	}
 
-	return trans.Transfer(src, dst)
+	err = trans.Transfer(src, dst)
+	if atomic.LoadInt32(&timedOut) == 1 {
+		// the timed out task isn't retried by the job service, it's
+		// marked as timed out and requeued to the next execution
+		logger.Warningf("the task exceeds its deadline %d seconds, requeue it to the next execution", dst.TaskDeadline)
+		return ctx.Checkin(model.TaskCheckInTimedOut)
+	}
+	if err != nil && dst.RateLimitAsSkip && classifier.IsRateLimited(err) {
+		// the rate limit is transient, the task isn't retried by the job service
+		// immediately but marked as rate limited and requeued to the next execution
+		logger.Warningf("the task is rate limited by the registry, requeue it to the next execution: %v", err)
+		return ctx.Checkin(model.TaskCheckInRateLimited)
+	}
+	if err != nil {
+		// record the category of the error, the failures of the execution are aggregated by it
+		if e := ctx.Checkin(model.TaskCheckInFailurePrefix + string(classifier.Categorize(err))); e != nil {
+			logger.Errorf("failed to check in the failure of the task: %v", e)
+		}
+	}
+	return err
 }
 
 func parseParams(params map[string]interface{}) (*model.Resource, *model.Resource, error) {
@@ -15,9 +15,16 @@
 package replication
 
 import (
+	"net/http"
 	"testing"
+	"time"
 
+	commonhttp "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/jobservice/job"
 	"github.com/goharbor/harbor/src/jobservice/job/impl"
+	"github.com/goharbor/harbor/src/jobservice/logger"
+	"github.com/goharbor/harbor/src/jobservice/logger/backend"
+	"github.com/goharbor/harbor/src/replication/classifier"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/transfer"
 	"github.com/stretchr/testify/assert"
@@ -98,3 +105,83 @@ func TestRun(t *testing.T) {
 	require.Nil(t, rep.Run(&impl.Context{}, params))
 	assert.True(t, transferred)
 }
+
+// records the check in messages
+type fakedCheckInContext struct {
+	*impl.Context
+	checkIns []string
+}
+
+func (f *fakedCheckInContext) Checkin(status string) error {
+	f.checkIns = append(f.checkIns, status)
+	return nil
+}
+
+func (f *fakedCheckInContext) OPCommand() (job.OPCommand, bool) {
+	return job.NilCommand, false
+}
+
+func (f *fakedCheckInContext) GetLogger() logger.Interface {
+	return backend.NewStdOutputLogger("INFO", backend.StdErr, 4)
+}
+
+// the transfer runs until it's stopped
+type fakedStalledTransfer struct {
+	shouldStop transfer.StopFunc
+}
+
+func (f *fakedStalledTransfer) Transfer(src *model.Resource, dst *model.Resource) error {
+	for !f.shouldStop() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+func TestRunWithDeadline(t *testing.T) {
+	err := transfer.RegisterFactory("stalled", func(_ transfer.Logger, stopFunc transfer.StopFunc) (transfer.Transfer, error) {
+		return &fakedStalledTransfer{shouldStop: stopFunc}, nil
+	})
+	require.Nil(t, err)
+	params := map[string]interface{}{
+		"src_resource": `{"type":"stalled"}`,
+		"dst_resource": `{"task_deadline":1}`,
+	}
+	ctx := &fakedCheckInContext{Context: &impl.Context{}}
+	rep := &Replication{}
+	// the timed out task isn't failed but checked in as timed out
+	require.Nil(t, rep.Run(ctx, params))
+	assert.Equal(t, []string{model.TaskCheckInTimedOut}, ctx.checkIns)
+}
+
+// the transfer always fails with the error
+type fakedFailedTransfer struct {
+	err error
+}
+
+func (f *fakedFailedTransfer) Transfer(src *model.Resource, dst *model.Resource) error {
+	return f.err
+}
+
+func TestRunWithRateLimit(t *testing.T) {
+	err := transfer.RegisterFactory("rate_limited", func(transfer.Logger, transfer.StopFunc) (transfer.Transfer, error) {
+		return &fakedFailedTransfer{err: &commonhttp.Error{Code: http.StatusTooManyRequests}}, nil
+	})
+	require.Nil(t, err)
+	rep := &Replication{}
+
+	// the rate limit error fails the task if the policy doesn't handle it as skip,
+	// and the category of the failure is checked in
+	ctx := &fakedCheckInContext{Context: &impl.Context{}}
+	params := map[string]interface{}{
+		"src_resource": `{"type":"rate_limited"}`,
+		"dst_resource": `{}`,
+	}
+	require.NotNil(t, rep.Run(ctx, params))
+	assert.Equal(t, []string{model.TaskCheckInFailurePrefix + string(classifier.CategoryRateLimited)}, ctx.checkIns)
+
+	// the rate limited task is checked in rather than failed
+	ctx = &fakedCheckInContext{Context: &impl.Context{}}
+	params["dst_resource"] = `{"rate_limit_as_skip":true}`
+	require.Nil(t, rep.Run(ctx, params))
+	assert.Equal(t, []string{model.TaskCheckInRateLimited}, ctx.checkIns)
+}
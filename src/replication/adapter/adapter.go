@@ -15,8 +15,10 @@
 package adapter
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/goharbor/harbor/src/replication/model"
 )
@@ -37,6 +39,56 @@ type Adapter interface {
 	HealthCheck() (model.HealthStatus, error)
 }
 
+// NamespaceChecker is an optional interface that the adapters can implement
+// to check whether the namespace exists in the registry
+type NamespaceChecker interface {
+	NamespaceExist(namespace string) (bool, error)
+}
+
+// TagCreationTimeLister is an optional interface that the adapters can implement
+// to list the creation time of the tags under the repository
+type TagCreationTimeLister interface {
+	ListTagCreationTimes(repository string) (map[string]time.Time, error)
+}
+
+// IncrementalImageRegistry is an optional interface that the adapters can implement
+// to fetch only the images pushed since the specified time on the server side
+type IncrementalImageRegistry interface {
+	FetchImagesPushedSince(ctx context.Context, filters []*model.Filter, since time.Time) ([]*model.Resource, error)
+}
+
+// TagLister is an optional interface that the adapters can implement
+// to list the tags under the repository
+type TagLister interface {
+	ListTag(repository string) ([]string, error)
+}
+
+// TagDeleter is an optional interface that the adapters can implement
+// to delete only the tag, the manifest and the other tags referring to it are kept
+type TagDeleter interface {
+	DeleteTag(repository, tag string) error
+}
+
+// DefaultNamespaceProvider is an optional interface that the adapters can implement
+// to provide the default namespace of the registry, e.g. "library" of Docker Hub
+type DefaultNamespaceProvider interface {
+	DefaultNamespace() string
+}
+
+// UntaggedManifestLister is an optional interface that the adapters can implement
+// to list the digests of the untagged manifests under the repository
+type UntaggedManifestLister interface {
+	ListUntaggedManifests(repository string) ([]string, error)
+}
+
+// QuotaChecker is an optional interface that the adapters can implement
+// to get the storage quota remaining in the namespace
+type QuotaChecker interface {
+	// GetRemainingQuota returns the bytes that can still be stored
+	// in the namespace, a negative value means no limit
+	GetRemainingQuota(namespace string) (int64, error)
+}
+
 // RegisterFactory registers one adapter factory to the registry
 func RegisterFactory(t model.RegistryType, factory Factory) error {
 	if len(t) == 0 {
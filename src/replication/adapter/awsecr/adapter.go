@@ -18,15 +18,14 @@ import (
 	"errors"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
-	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	awsecrapi "github.com/aws/aws-sdk-go/service/ecr"
 	"github.com/goharbor/harbor/src/common/utils/log"
-	"github.com/goharbor/harbor/src/common/utils/registry"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
 	"net/http"
 	"regexp"
+	"strings"
 )
 
 func init() {
@@ -44,7 +43,11 @@ func newAdapter(registry *model.Registry) (*adapter, error) {
 	if err != nil {
 		return nil, err
 	}
-	authorizer := NewAuth(region, registry.Credential.AccessKey, registry.Credential.AccessSecret, registry.Insecure)
+	var accessKey, accessSecret string
+	if registry.Credential != nil {
+		accessKey, accessSecret = registry.Credential.AccessKey, registry.Credential.AccessSecret
+	}
+	authorizer := NewAuth(region, accessKey, accessSecret, registry.Insecure)
 	reg, err := adp.NewDefaultImageRegistryWithCustomizedAuthorizer(registry, authorizer)
 	if err != nil {
 		return nil, err
@@ -95,13 +98,9 @@ func (*adapter) Info() (info *model.RegistryInfo, err error) {
 	}, nil
 }
 
-// HealthCheck checks health status of a registry
+// HealthCheck checks health status of a registry, the instance role is
+// used to ping the registry if no credential is configured
 func (a *adapter) HealthCheck() (model.HealthStatus, error) {
-	if a.registry.Credential == nil ||
-		len(a.registry.Credential.AccessKey) == 0 || len(a.registry.Credential.AccessSecret) == 0 {
-		log.Errorf("no credential to ping registry %s", a.registry.URL)
-		return model.Unhealthy, nil
-	}
 	if err := a.PingGet(); err != nil {
 		log.Errorf("failed to ping registry %s: %v", a.registry.URL, err)
 		return model.Unhealthy, nil
@@ -109,8 +108,11 @@ func (a *adapter) HealthCheck() (model.HealthStatus, error) {
 	return model.Healthy, nil
 }
 
-// PrepareForPush nothing need to do.
+// PrepareForPush creates the repositories as ECR requires them to exist before pushing.
+// The namespaces are mapped to the prefixes of the repository names, e.g. the resource
+// "library/nginx" is pushed to the repository "library/nginx"
 func (a *adapter) PrepareForPush(resources []*model.Resource) error {
+	repositories := map[string]struct{}{}
 	for _, resource := range resources {
 		if resource == nil {
 			return errors.New("the resource cannot be nil")
@@ -125,31 +127,46 @@ func (a *adapter) PrepareForPush(resources []*model.Resource) error {
 			return errors.New("the name of the namespace cannot be nil")
 		}
 
-		err := a.createRepository(resource.Metadata.Repository.Name)
-		if err != nil {
+		repository := resource.Metadata.Repository.Name
+		if _, exist := repositories[repository]; exist {
+			continue
+		}
+		repositories[repository] = struct{}{}
+		if err := a.createRepository(repository); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// NamespaceExist checks whether any repository prefixed with the namespace exists,
+// as ECR has no namespace but the prefixes of the repository names
+func (a *adapter) NamespaceExist(namespace string) (bool, error) {
+	repositories, err := a.Catalog()
+	if err != nil {
+		return false, err
+	}
+	for _, repository := range repositories {
+		if strings.HasPrefix(repository, namespace+"/") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func (a *adapter) createRepository(repository string) error {
-	if a.registry.Credential == nil ||
-		len(a.registry.Credential.AccessKey) == 0 || len(a.registry.Credential.AccessSecret) == 0 {
-		return errors.New("no credential ")
+	var accessKey, accessSecret string
+	if a.registry.Credential != nil {
+		accessKey, accessSecret = a.registry.Credential.AccessKey, a.registry.Credential.AccessSecret
 	}
-	cred := credentials.NewStaticCredentials(
-		a.registry.Credential.AccessKey,
-		a.registry.Credential.AccessSecret,
-		"")
 	if a.region == "" {
 		return errors.New("no region parsed")
 	}
 	config := &aws.Config{
-		Credentials: cred,
+		Credentials: newCredentials(accessKey, accessSecret),
 		Region:      &a.region,
 		HTTPClient: &http.Client{
-			Transport: registry.GetHTTPTransport(a.registry.Insecure),
+			Transport: adp.GetHTTPTransport(a.registry),
 		},
 	}
 	if a.forceEndpoint != nil {
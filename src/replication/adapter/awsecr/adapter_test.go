@@ -1,9 +1,11 @@
 package awsecr
 
 import (
+	"context"
 	"fmt"
 	"github.com/goharbor/harbor/src/common/utils/test"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -56,6 +58,26 @@ func TestAdapter_NewAdapter(t *testing.T) {
 	assert.Nil(t, adapter)
 	assert.NotNil(t, err)
 
+	// no credential, the instance role is used
+	adapter, err = factory(&model.Registry{
+		Type: model.RegistryTypeAwsEcr,
+		URL:  "https://123456.dkr.ecr.test-region.amazonaws.com",
+	})
+	assert.Nil(t, err)
+	assert.NotNil(t, adapter)
+}
+
+func TestNewCredentials(t *testing.T) {
+	assert.Nil(t, newCredentials("", ""))
+	assert.Nil(t, newCredentials("xxx", ""))
+	assert.Nil(t, newCredentials("", "ppp"))
+
+	cred := newCredentials("xxx", "ppp")
+	require.NotNil(t, cred)
+	value, err := cred.Get()
+	require.Nil(t, err)
+	assert.Equal(t, "xxx", value.AccessKeyID)
+	assert.Equal(t, "ppp", value.SecretAccessKey)
 }
 
 func getMockAdapter(t *testing.T, hasCred, health bool) (*adapter, *httptest.Server) {
@@ -154,12 +176,13 @@ func TestAdapter_Info(t *testing.T) {
 }
 
 func TestAdapter_HealthCheck(t *testing.T) {
+	// no credential, the instance role is used
 	a, s := getMockAdapter(t, false, true)
 	defer s.Close()
 	status, err := a.HealthCheck()
 	assert.Nil(t, err)
 	assert.NotNil(t, status)
-	assert.EqualValues(t, model.Unhealthy, status)
+	assert.EqualValues(t, model.Healthy, status)
 
 	a, s = getMockAdapter(t, true, false)
 	defer s.Close()
@@ -194,10 +217,47 @@ func TestAdapter_PrepareForPush(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAdapter_NamespaceExist(t *testing.T) {
+	server := test.NewServer(
+		&test.RequestHandlerMapping{
+			Method:  http.MethodGet,
+			Pattern: "/v2/_catalog",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"repositories": ["busybox", "library/nginx"]}`))
+			},
+		},
+	)
+	defer server.Close()
+	registry := &model.Registry{
+		Type: model.RegistryTypeAwsEcr,
+		URL:  server.URL,
+	}
+	reg, err := adp.NewDefaultImageRegistry(registry)
+	require.Nil(t, err)
+	a := &adapter{
+		registry:             registry,
+		DefaultImageRegistry: reg,
+		region:               "test-region",
+	}
+
+	exist, err := a.NamespaceExist("library")
+	require.Nil(t, err)
+	assert.True(t, exist)
+
+	exist, err = a.NamespaceExist("busybox")
+	require.Nil(t, err)
+	assert.False(t, exist)
+
+	exist, err = a.NamespaceExist("lib")
+	require.Nil(t, err)
+	assert.False(t, exist)
+}
+
 func TestAdapter_FetchImages(t *testing.T) {
 	a, s := getMockAdapter(t, true, true)
 	defer s.Close()
-	resources, err := a.FetchImages([]*model.Filter{
+	resources, err := a.FetchImages(context.Background(), []*model.Filter{
 		{
 			Type:  model.FilterTypeName,
 			Value: "*",
@@ -92,12 +92,8 @@ func (a *awsAuthCredential) Modify(req *http.Request) error {
 
 func (a *awsAuthCredential) getAuthorization() (string, string, string, *time.Time, error) {
 	log.Infof("Aws Ecr getAuthorization %s", a.accessKey)
-	cred := credentials.NewStaticCredentials(
-		a.accessKey,
-		a.accessSecret,
-		"")
 	config := &aws.Config{
-		Credentials: cred,
+		Credentials: newCredentials(a.accessKey, a.accessSecret),
 		Region:      &a.region,
 		HTTPClient: &http.Client{
 			Transport: registry.GetHTTPTransport(a.insecure),
@@ -152,7 +148,17 @@ func (a *awsAuthCredential) isTokenValid() bool {
 	return true
 }
 
-// NewAuth new aws auth
+// returns the static credentials of the access key and secret, or nil to let the SDK
+// resolve the credentials by its default chain(the environment variables, the shared
+// config and the instance role) if either of them is empty
+func newCredentials(accessKey, accessSecret string) *credentials.Credentials {
+	if len(accessKey) == 0 || len(accessSecret) == 0 {
+		return nil
+	}
+	return credentials.NewStaticCredentials(accessKey, accessSecret, "")
+}
+
+// NewAuth new aws auth, the instance role is used if the access key or secret is empty
 func NewAuth(region, accessKey, accessSecret string, insecure bool) Credential {
 	return &awsAuthCredential{
 		region:       region,
@@ -15,6 +15,8 @@
 package awsecr
 
 import (
+	"context"
+
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/util"
@@ -22,7 +24,7 @@ import (
 
 var _ adp.ImageRegistry = adapter{}
 
-func (a adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error) {
+func (a adapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 	nameFilterPattern := ""
 	tagFilterPattern := ""
 	for _, filter := range filters {
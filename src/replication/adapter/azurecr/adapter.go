@@ -12,7 +12,6 @@ import (
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/adapter/native"
 	"github.com/goharbor/harbor/src/replication/model"
-	"github.com/goharbor/harbor/src/replication/util"
 )
 
 func init() {
@@ -102,10 +101,10 @@ func getClient(registry *model.Registry) (*http.Client, error) {
 			registry.Credential.AccessSecret)
 	}
 
-	transport := util.GetHTTPTransport(registry.Insecure)
+	transport := adp.GetHTTPTransport(registry)
 	modifiers := []modifier.Modifier{
 		&auth.UserAgentModifier{
-			UserAgent: adp.UserAgentReplication,
+			UserAgent: adp.GetUserAgent(registry),
 		},
 		cred,
 	}
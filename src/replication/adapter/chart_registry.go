@@ -15,6 +15,7 @@
 package adapter
 
 import (
+	"context"
 	"io"
 
 	"github.com/goharbor/harbor/src/replication/model"
@@ -22,7 +23,8 @@ import (
 
 // ChartRegistry defines the capabilities that a chart registry should have
 type ChartRegistry interface {
-	FetchCharts(filters []*model.Filter) ([]*model.Resource, error)
+	// the fetching should be aborted once the context is done
+	FetchCharts(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error)
 	ChartExist(name, version string) (bool, error)
 	DownloadChart(name, version string) (io.ReadCloser, error)
 	UploadChart(name, version string, chart io.Reader) error
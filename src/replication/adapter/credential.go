@@ -0,0 +1,189 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/goharbor/harbor/src/common/http/modifier"
+	common_http_auth "github.com/goharbor/harbor/src/common/http/modifier/auth"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+var (
+	credentialProviders   = map[model.RegistryType]CredentialProvider{}
+	credentialProvidersMu sync.RWMutex
+)
+
+// CredentialProvider provides the credential used to access the registry.
+// It is consulted lazily when the requests are sent, so the implementations
+// can fetch the secrets from external systems(e.g. Vault) at runtime
+type CredentialProvider interface {
+	// Get returns the credential for the registry, nil means anonymous
+	Get(*model.Registry) (*model.Credential, error)
+	// Refresh is called when the registry rejects the credential returned
+	// by "Get"(e.g. got 401). The provider should drop its cached credential
+	// and return a new one
+	Refresh(*model.Registry) (*model.Credential, error)
+}
+
+// static credential provider returns the credential stored in the registry model
+type staticCredentialProvider struct{}
+
+func (s *staticCredentialProvider) Get(registry *model.Registry) (*model.Credential, error) {
+	return registry.Credential, nil
+}
+
+func (s *staticCredentialProvider) Refresh(registry *model.Registry) (*model.Credential, error) {
+	return registry.Credential, nil
+}
+
+// StaticCredentialProvider is the default credential provider which returns
+// the credential stored in the registry model directly
+var StaticCredentialProvider CredentialProvider = &staticCredentialProvider{}
+
+// RegisterCredentialProvider registers one credential provider for the specified registry type
+func RegisterCredentialProvider(t model.RegistryType, provider CredentialProvider) error {
+	if len(t) == 0 {
+		return errors.New("invalid registry type")
+	}
+	if provider == nil {
+		return errors.New("empty credential provider")
+	}
+	credentialProvidersMu.Lock()
+	defer credentialProvidersMu.Unlock()
+	if _, exist := credentialProviders[t]; exist {
+		return fmt.Errorf("credential provider for %s already exists", t)
+	}
+	credentialProviders[t] = provider
+	return nil
+}
+
+// GetCredentialProvider returns the credential provider registered for the
+// registry type, if no one is registered, the static credential provider is returned
+func GetCredentialProvider(t model.RegistryType) CredentialProvider {
+	credentialProvidersMu.RLock()
+	defer credentialProvidersMu.RUnlock()
+	provider, exist := credentialProviders[t]
+	if !exist {
+		return StaticCredentialProvider
+	}
+	return provider
+}
+
+// credentialModifier adds the credential got from the provider into the requests
+type credentialModifier struct {
+	registry *model.Registry
+	provider CredentialProvider
+}
+
+// NewCredentialModifier returns a modifier which gets the credential from the provider
+// every time when modifying the request
+func NewCredentialModifier(registry *model.Registry, provider CredentialProvider) modifier.Modifier {
+	return &credentialModifier{
+		registry: registry,
+		provider: provider,
+	}
+}
+
+func (c *credentialModifier) Modify(req *http.Request) error {
+	cred, err := c.provider.Get(c.registry)
+	if err != nil {
+		return fmt.Errorf("failed to get the credential for registry %s: %v", c.registry.URL, err)
+	}
+	if cred == nil || len(cred.AccessSecret) == 0 {
+		return nil
+	}
+	if cred.Type == model.CredentialTypeSecret {
+		return common_http_auth.NewSecretAuthorizer(cred.AccessSecret).Modify(req)
+	}
+	req.SetBasicAuth(cred.AccessKey, cred.AccessSecret)
+	return nil
+}
+
+// the authorizer caching the tokens requested with the credential
+type tokenCache interface {
+	ClearCachedTokens()
+}
+
+// credentialRefreshTransport asks the provider to refresh the credential
+// and retries the request once when getting 401
+type credentialRefreshTransport struct {
+	registry   *model.Registry
+	provider   CredentialProvider
+	transport  http.RoundTripper
+	authorizer modifier.Modifier
+}
+
+// NewCredentialRefreshTransport returns a transport which refreshes the credential
+// via the provider and retries the request once when the registry returns 401.
+// The modifiers that add the credential into the requests must be applied by
+// the underlying "transport" so that the retried request carries the new credential.
+// The "authorizer" is the one exchanging the credential for the tokens(can be nil),
+// its cached tokens are dropped when refreshing, as they're issued for the old credential
+func NewCredentialRefreshTransport(registry *model.Registry, provider CredentialProvider,
+	transport http.RoundTripper, authorizer modifier.Modifier) http.RoundTripper {
+	return &credentialRefreshTransport{
+		registry:   registry,
+		provider:   provider,
+		transport:  transport,
+		authorizer: authorizer,
+	}
+}
+
+func (c *credentialRefreshTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the request whose body cannot be replayed cannot be retried
+	retryable := req.Body == nil || req.GetBody != nil
+	var origin *http.Request
+	if retryable {
+		origin = copyRequest(req)
+	}
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !retryable {
+		return resp, err
+	}
+	if _, err := c.provider.Refresh(c.registry); err != nil {
+		log.Errorf("failed to refresh the credential for registry %s: %v", c.registry.URL, err)
+		return resp, nil
+	}
+	if cache, ok := c.authorizer.(tokenCache); ok {
+		cache.ClearCachedTokens()
+	}
+	if origin.GetBody != nil {
+		body, err := origin.GetBody()
+		if err != nil {
+			return resp, nil
+		}
+		origin.Body = body
+	}
+	resp.Body.Close()
+	log.Debugf("got 401 from registry %s, retry with the refreshed credential", c.registry.URL)
+	return c.transport.RoundTrip(origin)
+}
+
+// make a shallow copy of the request with its own header
+func copyRequest(req *http.Request) *http.Request {
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	return r
+}
@@ -0,0 +1,195 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	registry_pkg "github.com/goharbor/harbor/src/common/utils/registry"
+	"github.com/goharbor/harbor/src/common/utils/registry/auth"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the provider rotates the password when being refreshed
+type rotatingCredentialProvider struct {
+	passwords []string
+	index     int
+	gets      int
+	refreshes int
+}
+
+func (r *rotatingCredentialProvider) Get(*model.Registry) (*model.Credential, error) {
+	r.gets++
+	return &model.Credential{
+		Type:         model.CredentialTypeBasic,
+		AccessKey:    "admin",
+		AccessSecret: r.passwords[r.index],
+	}, nil
+}
+
+func (r *rotatingCredentialProvider) Refresh(registry *model.Registry) (*model.Credential, error) {
+	r.refreshes++
+	if r.index < len(r.passwords)-1 {
+		r.index++
+	}
+	return r.Get(registry)
+}
+
+func TestRegisterCredentialProvider(t *testing.T) {
+	credentialProviders = map[model.RegistryType]CredentialProvider{}
+	// empty type
+	assert.NotNil(t, RegisterCredentialProvider("", StaticCredentialProvider))
+	// empty provider
+	assert.NotNil(t, RegisterCredentialProvider("harbor", nil))
+	// pass
+	assert.Nil(t, RegisterCredentialProvider("harbor", &rotatingCredentialProvider{}))
+	// already exists
+	assert.NotNil(t, RegisterCredentialProvider("harbor", &rotatingCredentialProvider{}))
+}
+
+func TestGetCredentialProvider(t *testing.T) {
+	credentialProviders = map[model.RegistryType]CredentialProvider{}
+	// not registered, got the static one
+	assert.Equal(t, StaticCredentialProvider, GetCredentialProvider("harbor"))
+
+	provider := &rotatingCredentialProvider{}
+	require.Nil(t, RegisterCredentialProvider("harbor", provider))
+	assert.Equal(t, provider, GetCredentialProvider("harbor"))
+}
+
+func TestStaticCredentialProvider(t *testing.T) {
+	registry := &model.Registry{
+		Credential: &model.Credential{
+			AccessKey:    "admin",
+			AccessSecret: "password",
+		},
+	}
+	cred, err := StaticCredentialProvider.Get(registry)
+	require.Nil(t, err)
+	assert.Equal(t, registry.Credential, cred)
+}
+
+func TestCredentialRefreshTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, password, ok := r.BasicAuth()
+		if !ok || password != "rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := &model.Registry{
+		URL: server.URL,
+	}
+	provider := &rotatingCredentialProvider{
+		passwords: []string{"expired", "rotated"},
+	}
+	client := &http.Client{
+		Transport: NewCredentialRefreshTransport(registry, provider,
+			registry_pkg.NewTransport(http.DefaultTransport, NewCredentialModifier(registry, provider)), nil),
+	}
+
+	// the first request is rejected and retried with the rotated credential
+	resp, err := client.Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, provider.refreshes)
+	assert.Equal(t, 3, provider.gets)
+
+	// the credential is got lazily, no refresh anymore
+	resp, err = client.Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, 1, provider.refreshes)
+}
+
+func TestCredentialRefreshTransportStillUnauthorized(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	registry := &model.Registry{
+		URL: server.URL,
+	}
+	provider := &rotatingCredentialProvider{
+		passwords: []string{"expired", "rotated"},
+	}
+	client := &http.Client{
+		Transport: NewCredentialRefreshTransport(registry, provider,
+			registry_pkg.NewTransport(http.DefaultTransport, NewCredentialModifier(registry, provider)), nil),
+	}
+
+	// only retry once
+	resp, err := client.Get(server.URL)
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, 1, provider.refreshes)
+}
+
+func TestCredentialRefreshTransportWithCachedToken(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/":
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/service/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case "/service/token":
+			// the token server issues the new token only for the refreshed credential
+			token := "old"
+			if _, password, _ := r.BasicAuth(); password == "rotated" {
+				token = "new"
+			}
+			fmt.Fprintf(w, `{"token":"%s","expires_in":3600,"issued_at":"%s"}`,
+				token, time.Now().UTC().Format(time.RFC3339))
+		default:
+			// the old token is revoked by the registry
+			if r.Header.Get("Authorization") != "Bearer new" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name":"library/hello-world","tags":["latest"]}`))
+		}
+	}))
+	defer server.Close()
+
+	registry := &model.Registry{
+		URL: server.URL,
+	}
+	provider := &rotatingCredentialProvider{
+		passwords: []string{"expired", "rotated"},
+	}
+	authorizer := auth.NewStandardTokenAuthorizer(&http.Client{}, NewCredentialModifier(registry, provider))
+	reg, err := newDefaultImageRegistry(registry, authorizer, provider)
+	require.Nil(t, err)
+
+	// the token cached for the old credential is dropped when refreshing
+	tags, err := reg.ListTag("library/hello-world")
+	require.Nil(t, err)
+	assert.Equal(t, []string{"latest"}, tags)
+	assert.Equal(t, 1, provider.refreshes)
+}
@@ -2,13 +2,17 @@ package dockerhub
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
 
+	"github.com/docker/distribution"
+	common_http "github.com/goharbor/harbor/src/common/http"
 	"github.com/goharbor/harbor/src/common/utils/log"
 	"github.com/goharbor/harbor/src/common/utils/registry/auth"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
@@ -29,6 +33,7 @@ func factory(registry *model.Registry) (adp.Adapter, error) {
 		URL:        baseURL, // specify the URL of Docker Hub
 		Credential: registry.Credential,
 		Insecure:   registry.Insecure,
+		UserAgent:  registry.UserAgent,
 	})
 	if err != nil {
 		return nil, err
@@ -44,7 +49,7 @@ func factory(registry *model.Registry) (adp.Adapter, error) {
 			registry.Credential.AccessSecret)
 	}
 	authorizer := auth.NewStandardTokenAuthorizer(&http.Client{
-		Transport: util.GetHTTPTransport(registry.Insecure),
+		Transport: adp.GetHTTPTransport(registry),
 	}, credential)
 
 	reg, err := adp.NewDefaultImageRegistryWithCustomizedAuthorizer(&model.Registry{
@@ -52,6 +57,7 @@ func factory(registry *model.Registry) (adp.Adapter, error) {
 		URL:        registryURL, // specify the URL of Docker Hub registry service
 		Credential: registry.Credential,
 		Insecure:   registry.Insecure,
+		UserAgent:  registry.UserAgent,
 	}, authorizer)
 	if err != nil {
 		return nil, err
@@ -120,8 +126,10 @@ func (a *adapter) PrepareForPush(resources []*model.Resource) error {
 	}
 
 	for namespace := range namespaces {
-		err := a.CreateNamespace(&model.Namespace{
-			Name: namespace,
+		err := adp.CreateNamespaceWithRetry(namespace, func() error {
+			return a.CreateNamespace(&model.Namespace{
+				Name: namespace,
+			})
 		})
 		if err != nil {
 			return fmt.Errorf("create namespace '%s' in DockerHub error: %v", namespace, err)
@@ -193,7 +201,10 @@ func (a *adapter) CreateNamespace(namespace *model.Namespace) error {
 
 	if resp.StatusCode/100 != 2 {
 		log.Errorf("create namespace error: %d -- %s", resp.StatusCode, string(body))
-		return fmt.Errorf("%d -- %s", resp.StatusCode, body)
+		return &common_http.Error{
+			Code:    resp.StatusCode,
+			Message: string(body),
+		}
 	}
 
 	return nil
@@ -227,7 +238,7 @@ func (a *adapter) getNamespace(namespace string) (*model.Namespace, error) {
 }
 
 // FetchImages fetches images
-func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error) {
+func (a *adapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 	var repos []Repo
 	nameFilter, err := a.getStringFilterValue(model.FilterTypeName, filters)
 	if err != nil {
@@ -237,6 +248,7 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 	if err != nil {
 		return nil, err
 	}
+	nameFilter = normalizeNamePattern(nameFilter)
 
 	namespaces, err := a.listCandidateNamespaces(nameFilter)
 	if err != nil {
@@ -250,6 +262,9 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 		for {
 			pageRepos, err := a.getRepos(ns, "", page, pageSize)
 			if err != nil {
+				if _, ok := err.(*RateLimitError); ok {
+					return nil, err
+				}
 				return nil, fmt.Errorf("get repos for namespace '%s' from DockerHub error: %v", ns, err)
 			}
 			repos = append(repos, pageRepos.Repos...)
@@ -267,6 +282,10 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 	var resources []*model.Resource
 	// TODO(ChenDe): Get tags for repos in parallel
 	for _, repo := range repos {
+		// stop fetching once the caller gives up, e.g. the fetching is timed out
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		name := fmt.Sprintf("%s/%s", repo.Namespace, repo.Name)
 		// If name filter set, skip repos that don't match the filter pattern.
 		if len(nameFilter) != 0 {
@@ -285,6 +304,9 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 		for {
 			pageTags, err := a.getTags(repo.Namespace, repo.Name, page, pageSize)
 			if err != nil {
+				if _, ok := err.(*RateLimitError); ok {
+					return nil, err
+				}
 				return nil, fmt.Errorf("get tags for repo '%s/%s' from DockerHub error: %v", repo.Namespace, repo.Name, err)
 			}
 			for _, t := range pageTags.Tags {
@@ -328,6 +350,48 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 	return resources, nil
 }
 
+// DefaultNamespace returns the namespace of the official images
+func (a *adapter) DefaultNamespace() string {
+	return officialNamespace
+}
+
+// the official images are under the namespace "library", e.g. "nginx" -> "library/nginx"
+func normalizeRepository(repository string) string {
+	if strings.Contains(repository, "/") {
+		return repository
+	}
+	return officialNamespace + "/" + repository
+}
+
+// the name pattern without namespace matches the official images, e.g. "nginx" -> "library/nginx",
+// except the ones matching across the namespaces, e.g. "**"
+func normalizeNamePattern(pattern string) string {
+	if len(pattern) == 0 || strings.Contains(pattern, "/") || strings.Contains(pattern, "**") {
+		return pattern
+	}
+	return officialNamespace + "/" + pattern
+}
+
+// ManifestExist ...
+func (a *adapter) ManifestExist(repository, reference string) (bool, string, error) {
+	return a.DefaultImageRegistry.ManifestExist(normalizeRepository(repository), reference)
+}
+
+// PullManifest ...
+func (a *adapter) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	return a.DefaultImageRegistry.PullManifest(normalizeRepository(repository), reference, accepttedMediaTypes)
+}
+
+// BlobExist ...
+func (a *adapter) BlobExist(repository, digest string) (bool, error) {
+	return a.DefaultImageRegistry.BlobExist(normalizeRepository(repository), digest)
+}
+
+// PullBlob ...
+func (a *adapter) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	return a.DefaultImageRegistry.PullBlob(normalizeRepository(repository), digest)
+}
+
 func (a *adapter) listCandidateNamespaces(pattern string) ([]string, error) {
 	namespaces := []string{}
 	if len(pattern) > 0 {
@@ -347,7 +411,7 @@ func (a *adapter) listCandidateNamespaces(pattern string) ([]string, error) {
 // DeleteManifest ...
 // Note: DockerHub only supports delete by tag
 func (a *adapter) DeleteManifest(repository, reference string) error {
-	parts := strings.Split(repository, "/")
+	parts := strings.Split(normalizeRepository(repository), "/")
 	if len(parts) != 2 {
 		return fmt.Errorf("dockerhub only support repo in format <namespace>/<name>, but got: %s", repository)
 	}
@@ -370,6 +434,11 @@ func (a *adapter) DeleteManifest(repository, reference string) error {
 	return nil
 }
 
+// DeleteTag deletes only the tag as DockerHub deletes the manifest by tag
+func (a *adapter) DeleteTag(repository, tag string) error {
+	return a.DeleteManifest(repository, tag)
+}
+
 // getRepos gets a page of repos from DockerHub
 func (a *adapter) getRepos(namespace, name string, page, pageSize int) (*ReposResp, error) {
 	resp, err := a.client.Do(http.MethodGet, listReposPath(namespace, name, page, pageSize), nil)
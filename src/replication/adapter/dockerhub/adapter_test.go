@@ -1,6 +1,7 @@
 package dockerhub
 
 import (
+	"context"
 	"fmt"
 	"testing"
 
@@ -43,6 +44,13 @@ func TestInfo(t *testing.T) {
 	assert.Equal(t, model.ResourceTypeImage, info.SupportedResourceTypes[0])
 }
 
+func TestDefaultNamespace(t *testing.T) {
+	var ad adp.Adapter = &adapter{}
+	provider, ok := ad.(adp.DefaultNamespaceProvider)
+	require.True(t, ok)
+	assert.Equal(t, "library", provider.DefaultNamespace())
+}
+
 func TestListCandidateNamespaces(t *testing.T) {
 	adapter := &adapter{}
 	namespaces, err := adapter.listCandidateNamespaces("library/*")
@@ -69,7 +77,7 @@ func TestListNamespaces(t *testing.T) {
 func TestFetchImages(t *testing.T) {
 	ad := getAdapter(t)
 	adapter := ad.(*adapter)
-	_, err := adapter.FetchImages([]*model.Filter{
+	_, err := adapter.FetchImages(context.Background(), []*model.Filter{
 		{
 			Type:  model.FilterTypeName,
 			Value: "goharbor/harbor-core",
@@ -9,6 +9,7 @@ import (
 	"net/http"
 
 	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/util"
 )
@@ -18,13 +19,16 @@ type Client struct {
 	client     *http.Client
 	token      string
 	host       string
+	userAgent  string
 	credential LoginCredential
+	limiter    rateLimiter
 }
 
 // NewClient creates a new DockerHub client.
 func NewClient(registry *model.Registry) (*Client, error) {
 	client := &Client{
-		host: registry.URL,
+		host:      registry.URL,
+		userAgent: adp.GetUserAgent(registry),
 		client: &http.Client{
 			Transport: util.GetHTTPTransport(false),
 		},
@@ -56,11 +60,12 @@ func (c *Client) refreshToken() error {
 		return fmt.Errorf("marshal credential error: %v", err)
 	}
 
-	request, err := http.NewRequest(http.MethodPost, baseURL+loginPath, bytes.NewReader(b))
+	request, err := http.NewRequest(http.MethodPost, c.host+loginPath, bytes.NewReader(b))
 	if err != nil {
 		return err
 	}
 	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", c.userAgent)
 
 	resp, err := c.client.Do(request)
 	if err != nil {
@@ -88,8 +93,14 @@ func (c *Client) refreshToken() error {
 }
 
 // Do performs http request to DockerHub, it will set token automatically.
+// The "RateLimitError" is returned without sending the request if the remaining
+// requests fall to the reserve, or if the request is rejected by the rate limit
 func (c *Client) Do(method, path string, body io.Reader) (*http.Response, error) {
-	url := baseURL + path
+	if err := c.limiter.check(); err != nil {
+		log.Warningf("back off from DockerHub: %v", err)
+		return nil, err
+	}
+	url := c.host + path
 	log.Infof("%s %s", method, url)
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
@@ -99,6 +110,15 @@ func (c *Client) Do(method, path string, body io.Reader) (*http.Response, error)
 		req.Header.Set("Content-Type", "application/json")
 	}
 	req.Header.Set("Authorization", fmt.Sprintf("JWT %s", c.token))
+	req.Header.Set("User-Agent", c.userAgent)
 
-	return c.client.Do(req)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.limiter.update(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
 }
@@ -9,6 +9,9 @@ const (
 	listNamespacePath   = "/v2/repositories/namespaces"
 	createNamespacePath = "/v2/orgs/"
 
+	// the namespace of the official images
+	officialNamespace = "library"
+
 	metadataKeyCompany  = "company"
 	metadataKeyFullName = "fullName"
 )
@@ -0,0 +1,120 @@
+package dockerhub
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitReserve is the count of the requests reserved under the rate limit of Docker Hub,
+// the adapter backs off once the remaining requests fall to it rather than exhausting them
+var RateLimitReserve = 5
+
+// RateLimitError is returned when the rate limit of Docker Hub is hit or is about to be hit.
+// The message contains "too many requests", so it's classified as rate limited and the
+// request is retried later
+type RateLimitError struct {
+	// the remaining requests in the current window, -1 if unknown
+	Remaining int
+	// when the rate limit is reset, zero if unknown
+	Reset time.Time
+}
+
+func (r *RateLimitError) Error() string {
+	msg := "too many requests to Docker Hub"
+	if r.Remaining >= 0 {
+		msg = fmt.Sprintf("%s, %d requests remaining", msg, r.Remaining)
+	}
+	if !r.Reset.IsZero() {
+		msg = fmt.Sprintf("%s, retry after %s", msg, r.Reset.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// the rate limit state tracked from the headers of the responses
+type rateLimiter struct {
+	sync.Mutex
+	known     bool
+	remaining int
+	reset     time.Time
+}
+
+// returns the error if the remaining requests fall to the reserve and the window isn't reset yet
+func (r *rateLimiter) check() error {
+	r.Lock()
+	defer r.Unlock()
+	if !r.known || r.remaining > RateLimitReserve {
+		return nil
+	}
+	if !r.reset.IsZero() && time.Now().After(r.reset) {
+		r.known = false
+		return nil
+	}
+	return &RateLimitError{
+		Remaining: r.remaining,
+		Reset:     r.reset,
+	}
+}
+
+// update the state by the headers of the response, the error is returned if
+// the request is rejected by the rate limit
+func (r *rateLimiter) update(resp *http.Response) error {
+	remaining, reset, ok := parseRateLimit(resp.Header, time.Now())
+	r.Lock()
+	if ok {
+		r.known, r.remaining, r.reset = true, remaining, reset
+	}
+	r.Unlock()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		return nil
+	}
+	err := &RateLimitError{
+		Remaining: -1,
+	}
+	if ok {
+		err.Remaining, err.Reset = remaining, reset
+	}
+	if seconds, e := strconv.Atoi(resp.Header.Get("Retry-After")); e == nil {
+		err.Reset = time.Now().Add(time.Duration(seconds) * time.Second)
+	}
+	return err
+}
+
+// parse the rate limit headers of Docker Hub. The Hub API returns "X-RateLimit-Remaining"
+// and "X-RateLimit-Reset"(the unix time), the registry returns "RateLimit-Remaining"
+// with the window, e.g. "76;w=21600", whose end is taken as the reset time
+func parseRateLimit(header http.Header, now time.Time) (int, time.Time, bool) {
+	if value := header.Get("X-RateLimit-Remaining"); len(value) > 0 {
+		remaining, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+		var reset time.Time
+		if seconds, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			reset = time.Unix(seconds, 0)
+		}
+		return remaining, reset, true
+	}
+	if value := header.Get("RateLimit-Remaining"); len(value) > 0 {
+		parts := strings.Split(value, ";")
+		remaining, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil {
+			return 0, time.Time{}, false
+		}
+		var reset time.Time
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if !strings.HasPrefix(part, "w=") {
+				continue
+			}
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(part, "w=")); err == nil {
+				reset = now.Add(time.Duration(seconds) * time.Second)
+			}
+		}
+		return remaining, reset, true
+	}
+	return 0, time.Time{}, false
+}
@@ -0,0 +1,151 @@
+package dockerhub
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/classifier"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	now := time.Now()
+	// the headers of the Hub API
+	header := http.Header{}
+	header.Set("X-RateLimit-Remaining", "76")
+	header.Set("X-RateLimit-Reset", "1600000000")
+	remaining, reset, ok := parseRateLimit(header, now)
+	require.True(t, ok)
+	assert.Equal(t, 76, remaining)
+	assert.Equal(t, time.Unix(1600000000, 0), reset)
+
+	// the headers of the registry
+	header = http.Header{}
+	header.Set("RateLimit-Remaining", "3;w=21600")
+	remaining, reset, ok = parseRateLimit(header, now)
+	require.True(t, ok)
+	assert.Equal(t, 3, remaining)
+	assert.Equal(t, now.Add(6*time.Hour), reset)
+
+	// no or invalid headers
+	_, _, ok = parseRateLimit(http.Header{}, now)
+	assert.False(t, ok)
+	header = http.Header{}
+	header.Set("RateLimit-Remaining", "invalid")
+	_, _, ok = parseRateLimit(header, now)
+	assert.False(t, ok)
+}
+
+// the fake Hub API serving the repositories and tags of the official images, the remaining
+// requests are counted down from "remaining" and the requests are rejected once it's 0
+type fakedHub struct {
+	sync.Mutex
+	remaining int
+	requests  []string
+}
+
+func (f *fakedHub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, r.URL.Path)
+	if f.remaining <= 0 {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	f.remaining--
+	w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", f.remaining))
+	w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(time.Hour).Unix()))
+	switch {
+	case r.URL.Path == "/v2/repositories/library/":
+		w.Write([]byte(`{"results":[{"name":"nginx","namespace":"library"},{"name":"redis","namespace":"library"}]}`))
+	case strings.HasSuffix(r.URL.Path, "/tags/"):
+		w.Write([]byte(`{"results":[{"name":"latest"}]}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newFakedHubAdapter(hub *fakedHub) (*adapter, func()) {
+	server := httptest.NewServer(hub)
+	return &adapter{
+		registry: &model.Registry{},
+		client: &Client{
+			client: server.Client(),
+			host:   server.URL,
+		},
+	}, server.Close
+}
+
+func TestFetchOfficialImages(t *testing.T) {
+	hub := &fakedHub{remaining: 100}
+	adapter, closer := newFakedHubAdapter(hub)
+	defer closer()
+	// the name without namespace refers to the official image
+	resources, err := adapter.FetchImages(context.Background(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "nginx",
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(resources))
+	assert.Equal(t, "library/nginx", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"latest"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, "library/nginx", normalizeRepository("nginx"))
+	assert.Equal(t, "goharbor/harbor-core", normalizeRepository("goharbor/harbor-core"))
+	assert.Equal(t, "**", normalizeNamePattern("**"))
+}
+
+func TestFetchImagesBackOffFromRateLimit(t *testing.T) {
+	reserve := RateLimitReserve
+	RateLimitReserve = 1
+	defer func() {
+		RateLimitReserve = reserve
+	}()
+
+	// the adapter backs off once the remaining requests fall to the reserve
+	hub := &fakedHub{remaining: 3}
+	adapter, closer := newFakedHubAdapter(hub)
+	defer closer()
+	_, err := adapter.FetchImages(context.Background(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/*",
+		},
+	})
+	require.NotNil(t, err)
+	e, ok := err.(*RateLimitError)
+	require.True(t, ok)
+	assert.Equal(t, 1, e.Remaining)
+	assert.True(t, e.Reset.After(time.Now()))
+	assert.True(t, classifier.IsRateLimited(err))
+	// the reserved requests aren't used
+	assert.Equal(t, 2, len(hub.requests))
+	assert.Equal(t, 1, hub.remaining)
+
+	// the request rejected by the rate limit
+	hub = &fakedHub{remaining: 0}
+	adapter, closer = newFakedHubAdapter(hub)
+	defer closer()
+	_, err = adapter.FetchImages(context.Background(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/*",
+		},
+	})
+	require.NotNil(t, err)
+	e, ok = err.(*RateLimitError)
+	require.True(t, ok)
+	assert.Equal(t, -1, e.Remaining)
+	assert.True(t, e.Reset.After(time.Now().Add(50*time.Second)))
+	assert.True(t, classifier.IsRateLimited(err))
+}
@@ -0,0 +1,109 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// Interaction is one call of the adapter recorded: the method with the arguments,
+// the result returned and the error if any
+type Interaction struct {
+	Key    string          `json:"key"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// Fixture contains the interactions with the registry recorded by the "Recorder"
+// in order, they are replayed by the "Replayer"
+type Fixture struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Save the fixture as JSON
+func (f *Fixture) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(f)
+}
+
+// Load the fixture saved by "Save"
+func Load(r io.Reader) (*Fixture, error) {
+	fixture := &Fixture{}
+	if err := json.NewDecoder(r).Decode(fixture); err != nil {
+		return nil, fmt.Errorf("failed to decode the fixture: %v", err)
+	}
+	return fixture, nil
+}
+
+// build the key of the interaction by the method and arguments, e.g.
+// ManifestExist("library/hello-world","latest")
+func key(method string, args ...interface{}) string {
+	var values []string
+	for _, arg := range args {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			data = []byte(fmt.Sprintf("%q", fmt.Sprintf("%v", arg)))
+		}
+		values = append(values, string(data))
+	}
+	return fmt.Sprintf("%s(%s)", method, strings.Join(values, ","))
+}
+
+// drop the registries of the resources: they carry the connection info and
+// credentials rather than the state of the registries
+func withoutRegistries(resources []*model.Resource) []*model.Resource {
+	var result []*model.Resource
+	for _, resource := range resources {
+		if resource == nil {
+			result = append(result, nil)
+			continue
+		}
+		res := *resource
+		res.Registry = nil
+		result = append(result, &res)
+	}
+	return result
+}
+
+// the results of the methods returning multiple values or the values that
+// cannot be encoded directly
+
+type registryInfo struct {
+	*model.RegistryInfo
+	// "SupportedResourceTypes" of "RegistryInfo" isn't encoded
+	ResourceTypes []model.ResourceType `json:"supported_resource_types"`
+}
+
+type manifestExistence struct {
+	Exist  bool   `json:"exist"`
+	Digest string `json:"digest"`
+}
+
+type pulledManifest struct {
+	MediaType string `json:"media_type"`
+	Payload   []byte `json:"payload"`
+	Digest    string `json:"digest"`
+}
+
+type pulledBlob struct {
+	Size int64  `json:"size"`
+	Data []byte `json:"data"`
+}
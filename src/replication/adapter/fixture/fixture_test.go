@@ -0,0 +1,165 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution/manifest/schema2"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdapter() (*test.Adapter, string) {
+	adapter := test.NewAdapter()
+	adapter.Images = []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+	config := adapter.AddBlob([]byte("config"))
+	payload := []byte(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"config": {
+			"mediaType": "application/vnd.docker.container.image.v1+json",
+			"size": 6,
+			"digest": "` + config + `"
+		},
+		"layers": []
+	}`)
+	adapter.AddManifest("library/hello-world", "latest", schema2.MediaTypeManifest, payload)
+	adapter.AddChart("library/harbor", "0.2.0", []byte("chart"))
+	return adapter, config
+}
+
+// run a sequence of calls against the adapter and collect the results
+func run(t *testing.T, adapter adp.Adapter, config string) []interface{} {
+	registry := adapter.(adp.ImageRegistry)
+	chartRegistry := adapter.(adp.ChartRegistry)
+	var results []interface{}
+
+	info, err := adapter.Info()
+	require.Nil(t, err)
+	results = append(results, info)
+
+	images, err := registry.FetchImages(context.Background(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/*",
+		},
+	})
+	require.Nil(t, err)
+	results = append(results, images)
+
+	exist, digest, err := registry.ManifestExist("library/hello-world", "latest")
+	require.Nil(t, err)
+	results = append(results, exist, digest)
+
+	manifest, digest, err := registry.PullManifest("library/hello-world", "latest", []string{schema2.MediaTypeManifest})
+	require.Nil(t, err)
+	_, payload, err := manifest.Payload()
+	require.Nil(t, err)
+	results = append(results, string(payload), digest)
+
+	size, blob, err := registry.PullBlob("library/hello-world", config)
+	require.Nil(t, err)
+	data, err := ioutil.ReadAll(blob)
+	require.Nil(t, err)
+	blob.Close()
+	results = append(results, size, string(data))
+
+	// the blob doesn't exist before pushing and exists after
+	exist, err = registry.BlobExist("mirror/hello-world", config)
+	require.Nil(t, err)
+	results = append(results, exist)
+	require.Nil(t, registry.PushBlob("mirror/hello-world", config, size, bytes.NewReader(data)))
+	exist, err = registry.BlobExist("mirror/hello-world", config)
+	require.Nil(t, err)
+	results = append(results, exist)
+
+	chart, err := chartRegistry.DownloadChart("library/harbor", "0.2.0")
+	require.Nil(t, err)
+	data, err = ioutil.ReadAll(chart)
+	require.Nil(t, err)
+	chart.Close()
+	results = append(results, string(data))
+
+	// the error is recorded as well
+	_, err = chartRegistry.DownloadChart("library/harbor", "0.3.0")
+	require.NotNil(t, err)
+	results = append(results, err.Error())
+	return results
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	adapter, config := newAdapter()
+	recorder := NewRecorder(adapter)
+	recorded := run(t, recorder, config)
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, recorder.Fixture().Save(buf))
+	fixture, err := Load(buf)
+	require.Nil(t, err)
+	assert.Equal(t, len(recorder.Fixture().Interactions), len(fixture.Interactions))
+
+	replayer := NewReplayer(fixture)
+	replayed := run(t, replayer, config)
+	assert.Equal(t, recorded, replayed)
+	// the adapter isn't called when replaying
+	assert.Equal(t, 1, adapter.CallCount("PullBlob"))
+
+	// the last interaction is repeated once the recorded ones are used up
+	exist, err := replayer.BlobExist("mirror/hello-world", config)
+	require.Nil(t, err)
+	assert.True(t, exist)
+
+	// the calls not recorded fail
+	_, err = replayer.BlobExist("mirror/hello-world", "sha256:unknown")
+	assert.NotNil(t, err)
+	_, err = replayer.FetchCharts(context.Background(), nil)
+	assert.NotNil(t, err)
+}
+
+func TestRecordError(t *testing.T) {
+	adapter, _ := newAdapter()
+	adapter.SetError("HealthCheck", errors.New("unreachable"))
+	recorder := NewRecorder(adapter)
+	_, err := recorder.HealthCheck()
+	require.NotNil(t, err)
+
+	replayer := NewReplayer(recorder.Fixture())
+	_, err = replayer.HealthCheck()
+	require.NotNil(t, err)
+	assert.Equal(t, "unreachable", err.Error())
+}
+
+func TestLoadInvalidFixture(t *testing.T) {
+	_, err := Load(bytes.NewReader([]byte("invalid")))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,319 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/docker/distribution"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+var (
+	_ adp.Adapter       = &Recorder{}
+	_ adp.ImageRegistry = &Recorder{}
+	_ adp.ChartRegistry = &Recorder{}
+)
+
+// Recorder wraps the adapter and records the interactions with the registry
+// into the fixture which can be replayed by the "Replayer" later
+type Recorder struct {
+	adapter adp.Adapter
+	sync.Mutex
+	fixture *Fixture
+}
+
+// NewRecorder returns an instance of the recorder wrapping the adapter
+func NewRecorder(adapter adp.Adapter) *Recorder {
+	return &Recorder{
+		adapter: adapter,
+		fixture: &Fixture{
+			Interactions: []*Interaction{},
+		},
+	}
+}
+
+// Factory returns an adapter factory which always returns the recorder
+func (r *Recorder) Factory() adp.Factory {
+	return func(*model.Registry) (adp.Adapter, error) {
+		return r, nil
+	}
+}
+
+// Fixture returns the interactions recorded so far
+func (r *Recorder) Fixture() *Fixture {
+	r.Lock()
+	defer r.Unlock()
+	return &Fixture{
+		Interactions: append([]*Interaction{}, r.fixture.Interactions...),
+	}
+}
+
+func (r *Recorder) record(key string, result interface{}, err error) {
+	interaction := &Interaction{
+		Key: key,
+	}
+	if err != nil {
+		interaction.Error = err.Error()
+	} else if result != nil {
+		data, e := json.Marshal(result)
+		if e != nil {
+			log.Errorf("failed to encode the result of %s: %v", key, e)
+		}
+		interaction.Result = data
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.fixture.Interactions = append(r.fixture.Interactions, interaction)
+}
+
+func (r *Recorder) imageRegistry() (adp.ImageRegistry, error) {
+	registry, ok := r.adapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, fmt.Errorf("the adapter doesn't implement the ImageRegistry interface")
+	}
+	return registry, nil
+}
+
+func (r *Recorder) chartRegistry() (adp.ChartRegistry, error) {
+	registry, ok := r.adapter.(adp.ChartRegistry)
+	if !ok {
+		return nil, fmt.Errorf("the adapter doesn't implement the ChartRegistry interface")
+	}
+	return registry, nil
+}
+
+// Info ...
+func (r *Recorder) Info() (*model.RegistryInfo, error) {
+	info, err := r.adapter.Info()
+	var result *registryInfo
+	if info != nil {
+		result = &registryInfo{
+			RegistryInfo:  info,
+			ResourceTypes: info.SupportedResourceTypes,
+		}
+	}
+	r.record(key("Info"), result, err)
+	return info, err
+}
+
+// PrepareForPush ...
+func (r *Recorder) PrepareForPush(resources []*model.Resource) error {
+	k := key("PrepareForPush", withoutRegistries(resources))
+	err := r.adapter.PrepareForPush(resources)
+	r.record(k, nil, err)
+	return err
+}
+
+// HealthCheck ...
+func (r *Recorder) HealthCheck() (model.HealthStatus, error) {
+	status, err := r.adapter.HealthCheck()
+	r.record(key("HealthCheck"), status, err)
+	return status, err
+}
+
+// FetchImages ...
+func (r *Recorder) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return nil, err
+	}
+	k := key("FetchImages", filters)
+	resources, err := registry.FetchImages(ctx, filters)
+	r.record(k, withoutRegistries(resources), err)
+	return resources, err
+}
+
+// ManifestExist ...
+func (r *Recorder) ManifestExist(repository, reference string) (bool, string, error) {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return false, "", err
+	}
+	exist, digest, err := registry.ManifestExist(repository, reference)
+	r.record(key("ManifestExist", repository, reference), &manifestExistence{
+		Exist:  exist,
+		Digest: digest,
+	}, err)
+	return exist, digest, err
+}
+
+// PullManifest ...
+func (r *Recorder) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return nil, "", err
+	}
+	k := key("PullManifest", repository, reference, accepttedMediaTypes)
+	manifest, digest, err := registry.PullManifest(repository, reference, accepttedMediaTypes)
+	if err != nil {
+		r.record(k, nil, err)
+		return nil, "", err
+	}
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		r.record(k, nil, err)
+		return nil, "", err
+	}
+	r.record(k, &pulledManifest{
+		MediaType: mediaType,
+		Payload:   payload,
+		Digest:    digest,
+	}, nil)
+	return manifest, digest, nil
+}
+
+// PushManifest ...
+func (r *Recorder) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return err
+	}
+	err = registry.PushManifest(repository, reference, mediaType, payload)
+	r.record(key("PushManifest", repository, reference, mediaType, payload), nil, err)
+	return err
+}
+
+// DeleteManifest ...
+func (r *Recorder) DeleteManifest(repository, reference string) error {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return err
+	}
+	err = registry.DeleteManifest(repository, reference)
+	r.record(key("DeleteManifest", repository, reference), nil, err)
+	return err
+}
+
+// BlobExist ...
+func (r *Recorder) BlobExist(repository, digest string) (bool, error) {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return false, err
+	}
+	exist, err := registry.BlobExist(repository, digest)
+	r.record(key("BlobExist", repository, digest), exist, err)
+	return exist, err
+}
+
+// PullBlob reads the whole blob to record it
+func (r *Recorder) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return 0, nil, err
+	}
+	k := key("PullBlob", repository, digest)
+	size, blob, err := registry.PullBlob(repository, digest)
+	if err != nil {
+		r.record(k, nil, err)
+		return 0, nil, err
+	}
+	defer blob.Close()
+	data, err := ioutil.ReadAll(blob)
+	if err != nil {
+		r.record(k, nil, err)
+		return 0, nil, err
+	}
+	r.record(k, &pulledBlob{
+		Size: size,
+		Data: data,
+	}, nil)
+	return size, ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// PushBlob ...
+func (r *Recorder) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	registry, err := r.imageRegistry()
+	if err != nil {
+		return err
+	}
+	err = registry.PushBlob(repository, digest, size, blob)
+	r.record(key("PushBlob", repository, digest, size), nil, err)
+	return err
+}
+
+// FetchCharts ...
+func (r *Recorder) FetchCharts(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	registry, err := r.chartRegistry()
+	if err != nil {
+		return nil, err
+	}
+	k := key("FetchCharts", filters)
+	resources, err := registry.FetchCharts(ctx, filters)
+	r.record(k, withoutRegistries(resources), err)
+	return resources, err
+}
+
+// ChartExist ...
+func (r *Recorder) ChartExist(name, version string) (bool, error) {
+	registry, err := r.chartRegistry()
+	if err != nil {
+		return false, err
+	}
+	exist, err := registry.ChartExist(name, version)
+	r.record(key("ChartExist", name, version), exist, err)
+	return exist, err
+}
+
+// DownloadChart reads the whole chart to record it
+func (r *Recorder) DownloadChart(name, version string) (io.ReadCloser, error) {
+	registry, err := r.chartRegistry()
+	if err != nil {
+		return nil, err
+	}
+	k := key("DownloadChart", name, version)
+	chart, err := registry.DownloadChart(name, version)
+	if err != nil {
+		r.record(k, nil, err)
+		return nil, err
+	}
+	defer chart.Close()
+	data, err := ioutil.ReadAll(chart)
+	r.record(k, data, err)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// UploadChart ...
+func (r *Recorder) UploadChart(name, version string, chart io.Reader) error {
+	registry, err := r.chartRegistry()
+	if err != nil {
+		return err
+	}
+	err = registry.UploadChart(name, version, chart)
+	r.record(key("UploadChart", name, version), nil, err)
+	return err
+}
+
+// DeleteChart ...
+func (r *Recorder) DeleteChart(name, version string) error {
+	registry, err := r.chartRegistry()
+	if err != nil {
+		return err
+	}
+	err = registry.DeleteChart(name, version)
+	r.record(key("DeleteChart", name, version), nil, err)
+	return err
+}
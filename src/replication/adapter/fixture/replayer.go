@@ -0,0 +1,230 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fixture
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/docker/distribution"
+	registry_pkg "github.com/goharbor/harbor/src/common/utils/registry"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+var (
+	_ adp.Adapter       = &Replayer{}
+	_ adp.ImageRegistry = &Replayer{}
+	_ adp.ChartRegistry = &Replayer{}
+)
+
+// Replayer is the adapter replaying the interactions of the fixture without
+// connecting to any registry. The interactions with the same method and arguments
+// are replayed in the order they are recorded and the last one is repeated once
+// they are used up, the calls that aren't recorded fail
+type Replayer struct {
+	sync.Mutex
+	interactions map[string][]*Interaction
+	replayed     map[string]int
+}
+
+// NewReplayer returns an instance of the replayer for the fixture
+func NewReplayer(fixture *Fixture) *Replayer {
+	replayer := &Replayer{
+		interactions: map[string][]*Interaction{},
+		replayed:     map[string]int{},
+	}
+	if fixture != nil {
+		for _, interaction := range fixture.Interactions {
+			replayer.interactions[interaction.Key] = append(replayer.interactions[interaction.Key], interaction)
+		}
+	}
+	return replayer
+}
+
+// Factory returns an adapter factory which always returns the replayer
+func (r *Replayer) Factory() adp.Factory {
+	return func(*model.Registry) (adp.Adapter, error) {
+		return r, nil
+	}
+}
+
+// replay the interaction specified by the key and decode the result into "result"
+func (r *Replayer) replay(key string, result interface{}) error {
+	r.Lock()
+	interactions := r.interactions[key]
+	if len(interactions) == 0 {
+		r.Unlock()
+		return fmt.Errorf("the interaction %s isn't recorded", key)
+	}
+	i := r.replayed[key]
+	if i >= len(interactions) {
+		i = len(interactions) - 1
+	}
+	r.replayed[key]++
+	interaction := interactions[i]
+	r.Unlock()
+
+	if len(interaction.Error) > 0 {
+		return errors.New(interaction.Error)
+	}
+	if result == nil || len(interaction.Result) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(interaction.Result, result); err != nil {
+		return fmt.Errorf("failed to decode the result of %s: %v", key, err)
+	}
+	return nil
+}
+
+// Info ...
+func (r *Replayer) Info() (*model.RegistryInfo, error) {
+	info := &registryInfo{}
+	if err := r.replay(key("Info"), info); err != nil {
+		return nil, err
+	}
+	if info.RegistryInfo == nil {
+		return nil, nil
+	}
+	info.RegistryInfo.SupportedResourceTypes = info.ResourceTypes
+	return info.RegistryInfo, nil
+}
+
+// PrepareForPush ...
+func (r *Replayer) PrepareForPush(resources []*model.Resource) error {
+	return r.replay(key("PrepareForPush", withoutRegistries(resources)), nil)
+}
+
+// HealthCheck ...
+func (r *Replayer) HealthCheck() (model.HealthStatus, error) {
+	var status model.HealthStatus
+	if err := r.replay(key("HealthCheck"), &status); err != nil {
+		return status, err
+	}
+	return status, nil
+}
+
+// FetchImages ...
+func (r *Replayer) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	var resources []*model.Resource
+	if err := r.replay(key("FetchImages", filters), &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// ManifestExist ...
+func (r *Replayer) ManifestExist(repository, reference string) (bool, string, error) {
+	existence := &manifestExistence{}
+	if err := r.replay(key("ManifestExist", repository, reference), existence); err != nil {
+		return false, "", err
+	}
+	return existence.Exist, existence.Digest, nil
+}
+
+// PullManifest ...
+func (r *Replayer) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	m := &pulledManifest{}
+	if err := r.replay(key("PullManifest", repository, reference, accepttedMediaTypes), m); err != nil {
+		return nil, "", err
+	}
+	manifest, _, err := registry_pkg.UnMarshal(m.MediaType, m.Payload)
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, m.Digest, nil
+}
+
+// PushManifest ...
+func (r *Replayer) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	return r.replay(key("PushManifest", repository, reference, mediaType, payload), nil)
+}
+
+// DeleteManifest ...
+func (r *Replayer) DeleteManifest(repository, reference string) error {
+	return r.replay(key("DeleteManifest", repository, reference), nil)
+}
+
+// BlobExist ...
+func (r *Replayer) BlobExist(repository, digest string) (bool, error) {
+	var exist bool
+	if err := r.replay(key("BlobExist", repository, digest), &exist); err != nil {
+		return false, err
+	}
+	return exist, nil
+}
+
+// PullBlob ...
+func (r *Replayer) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	blob := &pulledBlob{}
+	if err := r.replay(key("PullBlob", repository, digest), blob); err != nil {
+		return 0, nil, err
+	}
+	return blob.Size, ioutil.NopCloser(bytes.NewReader(blob.Data)), nil
+}
+
+// PushBlob consumes the blob as the registry does
+func (r *Replayer) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	if _, err := io.Copy(ioutil.Discard, blob); err != nil {
+		return err
+	}
+	return r.replay(key("PushBlob", repository, digest, size), nil)
+}
+
+// FetchCharts ...
+func (r *Replayer) FetchCharts(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	var resources []*model.Resource
+	if err := r.replay(key("FetchCharts", filters), &resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// ChartExist ...
+func (r *Replayer) ChartExist(name, version string) (bool, error) {
+	var exist bool
+	if err := r.replay(key("ChartExist", name, version), &exist); err != nil {
+		return false, err
+	}
+	return exist, nil
+}
+
+// DownloadChart ...
+func (r *Replayer) DownloadChart(name, version string) (io.ReadCloser, error) {
+	var data []byte
+	if err := r.replay(key("DownloadChart", name, version), &data); err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// UploadChart consumes the chart as the registry does
+func (r *Replayer) UploadChart(name, version string, chart io.Reader) error {
+	if _, err := io.Copy(ioutil.Discard, chart); err != nil {
+		return err
+	}
+	return r.replay(key("UploadChart", name, version), nil)
+}
+
+// DeleteChart ...
+func (r *Replayer) DeleteChart(name, version string) error {
+	return r.replay(key("DeleteChart", name, version), nil)
+}
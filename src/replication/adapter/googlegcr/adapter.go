@@ -19,7 +19,6 @@ import (
 	"github.com/goharbor/harbor/src/common/utils/registry/auth"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
-	"github.com/goharbor/harbor/src/replication/util"
 	"net/http"
 )
 
@@ -41,7 +40,7 @@ func newAdapter(registry *model.Registry) (*adapter, error) {
 			registry.Credential.AccessSecret)
 	}
 	authorizer := auth.NewStandardTokenAuthorizer(&http.Client{
-		Transport: util.GetHTTPTransport(registry.Insecure),
+		Transport: adp.GetHTTPTransport(registry),
 	}, credential)
 
 	reg, err := adp.NewDefaultImageRegistryWithCustomizedAuthorizer(registry, authorizer)
@@ -1,6 +1,7 @@
 package googlegcr
 
 import (
+	"context"
 	"fmt"
 	"github.com/goharbor/harbor/src/common/utils/test"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
@@ -145,7 +146,7 @@ func TestAdapter_PrepareForPush(t *testing.T) {
 func TestAdapter_FetchImages(t *testing.T) {
 	a, s := getMockAdapter(t, true, true)
 	defer s.Close()
-	resources, err := a.FetchImages([]*model.Filter{
+	resources, err := a.FetchImages(context.Background(), []*model.Filter{
 		{
 			Type:  model.FilterTypeName,
 			Value: "*",
@@ -23,12 +23,11 @@ import (
 
 	common_http "github.com/goharbor/harbor/src/common/http"
 	"github.com/goharbor/harbor/src/common/http/modifier"
-	common_http_auth "github.com/goharbor/harbor/src/common/http/modifier/auth"
 	"github.com/goharbor/harbor/src/common/utils/log"
+	registry_pkg "github.com/goharbor/harbor/src/common/utils/registry"
 	"github.com/goharbor/harbor/src/common/utils/registry/auth"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
-	"github.com/goharbor/harbor/src/replication/util"
 )
 
 func init() {
@@ -49,23 +48,19 @@ type adapter struct {
 }
 
 func newAdapter(registry *model.Registry) (*adapter, error) {
-	transport := util.GetHTTPTransport(registry.Insecure)
+	var transport http.RoundTripper = adp.GetHTTPTransport(registry)
+	provider := adp.GetCredentialProvider(registry.Type)
+	if registry.Credential != nil || provider != adp.StaticCredentialProvider {
+		// the credential modifier is applied by the transport to make sure
+		// the retried request carries the refreshed credential
+		transport = adp.NewCredentialRefreshTransport(registry, provider,
+			registry_pkg.NewTransport(transport, adp.NewCredentialModifier(registry, provider)), nil)
+	}
 	modifiers := []modifier.Modifier{
 		&auth.UserAgentModifier{
-			UserAgent: adp.UserAgentReplication,
+			UserAgent: adp.GetUserAgent(registry),
 		},
 	}
-	if registry.Credential != nil {
-		var authorizer modifier.Modifier
-		if registry.Credential.Type == model.CredentialTypeSecret {
-			authorizer = common_http_auth.NewSecretAuthorizer(registry.Credential.AccessSecret)
-		} else {
-			authorizer = auth.NewBasicAuthCredential(
-				registry.Credential.AccessKey,
-				registry.Credential.AccessSecret)
-		}
-		modifiers = append(modifiers, authorizer)
-	}
 
 	reg, err := adp.NewDefaultImageRegistry(registry)
 	if err != nil {
@@ -76,7 +71,7 @@ func newAdapter(registry *model.Registry) (*adapter, error) {
 		url:      registry.URL,
 		client: common_http.NewClient(
 			&http.Client{
-				Transport: transport,
+				Transport: adp.NewWarningTransport(registry, transport),
 			}, modifiers...),
 		DefaultImageRegistry: reg,
 	}, nil
@@ -156,29 +151,46 @@ func (a *adapter) PrepareForPush(resources []*model.Resource) error {
 		projectName := paths[0]
 		// handle the public properties
 		metadata := resource.Metadata.Repository.Metadata
+		settings := resource.ProjectSettings
 		pro, exist := projects[projectName]
 		if exist {
 			metadata = mergeMetadata(pro.Metadata, metadata)
+			if pro.settings != nil {
+				settings = pro.settings
+			}
 		}
 		projects[projectName] = &project{
 			Name:     projectName,
 			Metadata: metadata,
+			settings: settings,
 		}
 	}
 	for _, project := range projects {
 		pro := struct {
-			Name     string                 `json:"project_name"`
-			Metadata map[string]interface{} `json:"metadata"`
+			Name         string                 `json:"project_name"`
+			Metadata     map[string]interface{} `json:"metadata"`
+			CVEWhitelist *cveWhitelist          `json:"cve_whitelist,omitempty"`
+			StorageLimit *int64                 `json:"storage_limit,omitempty"`
 		}{
 			Name:     project.Name,
 			Metadata: project.Metadata,
 		}
-		err := a.client.Post(a.getURL()+"/api/projects", pro)
-		if err != nil {
-			if httpErr, ok := err.(*common_http.Error); ok && httpErr.Code == http.StatusConflict {
-				log.Debugf("got 409 when trying to create project %s", project.Name)
-				continue
+		// the settings are applied only when creating the project as the request
+		// gets 409 if the project exists already
+		if settings := project.settings; settings != nil {
+			pro.Metadata = applyProjectSettings(project.Metadata, settings)
+			if len(settings.CVEAllowlist) > 0 {
+				pro.CVEWhitelist = &cveWhitelist{}
+				for _, cve := range settings.CVEAllowlist {
+					pro.CVEWhitelist.Items = append(pro.CVEWhitelist.Items, &cveWhitelistItem{CVEID: cve})
+				}
 			}
+			pro.StorageLimit = settings.StorageLimit
+		}
+		err := adp.CreateNamespaceWithRetry(project.Name, func() error {
+			return a.client.Post(a.getURL()+"/api/projects", pro)
+		})
+		if err != nil {
 			return err
 		}
 		log.Debugf("project %s created", project.Name)
@@ -218,10 +230,35 @@ func parsePublic(metadata map[string]interface{}) bool {
 	return false
 }
 
+// apply the settings to a copy of the project metadata
+func applyProjectSettings(metadata map[string]interface{}, settings *model.ProjectSettings) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, value := range metadata {
+		result[key] = value
+	}
+	if settings.AutoScan != nil {
+		result["auto_scan"] = strconv.FormatBool(*settings.AutoScan)
+	}
+	if len(settings.CVEAllowlist) > 0 {
+		result["reuse_sys_cve_whitelist"] = "false"
+	}
+	return result
+}
+
 type project struct {
 	ID       int64                  `json:"project_id"`
 	Name     string                 `json:"name"`
 	Metadata map[string]interface{} `json:"metadata"`
+	// the settings applied when creating the project
+	settings *model.ProjectSettings
+}
+
+type cveWhitelist struct {
+	Items []*cveWhitelistItem `json:"items"`
+}
+
+type cveWhitelistItem struct {
+	CVEID string `json:"cve_id"`
 }
 
 func (a *adapter) getProjects(name string) ([]*project, error) {
@@ -259,6 +296,15 @@ func (a *adapter) getProject(name string) (*project, error) {
 	return nil, nil
 }
 
+// NamespaceExist checks whether the project exists
+func (a *adapter) NamespaceExist(namespace string) (bool, error) {
+	project, err := a.getProject(namespace)
+	if err != nil {
+		return false, err
+	}
+	return project != nil, nil
+}
+
 func (a *adapter) getRepositories(projectID int64) ([]*adp.Repository, error) {
 	repositories := []*adp.Repository{}
 	url := fmt.Sprintf("%s/api/repositories?project_id=%d&page=1&page_size=500", a.getURL(), projectID)
@@ -15,7 +15,9 @@
 package harbor
 
 import (
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"testing"
 
@@ -152,6 +154,190 @@ func TestPrepareForPush(t *testing.T) {
 			},
 		})
 	require.Nil(t, err)
+
+	server.Close()
+
+	// the project is being created by the concurrent execution, got
+	// the transient error first and then the conflict
+	calls := 0
+	server = test.NewServer(&test.RequestHandlerMapping{
+		Method:  http.MethodPost,
+		Pattern: "/api/projects",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusConflict)
+		},
+	})
+	defer server.Close()
+	registry = &model.Registry{
+		URL: server.URL,
+	}
+	adapter, err = newAdapter(registry)
+	require.Nil(t, err)
+	err = adapter.PrepareForPush(
+		[]*model.Resource{
+			{
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+				},
+			},
+		})
+	require.Nil(t, err)
+	assert.Equal(t, 2, calls)
+}
+
+func TestPrepareForPushWithProjectSettings(t *testing.T) {
+	var created map[string]interface{}
+	server := test.NewServer(&test.RequestHandlerMapping{
+		Method:  http.MethodPost,
+		Pattern: "/api/projects",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			created = map[string]interface{}{}
+			require.Nil(t, json.NewDecoder(r.Body).Decode(&created))
+			w.WriteHeader(http.StatusCreated)
+		},
+	})
+	defer server.Close()
+	adapter, err := newAdapter(&model.Registry{
+		URL: server.URL,
+	})
+	require.Nil(t, err)
+
+	limit := int64(1024)
+	autoScan := true
+	err = adapter.PrepareForPush([]*model.Resource{
+		{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+					Metadata: map[string]interface{}{
+						"public": "true",
+					},
+				},
+			},
+			ProjectSettings: &model.ProjectSettings{
+				StorageLimit: &limit,
+				CVEAllowlist: []string{"CVE-2019-10164"},
+				AutoScan:     &autoScan,
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.NotNil(t, created)
+	assert.Equal(t, "library", created["project_name"])
+	assert.Equal(t, float64(1024), created["storage_limit"])
+	assert.Equal(t, map[string]interface{}{
+		"public":                  "true",
+		"auto_scan":               "true",
+		"reuse_sys_cve_whitelist": "false",
+	}, created["metadata"])
+	assert.Equal(t, map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"cve_id": "CVE-2019-10164"},
+		},
+	}, created["cve_whitelist"])
+
+	// no settings
+	created = nil
+	err = adapter.PrepareForPush([]*model.Resource{
+		{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+			},
+		},
+	})
+	require.Nil(t, err)
+	require.NotNil(t, created)
+	_, exist := created["storage_limit"]
+	assert.False(t, exist)
+	_, exist = created["cve_whitelist"]
+	assert.False(t, exist)
+
+	// the project exists already, the settings aren't applied by any other request
+	requests := 0
+	existing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method == http.MethodPost && r.URL.Path == "/api/projects" {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer existing.Close()
+	adapter, err = newAdapter(&model.Registry{
+		URL: existing.URL,
+	})
+	require.Nil(t, err)
+	err = adapter.PrepareForPush([]*model.Resource{
+		{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+			},
+			ProjectSettings: &model.ProjectSettings{
+				StorageLimit: &limit,
+				AutoScan:     &autoScan,
+			},
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 1, requests)
+}
+
+func TestNamespaceExist(t *testing.T) {
+	server := test.NewServer(&test.RequestHandlerMapping{
+		Method:  http.MethodGet,
+		Pattern: "/api/projects",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			data := `[{"project_id":1,"name":"library"},{"project_id":2,"name":"library2"}]`
+			w.Write([]byte(data))
+		},
+	})
+	defer server.Close()
+	registry := &model.Registry{
+		URL: server.URL,
+	}
+	adapter, err := newAdapter(registry)
+	require.Nil(t, err)
+
+	exist, err := adapter.NamespaceExist("library")
+	require.Nil(t, err)
+	assert.True(t, exist)
+
+	exist, err = adapter.NamespaceExist("libary")
+	require.Nil(t, err)
+	assert.False(t, exist)
+}
+
+func TestUserAgent(t *testing.T) {
+	var userAgent string
+	server := test.NewServer(&test.RequestHandlerMapping{
+		Method:  http.MethodGet,
+		Pattern: "/api/systeminfo",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			userAgent = r.UserAgent()
+			w.Write([]byte(`{"with_chartmuseum":false}`))
+		},
+	})
+	defer server.Close()
+	registry := &model.Registry{
+		URL:       server.URL,
+		UserAgent: "mirror/policy01",
+	}
+	adapter, err := newAdapter(registry)
+	require.Nil(t, err)
+	_, err = adapter.Info()
+	require.Nil(t, err)
+	assert.Equal(t, "mirror/policy01", userAgent)
 }
 
 func TestParsePublic(t *testing.T) {
@@ -16,6 +16,7 @@ package harbor
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -45,7 +46,7 @@ type chartVersionMetadata struct {
 	URLs []string `json:"urls"`
 }
 
-func (a *adapter) FetchCharts(filters []*model.Filter) ([]*model.Resource, error) {
+func (a *adapter) FetchCharts(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 	projects, err := a.listCandidateProjects(filters)
 	if err != nil {
 		return nil, err
@@ -70,6 +71,10 @@ func (a *adapter) FetchCharts(filters []*model.Filter) ([]*model.Resource, error
 			}
 		}
 		for _, repository := range repositories {
+			// stop fetching once the caller gives up, e.g. the fetching is timed out
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			name := strings.SplitN(repository.Name, "/", 2)[1]
 			url := fmt.Sprintf("%s/api/chartrepo/%s/charts/%s", a.getURL(), project.Name, name)
 			versions := []*chartVersion{}
@@ -106,7 +111,11 @@ func (a *adapter) FetchCharts(filters []*model.Filter) ([]*model.Resource, error
 							Name:     repository.Name,
 							Metadata: project.Metadata,
 						},
-						Vtags: []string{vTag.Name},
+						Vtags:  []string{vTag.Name},
+						Labels: vTag.Labels,
+					},
+					ExtendedInfo: map[string]interface{}{
+						model.ExtendedInfoPublic: parsePublic(project.Metadata),
 					},
 				})
 			}
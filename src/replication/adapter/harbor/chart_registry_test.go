@@ -16,6 +16,7 @@ package harbor
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"testing"
 
@@ -70,13 +71,16 @@ func TestFetchCharts(t *testing.T) {
 	adapter, err := newAdapter(registry)
 	require.Nil(t, err)
 	// nil filter
-	resources, err := adapter.FetchCharts(nil)
+	resources, err := adapter.FetchCharts(context.Background(), nil)
 	require.Nil(t, err)
 	assert.Equal(t, 2, len(resources))
 	assert.Equal(t, model.ResourceTypeChart, resources[0].Type)
 	assert.Equal(t, "library/harbor", resources[0].Metadata.Repository.Name)
 	assert.Equal(t, 1, len(resources[0].Metadata.Vtags))
 	assert.Equal(t, "1.0", resources[0].Metadata.Vtags[0])
+	public, known := resources[0].IsPublic()
+	assert.True(t, known)
+	assert.True(t, public)
 	// not nil filter
 	filters := []*model.Filter{
 		{
@@ -88,7 +92,7 @@ func TestFetchCharts(t *testing.T) {
 			Value: "1.0",
 		},
 	}
-	resources, err = adapter.FetchCharts(filters)
+	resources, err = adapter.FetchCharts(context.Background(), filters)
 	require.Nil(t, err)
 	require.Equal(t, 1, len(resources))
 	assert.Equal(t, model.ResourceTypeChart, resources[0].Type)
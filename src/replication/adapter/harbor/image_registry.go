@@ -15,8 +15,10 @@
 package harbor
 
 import (
+	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/goharbor/harbor/src/common/utils/log"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
@@ -24,7 +26,7 @@ import (
 	"github.com/goharbor/harbor/src/replication/util"
 )
 
-func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error) {
+func (a *adapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 	projects, err := a.listCandidateProjects(filters)
 	if err != nil {
 		return nil, err
@@ -44,6 +46,10 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 			}
 		}
 		for _, repository := range repositories {
+			// stop fetching once the caller gives up, e.g. the fetching is timed out
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			vTags, err := a.getTags(repository.Name)
 			if err != nil {
 				return nil, err
@@ -71,7 +77,13 @@ func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error
 						Name:     repository.Name,
 						Metadata: project.Metadata,
 					},
-					Vtags: tags,
+					Vtags:   tags,
+					Labels:  getLabels(vTags),
+					Digests: getDigests(vTags),
+				},
+				ExtendedInfo: map[string]interface{}{
+					model.ExtendedInfoPublic:    parsePublic(project.Metadata),
+					model.ExtendedInfoPullCount: repository.PullCount,
 				},
 			})
 		}
@@ -124,10 +136,56 @@ func (a *adapter) DeleteManifest(repository, reference string) error {
 	return a.client.Delete(url)
 }
 
+// ListTagCreationTimes lists the creation time of the tags under the repository
+func (a *adapter) ListTagCreationTimes(repository string) (map[string]time.Time, error) {
+	url := fmt.Sprintf("%s/api/repositories/%s/tags", a.getURL(), repository)
+	tags := []*struct {
+		Name    string    `json:"name"`
+		Created time.Time `json:"created"`
+	}{}
+	if err := a.client.Get(url, &tags); err != nil {
+		return nil, err
+	}
+	times := map[string]time.Time{}
+	for _, tag := range tags {
+		times[tag.Name] = tag.Created
+	}
+	return times, nil
+}
+
+// returns the distinct labels of the tags, they are set as the labels of the
+// resource so that the label filter can be applied by the flow
+func getLabels(vTags []*adp.VTag) []string {
+	labels := []string{}
+	exist := map[string]struct{}{}
+	for _, vTag := range vTags {
+		for _, label := range vTag.Labels {
+			if _, ok := exist[label]; ok {
+				continue
+			}
+			exist[label] = struct{}{}
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// get the digests of the tags indexed by the tag names
+func getDigests(vTags []*adp.VTag) map[string]string {
+	digests := map[string]string{}
+	for _, vTag := range vTags {
+		if len(vTag.Digest) > 0 {
+			digests[vTag.Name] = vTag.Digest
+		}
+	}
+	return digests
+}
+
 func (a *adapter) getTags(repository string) ([]*adp.VTag, error) {
 	url := fmt.Sprintf("%s/api/repositories/%s/tags", a.getURL(), repository)
 	tags := []*struct {
 		Name   string `json:"name"`
+		Digest string `json:"digest"`
 		Labels []*struct {
 			Name string `json:"name"`
 		}
@@ -144,6 +202,7 @@ func (a *adapter) getTags(repository string) ([]*adp.VTag, error) {
 		vTags = append(vTags, &adp.VTag{
 			Name:         tag.Name,
 			Labels:       labels,
+			Digest:       tag.Digest,
 			ResourceType: string(model.ResourceTypeImage),
 		})
 	}
@@ -15,8 +15,10 @@
 package harbor
 
 import (
+	"context"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/goharbor/harbor/src/common/utils/test"
 	"github.com/goharbor/harbor/src/replication/model"
@@ -42,7 +44,8 @@ func TestFetchImages(t *testing.T) {
 			Pattern: "/api/repositories/library/hello-world/tags",
 			Handler: func(w http.ResponseWriter, r *http.Request) {
 				data := `[{
-					"name": "1.0"
+					"name": "1.0",
+					"digest": "sha256:5c7fc6d7b2d9e2b2e3f8b3d5e0d8b8b4e5c1a1b2c3d4e5f60718293a4b5c6d7e"
 				},{
 					"name": "2.0"
 				}]`
@@ -54,7 +57,8 @@ func TestFetchImages(t *testing.T) {
 			Pattern: "/api/repositories",
 			Handler: func(w http.ResponseWriter, r *http.Request) {
 				data := `[{
-					"name": "library/hello-world"
+					"name": "library/hello-world",
+					"pull_count": 10
 				}]`
 				w.Write([]byte(data))
 			},
@@ -67,7 +71,7 @@ func TestFetchImages(t *testing.T) {
 	adapter, err := newAdapter(registry)
 	require.Nil(t, err)
 	// nil filter
-	resources, err := adapter.FetchImages(nil)
+	resources, err := adapter.FetchImages(context.Background(), nil)
 	require.Nil(t, err)
 	assert.Equal(t, 1, len(resources))
 	assert.Equal(t, model.ResourceTypeImage, resources[0].Type)
@@ -75,6 +79,16 @@ func TestFetchImages(t *testing.T) {
 	assert.Equal(t, 2, len(resources[0].Metadata.Vtags))
 	assert.Equal(t, "1.0", resources[0].Metadata.Vtags[0])
 	assert.Equal(t, "2.0", resources[0].Metadata.Vtags[1])
+	// only the digests returned by the API are populated
+	assert.Equal(t, map[string]string{
+		"1.0": "sha256:5c7fc6d7b2d9e2b2e3f8b3d5e0d8b8b4e5c1a1b2c3d4e5f60718293a4b5c6d7e",
+	}, resources[0].Metadata.Digests)
+	public, known := resources[0].IsPublic()
+	assert.True(t, known)
+	assert.True(t, public)
+	count, known := resources[0].GetPullCount()
+	assert.True(t, known)
+	assert.Equal(t, int64(10), count)
 	// not nil filter
 	filters := []*model.Filter{
 		{
@@ -86,13 +100,18 @@ func TestFetchImages(t *testing.T) {
 			Value: "1.0",
 		},
 	}
-	resources, err = adapter.FetchImages(filters)
+	resources, err = adapter.FetchImages(context.Background(), filters)
 	require.Nil(t, err)
 	assert.Equal(t, 1, len(resources))
 	assert.Equal(t, model.ResourceTypeImage, resources[0].Type)
 	assert.Equal(t, "library/hello-world", resources[0].Metadata.Repository.Name)
 	assert.Equal(t, 1, len(resources[0].Metadata.Vtags))
 	assert.Equal(t, "1.0", resources[0].Metadata.Vtags[0])
+	// the fetching is aborted once the context is done
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = adapter.FetchImages(ctx, nil)
+	assert.Equal(t, context.Canceled, err)
 }
 
 func TestDeleteManifest(t *testing.T) {
@@ -111,3 +130,30 @@ func TestDeleteManifest(t *testing.T) {
 	err = adapter.DeleteManifest("library/hello-world", "1.0")
 	require.Nil(t, err)
 }
+
+func TestListTagCreationTimes(t *testing.T) {
+	server := test.NewServer(&test.RequestHandlerMapping{
+		Method:  http.MethodGet,
+		Pattern: "/api/repositories/library/hello-world/tags",
+		Handler: func(w http.ResponseWriter, r *http.Request) {
+			data := `[{
+				"name": "1.0",
+				"created": "2019-01-01T00:00:00Z"
+			},{
+				"name": "2.0",
+				"created": "2019-02-01T00:00:00Z"
+			}]`
+			w.Write([]byte(data))
+		}})
+	defer server.Close()
+	registry := &model.Registry{
+		URL: server.URL,
+	}
+	adapter, err := newAdapter(registry)
+	require.Nil(t, err)
+	times, err := adapter.ListTagCreationTimes("library/hello-world")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(times))
+	assert.Equal(t, time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), times["1.0"].UTC())
+	assert.Equal(t, time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC), times["2.0"].UTC())
+}
@@ -13,7 +13,6 @@ import (
 	"github.com/goharbor/harbor/src/common/utils/log"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
-	"github.com/goharbor/harbor/src/replication/util"
 )
 
 func init() {
@@ -133,7 +132,7 @@ func (a *adapter) PrepareForPush(resources []*model.Resource) error {
 
 	url := fmt.Sprintf("%s/dockyard/v2/namespaces", a.registry.URL)
 	client := &http.Client{
-		Transport: util.GetHTTPTransport(a.registry.Insecure),
+		Transport: adp.GetHTTPTransport(a.registry),
 	}
 	for namespace := range namespaces {
 		namespacebyte, err := json.Marshal(struct {
@@ -1,6 +1,7 @@
 package huawei
 
 import (
+	"context"
 	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
@@ -13,7 +14,7 @@ import (
 )
 
 // FetchImages gets resources from Huawei SWR
-func (a *adapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error) {
+func (a *adapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 
 	resources := []*model.Resource{}
 
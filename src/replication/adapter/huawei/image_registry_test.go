@@ -1,6 +1,7 @@
 package huawei
 
 import (
+	"context"
 	"strings"
 	"testing"
 
@@ -24,7 +25,7 @@ func init() {
 }
 
 func TestAdapter_FetchImages(t *testing.T) {
-	resources, err := HWAdapter.FetchImages(nil)
+	resources, err := HWAdapter.FetchImages(context.Background(), nil)
 	if err != nil {
 		if strings.HasPrefix(err.Error(), "[401]") {
 			t.Log("huawei ak/sk is not available", err.Error())
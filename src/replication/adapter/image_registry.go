@@ -15,6 +15,7 @@
 package adapter
 
 import (
+	"context"
 	"errors"
 	"io"
 	"net/http"
@@ -26,22 +27,42 @@ import (
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema1"
 	"github.com/goharbor/harbor/src/common/http/modifier"
-	common_http_auth "github.com/goharbor/harbor/src/common/http/modifier/auth"
 	"github.com/goharbor/harbor/src/common/utils/log"
 	registry_pkg "github.com/goharbor/harbor/src/common/utils/registry"
 	"github.com/goharbor/harbor/src/common/utils/registry/auth"
 	"github.com/goharbor/harbor/src/replication/model"
-	"github.com/goharbor/harbor/src/replication/util"
 )
 
 // const definition
 const (
 	UserAgentReplication = "harbor-replication-service"
+	// DefaultMaxManifestSize is the max bytes of the manifests pulled from the
+	// registry if it isn't specified, same as the limit of docker distribution
+	DefaultMaxManifestSize int64 = 4 << 20
 )
 
+// GetUserAgent returns the User-Agent used to access the registry, the default
+// one is returned if the registry doesn't specify it
+func GetUserAgent(registry *model.Registry) string {
+	if registry == nil || len(registry.UserAgent) == 0 {
+		return UserAgentReplication
+	}
+	return registry.UserAgent
+}
+
+// GetMaxManifestSize returns the max bytes of the manifests pulled from the
+// registry, the default one is returned if the registry doesn't specify it
+func GetMaxManifestSize(registry *model.Registry) int64 {
+	if registry == nil || registry.MaxManifestSize <= 0 {
+		return DefaultMaxManifestSize
+	}
+	return registry.MaxManifestSize
+}
+
 // ImageRegistry defines the capabilities that an image registry should have
 type ImageRegistry interface {
-	FetchImages(filters []*model.Filter) ([]*model.Resource, error)
+	// the fetching should be aborted once the context is done
+	FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error)
 	ManifestExist(repository, reference string) (exist bool, digest string, err error)
 	PullManifest(repository, reference string, accepttedMediaTypes []string) (manifest distribution.Manifest, digest string, err error)
 	PushManifest(repository, reference, mediaType string, payload []byte) error
@@ -56,6 +77,8 @@ type ImageRegistry interface {
 type Repository struct {
 	ResourceType string `json:"resource_type"`
 	Name         string `json:"name"`
+	// the count of the pulls, only supplied by the registries aware of it, e.g. Harbor
+	PullCount int64 `json:"pull_count"`
 }
 
 // GetName returns the name
@@ -83,6 +106,7 @@ type VTag struct {
 	ResourceType string   `json:"resource_type"`
 	Name         string   `json:"name"`
 	Labels       []string `json:"labels"`
+	Digest       string   `json:"digest"`
 }
 
 // GetFilterableType returns the filterable type
@@ -129,38 +153,44 @@ func NewDefaultRegistryWithClient(registry *model.Registry, client *http.Client)
 	}, nil
 }
 
-// NewDefaultImageRegistry returns an instance of DefaultImageRegistry
+// NewDefaultImageRegistry returns an instance of DefaultImageRegistry, the credential
+// is got from the credential provider registered for the registry type lazily
 func NewDefaultImageRegistry(registry *model.Registry) (*DefaultImageRegistry, error) {
+	provider := GetCredentialProvider(registry.Type)
 	var authorizer modifier.Modifier
-	if registry.Credential != nil && len(registry.Credential.AccessSecret) != 0 {
-		var cred modifier.Modifier
-		if registry.Credential.Type == model.CredentialTypeSecret {
-			cred = common_http_auth.NewSecretAuthorizer(registry.Credential.AccessSecret)
-		} else {
-			cred = auth.NewBasicAuthCredential(
-				registry.Credential.AccessKey,
-				registry.Credential.AccessSecret)
-		}
+	if provider != StaticCredentialProvider ||
+		registry.Credential != nil && len(registry.Credential.AccessSecret) != 0 {
 		authorizer = auth.NewStandardTokenAuthorizer(&http.Client{
-			Transport: util.GetHTTPTransport(registry.Insecure),
-		}, cred, registry.TokenServiceURL)
+			Transport: GetHTTPTransport(registry),
+		}, NewCredentialModifier(registry, provider), registry.TokenServiceURL)
 	}
-	return NewDefaultImageRegistryWithCustomizedAuthorizer(registry, authorizer)
+	return newDefaultImageRegistry(registry, authorizer, provider)
 }
 
 // NewDefaultImageRegistryWithCustomizedAuthorizer returns an instance of DefaultImageRegistry with the customized authorizer
 func NewDefaultImageRegistryWithCustomizedAuthorizer(registry *model.Registry, authorizer modifier.Modifier) (*DefaultImageRegistry, error) {
-	transport := util.GetHTTPTransport(registry.Insecure)
+	return newDefaultImageRegistry(registry, authorizer, nil)
+}
+
+// if the credential provider is set, the request will be retried with the
+// refreshed credential when getting 401
+func newDefaultImageRegistry(registry *model.Registry, authorizer modifier.Modifier,
+	provider CredentialProvider) (*DefaultImageRegistry, error) {
+	transport := GetHTTPTransport(registry)
 	modifiers := []modifier.Modifier{
 		&auth.UserAgentModifier{
-			UserAgent: UserAgentReplication,
+			UserAgent: GetUserAgent(registry),
 		},
 	}
 	if authorizer != nil {
 		modifiers = append(modifiers, authorizer)
 	}
+	var tr http.RoundTripper = registry_pkg.NewTransport(transport, modifiers...)
+	if provider != nil {
+		tr = NewCredentialRefreshTransport(registry, provider, tr, authorizer)
+	}
 	client := &http.Client{
-		Transport: registry_pkg.NewTransport(transport, modifiers...),
+		Transport: NewWarningTransport(registry, tr),
 	}
 	reg, err := registry_pkg.NewRegistry(registry.URL, client)
 	if err != nil {
@@ -198,6 +228,7 @@ func (d *DefaultImageRegistry) create(repository string) (*registry_pkg.Reposito
 	if err != nil {
 		return nil, err
 	}
+	client.MaxManifestSize = GetMaxManifestSize(d.registry)
 	d.clients[repository] = client
 	return client, nil
 }
@@ -15,9 +15,15 @@
 package adapter
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TODO add UT
@@ -44,3 +50,73 @@ func TestIsDigest(t *testing.T) {
 		assert.Equal(t, c.isDigest, isDigest(c.str))
 	}
 }
+
+func TestGetUserAgent(t *testing.T) {
+	assert.Equal(t, UserAgentReplication, GetUserAgent(nil))
+	assert.Equal(t, UserAgentReplication, GetUserAgent(&model.Registry{}))
+	assert.Equal(t, "mirror/policy01", GetUserAgent(&model.Registry{
+		UserAgent: "mirror/policy01",
+	}))
+}
+
+func TestUserAgentOfDefaultImageRegistry(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.UserAgent()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// default
+	registry, err := NewDefaultImageRegistry(&model.Registry{
+		URL: server.URL,
+	})
+	require.Nil(t, err)
+	_, _, err = registry.ManifestExist("library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.Equal(t, UserAgentReplication, userAgent)
+
+	// customized
+	registry, err = NewDefaultImageRegistry(&model.Registry{
+		URL:       server.URL,
+		UserAgent: "mirror/policy01",
+	})
+	require.Nil(t, err)
+	_, _, err = registry.ManifestExist("library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.Equal(t, "mirror/policy01", userAgent)
+}
+
+func TestGetMaxManifestSize(t *testing.T) {
+	assert.Equal(t, DefaultMaxManifestSize, GetMaxManifestSize(nil))
+	assert.Equal(t, DefaultMaxManifestSize, GetMaxManifestSize(&model.Registry{}))
+	assert.Equal(t, int64(1024), GetMaxManifestSize(&model.Registry{
+		MaxManifestSize: 1024,
+	}))
+}
+
+func TestPullOversizedManifest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", schema2.MediaTypeManifest)
+		// the oversized manifest without the Content-Length
+		if strings.HasSuffix(r.URL.Path, "/chunked") {
+			w.Write([]byte(strings.Repeat("a", 1024)))
+			w.(http.Flusher).Flush()
+			w.Write([]byte(strings.Repeat("a", 1024)))
+			return
+		}
+		w.Write([]byte(strings.Repeat("a", 2048)))
+	}))
+	defer server.Close()
+
+	registry, err := NewDefaultImageRegistry(&model.Registry{
+		URL:             server.URL,
+		MaxManifestSize: 1024,
+	})
+	require.Nil(t, err)
+	for _, reference := range []string{"latest", "chunked"} {
+		_, _, err = registry.PullManifest("library/hello-world", reference, []string{schema2.MediaTypeManifest})
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "exceeds the limit 1024 bytes")
+	}
+}
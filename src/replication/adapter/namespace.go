@@ -0,0 +1,56 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"net/http"
+	"time"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/classifier"
+)
+
+var (
+	// the max count of retries when the namespace creation gets a transient error
+	namespaceCreationRetries = 3
+	// the interval between the retries of the namespace creation
+	namespaceCreationRetryInterval = 500 * time.Millisecond
+)
+
+// CreateNamespaceWithRetry creates the namespace by calling the "create" function.
+// As the same namespace may be created by the concurrent executions at the same time,
+// the conflict(409) is treated as success, and the transient errors(e.g. 5xx) which are
+// returned by some registries during the concurrent creation are retried briefly.
+// The "create" function should return the *common_http.Error when getting the
+// unexpected status code
+func CreateNamespaceWithRetry(namespace string, create func() error) error {
+	for i := 0; ; i++ {
+		err := create()
+		if err == nil {
+			return nil
+		}
+		if httpErr, ok := err.(*common_http.Error); ok && httpErr.Code == http.StatusConflict {
+			log.Debugf("got 409 when trying to create namespace %s, it is created by others", namespace)
+			return nil
+		}
+		if !classifier.IsTransient(err) || i >= namespaceCreationRetries {
+			return err
+		}
+		log.Warningf("failed to create namespace %s, will retry in %s: %v", namespace,
+			namespaceCreationRetryInterval, err)
+		time.Sleep(namespaceCreationRetryInterval)
+	}
+}
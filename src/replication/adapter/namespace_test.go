@@ -0,0 +1,77 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateNamespaceWithRetry(t *testing.T) {
+	interval := namespaceCreationRetryInterval
+	defer func() {
+		namespaceCreationRetryInterval = interval
+	}()
+	namespaceCreationRetryInterval = time.Millisecond
+
+	// returns the errors in order, then succeeds
+	newCreate := func(errs ...error) (func() error, *int) {
+		calls := 0
+		return func() error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+			return nil
+		}, &calls
+	}
+
+	// created
+	create, calls := newCreate()
+	assert.Nil(t, CreateNamespaceWithRetry("library", create))
+	assert.Equal(t, 1, *calls)
+
+	// created by the concurrent execution
+	create, calls = newCreate(&common_http.Error{Code: http.StatusConflict})
+	assert.Nil(t, CreateNamespaceWithRetry("library", create))
+	assert.Equal(t, 1, *calls)
+
+	// the transient error during the concurrent creation resolves to the conflict
+	create, calls = newCreate(&common_http.Error{Code: http.StatusInternalServerError},
+		&common_http.Error{Code: http.StatusConflict})
+	assert.Nil(t, CreateNamespaceWithRetry("library", create))
+	assert.Equal(t, 2, *calls)
+
+	// the transient error persists
+	create, calls = newCreate(&common_http.Error{Code: http.StatusInternalServerError},
+		&common_http.Error{Code: http.StatusInternalServerError},
+		&common_http.Error{Code: http.StatusServiceUnavailable},
+		&common_http.Error{Code: http.StatusInternalServerError})
+	assert.NotNil(t, CreateNamespaceWithRetry("library", create))
+	assert.Equal(t, 4, *calls)
+
+	// other errors aren't retried
+	create, calls = newCreate(&common_http.Error{Code: http.StatusForbidden})
+	assert.NotNil(t, CreateNamespaceWithRetry("library", create))
+	assert.Equal(t, 1, *calls)
+	create, calls = newCreate(errors.New("error"))
+	assert.NotNil(t, CreateNamespaceWithRetry("library", create))
+	assert.Equal(t, 1, *calls)
+}
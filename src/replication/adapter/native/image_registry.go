@@ -15,6 +15,8 @@
 package native
 
 import (
+	"context"
+
 	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/util"
@@ -23,7 +25,7 @@ import (
 var _ adp.ImageRegistry = Native{}
 
 // FetchImages ...
-func (n Native) FetchImages(filters []*model.Filter) ([]*model.Resource, error) {
+func (n Native) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 	nameFilterPattern := ""
 	tagFilterPattern := ""
 	for _, filter := range filters {
@@ -41,6 +43,10 @@ func (n Native) FetchImages(filters []*model.Filter) ([]*model.Resource, error)
 
 	var resources []*model.Resource
 	for _, repository := range repositories {
+		// stop fetching once the caller gives up, e.g. the fetching is timed out
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		tags, err := n.filterTags(repository, tagFilterPattern)
 		if err != nil {
 			return nil, err
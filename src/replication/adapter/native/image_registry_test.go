@@ -15,9 +15,12 @@
 package native
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/goharbor/harbor/src/common/utils/test"
@@ -262,7 +265,7 @@ func Test_native_FetchImages(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			var resources, err = adapter.FetchImages(tt.filters)
+			var resources, err = adapter.FetchImages(context.Background(), tt.filters)
 			if tt.wantErr {
 				require.Len(t, resources, 0)
 				require.NotNil(t, err)
@@ -277,3 +280,61 @@ func Test_native_FetchImages(t *testing.T) {
 		})
 	}
 }
+
+func Test_native_FetchImagesPagination(t *testing.T) {
+	repositories := []string{}
+	for i := 0; i < 5; i++ {
+		repositories = append(repositories, fmt.Sprintf("test/repo%d", i))
+	}
+	// the catalog and tags are returned in pages with the size 2
+	mock := test.NewServer(
+		&test.RequestHandlerMapping{
+			Method:  http.MethodGet,
+			Pattern: "/v2/_catalog",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				begin, _ := strconv.Atoi(r.URL.Query().Get("last"))
+				end := begin + 2
+				if end < len(repositories) {
+					w.Header().Set("Link", fmt.Sprintf(`</v2/_catalog?last=%d&n=2>; rel="next"`, end))
+				} else {
+					end = len(repositories)
+				}
+				w.Write([]byte(fmt.Sprintf(`{"repositories":["%s"]}`,
+					strings.Join(repositories[begin:end], `","`))))
+			},
+		},
+		&test.RequestHandlerMapping{
+			Method:  http.MethodGet,
+			Pattern: "/v2/test/",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("last") == "" {
+					w.Header().Set("Link", fmt.Sprintf(`<%s?last=tag2&n=2>; rel="next"`, r.URL.Path))
+					w.Write([]byte(`{"tags":["tag1","tag2"]}`))
+					return
+				}
+				w.Write([]byte(`{"tags":["tag3"]}`))
+			},
+		},
+	)
+	defer mock.Close()
+
+	registry := &model.Registry{
+		Type:     model.RegistryTypeDockerRegistry,
+		URL:      mock.URL,
+		Insecure: true,
+	}
+	reg, err := adp.NewDefaultImageRegistry(registry)
+	require.Nil(t, err)
+	adapter := Native{
+		DefaultImageRegistry: reg,
+		registry:             registry,
+	}
+
+	resources, err := adapter.FetchImages(context.Background(), nil)
+	require.Nil(t, err)
+	require.Equal(t, len(repositories), len(resources))
+	for i, resource := range resources {
+		assert.Equal(t, repositories[i], resource.Metadata.Repository.Name)
+		assert.Equal(t, []string{"tag1", "tag2", "tag3"}, resource.Metadata.Vtags)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/docker/distribution"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/opencontainers/go-digest"
+)
+
+// const definitions
+const (
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+
+	// the media types of the Helm charts stored as OCI artifacts
+	MediaTypeHelmChartConfig  = "application/vnd.cncf.helm.config.v1+json"
+	MediaTypeHelmChartContent = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+)
+
+func init() {
+	// the vendored distribution library doesn't support the OCI manifest,
+	// register it to make the OCI artifacts(e.g. the referrers) can be unmarshalled
+	if err := distribution.RegisterManifestSchema(MediaTypeOCIManifest, unmarshalOCIManifest); err != nil {
+		log.Errorf("failed to register the manifest schema for %s: %v", MediaTypeOCIManifest, err)
+	}
+}
+
+// OCIManifest is the OCI image manifest, the "subject" field links
+// the manifest to another one which makes it a referrer
+type OCIManifest struct {
+	SchemaVersion int                       `json:"schemaVersion"`
+	MediaType     string                    `json:"mediaType,omitempty"`
+	ArtifactType  string                    `json:"artifactType,omitempty"`
+	Config        distribution.Descriptor   `json:"config"`
+	Layers        []distribution.Descriptor `json:"layers"`
+	Subject       *distribution.Descriptor  `json:"subject,omitempty"`
+	Annotations   map[string]string         `json:"annotations,omitempty"`
+
+	// the raw payload is kept to make sure the digest isn't changed
+	payload []byte
+}
+
+// References returns the config and layers of the manifest
+func (o *OCIManifest) References() []distribution.Descriptor {
+	references := []distribution.Descriptor{}
+	if len(o.Config.Digest) > 0 {
+		references = append(references, o.Config)
+	}
+	return append(references, o.Layers...)
+}
+
+// Payload returns the raw payload of the manifest
+func (o *OCIManifest) Payload() (string, []byte, error) {
+	return MediaTypeOCIManifest, o.payload, nil
+}
+
+// IsHelmChart returns whether the manifest is a Helm chart stored as an OCI artifact.
+// The chart is copied as other OCI artifacts through the ImageRegistry, as the Helm
+// client pushes the chart as an OCI manifest with the chart content as the only layer
+func IsHelmChart(manifest distribution.Manifest) bool {
+	m, ok := manifest.(*OCIManifest)
+	if !ok {
+		return false
+	}
+	if m.Config.MediaType == MediaTypeHelmChartConfig {
+		return true
+	}
+	for _, layer := range m.Layers {
+		if layer.MediaType == MediaTypeHelmChartContent {
+			return true
+		}
+	}
+	return false
+}
+
+func unmarshalOCIManifest(payload []byte) (distribution.Manifest, distribution.Descriptor, error) {
+	manifest := &OCIManifest{}
+	if err := json.Unmarshal(payload, manifest); err != nil {
+		return nil, distribution.Descriptor{}, err
+	}
+	if manifest.SchemaVersion != 2 {
+		return nil, distribution.Descriptor{}, errors.New("invalid schema version of the OCI manifest")
+	}
+	manifest.payload = payload
+	return manifest, distribution.Descriptor{
+		MediaType: MediaTypeOCIManifest,
+		Digest:    digest.FromBytes(payload),
+		Size:      int64(len(payload)),
+	}, nil
+}
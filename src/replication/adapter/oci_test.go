@@ -0,0 +1,48 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsHelmChart(t *testing.T) {
+	// the chart identified by the config
+	assert.True(t, IsHelmChart(&OCIManifest{
+		Config: distribution.Descriptor{
+			MediaType: MediaTypeHelmChartConfig,
+		},
+	}))
+	// the chart identified by the content
+	assert.True(t, IsHelmChart(&OCIManifest{
+		Layers: []distribution.Descriptor{
+			{
+				MediaType: MediaTypeHelmChartContent,
+			},
+		},
+	}))
+	// other OCI artifacts
+	assert.False(t, IsHelmChart(&OCIManifest{
+		Config: distribution.Descriptor{
+			MediaType: "application/vnd.oci.image.config.v1+json",
+		},
+	}))
+	// not an OCI manifest
+	assert.False(t, IsHelmChart(&schema2.DeserializedManifest{}))
+}
@@ -0,0 +1,74 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/docker/distribution"
+	common_http "github.com/goharbor/harbor/src/common/http"
+)
+
+// ReferrerRegistry is an optional interface that the image registries can
+// implement to discover the OCI artifacts(SBOMs, signatures, attestations, etc.)
+// attached to the manifest via the "subject" field
+type ReferrerRegistry interface {
+	// ListReferrers returns the descriptors of the manifests referring to the
+	// manifest specified by the digest. Returns nil if the registry doesn't
+	// support the referrers API
+	ListReferrers(repository, digest string) ([]distribution.Descriptor, error)
+}
+
+var _ ReferrerRegistry = &DefaultImageRegistry{}
+
+// ListReferrers lists the referrers via the OCI referrers API
+func (d *DefaultImageRegistry) ListReferrers(repository, digest string) ([]distribution.Descriptor, error) {
+	url := fmt.Sprintf("%s/v2/%s/referrers/%s", d.registry.URL, repository, digest)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", MediaTypeOCIIndex)
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+	// the registry doesn't support the referrers API
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, nil
+	default:
+		return nil, &common_http.Error{
+			Code:    resp.StatusCode,
+			Message: string(data),
+		}
+	}
+	index := &struct {
+		Manifests []distribution.Descriptor `json:"manifests"`
+	}{}
+	if err = json.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+	return index.Manifests, nil
+}
@@ -0,0 +1,125 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const subjectDigest = "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"
+
+func TestListReferrers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/library/hello-world/referrers/" + subjectDigest:
+			w.Header().Set("Content-Type", MediaTypeOCIIndex)
+			w.Write([]byte(`{
+				"schemaVersion": 2,
+				"mediaType": "application/vnd.oci.image.index.v1+json",
+				"manifests": [
+					{
+						"mediaType": "application/vnd.oci.image.manifest.v1+json",
+						"size": 1024,
+						"digest": "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f"
+					},
+					{
+						"mediaType": "application/vnd.oci.image.manifest.v1+json",
+						"size": 2048,
+						"digest": "sha256:3c3a4604a545cdc127456d94e421cd355bca5b528f4a9c1905b15da2eb4a4c6b"
+					}
+				]
+			}`))
+		case "/v2/library/error/referrers/" + subjectDigest:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry, err := NewDefaultImageRegistry(&model.Registry{
+		URL:      server.URL,
+		Insecure: true,
+	})
+	require.Nil(t, err)
+
+	// pass
+	referrers, err := registry.ListReferrers("library/hello-world", subjectDigest)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(referrers))
+	assert.Equal(t, "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f", referrers[0].Digest.String())
+	assert.Equal(t, int64(2048), referrers[1].Size)
+
+	// the referrers API isn't supported
+	referrers, err = registry.ListReferrers("library/unsupported", subjectDigest)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(referrers))
+
+	// error
+	_, err = registry.ListReferrers("library/error", subjectDigest)
+	assert.NotNil(t, err)
+}
+
+func TestUnmarshalOCIManifest(t *testing.T) {
+	payload := []byte(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.oci.image.manifest.v1+json",
+		"artifactType": "application/vnd.example.sbom",
+		"config": {
+			"mediaType": "application/vnd.oci.empty.v1+json",
+			"size": 2,
+			"digest": "sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a"
+		},
+		"layers": [
+			{
+				"mediaType": "application/spdx+json",
+				"size": 1024,
+				"digest": "sha256:ec4b8955958665577945c89419d1af06b5f7636b4ac3da7f12184802ad867736"
+			}
+		],
+		"subject": {
+			"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+			"size": 1024,
+			"digest": "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"
+		}
+	}`)
+	manifest, descriptor, err := distribution.UnmarshalManifest(MediaTypeOCIManifest, payload)
+	require.Nil(t, err)
+	assert.Equal(t, MediaTypeOCIManifest, descriptor.MediaType)
+	assert.Equal(t, int64(len(payload)), descriptor.Size)
+
+	oci, ok := manifest.(*OCIManifest)
+	require.True(t, ok)
+	assert.Equal(t, "application/vnd.example.sbom", oci.ArtifactType)
+	require.NotNil(t, oci.Subject)
+	assert.Equal(t, subjectDigest, oci.Subject.Digest.String())
+	assert.Equal(t, 2, len(oci.References()))
+
+	mediaType, data, err := oci.Payload()
+	require.Nil(t, err)
+	assert.Equal(t, MediaTypeOCIManifest, mediaType)
+	assert.Equal(t, payload, data)
+
+	// invalid schema version
+	_, _, err = distribution.UnmarshalManifest(MediaTypeOCIManifest, []byte(`{"schemaVersion": 1}`))
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,399 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/docker/distribution"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/opencontainers/go-digest"
+)
+
+var (
+	_ adp.Adapter       = &Adapter{}
+	_ adp.ImageRegistry = &Adapter{}
+	_ adp.ChartRegistry = &Adapter{}
+)
+
+type manifest struct {
+	mediaType string
+	payload   []byte
+	digest    string
+}
+
+// Adapter is an in-memory adapter used for testing. The resources returned by
+// "FetchImages" and "FetchCharts" are controlled by the "Images" and "Charts",
+// the error returned by any method can be injected by "SetError" and the calls
+// of every method are counted. The zero value is an empty adapter, so it can be
+// embedded by the adapters which implement the optional capabilities
+type Adapter struct {
+	// RegistryInfo is returned by "Info", the registry supports both images and
+	// charts with the manual trigger if it isn't set
+	RegistryInfo *model.RegistryInfo
+	// Health is returned by "HealthCheck", the registry is healthy if it isn't set
+	Health model.HealthStatus
+	// Images are returned by "FetchImages", the filters are ignored
+	Images []*model.Resource
+	// Charts are returned by "FetchCharts", the filters are ignored
+	Charts []*model.Resource
+
+	sync.Mutex
+	manifests map[string]*manifest
+	blobs     map[string][]byte
+	charts    map[string][]byte
+	errors    map[string]error
+	calls     map[string]int
+	filters   map[string][]*model.Filter
+}
+
+// NewAdapter returns an empty in-memory adapter
+func NewAdapter() *Adapter {
+	return &Adapter{}
+}
+
+// Factory returns an adapter factory which always returns the adapter
+func (a *Adapter) Factory() adp.Factory {
+	return func(*model.Registry) (adp.Adapter, error) {
+		return a, nil
+	}
+}
+
+// SetError injects the error returned by the method specified by the name,
+// e.g. "FetchImages". Set the error to nil to clear it
+func (a *Adapter) SetError(method string, err error) {
+	a.Lock()
+	defer a.Unlock()
+	a.init()
+	if err == nil {
+		delete(a.errors, method)
+		return
+	}
+	a.errors[method] = err
+}
+
+// CallCount returns how many times the method specified by the name is called
+func (a *Adapter) CallCount(method string) int {
+	a.Lock()
+	defer a.Unlock()
+	return a.calls[method]
+}
+
+// Filters returns the filters passed in the last call of "FetchImages" or "FetchCharts"
+func (a *Adapter) Filters(method string) []*model.Filter {
+	a.Lock()
+	defer a.Unlock()
+	return a.filters[method]
+}
+
+// AddManifest adds the manifest into the adapter, it can be referenced by the tag or digest
+func (a *Adapter) AddManifest(repository, tag, mediaType string, payload []byte) {
+	a.Lock()
+	defer a.Unlock()
+	a.init()
+	a.addManifest(repository, tag, mediaType, payload)
+}
+
+// AddTag makes the tag of the repository reference the manifest specified by the digest.
+// The manifest doesn't have to be added if only its digest matters, but it can't be
+// pulled in that case
+func (a *Adapter) AddTag(repository, tag, digest string) {
+	a.Lock()
+	defer a.Unlock()
+	a.init()
+	m, exist := a.manifests[repository+":"+digest]
+	if !exist {
+		m = &manifest{
+			digest: digest,
+		}
+	}
+	a.manifests[repository+":"+tag] = m
+}
+
+// AddBlob adds the blob into the adapter and returns its digest
+func (a *Adapter) AddBlob(data []byte) string {
+	a.Lock()
+	defer a.Unlock()
+	a.init()
+	dgt := digest.FromBytes(data).String()
+	a.blobs[dgt] = data
+	return dgt
+}
+
+// AddChart adds the chart into the adapter
+func (a *Adapter) AddChart(name, version string, data []byte) {
+	a.Lock()
+	defer a.Unlock()
+	a.init()
+	a.charts[name+":"+version] = data
+}
+
+// initialize the zero value
+func (a *Adapter) init() {
+	if a.calls != nil {
+		return
+	}
+	a.manifests = map[string]*manifest{}
+	a.blobs = map[string][]byte{}
+	a.charts = map[string][]byte{}
+	a.errors = map[string]error{}
+	a.calls = map[string]int{}
+	a.filters = map[string][]*model.Filter{}
+}
+
+// record the call and returns the injected error
+func (a *Adapter) call(method string) error {
+	a.Lock()
+	defer a.Unlock()
+	a.init()
+	a.calls[method]++
+	return a.errors[method]
+}
+
+func (a *Adapter) addManifest(repository, reference, mediaType string, payload []byte) {
+	m := &manifest{
+		mediaType: mediaType,
+		payload:   payload,
+		digest:    digest.FromBytes(payload).String(),
+	}
+	a.manifests[repository+":"+reference] = m
+	a.manifests[repository+":"+m.digest] = m
+}
+
+// Info ...
+func (a *Adapter) Info() (*model.RegistryInfo, error) {
+	if err := a.call("Info"); err != nil {
+		return nil, err
+	}
+	if a.RegistryInfo != nil {
+		return a.RegistryInfo, nil
+	}
+	return &model.RegistryInfo{
+		Type: model.RegistryTypeHarbor,
+		SupportedResourceTypes: []model.ResourceType{
+			model.ResourceTypeImage,
+			model.ResourceTypeChart,
+		},
+		SupportedTriggers: []model.TriggerType{model.TriggerTypeManual},
+	}, nil
+}
+
+// PrepareForPush ...
+func (a *Adapter) PrepareForPush([]*model.Resource) error {
+	return a.call("PrepareForPush")
+}
+
+// HealthCheck ...
+func (a *Adapter) HealthCheck() (model.HealthStatus, error) {
+	if err := a.call("HealthCheck"); err != nil {
+		return model.Unhealthy, err
+	}
+	if len(a.Health) > 0 {
+		return a.Health, nil
+	}
+	return model.Healthy, nil
+}
+
+// FetchImages returns the copies of "Images" as the flow may modify the resources
+func (a *Adapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	if err := a.call("FetchImages"); err != nil {
+		return nil, err
+	}
+	a.recordFilters("FetchImages", filters)
+	return copyResources(a.Images), nil
+}
+
+// ManifestExist ...
+func (a *Adapter) ManifestExist(repository, reference string) (bool, string, error) {
+	if err := a.call("ManifestExist"); err != nil {
+		return false, "", err
+	}
+	a.Lock()
+	defer a.Unlock()
+	m, exist := a.manifests[repository+":"+reference]
+	if !exist {
+		return false, "", nil
+	}
+	return true, m.digest, nil
+}
+
+// PullManifest ...
+func (a *Adapter) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	if err := a.call("PullManifest"); err != nil {
+		return nil, "", err
+	}
+	a.Lock()
+	m, exist := a.manifests[repository+":"+reference]
+	a.Unlock()
+	if !exist {
+		return nil, "", fmt.Errorf("manifest %s:%s not found", repository, reference)
+	}
+	if m.payload == nil {
+		return nil, "", fmt.Errorf("manifest %s:%s is only tagged without the payload", repository, reference)
+	}
+	manifest, _, err := distribution.UnmarshalManifest(m.mediaType, m.payload)
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, m.digest, nil
+}
+
+// PushManifest ...
+func (a *Adapter) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	if err := a.call("PushManifest"); err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.addManifest(repository, reference, mediaType, payload)
+	return nil
+}
+
+// DeleteManifest ...
+func (a *Adapter) DeleteManifest(repository, reference string) error {
+	if err := a.call("DeleteManifest"); err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	delete(a.manifests, repository+":"+reference)
+	return nil
+}
+
+// BlobExist ...
+func (a *Adapter) BlobExist(repository, digest string) (bool, error) {
+	if err := a.call("BlobExist"); err != nil {
+		return false, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	_, exist := a.blobs[digest]
+	return exist, nil
+}
+
+// PullBlob ...
+func (a *Adapter) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	if err := a.call("PullBlob"); err != nil {
+		return 0, nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	data, exist := a.blobs[digest]
+	if !exist {
+		return 0, nil, fmt.Errorf("blob %s not found", digest)
+	}
+	return int64(len(data)), ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// PushBlob ...
+func (a *Adapter) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	if err := a.call("PushBlob"); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(blob)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.blobs[digest] = data
+	return nil
+}
+
+// FetchCharts returns the copies of "Charts" as the flow may modify the resources
+func (a *Adapter) FetchCharts(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	if err := a.call("FetchCharts"); err != nil {
+		return nil, err
+	}
+	a.recordFilters("FetchCharts", filters)
+	return copyResources(a.Charts), nil
+}
+
+// ChartExist ...
+func (a *Adapter) ChartExist(name, version string) (bool, error) {
+	if err := a.call("ChartExist"); err != nil {
+		return false, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	_, exist := a.charts[name+":"+version]
+	return exist, nil
+}
+
+// DownloadChart ...
+func (a *Adapter) DownloadChart(name, version string) (io.ReadCloser, error) {
+	if err := a.call("DownloadChart"); err != nil {
+		return nil, err
+	}
+	a.Lock()
+	defer a.Unlock()
+	data, exist := a.charts[name+":"+version]
+	if !exist {
+		return nil, fmt.Errorf("chart %s:%s not found", name, version)
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+// UploadChart ...
+func (a *Adapter) UploadChart(name, version string, chart io.Reader) error {
+	if err := a.call("UploadChart"); err != nil {
+		return err
+	}
+	data, err := ioutil.ReadAll(chart)
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	a.charts[name+":"+version] = data
+	return nil
+}
+
+// DeleteChart ...
+func (a *Adapter) DeleteChart(name, version string) error {
+	if err := a.call("DeleteChart"); err != nil {
+		return err
+	}
+	a.Lock()
+	defer a.Unlock()
+	delete(a.charts, name+":"+version)
+	return nil
+}
+
+func (a *Adapter) recordFilters(method string, filters []*model.Filter) {
+	a.Lock()
+	defer a.Unlock()
+	a.filters[method] = filters
+}
+
+func copyResources(resources []*model.Resource) []*model.Resource {
+	result := []*model.Resource{}
+	for _, resource := range resources {
+		res := *resource
+		if resource.Metadata != nil {
+			metadata := *resource.Metadata
+			metadata.Vtags = append([]string{}, resource.Metadata.Vtags...)
+			res.Metadata = &metadata
+		}
+		result = append(result, &res)
+	}
+	return result
+}
@@ -0,0 +1,200 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchResources(t *testing.T) {
+	adapter := NewAdapter()
+	adapter.Images = []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"1.0", "2.0"},
+			},
+		},
+	}
+	adapter.Charts = []*model.Resource{
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/harbor",
+				},
+				Vtags: []string{"0.2.0"},
+			},
+		},
+	}
+
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: "1.*",
+		},
+	}
+	images, err := adapter.FetchImages(context.Background(), filters)
+	require.Nil(t, err)
+	// the filters are recorded but ignored
+	assert.Equal(t, filters, adapter.Filters("FetchImages"))
+	require.Equal(t, 1, len(images))
+	assert.Equal(t, "library/hello-world", images[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"1.0", "2.0"}, images[0].Metadata.Vtags)
+	// the returned resources are copies
+	images[0].Metadata.Vtags[0] = "3.0"
+	assert.Equal(t, "1.0", adapter.Images[0].Metadata.Vtags[0])
+
+	charts, err := adapter.FetchCharts(context.Background(), nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(charts))
+	assert.Equal(t, "library/harbor", charts[0].Metadata.Repository.Name)
+
+	assert.Equal(t, 1, adapter.CallCount("FetchImages"))
+	assert.Equal(t, 1, adapter.CallCount("FetchCharts"))
+	assert.Equal(t, 0, adapter.CallCount("Info"))
+}
+
+func TestZeroValue(t *testing.T) {
+	adapter := &Adapter{}
+	info, err := adapter.Info()
+	require.Nil(t, err)
+	assert.Equal(t, model.RegistryTypeHarbor, info.Type)
+	assert.Equal(t, 2, len(info.SupportedResourceTypes))
+	status, err := adapter.HealthCheck()
+	require.Nil(t, err)
+	assert.Equal(t, model.HealthStatus(model.Healthy), status)
+	exist, _, err := adapter.ManifestExist("library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.False(t, exist)
+
+	adapter.Health = model.Unhealthy
+	status, err = adapter.HealthCheck()
+	require.Nil(t, err)
+	assert.Equal(t, model.HealthStatus(model.Unhealthy), status)
+	assert.Equal(t, 2, adapter.CallCount("HealthCheck"))
+}
+
+func TestSetError(t *testing.T) {
+	adapter := NewAdapter()
+	adapter.SetError("FetchImages", errors.New("error"))
+	_, err := adapter.FetchImages(context.Background(), nil)
+	assert.NotNil(t, err)
+	// the other methods aren't affected
+	_, err = adapter.Info()
+	assert.Nil(t, err)
+	_, err = adapter.FetchCharts(context.Background(), nil)
+	assert.Nil(t, err)
+
+	// clear the error
+	adapter.SetError("FetchImages", nil)
+	_, err = adapter.FetchImages(context.Background(), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, adapter.CallCount("FetchImages"))
+
+	// the factory returns the same adapter
+	adp, err := adapter.Factory()(&model.Registry{})
+	require.Nil(t, err)
+	assert.Equal(t, adapter, adp)
+}
+
+func TestManifestAndBlob(t *testing.T) {
+	adapter := NewAdapter()
+	config := adapter.AddBlob([]byte("config"))
+	payload := []byte(`{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+		"config": {
+			"mediaType": "application/vnd.docker.container.image.v1+json",
+			"size": 6,
+			"digest": "` + config + `"
+		},
+		"layers": []
+	}`)
+	adapter.AddManifest("library/hello-world", "latest", schema2.MediaTypeManifest, payload)
+
+	exist, digest, err := adapter.ManifestExist("library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.True(t, exist)
+	exist, _, err = adapter.ManifestExist("library/hello-world", digest)
+	require.Nil(t, err)
+	assert.True(t, exist)
+	exist, _, err = adapter.ManifestExist("library/hello-world", "1.0")
+	require.Nil(t, err)
+	assert.False(t, exist)
+
+	manifest, dgt, err := adapter.PullManifest("library/hello-world", "latest", nil)
+	require.Nil(t, err)
+	assert.Equal(t, digest, dgt)
+	require.Equal(t, 1, len(manifest.References()))
+	assert.Equal(t, config, manifest.References()[0].Digest.String())
+
+	exist, err = adapter.BlobExist("library/hello-world", config)
+	require.Nil(t, err)
+	assert.True(t, exist)
+	size, blob, err := adapter.PullBlob("library/hello-world", config)
+	require.Nil(t, err)
+	defer blob.Close()
+	assert.Equal(t, int64(6), size)
+
+	// the tag references the existing manifest or only a digest
+	adapter.AddTag("library/hello-world", "stable", digest)
+	_, dgt, err = adapter.PullManifest("library/hello-world", "stable", nil)
+	require.Nil(t, err)
+	assert.Equal(t, digest, dgt)
+	adapter.AddTag("library/hello-world", "dev", "sha256:1")
+	exist, dgt, err = adapter.ManifestExist("library/hello-world", "dev")
+	require.Nil(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, "sha256:1", dgt)
+	_, _, err = adapter.PullManifest("library/hello-world", "dev", nil)
+	assert.NotNil(t, err)
+
+	require.Nil(t, adapter.DeleteManifest("library/hello-world", "latest"))
+	exist, _, err = adapter.ManifestExist("library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.False(t, exist)
+}
+
+func TestChart(t *testing.T) {
+	adapter := NewAdapter()
+	require.Nil(t, adapter.UploadChart("library/harbor", "0.2.0", bytes.NewReader([]byte("chart"))))
+	exist, err := adapter.ChartExist("library/harbor", "0.2.0")
+	require.Nil(t, err)
+	assert.True(t, exist)
+
+	chart, err := adapter.DownloadChart("library/harbor", "0.2.0")
+	require.Nil(t, err)
+	defer chart.Close()
+	data, err := ioutil.ReadAll(chart)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("chart"), data)
+
+	require.Nil(t, adapter.DeleteChart("library/harbor", "0.2.0"))
+	_, err = adapter.DownloadChart("library/harbor", "0.2.0")
+	assert.NotNil(t, err)
+}
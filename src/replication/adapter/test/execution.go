@@ -0,0 +1,335 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package test
+
+import (
+	"reflect"
+	"regexp"
+	"sync"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+)
+
+var _ execution.Manager = &ExecutionManager{}
+
+var columnPattern = regexp.MustCompile(`column\(([^)]+)\)`)
+
+// ExecutionManager is an in-memory execution manager used for testing. The executions
+// and tasks are stored as copies just like in the database, the updates only change
+// the columns specified by the "props" and every status of the tasks is recorded.
+// The zero value is an empty manager
+type ExecutionManager struct {
+	sync.Mutex
+	executions []*models.Execution
+	tasks      []*models.Task
+	// the statuses of the tasks in the order they are updated, indexed by the task ID
+	statuses map[int64][]string
+}
+
+// NewExecutionManager returns an empty in-memory execution manager
+func NewExecutionManager() *ExecutionManager {
+	return &ExecutionManager{}
+}
+
+// TaskStatuses returns the statuses of the task in the order they are updated
+// by "UpdateTaskStatus", the initial status of the task isn't included
+func (e *ExecutionManager) TaskStatuses(id int64) []string {
+	e.Lock()
+	defer e.Unlock()
+	return e.statuses[id]
+}
+
+// Tasks returns the copies of all the tasks ordered by the ID
+func (e *ExecutionManager) Tasks() []*models.Task {
+	e.Lock()
+	defer e.Unlock()
+	tasks := []*models.Task{}
+	for _, task := range e.tasks {
+		if task == nil {
+			continue
+		}
+		t := *task
+		tasks = append(tasks, &t)
+	}
+	return tasks
+}
+
+// Create ...
+func (e *ExecutionManager) Create(execution *models.Execution) (int64, error) {
+	e.Lock()
+	defer e.Unlock()
+	ex := *execution
+	ex.ID = int64(len(e.executions) + 1)
+	e.executions = append(e.executions, &ex)
+	return ex.ID, nil
+}
+
+// List returns the executions matching the query ordered by the ID descending
+func (e *ExecutionManager) List(query ...*models.ExecutionQuery) (int64, []*models.Execution, error) {
+	e.Lock()
+	defer e.Unlock()
+	executions := []*models.Execution{}
+	for i := len(e.executions) - 1; i >= 0; i-- {
+		ex := e.executions[i]
+		if ex == nil {
+			continue
+		}
+		if len(query) > 0 {
+			q := query[0]
+			if q.PolicyID > 0 && ex.PolicyID != q.PolicyID {
+				continue
+			}
+			if len(q.Trigger) > 0 && string(ex.Trigger) != q.Trigger {
+				continue
+			}
+			if len(q.Statuses) > 0 && !contains(q.Statuses, ex.Status) {
+				continue
+			}
+		}
+		copied := *ex
+		executions = append(executions, &copied)
+	}
+	total := int64(len(executions))
+	if len(query) > 0 {
+		start, end := paginate(query[0].Pagination, total)
+		executions = executions[start:end]
+	}
+	return total, executions, nil
+}
+
+// Get returns nil if the execution doesn't exist
+func (e *ExecutionManager) Get(id int64) (*models.Execution, error) {
+	e.Lock()
+	defer e.Unlock()
+	ex := e.getExecution(id)
+	if ex == nil {
+		return nil, nil
+	}
+	copied := *ex
+	return &copied, nil
+}
+
+// Update ...
+func (e *ExecutionManager) Update(execution *models.Execution, props ...string) error {
+	e.Lock()
+	defer e.Unlock()
+	if ex := e.getExecution(execution.ID); ex != nil {
+		copyColumns(ex, execution, props)
+	}
+	return nil
+}
+
+// Remove ...
+func (e *ExecutionManager) Remove(id int64) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.getExecution(id) != nil {
+		e.executions[id-1] = nil
+	}
+	return nil
+}
+
+// RemoveAll removes all the executions of the policy
+func (e *ExecutionManager) RemoveAll(policyID int64) error {
+	e.Lock()
+	defer e.Unlock()
+	for i, ex := range e.executions {
+		if ex != nil && ex.PolicyID == policyID {
+			e.executions[i] = nil
+		}
+	}
+	return nil
+}
+
+// CreateTask ...
+func (e *ExecutionManager) CreateTask(task *models.Task) (int64, error) {
+	e.Lock()
+	defer e.Unlock()
+	t := *task
+	t.ID = int64(len(e.tasks) + 1)
+	e.tasks = append(e.tasks, &t)
+	return t.ID, nil
+}
+
+// ListTasks returns the tasks matching the query ordered by the ID
+func (e *ExecutionManager) ListTasks(query ...*models.TaskQuery) (int64, []*models.Task, error) {
+	e.Lock()
+	defer e.Unlock()
+	tasks := []*models.Task{}
+	for _, task := range e.tasks {
+		if task == nil {
+			continue
+		}
+		if len(query) > 0 {
+			q := query[0]
+			if q.ExecutionID > 0 && task.ExecutionID != q.ExecutionID {
+				continue
+			}
+			if len(q.JobID) > 0 && task.JobID != q.JobID {
+				continue
+			}
+			if len(q.ResourceType) > 0 && task.ResourceType != q.ResourceType {
+				continue
+			}
+			if len(q.Statuses) > 0 && !contains(q.Statuses, task.Status) {
+				continue
+			}
+		}
+		t := *task
+		tasks = append(tasks, &t)
+	}
+	total := int64(len(tasks))
+	if len(query) > 0 {
+		start, end := paginate(query[0].Pagination, total)
+		tasks = tasks[start:end]
+	}
+	return total, tasks, nil
+}
+
+// GetTask returns nil if the task doesn't exist
+func (e *ExecutionManager) GetTask(id int64) (*models.Task, error) {
+	e.Lock()
+	defer e.Unlock()
+	task := e.getTask(id)
+	if task == nil {
+		return nil, nil
+	}
+	t := *task
+	return &t, nil
+}
+
+// UpdateTask ...
+func (e *ExecutionManager) UpdateTask(task *models.Task, props ...string) error {
+	e.Lock()
+	defer e.Unlock()
+	if t := e.getTask(task.ID); t != nil {
+		status := t.Status
+		copyColumns(t, task, props)
+		// the status can only be updated by "UpdateTaskStatus"
+		t.Status = status
+	}
+	return nil
+}
+
+// UpdateTaskStatus ...
+func (e *ExecutionManager) UpdateTaskStatus(id int64, status string, statusCondition ...string) error {
+	e.Lock()
+	defer e.Unlock()
+	task := e.getTask(id)
+	if task == nil {
+		return nil
+	}
+	if len(statusCondition) > 0 && !contains(statusCondition, task.Status) {
+		return nil
+	}
+	task.Status = status
+	if e.statuses == nil {
+		e.statuses = map[int64][]string{}
+	}
+	e.statuses[id] = append(e.statuses[id], status)
+	return nil
+}
+
+// RemoveTask ...
+func (e *ExecutionManager) RemoveTask(id int64) error {
+	e.Lock()
+	defer e.Unlock()
+	if e.getTask(id) != nil {
+		e.tasks[id-1] = nil
+	}
+	return nil
+}
+
+// RemoveAllTasks removes all the tasks of the execution
+func (e *ExecutionManager) RemoveAllTasks(executionID int64) error {
+	e.Lock()
+	defer e.Unlock()
+	for i, task := range e.tasks {
+		if task != nil && task.ExecutionID == executionID {
+			e.tasks[i] = nil
+		}
+	}
+	return nil
+}
+
+// GetTaskLog ...
+func (e *ExecutionManager) GetTaskLog(int64) ([]byte, error) {
+	return nil, nil
+}
+
+// SubscribeTaskStatus returns a nil channel as no transition is published
+func (e *ExecutionManager) SubscribeTaskStatus(int64) (<-chan *execution.TaskStatusTransition, func()) {
+	return nil, func() {}
+}
+
+func (e *ExecutionManager) getExecution(id int64) *models.Execution {
+	if id <= 0 || id > int64(len(e.executions)) {
+		return nil
+	}
+	return e.executions[id-1]
+}
+
+func (e *ExecutionManager) getTask(id int64) *models.Task {
+	if id <= 0 || id > int64(len(e.tasks)) {
+		return nil
+	}
+	return e.tasks[id-1]
+}
+
+// copy the fields specified by the props which are either the field names or the
+// column names, all the columns except the primary key are copied if no props specified
+func copyColumns(dst, src interface{}, props []string) {
+	d := reflect.ValueOf(dst).Elem()
+	s := reflect.ValueOf(src).Elem()
+	for i := 0; i < d.NumField(); i++ {
+		field := d.Type().Field(i)
+		match := columnPattern.FindStringSubmatch(field.Tag.Get("orm"))
+		if len(match) == 0 || match[1] == "id" {
+			continue
+		}
+		if len(props) > 0 && !contains(props, field.Name) && !contains(props, match[1]) {
+			continue
+		}
+		d.Field(i).Set(s.Field(i))
+	}
+}
+
+func paginate(pagination models.Pagination, total int64) (int64, int64) {
+	if pagination.Size <= 0 {
+		return 0, total
+	}
+	page := pagination.Page
+	if page <= 0 {
+		page = 1
+	}
+	start := (page - 1) * pagination.Size
+	end := start + pagination.Size
+	if start > total {
+		start = total
+	}
+	if end > total {
+		end = total
+	}
+	return start, end
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package test
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecution(t *testing.T) {
+	mgr := NewExecutionManager()
+	for _, policyID := range []int64{1, 2, 1} {
+		_, err := mgr.Create(&models.Execution{
+			PolicyID: policyID,
+			Status:   models.ExecutionStatusInProgress,
+		})
+		require.Nil(t, err)
+	}
+
+	// listed by the ID descending
+	total, executions, err := mgr.List(&models.ExecutionQuery{
+		PolicyID: 1,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	require.Equal(t, 2, len(executions))
+	assert.Equal(t, int64(3), executions[0].ID)
+	assert.Equal(t, int64(1), executions[1].ID)
+	total, executions, err = mgr.List(&models.ExecutionQuery{
+		Pagination: models.Pagination{
+			Page: 1,
+			Size: 1,
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+	require.Equal(t, 1, len(executions))
+	assert.Equal(t, int64(3), executions[0].ID)
+
+	// only the specified properties are updated
+	require.Nil(t, mgr.Update(&models.Execution{
+		ID:         1,
+		Status:     models.ExecutionStatusSucceed,
+		StatusText: "done",
+	}, "Status"))
+	execution, err := mgr.Get(1)
+	require.Nil(t, err)
+	assert.Equal(t, models.ExecutionStatusSucceed, execution.Status)
+	assert.Equal(t, "", execution.StatusText)
+	assert.Equal(t, int64(1), execution.PolicyID)
+	// the stored one isn't changed by the copy
+	execution.Status = models.ExecutionStatusFailed
+	execution, err = mgr.Get(1)
+	require.Nil(t, err)
+	assert.Equal(t, models.ExecutionStatusSucceed, execution.Status)
+
+	require.Nil(t, mgr.RemoveAll(1))
+	total, _, err = mgr.List()
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	execution, err = mgr.Get(1)
+	require.Nil(t, err)
+	assert.Nil(t, execution)
+}
+
+func TestTask(t *testing.T) {
+	mgr := &ExecutionManager{}
+	for _, executionID := range []int64{1, 1, 2} {
+		_, err := mgr.CreateTask(&models.Task{
+			ExecutionID: executionID,
+			Status:      models.TaskStatusInitialized,
+		})
+		require.Nil(t, err)
+	}
+
+	// the status is updated only if it matches the conditions
+	require.Nil(t, mgr.UpdateTaskStatus(1, models.TaskStatusPending, models.TaskStatusInitialized))
+	require.Nil(t, mgr.UpdateTaskStatus(1, models.TaskStatusFailed, models.TaskStatusInitialized))
+	require.Nil(t, mgr.UpdateTaskStatus(1, models.TaskStatusSucceed))
+	assert.Equal(t, []string{models.TaskStatusPending, models.TaskStatusSucceed}, mgr.TaskStatuses(1))
+	task, err := mgr.GetTask(1)
+	require.Nil(t, err)
+	assert.Equal(t, models.TaskStatusSucceed, task.Status)
+
+	// the status isn't updated by "UpdateTask"
+	require.Nil(t, mgr.UpdateTask(&models.Task{
+		ID:     2,
+		JobID:  "job",
+		Status: models.TaskStatusFailed,
+	}, "JobID", "Status"))
+	task, err = mgr.GetTask(2)
+	require.Nil(t, err)
+	assert.Equal(t, "job", task.JobID)
+	assert.Equal(t, models.TaskStatusInitialized, task.Status)
+
+	total, tasks, err := mgr.ListTasks(&models.TaskQuery{
+		ExecutionID: 1,
+		Statuses:    []string{models.TaskStatusInitialized},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Equal(t, 1, len(tasks))
+	assert.Equal(t, int64(2), tasks[0].ID)
+
+	require.Nil(t, mgr.RemoveAllTasks(1))
+	assert.Equal(t, 1, len(mgr.Tasks()))
+	task, err = mgr.GetTask(1)
+	require.Nil(t, err)
+	assert.Nil(t, task)
+}
@@ -0,0 +1,89 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+var (
+	// the transports pinning the hosts to the specific IPs, they are cached
+	// to reuse the connections
+	pinnedTransports   = map[string]*http.Transport{}
+	pinnedTransportsMu sync.Mutex
+)
+
+// GetHTTPTransport returns the transport used to access the registry. If the
+// registry specifies the "ResolveOverride", the connections to the overridden
+// hosts are dialed to the specified IPs(like the "--resolve" option of curl),
+// while the original host names are still used for the TLS SNI and verification
+func GetHTTPTransport(registry *model.Registry) *http.Transport {
+	if len(registry.ResolveOverride) == 0 {
+		return util.GetHTTPTransport(registry.Insecure)
+	}
+	key := getPinnedTransportKey(registry)
+	pinnedTransportsMu.Lock()
+	defer pinnedTransportsMu.Unlock()
+	if transport, exist := pinnedTransports[key]; exist {
+		return transport
+	}
+	transport := &http.Transport{
+		Proxy:       http.ProxyFromEnvironment,
+		DialContext: newPinnedDialer(registry.ResolveOverride),
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: registry.Insecure,
+		},
+	}
+	pinnedTransports[key] = transport
+	return transport
+}
+
+func getPinnedTransportKey(registry *model.Registry) string {
+	var pairs []string
+	for host, ip := range registry.ResolveOverride {
+		pairs = append(pairs, host+"="+ip)
+	}
+	sort.Strings(pairs)
+	return fmt.Sprintf("%t;%s", registry.Insecure, strings.Join(pairs, ";"))
+}
+
+// return the dial function which replaces the hosts with the overridden IPs
+func newPinnedDialer(overrides map[string]string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip, exist := overrides[host]; exist {
+			addr = net.JoinHostPort(ip, port)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
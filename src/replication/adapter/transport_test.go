@@ -0,0 +1,93 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHTTPTransport(t *testing.T) {
+	// no override
+	registry := &model.Registry{
+		Insecure: true,
+	}
+	assert.Equal(t, util.GetHTTPTransport(true), GetHTTPTransport(registry))
+
+	// the transport is cached
+	registry.ResolveOverride = map[string]string{
+		"registry.harbor.local": "127.0.0.1",
+	}
+	transport := GetHTTPTransport(registry)
+	assert.NotEqual(t, util.GetHTTPTransport(true), transport)
+	assert.Equal(t, transport, GetHTTPTransport(&model.Registry{
+		Insecure: true,
+		ResolveOverride: map[string]string{
+			"registry.harbor.local": "127.0.0.1",
+		},
+	}))
+	// different override
+	assert.NotEqual(t, transport, GetHTTPTransport(&model.Registry{
+		Insecure: true,
+		ResolveOverride: map[string]string{
+			"registry.harbor.local": "127.0.0.2",
+		},
+	}))
+}
+
+func TestPinnedTransport(t *testing.T) {
+	var serverName, host string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	require.Nil(t, err)
+	// the server listens on 127.0.0.1
+	registry := &model.Registry{
+		URL:      "https://registry.harbor.local:" + u.Port(),
+		Insecure: true,
+		ResolveOverride: map[string]string{
+			"registry.harbor.local": u.Hostname(),
+		},
+	}
+	client := &http.Client{
+		Transport: GetHTTPTransport(registry),
+	}
+	resp, err := client.Get(registry.URL + "/v2/")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	// the original host name is presented for the TLS SNI and the "Host" header
+	assert.Equal(t, "registry.harbor.local", serverName)
+	assert.Equal(t, "registry.harbor.local:"+u.Port(), host)
+}
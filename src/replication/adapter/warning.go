@@ -0,0 +1,162 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// Warning is the warning returned by the registry in the "Warning" header(RFC7234),
+// e.g. 299 - "this API is deprecated"
+type Warning struct {
+	Code  int
+	Agent string
+	Text  string
+}
+
+func (w *Warning) String() string {
+	if w.Code == 0 {
+		return w.Text
+	}
+	return fmt.Sprintf("%d %s %q", w.Code, w.Agent, w.Text)
+}
+
+// ParseWarnings parses the values of the "Warning" headers. One value can contain
+// several warnings separated by comma, the rest of the malformed value is returned
+// as the text of one warning without code rather than being dropped
+func ParseWarnings(values []string) []*Warning {
+	var warnings []*Warning
+	for _, value := range values {
+		rest := value
+		for {
+			rest = strings.TrimLeft(rest, " ,")
+			if len(rest) == 0 {
+				break
+			}
+			warning, remaining, ok := parseWarning(rest)
+			if !ok {
+				warnings = append(warnings, &Warning{Text: strings.TrimSpace(rest)})
+				break
+			}
+			warnings = append(warnings, warning)
+			rest = remaining
+		}
+	}
+	return warnings
+}
+
+// parse the warning at the beginning of the value: warn-code SP warn-agent SP warn-text [SP warn-date]
+func parseWarning(value string) (*Warning, string, bool) {
+	fields := strings.SplitN(value, " ", 3)
+	if len(fields) != 3 {
+		return nil, "", false
+	}
+	code, err := strconv.Atoi(fields[0])
+	if err != nil || len(fields[0]) != 3 {
+		return nil, "", false
+	}
+	text, rest, ok := parseQuotedString(fields[2])
+	if !ok {
+		return nil, "", false
+	}
+	// the optional warn-date
+	if strings.HasPrefix(rest, " \"") {
+		if _, rest, ok = parseQuotedString(rest[1:]); !ok {
+			return nil, "", false
+		}
+	}
+	rest = strings.TrimLeft(rest, " ")
+	if len(rest) > 0 && rest[0] != ',' {
+		return nil, "", false
+	}
+	return &Warning{
+		Code:  code,
+		Agent: fields[1],
+		Text:  text,
+	}, rest, true
+}
+
+// parse the quoted string at the beginning of the value, returns the unquoted string and the rest
+func parseQuotedString(value string) (string, string, bool) {
+	if len(value) == 0 || value[0] != '"' {
+		return "", "", false
+	}
+	var b strings.Builder
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			if i+1 < len(value) {
+				i++
+				b.WriteByte(value[i])
+			}
+		case '"':
+			return b.String(), value[i+1:], true
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return "", "", false
+}
+
+// NewWarningTransport returns the transport logging the warnings returned by the registry
+// in the "Warning" headers, e.g. the deprecation notices, so the operators are aware of them.
+// Every distinct warning is logged once by the transport to avoid flooding the log
+func NewWarningTransport(registry *model.Registry, transport http.RoundTripper) http.RoundTripper {
+	return &warningTransport{
+		registry:  registry,
+		transport: transport,
+		logged:    map[string]struct{}{},
+	}
+}
+
+type warningTransport struct {
+	registry  *model.Registry
+	transport http.RoundTripper
+	lock      sync.Mutex
+	logged    map[string]struct{}
+}
+
+func (w *warningTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := w.transport.RoundTrip(req)
+	if err != nil || resp == nil {
+		return resp, err
+	}
+	for _, warning := range ParseWarnings(resp.Header["Warning"]) {
+		if !w.markLogged(warning.String()) {
+			continue
+		}
+		log.Warningf("the registry %s returned the warning for %s %s: %s",
+			w.registry.URL, req.Method, req.URL.Path, warning)
+	}
+	return resp, nil
+}
+
+// returns false if the warning has been logged
+func (w *warningTransport) markLogged(warning string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, exist := w.logged[warning]; exist {
+		return false
+	}
+	w.logged[warning] = struct{}{}
+	return true
+}
@@ -0,0 +1,96 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package adapter
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWarnings(t *testing.T) {
+	cases := []struct {
+		values   []string
+		warnings []*Warning
+	}{
+		{nil, nil},
+		{
+			[]string{`299 - "the API is deprecated"`},
+			[]*Warning{{Code: 299, Agent: "-", Text: "the API is deprecated"}},
+		},
+		// several warnings in one value, with the date and the escaped quote
+		{
+			[]string{`110 registry.local "Response is \"stale\"", 299 - "deprecated, use v2" "Sat, 25 Aug 2012 23:34:45 GMT"`},
+			[]*Warning{
+				{Code: 110, Agent: "registry.local", Text: `Response is "stale"`},
+				{Code: 299, Agent: "-", Text: "deprecated, use v2"},
+			},
+		},
+		// several values
+		{
+			[]string{`199 - "first"`, `214 - "second"`},
+			[]*Warning{
+				{Code: 199, Agent: "-", Text: "first"},
+				{Code: 214, Agent: "-", Text: "second"},
+			},
+		},
+		// malformed
+		{
+			[]string{`the API is deprecated`},
+			[]*Warning{{Text: "the API is deprecated"}},
+		},
+		{
+			[]string{`299 - "unterminated`},
+			[]*Warning{{Text: `299 - "unterminated`}},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.warnings, ParseWarnings(c.values))
+	}
+}
+
+func TestWarningTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Warning", `299 - "the registry API v1 is deprecated"`)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	buf := &bytes.Buffer{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stdout)
+
+	registry, err := NewDefaultImageRegistry(&model.Registry{
+		URL: server.URL,
+	})
+	require.Nil(t, err)
+	_, err = registry.BlobExist("library/hello-world", "sha256:1")
+	require.Nil(t, err)
+	assert.Contains(t, buf.String(), `the registry API v1 is deprecated`)
+	assert.Contains(t, buf.String(), server.URL)
+
+	// the same warning is logged only once
+	_, err = registry.BlobExist("library/hello-world", "sha256:2")
+	require.Nil(t, err)
+	assert.Equal(t, 1, strings.Count(buf.String(), "the registry API v1 is deprecated"))
+}
@@ -0,0 +1,94 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package classifier
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+)
+
+// Category is the category of the error, it's finer than the class and is used to
+// aggregate the failures of an execution, e.g. "17 auth, 3 timeout, 1 quota"
+type Category string
+
+// the categories of the errors
+const (
+	CategoryAuth        Category = "auth"
+	CategoryNotFound    Category = "not_found"
+	CategoryQuota       Category = "quota"
+	CategoryRateLimited Category = "rate_limited"
+	CategoryTimeout     Category = "timeout"
+	CategoryNetwork     Category = "network"
+	CategoryServer      Category = "server"
+	CategoryOther       Category = "other"
+)
+
+// Categorize the error by the status code, the type and the message of the error.
+// The rate limited errors are told by the classifiers. Returns empty category for the nil error
+func Categorize(err error) Category {
+	if err == nil {
+		return ""
+	}
+	if IsRateLimited(err) {
+		return CategoryRateLimited
+	}
+	// the quota errors are returned with varied status codes, e.g. 403 or 412
+	msg := strings.ToLower(err.Error())
+	if strings.Contains(msg, "quota") {
+		return CategoryQuota
+	}
+	switch e := err.(type) {
+	case *common_http.Error:
+		switch {
+		case e.Code == http.StatusUnauthorized, e.Code == http.StatusForbidden:
+			return CategoryAuth
+		case e.Code == http.StatusNotFound:
+			return CategoryNotFound
+		case e.Code == http.StatusRequestTimeout, e.Code == http.StatusGatewayTimeout:
+			return CategoryTimeout
+		case e.Code >= http.StatusInternalServerError:
+			return CategoryServer
+		}
+	case *url.Error:
+		if e.Timeout() {
+			return CategoryTimeout
+		}
+		return Categorize(e.Err)
+	case net.Error:
+		if e.Timeout() {
+			return CategoryTimeout
+		}
+		if _, ok := e.(*net.OpError); ok {
+			return CategoryNetwork
+		}
+	}
+	switch {
+	case strings.Contains(msg, "unauthorized"), strings.Contains(msg, "authentication required"),
+		strings.Contains(msg, "denied"):
+		return CategoryAuth
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "deadline exceeded"):
+		return CategoryTimeout
+	case strings.Contains(msg, "connection reset by peer"), strings.Contains(msg, "connection refused"),
+		strings.Contains(msg, "no such host"):
+		return CategoryNetwork
+	case strings.Contains(msg, "not found"), strings.Contains(msg, "unknown"):
+		return CategoryNotFound
+	}
+	return CategoryOther
+}
@@ -0,0 +1,160 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package classifier
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+)
+
+// Class is the class of the error
+type Class string
+
+// the classes of the errors
+const (
+	// the error may disappear when retrying later, e.g. the network errors
+	ClassTransient Class = "transient"
+	// the request is rate limited by the registry, it's transient too
+	ClassRateLimited Class = "rate_limited"
+	// retrying doesn't help, e.g. the resource isn't found
+	ClassPermanent Class = "permanent"
+)
+
+// ErrorClassifier classifies the errors. The "known" returned is false if the classifier
+// cannot tell the class of the error, the next classifier is consulted then
+type ErrorClassifier interface {
+	Classify(err error) (class Class, known bool)
+}
+
+// ErrorClassifierFunc adapts the function to the ErrorClassifier
+type ErrorClassifierFunc func(err error) (Class, bool)
+
+// Classify ...
+func (f ErrorClassifierFunc) Classify(err error) (Class, bool) {
+	return f(err)
+}
+
+type namedClassifier struct {
+	name       string
+	classifier ErrorClassifier
+}
+
+var (
+	classifiers   []*namedClassifier
+	classifiersMu sync.RWMutex
+)
+
+// Register the custom classifier, the custom classifiers are consulted in the order of
+// registration before the default one, so they can override the default classification
+func Register(name string, classifier ErrorClassifier) error {
+	if len(name) == 0 {
+		return errors.New("invalid error classifier name")
+	}
+	if classifier == nil {
+		return errors.New("empty error classifier")
+	}
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	for _, c := range classifiers {
+		if c.name == name {
+			return fmt.Errorf("error classifier %s already exists", name)
+		}
+	}
+	classifiers = append(classifiers, &namedClassifier{
+		name:       name,
+		classifier: classifier,
+	})
+	return nil
+}
+
+// Classify the error by the registered classifiers and then the default one.
+// Returns empty class for the nil error
+func Classify(err error) Class {
+	if err == nil {
+		return ""
+	}
+	classifiersMu.RLock()
+	defer classifiersMu.RUnlock()
+	for _, c := range classifiers {
+		if class, known := c.classifier.Classify(err); known {
+			return class
+		}
+	}
+	class, _ := Default.Classify(err)
+	return class
+}
+
+// IsTransient returns whether the error is transient(including rate limited) and worth retrying
+func IsTransient(err error) bool {
+	class := Classify(err)
+	return class == ClassTransient || class == ClassRateLimited
+}
+
+// IsRateLimited returns whether the error is caused by the rate limit of the registry
+func IsRateLimited(err error) bool {
+	return Classify(err) == ClassRateLimited
+}
+
+// Default is the default classifier: the status code 429 and the error code "TOOMANYREQUESTS"
+// of the registry API are rate limited. The status codes 408, 500, 502, 503, 504, the network
+// timeouts, the failed network operations(e.g. the refused or reset connections) and the
+// unexpected EOF are transient. Others are permanent
+var Default ErrorClassifier = ErrorClassifierFunc(classifyByDefault)
+
+func classifyByDefault(err error) (Class, bool) {
+	switch e := err.(type) {
+	case *common_http.Error:
+		switch e.Code {
+		case http.StatusTooManyRequests:
+			return ClassRateLimited, true
+		case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return ClassTransient, true
+		}
+	case *url.Error:
+		if e.Timeout() {
+			return ClassTransient, true
+		}
+		return classifyByDefault(e.Err)
+	case net.Error:
+		if e.Timeout() {
+			return ClassTransient, true
+		}
+		if _, ok := e.(*net.OpError); ok {
+			// the connection is refused or reset
+			return ClassTransient, true
+		}
+	}
+	if err == io.ErrUnexpectedEOF {
+		return ClassTransient, true
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "toomanyrequests"), strings.Contains(msg, "too many requests"):
+		return ClassRateLimited, true
+	case strings.Contains(msg, "connection reset by peer"), strings.Contains(msg, "connection refused"),
+		strings.Contains(msg, "i/o timeout"), strings.Contains(msg, "tls handshake timeout"):
+		return ClassTransient, true
+	}
+	return ClassPermanent, true
+}
@@ -0,0 +1,122 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package classifier
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type timeoutError struct{}
+
+func (t *timeoutError) Error() string   { return "timeout" }
+func (t *timeoutError) Timeout() bool   { return true }
+func (t *timeoutError) Temporary() bool { return true }
+
+func TestClassifyByDefault(t *testing.T) {
+	cases := []struct {
+		err   error
+		class Class
+	}{
+		{nil, ""},
+		{&common_http.Error{Code: http.StatusTooManyRequests}, ClassRateLimited},
+		{errors.New("toomanyrequests: You have reached your pull rate limit"), ClassRateLimited},
+		{&common_http.Error{Code: http.StatusServiceUnavailable}, ClassTransient},
+		{&common_http.Error{Code: http.StatusBadGateway}, ClassTransient},
+		{&common_http.Error{Code: http.StatusNotFound}, ClassPermanent},
+		{&common_http.Error{Code: http.StatusUnauthorized}, ClassPermanent},
+		{&url.Error{Op: "Get", URL: "https://registry.local/v2/", Err: &common_http.Error{Code: http.StatusTooManyRequests}}, ClassRateLimited},
+		{&url.Error{Op: "Get", URL: "https://registry.local/v2/", Err: &timeoutError{}}, ClassTransient},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, ClassTransient},
+		{errors.New("read tcp 10.0.0.1:443: connection reset by peer"), ClassTransient},
+		{io.ErrUnexpectedEOF, ClassTransient},
+		{errors.New("manifest unknown"), ClassPermanent},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.class, Classify(c.err))
+	}
+	assert.True(t, IsTransient(&common_http.Error{Code: http.StatusTooManyRequests}))
+	assert.True(t, IsRateLimited(&common_http.Error{Code: http.StatusTooManyRequests}))
+	assert.False(t, IsTransient(errors.New("manifest unknown")))
+	assert.False(t, IsTransient(nil))
+}
+
+var errQuotaExceeded = errors.New("quota exceeded")
+
+func TestRegister(t *testing.T) {
+	defer func() {
+		classifiers = nil
+	}()
+	// the custom classifier treats the quota errors as transient as the
+	// quota is extended regularly, and knows nothing about others
+	custom := ErrorClassifierFunc(func(err error) (Class, bool) {
+		if err == errQuotaExceeded {
+			return ClassTransient, true
+		}
+		if e, ok := err.(*common_http.Error); ok && e.Code == http.StatusServiceUnavailable {
+			return ClassPermanent, true
+		}
+		return "", false
+	})
+	assert.Equal(t, ClassPermanent, Classify(errQuotaExceeded))
+
+	// invalid
+	assert.NotNil(t, Register("", custom))
+	assert.NotNil(t, Register("quota", nil))
+
+	require.Nil(t, Register("quota", custom))
+	// already exists
+	assert.NotNil(t, Register("quota", custom))
+
+	// overrides the default one
+	assert.Equal(t, ClassTransient, Classify(errQuotaExceeded))
+	assert.Equal(t, ClassPermanent, Classify(&common_http.Error{Code: http.StatusServiceUnavailable}))
+	// falls back to the default one
+	assert.Equal(t, ClassRateLimited, Classify(&common_http.Error{Code: http.StatusTooManyRequests}))
+}
+
+func TestCategorize(t *testing.T) {
+	cases := []struct {
+		err      error
+		category Category
+	}{
+		{nil, ""},
+		{&common_http.Error{Code: http.StatusUnauthorized}, CategoryAuth},
+		{&common_http.Error{Code: http.StatusForbidden}, CategoryAuth},
+		{errors.New("unauthorized: authentication required"), CategoryAuth},
+		{&common_http.Error{Code: http.StatusForbidden, Message: "the quota of project library is exceeded"}, CategoryQuota},
+		{&common_http.Error{Code: http.StatusNotFound}, CategoryNotFound},
+		{errors.New("manifest unknown"), CategoryNotFound},
+		{&common_http.Error{Code: http.StatusTooManyRequests}, CategoryRateLimited},
+		{&common_http.Error{Code: http.StatusGatewayTimeout}, CategoryTimeout},
+		{&url.Error{Op: "Get", URL: "https://registry.local/v2/", Err: &timeoutError{}}, CategoryTimeout},
+		{&common_http.Error{Code: http.StatusInternalServerError}, CategoryServer},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}, CategoryNetwork},
+		{errors.New("read tcp 10.0.0.1:443: connection reset by peer"), CategoryNetwork},
+		{errors.New("invalid manifest"), CategoryOther},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.category, Categorize(c.err), "%v", c.err)
+	}
+}
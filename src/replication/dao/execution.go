@@ -15,6 +15,7 @@
 package dao
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 
@@ -96,6 +97,21 @@ func GetExecution(id int64) (*models.Execution, error) {
 
 // fillExecution will fill the statistics data and status by tasks data
 func fillExecution(execution *models.Execution) error {
+	if len(execution.ErrorSummaryText) > 0 {
+		if err := json.Unmarshal([]byte(execution.ErrorSummaryText), &execution.ErrorSummary); err != nil {
+			log.Errorf("failed to unmarshal the error summary of execution %d: %v", execution.ID, err)
+		}
+	}
+	if len(execution.FilterSummaryText) > 0 {
+		if err := json.Unmarshal([]byte(execution.FilterSummaryText), &execution.FilterSummary); err != nil {
+			log.Errorf("failed to unmarshal the filter summary of execution %d: %v", execution.ID, err)
+		}
+	}
+	if len(execution.SummaryText) > 0 {
+		if err := json.Unmarshal([]byte(execution.SummaryText), &execution.Summary); err != nil {
+			log.Errorf("failed to unmarshal the summary of execution %d: %v", execution.ID, err)
+		}
+	}
 	if executionFinished(execution.Status) {
 		return nil
 	}
@@ -133,14 +149,62 @@ func fillExecution(execution *models.Execution) error {
 
 	// if execution status changed to a final status, store to DB
 	if executionFinished(execution.Status) {
+		if execution.Failed > 0 {
+			aggregateErrors(execution)
+		}
 		UpdateExecution(execution, models.ExecutionPropsName.Status, models.ExecutionPropsName.InProgress,
 			models.ExecutionPropsName.Succeed, models.ExecutionPropsName.Failed, models.ExecutionPropsName.Stopped,
-			models.ExecutionPropsName.EndTime, models.ExecutionPropsName.Total)
+			models.ExecutionPropsName.Skipped, models.ExecutionPropsName.EndTime, models.ExecutionPropsName.Total,
+			models.ExecutionPropsName.ErrorSummary, models.ExecutionPropsName.FetchFailed)
 	}
 	return nil
 }
 
+// aggregate the failed tasks of the finished execution by the error category, the summary
+// is logged once here as the execution is finished only once
+func aggregateErrors(execution *models.Execution) {
+	o := dao.GetOrmer()
+	sql := `select error_category, count(*) as c from replication_task where execution_id = ? and status = ? group by error_category`
+	stats := []*models.TaskErrorStat{}
+	if _, err := o.Raw(sql, execution.ID, models.TaskStatusFailed).QueryRows(&stats); err != nil {
+		log.Errorf("Query the failed tasks error execution %d: %v", execution.ID, err)
+		return
+	}
+	execution.ErrorSummary = summarizeErrors(stats)
+	data, err := json.Marshal(execution.ErrorSummary)
+	if err != nil {
+		log.Errorf("failed to marshal the error summary of execution %d: %v", execution.ID, err)
+		return
+	}
+	execution.ErrorSummaryText = string(data)
+	log.Infof("the execution %d finished with %d failed tasks: %s", execution.ID,
+		execution.Failed, models.FormatErrorSummary(execution.ErrorSummary))
+}
+
+// group the counts of the failed tasks by the error category, the failures
+// without category(e.g. the job crashes) are counted as "unknown"
+func summarizeErrors(stats []*models.TaskErrorStat) map[string]int {
+	summary := map[string]int{}
+	for _, stat := range stats {
+		category := stat.ErrorCategory
+		if len(category) == 0 {
+			category = "unknown"
+		}
+		summary[category] += stat.C
+	}
+	return summary
+}
+
+// return the status that the task status is counted as, the intentionally
+// skipped tasks are counted as "Skipped" and the ones recording the fetching failures
+// are counted as "FetchFailed", neither of them is a status of execution
 func getStatus(status string) (string, error) {
+	if models.IsTaskSkipped(status) {
+		return models.TaskStatusSkipped, nil
+	}
+	if status == models.TaskStatusFetchFailed {
+		return models.TaskStatusFetchFailed, nil
+	}
 	switch status {
 	case models.TaskStatusInitialized, models.TaskStatusPending, models.TaskStatusInProgress:
 		return models.ExecutionStatusInProgress, nil
@@ -164,6 +228,10 @@ func updateStatusCount(execution *models.Execution, status string, delta int) er
 		execution.Stopped += delta
 	case models.ExecutionStatusFailed:
 		execution.Failed += delta
+	case models.TaskStatusSkipped:
+		execution.Skipped += delta
+	case models.TaskStatusFetchFailed:
+		execution.FetchFailed += delta
 	}
 	return nil
 }
@@ -194,17 +262,14 @@ func generateStatus(execution *models.Execution) string {
 		return models.ExecutionStatusFailed
 	} else if execution.Stopped > 0 {
 		return models.ExecutionStatusStopped
+	} else if execution.FetchFailed > 0 {
+		return models.ExecutionStatusPartialSuccess
 	}
 	return models.ExecutionStatusSucceed
 }
 
 func executionFinished(status string) bool {
-	if status == models.ExecutionStatusStopped ||
-		status == models.ExecutionStatusSucceed ||
-		status == models.ExecutionStatusFailed {
-		return true
-	}
-	return false
+	return models.IsExecutionFinished(status)
 }
 
 // DeleteExecution ...
@@ -322,12 +387,13 @@ func UpdateTask(task *models.Task, props ...string) (int64, error) {
 	return o.Update(task, props...)
 }
 
-// UpdateTaskStatus ...
+// UpdateTaskStatus updates the status of the task, the task is updated only when
+// its current status is one of the "statusCondition" if they are specified
 func UpdateTaskStatus(id int64, status string, statusCondition ...string) (int64, error) {
 	qs := dao.GetOrmer().QueryTable(&models.Task{}).
 		Filter("id", id)
 	if len(statusCondition) > 0 {
-		qs = qs.Filter("status", statusCondition[0])
+		qs = qs.Filter("status__in", statusCondition)
 	}
 	params := orm.Params{
 		"status": status,
@@ -345,7 +411,9 @@ func UpdateTaskStatus(id int64, status string, statusCondition ...string) (int64
 }
 
 func taskFinished(status string) bool {
-	if status == models.TaskStatusFailed || status == models.TaskStatusStopped || status == models.TaskStatusSucceed {
+	if status == models.TaskStatusFailed || status == models.TaskStatusStopped ||
+		status == models.TaskStatusSucceed || models.IsTaskSkipped(status) ||
+		models.IsTaskPreviewed(status) {
 		return true
 	}
 	return false
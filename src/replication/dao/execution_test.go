@@ -157,6 +157,16 @@ func TestMethodOfTask(t *testing.T) {
 	task, _ = GetTask(id1)
 	assert.Equal(t, "Succeed", task.Status)
 
+	// test update status with conditions
+	n, err = UpdateTaskStatus(id1, "InProgress", "Initialized", "Pending")
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+	n, err = UpdateTaskStatus(id1, "Failed", "InProgress", "Succeed")
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), n)
+	task, _ = GetTask(id1)
+	assert.Equal(t, "Failed", task.Status)
+
 	// test delete
 	require.Nil(t, DeleteTask(id1))
 	task, err = GetTask(id1)
@@ -287,3 +297,54 @@ func TestExecutionFill2(t *testing.T) {
 	assert.Equal(t, 1, exes[0].Failed)
 	assert.Equal(t, 0, exes[0].Succeed)
 }
+
+func TestGetStatus(t *testing.T) {
+	cases := map[string]string{
+		models.TaskStatusPending:     models.ExecutionStatusInProgress,
+		models.TaskStatusSucceed:     models.ExecutionStatusSucceed,
+		models.TaskStatusFailed:      models.ExecutionStatusFailed,
+		models.TaskStatusStopped:     models.ExecutionStatusStopped,
+		models.TaskStatusSkipped:     models.TaskStatusSkipped,
+		models.TaskStatusDeferred:    models.TaskStatusSkipped,
+		models.TaskStatusDenied:      models.TaskStatusSkipped,
+		models.TaskStatusOverQuota:   models.TaskStatusSkipped,
+		models.TaskStatusTimedOut:    models.TaskStatusSkipped,
+		models.TaskStatusRateLimited: models.TaskStatusSkipped,
+		models.TaskStatusFetchFailed: models.TaskStatusFetchFailed,
+	}
+	for taskStatus, expected := range cases {
+		status, err := getStatus(taskStatus)
+		require.Nil(t, err)
+		assert.Equal(t, expected, status)
+	}
+
+	// the skipped tasks are counted separately and don't affect the status
+	execution := &models.Execution{}
+	updateStatusCount(execution, models.TaskStatusSkipped, 2)
+	updateStatusCount(execution, models.ExecutionStatusSucceed, 1)
+	assert.Equal(t, 2, execution.Skipped)
+	assert.Equal(t, models.ExecutionStatusSucceed, generateStatus(execution))
+	// the fetching failures make the succeeded execution partial success
+	updateStatusCount(execution, models.TaskStatusFetchFailed, 1)
+	assert.Equal(t, 1, execution.FetchFailed)
+	assert.Equal(t, models.ExecutionStatusPartialSuccess, generateStatus(execution))
+	updateStatusCount(execution, models.ExecutionStatusFailed, 1)
+	assert.Equal(t, models.ExecutionStatusFailed, generateStatus(execution))
+	assert.True(t, executionFinished(models.ExecutionStatusPartialSuccess))
+}
+
+func TestSummarizeErrors(t *testing.T) {
+	summary := summarizeErrors([]*models.TaskErrorStat{
+		{ErrorCategory: "auth", C: 17},
+		{ErrorCategory: "timeout", C: 3},
+		{ErrorCategory: "quota", C: 1},
+		{ErrorCategory: "", C: 2},
+	})
+	assert.Equal(t, map[string]int{
+		"auth":    17,
+		"timeout": 3,
+		"quota":   1,
+		"unknown": 2,
+	}, summary)
+	assert.Equal(t, "17 auth, 3 timeout, 2 unknown, 1 quota", models.FormatErrorSummary(summary))
+}
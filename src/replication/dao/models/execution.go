@@ -1,6 +1,9 @@
 package models
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/goharbor/harbor/src/replication/model"
@@ -19,6 +22,14 @@ const (
 	ExecutionStatusSucceed    string = "Succeed"
 	ExecutionStatusStopped    string = "Stopped"
 	ExecutionStatusInProgress string = "InProgress"
+	// The execution is a dry run, the tasks are recorded but not submitted
+	ExecutionStatusDryRun string = "DryRun"
+	// The tasks of the execution succeeded but some resources failed to
+	// be fetched from the source registry by the best-effort fetching
+	ExecutionStatusPartialSuccess string = "PartialSuccess"
+	// The execution is skipped without any task as the previous execution
+	// of the policy is still running
+	ExecutionStatusSkipped string = "Skipped"
 
 	ExecutionTriggerManual   string = "Manual"
 	ExecutionTriggerEvent    string = "Event"
@@ -31,8 +42,49 @@ const (
 	TaskStatusSucceed     string = "Succeed"
 	TaskStatusFailed      string = "Failed"
 	TaskStatusStopped     string = "Stopped"
+	// The task isn't submitted as the byte budget of the execution
+	// is reached, it will be replicated by the next execution
+	TaskStatusDeferred string = "Deferred"
+	// The task is denied by the pre-copy webhook of the policy
+	TaskStatusDenied string = "Denied"
+	// The task isn't submitted as copying the resources of the destination
+	// namespace would exceed the quota of the namespace
+	TaskStatusOverQuota string = "OverQuota"
+	// The task exceeds its deadline, it's requeued to the next execution
+	TaskStatusTimedOut string = "TimedOut"
+	// The task is rate limited by the registry, it's requeued to the next execution
+	TaskStatusRateLimited string = "RateLimited"
+	// The task isn't run intentionally, e.g. the resource isn't modified
+	TaskStatusSkipped string = "Skipped"
+	// The task is recorded by a dry run, it would copy the resource in a real execution
+	TaskStatusWouldCopy string = "WouldCopy"
+	// The task is recorded by a dry run, it would delete the resource in a real execution
+	TaskStatusWouldDelete string = "WouldDelete"
+	// The task records the resources failed to be fetched from the source
+	// registry by the best-effort fetching, nothing is replicated by it
+	TaskStatusFetchFailed string = "FetchFailed"
 )
 
+// IsTaskSkipped returns whether the task with the status is skipped intentionally,
+// the skipped tasks are counted separately from the failed and stopped ones
+func IsTaskSkipped(status string) bool {
+	return status == TaskStatusSkipped || status == TaskStatusDeferred ||
+		status == TaskStatusDenied || status == TaskStatusOverQuota ||
+		status == TaskStatusTimedOut || status == TaskStatusRateLimited
+}
+
+// IsExecutionFinished returns whether the execution with the status is finished
+func IsExecutionFinished(status string) bool {
+	return status == ExecutionStatusStopped || status == ExecutionStatusSucceed ||
+		status == ExecutionStatusFailed || status == ExecutionStatusDryRun ||
+		status == ExecutionStatusPartialSuccess || status == ExecutionStatusSkipped
+}
+
+// IsTaskPreviewed returns whether the task is recorded by a dry run
+func IsTaskPreviewed(status string) bool {
+	return status == TaskStatusWouldCopy || status == TaskStatusWouldDelete
+}
+
 // ExecutionPropsName defines the names of fields of Execution
 var ExecutionPropsName = ExecutionFieldsName{
 	ID:         "ID",
@@ -44,9 +96,17 @@ var ExecutionPropsName = ExecutionFieldsName{
 	Succeed:    "Succeed",
 	InProgress: "InProgress",
 	Stopped:    "Stopped",
+	Skipped:    "Skipped",
 	Trigger:    "Trigger",
 	StartTime:  "StartTime",
 	EndTime:    "EndTime",
+
+	ErrorSummary: "ErrorSummaryText",
+	FetchFailed:  "FetchFailed",
+	Digests:      "Digests",
+
+	FilterSummary: "FilterSummaryText",
+	Summary:       "SummaryText",
 }
 
 // ExecutionFieldsName defines the props of Execution
@@ -60,9 +120,17 @@ type ExecutionFieldsName struct {
 	Succeed    string
 	InProgress string
 	Stopped    string
+	Skipped    string
 	Trigger    string
 	StartTime  string
 	EndTime    string
+
+	ErrorSummary string
+	FetchFailed  string
+	Digests      string
+
+	FilterSummary string
+	Summary       string
 }
 
 // Execution holds information about once replication execution.
@@ -76,9 +144,93 @@ type Execution struct {
 	Succeed    int               `orm:"column(succeed)" json:"succeed"`
 	InProgress int               `orm:"column(in_progress)" json:"in_progress"`
 	Stopped    int               `orm:"column(stopped)" json:"stopped"`
+	Skipped    int               `orm:"column(skipped)" json:"skipped"`
 	Trigger    model.TriggerType `orm:"column(trigger)" json:"trigger"`
 	StartTime  time.Time         `orm:"column(start_time)" json:"start_time"`
 	EndTime    time.Time         `orm:"column(end_time)" json:"end_time"`
+	// the count of the failed tasks grouped by the error category, it's
+	// aggregated when the execution finishes
+	ErrorSummary     map[string]int `orm:"-" json:"error_summary,omitempty"`
+	ErrorSummaryText string         `orm:"column(error_summary)" json:"-"`
+	// the count of the resource types or namespaces failed to be fetched by
+	// the best-effort fetching
+	FetchFailed int `orm:"column(fetch_failed)" json:"fetch_failed"`
+	// the digests of the tags fetched by the incremental replication indexed by
+	// "repository:tag" as JSON, the next execution only replicates the tags whose
+	// digests differ if this one succeeds
+	Digests string `orm:"column(digests)" json:"-"`
+	// the count of the resources dropped by each type of the filters, it helps
+	// to find out the filters dropping more resources than expected
+	FilterSummary     map[string]int `orm:"-" json:"filter_summary,omitempty"`
+	FilterSummaryText string         `orm:"column(filter_summary)" json:"-"`
+	// the summary of the run of the flow, it's stored once the run ends. The outcome
+	// of the tasks is counted by the "Succeed", "Failed", etc. when they finish
+	Summary     *ExecutionSummary `orm:"-" json:"summary,omitempty"`
+	SummaryText string            `orm:"column(summary)" json:"-"`
+}
+
+// ExecutionSummary is the summary of the run of the flow of one execution
+type ExecutionSummary struct {
+	// the count of resources fetched from the source registry
+	Fetched int `json:"fetched"`
+	// the count of resources left after filtering
+	Filtered int `json:"filtered"`
+	// the count of tasks created, including the skipped ones
+	Created int `json:"created"`
+	// the count of tasks submitted successfully
+	Submitted int `json:"submitted"`
+	// the count of tasks failed to be submitted
+	FailedToSubmit int `json:"failed_to_submit"`
+	// the count of tasks skipped intentionally by the flow
+	Skipped int `json:"skipped"`
+	// the bytes of the submitted tasks estimated by the byte budget of the
+	// policy, it's omitted as the bytes are unknown if there is no budget
+	EstimatedBytes *int64 `json:"estimated_bytes,omitempty"`
+	// the elapsed time of the run in milliseconds
+	Elapsed int64 `json:"elapsed"`
+}
+
+// FormatErrorSummary formats the error summary of the execution ordered by the count,
+// e.g. "17 auth, 3 timeout, 1 quota"
+func FormatErrorSummary(summary map[string]int) string {
+	groups := []string{}
+	for _, category := range sortByCount(summary) {
+		groups = append(groups, fmt.Sprintf("%d %s", summary[category], category))
+	}
+	return strings.Join(groups, ", ")
+}
+
+// FormatFilterSummary formats the filter summary of the execution ordered by the count,
+// e.g. "name filter dropped 120, tag filter dropped 45"
+func FormatFilterSummary(summary map[string]int) string {
+	groups := []string{}
+	for _, filter := range sortByCount(summary) {
+		groups = append(groups, fmt.Sprintf("%s filter dropped %d", filter, summary[filter]))
+	}
+	return strings.Join(groups, ", ")
+}
+
+// FormatTaskOutcome formats the outcome of the tasks of the execution,
+// e.g. "tasks succeeded: 8, failed: 1, stopped: 0, skipped: 2"
+func FormatTaskOutcome(execution *Execution) string {
+	return fmt.Sprintf("tasks succeeded: %d, failed: %d, stopped: %d, skipped: %d",
+		execution.Succeed, execution.Failed, execution.Stopped, execution.Skipped)
+}
+
+// returns the keys of the summary ordered by the count descending and then by the name
+func sortByCount(summary map[string]int) []string {
+	keys := []string{}
+	for key := range summary {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := summary[keys[i]], summary[keys[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
 }
 
 // TaskPropsName defines the names of fields of Task
@@ -92,6 +244,8 @@ var TaskPropsName = TaskFieldsName{
 	Status:       "Status",
 	StartTime:    "StartTime",
 	EndTime:      "EndTime",
+
+	ErrorCategory: "ErrorCategory",
 }
 
 // TaskFieldsName defines the props of Task
@@ -105,6 +259,8 @@ type TaskFieldsName struct {
 	Status       string
 	StartTime    string
 	EndTime      string
+
+	ErrorCategory string
 }
 
 // Task represent the tasks in one execution.
@@ -119,6 +275,8 @@ type Task struct {
 	Status       string     `orm:"column(status)" json:"status"`
 	StartTime    *time.Time `orm:"column(start_time)" json:"start_time"`
 	EndTime      *time.Time `orm:"column(end_time)" json:"end_time,omitempty"`
+	// the category of the error failing the task, e.g. "auth"
+	ErrorCategory string `orm:"column(error_category)" json:"error_category,omitempty"`
 }
 
 // TableName is required by by beego orm to map Execution to table replication_execution
@@ -153,3 +311,9 @@ type TaskStat struct {
 	Status string `orm:"column(status)"`
 	C      int    `orm:"column(c)"`
 }
+
+// TaskErrorStat holds statistics of the failed tasks by error category
+type TaskErrorStat struct {
+	ErrorCategory string `orm:"column(error_category)"`
+	C             int    `orm:"column(c)"`
+}
@@ -0,0 +1,52 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatErrorSummary(t *testing.T) {
+	assert.Equal(t, "", FormatErrorSummary(nil))
+	// ordered by the count and then the category
+	assert.Equal(t, "17 auth, 3 quota, 3 timeout, 1 other", FormatErrorSummary(map[string]int{
+		"timeout": 3,
+		"other":   1,
+		"auth":    17,
+		"quota":   3,
+	}))
+}
+
+func TestFormatFilterSummary(t *testing.T) {
+	assert.Equal(t, "", FormatFilterSummary(nil))
+	// ordered by the count and then the filter type
+	assert.Equal(t, "name filter dropped 120, label filter dropped 45, tag filter dropped 45",
+		FormatFilterSummary(map[string]int{
+			"tag":   45,
+			"name":  120,
+			"label": 45,
+		}))
+}
+
+func TestFormatTaskOutcome(t *testing.T) {
+	assert.Equal(t, "tasks succeeded: 8, failed: 1, stopped: 0, skipped: 2",
+		FormatTaskOutcome(&Execution{
+			Succeed: 8,
+			Failed:  1,
+			Skipped: 2,
+		}))
+}
@@ -18,6 +18,7 @@ type RepPolicy struct {
 	ReplicateDeletion bool      `orm:"column(replicate_deletion)" json:"replicate_deletion"`
 	CreationTime      time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
 	UpdateTime        time.Time `orm:"column(update_time);auto_now" json:"update_time"`
+	Options           string    `orm:"column(options)" json:"options"`
 }
 
 // TableName set table name for ORM.
@@ -26,6 +26,9 @@ type Registry struct {
 	Health         string    `orm:"column(health)" json:"health"`
 	CreationTime   time.Time `orm:"column(creation_time);auto_now_add" json:"creation_time"`
 	UpdateTime     time.Time `orm:"column(update_time);auto_now" json:"update_time"`
+
+	// the JSON encoded mapping of host -> IP used to pin the hosts of the registry
+	ResolveOverride string `orm:"column(resolve_override)" json:"resolve_override"`
 }
 
 // TableName is required by by beego orm to map Registry to table registry
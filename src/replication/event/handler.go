@@ -18,8 +18,6 @@ import (
 	"errors"
 	"fmt"
 
-	"github.com/goharbor/harbor/src/replication/util"
-
 	"github.com/goharbor/harbor/src/common/utils/log"
 	"github.com/goharbor/harbor/src/replication/config"
 	"github.com/goharbor/harbor/src/replication/model"
@@ -134,10 +132,17 @@ func match(filters []*model.Filter, resource *model.Resource) (bool, error) {
 		if filter.Type != model.FilterTypeName {
 			continue
 		}
-		m, err := util.Match(filter.Value.(string), repository)
+		matcher, err := filter.GetMatcher()
 		if err != nil {
 			return false, err
 		}
+		m, err := matcher.Match(repository)
+		if err != nil {
+			return false, err
+		}
+		if filter.IsExclusion() {
+			m = !m
+		}
 		if !m {
 			match = false
 			break
@@ -16,12 +16,17 @@ package model
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/goharbor/harbor/src/replication/filter"
+	"github.com/goharbor/harbor/src/replication/util"
 
 	"github.com/astaxie/beego/validation"
 	"github.com/goharbor/harbor/src/common/models"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/opencontainers/go-digest"
 	"github.com/robfig/cron"
 )
 
@@ -31,6 +36,85 @@ const (
 	FilterTypeName     FilterType = "name"
 	FilterTypeTag      FilterType = "tag"
 	FilterTypeLabel    FilterType = "label"
+	// keep only the latest patch of each semver minor line, the tags must be
+	// the full semantic versions(e.g. "1.4" isn't), the value indicates
+	// whether to keep the non-semver tags
+	FilterTypeLatestPatch FilterType = "latest_patch"
+	// keep only the tags whose digests on the source registry differ from
+	// the ones on the destination registry, the value indicates whether the
+	// filter is enabled
+	FilterTypeModified FilterType = "modified"
+	// drop the tags pushed within the specified seconds to let the source
+	// settle, e.g. the transient tags pushed by CI
+	FilterTypeMinAge FilterType = "min_age"
+	// keep only the resources whose repositories have any tag pushed within the
+	// specified seconds to skip the dormant repositories entirely
+	FilterTypeMaxInactivity FilterType = "max_inactivity"
+	// keep only the resources whose repositories have the specified visibility:
+	// "public" or "private"
+	FilterTypeVisibility FilterType = "visibility"
+	// keep only the resources whose repositories are pulled at least the specified
+	// times, e.g. to mirror only the actively used repositories
+	FilterTypePullCount FilterType = "pull_count"
+	// keep only the latest N tags of each resource, e.g. for the cache-style
+	// replication, the value is the "LatestTags"
+	FilterTypeLatestTags FilterType = "latest_tags"
+	// keep only the sub-manifests of the specified platforms("os/arch" or "os/arch/variant")
+	// of the multi-arch images, e.g. ["linux/amd64", "linux/arm64"]
+	FilterTypePlatform FilterType = "platform"
+	// keep only the tags whose manifests have the specified digests, e.g. to pin the
+	// replication to the audited images. The tag filters are ignored by the resources
+	// it applies to and the digests are matched against the "Digests" of the metadata
+	FilterTypeDigest FilterType = "digest"
+
+	// the matching modes of the name and tag filters
+	FilterModeGlob   FilterMode = "glob"
+	FilterModeRegex  FilterMode = "regex"
+	FilterModeSemver FilterMode = "semver"
+
+	// the decorations of the name and tag filters: keep or drop the matched ones
+	FilterDecorationMatches  = "matches"
+	FilterDecorationExcludes = "excludes"
+
+	VisibilityPublic  = "public"
+	VisibilityPrivate = "private"
+
+	// the orders of the latest tags filter
+	LatestTagsOrderByPushTime = "push_time"
+	LatestTagsOrderBySemver   = "semver"
+
+	// the order of processing the tags when the count of tags of one
+	// repository exceeds the "MaxTagsPerRepository" of the policy
+	TagOrderOldestFirst = "oldest_first"
+	TagOrderNewestFirst = "newest_first"
+
+	// normalize the destination tags of the images into lowercase
+	TagNormalizationLowercase = "lowercase"
+
+	// the modes of running the tasks of the executions
+	ExecutionModeParallel = "parallel"
+	ExecutionModeOrdered  = "ordered"
+
+	// the ways handling the failure of signing the copied images: fail the
+	// task or log a warning
+	SigningFailureFail = "fail"
+	SigningFailureWarn = "warn"
+
+	// the media types translated into when copying the images: the Docker or OCI ones
+	MediaTypeTranslationDocker = "docker"
+	MediaTypeTranslationOCI    = "oci"
+
+	// the ways handling the repositories without any tag: replicate them as the
+	// other ones or skip them entirely
+	EmptyRepositoriesInclude = "include"
+	EmptyRepositoriesSkip    = "skip"
+
+	// the ways handling the new execution of the policy when its previous
+	// execution is still running: start it anyway, skip it, or queue it until
+	// the previous one finishes
+	ConcurrentExecutionAllow = "allow"
+	ConcurrentExecutionSkip  = "skip"
+	ConcurrentExecutionQueue = "queue"
 
 	TriggerTypeManual     TriggerType = "manual"
 	TriggerTypeScheduled  TriggerType = "scheduled"
@@ -52,6 +136,10 @@ type Policy struct {
 	// or keep namespaces same with the source ones (under this case,
 	// the DestNamespace should be set to empty)
 	DestNamespace string `json:"dest_namespace"`
+	// The rules remapping the source repositories to the destination ones, e.g. "library/*"
+	// to "mirror/library/*". The first matched rule wins and the unmatched repositories keep
+	// their original names. They cannot be used together with the "DestNamespace"
+	NamespaceMappings []*NamespaceMapping `json:"namespace_mappings,omitempty"`
 	// Filters
 	Filters []*Filter `json:"filters"`
 	// Trigger
@@ -61,12 +149,210 @@ type Policy struct {
 	Deletion bool `json:"deletion"`
 	// If override the image tag
 	Override bool `json:"override"`
+	// The override settings of the destination namespaces(namespace -> override),
+	// they take precedence over the "Override" for the resources in the namespaces
+	NamespaceOverrides map[string]bool `json:"namespace_overrides,omitempty"`
+	// If fail the replication when the source namespace specified
+	// in the name filter doesn't exist
+	StrictSrcNamespace bool `json:"strict_src_namespace"`
+	// Include the default namespace of the source registry(e.g. "library" of Docker Hub) when
+	// no source namespace is specified in the name filter, as it isn't listed by some adapters.
+	// Only the adapters providing the default namespace support it
+	IncludeDefaultSrcNamespace bool `json:"include_default_src_namespace"`
+	// The settings applied to the destination projects created by the replication, e.g. the
+	// storage quota. They're applied only when creating the projects, the existing ones are
+	// kept as they are. Only the Harbor destination supports them
+	DestProjectSettings *ProjectSettings `json:"dest_project_settings,omitempty"`
+	// Create each destination namespace just in time before submitting its first task rather
+	// than creating all of them before submitting any task, so the copy starts sooner
+	LazyNamespaceCreation bool `json:"lazy_namespace_creation"`
+	// The max attempts of creating the adapters of the registries and the delay in milliseconds
+	// before the first retry, the delay doubles for the later retries. Only the transient errors
+	// are retried and the default ones of the flow are used if <= 0
+	AdapterCreationMaxAttempts int `json:"adapter_creation_max_attempts"`
+	AdapterCreationBaseDelay   int `json:"adapter_creation_base_delay"`
+	// The bounds of the count of the tasks of each resource type in flight at the same time.
+	// The count is tuned adaptively according to the error rate of the last execution when
+	// MaxConcurrency > 0, and is also bounded by the MaxInFlightTasks if it's set
+	MinConcurrency int `json:"min_concurrency"`
+	MaxConcurrency int `json:"max_concurrency"`
+	// The max count of the tasks of each resource type in flight(submitted but not finished)
+	// at the same time, the rest are submitted as the earlier ones finish. No limit if <= 0
+	MaxInFlightTasks int `json:"max_in_flight_tasks"`
+	// How the tasks of the execution run: "parallel"(default) submits them as fast as the
+	// concurrency settings allow and they run in any order, "ordered" runs them one by one
+	// in the order of the resources, the next one is submitted once the previous one finishes.
+	// The "MaxInFlightTasks" is ignored in the "ordered" mode
+	ExecutionMode string `json:"execution_mode"`
+	// The count of the resource types and namespaces fetched in parallel from the
+	// source registry, the default one of the flow is used if <= 0
+	FetchConcurrency int `json:"fetch_concurrency"`
+	// Fetch the resources in best effort: the resource types and namespaces failed to
+	// be fetched are recorded and the execution continues with the fetched ones. The
+	// whole fetching fails if any of them fails by default
+	BestEffortFetch bool `json:"best_effort_fetch"`
+	// The seconds after which fetching one resource type(or one namespace) from the source
+	// registry is aborted and fails, so a stuck registry doesn't block the execution forever.
+	// The default one of the flow is used if it's 0
+	FetchTimeout int `json:"fetch_timeout"`
+	// The max count of the resources fetched from the source registry by one execution, the
+	// execution fails before creating any task if more are fetched, so a misconfigured filter
+	// matching the whole registry doesn't flood the database with the tasks. The default one
+	// of the flow is used if it's 0
+	MaxResources int `json:"max_resources"`
+	// Only replicate the resources changed since the last execution that succeeded
+	// fully. The full scan is done if there is no such execution
+	Incremental bool `json:"incremental"`
+	// The namespaces that the credential of the source registry has access to, only
+	// the resources under them are fetched and replicated. All namespaces if empty
+	CredentialNamespaces []string `json:"credential_namespaces,omitempty"`
+	// The max bytes transferred by one execution, the tasks exceeding
+	// the budget are deferred to the next execution. No limit if <= 0
+	MaxBytesPerExecution int64 `json:"max_bytes_per_execution"`
+	// The User-Agent carried by the requests sent to the source and destination
+	// registries, the default one identifying Harbor is used if it's empty
+	UserAgent string `json:"user_agent"`
+	// The max bytes of the manifests pulled from the registries, the larger ones
+	// are rejected. The default limit(4MiB) is used if it's <= 0
+	MaxManifestSize int64 `json:"max_manifest_size"`
+	// The webhook called to approve or deny every resource before copying it,
+	// the resource is copied anyway if the webhook fails and it's fail-open
+	PreCopyWebhookURL      string `json:"pre_copy_webhook_url"`
+	PreCopyWebhookFailOpen bool   `json:"pre_copy_webhook_fail_open"`
+	// The max count of the tags processed per repository in one execution, the tags
+	// already replicated are skipped and the excess is processed by the subsequent
+	// executions. No limit if <= 0
+	MaxTagsPerRepository int `json:"max_tags_per_repository"`
+	// The max count of the repositories processed per source namespace in one execution,
+	// the tasks of the excess repositories are deferred to the next execution. No limit if <= 0
+	MaxRepositoriesPerNamespace int `json:"max_repositories_per_namespace"`
+	// The max count of the tags replicated by one task, the resources with more tags
+	// are split into several tasks which are run and retried independently. No split if <= 0
+	TagsPerTask int `json:"tags_per_task"`
+	// The order of processing the tags: "oldest_first"(default) or "newest_first"
+	TagOrder string `json:"tag_order"`
+	// Strip the implicit "library/" namespace of the official images of Docker Hub
+	// when assembling the destination resources, e.g. "library/nginx" -> "nginx"
+	StripLibraryNamespace bool `json:"strip_library_namespace"`
+	// Include the untagged manifests(only referenced by digest) of the image repositories,
+	// they are replicated by digest
+	IncludeUntagged bool `json:"include_untagged"`
+	// Move the resources: the source resources are deleted after being copied
+	// to the destination registry successfully
+	Move bool `json:"move"`
+	// The seconds after which the copy of a blob is aborted and retried if
+	// no bytes are transferred. No idle timeout if <= 0
+	BlobIdleTimeout int `json:"blob_idle_timeout"`
+	// The size in bytes of the buffer reading the blobs from the source registry, a larger
+	// one gives better throughput on the high-latency links. The default one(1 MiB) is used if <= 0
+	BlobBufferSize int `json:"blob_buffer_size"`
+	// The count of the tags of one repository copied concurrently by one task,
+	// the tags are copied one by one if it's <= 1
+	TagConcurrency int `json:"tag_concurrency"`
+	// The count of retries when pushing a manifest to the destination registry gets 5xx, the
+	// existence of the manifest is checked before retrying as the push may succeed on the server
+	// side. No retry if <= 0
+	ManifestPushRetries int `json:"manifest_push_retries"`
+	// Push the config blob of the images first and check it's accepted by the destination
+	// registry before copying the layers, so the copy fails fast without transferring any
+	// layer if the destination rejects the config
+	ConfigBlobFirst bool `json:"config_blob_first"`
+	// The max count of the blobs uploaded concurrently to the destination registry by all the
+	// tasks running in the same job service, across the tasks rather than within one task like
+	// the "TagConcurrency". The uploads of other policies targeting the registry are counted
+	// too. No limit if <= 0
+	MaxDestinationBlobUploads int `json:"max_destination_blob_uploads"`
+	// The max bandwidth in MB/s consumed by copying the blobs, it's shared by all the
+	// tasks of one execution running in the same job service. No limit if <= 0
+	BandwidthLimit int `json:"bandwidth_limit"`
+	// The normalization applied to the destination tags of the images, e.g. "lowercase".
+	// Only the first one of the source tags normalized to the same tag is replicated
+	TagNormalization string `json:"tag_normalization"`
+	// Check the health of the destination registry before submitting the tasks,
+	// the execution is aborted if the destination registry isn't healthy
+	DestinationHealthGate bool `json:"destination_health_gate"`
+	// The name of the signer signing the copied images on the destination registry, the
+	// images aren't signed if it's empty. The signing failure fails the task if the
+	// "SigningFailure" is "fail", otherwise(default) only a warning is logged
+	Signer         string `json:"signer"`
+	SigningFailure string `json:"signing_failure"`
+	// Translate the media types of the manifests, configs and layers of the images into
+	// the Docker("docker") or OCI("oci") equivalents for the destination registry which
+	// rejects the other ones. Only the images whose media types all have the equivalents
+	// are translated, and the digests of the translated manifests are changed
+	MediaTypeTranslation string `json:"media_type_translation"`
+	// The handling of the image repositories without any tag, they're skipped entirely(no
+	// namespace is created for them on the destination) if it's "skip", otherwise(default)
+	// they're replicated as the other repositories
+	EmptyRepositories string `json:"empty_repositories"`
+	// The count of the repositories sampled by the dry run to estimate the resources, tasks
+	// and bytes of the full run, e.g. for the huge registries whose full dry run is expensive.
+	// The first N repositories matching the filters are sampled unless "DryRunRandomSample"
+	// is set. All the repositories are previewed if <= 0
+	DryRunSampleSize   int  `json:"dry_run_sample_size"`
+	DryRunRandomSample bool `json:"dry_run_random_sample"`
+	// Record the decisions made by the filters for every resource and tag, they're logged
+	// by the executions and included in the plans. It's off by default for performance
+	TraceFilters bool `json:"trace_filters"`
+	// The seconds after which the task is timed out, the timed out task is requeued
+	// to the next execution rather than failed. No deadline if <= 0
+	TaskDeadline int `json:"task_deadline"`
+	// The max times one resource is requeued because of timeout or rate limit, the
+	// default one(3) is used if it's <= 0
+	MaxTimeoutRequeues int `json:"max_timeout_requeues"`
+	// Mark the tasks rate limited by the registries as skipped and requeue them to the
+	// next execution rather than failing them, as the rate limit is transient
+	RateLimitAsSkip bool `json:"rate_limit_as_skip"`
+	// Keep the resources whose visibility is unknown(e.g. the adapter cannot supply it)
+	// when applying the visibility filter, they are dropped by default
+	IncludeUnknownVisibility bool `json:"include_unknown_visibility"`
+	// Keep the resources whose pull count is unknown(e.g. the adapter cannot supply it)
+	// when applying the pull count filter, they are dropped by default
+	IncludeUnknownPullCount bool `json:"include_unknown_pull_count"`
+	// The seconds after which the tags on the destination registry expire, the expired
+	// tags under the destination repositories of the policy are deleted even if they're
+	// still present at the source, e.g. for the cache-style mirror. No TTL if <= 0
+	DestinationTagTTL int `json:"destination_tag_ttl"`
+	// Delete the tags that exist under the destination repositories but are removed from the
+	// source ones. Only the tags matching the filters of the policy are deleted and only the
+	// executions fetching the resources from the source registry detect the removed tags
+	PropagateTagDeletion bool `json:"propagate_tag_deletion"`
+	// Delete the signature, attestation and SBOM tags of cosign("sha256-<hex>.sig", etc.) under
+	// the destination repositories whose subject images no longer exist after the deletions of
+	// the execution, they are left as the orphans by default
+	CleanupOrphanedSignatures bool `json:"cleanup_orphaned_signatures"`
+	// How to handle the new execution when the previous execution of the policy is
+	// still running: "allow"(default), "skip" or "queue"
+	ConcurrentExecution string `json:"concurrent_execution"`
+	// The log level of the stage logging of the executions of the policy: "debug", "info",
+	// "warning", etc. It overrides the global log level for the executions of the policy only
+	LogLevel string `json:"log_level"`
 	// Operations
 	Enabled      bool      `json:"enabled"`
 	CreationTime time.Time `json:"creation_time"`
 	UpdateTime   time.Time `json:"update_time"`
 }
 
+// NamespaceMapping maps the source repositories matching "From" to the destination ones
+// specified by "To". The "*" can only be the last character of both patterns, it matches
+// the rest of the repository name which is appended to "To". Without the "*", the pattern
+// matches the whole repository name
+type NamespaceMapping struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ProjectSettings holds the settings applied to the destination projects when creating them
+type ProjectSettings struct {
+	// The storage quota in bytes, -1 means unlimited. The default one of
+	// the destination is used if it's nil
+	StorageLimit *int64 `json:"storage_limit,omitempty"`
+	// The CVE IDs allowed in the project, the system allowlist is used if it's empty
+	CVEAllowlist []string `json:"cve_allowlist,omitempty"`
+	// Scan the images automatically on push, the default one of the destination is used if it's nil
+	AutoScan *bool `json:"auto_scan,omitempty"`
+}
+
 // Valid the policy
 func (p *Policy) Valid(v *validation.Validation) {
 	if len(p.Name) == 0 {
@@ -88,19 +374,45 @@ func (p *Policy) Valid(v *validation.Validation) {
 
 	// valid the filters
 	for _, filter := range p.Filters {
+		switch filter.Scope {
+		case "", ResourceTypeImage, ResourceTypeChart:
+		default:
+			v.SetError("filters", fmt.Sprintf("invalid filter scope: %s", filter.Scope))
+		}
+		if len(filter.Mode) > 0 && filter.Type != FilterTypeName && filter.Type != FilterTypeTag {
+			v.SetError("filters", "only the name and tag filters support the mode")
+		}
+		if filter.Mode == FilterModeSemver && filter.Type != FilterTypeTag {
+			v.SetError("filters", "only the tag filters support the semver mode")
+		}
+		switch filter.Decoration {
+		case "", FilterDecorationMatches:
+		case FilterDecorationExcludes:
+			if filter.Type != FilterTypeName && filter.Type != FilterTypeTag {
+				v.SetError("filters", "only the name and tag filters support the exclusion")
+			}
+		default:
+			v.SetError("filters", fmt.Sprintf("invalid filter decoration: %s", filter.Decoration))
+		}
 		switch filter.Type {
-		case FilterTypeResource, FilterTypeName, FilterTypeTag:
-			value, ok := filter.Value.(string)
-			if !ok {
+		case FilterTypeResource:
+			rt, err := filter.GetResourceType()
+			if err != nil {
 				v.SetError("filters", "the type of filter value isn't string")
 				break
 			}
-			if filter.Type == FilterTypeResource {
-				rt := ResourceType(value)
-				if !(rt == ResourceTypeImage || rt == ResourceTypeChart) {
-					v.SetError("filters", fmt.Sprintf("invalid resource filter: %s", value))
-					break
-				}
+			if !(rt == ResourceTypeImage || rt == ResourceTypeChart) {
+				v.SetError("filters", fmt.Sprintf("invalid resource filter: %s", rt))
+				break
+			}
+		case FilterTypeName, FilterTypeTag:
+			if _, ok := filter.Value.(string); !ok {
+				v.SetError("filters", "the type of filter value isn't string")
+				break
+			}
+			if _, err := filter.GetMatcher(); err != nil {
+				v.SetError("filters", err.Error())
+				break
 			}
 		case FilterTypeLabel:
 			labels, ok := filter.Value.([]interface{})
@@ -115,12 +427,250 @@ func (p *Policy) Valid(v *validation.Validation) {
 					break
 				}
 			}
+		case FilterTypeLatestPatch:
+			if _, ok := filter.Value.(bool); !ok {
+				v.SetError("filters", "the type of latest patch filter value isn't bool")
+			}
+		case FilterTypeModified:
+			if _, ok := filter.Value.(bool); !ok {
+				v.SetError("filters", "the type of modified filter value isn't bool")
+			}
+		case FilterTypeMinAge:
+			if age, err := filter.GetMinAge(); err != nil || age < 0 {
+				v.SetError("filters", "the min age filter value isn't a non-negative number")
+			}
+		case FilterTypeMaxInactivity:
+			if filter.Scope == ResourceTypeChart {
+				v.SetError("filters", "the max inactivity filter only applies to the images")
+			}
+			if inactivity, err := filter.GetMaxInactivity(); err != nil || inactivity <= 0 {
+				v.SetError("filters", "the max inactivity filter value isn't a positive number")
+			}
+		case FilterTypeVisibility:
+			if value, _ := filter.Value.(string); value != VisibilityPublic && value != VisibilityPrivate {
+				v.SetError("filters", fmt.Sprintf("the visibility filter value isn't %s or %s",
+					VisibilityPublic, VisibilityPrivate))
+			}
+		case FilterTypePullCount:
+			if count, err := filter.GetPullCount(); err != nil || count < 0 {
+				v.SetError("filters", "the pull count filter value isn't a non-negative number")
+			}
+		case FilterTypeLatestTags:
+			latest, err := filter.GetLatestTags()
+			if err != nil {
+				v.SetError("filters", err.Error())
+				break
+			}
+			if latest.Count <= 0 {
+				v.SetError("filters", "the count of latest tags filter isn't a positive number")
+			}
+			switch latest.OrderBy {
+			case "", LatestTagsOrderByPushTime, LatestTagsOrderBySemver:
+			default:
+				v.SetError("filters", fmt.Sprintf("invalid order of latest tags filter: %s", latest.OrderBy))
+			}
+		case FilterTypePlatform:
+			if filter.Scope == ResourceTypeChart {
+				v.SetError("filters", "the platform filter only applies to the images")
+			}
+			platforms, err := filter.GetPlatforms()
+			if err != nil {
+				v.SetError("filters", err.Error())
+				break
+			}
+			if len(platforms) == 0 {
+				v.SetError("filters", "no platform is specified in the platform filter")
+			}
+		case FilterTypeDigest:
+			if filter.Scope == ResourceTypeChart {
+				v.SetError("filters", "the digest filter only applies to the images")
+			}
+			digests, err := filter.GetDigests()
+			if err != nil {
+				v.SetError("filters", err.Error())
+				break
+			}
+			if len(digests) == 0 {
+				v.SetError("filters", "no digest is specified in the digest filter")
+			}
 		default:
 			v.SetError("filters", "invalid filter type")
 			break
 		}
 	}
 
+	switch p.ExecutionMode {
+	case "", ExecutionModeParallel, ExecutionModeOrdered:
+	default:
+		v.SetError("execution_mode", "invalid execution mode")
+	}
+
+	// valid tag order
+	switch p.TagOrder {
+	case "", TagOrderOldestFirst, TagOrderNewestFirst:
+	default:
+		v.SetError("tag_order", "invalid tag order")
+	}
+
+	// valid tag normalization
+	switch p.TagNormalization {
+	case "", TagNormalizationLowercase:
+	default:
+		v.SetError("tag_normalization", "invalid tag normalization")
+	}
+
+	// valid the media type translation
+	switch p.MediaTypeTranslation {
+	case "", MediaTypeTranslationDocker, MediaTypeTranslationOCI:
+	default:
+		v.SetError("media_type_translation", "invalid media type translation")
+	}
+
+	// valid the handling of the empty repositories
+	switch p.EmptyRepositories {
+	case "", EmptyRepositoriesInclude, EmptyRepositoriesSkip:
+	default:
+		v.SetError("empty_repositories", "invalid handling of the empty repositories")
+	}
+
+	// valid the signing failure policy
+	switch p.SigningFailure {
+	case "", SigningFailureFail, SigningFailureWarn:
+	default:
+		v.SetError("signing_failure", "invalid signing failure policy")
+	}
+
+	// valid the log level
+	if len(p.LogLevel) > 0 {
+		if _, err := log.ParseLevel(p.LogLevel); err != nil {
+			v.SetError("log_level", "invalid log level")
+		}
+	}
+
+	// valid the concurrent execution policy
+	switch p.ConcurrentExecution {
+	case "", ConcurrentExecutionAllow, ConcurrentExecutionSkip, ConcurrentExecutionQueue:
+	default:
+		v.SetError("concurrent_execution", "invalid concurrent execution policy")
+	}
+
+	// the deletion of the source resources made by moving them would
+	// be replicated to the destination registry
+	if p.Move && p.Deletion {
+		v.SetError("move", "cannot move the resources when the deletion is replicated")
+	}
+
+	// the tags moved from the source would be taken as removed and deleted from the destination
+	if p.Move && p.PropagateTagDeletion {
+		v.SetError("move", "cannot move the resources when the deletion of tags is propagated")
+	}
+
+	if p.MaxManifestSize < 0 {
+		v.SetError("max_manifest_size", "cannot be negative")
+	}
+
+	if p.TagConcurrency < 0 {
+		v.SetError("tag_concurrency", "cannot be negative")
+	}
+
+	if p.TaskDeadline < 0 {
+		v.SetError("task_deadline", "cannot be negative")
+	}
+
+	if p.MaxInFlightTasks < 0 {
+		v.SetError("max_in_flight_tasks", "cannot be negative")
+	}
+
+	if p.AdapterCreationMaxAttempts < 0 {
+		v.SetError("adapter_creation_max_attempts", "cannot be negative")
+	}
+
+	if p.AdapterCreationBaseDelay < 0 {
+		v.SetError("adapter_creation_base_delay", "cannot be negative")
+	}
+
+	if p.FetchTimeout < 0 {
+		v.SetError("fetch_timeout", "cannot be negative")
+	}
+
+	if p.MaxResources < 0 {
+		v.SetError("max_resources", "cannot be negative")
+	}
+
+	if p.BlobIdleTimeout < 0 {
+		v.SetError("blob_idle_timeout", "cannot be negative")
+	}
+
+	if p.DestinationTagTTL < 0 {
+		v.SetError("destination_tag_ttl", "cannot be negative")
+	}
+
+	if p.BlobBufferSize < 0 {
+		v.SetError("blob_buffer_size", "cannot be negative")
+	}
+
+	if p.ManifestPushRetries < 0 {
+		v.SetError("manifest_push_retries", "cannot be negative")
+	}
+
+	if p.MaxDestinationBlobUploads < 0 {
+		v.SetError("max_destination_blob_uploads", "cannot be negative")
+	}
+
+	if p.BandwidthLimit < 0 {
+		v.SetError("bandwidth_limit", "cannot be negative")
+	}
+
+	if p.DryRunSampleSize < 0 {
+		v.SetError("dry_run_sample_size", "cannot be negative")
+	}
+
+	// valid the namespaces of the credential
+	for _, namespace := range p.CredentialNamespaces {
+		if len(namespace) == 0 || strings.Contains(namespace, "/") {
+			v.SetError("credential_namespaces", fmt.Sprintf("invalid namespace: %q", namespace))
+			break
+		}
+	}
+
+	// valid the namespace mappings
+	if len(p.NamespaceMappings) > 0 && len(p.DestNamespace) > 0 {
+		v.SetError("namespace_mappings", "cannot be used together with the destination namespace")
+	}
+	for _, mapping := range p.NamespaceMappings {
+		if mapping == nil || len(mapping.From) == 0 || len(mapping.To) == 0 {
+			v.SetError("namespace_mappings", "the patterns cannot be empty")
+			break
+		}
+		if strings.Contains(strings.TrimSuffix(mapping.From, "*"), "*") ||
+			strings.Contains(strings.TrimSuffix(mapping.To, "*"), "*") ||
+			strings.HasSuffix(mapping.From, "*") != strings.HasSuffix(mapping.To, "*") {
+			v.SetError("namespace_mappings", fmt.Sprintf("invalid mapping from %s to %s", mapping.From, mapping.To))
+			break
+		}
+	}
+
+	// valid the settings of the destination projects
+	if settings := p.DestProjectSettings; settings != nil {
+		if settings.StorageLimit != nil && *settings.StorageLimit < -1 {
+			v.SetError("dest_project_settings", "the storage limit cannot be less than -1")
+		}
+		for _, cve := range settings.CVEAllowlist {
+			if len(cve) == 0 {
+				v.SetError("dest_project_settings", "the CVE ID in the allowlist cannot be empty")
+				break
+			}
+		}
+	}
+
+	// valid pre-copy webhook
+	if len(p.PreCopyWebhookURL) > 0 {
+		u, err := url.Parse(p.PreCopyWebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+			v.SetError("pre_copy_webhook_url", "invalid webhook URL")
+		}
+	}
+
 	// valid trigger
 	if p.Trigger != nil {
 		switch p.Trigger.Type {
@@ -140,13 +690,261 @@ func (p *Policy) Valid(v *validation.Validation) {
 	}
 }
 
+// GetOverride returns whether to override the resources in the destination namespace,
+// the override setting of the namespace is used if it's specified
+func (p *Policy) GetOverride(namespace string) bool {
+	if override, exist := p.NamespaceOverrides[namespace]; exist {
+		return override
+	}
+	return p.Override
+}
+
 // FilterType represents the type info of the filter.
 type FilterType string
 
+// FilterMode represents how the pattern of the name and tag filters is matched
+type FilterMode string
+
 // Filter holds the info of the filter
 type Filter struct {
 	Type  FilterType  `json:"type"`
 	Value interface{} `json:"value"`
+	// The resource type that the filter applies to, the filter
+	// applies to all types of resources if it's empty
+	Scope ResourceType `json:"scope,omitempty"`
+	// The matching mode of the name and tag filters, the glob
+	// patterns are used if it's empty
+	Mode FilterMode `json:"mode,omitempty"`
+	// The decoration of the name and tag filters: "matches"(default) keeps
+	// the matched resources and tags, "excludes" drops them
+	Decoration string `json:"decoration,omitempty"`
+}
+
+// IsExclusion returns whether the filter drops the matched resources and tags
+func (f *Filter) IsExclusion() bool {
+	return f.Decoration == FilterDecorationExcludes
+}
+
+// IsRegex returns whether the pattern of the filter is a regular expression
+func (f *Filter) IsRegex() bool {
+	return f.Mode == FilterModeRegex
+}
+
+// IsGlob returns whether the pattern of the filter is a glob
+func (f *Filter) IsGlob() bool {
+	return f.Mode == "" || f.Mode == FilterModeGlob
+}
+
+// GetMatcher returns the matcher of the name and tag filters according to the mode,
+// the error is returned if the value isn't a string or isn't a valid regular expression
+// or semver range
+func (f *Filter) GetMatcher() (util.Matcher, error) {
+	pattern, ok := f.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a valid string", f.Value)
+	}
+	switch f.Mode {
+	case "", FilterModeGlob:
+		return util.NewGlobMatcher(pattern), nil
+	case FilterModeRegex:
+		return util.NewRegexMatcher(pattern)
+	case FilterModeSemver:
+		return util.NewSemverMatcher(pattern)
+	default:
+		return nil, fmt.Errorf("unsupported filter mode: %s", f.Mode)
+	}
+}
+
+// AppliesTo returns whether the filter applies to the resource type
+func (f *Filter) AppliesTo(resourceType ResourceType) bool {
+	return len(f.Scope) == 0 || f.Scope == resourceType
+}
+
+// GetResourceType returns the value of the resource type filter, both
+// the string and ResourceType values are accepted
+func (f *Filter) GetResourceType() (ResourceType, error) {
+	switch value := f.Value.(type) {
+	case ResourceType:
+		return value, nil
+	case string:
+		return ResourceType(value), nil
+	default:
+		return "", fmt.Errorf("%v is not a valid resource type", f.Value)
+	}
+}
+
+// GetMinAge returns the value of the min age filter, the value is the seconds
+// and both the integer and float values(got from JSON) are accepted
+func (f *Filter) GetMinAge() (time.Duration, error) {
+	age, ok := seconds(f.Value)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a valid min age", f.Value)
+	}
+	return age, nil
+}
+
+// GetMaxInactivity returns the value of the max inactivity filter, the value is
+// the seconds and both the integer and float values(got from JSON) are accepted
+func (f *Filter) GetMaxInactivity() (time.Duration, error) {
+	inactivity, ok := seconds(f.Value)
+	if !ok {
+		return 0, fmt.Errorf("%v is not a valid max inactivity", f.Value)
+	}
+	return inactivity, nil
+}
+
+// convert the seconds of the integer or float value to the duration
+func seconds(value interface{}) (time.Duration, bool) {
+	switch v := value.(type) {
+	case int:
+		return time.Duration(v) * time.Second, true
+	case int64:
+		return time.Duration(v) * time.Second, true
+	case float64:
+		return time.Duration(v * float64(time.Second)), true
+	default:
+		return 0, false
+	}
+}
+
+// GetPullCount returns the value of the pull count filter, both the integer
+// and float values(got from JSON) are accepted
+func (f *Filter) GetPullCount() (int64, error) {
+	switch value := f.Value.(type) {
+	case int:
+		return int64(value), nil
+	case int64:
+		return value, nil
+	case float64:
+		return int64(value), nil
+	default:
+		return 0, fmt.Errorf("%v is not a valid pull count", f.Value)
+	}
+}
+
+// LatestTags is the value of the latest tags filter
+type LatestTags struct {
+	// The count of tags kept for each resource
+	Count int `json:"count"`
+	// The key to order the tags: "push_time"(default) or "semver". The tags are
+	// ordered by semver if the source registry cannot provide the push time
+	OrderBy string `json:"order_by,omitempty"`
+}
+
+// GetLatestTags returns the value of the latest tags filter, both the
+// "LatestTags" and the map(got from JSON) are accepted
+func (f *Filter) GetLatestTags() (*LatestTags, error) {
+	switch value := f.Value.(type) {
+	case *LatestTags:
+		return value, nil
+	case LatestTags:
+		return &value, nil
+	case map[string]interface{}:
+		latest := &LatestTags{}
+		switch count := value["count"].(type) {
+		case int:
+			latest.Count = count
+		case int64:
+			latest.Count = int(count)
+		case float64:
+			latest.Count = int(count)
+		default:
+			return nil, fmt.Errorf("%v is not a valid count of latest tags", value["count"])
+		}
+		if orderBy, exist := value["order_by"]; exist {
+			str, ok := orderBy.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a valid order of latest tags", orderBy)
+			}
+			latest.OrderBy = str
+		}
+		return latest, nil
+	default:
+		return nil, fmt.Errorf("%v is not a valid latest tags filter value", f.Value)
+	}
+}
+
+// GetPlatforms returns the platforms("os/arch" or "os/arch/variant") of the platform filter
+// in lower case, both the string slice and the interface slice(got from JSON) are accepted
+func (f *Filter) GetPlatforms() ([]string, error) {
+	var values []string
+	switch value := f.Value.(type) {
+	case []string:
+		values = value
+	case []interface{}:
+		for _, v := range value {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a valid string", v)
+			}
+			values = append(values, str)
+		}
+	default:
+		return nil, fmt.Errorf("%v is not a valid platform list", f.Value)
+	}
+	platforms := []string{}
+	for _, value := range values {
+		components := strings.Split(value, "/")
+		if len(components) < 2 || len(components) > 3 {
+			return nil, fmt.Errorf("%s is not a valid platform, it should be os/arch or os/arch/variant", value)
+		}
+		for _, component := range components {
+			if len(component) == 0 {
+				return nil, fmt.Errorf("%s is not a valid platform, it should be os/arch or os/arch/variant", value)
+			}
+		}
+		platforms = append(platforms, strings.ToLower(value))
+	}
+	return platforms, nil
+}
+
+// GetDigests returns the digests of the digest filter, the single string, the string
+// slice and the interface slice(got from JSON) are accepted
+func (f *Filter) GetDigests() ([]string, error) {
+	var values []string
+	switch value := f.Value.(type) {
+	case string:
+		values = []string{value}
+	case []string:
+		values = value
+	case []interface{}:
+		for _, v := range value {
+			str, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a valid string", v)
+			}
+			values = append(values, str)
+		}
+	default:
+		return nil, fmt.Errorf("%v is not a valid digest list", f.Value)
+	}
+	for _, value := range values {
+		if _, err := digest.Parse(value); err != nil {
+			return nil, fmt.Errorf("%s is not a valid digest: %v", value, err)
+		}
+	}
+	return values, nil
+}
+
+// GetLabels returns the value of the label filter, both the string slice and
+// the interface slice(got from JSON) are accepted
+func (f *Filter) GetLabels() ([]string, error) {
+	switch value := f.Value.(type) {
+	case []string:
+		return value, nil
+	case []interface{}:
+		labels := []string{}
+		for _, v := range value {
+			label, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("%v is not a valid string", v)
+			}
+			labels = append(labels, label)
+		}
+		return labels, nil
+	default:
+		return nil, fmt.Errorf("%v is not a valid label list", f.Value)
+	}
 }
 
 // DoFilter filter the filterables
@@ -162,12 +960,16 @@ func (f *Filter) DoFilter(filterables interface{}) error {
 	case FilterTypeTag:
 		ft = filter.NewVTagNameFilter(f.Value.(string))
 	case FilterTypeLabel:
-		labels, ok := f.Value.([]string)
-		if ok {
+		labels, err := f.GetLabels()
+		if err == nil {
 			ft = filter.NewVTagLabelFilter(labels)
 		}
 	case FilterTypeResource:
-		ft = filter.NewResourceTypeFilter(f.Value.(string))
+		rt, err := f.GetResourceType()
+		if err != nil {
+			return err
+		}
+		ft = filter.NewResourceTypeFilter(string(rt))
 	default:
 		return fmt.Errorf("unsupported filter type: %s", f.Type)
 	}
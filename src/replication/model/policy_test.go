@@ -17,6 +17,7 @@ package model
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/astaxie/beego/validation"
 	"github.com/stretchr/testify/assert"
@@ -106,7 +107,1002 @@ func TestValidOfPolicy(t *testing.T) {
 					},
 					{
 						Type:  FilterTypeTag,
-						Value: "",
+						Value: 1,
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid regular expression of name filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeName,
+						Value: "library/(hello",
+						Mode:  FilterModeRegex,
+					},
+				},
+			},
+			pass: false,
+		},
+		// semver mode for the name filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeName,
+						Value: ">=1.4.0",
+						Mode:  FilterModeSemver,
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid semver range
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeTag,
+						Value: ">=1.x.y",
+						Mode:  FilterModeSemver,
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid filter mode
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeTag,
+						Value: "v*",
+						Mode:  "wildcard",
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid filter decoration
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:       FilterTypeName,
+						Value:      "library/**",
+						Decoration: "ignores",
+					},
+				},
+			},
+			pass: false,
+		},
+		// exclusion of resource filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:       FilterTypeResource,
+						Value:      ResourceTypeImage,
+						Decoration: FilterDecorationExcludes,
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid latest patch filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeLatestPatch,
+						Value: "true",
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid tag order
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				TagOrder: "random",
+			},
+			pass: false,
+		},
+		// move with the deletion replicated
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Move:     true,
+				Deletion: true,
+			},
+			pass: false,
+		},
+		// negative tag concurrency
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				TagConcurrency: -1,
+			},
+			pass: false,
+		},
+		// negative max attempts of the adapter creation
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				AdapterCreationMaxAttempts: -1,
+			},
+			pass: false,
+		},
+		// negative base delay of the adapter creation
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				AdapterCreationBaseDelay: -1,
+			},
+			pass: false,
+		},
+		// negative manifest push retries
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				ManifestPushRetries: -1,
+			},
+			pass: false,
+		},
+		// negative max in-flight tasks
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				MaxInFlightTasks: -1,
+			},
+			pass: false,
+		},
+		// negative task deadline
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				TaskDeadline: -1,
+			},
+			pass: false,
+		},
+		// negative fetch timeout
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				FetchTimeout: -1,
+			},
+			pass: false,
+		},
+		// negative max resources
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				MaxResources: -1,
+			},
+			pass: false,
+		},
+		// negative blob idle timeout
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				BlobIdleTimeout: -1,
+			},
+			pass: false,
+		},
+		// negative destination tag TTL
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				DestinationTagTTL: -1,
+			},
+			pass: false,
+		},
+		// negative blob buffer size
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				BlobBufferSize: -1,
+			},
+			pass: false,
+		},
+		// invalid filter scope
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeName,
+						Value: "library/**",
+						Scope: "helm",
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid min age filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeMinAge,
+						Value: -1,
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid max inactivity filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeMaxInactivity,
+						Value: 0,
+					},
+				},
+			},
+			pass: false,
+		},
+		// max inactivity filter for charts
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeMaxInactivity,
+						Scope: ResourceTypeChart,
+						Value: 3600,
+					},
+				},
+			},
+			pass: false,
+		},
+		// valid max inactivity filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeMaxInactivity,
+						Value: float64(86400),
+					},
+				},
+			},
+			pass: true,
+		},
+		// invalid visibility filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeVisibility,
+						Value: "internal",
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid pull count filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypePullCount,
+						Value: "10",
+					},
+				},
+			},
+			pass: false,
+		},
+		// negative pull count filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypePullCount,
+						Value: float64(-1),
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid count of latest tags filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type: FilterTypeLatestTags,
+						Value: map[string]interface{}{
+							"count": float64(0),
+						},
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid order of latest tags filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type: FilterTypeLatestTags,
+						Value: map[string]interface{}{
+							"count":    float64(5),
+							"order_by": "name",
+						},
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid storage limit of destination projects
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				DestProjectSettings: &ProjectSettings{
+					StorageLimit: func() *int64 { limit := int64(-2); return &limit }(),
+				},
+			},
+			pass: false,
+		},
+		// valid namespace mappings
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				NamespaceMappings: []*NamespaceMapping{
+					{From: "library/*", To: "mirror/library/*"},
+					{From: "team-a/app", To: "a/app"},
+				},
+			},
+			pass: true,
+		},
+		// namespace mappings with the destination namespace
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				DestNamespace: "mirror",
+				NamespaceMappings: []*NamespaceMapping{
+					{From: "library/*", To: "mirror/library/*"},
+				},
+			},
+			pass: false,
+		},
+		// namespace mapping with the wildcard in the middle
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				NamespaceMappings: []*NamespaceMapping{
+					{From: "library/*/nginx", To: "mirror/*"},
+				},
+			},
+			pass: false,
+		},
+		// namespace mapping with the wildcard in one pattern only
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				NamespaceMappings: []*NamespaceMapping{
+					{From: "library/*", To: "mirror"},
+				},
+			},
+			pass: false,
+		},
+		// move with the propagation of the tag deletion
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Move:                 true,
+				PropagateTagDeletion: true,
+			},
+			pass: false,
+		},
+		// negative max destination blob uploads
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				MaxDestinationBlobUploads: -1,
+			},
+			pass: false,
+		},
+		// negative dry run sample size
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				DryRunSampleSize: -1,
+			},
+			pass: false,
+		},
+		// invalid namespace of the credential
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 1,
+				},
+				DestRegistry: &Registry{
+					ID: 0,
+				},
+				CredentialNamespaces: []string{"library", "library/hello-world"},
+			},
+			pass: false,
+		},
+		// valid namespaces of the credential
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 1,
+				},
+				DestRegistry: &Registry{
+					ID: 0,
+				},
+				CredentialNamespaces: []string{"library", "harbor"},
+			},
+			pass: true,
+		},
+		// negative bandwidth limit
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				BandwidthLimit: -1,
+			},
+			pass: false,
+		},
+		// valid platform filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypePlatform,
+						Value: []interface{}{"linux/amd64", "linux/arm64"},
+					},
+				},
+			},
+			pass: true,
+		},
+		// invalid platform filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypePlatform,
+						Value: []interface{}{"amd64"},
+					},
+				},
+			},
+			pass: false,
+		},
+		// platform filter scoped to the charts
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypePlatform,
+						Scope: ResourceTypeChart,
+						Value: []interface{}{"linux/amd64"},
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid media type translation
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				MediaTypeTranslation: "schema1",
+			},
+			pass: false,
+		},
+		// valid media type translation
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				MediaTypeTranslation: MediaTypeTranslationDocker,
+			},
+			pass: true,
+		},
+		// valid digest filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeDigest,
+						Value: []interface{}{"sha256:1111111111111111111111111111111111111111111111111111111111111111"},
+					},
+				},
+			},
+			pass: true,
+		},
+		// invalid digest
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeDigest,
+						Value: "sha256:abc",
+					},
+				},
+			},
+			pass: false,
+		},
+		// no digest
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeDigest,
+						Value: []interface{}{},
+					},
+				},
+			},
+			pass: false,
+		},
+		// the digest filter scoped to the charts
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeDigest,
+						Value: "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+						Scope: ResourceTypeChart,
+					},
+				},
+			},
+			pass: false,
+		},
+		// invalid handling of the empty repositories
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				EmptyRepositories: "delete",
+			},
+			pass: false,
+		},
+		// skip the empty repositories
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				EmptyRepositories: EmptyRepositoriesSkip,
+			},
+			pass: true,
+		},
+		// invalid signing failure policy
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Signer:         "cosign",
+				SigningFailure: "ignore",
+			},
+			pass: false,
+		},
+		// invalid concurrent execution policy
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				ConcurrentExecution: "cancel",
+			},
+			pass: false,
+		},
+		// invalid log level
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				LogLevel: "verbose",
+			},
+			pass: false,
+		},
+		// invalid tag normalization
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				TagNormalization: "uppercase",
+			},
+			pass: false,
+		},
+		// invalid execution mode
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				ExecutionMode: "random",
+			},
+			pass: false,
+		},
+		// valid execution mode
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				ExecutionMode: ExecutionModeOrdered,
+			},
+			pass: true,
+		},
+		// invalid pre-copy webhook URL
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				PreCopyWebhookURL: "webhook.local/approve",
+			},
+			pass: false,
+		},
+		// invalid modified filter
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeModified,
+						Value: "true",
 					},
 				},
 			},
@@ -214,6 +1210,25 @@ func TestValidOfPolicy(t *testing.T) {
 			},
 			pass: true,
 		},
+		// pass, the value of resource filter is ResourceType
+		{
+			policy: &Policy{
+				Name: "policy01",
+				SrcRegistry: &Registry{
+					ID: 0,
+				},
+				DestRegistry: &Registry{
+					ID: 1,
+				},
+				Filters: []*Filter{
+					{
+						Type:  FilterTypeResource,
+						Value: ResourceTypeChart,
+					},
+				},
+			},
+			pass: true,
+		},
 	}
 
 	for i, c := range cases {
@@ -223,3 +1238,159 @@ func TestValidOfPolicy(t *testing.T) {
 		assert.Equal(t, c.pass, len(v.Errors) == 0)
 	}
 }
+
+func TestGetResourceType(t *testing.T) {
+	cases := []struct {
+		value        interface{}
+		resourceType ResourceType
+		err          bool
+	}{
+		{"image", ResourceTypeImage, false},
+		{ResourceTypeChart, ResourceTypeChart, false},
+		{1, "", true},
+		{nil, "", true},
+	}
+	for _, c := range cases {
+		filter := &Filter{
+			Type:  FilterTypeResource,
+			Value: c.value,
+		}
+		resourceType, err := filter.GetResourceType()
+		assert.Equal(t, c.err, err != nil)
+		assert.Equal(t, c.resourceType, resourceType)
+	}
+}
+
+func TestGetMinAge(t *testing.T) {
+	cases := []struct {
+		value interface{}
+		age   time.Duration
+		err   bool
+	}{
+		{120, 2 * time.Minute, false},
+		{int64(60), time.Minute, false},
+		{float64(1.5), 1500 * time.Millisecond, false},
+		{"120", 0, true},
+		{nil, 0, true},
+	}
+	for _, c := range cases {
+		filter := &Filter{
+			Type:  FilterTypeMinAge,
+			Value: c.value,
+		}
+		age, err := filter.GetMinAge()
+		assert.Equal(t, c.err, err != nil)
+		assert.Equal(t, c.age, age)
+	}
+}
+
+func TestGetMaxInactivity(t *testing.T) {
+	cases := []struct {
+		value      interface{}
+		inactivity time.Duration
+		err        bool
+	}{
+		{3600, time.Hour, false},
+		{float64(86400), 24 * time.Hour, false},
+		{"3600", 0, true},
+	}
+	for _, c := range cases {
+		filter := &Filter{
+			Type:  FilterTypeMaxInactivity,
+			Value: c.value,
+		}
+		inactivity, err := filter.GetMaxInactivity()
+		assert.Equal(t, c.err, err != nil)
+		assert.Equal(t, c.inactivity, inactivity)
+	}
+}
+
+func TestGetLatestTags(t *testing.T) {
+	cases := []struct {
+		value  interface{}
+		latest *LatestTags
+		err    bool
+	}{
+		{&LatestTags{Count: 5}, &LatestTags{Count: 5}, false},
+		{LatestTags{Count: 5, OrderBy: LatestTagsOrderBySemver}, &LatestTags{Count: 5, OrderBy: LatestTagsOrderBySemver}, false},
+		{map[string]interface{}{"count": float64(3), "order_by": "push_time"}, &LatestTags{Count: 3, OrderBy: LatestTagsOrderByPushTime}, false},
+		{map[string]interface{}{"count": "3"}, nil, true},
+		{map[string]interface{}{"count": 3, "order_by": 1}, nil, true},
+		{5, nil, true},
+	}
+	for _, c := range cases {
+		filter := &Filter{
+			Type:  FilterTypeLatestTags,
+			Value: c.value,
+		}
+		latest, err := filter.GetLatestTags()
+		assert.Equal(t, c.err, err != nil)
+		assert.Equal(t, c.latest, latest)
+	}
+}
+
+func TestGetPlatforms(t *testing.T) {
+	cases := []struct {
+		value     interface{}
+		platforms []string
+		err       bool
+	}{
+		{[]string{"linux/amd64"}, []string{"linux/amd64"}, false},
+		{[]interface{}{"Linux/ARM64", "linux/arm/v7"}, []string{"linux/arm64", "linux/arm/v7"}, false},
+		{[]interface{}{}, []string{}, false},
+		{[]interface{}{1}, nil, true},
+		{[]string{"linux"}, nil, true},
+		{[]string{"linux/"}, nil, true},
+		{[]string{"linux/arm/v7/x"}, nil, true},
+		{"linux/amd64", nil, true},
+	}
+	for _, c := range cases {
+		filter := &Filter{
+			Type:  FilterTypePlatform,
+			Value: c.value,
+		}
+		platforms, err := filter.GetPlatforms()
+		assert.Equal(t, c.err, err != nil)
+		assert.Equal(t, c.platforms, platforms)
+	}
+}
+
+func TestGetLabels(t *testing.T) {
+	cases := []struct {
+		value  interface{}
+		labels []string
+		err    bool
+	}{
+		{[]string{"prod"}, []string{"prod"}, false},
+		{[]interface{}{"prod", "stable"}, []string{"prod", "stable"}, false},
+		{[]interface{}{1}, nil, true},
+		{"prod", nil, true},
+		{nil, nil, true},
+	}
+	for _, c := range cases {
+		filter := &Filter{
+			Type:  FilterTypeLabel,
+			Value: c.value,
+		}
+		labels, err := filter.GetLabels()
+		assert.Equal(t, c.err, err != nil)
+		assert.Equal(t, c.labels, labels)
+	}
+}
+
+func TestGetOverride(t *testing.T) {
+	policy := &Policy{
+		Override: true,
+		NamespaceOverrides: map[string]bool{
+			"release": false,
+			"dev":     true,
+		},
+	}
+	assert.True(t, policy.GetOverride("library"))
+	assert.False(t, policy.GetOverride("release"))
+	assert.True(t, policy.GetOverride("dev"))
+
+	policy.Override = false
+	assert.False(t, policy.GetOverride("library"))
+	assert.True(t, policy.GetOverride("dev"))
+}
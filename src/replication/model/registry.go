@@ -91,8 +91,17 @@ type Registry struct {
 	Credential      *Credential `json:"credential"`
 	Insecure        bool        `json:"insecure"`
 	Status          string      `json:"status"`
-	CreationTime    time.Time   `json:"creation_time"`
-	UpdateTime      time.Time   `json:"update_time"`
+	// ResolveOverride pins the hosts to the specific IPs(host -> IP) when
+	// connecting to the registry, e.g. to bypass the load balancer
+	ResolveOverride map[string]string `json:"resolve_override,omitempty"`
+	// UserAgent is carried by the requests sent to the registry, it is
+	// set by the replication policy and isn't persisted
+	UserAgent string `json:"user_agent,omitempty"`
+	// MaxManifestSize is the max bytes of the manifests pulled from the registry,
+	// it is set by the replication policy and isn't persisted
+	MaxManifestSize int64     `json:"max_manifest_size,omitempty"`
+	CreationTime    time.Time `json:"creation_time"`
+	UpdateTime      time.Time `json:"update_time"`
 }
 
 // RegistryQuery defines the query conditions for listing registries
@@ -20,6 +20,32 @@ const (
 	ResourceTypeChart ResourceType = "chart"
 )
 
+// ExtendedInfoPublic is the key of the "ExtendedInfo" of the resource recording whether
+// its repository is public(bool), it's set by the adapters aware of the visibility
+const ExtendedInfoPublic = "public"
+
+// ExtendedInfoPullCount is the key of the "ExtendedInfo" of the resource recording the
+// pull count of its repository(int64), it's set by the adapters aware of the pull count
+const ExtendedInfoPullCount = "pull_count"
+
+// ExtendedInfoPlatformDigests is the key of the "ExtendedInfo" of the resource recording the
+// digests of the sub-manifests selected by the platform filter for the tags of multi-arch
+// images(tag -> digests), the reduced manifest lists referencing them are copied
+const ExtendedInfoPlatformDigests = "platform_digests"
+
+// TaskCheckInTimedOut is checked in by the replication job when the task exceeds its
+// deadline, the task is marked as timed out and requeued to the next execution
+const TaskCheckInTimedOut = "timed_out"
+
+// TaskCheckInRateLimited is checked in by the replication job when the task is rate limited
+// by the registry and the policy handles it as skip, the task is requeued to the next execution
+const TaskCheckInRateLimited = "rate_limited"
+
+// TaskCheckInFailurePrefix prefixes the category of the error failing the task, it's checked in
+// by the replication job when the task fails, e.g. "failure:auth". The failures of an execution
+// are aggregated by the category
+const TaskCheckInFailurePrefix = "failure:"
+
 // ResourceType represents the type of the resource
 type ResourceType string
 
@@ -34,6 +60,9 @@ type ResourceMetadata struct {
 	Vtags      []string    `json:"v_tags"`
 	// TODO the labels should be put into tag and repository level?
 	Labels []string `json:"labels"`
+	// the digests of the manifests indexed by the tags, populated by the adapters
+	// which get them when fetching the resources. It's needed by the digest filter
+	Digests map[string]string `json:"digests,omitempty"`
 }
 
 // GetResourceName returns the name of the resource
@@ -61,4 +90,94 @@ type Resource struct {
 	Deleted bool `json:"deleted"`
 	// indicate whether the resource can be overridden
 	Override bool `json:"override"`
+	// indicate whether the source resource is deleted after being copied successfully
+	Move bool `json:"move"`
+	// the seconds after which the stalled copy of a blob is aborted, no idle timeout if <= 0
+	BlobIdleTimeout int `json:"blob_idle_timeout"`
+	// the size in bytes of the buffer reading the blobs, the default one is used if <= 0
+	BlobBufferSize int `json:"blob_buffer_size"`
+	// the count of the tags copied concurrently, the tags are copied one by one if <= 1
+	TagConcurrency int `json:"tag_concurrency"`
+	// the count of retries when pushing a manifest gets 5xx, no retry if <= 0
+	ManifestPushRetries int `json:"manifest_push_retries"`
+	// indicate whether the config blob is pushed and checked before copying the layers
+	ConfigBlobFirst bool `json:"config_blob_first"`
+	// the max count of the blobs uploaded concurrently to the destination registry by
+	// all the tasks, no limit if <= 0
+	MaxBlobUploads int `json:"max_blob_uploads"`
+	// the max bandwidth in MB/s shared by the tasks of the execution specified by
+	// "ExecutionID" when copying the blobs, no limit if <= 0
+	BandwidthLimit int   `json:"bandwidth_limit"`
+	ExecutionID    int64 `json:"execution_id,omitempty"`
+	// the seconds after which the task is timed out and requeued to the next execution,
+	// no deadline if <= 0
+	TaskDeadline int `json:"task_deadline"`
+	// indicate whether the task rate limited by the registry is skipped and requeued to
+	// the next execution rather than failed
+	RateLimitAsSkip bool `json:"rate_limit_as_skip"`
+	// the name of the signer signing the copied images and how to handle the signing
+	// failure: "fail" or "warn"(default)
+	Signer         string `json:"signer,omitempty"`
+	SigningFailure string `json:"signing_failure,omitempty"`
+	// the media types translated into when copying the images: "docker" or "oci"
+	MediaTypeTranslation string `json:"media_type_translation,omitempty"`
+	// the settings applied to the destination project when creating it
+	ProjectSettings *ProjectSettings `json:"project_settings,omitempty"`
+	// indicate whether the cosign signatures whose subject images no longer exist are
+	// deleted after deleting the tags under the repository
+	CleanupOrphanedSignatures bool `json:"cleanup_orphaned_signatures,omitempty"`
+}
+
+// IsPublic returns whether the repository of the resource is public and
+// whether the visibility is known
+func (r *Resource) IsPublic() (public bool, known bool) {
+	if r.ExtendedInfo == nil {
+		return false, false
+	}
+	public, known = r.ExtendedInfo[ExtendedInfoPublic].(bool)
+	return public, known
+}
+
+// GetPlatformDigests returns the digests of the sub-manifests selected by the platform
+// filter indexed by the tags, both the typed map and the one got from JSON are accepted
+func (r *Resource) GetPlatformDigests() map[string][]string {
+	if r.ExtendedInfo == nil {
+		return nil
+	}
+	switch value := r.ExtendedInfo[ExtendedInfoPlatformDigests].(type) {
+	case map[string][]string:
+		return value
+	case map[string]interface{}:
+		result := map[string][]string{}
+		for tag, v := range value {
+			list, ok := v.([]interface{})
+			if !ok {
+				continue
+			}
+			for _, d := range list {
+				if digest, ok := d.(string); ok {
+					result[tag] = append(result[tag], digest)
+				}
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// GetPullCount returns the pull count of the repository of the resource and
+// whether the pull count is known
+func (r *Resource) GetPullCount() (count int64, known bool) {
+	if r.ExtendedInfo == nil {
+		return 0, false
+	}
+	switch value := r.ExtendedInfo[ExtendedInfoPullCount].(type) {
+	case int64:
+		return value, true
+	case int:
+		return int64(value), true
+	case float64:
+		return int64(value), true
+	}
+	return 0, false
 }
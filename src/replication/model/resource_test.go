@@ -45,3 +45,67 @@ func TestGetResourceName(t *testing.T) {
 	}
 	assert.Equal(t, "library/hello-world", r.GetResourceName())
 }
+
+func TestIsPublic(t *testing.T) {
+	r := &Resource{}
+	public, known := r.IsPublic()
+	assert.False(t, known)
+	assert.False(t, public)
+
+	r.ExtendedInfo = map[string]interface{}{
+		ExtendedInfoPublic: "true",
+	}
+	_, known = r.IsPublic()
+	assert.False(t, known)
+
+	r.ExtendedInfo[ExtendedInfoPublic] = true
+	public, known = r.IsPublic()
+	assert.True(t, known)
+	assert.True(t, public)
+}
+
+func TestGetPullCount(t *testing.T) {
+	r := &Resource{}
+	_, known := r.GetPullCount()
+	assert.False(t, known)
+
+	r.ExtendedInfo = map[string]interface{}{
+		ExtendedInfoPullCount: "10",
+	}
+	_, known = r.GetPullCount()
+	assert.False(t, known)
+
+	r.ExtendedInfo[ExtendedInfoPullCount] = int64(10)
+	count, known := r.GetPullCount()
+	assert.True(t, known)
+	assert.Equal(t, int64(10), count)
+
+	// got from JSON
+	r.ExtendedInfo[ExtendedInfoPullCount] = float64(20)
+	count, known = r.GetPullCount()
+	assert.True(t, known)
+	assert.Equal(t, int64(20), count)
+}
+
+func TestGetPlatformDigests(t *testing.T) {
+	r := &Resource{}
+	assert.Nil(t, r.GetPlatformDigests())
+
+	r.ExtendedInfo = map[string]interface{}{
+		ExtendedInfoPlatformDigests: map[string][]string{
+			"latest": {"sha256:1", "sha256:2"},
+		},
+	}
+	assert.Equal(t, map[string][]string{
+		"latest": {"sha256:1", "sha256:2"},
+	}, r.GetPlatformDigests())
+
+	// got from JSON
+	r.ExtendedInfo[ExtendedInfoPlatformDigests] = map[string]interface{}{
+		"latest": []interface{}{"sha256:1", "sha256:2"},
+		"v1":     "sha256:3",
+	}
+	assert.Equal(t, map[string][]string{
+		"latest": {"sha256:1", "sha256:2"},
+	}, r.GetPlatformDigests())
+}
@@ -15,7 +15,9 @@
 package operation
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/goharbor/harbor/src/common/job"
@@ -32,12 +34,18 @@ import (
 type Controller interface {
 	// trigger is used to specify what this replication is triggered by
 	StartReplication(policy *model.Policy, resource *model.Resource, trigger model.TriggerType) (int64, error)
+	// StartDryRun previews the replication: the tasks are recorded but not submitted
+	StartDryRun(policy *model.Policy) (int64, error)
 	StopReplication(int64) error
 	ListExecutions(...*models.ExecutionQuery) (int64, []*models.Execution, error)
 	GetExecution(int64) (*models.Execution, error)
+	// UpdateExecution updates the specified properties of the execution
+	UpdateExecution(execution *models.Execution, props ...string) error
 	ListTasks(...*models.TaskQuery) (int64, []*models.Task, error)
 	GetTask(int64) (*models.Task, error)
 	UpdateTaskStatus(id int64, status string, statusCondition ...string) error
+	// UpdateTask updates the specified properties of the task
+	UpdateTask(task *models.Task, props ...string) error
 	GetTaskLog(int64) ([]byte, error)
 }
 
@@ -64,9 +72,19 @@ type controller struct {
 	flowCtl      flow.Controller
 	executionMgr execution.Manager
 	scheduler    scheduler.Scheduler
+	// the functions cancelling the flows of the running executions, indexed by the execution ID
+	cancels sync.Map
 }
 
 func (c *controller) StartReplication(policy *model.Policy, resource *model.Resource, trigger model.TriggerType) (int64, error) {
+	return c.start(policy, resource, trigger, false)
+}
+
+func (c *controller) StartDryRun(policy *model.Policy) (int64, error) {
+	return c.start(policy, nil, model.TriggerTypeManual, true)
+}
+
+func (c *controller) start(policy *model.Policy, resource *model.Resource, trigger model.TriggerType, dryRun bool) (int64, error) {
 	if !policy.Enabled {
 		return 0, fmt.Errorf("the policy %d is disabled", policy.ID)
 	}
@@ -81,12 +99,30 @@ func (c *controller) StartReplication(policy *model.Policy, resource *model.Reso
 	log.Debugf("waiting for the available replicator ...")
 	<-c.replicators
 	log.Debugf("got an available replicator, starting the replication ...")
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancels.Store(id, cancel)
 	go func() {
 		defer func() {
+			c.cancels.Delete(id)
+			cancel()
 			c.replicators <- struct{}{}
 		}()
-		flow := c.createFlow(id, policy, resource)
-		if n, err := c.flowCtl.Start(flow); err != nil {
+		f := c.createFlow(id, policy, resource, dryRun)
+		n, err := c.flowCtl.Start(ctx, f)
+		// the tasks submitted before the cancellation are left as they are
+		if flow.IsCancelled(err) {
+			if e := c.executionMgr.Update(&models.Execution{
+				ID:         id,
+				Status:     models.ExecutionStatusStopped,
+				StatusText: "the execution is stopped during the scheduling",
+				EndTime:    time.Now(),
+			}, "Status", "StatusText", "EndTime"); e != nil {
+				log.Errorf("failed to update the execution %d: %v", id, e)
+			}
+			log.Infof("the execution %d is stopped after %d tasks are scheduled", id, n)
+			return
+		}
+		if err != nil {
 			// only update the execution when got error.
 			// if got no error, it will be updated automatically
 			// when listing the execution records
@@ -106,19 +142,30 @@ func (c *controller) StartReplication(policy *model.Policy, resource *model.Reso
 }
 
 // create different replication flows according to the input parameters
-func (c *controller) createFlow(executionID int64, policy *model.Policy, resource *model.Resource) flow.Flow {
+func (c *controller) createFlow(executionID int64, policy *model.Policy, resource *model.Resource, dryRun bool) flow.Flow {
 	// replicate the deletion operation, so create a deletion flow
 	if resource != nil && resource.Deleted {
+		if dryRun {
+			return flow.NewDryRunDeletionFlow(c.executionMgr, c.scheduler, executionID, policy, resource)
+		}
 		return flow.NewDeletionFlow(c.executionMgr, c.scheduler, executionID, policy, resource)
 	}
 	resources := []*model.Resource{}
 	if resource != nil {
 		resources = append(resources, resource)
 	}
+	if dryRun {
+		return flow.NewDryRunCopyFlow(c.executionMgr, c.scheduler, executionID, policy, resources...)
+	}
 	return flow.NewCopyFlow(c.executionMgr, c.scheduler, executionID, policy, resources...)
 }
 
 func (c *controller) StopReplication(executionID int64) error {
+	// stop submitting the tasks if the flow of the execution is still running
+	if cancel, ok := c.cancels.Load(executionID); ok {
+		cancel.(context.CancelFunc)()
+		log.Debugf("the flow of the execution %d is cancelled", executionID)
+	}
 	_, tasks, err := c.ListTasks(&models.TaskQuery{
 		ExecutionID: executionID,
 	})
@@ -153,6 +200,11 @@ func (c *controller) StopReplication(executionID int64) error {
 			log.Debugf("the task %d(job ID: %s) isn't running, its status is %s, skip", task.ID, task.JobID, task.Status)
 			continue
 		}
+		// the task isn't submitted yet, it's stopped by the cancelled flow
+		if len(task.JobID) == 0 {
+			log.Debugf("the task %d isn't submitted yet, skip", task.ID)
+			continue
+		}
 		if err = c.scheduler.Stop(task.JobID); err != nil {
 			return err
 		}
@@ -168,7 +220,15 @@ func isTaskRunning(task *models.Task) bool {
 	switch task.Status {
 	case models.TaskStatusSucceed,
 		models.TaskStatusStopped,
-		models.TaskStatusFailed:
+		models.TaskStatusFailed,
+		models.TaskStatusDeferred,
+		models.TaskStatusDenied,
+		models.TaskStatusOverQuota,
+		models.TaskStatusTimedOut,
+		models.TaskStatusRateLimited,
+		models.TaskStatusSkipped,
+		models.TaskStatusWouldCopy,
+		models.TaskStatusWouldDelete:
 		return false
 	}
 	return true
@@ -189,6 +249,12 @@ func (c *controller) GetTask(id int64) (*models.Task, error) {
 func (c *controller) UpdateTaskStatus(id int64, status string, statusCondition ...string) error {
 	return c.executionMgr.UpdateTaskStatus(id, status, statusCondition...)
 }
+func (c *controller) UpdateExecution(execution *models.Execution, props ...string) error {
+	return c.executionMgr.Update(execution, props...)
+}
+func (c *controller) UpdateTask(task *models.Task, props ...string) error {
+	return c.executionMgr.UpdateTask(task, props...)
+}
 func (c *controller) GetTaskLog(taskID int64) ([]byte, error) {
 	return c.executionMgr.GetTaskLog(taskID)
 }
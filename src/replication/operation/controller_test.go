@@ -15,6 +15,7 @@
 package operation
 
 import (
+	"context"
 	"io"
 	"os"
 	"testing"
@@ -24,6 +25,7 @@ import (
 	"github.com/goharbor/harbor/src/replication/config"
 	"github.com/goharbor/harbor/src/replication/dao/models"
 	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
 	"github.com/goharbor/harbor/src/replication/operation/flow"
 	"github.com/goharbor/harbor/src/replication/operation/scheduler"
 	"github.com/stretchr/testify/assert"
@@ -86,6 +88,9 @@ func (f *fakedExecutionManager) RemoveAllTasks(int64) error {
 func (f *fakedExecutionManager) GetTaskLog(int64) ([]byte, error) {
 	return []byte("message"), nil
 }
+func (f *fakedExecutionManager) SubscribeTaskStatus(int64) (<-chan *execution.TaskStatusTransition, func()) {
+	return nil, func() {}
+}
 
 type fakedScheduler struct{}
 
@@ -305,6 +310,66 @@ func TestStopReplication(t *testing.T) {
 	require.Nil(t, err)
 }
 
+// blocks until the flow is cancelled
+type fakedBlockingFlowController struct {
+	started chan struct{}
+}
+
+func (f *fakedBlockingFlowController) Start(ctx context.Context, fl flow.Flow) (int, error) {
+	close(f.started)
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+// records the updates of the execution
+type fakedUpdateRecordingExecutionManager struct {
+	fakedExecutionManager
+	updated chan *models.Execution
+}
+
+func (f *fakedUpdateRecordingExecutionManager) Update(execution *models.Execution, props ...string) error {
+	f.updated <- execution
+	return nil
+}
+
+func TestStopReplicationDuringScheduling(t *testing.T) {
+	mgr := &fakedUpdateRecordingExecutionManager{
+		updated: make(chan *models.Execution, 1),
+	}
+	flowCtl := &fakedBlockingFlowController{
+		started: make(chan struct{}),
+	}
+	c := &controller{
+		replicators:  make(chan struct{}, 1),
+		executionMgr: mgr,
+		scheduler:    &fakedScheduler{},
+		flowCtl:      flowCtl,
+	}
+	c.replicators <- struct{}{}
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		Enabled: true,
+	}
+	id, err := c.StartReplication(policy, nil, model.TriggerTypeManual)
+	require.Nil(t, err)
+	<-flowCtl.started
+
+	err = c.StopReplication(id)
+	require.Nil(t, err)
+	execution := <-mgr.updated
+	assert.Equal(t, id, execution.ID)
+	assert.Equal(t, models.ExecutionStatusStopped, execution.Status)
+	// the replicator is released once the flow returns
+	<-c.replicators
+	_, ok := c.cancels.Load(id)
+	assert.False(t, ok)
+}
+
 func TestListExecutions(t *testing.T) {
 	n, executions, err := ctl.ListExecutions()
 	require.Nil(t, err)
@@ -48,7 +48,7 @@ type Manager interface {
 	// you want to update the status, use "UpdateTaskStatus" instead
 	UpdateTask(task *models.Task, props ...string) error
 	// UpdateTaskStatus only updates the task status. If "statusCondition"
-	// presents, only the tasks whose status equal to "statusCondition"
+	// presents, only the tasks whose status equal to one of "statusCondition"
 	// will be updated
 	UpdateTaskStatus(taskID int64, status string, statusCondition ...string) error
 	// Remove one task specified by task ID
@@ -57,15 +57,22 @@ type Manager interface {
 	RemoveAllTasks(int64) error
 	// Get the log of one specific task
 	GetTaskLog(int64) ([]byte, error)
+	// SubscribeTaskStatus subscribes the status transitions of the task specified
+	// by the ID, all tasks are subscribed if the ID is 0. The returned function
+	// must be called to cancel the subscription and close the channel
+	SubscribeTaskStatus(taskID int64) (<-chan *TaskStatusTransition, func())
 }
 
 // DefaultManager ..
 type DefaultManager struct {
+	publisher *publisher
 }
 
 // NewDefaultManager ...
 func NewDefaultManager() Manager {
-	return &DefaultManager{}
+	return &DefaultManager{
+		publisher: newPublisher(),
+	}
 }
 
 // Create a new execution
@@ -116,7 +123,12 @@ func (dm *DefaultManager) RemoveAll(policyID int64) error {
 
 // CreateTask used to create a task
 func (dm *DefaultManager) CreateTask(task *models.Task) (int64, error) {
-	return dao.AddTask(task)
+	id, err := dao.AddTask(task)
+	if err != nil {
+		return 0, err
+	}
+	dm.publisher.publish(id, task.Status)
+	return id, nil
 }
 
 // ListTasks list the tasks according to the query
@@ -159,6 +171,7 @@ func (dm *DefaultManager) UpdateTaskStatus(taskID int64, status string, statusCo
 	if n == 0 {
 		return fmt.Errorf("Update task status failed %d: -> %s ", taskID, status)
 	}
+	dm.publisher.publish(taskID, status)
 	return nil
 }
 
@@ -184,3 +197,8 @@ func (dm *DefaultManager) GetTaskLog(taskID int64) ([]byte, error) {
 
 	return utils.GetJobServiceClient().GetJobLog(task.JobID)
 }
+
+// SubscribeTaskStatus subscribes the status transitions of the task
+func (dm *DefaultManager) SubscribeTaskStatus(taskID int64) (<-chan *TaskStatusTransition, func()) {
+	return dm.publisher.subscribe(taskID)
+}
@@ -0,0 +1,89 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execution
+
+import (
+	"sync"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+)
+
+// the size of the buffer of the subscription channels, the transitions are
+// dropped when the buffer of a slow subscriber is full rather than blocking
+// the updating of the task status
+const subscriptionBufferSize = 64
+
+// TaskStatusTransition is published to the subscribers when the status of a task changes
+type TaskStatusTransition struct {
+	TaskID int64  `json:"task_id"`
+	Status string `json:"status"`
+}
+
+type subscription struct {
+	taskID int64
+	ch     chan *TaskStatusTransition
+}
+
+type publisher struct {
+	sync.Mutex
+	nextID        int64
+	subscriptions map[int64]*subscription
+}
+
+func newPublisher() *publisher {
+	return &publisher{
+		subscriptions: map[int64]*subscription{},
+	}
+}
+
+// subscribe the status transitions of the task specified by the ID, all tasks
+// are subscribed if the ID is 0. The returned function must be called to cancel
+// the subscription when it isn't needed anymore, the channel is closed then
+func (p *publisher) subscribe(taskID int64) (<-chan *TaskStatusTransition, func()) {
+	p.Lock()
+	defer p.Unlock()
+	p.nextID++
+	id := p.nextID
+	sub := &subscription{
+		taskID: taskID,
+		ch:     make(chan *TaskStatusTransition, subscriptionBufferSize),
+	}
+	p.subscriptions[id] = sub
+	once := sync.Once{}
+	return sub.ch, func() {
+		once.Do(func() {
+			p.Lock()
+			defer p.Unlock()
+			delete(p.subscriptions, id)
+			close(sub.ch)
+		})
+	}
+}
+
+func (p *publisher) publish(taskID int64, status string) {
+	p.Lock()
+	defer p.Unlock()
+	for _, sub := range p.subscriptions {
+		if sub.taskID != 0 && sub.taskID != taskID {
+			continue
+		}
+		select {
+		case sub.ch <- &TaskStatusTransition{TaskID: taskID, Status: status}:
+		default:
+			log.Warningf("the buffer of the subscription is full, drop the status transition of the task %d to %s",
+				taskID, status)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package execution
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublisher(t *testing.T) {
+	p := newPublisher()
+	ch, cancel := p.subscribe(1)
+	all, cancelAll := p.subscribe(0)
+	defer cancelAll()
+
+	transitions := []string{
+		models.TaskStatusInitialized,
+		models.TaskStatusPending,
+		models.TaskStatusInProgress,
+		models.TaskStatusSucceed,
+	}
+	for _, status := range transitions {
+		p.publish(1, status)
+	}
+	p.publish(2, models.TaskStatusFailed)
+
+	for _, status := range transitions {
+		transition := <-ch
+		assert.Equal(t, int64(1), transition.TaskID)
+		assert.Equal(t, status, transition.Status)
+	}
+	assert.Equal(t, len(transitions)+1, len(all))
+
+	// the channel is closed and the subscription is removed after cancelling
+	cancel()
+	cancel()
+	_, ok := <-ch
+	assert.False(t, ok)
+	require.Equal(t, 1, len(p.subscriptions))
+	p.publish(1, models.TaskStatusStopped)
+	assert.Equal(t, len(transitions)+2, len(all))
+}
@@ -0,0 +1,58 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/classifier"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+var (
+	// the default max attempts of creating the adapter
+	defaultAdapterCreationMaxAttempts = 3
+	// the default delay before the first retry of creating the adapter
+	defaultAdapterCreationBaseDelay = 500 * time.Millisecond
+)
+
+// create the adapter by the factory, the transient errors(e.g. the network errors, 5xx, or the
+// failure of refreshing the token caused by them) are retried with the exponential backoff, while
+// the permanent ones(e.g. the invalid credential) are returned immediately
+func createAdapter(factory adp.Factory, registry *model.Registry, policy *model.Policy) (adp.Adapter, error) {
+	attempts := defaultAdapterCreationMaxAttempts
+	if policy.AdapterCreationMaxAttempts > 0 {
+		attempts = policy.AdapterCreationMaxAttempts
+	}
+	delay := defaultAdapterCreationBaseDelay
+	if policy.AdapterCreationBaseDelay > 0 {
+		delay = time.Duration(policy.AdapterCreationBaseDelay) * time.Millisecond
+	}
+	for i := 1; ; i++ {
+		adapter, err := factory(registry)
+		if err == nil {
+			return adapter, nil
+		}
+		if i >= attempts || !classifier.IsTransient(err) {
+			return nil, err
+		}
+		log.Warningf("failed to create the adapter for registry %s(attempt %d/%d), will retry in %s: %v",
+			registry.URL, i, attempts, delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
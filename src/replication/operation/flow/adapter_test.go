@@ -0,0 +1,71 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// returns the errors one by one and then the adapter
+func newFailingFactory(errs ...error) (adp.Factory, *int) {
+	calls := 0
+	return func(*model.Registry) (adp.Adapter, error) {
+		calls++
+		if calls <= len(errs) {
+			return nil, errs[calls-1]
+		}
+		return newFakedAdapter(), nil
+	}, &calls
+}
+
+func TestCreateAdapter(t *testing.T) {
+	registry := &model.Registry{}
+	policy := &model.Policy{
+		AdapterCreationBaseDelay: 1,
+	}
+
+	// succeed after the transient errors are retried
+	factory, calls := newFailingFactory(
+		&common_http.Error{Code: http.StatusBadGateway},
+		errors.New("dial tcp 10.0.0.1:443: i/o timeout"))
+	adapter, err := createAdapter(factory, registry, policy)
+	require.Nil(t, err)
+	assert.NotNil(t, adapter)
+	assert.Equal(t, 3, *calls)
+
+	// the max attempts are reached
+	policy.AdapterCreationMaxAttempts = 2
+	factory, calls = newFailingFactory(
+		&common_http.Error{Code: http.StatusServiceUnavailable},
+		&common_http.Error{Code: http.StatusServiceUnavailable})
+	_, err = createAdapter(factory, registry, policy)
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, *calls)
+
+	// the permanent error isn't retried
+	policy.AdapterCreationMaxAttempts = 0
+	factory, calls = newFailingFactory(&common_http.Error{Code: http.StatusUnauthorized})
+	_, err = createAdapter(factory, registry, policy)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, *calls)
+}
@@ -0,0 +1,89 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// get the value of the "min_age" filter of the policy, returns 0 if it isn't set
+func getMinAge(policy *model.Policy) (time.Duration, error) {
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypeMinAge {
+			continue
+		}
+		return filter.GetMinAge()
+	}
+	return 0, nil
+}
+
+// selects the tags of the image resources that are pushed before the min age specified
+// by the "min_age" filter. The tags whose push time is unknown are selected, and other
+// resources are kept as they are
+type minAgeSelector struct {
+	minAge time.Duration
+	lister adp.TagCreationTimeLister
+	now    time.Time
+}
+
+func newMinAgeSelector(filter *model.Filter, srcAdapter adp.Adapter) (TagSelector, error) {
+	minAge, err := filter.GetMinAge()
+	if err != nil {
+		return nil, err
+	}
+	if minAge <= 0 {
+		return nil, nil
+	}
+	lister, ok := srcAdapter.(adp.TagCreationTimeLister)
+	if !ok {
+		return nil, fmt.Errorf("the source adapter doesn't support listing the push time of tags, cannot apply the min age filter")
+	}
+	return &minAgeSelector{
+		minAge: minAge,
+		lister: lister,
+		now:    time.Now(),
+	}, nil
+}
+
+func (m *minAgeSelector) Select(resource *model.Resource) ([]string, error) {
+	if resource.Type != model.ResourceTypeImage {
+		return resource.Metadata.Vtags, nil
+	}
+	repository := resource.Metadata.Repository.Name
+	times, err := m.lister.ListTagCreationTimes(repository)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the creation time of tags under %s: %v", repository, err)
+	}
+	var tags []string
+	for _, tag := range resource.Metadata.Vtags {
+		pushTime, exist := times[tag]
+		if exist && m.now.Sub(pushTime) < m.minAge {
+			log.Debugf("the tag %s:%s is pushed at %v which is younger than %v, skip",
+				repository, tag, pushTime, m.minAge)
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+func (m *minAgeSelector) Describe() string {
+	return fmt.Sprintf("older than %v", m.minAge)
+}
@@ -0,0 +1,119 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the adapter lists the creation time of the tags according to the "times" map keyed by
+// the repository, the times keyed by "*" are listed for the repositories not in the map
+// and the repository doesn't exist if neither is found
+type fakedTagTimeAdapter struct {
+	test.Adapter
+	times map[string]map[string]time.Time
+}
+
+func (f *fakedTagTimeAdapter) ListTagCreationTimes(repository string) (map[string]time.Time, error) {
+	if times, exist := f.times[repository]; exist {
+		return times, nil
+	}
+	if times, exist := f.times["*"]; exist {
+		return times, nil
+	}
+	return nil, &common_http.Error{Code: http.StatusNotFound}
+}
+
+// the tag "fresh" is just pushed and the tag "old" is pushed one hour ago,
+// the push time of other tags is unknown
+func newPushTimeAdapter() *fakedTagTimeAdapter {
+	return &fakedTagTimeAdapter{
+		times: map[string]map[string]time.Time{
+			"*": {
+				"fresh": time.Now(),
+				"old":   time.Now().Add(-time.Hour),
+			},
+		},
+	}
+}
+
+func newAgeResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"fresh", "old", "unknown"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"fresh"},
+			},
+		},
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/harbor",
+				},
+				Vtags: []string{"fresh"},
+			},
+		},
+	}
+}
+
+func TestSelectTagsByMinAge(t *testing.T) {
+	adapter := newPushTimeAdapter()
+
+	// no min age filter
+	resources, err := selectTags(adapter, newAgeResources(), &model.Policy{})
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(resources))
+
+	// the fresh tags are skipped and the old ones are kept
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeMinAge,
+				Value: float64(120),
+			},
+		},
+	}
+	resources, err = selectTags(adapter, newAgeResources(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, "library/hello-world", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"old", "unknown"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, "library/harbor", resources[1].Metadata.Repository.Name)
+
+	// the adapter cannot list the push time of tags
+	_, err = selectTags(newFakedAdapter(), newAgeResources(), policy)
+	assert.NotNil(t, err)
+}
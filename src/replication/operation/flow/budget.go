@@ -0,0 +1,150 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// apply the byte budget of the policy to the items: the items are submitted in order
+// until the total size reaches the budget, the remaining ones are marked as "deferred"
+// and will be replicated by the next execution. Only the blobs missing on the destination
+// are counted, so the items replicated by the previous executions cost nearly nothing and
+// the budget goes to the deferred ones. The first item is always submitted even if it
+// exceeds the budget alone, otherwise the executions never make progress. Returns the items
+// that should be submitted and their total size
+func applyByteBudget(srcAdapter, dstAdapter adp.Adapter, executionMgr execution.Manager,
+	items []*scheduler.ScheduleItem, policy *model.Policy) ([]*scheduler.ScheduleItem, int64, error) {
+	if policy == nil || policy.MaxBytesPerExecution <= 0 {
+		return items, 0, nil
+	}
+	var total int64
+	for i, item := range items {
+		size, err := getTransferSize(srcAdapter, dstAdapter, item)
+		if err != nil {
+			return nil, 0, err
+		}
+		if i == 0 || total+size <= policy.MaxBytesPerExecution {
+			total += size
+			continue
+		}
+		log.Infof("the byte budget %d of the policy %d is reached, defer the remaining %d tasks",
+			policy.MaxBytesPerExecution, policy.ID, len(items)-i)
+		for _, deferred := range items[i:] {
+			if err = executionMgr.UpdateTaskStatus(deferred.TaskID, models.TaskStatusDeferred,
+				models.TaskStatusInitialized); err != nil {
+				log.Errorf("failed to update the task status %d: %v", deferred.TaskID, err)
+			}
+		}
+		return items[:i], total, nil
+	}
+	return items, total, nil
+}
+
+// the media types of the manifests whose blobs are counted
+var sizedManifestMediaTypes = []string{
+	schema1.MediaTypeManifest,
+	schema2.MediaTypeManifest,
+	manifestlist.MediaTypeManifestList,
+	adp.MediaTypeOCIManifest,
+}
+
+// get the total size of the blobs referenced by the image resource, the blobs
+// shared by the tags are counted only once. The size of other resources is
+// unknown and treated as 0
+func getResourceSize(adapter adp.Adapter, resource *model.Resource) (int64, error) {
+	blobs, err := getBlobs(adapter, resource)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, s := range blobs {
+		size += s
+	}
+	return size, nil
+}
+
+// get the total size of the blobs of the item which are missing on the destination, all
+// the blobs are counted if the destination adapter cannot check the existence of them
+func getTransferSize(srcAdapter, dstAdapter adp.Adapter, item *scheduler.ScheduleItem) (int64, error) {
+	blobs, err := getBlobs(srcAdapter, item.SrcResource)
+	if err != nil {
+		return 0, err
+	}
+	registry, ok := dstAdapter.(adp.ImageRegistry)
+	var size int64
+	for digest, s := range blobs {
+		if ok && item.DstResource != nil && item.DstResource.Metadata != nil {
+			exist, err := registry.BlobExist(item.DstResource.Metadata.Repository.Name, digest)
+			if err != nil {
+				log.Debugf("failed to check the existence of the blob %s on the destination, count it: %v", digest, err)
+			}
+			if exist {
+				continue
+			}
+		}
+		size += s
+	}
+	return size, nil
+}
+
+// get the blobs(digest -> size) referenced by the tags of the image resource
+func getBlobs(adapter adp.Adapter, resource *model.Resource) (map[string]int64, error) {
+	blobs := map[string]int64{}
+	if resource.Type != model.ResourceTypeImage || resource.Deleted {
+		return blobs, nil
+	}
+	registry, ok := adapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, fmt.Errorf("the adapter doesn't implement the ImageRegistry interface")
+	}
+	repository := resource.Metadata.Repository.Name
+	for _, tag := range resource.Metadata.Vtags {
+		if err := collectBlobs(registry, repository, tag, blobs); err != nil {
+			return nil, err
+		}
+	}
+	return blobs, nil
+}
+
+// collect the blobs referenced by the manifest, the references of the manifest list are
+// the child manifests rather than the blobs, so the child manifests are resolved instead
+func collectBlobs(registry adp.ImageRegistry, repository, reference string, blobs map[string]int64) error {
+	manifest, _, err := registry.PullManifest(repository, reference, sizedManifestMediaTypes)
+	if err != nil {
+		return fmt.Errorf("failed to pull the manifest of %s:%s: %v", repository, reference, err)
+	}
+	_, isList := manifest.(*manifestlist.DeserializedManifestList)
+	for _, descriptor := range manifest.References() {
+		if isList {
+			if err = collectBlobs(registry, repository, descriptor.Digest.String(), blobs); err != nil {
+				return err
+			}
+			continue
+		}
+		blobs[descriptor.Digest.String()] = descriptor.Size
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// returns the adapter with the images of the resources, every image has a config with
+// size 10 and a layer with size 100, the layer is shared by the tags of the same
+// repository. The tag "multi" is a manifest list of 2 images
+func newSizedAdapter(t *testing.T, resources ...*model.Resource) *test.Adapter {
+	adapter := &test.Adapter{}
+	addImage := func(repository, reference string) distribution.Descriptor {
+		manifest, err := schema2.FromStruct(schema2.Manifest{
+			Versioned: schema2.SchemaVersion,
+			Config: distribution.Descriptor{
+				MediaType: schema2.MediaTypeImageConfig,
+				Size:      10,
+				Digest:    digest.FromString(repository + ":" + reference),
+			},
+			Layers: []distribution.Descriptor{
+				{
+					MediaType: schema2.MediaTypeLayer,
+					Size:      100,
+					Digest:    digest.FromString(repository),
+				},
+			},
+		})
+		require.Nil(t, err)
+		mediaType, payload, err := manifest.Payload()
+		require.Nil(t, err)
+		adapter.AddManifest(repository, reference, mediaType, payload)
+		return distribution.Descriptor{
+			MediaType: mediaType,
+			Size:      int64(len(payload)),
+			Digest:    digest.FromBytes(payload),
+		}
+	}
+	for _, resource := range resources {
+		repository := resource.Metadata.Repository.Name
+		for _, tag := range resource.Metadata.Vtags {
+			if tag != "multi" {
+				addImage(repository, tag)
+				continue
+			}
+			list, err := manifestlist.FromDescriptors([]manifestlist.ManifestDescriptor{
+				{Descriptor: addImage(repository, "amd64")},
+				{Descriptor: addImage(repository, "arm64")},
+			})
+			require.Nil(t, err)
+			mediaType, payload, err := list.Payload()
+			require.Nil(t, err)
+			adapter.AddManifest(repository, tag, mediaType, payload)
+		}
+	}
+	return adapter
+}
+
+func newBudgetItems() []*scheduler.ScheduleItem {
+	items := []*scheduler.ScheduleItem{}
+	for i, tags := range [][]string{{"1.0", "2.0"}, {"latest"}, {"latest"}} {
+		resource := &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: fmt.Sprintf("library/image%d", i),
+				},
+				Vtags: tags,
+			},
+		}
+		items = append(items, &scheduler.ScheduleItem{
+			TaskID:      int64(i + 1),
+			SrcResource: resource,
+			DstResource: resource,
+		})
+	}
+	return items
+}
+
+func TestApplyByteBudget(t *testing.T) {
+	var resources []*model.Resource
+	for _, item := range newBudgetItems() {
+		resources = append(resources, item.SrcResource)
+	}
+	adapter := newSizedAdapter(t, resources...)
+	dstAdapter := &test.Adapter{}
+	mgr := newFakedExecutionManagerWithTasks(3)
+
+	// no budget
+	items, _, err := applyByteBudget(adapter, dstAdapter, mgr, newBudgetItems(), &model.Policy{})
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+
+	// the sizes of the items are 120, 110 and 110
+	items, bytes, err := applyByteBudget(adapter, dstAdapter, mgr, newBudgetItems(), &model.Policy{
+		MaxBytesPerExecution: 300,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, int64(230), bytes)
+	require.Equal(t, 2, len(items))
+	assert.Equal(t, int64(1), items[0].TaskID)
+	assert.Equal(t, int64(2), items[1].TaskID)
+	assert.Equal(t, map[int64]string{3: models.TaskStatusDeferred}, updatedTaskStatuses(mgr))
+
+	// the budget is too small for any item, the first one is submitted anyway
+	mgr = newFakedExecutionManagerWithTasks(3)
+	items, _, err = applyByteBudget(adapter, dstAdapter, mgr, newBudgetItems(), &model.Policy{
+		MaxBytesPerExecution: 100,
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, int64(1), items[0].TaskID)
+	assert.Equal(t, 2, len(updatedTaskStatuses(mgr)))
+
+	// the blobs of the first 2 items are replicated by the previous execution,
+	// so the deferred one fits into the budget this time
+	for _, item := range newBudgetItems()[:2] {
+		repository := item.SrcResource.Metadata.Repository.Name
+		dstAdapter.AddBlob([]byte(repository))
+		for _, tag := range item.SrcResource.Metadata.Vtags {
+			dstAdapter.AddBlob([]byte(repository + ":" + tag))
+		}
+	}
+	mgr = newFakedExecutionManagerWithTasks(3)
+	items, bytes, err = applyByteBudget(adapter, dstAdapter, mgr, newBudgetItems(), &model.Policy{
+		MaxBytesPerExecution: 200,
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, int64(110), bytes)
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+}
+
+func TestGetResourceSizeOfManifestList(t *testing.T) {
+	// the layers of the child manifests are counted rather than the child manifests
+	resource := &model.Resource{
+		Type: model.ResourceTypeImage,
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: "library/hello-world",
+			},
+			Vtags: []string{"multi"},
+		},
+	}
+	size, err := getResourceSize(newSizedAdapter(t, resource), resource)
+	require.Nil(t, err)
+	// the configs of the 2 images and the layer shared by them
+	assert.Equal(t, int64(120), size)
+}
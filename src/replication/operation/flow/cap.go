@@ -0,0 +1,141 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+// apply the cap of the repositories per source namespace to the items to avoid one namespace
+// dominating the execution: the items of the first repositories of each namespace are submitted
+// in order until the count of the repositories reaches the cap, the items of the remaining
+// repositories are marked as "deferred" and will be replicated by the next execution
+func applyNamespaceRepositoryCap(executionMgr execution.Manager, items []*scheduler.ScheduleItem,
+	policy *model.Policy) []*scheduler.ScheduleItem {
+	if policy == nil || policy.MaxRepositoriesPerNamespace <= 0 {
+		return items
+	}
+	// namespace -> the repositories accepted
+	accepted := map[string]map[string]struct{}{}
+	// namespace -> the repositories deferred
+	deferred := map[string]map[string]struct{}{}
+	var result []*scheduler.ScheduleItem
+	for _, item := range items {
+		repository := item.SrcResource.Metadata.Repository.Name
+		namespace, _ := util.ParseNamespace(repository)
+		repositories, exist := accepted[namespace]
+		if !exist {
+			repositories = map[string]struct{}{}
+			accepted[namespace] = repositories
+		}
+		// the items of the same repository(e.g. the versions of one chart) are kept together
+		if _, exist := repositories[repository]; exist || len(repositories) < policy.MaxRepositoriesPerNamespace {
+			repositories[repository] = struct{}{}
+			result = append(result, item)
+			continue
+		}
+		if deferred[namespace] == nil {
+			deferred[namespace] = map[string]struct{}{}
+		}
+		deferred[namespace][repository] = struct{}{}
+		if err := executionMgr.UpdateTaskStatus(item.TaskID, models.TaskStatusDeferred,
+			models.TaskStatusInitialized); err != nil {
+			log.Errorf("failed to update the task status %d: %v", item.TaskID, err)
+		}
+	}
+	for namespace, repositories := range deferred {
+		log.Infof("the cap %d of the repositories per namespace of the policy %d is reached, "+
+			"defer the remaining %d repositories of the namespace %s",
+			policy.MaxRepositoriesPerNamespace, policy.ID, len(repositories), namespace)
+	}
+	return result
+}
+
+// put the items of the repositories deferred in the previous execution of the policy first,
+// in the order they were deferred. As the tasks are created in the order of the items, the
+// repositories admitted by the cap rotate across the executions rather than the same first
+// repositories of each namespace being admitted every time and the others never running
+func rotateDeferredRepositories(executionMgr execution.Manager, executionID int64,
+	items []*scheduler.ScheduleItem, policy *model.Policy) ([]*scheduler.ScheduleItem, error) {
+	if policy == nil || policy.MaxRepositoriesPerNamespace <= 0 || len(items) == 0 {
+		return items, nil
+	}
+	// the executions are sorted by the start time in descending order
+	_, executions, err := executionMgr.List(&models.ExecutionQuery{
+		PolicyID: policy.ID,
+		Pagination: models.Pagination{
+			Page: 1,
+			Size: 2,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the executions of the policy %d: %v", policy.ID, err)
+	}
+	var previous *models.Execution
+	for _, e := range executions {
+		if e.ID != executionID {
+			previous = e
+			break
+		}
+	}
+	if previous == nil {
+		return items, nil
+	}
+	_, tasks, err := executionMgr.ListTasks(&models.TaskQuery{
+		ExecutionID: previous.ID,
+		Statuses:    []string{models.TaskStatusDeferred},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the deferred tasks of the execution %d: %v", previous.ID, err)
+	}
+	if len(tasks) == 0 {
+		return items, nil
+	}
+	// the tasks were created in the order of the items of the previous execution
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].ID < tasks[j].ID
+	})
+	ranks := map[string]int{}
+	for _, task := range tasks {
+		repository := task.SrcResource
+		if i := strings.Index(repository, ":["); i > 0 {
+			repository = repository[:i]
+		}
+		if _, exist := ranks[repository]; !exist {
+			ranks[repository] = len(ranks)
+		}
+	}
+	rotated := make([]*scheduler.ScheduleItem, len(items))
+	copy(rotated, items)
+	sort.SliceStable(rotated, func(i, j int) bool {
+		ri, deferredI := ranks[rotated[i].SrcResource.Metadata.Repository.Name]
+		rj, deferredJ := ranks[rotated[j].SrcResource.Metadata.Repository.Name]
+		if deferredI != deferredJ {
+			return deferredI
+		}
+		return deferredI && ri < rj
+	})
+	log.Debugf("%d repositories deferred in the execution %d are put first", len(ranks), previous.ID)
+	return rotated, nil
+}
@@ -0,0 +1,151 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCapItem(taskID int64, repository string) *scheduler.ScheduleItem {
+	return &scheduler.ScheduleItem{
+		TaskID: taskID,
+		SrcResource: &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: repository,
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+}
+
+func TestApplyNamespaceRepositoryCap(t *testing.T) {
+	// 100 repositories in the namespace "library" and 5 in "harbor"
+	items := []*scheduler.ScheduleItem{}
+	for i := 0; i < 100; i++ {
+		items = append(items, newCapItem(int64(len(items)+1), fmt.Sprintf("library/image%d", i)))
+	}
+	for i := 0; i < 5; i++ {
+		items = append(items, newCapItem(int64(len(items)+1), fmt.Sprintf("harbor/image%d", i)))
+	}
+	mgr := newFakedExecutionManagerWithTasks(len(items))
+	policy := &model.Policy{
+		MaxRepositoriesPerNamespace: 20,
+	}
+	res := applyNamespaceRepositoryCap(mgr, items, policy)
+	require.Equal(t, 25, len(res))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, fmt.Sprintf("library/image%d", i), res[i].SrcResource.Metadata.Repository.Name)
+	}
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, fmt.Sprintf("harbor/image%d", i), res[20+i].SrcResource.Metadata.Repository.Name)
+	}
+	// the remaining 80 repositories of "library" are deferred
+	require.Equal(t, 80, len(updatedTaskStatuses(mgr)))
+	for id := int64(21); id <= 100; id++ {
+		assert.Equal(t, models.TaskStatusDeferred, updatedTaskStatuses(mgr)[id])
+	}
+
+	// no cap
+	res = applyNamespaceRepositoryCap(mgr, items, &model.Policy{})
+	assert.Equal(t, 105, len(res))
+}
+
+func TestApplyNamespaceRepositoryCapKeepsRepositoriesTogether(t *testing.T) {
+	// the versions of one chart are different items of the same repository
+	items := []*scheduler.ScheduleItem{
+		newCapItem(1, "library/harbor"),
+		newCapItem(2, "library/mysql"),
+		newCapItem(3, "library/harbor"),
+	}
+	mgr := newFakedExecutionManagerWithTasks(len(items))
+	res := applyNamespaceRepositoryCap(mgr, items, &model.Policy{
+		MaxRepositoriesPerNamespace: 1,
+	})
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, int64(1), res[0].TaskID)
+	assert.Equal(t, int64(3), res[1].TaskID)
+	assert.Equal(t, map[int64]string{2: models.TaskStatusDeferred}, updatedTaskStatuses(mgr))
+}
+
+func TestApplyNamespaceRepositoryCapWithNestedRepositories(t *testing.T) {
+	// the nested repositories belong to the project "library"
+	items := []*scheduler.ScheduleItem{
+		newCapItem(1, "library/a/b"),
+		newCapItem(2, "library/a/c"),
+		newCapItem(3, "library/d"),
+	}
+	mgr := newFakedExecutionManagerWithTasks(len(items))
+	res := applyNamespaceRepositoryCap(mgr, items, &model.Policy{
+		MaxRepositoriesPerNamespace: 2,
+	})
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, int64(1), res[0].TaskID)
+	assert.Equal(t, int64(2), res[1].TaskID)
+	assert.Equal(t, map[int64]string{3: models.TaskStatusDeferred}, updatedTaskStatuses(mgr))
+}
+
+func TestRotateDeferredRepositories(t *testing.T) {
+	repositories := []string{"library/a", "library/b", "library/c", "library/d", "library/e"}
+	policy := &model.Policy{
+		MaxRepositoriesPerNamespace: 2,
+	}
+	mgr := test.NewExecutionManager()
+	var admitted [][]string
+	for n := 0; n < 4; n++ {
+		executionID, err := mgr.Create(&models.Execution{
+			PolicyID: policy.ID,
+		})
+		require.Nil(t, err)
+		var items []*scheduler.ScheduleItem
+		for _, repository := range repositories {
+			items = append(items, newCapItem(0, repository))
+		}
+		items, err = rotateDeferredRepositories(mgr, executionID, items, policy)
+		require.Nil(t, err)
+		// the tasks are created in the order of the items
+		for _, item := range items {
+			item.TaskID, err = mgr.CreateTask(&models.Task{
+				ExecutionID: executionID,
+				SrcResource: getResourceName(item.SrcResource),
+				Status:      models.TaskStatusInitialized,
+			})
+			require.Nil(t, err)
+		}
+		res := applyNamespaceRepositoryCap(mgr, items, policy)
+		var names []string
+		for _, item := range res {
+			names = append(names, item.SrcResource.Metadata.Repository.Name)
+		}
+		admitted = append(admitted, names)
+	}
+	// every repository gets its turn
+	assert.Equal(t, [][]string{
+		{"library/a", "library/b"},
+		{"library/c", "library/d"},
+		{"library/e", "library/a"},
+		{"library/b", "library/c"},
+	}, admitted)
+}
@@ -0,0 +1,65 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// split the items whose resources have more tags than tagsPerTask into several
+// items with at most tagsPerTask tags each, so that the chunks are submitted and
+// retried as separate tasks. The items are returned as is if tagsPerTask <= 0
+func splitItemsByTags(items []*scheduler.ScheduleItem, tagsPerTask int) []*scheduler.ScheduleItem {
+	if tagsPerTask <= 0 {
+		return items
+	}
+	result := []*scheduler.ScheduleItem{}
+	for _, item := range items {
+		if !isSplittable(item, tagsPerTask) {
+			result = append(result, item)
+			continue
+		}
+		srcTags, dstTags := item.SrcResource.Metadata.Vtags, item.DstResource.Metadata.Vtags
+		for i := 0; i < len(srcTags); i += tagsPerTask {
+			end := i + tagsPerTask
+			if end > len(srcTags) {
+				end = len(srcTags)
+			}
+			result = append(result, &scheduler.ScheduleItem{
+				SrcResource: copyResourceWithTags(item.SrcResource, srcTags[i:end]),
+				DstResource: copyResourceWithTags(item.DstResource, dstTags[i:end]),
+			})
+		}
+	}
+	return result
+}
+
+// the tags of the source and destination resources are split by the same
+// positions, so only the ones with the tags in pairs can be split
+func isSplittable(item *scheduler.ScheduleItem, tagsPerTask int) bool {
+	if item.SrcResource == nil || item.SrcResource.Metadata == nil ||
+		item.DstResource == nil || item.DstResource.Metadata == nil {
+		return false
+	}
+	srcTags, dstTags := item.SrcResource.Metadata.Vtags, item.DstResource.Metadata.Vtags
+	return len(srcTags) > tagsPerTask && len(srcTags) == len(dstTags)
+}
+
+func copyResourceWithTags(resource *model.Resource, tags []string) *model.Resource {
+	res := copyResource(resource)
+	res.Metadata.Vtags = append([]string(nil), tags...)
+	return res
+}
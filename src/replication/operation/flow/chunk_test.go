@@ -0,0 +1,86 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newChunkItems(count int) []*scheduler.ScheduleItem {
+	tags := []string{}
+	for i := 0; i < count; i++ {
+		tags = append(tags, fmt.Sprintf("%d.0", i))
+	}
+	newResource := func(repository string) *model.Resource {
+		return &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: repository,
+				},
+				Vtags: append([]string(nil), tags...),
+			},
+		}
+	}
+	return []*scheduler.ScheduleItem{
+		{
+			SrcResource: newResource("library/hello-world"),
+			DstResource: newResource("mirror/hello-world"),
+		},
+	}
+}
+
+func TestSplitItemsByTags(t *testing.T) {
+	// no split
+	items := splitItemsByTags(newChunkItems(50), 0)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, 50, len(items[0].SrcResource.Metadata.Vtags))
+
+	// the resource has less tags than the chunk size
+	items = splitItemsByTags(newChunkItems(5), 10)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, 5, len(items[0].SrcResource.Metadata.Vtags))
+
+	// the uneven chunks
+	items = splitItemsByTags(newChunkItems(25), 10)
+	require.Equal(t, 3, len(items))
+	assert.Equal(t, []string{"20.0", "21.0", "22.0", "23.0", "24.0"}, items[2].SrcResource.Metadata.Vtags)
+}
+
+func TestCreateTasksInChunks(t *testing.T) {
+	mgr := newFakedExecutionManager()
+	original := newChunkItems(50)
+	items, err := createTasks(mgr, 1, original, 10)
+	require.Nil(t, err)
+	require.Equal(t, 5, len(items))
+	require.Equal(t, 5, len(mgr.Tasks()))
+	for i, item := range items {
+		assert.Equal(t, int64(i+1), item.TaskID)
+		assert.Equal(t, "library/hello-world", item.SrcResource.Metadata.Repository.Name)
+		assert.Equal(t, "mirror/hello-world", item.DstResource.Metadata.Repository.Name)
+		require.Equal(t, 10, len(item.SrcResource.Metadata.Vtags))
+		assert.Equal(t, fmt.Sprintf("%d.0", i*10), item.SrcResource.Metadata.Vtags[0])
+		assert.Equal(t, item.SrcResource.Metadata.Vtags, item.DstResource.Metadata.Vtags)
+		assert.Equal(t, getResourceName(item.SrcResource), mgr.Tasks()[i].SrcResource)
+	}
+	// the original resource isn't modified
+	assert.Equal(t, 50, len(original[0].SrcResource.Metadata.Vtags))
+}
@@ -0,0 +1,150 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"sync"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+const (
+	// the concurrency backs off when the error rate of the last execution exceeds this value
+	errorRateThreshold = 0.1
+)
+
+// concurrencyController tunes the count of the tasks in flight adaptively:
+// increases the concurrency by one when all the tasks of the last execution succeed
+// and halves it when the error rate of the last execution exceeds the threshold
+type concurrencyController struct {
+	sync.Mutex
+	min     int
+	max     int
+	current int
+	// the last execution whose outcome is reported
+	reported int64
+}
+
+func newConcurrencyController(min, max int) *concurrencyController {
+	c := &concurrencyController{}
+	c.setBounds(min, max)
+	c.current = c.min
+	return c
+}
+
+// set the bounds of the concurrency, the current one is kept within them
+func (c *concurrencyController) setBounds(min, max int) {
+	c.Lock()
+	defer c.Unlock()
+	if min <= 0 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	c.min, c.max = min, max
+	if c.current < min {
+		c.current = min
+	}
+	if c.current > max {
+		c.current = max
+	}
+}
+
+// Concurrency returns the current concurrency
+func (c *concurrencyController) Concurrency() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.current
+}
+
+// Report the final counts of the tasks of the finished execution and tune the
+// concurrency, the outcome of one execution is only counted once
+func (c *concurrencyController) Report(executionID int64, succeed, failed int) {
+	c.Lock()
+	defer c.Unlock()
+	if executionID <= c.reported {
+		return
+	}
+	c.reported = executionID
+	total := succeed + failed
+	if total == 0 {
+		return
+	}
+	rate := float64(failed) / float64(total)
+	switch {
+	case rate > errorRateThreshold:
+		c.current = c.current / 2
+		if c.current < c.min {
+			c.current = c.min
+		}
+	case failed == 0:
+		if c.current < c.max {
+			c.current++
+		}
+	}
+	log.Debugf("the error rate of the execution %d is %.2f, the concurrency is tuned to %d",
+		executionID, rate, c.current)
+}
+
+// the concurrency controllers indexed by the policy IDs. The tasks are run by the
+// jobservice asynchronously, so their outcome is only known when the execution finishes,
+// and the next execution of the policy starts from the concurrency tuned by it. The
+// controllers are kept in memory, the concurrency starts from the min after restarting,
+// and the one of the policy is removed when the policy is deleted
+var concurrencyControllers = struct {
+	sync.Mutex
+	controllers map[int64]*concurrencyController
+}{
+	controllers: map[int64]*concurrencyController{},
+}
+
+// get the concurrency controller of the policy, the bounds are updated as the
+// policy may be changed since the last execution
+func getConcurrencyController(policy *model.Policy) *concurrencyController {
+	concurrencyControllers.Lock()
+	defer concurrencyControllers.Unlock()
+	ctl, exist := concurrencyControllers.controllers[policy.ID]
+	if !exist {
+		ctl = newConcurrencyController(policy.MinConcurrency, policy.MaxConcurrency)
+		concurrencyControllers.controllers[policy.ID] = ctl
+		return ctl
+	}
+	ctl.setBounds(policy.MinConcurrency, policy.MaxConcurrency)
+	return ctl
+}
+
+// ReportExecutionOutcome tunes the concurrency of the policy by the error rate of
+// the tasks of its finished execution. It's called by the task status hook, the
+// tasks skipped or stopped aren't counted
+func ReportExecutionOutcome(policyID, executionID int64, succeed, failed int) {
+	concurrencyControllers.Lock()
+	ctl, exist := concurrencyControllers.controllers[policyID]
+	concurrencyControllers.Unlock()
+	// the concurrency isn't tuned adaptively for the policy
+	if !exist {
+		return
+	}
+	ctl.Report(executionID, succeed, failed)
+}
+
+// RemoveConcurrencyController removes the concurrency tuned for the policy,
+// it's called when the policy is deleted
+func RemoveConcurrencyController(policyID int64) {
+	concurrencyControllers.Lock()
+	defer concurrencyControllers.Unlock()
+	delete(concurrencyControllers.controllers, policyID)
+}
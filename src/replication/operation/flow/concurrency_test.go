@@ -0,0 +1,166 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the scheduler fails the tasks whose ID is larger than "failFrom"
+type fakedFailingScheduler struct {
+	fakedScheduler
+	failFrom int64
+}
+
+func (f *fakedFailingScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	results := []*scheduler.ScheduleResult{}
+	for _, item := range items {
+		result := &scheduler.ScheduleResult{
+			TaskID: item.TaskID,
+		}
+		if item.TaskID > f.failFrom {
+			result.Error = errors.New("error")
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func TestConcurrencyController(t *testing.T) {
+	ctl := newConcurrencyController(0, 4)
+	assert.Equal(t, 1, ctl.Concurrency())
+
+	// increase while the tasks succeed
+	var id int64
+	for id = 1; id <= 10; id++ {
+		ctl.Report(id, ctl.Concurrency(), 0)
+	}
+	assert.Equal(t, 4, ctl.Concurrency())
+
+	// the error rate is under the threshold, keep the concurrency
+	ctl.Report(11, 19, 1)
+	assert.Equal(t, 4, ctl.Concurrency())
+
+	// the error rate rises, back off
+	ctl.Report(12, 3, 1)
+	assert.Equal(t, 2, ctl.Concurrency())
+	// the outcome of the same execution is only counted once
+	ctl.Report(12, 3, 1)
+	assert.Equal(t, 2, ctl.Concurrency())
+	ctl.Report(13, 0, 2)
+	assert.Equal(t, 1, ctl.Concurrency())
+	// bounded by the min
+	ctl.Report(14, 0, 1)
+	assert.Equal(t, 1, ctl.Concurrency())
+}
+
+func TestReportExecutionOutcome(t *testing.T) {
+	policy := &model.Policy{
+		ID:             1000,
+		MinConcurrency: 2,
+		MaxConcurrency: 8,
+	}
+	assert.Equal(t, 2, getConcurrencyController(policy).Concurrency())
+
+	// the next execution starts from the concurrency tuned by the last one
+	ReportExecutionOutcome(1000, 1, 10, 0)
+	ReportExecutionOutcome(1000, 2, 10, 0)
+	assert.Equal(t, 4, getConcurrencyController(policy).Concurrency())
+	// the error rate of the tasks rises
+	ReportExecutionOutcome(1000, 3, 8, 2)
+	assert.Equal(t, 2, getConcurrencyController(policy).Concurrency())
+
+	// the bounds are changed by updating the policy
+	policy.MinConcurrency = 3
+	assert.Equal(t, 3, getConcurrencyController(policy).Concurrency())
+
+	// the policy doesn't tune the concurrency
+	ReportExecutionOutcome(1001, 1, 0, 10)
+}
+
+func TestRemoveConcurrencyController(t *testing.T) {
+	policy := &model.Policy{
+		ID:             1001,
+		MinConcurrency: 1,
+		MaxConcurrency: 8,
+	}
+	getConcurrencyController(policy)
+	ReportExecutionOutcome(1001, 1, 10, 0)
+	assert.Equal(t, 2, getConcurrencyController(policy).Concurrency())
+
+	// the concurrency starts from the min again once the policy is removed
+	RemoveConcurrencyController(1001)
+	ReportExecutionOutcome(1001, 2, 10, 0)
+	assert.Equal(t, 1, getConcurrencyController(policy).Concurrency())
+	RemoveConcurrencyController(1001)
+}
+
+func TestGetInFlightLimit(t *testing.T) {
+	assert.Equal(t, 0, getInFlightLimit(nil))
+	assert.Equal(t, 0, getInFlightLimit(&model.Policy{}))
+	assert.Equal(t, 10, getInFlightLimit(&model.Policy{MaxInFlightTasks: 10}))
+	assert.Equal(t, 1, getInFlightLimit(&model.Policy{
+		MaxInFlightTasks: 10,
+		ExecutionMode:    model.ExecutionModeOrdered,
+	}))
+
+	// bounded by the concurrency tuned adaptively
+	policy := &model.Policy{
+		ID:             1002,
+		MinConcurrency: 4,
+		MaxConcurrency: 8,
+	}
+	defer RemoveConcurrencyController(policy.ID)
+	assert.Equal(t, 4, getInFlightLimit(policy))
+	policy.MaxInFlightTasks = 10
+	assert.Equal(t, 4, getInFlightLimit(policy))
+	policy.MaxInFlightTasks = 2
+	assert.Equal(t, 2, getInFlightLimit(policy))
+}
+
+func TestScheduleWithAdaptiveConcurrency(t *testing.T) {
+	interval := inFlightPollInterval
+	inFlightPollInterval = time.Millisecond
+	defer func() {
+		inFlightPollInterval = interval
+	}()
+
+	policy := &model.Policy{
+		ID:             1003,
+		MinConcurrency: 4,
+		MaxConcurrency: 8,
+	}
+	defer RemoveConcurrencyController(policy.ID)
+	sched := &fakedBatchScheduler{}
+	n, err := schedule(context.Background(), sched, newInFlightExecutionManager(models.TaskStatusSucceed),
+		newInFlightItems(), policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 28, n)
+	// at most 4 tasks of each resource type are in flight
+	assert.Equal(t, []int{4, 3, 4, 4, 4, 4, 4, 1}, sched.batches)
+
+	_, err = schedule(context.Background(), &fakedFailingScheduler{}, newInFlightExecutionManager(""),
+		newInFlightItems(), policy, nil)
+	assert.NotNil(t, err)
+}
@@ -14,15 +14,21 @@
 
 package flow
 
+import (
+	"context"
+)
+
 // Flow defines the replication flow
 type Flow interface {
+	// the parameter is the context cancelling the flow, the flow cannot be
+	// cancelled if it isn't a context(e.g. nil).
 	// returns the count of tasks which have been scheduled and the error
 	Run(interface{}) (int, error)
 }
 
 // Controller is the controller that controls the replication flows
 type Controller interface {
-	Start(Flow) (int, error)
+	Start(context.Context, Flow) (int, error)
 }
 
 // NewController returns an instance of the default flow controller
@@ -32,6 +38,20 @@ func NewController() Controller {
 
 type controller struct{}
 
-func (c *controller) Start(flow Flow) (int, error) {
-	return flow.Run(nil)
+func (c *controller) Start(ctx context.Context, flow Flow) (int, error) {
+	return flow.Run(ctx)
+}
+
+// get the context from the parameter of the flow
+func getContext(param interface{}) context.Context {
+	if ctx, ok := param.(context.Context); ok && ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
+
+// IsCancelled returns whether the error is returned by the flow because its context is
+// cancelled, the execution should be marked as stopped rather than failed if so
+func IsCancelled(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded
 }
@@ -15,6 +15,7 @@
 package flow
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -30,7 +31,7 @@ func (f *fakedFlow) Run(interface{}) (int, error) {
 func TestStart(t *testing.T) {
 	flow := &fakedFlow{}
 	controller := NewController()
-	n, err := controller.Start(flow)
+	n, err := controller.Start(context.Background(), flow)
 	require.Nil(t, err)
 	assert.Equal(t, 1, n)
 }
@@ -15,9 +15,11 @@
 package flow
 
 import (
+	"context"
 	"time"
 
 	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/dao/models"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/operation/execution"
@@ -30,6 +32,9 @@ type copyFlow struct {
 	policy       *model.Policy
 	executionMgr execution.Manager
 	scheduler    scheduler.Scheduler
+	logger       *log.Logger
+	// only record the tasks that would be submitted rather than submitting them
+	dryRun bool
 }
 
 // NewCopyFlow returns an instance of the copy flow which replicates the resources from
@@ -43,54 +48,302 @@ func NewCopyFlow(executionMgr execution.Manager, scheduler scheduler.Scheduler,
 		executionID:  executionID,
 		policy:       policy,
 		resources:    resources,
+		logger:       newFlowLogger(policy),
 	}
 }
 
-func (c *copyFlow) Run(interface{}) (int, error) {
+func (c *copyFlow) Run(param interface{}) (int, error) {
+	sum := newSummary()
+	n, err := c.run(getContext(param), sum)
+	if c.dryRun && err == nil {
+		markExecutionDryRun(c.executionMgr, c.executionID, sum)
+	}
+	sum.emit(c.executionMgr, c.executionID, err)
+	return n, err
+}
+
+func (c *copyFlow) run(ctx context.Context, sum *summary) (int, error) {
+	proceed, err := guardConcurrentExecutions(ctx, c.executionMgr, c.executionID, c.policy)
+	if err != nil || !proceed {
+		return 0, err
+	}
 	srcAdapter, dstAdapter, err := initialize(c.policy)
 	if err != nil {
 		return 0, err
 	}
-	var srcResources []*model.Resource
-	if len(c.resources) > 0 {
-		srcResources, err = filterResources(c.resources, c.policy.Filters)
-	} else {
-		srcResources, err = fetchResources(srcAdapter, c.policy)
+	srcResources := c.resources
+	var fetchFailures []*fetchFailure
+	var mark *watermark
+	if len(srcResources) == 0 {
+		mark, err = getWatermark(c.executionMgr, c.executionID, c.policy)
+		if err != nil {
+			return 0, err
+		}
+		srcResources, fetchFailures, err = fetchResources(ctx, srcAdapter, c.policy, mark)
+		if err != nil {
+			return 0, err
+		}
+		if err = createFetchFailedTasks(c.executionMgr, c.executionID, fetchFailures); err != nil {
+			return 0, err
+		}
+		if err = checkSrcNamespaces(srcAdapter, c.policy, srcResources); err != nil {
+			return 0, err
+		}
+	}
+	// the resources timed out in the previous execution are requeued
+	srcResources, err = requeueRetryableResources(c.executionMgr, c.executionID, c.policy, srcResources)
+	if err != nil {
+		return 0, err
+	}
+	srcResources = filterByCredentialScope(srcResources, c.policy)
+	// the tags removed from the source are only detected when all the resources are fetched
+	var sourceTags map[string]map[string]struct{}
+	if len(c.resources) == 0 && len(fetchFailures) == 0 && !fetchesIncrementally(srcAdapter, mark) {
+		sourceTags = snapshotSourceTags(srcResources, c.policy)
+	}
+	sum.Fetched = len(srcResources)
+	c.logger.Debugf("%d resources fetched for the execution %d", len(srcResources), c.executionID)
+	// the filters that cannot be handled by the adapters are applied here
+	var trace *FilterTrace
+	if c.policy.TraceFilters {
+		trace = &FilterTrace{}
+	}
+	srcResources, err = traceFilterResources(srcResources, c.policy.Filters, trace, sum.Dropped)
+	if err != nil {
+		return 0, err
 	}
+	trace.emit(c.executionID)
+	recordFilterSummary(c.executionMgr, c.executionID, sum.Dropped)
+	// the sampled dry run only previews part of the repositories matching the
+	// filters and extrapolates the full run from them
+	var sampled, total int
+	if c.dryRun && c.policy.DryRunSampleSize > 0 {
+		srcResources, total = sampleResources(srcResources, c.policy.DryRunSampleSize, c.policy.DryRunRandomSample)
+		sampled = c.policy.DryRunSampleSize
+		if sampled > total {
+			sampled = total
+		}
+		c.logger.Infof("%d of %d repositories sampled for the dry run %d", sampled, total, c.executionID)
+	}
+	srcResources = filterEmptyRepositories(srcResources, c.policy)
+	srcResources, err = filterDormantResources(srcAdapter, srcResources, c.policy)
 	if err != nil {
 		return 0, err
 	}
+	srcResources, err = selectTags(srcAdapter, srcResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	srcResources, err = filterByPlatform(srcAdapter, srcResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	srcResources, err = filterByVisibility(srcResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	srcResources, err = filterByPullCount(srcResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	// applied after the other filters as the digests recorded for the next execution
+	// should only cover the tags that this one replicates
+	if len(c.resources) == 0 {
+		srcResources, err = filterUnchangedResources(c.executionMgr, c.executionID, srcAdapter,
+			srcResources, mark, c.policy)
+		if err != nil {
+			return 0, err
+		}
+	}
+	srcResources, err = appendUntaggedManifests(srcAdapter, srcResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	sum.Filtered = len(srcResources)
+	c.logger.Debugf("%d resources left after filtering for the execution %d", len(srcResources), c.executionID)
 
 	isStopped, err := isExecutionStopped(c.executionMgr, c.executionID)
 	if err != nil {
 		return 0, err
 	}
 	if isStopped {
-		log.Debugf("the execution %d is stopped, stop the flow", c.executionID)
+		c.logger.Debugf("the execution %d is stopped, stop the flow", c.executionID)
 		return 0, nil
 	}
+	if err = ctx.Err(); err != nil {
+		return 0, err
+	}
 
 	if len(srcResources) == 0 {
+		if len(fetchFailures) > 0 {
+			markExecutionPartialSuccess(c.executionMgr, c.executionID, len(fetchFailures), 0,
+				"no resources need to be replicated")
+			c.logger.Infof("no resources need to be replicated for the execution %d, skip", c.executionID)
+			return 0, nil
+		}
 		markExecutionSuccess(c.executionMgr, c.executionID, "no resources need to be replicated")
-		log.Infof("no resources need to be replicated for the execution %d, skip", c.executionID)
+		c.logger.Infof("no resources need to be replicated for the execution %d, skip", c.executionID)
 		return 0, nil
 	}
 
 	srcResources = assembleSourceResources(srcResources, c.policy)
 	dstResources := assembleDestinationResources(srcResources, c.policy)
+	// the tasks of the execution share the bandwidth limit
+	for _, resource := range dstResources {
+		resource.ExecutionID = c.executionID
+	}
+	if err = checkSelfReplication(c.policy, srcResources, dstResources); err != nil {
+		return 0, err
+	}
+	// the expired destination tags and the ones removed from the source are deleted
+	// no matter whether the resources are modified
+	srcResources, dstResources, deletionItems, err := expireDestinationTags(dstAdapter,
+		srcResources, dstResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	removedItems, err := detectRemovedTags(dstAdapter, srcResources, dstResources,
+		sourceTags, deletionItems, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	deletionItems = append(deletionItems, removedItems...)
+	var deletionSum *summary
+	if c.dryRun {
+		deletionSum, err = previewDeletions(c.executionMgr, c.executionID, deletionItems, c.policy)
+	} else {
+		deletionSum, err = scheduleDeletions(ctx, c.scheduler, c.executionMgr, c.executionID, deletionItems, c.policy)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// merged at last as the counts are overwritten when scheduling the copy tasks
+	defer sum.merge(deletionSum)
+	deletions := deletionSum.Created
+	c.logger.Debugf("%d tasks deleting the destination tags scheduled for the execution %d", deletions, c.executionID)
+
+	modifiedSrcResources, modifiedDstResources, err := filterUnmodifiedResources(srcAdapter, dstAdapter,
+		srcResources, dstResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	// record the unmodified resources as the skipped tasks
+	unmodifiedSrcResources, unmodifiedDstResources := getDroppedResources(srcResources,
+		dstResources, modifiedSrcResources)
+	skipped, err := createSkippedTasks(c.executionMgr, c.executionID,
+		unmodifiedSrcResources, unmodifiedDstResources)
+	if err != nil {
+		return 0, err
+	}
+	sum.Created, sum.Skipped = skipped, skipped
+	srcResources, dstResources = modifiedSrcResources, modifiedDstResources
+	srcResources, dstResources, err = limitTagFanOut(srcAdapter, dstAdapter,
+		srcResources, dstResources, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	sum.Filtered = len(srcResources)
+	c.logger.Debugf("%d resources are modified and %d are skipped for the execution %d",
+		len(srcResources), skipped, c.executionID)
+	if len(srcResources) == 0 {
+		// the status of the execution is got from the tasks deleting the destination tags
+		if deletions == 0 && len(fetchFailures) > 0 {
+			markExecutionPartialSuccess(c.executionMgr, c.executionID, len(fetchFailures), skipped,
+				"no resources are modified")
+		} else if deletions == 0 {
+			markExecutionSkipped(c.executionMgr, c.executionID, skipped, "no resources are modified")
+		}
+		c.logger.Infof("no resources are modified for the execution %d, skip", c.executionID)
+		return deletions, nil
+	}
+
+	if c.dryRun {
+		return 0, c.preview(sum, srcAdapter, srcResources, dstResources, sampled, total)
+	}
 
-	if err = prepareForPush(dstAdapter, dstResources); err != nil {
+	sched := c.scheduler
+	if c.policy.LazyNamespaceCreation {
+		sched = newNamespacePreparingScheduler(c.scheduler, dstAdapter, dstResources)
+	} else if err = prepareForPush(dstAdapter, dstResources); err != nil {
 		return 0, err
 	}
 	items, err := preprocess(c.scheduler, srcResources, dstResources)
 	if err != nil {
 		return 0, err
 	}
-	if err = createTasks(c.executionMgr, c.executionID, items); err != nil {
+	items, err = rotateDeferredRepositories(c.executionMgr, c.executionID, items, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	items, err = createTasks(c.executionMgr, c.executionID, items, c.policy.TagsPerTask)
+	if err != nil {
 		return 0, err
 	}
+	created := len(items)
+	sum.Created += created
+	c.logger.Debugf("%d tasks created for the execution %d", created, c.executionID)
+	items, sum.Failed = validateByWebhook(c.executionMgr, items, c.policy)
+	items = applyNamespaceRepositoryCap(c.executionMgr, items, c.policy)
+	items, err = applyNamespaceQuota(srcAdapter, dstAdapter, c.executionMgr, items)
+	if err != nil {
+		return 0, err
+	}
+	var bytes int64
+	items, bytes, err = applyByteBudget(srcAdapter, dstAdapter, c.executionMgr, items, c.policy)
+	if err != nil {
+		return 0, err
+	}
+	sum.estimateBytes(c.policy, bytes)
+	// the tasks denied by the webhook, over the quota or deferred by the byte budget
+	sum.Skipped += created - len(items) - sum.Failed
+	if len(items) == 0 {
+		c.logger.Infof("no tasks of the execution %d need to be submitted, skip", c.executionID)
+		return deletions, nil
+	}
+	c.logger.Debugf("%d tasks of the execution %d to be submitted", len(items), c.executionID)
 
-	return schedule(c.scheduler, c.executionMgr, items)
+	if err = checkDestinationHealth(dstAdapter, c.executionMgr, items, c.policy); err != nil {
+		sum.Failed += len(items)
+		return deletions + len(items), err
+	}
+	n, err := schedule(ctx, sched, c.executionMgr, items, c.policy, sum)
+	return deletions + n, err
+}
+
+// mark the execution whose tasks are all skipped as success in database
+func markExecutionSkipped(mgr execution.Manager, id int64, skipped int, message string) {
+	err := mgr.Update(
+		&models.Execution{
+			ID:         id,
+			Status:     models.ExecutionStatusSucceed,
+			StatusText: message,
+			Total:      skipped,
+			Skipped:    skipped,
+			EndTime:    time.Now(),
+		}, "Status", "StatusText", "Total", "Skipped", "EndTime")
+	if err != nil {
+		log.Errorf("failed to update the execution %d: %v", id, err)
+		return
+	}
+}
+
+// mark the execution as partial success in database, some resources failed to
+// be fetched and the others are skipped
+func markExecutionPartialSuccess(mgr execution.Manager, id int64, fetchFailed, skipped int, message string) {
+	err := mgr.Update(
+		&models.Execution{
+			ID:          id,
+			Status:      models.ExecutionStatusPartialSuccess,
+			StatusText:  message,
+			Total:       fetchFailed + skipped,
+			Skipped:     skipped,
+			FetchFailed: fetchFailed,
+			EndTime:     time.Now(),
+		}, "Status", "StatusText", "Total", "Skipped", "FetchFailed", "EndTime")
+	if err != nil {
+		log.Errorf("failed to update the execution %d: %v", id, err)
+		return
+	}
 }
 
 // mark the execution as success in database
@@ -107,3 +360,27 @@ func markExecutionSuccess(mgr execution.Manager, id int64, message string) {
 		return
 	}
 }
+
+// record the tasks that would be submitted by the flow, nothing is
+// pushed to the destination registry
+func (c *copyFlow) preview(sum *summary, srcAdapter adp.Adapter, srcResources, dstResources []*model.Resource,
+	sampled, total int) error {
+	items, err := preprocess(c.scheduler, srcResources, dstResources)
+	if err != nil {
+		return err
+	}
+	n, err := createDryRunTasks(c.executionMgr, c.executionID, items, c.policy.TagsPerTask)
+	if err != nil {
+		return err
+	}
+	sum.Created += n
+	sum.Previewed += n
+	c.logger.Debugf("%d tasks of the dry run %d recorded", n, c.executionID)
+	if sampled > 0 {
+		if sum.Estimate, err = newEstimate(srcAdapter, items, n, sampled, total); err != nil {
+			return err
+		}
+		c.logger.Infof("the full run of the dry run %d is estimated: %s", c.executionID, sum.Estimate)
+	}
+	return nil
+}
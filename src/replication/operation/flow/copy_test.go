@@ -13,6 +13,8 @@ package flow
 import (
 	"testing"
 
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -20,7 +22,7 @@ import (
 
 func TestRunOfCopyFlow(t *testing.T) {
 	scheduler := &fakedScheduler{}
-	executionMgr := &fakedExecutionManager{}
+	executionMgr := newFakedExecutionManager()
 	policy := &model.Policy{
 		SrcRegistry: &model.Registry{
 			Type: model.RegistryTypeHarbor,
@@ -34,3 +36,93 @@ func TestRunOfCopyFlow(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, 2, n)
 }
+
+func TestRunOfCopyFlowWithTooManyResources(t *testing.T) {
+	executionMgr := newFakedExecutionManager()
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		MaxResources: 1,
+	}
+	// the flow fails without creating any task as 2 resources are fetched
+	flow := NewCopyFlow(executionMgr, &fakedScheduler{}, 1, policy)
+	_, err := flow.Run(nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "more than the max count 1")
+	assert.Equal(t, 0, len(executionMgr.Tasks()))
+}
+
+func TestRunOfCopyFlowWithMissingSrcNamespace(t *testing.T) {
+	scheduler := &fakedScheduler{}
+	executionMgr := newFakedExecutionManager()
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeName,
+				Value: "libary/**",
+			},
+		},
+	}
+	// lenient mode, nothing is replicated
+	flow := NewCopyFlow(executionMgr, scheduler, 1, policy)
+	n, err := flow.Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	// strict mode
+	policy.StrictSrcNamespace = true
+	flow = NewCopyFlow(executionMgr, scheduler, 1, policy)
+	_, err = flow.Run(nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "libary")
+}
+
+func TestRunOfCopyFlowWithDestinationOnlyTags(t *testing.T) {
+	registryType := model.RegistryType("faked-dst-only-tag")
+	// the destination has the tag not present at the source
+	dst := &test.Adapter{
+		Images: []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"latest", "dst-only"},
+				},
+			},
+		},
+	}
+	require.Nil(t, adapter.RegisterFactory(registryType, func(*model.Registry) (adapter.Adapter, error) {
+		return dst, nil
+	}))
+	sched := &fakedRecordingScheduler{}
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: registryType,
+		},
+		Override: true,
+	}
+	_, err := NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.Nil(t, err)
+	// only the source tags are copied, the destination-only tag produces no deletion
+	require.NotEqual(t, 0, len(sched.items))
+	for _, item := range sched.items {
+		assert.False(t, item.DstResource.Deleted)
+		assert.NotContains(t, item.DstResource.Metadata.Vtags, "dst-only")
+	}
+	assert.Equal(t, 0, dst.CallCount("DeleteManifest"))
+}
@@ -15,6 +15,8 @@
 package flow
 
 import (
+	"context"
+
 	"github.com/goharbor/harbor/src/common/utils/log"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/operation/execution"
@@ -27,6 +29,9 @@ type deletionFlow struct {
 	executionMgr execution.Manager
 	scheduler    scheduler.Scheduler
 	resources    []*model.Resource
+	logger       *log.Logger
+	// only record the tasks that would be submitted rather than submitting them
+	dryRun bool
 }
 
 // NewDeletionFlow returns an instance of the delete flow which deletes the resources
@@ -39,30 +44,60 @@ func NewDeletionFlow(executionMgr execution.Manager, scheduler scheduler.Schedul
 		executionID:  executionID,
 		policy:       policy,
 		resources:    resources,
+		logger:       newFlowLogger(policy),
 	}
 }
 
-func (d *deletionFlow) Run(interface{}) (int, error) {
+func (d *deletionFlow) Run(param interface{}) (int, error) {
+	sum := newSummary()
+	n, err := d.run(getContext(param), sum)
+	if d.dryRun && err == nil {
+		markExecutionDryRun(d.executionMgr, d.executionID, sum)
+	}
+	sum.emit(d.executionMgr, d.executionID, err)
+	return n, err
+}
+
+func (d *deletionFlow) run(ctx context.Context, sum *summary) (int, error) {
+	sum.Fetched = len(d.resources)
 	srcResources, err := filterResources(d.resources, d.policy.Filters)
 	if err != nil {
 		return 0, err
 	}
+	sum.Filtered = len(srcResources)
+	d.logger.Debugf("%d of the %d resources left after filtering for the execution %d",
+		len(srcResources), len(d.resources), d.executionID)
 	if len(srcResources) == 0 {
 		markExecutionSuccess(d.executionMgr, d.executionID, "no resources need to be replicated")
-		log.Infof("no resources need to be replicated for the execution %d, skip", d.executionID)
+		d.logger.Infof("no resources need to be replicated for the execution %d, skip", d.executionID)
 		return 0, nil
 	}
 
 	srcResources = assembleSourceResources(srcResources, d.policy)
 	dstResources := assembleDestinationResources(srcResources, d.policy)
+	if err = checkSelfReplication(d.policy, srcResources, dstResources); err != nil {
+		return 0, err
+	}
 
 	items, err := preprocess(d.scheduler, srcResources, dstResources)
 	if err != nil {
 		return 0, err
 	}
-	if err = createTasks(d.executionMgr, d.executionID, items); err != nil {
+	if d.dryRun {
+		n, err := createDryRunTasks(d.executionMgr, d.executionID, items, d.policy.TagsPerTask)
+		if err != nil {
+			return 0, err
+		}
+		sum.Created, sum.Previewed = n, n
+		d.logger.Debugf("%d tasks of the dry run %d recorded", n, d.executionID)
+		return 0, nil
+	}
+	items, err = createTasks(d.executionMgr, d.executionID, items, d.policy.TagsPerTask)
+	if err != nil {
 		return 0, err
 	}
+	sum.Created = len(items)
+	d.logger.Debugf("%d tasks created for the execution %d", len(items), d.executionID)
 
-	return schedule(d.scheduler, d.executionMgr, items)
+	return schedule(ctx, d.scheduler, d.executionMgr, items, d.policy, sum)
 }
@@ -24,7 +24,7 @@ import (
 
 func TestRunOfDeletionFlow(t *testing.T) {
 	scheduler := &fakedScheduler{}
-	executionMgr := &fakedExecutionManager{}
+	executionMgr := newFakedExecutionManager()
 	policy := &model.Policy{
 		SrcRegistry: &model.Registry{
 			Type: model.RegistryTypeHarbor,
@@ -0,0 +1,94 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// NewDryRunCopyFlow returns an instance of the copy flow which only previews the
+// replication: the resources are fetched, filtered and preprocessed as usual, but
+// the tasks are recorded as "WouldCopy"/"WouldDelete" rather than being submitted
+func NewDryRunCopyFlow(executionMgr execution.Manager, scheduler scheduler.Scheduler,
+	executionID int64, policy *model.Policy, resources ...*model.Resource) Flow {
+	flow := NewCopyFlow(executionMgr, scheduler, executionID, policy, resources...).(*copyFlow)
+	flow.dryRun = true
+	return flow
+}
+
+// NewDryRunDeletionFlow returns an instance of the deletion flow which only previews
+// the deletion of the resources on the destination registry
+func NewDryRunDeletionFlow(executionMgr execution.Manager, scheduler scheduler.Scheduler,
+	executionID int64, policy *model.Policy, resources ...*model.Resource) Flow {
+	flow := NewDeletionFlow(executionMgr, scheduler, executionID, policy, resources...).(*deletionFlow)
+	flow.dryRun = true
+	return flow
+}
+
+// record the items which would be submitted as the tasks of the dry run, the items
+// are split by the tags in the same way as createTasks. Returns the count of the recorded tasks
+func createDryRunTasks(mgr execution.Manager, executionID int64, items []*scheduler.ScheduleItem,
+	tagsPerTask int) (int, error) {
+	items = splitItemsByTags(items, tagsPerTask)
+	for _, item := range items {
+		status := models.TaskStatusWouldCopy
+		if item.DstResource.Deleted {
+			status = models.TaskStatusWouldDelete
+		}
+		task := &models.Task{
+			ExecutionID:  executionID,
+			Status:       status,
+			ResourceType: string(item.SrcResource.Type),
+			SrcResource:  getResourceName(item.SrcResource),
+			DstResource:  getResourceName(item.DstResource),
+			Operation:    getOperation(item),
+		}
+		id, err := mgr.CreateTask(task)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create task records for the execution %d: %v", executionID, err)
+		}
+		item.TaskID = id
+		log.Debugf("task record %d for the dry run %d created: %s", id, executionID, status)
+	}
+	return len(items), nil
+}
+
+// mark the execution as a dry run, its status isn't calculated from the tasks
+func markExecutionDryRun(mgr execution.Manager, id int64, sum *summary) {
+	statusText := fmt.Sprintf("dry run: %d tasks would be submitted, %d skipped",
+		sum.Previewed, sum.Skipped)
+	if sum.Estimate != nil {
+		statusText = fmt.Sprintf("%s, %s", statusText, sum.Estimate)
+	}
+	err := mgr.Update(
+		&models.Execution{
+			ID:         id,
+			Status:     models.ExecutionStatusDryRun,
+			StatusText: statusText,
+			Total:      sum.Created,
+			Skipped:    sum.Skipped,
+			EndTime:    time.Now(),
+		}, "Status", "StatusText", "Total", "Skipped", "EndTime")
+	if err != nil {
+		log.Errorf("failed to update the execution %d: %v", id, err)
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDryRunPolicy() *model.Policy {
+	return &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+	}
+}
+
+func TestRunOfDryRunCopyFlow(t *testing.T) {
+	sched := &fakedRecordingScheduler{}
+	mgr := newFakedExecutionManager()
+	n, err := NewDryRunCopyFlow(mgr, sched, 1, newDryRunPolicy()).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, len(sched.items))
+
+	tasks := mgr.Tasks()
+	require.Equal(t, 2, len(tasks))
+	for _, task := range tasks {
+		assert.Equal(t, models.TaskStatusWouldCopy, task.Status)
+		assert.Equal(t, "copy", task.Operation)
+	}
+	execution, err := mgr.Get(1)
+	require.Nil(t, err)
+	assert.Equal(t, models.ExecutionStatusDryRun, execution.Status)
+	assert.Equal(t, 2, execution.Total)
+	assert.Contains(t, execution.StatusText, "2 tasks would be submitted")
+}
+
+func TestRunOfDryRunDeletionFlow(t *testing.T) {
+	sched := &fakedRecordingScheduler{}
+	mgr := newFakedExecutionManager()
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+			Deleted: true,
+		},
+	}
+	n, err := NewDryRunDeletionFlow(mgr, sched, 1, newDryRunPolicy(), resources...).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, len(sched.items))
+
+	tasks := mgr.Tasks()
+	require.Equal(t, 1, len(tasks))
+	assert.Equal(t, models.TaskStatusWouldDelete, tasks[0].Status)
+	assert.Equal(t, "deletion", tasks[0].Operation)
+	execution, err := mgr.Get(1)
+	require.Nil(t, err)
+	assert.Equal(t, models.ExecutionStatusDryRun, execution.Status)
+	assert.Equal(t, 1, execution.Total)
+}
@@ -0,0 +1,40 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// drop the image resources whose repositories have no tag if the policy skips the
+// empty repositories, so that neither the namespaces nor the tasks are created for
+// them on the destination registry. Other resources are kept as they are
+func filterEmptyRepositories(resources []*model.Resource, policy *model.Policy) []*model.Resource {
+	if policy.EmptyRepositories != model.EmptyRepositoriesSkip {
+		return resources
+	}
+	var result []*model.Resource
+	for _, resource := range resources {
+		if resource.Type == model.ResourceTypeImage && !resource.Deleted &&
+			resource.Metadata != nil && len(resource.Metadata.Vtags) == 0 {
+			log.Debugf("the repository %s has no tag, skip", getResourceName(resource))
+			continue
+		}
+		result = append(result, resource)
+	}
+	log.Debug("filter empty repositories completed")
+	return result
+}
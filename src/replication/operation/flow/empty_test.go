@@ -0,0 +1,103 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the repository "library/empty" has no tag
+func newEmptyRepositoryResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/empty",
+				},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+}
+
+func TestFilterEmptyRepositories(t *testing.T) {
+	// include
+	resources := filterEmptyRepositories(newEmptyRepositoryResources(), &model.Policy{})
+	assert.Equal(t, []string{"library/empty", "library/hello-world"}, getResourceNames(resources))
+
+	// skip
+	resources = filterEmptyRepositories(newEmptyRepositoryResources(), &model.Policy{
+		EmptyRepositories: model.EmptyRepositoriesSkip,
+	})
+	assert.Equal(t, []string{"library/hello-world"}, getResourceNames(resources))
+}
+
+func TestRunOfCopyFlowWithEmptyRepositories(t *testing.T) {
+	srcType := model.RegistryType("faked-empty-repository")
+	require.Nil(t, adapter.RegisterFactory(srcType, func(*model.Registry) (adapter.Adapter, error) {
+		return &test.Adapter{Images: newEmptyRepositoryResources()}, nil
+	}))
+	events := []string{}
+	dstType := model.RegistryType("faked-empty-repository-preparing")
+	require.Nil(t, adapter.RegisterFactory(dstType, func(*model.Registry) (adapter.Adapter, error) {
+		return &fakedPreparingAdapter{events: &events}, nil
+	}))
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: srcType,
+		},
+		DestRegistry: &model.Registry{
+			Type: dstType,
+		},
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+		},
+	}
+
+	// include: the namespace is prepared for the empty repository as well
+	sched := &fakedRecordingScheduler{}
+	_, err := NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"prepare library/empty,library/hello-world"}, events)
+	assert.Equal(t, 2, len(sched.items))
+
+	// skip: neither the namespace nor the task is created for the empty repository
+	events = []string{}
+	policy.EmptyRepositories = model.EmptyRepositoriesSkip
+	sched = &fakedRecordingScheduler{}
+	_, err = NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"prepare library/hello-world"}, events)
+	require.Equal(t, 1, len(sched.items))
+	assert.Equal(t, "library/hello-world", sched.items[0].SrcResource.Metadata.Repository.Name)
+}
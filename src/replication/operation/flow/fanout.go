@@ -0,0 +1,127 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+// limit the count of tags processed per repository in one execution. The tags already
+// replicated are skipped, so the excess tags are processed by the subsequent executions.
+// The tags are sorted by their creation time according to the tag order of the policy,
+// if the adapter cannot list the creation time, the order returned by the registry is used
+func limitTagFanOut(srcAdapter, dstAdapter adp.Adapter, srcResources,
+	dstResources []*model.Resource, policy *model.Policy) ([]*model.Resource, []*model.Resource, error) {
+	if policy == nil || policy.MaxTagsPerRepository <= 0 {
+		return srcResources, dstResources, nil
+	}
+	srcRegistry, ok := srcAdapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, nil, fmt.Errorf("the source adapter doesn't implement the ImageRegistry interface")
+	}
+	dstRegistry, ok := dstAdapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, nil, fmt.Errorf("the destination adapter doesn't implement the ImageRegistry interface")
+	}
+	lister, _ := srcAdapter.(adp.TagCreationTimeLister)
+	// the unmodified tags have been filtered out if the "modified" filter is enabled
+	compare := !isModifiedFilterEnabled(policy)
+
+	var srcResult, dstResult []*model.Resource
+	for i, srcResource := range srcResources {
+		dstResource := dstResources[i]
+		if srcResource.Type != model.ResourceTypeImage || srcResource.Deleted ||
+			len(srcResource.Metadata.Vtags) == 0 {
+			srcResult = append(srcResult, srcResource)
+			dstResult = append(dstResult, dstResource)
+			continue
+		}
+		srcTags, dstTags := srcResource.Metadata.Vtags, dstResource.Metadata.Vtags
+		if compare {
+			var err error
+			srcTags, dstTags, err = getModifiedTags(srcRegistry, dstRegistry, srcResource, dstResource)
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(srcTags) == 0 {
+				continue
+			}
+		}
+		if len(srcTags) > policy.MaxTagsPerRepository {
+			repository := srcResource.Metadata.Repository.Name
+			if lister != nil {
+				times, err := lister.ListTagCreationTimes(repository)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to list the creation time of tags under %s: %v", repository, err)
+				}
+				srcTags, dstTags = sortTagsByCreationTime(srcTags, dstTags, times, policy.TagOrder)
+			}
+			log.Debugf("%d tags of %s are deferred to the subsequent executions",
+				len(srcTags)-policy.MaxTagsPerRepository, repository)
+			srcTags = srcTags[:policy.MaxTagsPerRepository]
+			dstTags = dstTags[:policy.MaxTagsPerRepository]
+		}
+		// NOTE: the source and destination resources share the same "Vtags", set them separately
+		srcResource.Metadata.Vtags = srcTags
+		dstResource.Metadata.Vtags = dstTags
+		srcResult = append(srcResult, srcResource)
+		dstResult = append(dstResult, dstResource)
+	}
+	log.Debug("limit the tag fan-out completed")
+	return srcResult, dstResult, nil
+}
+
+// sort the source tags and the corresponding destination tags by the creation time of
+// the source tags, the tags without creation time are treated as the oldest ones
+func sortTagsByCreationTime(srcTags, dstTags []string, times map[string]time.Time,
+	order string) ([]string, []string) {
+	indexes := make([]int, len(srcTags))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		ti, tj := srcTags[indexes[i]], srcTags[indexes[j]]
+		if order == model.TagOrderNewestFirst {
+			return isNewerTag(ti, tj, times)
+		}
+		return isNewerTag(tj, ti, times)
+	})
+	sortedSrcTags := make([]string, len(srcTags))
+	sortedDstTags := make([]string, len(dstTags))
+	for i, index := range indexes {
+		sortedSrcTags[i] = srcTags[index]
+		sortedDstTags[i] = dstTags[index]
+	}
+	return sortedSrcTags, sortedDstTags
+}
+
+// returns whether the tag "a" is newer than "b" according to their creation time, the tags
+// without creation time are treated as the oldest ones. The tags created at the same time are
+// ordered by semver and then lexically, so the selection of all the time-based features is
+// deterministic even though many tags share the same creation time, e.g. the bulk imports
+func isNewerTag(a, b string, times map[string]time.Time) bool {
+	ta, tb := times[a], times[b]
+	if !ta.Equal(tb) {
+		return ta.After(tb)
+	}
+	return util.IsNewerVersion(a, b)
+}
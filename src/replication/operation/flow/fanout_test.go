@@ -0,0 +1,161 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the tag "n" is created n hours after the base time
+func newFanOutAdapter() *fakedTagTimeAdapter {
+	base := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := map[string]time.Time{}
+	for i := 0; i < 50; i++ {
+		times[fmt.Sprint(i)] = base.Add(time.Duration(i) * time.Hour)
+	}
+	return &fakedTagTimeAdapter{
+		times: map[string]map[string]time.Time{
+			"*": times,
+		},
+	}
+}
+
+// the source repository has 50 tags which are listed in a shuffled order
+func newFanOutResources() []*model.Resource {
+	tags := []string{}
+	for i := 0; i < 50; i++ {
+		tags = append(tags, fmt.Sprint((i*7)%50))
+	}
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: tags,
+			},
+		},
+	}
+}
+
+func TestLimitTagFanOut(t *testing.T) {
+	src := newFanOutAdapter()
+	for i := 0; i < 50; i++ {
+		src.AddTag("library/hello-world", fmt.Sprint(i), fmt.Sprintf("sha256:%d", i))
+	}
+	dst := &test.Adapter{}
+	policy := &model.Policy{
+		MaxTagsPerRepository: 10,
+	}
+
+	// the remaining tags are processed by the subsequent runs, 10 tags per run
+	for run := 0; run < 5; run++ {
+		srcResources := newFanOutResources()
+		dstResources := assembleDestinationResources(srcResources, policy)
+		srcResources, dstResources, err := limitTagFanOut(src, dst, srcResources, dstResources, policy)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(srcResources))
+		require.Equal(t, 10, len(srcResources[0].Metadata.Vtags))
+		require.Equal(t, 10, len(dstResources[0].Metadata.Vtags))
+		// the oldest ones are processed first
+		expected := []string{}
+		for i := 0; i < 10; i++ {
+			expected = append(expected, fmt.Sprint(run*10+i))
+		}
+		assert.ElementsMatch(t, expected, srcResources[0].Metadata.Vtags)
+		for i, tag := range srcResources[0].Metadata.Vtags {
+			// replicate the tag
+			dst.AddTag("library/hello-world", dstResources[0].Metadata.Vtags[i], "sha256:"+tag)
+		}
+	}
+
+	// all the tags have been replicated
+	srcResources := newFanOutResources()
+	dstResources := assembleDestinationResources(srcResources, policy)
+	srcResources, _, err := limitTagFanOut(src, dst, srcResources, dstResources, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(srcResources))
+}
+
+func TestLimitTagFanOutNewestFirst(t *testing.T) {
+	src := newFanOutAdapter()
+	dst := &test.Adapter{}
+	policy := &model.Policy{
+		MaxTagsPerRepository: 10,
+		TagOrder:             model.TagOrderNewestFirst,
+	}
+	srcResources := newFanOutResources()
+	dstResources := assembleDestinationResources(srcResources, policy)
+	srcResources, _, err := limitTagFanOut(src, dst, srcResources, dstResources, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(srcResources))
+	assert.Equal(t, []string{"49", "48", "47", "46", "45", "44", "43", "42", "41", "40"},
+		srcResources[0].Metadata.Vtags)
+}
+
+func TestLimitTagFanOutWithoutCreationTime(t *testing.T) {
+	src := &test.Adapter{}
+	dst := &test.Adapter{}
+
+	// no limit
+	policy := &model.Policy{}
+	srcResources := newFanOutResources()
+	dstResources := assembleDestinationResources(srcResources, policy)
+	srcResources, _, err := limitTagFanOut(src, dst, srcResources, dstResources, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(srcResources))
+	assert.Equal(t, 50, len(srcResources[0].Metadata.Vtags))
+
+	// the order returned by the registry is used
+	policy.MaxTagsPerRepository = 5
+	srcResources = newFanOutResources()
+	dstResources = assembleDestinationResources(srcResources, policy)
+	srcResources, _, err = limitTagFanOut(src, dst, srcResources, dstResources, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(srcResources))
+	assert.Equal(t, []string{"0", "7", "14", "21", "28"}, srcResources[0].Metadata.Vtags)
+}
+
+func TestSortTagsByCreationTimeWithTies(t *testing.T) {
+	bulk := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := map[string]time.Time{
+		"1.10.0": bulk,
+		"1.9.0":  bulk,
+		"v1.9.0": bulk,
+		"stable": bulk,
+		"latest": bulk,
+		"2.0.0":  bulk.Add(-time.Hour),
+	}
+	// the selection doesn't depend on the order returned by the registry
+	for _, tags := range [][]string{
+		{"latest", "1.9.0", "2.0.0", "stable", "v1.9.0", "1.10.0"},
+		{"1.10.0", "v1.9.0", "stable", "2.0.0", "1.9.0", "latest"},
+	} {
+		sorted, dst := sortTagsByCreationTime(tags, tags, times, model.TagOrderNewestFirst)
+		assert.Equal(t, []string{"1.10.0", "v1.9.0", "1.9.0", "stable", "latest", "2.0.0"}, sorted)
+		assert.Equal(t, sorted, dst)
+
+		sorted, _ = sortTagsByCreationTime(tags, tags, times, model.TagOrderOldestFirst)
+		assert.Equal(t, []string{"2.0.0", "latest", "stable", "1.9.0", "v1.9.0", "1.10.0"}, sorted)
+	}
+}
@@ -0,0 +1,373 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+// DefaultFetchConcurrency is the count of the resource types and namespaces fetched
+// in parallel from the source registry, it's used if the policy doesn't specify one
+var DefaultFetchConcurrency = 4
+
+// DefaultFetchTimeout is the max duration of fetching the resources of one resource type
+// and namespace from the source registry, it's used if the policy doesn't specify one
+var DefaultFetchTimeout = 10 * time.Minute
+
+// DefaultMaxResources is the max count of the resources fetched from the source registry
+// by one execution, it's used if the policy doesn't specify one
+var DefaultMaxResources = 100000
+
+// the resources of one resource type(and one namespace if the name filter
+// specifies several namespaces) fetched from the source registry
+type fetchUnit struct {
+	name         string
+	resourceType model.ResourceType
+	fetch        func(ctx context.Context) ([]*model.Resource, error)
+}
+
+// the unit failed to be fetched, it's returned rather than failing the whole
+// fetching when the policy fetches the resources in best effort
+type fetchFailure struct {
+	name         string
+	resourceType model.ResourceType
+	err          error
+}
+
+func (f *fetchFailure) String() string {
+	return fmt.Sprintf("failed to fetch %s: %v", f.name, f.err)
+}
+
+func getFetchConcurrency(policy *model.Policy) int {
+	if policy != nil && policy.FetchConcurrency > 0 {
+		return policy.FetchConcurrency
+	}
+	if DefaultFetchConcurrency > 0 {
+		return DefaultFetchConcurrency
+	}
+	return 1
+}
+
+func getFetchTimeout(policy *model.Policy) time.Duration {
+	if policy != nil && policy.FetchTimeout > 0 {
+		return time.Duration(policy.FetchTimeout) * time.Second
+	}
+	return DefaultFetchTimeout
+}
+
+func getMaxResources(policy *model.Policy) int {
+	if policy != nil && policy.MaxResources > 0 {
+		return policy.MaxResources
+	}
+	return DefaultMaxResources
+}
+
+// fail fast if more resources than the max count are fetched, each of them results in
+// at least one task, so the tasks of the filters matching far more than expected(e.g.
+// the whole public registry) aren't created. No limit if the max count <= 0
+func checkResourceCount(resources []*model.Resource, policy *model.Policy) error {
+	max := getMaxResources(policy)
+	if max <= 0 || len(resources) <= max {
+		return nil
+	}
+	return fmt.Errorf("%d resources are fetched, more than the max count %d of one execution, narrow down the filters or raise the max resources of the policy",
+		len(resources), max)
+}
+
+// split the fetching into units per resource type and namespace, the default namespace
+// of the source registry is fetched by an extra unit if it isn't empty
+func getFetchUnits(adapter adp.Adapter, policy *model.Policy, resTypes []model.ResourceType,
+	defaultNamespace string, mark *watermark) ([]*fetchUnit, error) {
+	var units []*fetchUnit
+	// convert the adapter to different interfaces according to its required resource types
+	for _, typ := range resTypes {
+		// only the filters applying to the resource type are passed to the adapter, the
+		// other filters are applied by the flow itself in "filterResources"
+		var filters []*model.Filter
+		for _, filter := range policy.Filters {
+			switch filter.Type {
+			case model.FilterTypeName, model.FilterTypeTag, model.FilterTypeLabel:
+				// the tag filters are ignored when the tags are pinned by the digests
+				if filter.Type == model.FilterTypeTag && hasDigestFilter(policy.Filters, typ) {
+					continue
+				}
+				// the adapters only keep the matched resources as globs, the regular
+				// expressions, the semver ranges and the exclusions are applied by the flow
+				if filter.AppliesTo(typ) && filter.IsGlob() && !filter.IsExclusion() {
+					filters = append(filters, filter)
+				}
+			}
+		}
+		var fetch func(context.Context, []*model.Filter) ([]*model.Resource, error)
+		if typ == model.ResourceTypeImage {
+			// images
+			reg, ok := adapter.(adp.ImageRegistry)
+			if !ok {
+				return nil, fmt.Errorf("the adapter doesn't implement the ImageRegistry interface")
+			}
+			fetch = reg.FetchImages
+			if fetchesIncrementally(adapter, mark) {
+				incremental := adapter.(adp.IncrementalImageRegistry)
+				fetch = func(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+					return incremental.FetchImagesPushedSince(ctx, filters, mark.time)
+				}
+			}
+		} else if typ == model.ResourceTypeChart {
+			// charts
+			reg, ok := adapter.(adp.ChartRegistry)
+			if !ok {
+				return nil, fmt.Errorf("the adapter doesn't implement the ChartRegistry interface")
+			}
+			fetch = reg.FetchCharts
+		} else {
+			return nil, fmt.Errorf("unsupported resource type %s", typ)
+		}
+		for _, nsFilters := range scopeNamespaceFilters(splitByNamespace(filters), policy) {
+			name := string(typ)
+			if len(nsFilters.namespace) > 0 {
+				name = fmt.Sprintf("%s of the namespace %s", typ, nsFilters.namespace)
+			}
+			filters := nsFilters.filters
+			units = append(units, &fetchUnit{
+				name:         name,
+				resourceType: typ,
+				fetch: func(ctx context.Context) ([]*model.Resource, error) {
+					return fetch(ctx, filters)
+				},
+			})
+		}
+		if len(defaultNamespace) > 0 && inCredentialScope(policy, defaultNamespace) {
+			filters := withNamespace(filters, defaultNamespace)
+			units = append(units, &fetchUnit{
+				name:         fmt.Sprintf("%s of the default namespace %s", typ, defaultNamespace),
+				resourceType: typ,
+				fetch: func(ctx context.Context) ([]*model.Resource, error) {
+					return fetch(ctx, filters)
+				},
+			})
+		}
+	}
+	return units, nil
+}
+
+// record the units failed to be fetched as the tasks of the execution, so that
+// the execution finishes as partial success rather than success
+func createFetchFailedTasks(mgr execution.Manager, executionID int64, failures []*fetchFailure) error {
+	for _, failure := range failures {
+		_, err := mgr.CreateTask(&models.Task{
+			ExecutionID:  executionID,
+			Status:       models.TaskStatusFetchFailed,
+			ResourceType: string(failure.resourceType),
+			SrcResource:  failure.name,
+			Operation:    "fetch",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create the task record of the fetching failure for the execution %d: %v",
+				executionID, err)
+		}
+	}
+	return nil
+}
+
+// get the default namespace of the source registry which should be included by the
+// fetching. It's empty unless the policy includes the default namespace, no namespace
+// is specified by the name filters and the adapter provides the default namespace
+func getDefaultSrcNamespace(adapter adp.Adapter, policy *model.Policy) string {
+	if !policy.IncludeDefaultSrcNamespace || len(getSrcNamespaces(policy)) > 0 {
+		return ""
+	}
+	provider, ok := adapter.(adp.DefaultNamespaceProvider)
+	if !ok {
+		log.Warning("the source adapter doesn't provide the default namespace, skip including it")
+		return ""
+	}
+	return provider.DefaultNamespace()
+}
+
+// replace the name filters passed to the adapter with the one fetching everything under
+// the namespace, the name filters are still applied by the flow in "filterResources"
+func withNamespace(filters []*model.Filter, namespace string) []*model.Filter {
+	result := []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: namespace + "/**",
+		},
+	}
+	for _, filter := range filters {
+		if filter.Type != model.FilterTypeName {
+			result = append(result, filter)
+		}
+	}
+	return result
+}
+
+// remove the resources of the same type and repository fetched more than once,
+// the first one is kept
+func dedupResources(resources []*model.Resource) []*model.Resource {
+	seen := map[string]struct{}{}
+	var result []*model.Resource
+	for _, resource := range resources {
+		if resource.Metadata == nil || resource.Metadata.Repository == nil {
+			result = append(result, resource)
+			continue
+		}
+		key := string(resource.Type) + ":" + resource.Metadata.Repository.Name
+		if _, exist := seen[key]; exist {
+			continue
+		}
+		seen[key] = struct{}{}
+		result = append(result, resource)
+	}
+	return result
+}
+
+type namespaceFilters struct {
+	namespace string
+	filters   []*model.Filter
+}
+
+// split the filters per namespace when the only name filter specifies several namespaces,
+// e.g. "{library,harbor}/**" -> "library/**" and "harbor/**", so that the namespaces can
+// be fetched in parallel. The filters are returned as they are otherwise
+func splitByNamespace(filters []*model.Filter) []*namespaceFilters {
+	index := -1
+	for i, filter := range filters {
+		if filter.Type != model.FilterTypeName {
+			continue
+		}
+		// more than one name filter
+		if index >= 0 {
+			index = -1
+			break
+		}
+		index = i
+	}
+	if index < 0 {
+		return []*namespaceFilters{{filters: filters}}
+	}
+	pattern, _ := filters[index].Value.(string)
+	components := strings.SplitN(pattern, "/", 2)
+	if len(components) < 2 {
+		return []*namespaceFilters{{filters: filters}}
+	}
+	namespaces, ok := util.IsSpecificPathComponent(components[0])
+	if !ok || len(namespaces) < 2 {
+		return []*namespaceFilters{{filters: filters}}
+	}
+	var result []*namespaceFilters
+	for _, namespace := range namespaces {
+		nameFilter := *filters[index]
+		nameFilter.Value = namespace + "/" + components[1]
+		nsFilters := append([]*model.Filter{}, filters...)
+		nsFilters[index] = &nameFilter
+		result = append(result, &namespaceFilters{
+			namespace: namespace,
+			filters:   nsFilters,
+		})
+	}
+	return result
+}
+
+// run the units with the bounded concurrency. The resources are returned in the
+// order of the units no matter which one completes first, and the errors of all
+// the failed units are aggregated. Each unit is aborted and fails if it doesn't
+// complete within the timeout. When fetching in best effort, the resources of
+// the succeeded units are returned along with the failed ones and the error is
+// only returned if all the units failed
+func fetchConcurrently(ctx context.Context, units []*fetchUnit, concurrency int, timeout time.Duration,
+	bestEffort bool) ([]*model.Resource, []*fetchFailure, error) {
+	results := make([][]*model.Resource, len(units))
+	errs := make([]error, len(units))
+	sem := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
+	for i, unit := range units {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, unit *fetchUnit) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			results[i], errs[i] = fetchWithTimeout(ctx, unit, timeout)
+			if errs[i] == nil {
+				log.Debugf("fetch %s completed", unit.name)
+			}
+		}(i, unit)
+	}
+	wg.Wait()
+
+	var failures []*fetchFailure
+	var messages []string
+	for i, err := range errs {
+		if err != nil {
+			failure := &fetchFailure{
+				name:         units[i].name,
+				resourceType: units[i].resourceType,
+				err:          err,
+			}
+			failures = append(failures, failure)
+			messages = append(messages, failure.String())
+		}
+	}
+	if len(messages) > 0 && (!bestEffort || len(failures) == len(units)) {
+		return nil, nil, fmt.Errorf("%s", strings.Join(messages, "; "))
+	}
+	for _, message := range messages {
+		log.Warningf("%s, continue with the resources fetched by the other units", message)
+	}
+	resources := []*model.Resource{}
+	for _, res := range results {
+		resources = append(resources, res...)
+	}
+	return resources, failures, nil
+}
+
+// run the unit and wait for it at most the timeout. The context passed to the unit
+// is done once timed out, the adapters honoring it stop fetching and the others are
+// left running in the background as there is no way to stop them
+func fetchWithTimeout(ctx context.Context, unit *fetchUnit, timeout time.Duration) ([]*model.Resource, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		resources []*model.Resource
+		err       error
+	}
+	// buffered, so the abandoned unit doesn't block forever when it returns
+	done := make(chan *result, 1)
+	go func() {
+		resources, err := unit.fetch(ctx)
+		done <- &result{resources, err}
+	}()
+	select {
+	case r := <-done:
+		return r.resources, r.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("timed out after %v", timeout)
+		}
+		return nil, ctx.Err()
+	}
+}
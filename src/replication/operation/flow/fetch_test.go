@@ -0,0 +1,418 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitByNamespace(t *testing.T) {
+	tagFilter := &model.Filter{
+		Type:  model.FilterTypeTag,
+		Value: "v*",
+	}
+	// several namespaces
+	nameFilter := &model.Filter{
+		Type:  model.FilterTypeName,
+		Value: "{library,harbor}/**",
+	}
+	result := splitByNamespace([]*model.Filter{nameFilter, tagFilter})
+	require.Equal(t, 2, len(result))
+	assert.Equal(t, "library", result[0].namespace)
+	assert.Equal(t, "library/**", result[0].filters[0].Value)
+	assert.Equal(t, tagFilter, result[0].filters[1])
+	assert.Equal(t, "harbor", result[1].namespace)
+	assert.Equal(t, "harbor/**", result[1].filters[0].Value)
+	// the origin filter isn't changed
+	assert.Equal(t, "{library,harbor}/**", nameFilter.Value)
+
+	// only one namespace or no specific namespace
+	for _, pattern := range []string{"library/**", "**", "lib*/**"} {
+		filters := []*model.Filter{
+			{
+				Type:  model.FilterTypeName,
+				Value: pattern,
+			},
+		}
+		result = splitByNamespace(filters)
+		require.Equal(t, 1, len(result))
+		assert.Equal(t, filters, result[0].filters)
+	}
+
+	// more than one name filter
+	filters := []*model.Filter{nameFilter, {Type: model.FilterTypeName, Value: "library/*"}}
+	result = splitByNamespace(filters)
+	require.Equal(t, 1, len(result))
+	assert.Equal(t, filters, result[0].filters)
+}
+
+func TestFetchConcurrently(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	var units []*fetchUnit
+	for i := 0; i < 10; i++ {
+		i := i
+		units = append(units, &fetchUnit{
+			name: fmt.Sprintf("unit%d", i),
+			fetch: func(context.Context) ([]*model.Resource, error) {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+				// the former units complete later
+				time.Sleep(time.Duration(10-i) * time.Millisecond)
+				mu.Lock()
+				running--
+				mu.Unlock()
+				return []*model.Resource{
+					{
+						Metadata: &model.ResourceMetadata{
+							Repository: &model.Repository{
+								Name: fmt.Sprintf("library/image%d", i),
+							},
+						},
+					},
+				}, nil
+			},
+		})
+	}
+	resources, _, err := fetchConcurrently(context.Background(), units, 3, time.Minute, false)
+	require.Nil(t, err)
+	require.Equal(t, 10, len(resources))
+	// the order of the units is kept
+	for i, resource := range resources {
+		assert.Equal(t, fmt.Sprintf("library/image%d", i), resource.Metadata.Repository.Name)
+	}
+	assert.True(t, maxRunning <= 3)
+
+	// the errors of all the failed units are returned
+	units[2].fetch = func(context.Context) ([]*model.Resource, error) {
+		return nil, errors.New("error2")
+	}
+	units[7].fetch = func(context.Context) ([]*model.Resource, error) {
+		return nil, errors.New("error7")
+	}
+	_, _, err = fetchConcurrently(context.Background(), units, 3, time.Minute, false)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch unit2: error2")
+	assert.Contains(t, err.Error(), "failed to fetch unit7: error7")
+
+	// the resources of the succeeded units are returned along with the failed ones
+	resources, failures, err := fetchConcurrently(context.Background(), units, 3, time.Minute, true)
+	require.Nil(t, err)
+	assert.Equal(t, 8, len(resources))
+	require.Equal(t, 2, len(failures))
+	assert.Equal(t, "unit2", failures[0].name)
+	assert.Equal(t, "failed to fetch unit7: error7", failures[1].String())
+
+	// fails if all the units failed
+	_, _, err = fetchConcurrently(context.Background(), units[2:3], 3, time.Minute, true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to fetch unit2: error2")
+}
+
+func TestFetchWithTimeout(t *testing.T) {
+	units := []*fetchUnit{
+		{
+			name: "completed",
+			fetch: func(context.Context) ([]*model.Resource, error) {
+				return []*model.Resource{{}}, nil
+			},
+		},
+		// honors the context
+		{
+			name: "aborted",
+			fetch: func(ctx context.Context) ([]*model.Resource, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+		},
+		// ignores the context and hangs
+		{
+			name: "stuck",
+			fetch: func(context.Context) ([]*model.Resource, error) {
+				time.Sleep(time.Hour)
+				return nil, nil
+			},
+		},
+	}
+	resources, failures, err := fetchConcurrently(context.Background(), units, 3, 10*time.Millisecond, true)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(resources))
+	require.Equal(t, 2, len(failures))
+	assert.Equal(t, "failed to fetch aborted: timed out after 10ms", failures[0].String())
+	assert.Equal(t, "failed to fetch stuck: timed out after 10ms", failures[1].String())
+
+	// the cancellation of the parent context aborts the fetching as well
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = fetchWithTimeout(ctx, units[2], time.Hour)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestGetMaxResources(t *testing.T) {
+	assert.Equal(t, DefaultMaxResources, getMaxResources(nil))
+	assert.Equal(t, DefaultMaxResources, getMaxResources(&model.Policy{}))
+	assert.Equal(t, 10, getMaxResources(&model.Policy{MaxResources: 10}))
+}
+
+func TestGetFetchTimeout(t *testing.T) {
+	assert.Equal(t, DefaultFetchTimeout, getFetchTimeout(nil))
+	assert.Equal(t, DefaultFetchTimeout, getFetchTimeout(&model.Policy{}))
+	assert.Equal(t, 30*time.Second, getFetchTimeout(&model.Policy{FetchTimeout: 30}))
+}
+
+func TestCheckResourceCount(t *testing.T) {
+	resources := []*model.Resource{{}, {}, {}}
+	assert.Nil(t, checkResourceCount(resources, &model.Policy{}))
+	assert.Nil(t, checkResourceCount(resources, &model.Policy{MaxResources: 3}))
+	err := checkResourceCount(resources, &model.Policy{MaxResources: 2})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "3 resources are fetched")
+
+	// no limit if the default one is disabled
+	max := DefaultMaxResources
+	defer func() {
+		DefaultMaxResources = max
+	}()
+	DefaultMaxResources = 0
+	assert.Nil(t, checkResourceCount(resources, nil))
+	DefaultMaxResources = 2
+	assert.NotNil(t, checkResourceCount(resources, nil))
+}
+
+func TestCreateFetchFailedTasks(t *testing.T) {
+	mgr := newFakedExecutionManager()
+	err := createFetchFailedTasks(mgr, 1, []*fetchFailure{
+		{
+			name:         "image of the namespace library",
+			resourceType: model.ResourceTypeImage,
+			err:          errors.New("error"),
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(mgr.Tasks()))
+	assert.Equal(t, int64(1), mgr.Tasks()[0].ExecutionID)
+	assert.Equal(t, models.TaskStatusFetchFailed, mgr.Tasks()[0].Status)
+	assert.Equal(t, string(model.ResourceTypeImage), mgr.Tasks()[0].ResourceType)
+	assert.Equal(t, "image of the namespace library", mgr.Tasks()[0].SrcResource)
+	assert.Equal(t, "fetch", mgr.Tasks()[0].Operation)
+
+	markExecutionPartialSuccess(mgr, 1, 1, 2, "no resources are modified")
+	execution, err := mgr.Get(1)
+	require.Nil(t, err)
+	assert.Equal(t, models.ExecutionStatusPartialSuccess, execution.Status)
+	assert.Equal(t, 3, execution.Total)
+	assert.Equal(t, 2, execution.Skipped)
+	assert.Equal(t, 1, execution.FetchFailed)
+}
+
+// the adapter returns one image for every namespace specified by the name filter and
+// records the namespaces fetched. The namespace "failure" is unavailable and the ones
+// not in the "granted" are forbidden if it's set
+type fakedNamespaceFetchingAdapter struct {
+	test.Adapter
+	granted map[string]bool
+	mu      sync.Mutex
+	fetched []string
+}
+
+func (f *fakedNamespaceFetchingAdapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	for _, filter := range filters {
+		if filter.Type != model.FilterTypeName {
+			continue
+		}
+		namespace, _ := util.ParseRepository(filter.Value.(string))
+		f.mu.Lock()
+		f.fetched = append(f.fetched, namespace)
+		f.mu.Unlock()
+		if namespace == "failure" {
+			return nil, errors.New("the namespace is unavailable")
+		}
+		if f.granted != nil && !f.granted[namespace] {
+			return nil, fmt.Errorf("403 forbidden: %s", namespace)
+		}
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: namespace + "/hello-world",
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+		}, nil
+	}
+	return nil, nil
+}
+
+func TestFetchResourcesByNamespace(t *testing.T) {
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+			{
+				Type:  model.FilterTypeName,
+				Value: "{ns1,ns2,ns3,ns4,ns5}/**",
+			},
+		},
+		FetchConcurrency: 2,
+	}
+	resources, _, err := fetchResources(context.Background(), &fakedNamespaceFetchingAdapter{}, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ns1/hello-world", "ns2/hello-world", "ns3/hello-world",
+		"ns4/hello-world", "ns5/hello-world"}, getResourceNames(resources))
+
+	// one failing namespace fails the fetching
+	policy.Filters[1].Value = "{ns1,failure}/**"
+	_, _, err = fetchResources(context.Background(), &fakedNamespaceFetchingAdapter{}, policy, nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "namespace failure")
+}
+
+// the Docker Hub-style adapter only returns the images under the namespaces of the
+// user("user/app") unless the default namespace "library" is specified
+type fakedDefaultNamespaceAdapter struct {
+	test.Adapter
+}
+
+func (f *fakedDefaultNamespaceAdapter) DefaultNamespace() string {
+	return "library"
+}
+
+func (f *fakedDefaultNamespaceAdapter) FetchImages(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
+	name := "user/app"
+	for _, filter := range filters {
+		if filter.Type == model.FilterTypeName && filter.Value == "library/**" {
+			name = "library/nginx"
+		}
+	}
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}, nil
+}
+
+func TestFetchResourcesWithDefaultNamespace(t *testing.T) {
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+		},
+	}
+	// not included
+	resources, _, err := fetchResources(context.Background(), &fakedDefaultNamespaceAdapter{}, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"user/app"}, getResourceNames(resources))
+
+	// the default namespace is included when no namespace is specified
+	policy.IncludeDefaultSrcNamespace = true
+	resources, _, err = fetchResources(context.Background(), &fakedDefaultNamespaceAdapter{}, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"user/app", "library/nginx"}, getResourceNames(resources))
+
+	// the name filter without the specific namespace
+	policy.Filters = append(policy.Filters, &model.Filter{
+		Type:  model.FilterTypeName,
+		Value: "*/nginx",
+	})
+	resources, _, err = fetchResources(context.Background(), &fakedDefaultNamespaceAdapter{}, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"user/app", "library/nginx"}, getResourceNames(resources))
+
+	// the namespace is specified
+	policy.Filters[1].Value = "user/**"
+	resources, _, err = fetchResources(context.Background(), &fakedDefaultNamespaceAdapter{}, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"user/app"}, getResourceNames(resources))
+
+	// the adapter doesn't provide the default namespace
+	policy.Filters = policy.Filters[:1]
+	resources, _, err = fetchResources(context.Background(), &fakedNamespaceFetchingAdapter{}, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(resources))
+}
+
+func TestWithNamespace(t *testing.T) {
+	tagFilter := &model.Filter{
+		Type:  model.FilterTypeTag,
+		Value: "v*",
+	}
+	filters := withNamespace([]*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "*/nginx",
+		},
+		tagFilter,
+	}, "library")
+	require.Equal(t, 2, len(filters))
+	assert.Equal(t, model.FilterTypeName, filters[0].Type)
+	assert.Equal(t, "library/**", filters[0].Value)
+	assert.Equal(t, tagFilter, filters[1])
+}
+
+func TestDedupResources(t *testing.T) {
+	newResource := func(typ model.ResourceType, name string) *model.Resource {
+		return &model.Resource{
+			Type: typ,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+			},
+		}
+	}
+	resources := dedupResources([]*model.Resource{
+		newResource(model.ResourceTypeImage, "library/nginx"),
+		newResource(model.ResourceTypeChart, "library/nginx"),
+		newResource(model.ResourceTypeImage, "library/nginx"),
+		newResource(model.ResourceTypeImage, "user/app"),
+	})
+	require.Equal(t, 3, len(resources))
+	assert.Equal(t, model.ResourceTypeChart, resources[1].Type)
+	assert.Equal(t, "user/app", resources[2].Metadata.Repository.Name)
+}
+
+func TestGetFetchConcurrency(t *testing.T) {
+	assert.Equal(t, DefaultFetchConcurrency, getFetchConcurrency(nil))
+	assert.Equal(t, DefaultFetchConcurrency, getFetchConcurrency(&model.Policy{}))
+	assert.Equal(t, 8, getFetchConcurrency(&model.Policy{FetchConcurrency: 8}))
+}
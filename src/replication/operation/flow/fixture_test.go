@@ -0,0 +1,98 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/adapter/fixture"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// records the items submitted
+type fakedRecordingScheduler struct {
+	fakedScheduler
+	items []*scheduler.ScheduleItem
+}
+
+func (f *fakedRecordingScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	f.items = append(f.items, items...)
+	return f.fakedScheduler.Schedule(items)
+}
+
+// run the copy flow against the registries of the type and returns the submitted
+// items, the registries of the resources are dropped as their types differ
+func runCopyFlowAgainst(t *testing.T, registryType model.RegistryType) []*scheduler.ScheduleItem {
+	sched := &fakedRecordingScheduler{}
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: registryType,
+		},
+		DestRegistry: &model.Registry{
+			Type: registryType,
+		},
+		DestNamespace: "mirror",
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeName,
+				Value: "library/**",
+			},
+		},
+		DestinationHealthGate: true,
+	}
+	n, err := NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.Nil(t, err)
+	require.Equal(t, n, len(sched.items))
+	for _, item := range sched.items {
+		item.SrcResource.Registry, item.DstResource.Registry = nil, nil
+	}
+	return sched.items
+}
+
+func TestReplayCopyFlow(t *testing.T) {
+	recorder := fixture.NewRecorder(newFakedAdapter())
+	require.Nil(t, adapter.RegisterFactory("faked-recording", recorder.Factory()))
+	recorded := runCopyFlowAgainst(t, "faked-recording")
+	require.Equal(t, 2, len(recorded))
+
+	buf := &bytes.Buffer{}
+	require.Nil(t, recorder.Fixture().Save(buf))
+	fx, err := fixture.Load(buf)
+	require.Nil(t, err)
+
+	// the recorded run replays identically
+	replayer := fixture.NewReplayer(fx)
+	require.Nil(t, adapter.RegisterFactory("faked-replaying", replayer.Factory()))
+	assert.Equal(t, recorded, runCopyFlowAgainst(t, "faked-replaying"))
+	assert.Equal(t, recorded, runCopyFlowAgainst(t, "faked-replaying"))
+
+	// the run diverging from the recorded one fails as the interactions aren't recorded
+	require.Nil(t, adapter.RegisterFactory("faked-empty", fixture.NewReplayer(&fixture.Fixture{}).Factory()))
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: "faked-empty",
+		},
+		DestRegistry: &model.Registry{
+			Type: "faked-empty",
+		},
+	}
+	_, err = NewCopyFlow(newFakedExecutionManager(), &fakedScheduler{}, 1, policy).Run(nil)
+	assert.NotNil(t, err)
+}
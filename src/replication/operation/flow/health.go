@@ -0,0 +1,67 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// check the health of the registry, the error is returned if the health check fails or
+// the registry isn't healthy
+func checkRegistryHealth(adapter adp.Adapter) error {
+	status, err := adapter.HealthCheck()
+	if err != nil {
+		return fmt.Errorf("failed to check the health: %v", err)
+	}
+	if status != model.Healthy {
+		return fmt.Errorf("the registry is %s", status)
+	}
+	return nil
+}
+
+// check the health of the destination registry before submitting the items if the health
+// gate of the policy is enabled. If the destination registry isn't healthy(unreachable,
+// unauthorized, etc.), the tasks of the items are marked as failed and the error is returned,
+// so the flow is aborted before submitting any task that would fail anyway
+func checkDestinationHealth(adapter adp.Adapter, executionMgr execution.Manager,
+	items []*scheduler.ScheduleItem, policy *model.Policy) error {
+	if policy == nil || !policy.DestinationHealthGate || len(items) == 0 {
+		return nil
+	}
+	status, err := adapter.HealthCheck()
+	if err == nil && status == model.Healthy {
+		log.Debug("the destination registry is healthy")
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("the destination registry is %s", status)
+	} else {
+		err = fmt.Errorf("failed to check the health of the destination registry: %v", err)
+	}
+	for _, item := range items {
+		if e := executionMgr.UpdateTaskStatus(item.TaskID, models.TaskStatusFailed,
+			models.TaskStatusInitialized); e != nil {
+			log.Errorf("failed to update the task status %d: %v", item.TaskID, e)
+		}
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// returns the adapter failing to check the health with the error
+func newHealthCheckFailingAdapter(err error) *test.Adapter {
+	adapter := &test.Adapter{}
+	adapter.SetError("HealthCheck", err)
+	return adapter
+}
+
+func newHealthItems() []*scheduler.ScheduleItem {
+	return []*scheduler.ScheduleItem{
+		{TaskID: 1},
+		{TaskID: 2},
+	}
+}
+
+func TestCheckDestinationHealth(t *testing.T) {
+	unhealthy := &test.Adapter{Health: model.Unhealthy}
+	policy := &model.Policy{}
+	mgr := newFakedExecutionManagerWithTasks(2)
+
+	// the gate is disabled
+	require.Nil(t, checkDestinationHealth(unhealthy, mgr, newHealthItems(), policy))
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+
+	// healthy
+	policy.DestinationHealthGate = true
+	require.Nil(t, checkDestinationHealth(&test.Adapter{}, mgr, newHealthItems(), policy))
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+
+	// unhealthy
+	err := checkDestinationHealth(unhealthy, mgr, newHealthItems(), policy)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unhealthy")
+	assert.Equal(t, map[int64]string{
+		1: models.TaskStatusFailed,
+		2: models.TaskStatusFailed,
+	}, updatedTaskStatuses(mgr))
+
+	// failed to check the health
+	mgr = newFakedExecutionManagerWithTasks(2)
+	err = checkDestinationHealth(newHealthCheckFailingAdapter(errors.New("unauthorized")), mgr, newHealthItems(), policy)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unauthorized")
+	assert.Equal(t, 2, len(updatedTaskStatuses(mgr)))
+}
+
+func TestCheckRegistryHealth(t *testing.T) {
+	assert.Nil(t, checkRegistryHealth(&test.Adapter{}))
+
+	err := checkRegistryHealth(&test.Adapter{Health: model.Unhealthy})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unhealthy")
+
+	err = checkRegistryHealth(newHealthCheckFailingAdapter(errors.New("unauthorized")))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "unauthorized")
+}
+
+func TestRunOfCopyFlowWithUnhealthyRegistries(t *testing.T) {
+	registryType := model.RegistryType("faked-unhealthy")
+	require.Nil(t, adapter.RegisterFactory(registryType, func(*model.Registry) (adapter.Adapter, error) {
+		return &test.Adapter{Health: model.Unhealthy}, nil
+	}))
+
+	// the unhealthy destination aborts the flow right after initializing, even
+	// though the gate is disabled
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: registryType,
+		},
+	}
+	sched := &fakedRecordingScheduler{}
+	n, err := NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "destination registry")
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, len(sched.items))
+
+	// so does the unhealthy source
+	policy.SrcRegistry.Type, policy.DestRegistry.Type = registryType, model.RegistryTypeHarbor
+	sched = &fakedRecordingScheduler{}
+	_, err = NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "source registry")
+	assert.Equal(t, 0, len(sched.items))
+}
@@ -0,0 +1,228 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+)
+
+// the page size used to list the previous executions when looking up the watermark
+var watermarkPageSize int64 = 20
+
+// the statuses of the tasks that are skipped without replicating the resources, the
+// execution containing them isn't taken as the watermark even if it succeeds
+var unreplicatedTaskStatuses = []string{models.TaskStatusDeferred, models.TaskStatusDenied,
+	models.TaskStatusOverQuota, models.TaskStatusTimedOut, models.TaskStatusRateLimited}
+
+// the point the incremental replication continues from, it's got from the last
+// execution of the policy that fetched the resources and succeeded fully
+type watermark struct {
+	// the start time of the execution
+	time time.Time
+	// the digests of the tags recorded by the execution indexed by "repository:tag",
+	// nil if the execution didn't record them
+	digests map[string]string
+}
+
+// get the watermark of the incremental replication, nil is returned if the policy isn't
+// incremental or none of the previous executions succeeded fully. As only the executions
+// replicating all the resources are taken, the watermark never advances over the failed,
+// stopped, partially succeeded ones or the ones deferring some resources. The event based executions replicating the specified resources are
+// ignored as they don't cover all the resources of the policy
+func getWatermark(mgr execution.Manager, executionID int64, policy *model.Policy) (*watermark, error) {
+	if !policy.Incremental {
+		return nil, nil
+	}
+	// the executions are sorted by the start time in descending order. The statuses are
+	// refreshed by the tasks when listing, so they aren't used as the query conditions
+	for page := int64(1); ; page++ {
+		total, executions, err := mgr.List(&models.ExecutionQuery{
+			PolicyID: policy.ID,
+			Pagination: models.Pagination{
+				Page: page,
+				Size: watermarkPageSize,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the executions of the policy %d: %v", policy.ID, err)
+		}
+		for _, e := range executions {
+			if e.ID == executionID || e.Status != models.ExecutionStatusSucceed ||
+				e.Trigger == model.TriggerTypeEventBased {
+				continue
+			}
+			n, _, err := mgr.ListTasks(&models.TaskQuery{
+				ExecutionID: e.ID,
+				Statuses:    unreplicatedTaskStatuses,
+				Pagination: models.Pagination{
+					Page: 1,
+					Size: 1,
+				},
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to list the tasks of the execution %d: %v", e.ID, err)
+			}
+			if n > 0 {
+				continue
+			}
+			mark := &watermark{
+				time: e.StartTime,
+			}
+			if len(e.Digests) > 0 {
+				if err := json.Unmarshal([]byte(e.Digests), &mark.digests); err != nil {
+					log.Warningf("failed to unmarshal the digests recorded by the execution %d, compare all the tags: %v",
+						e.ID, err)
+					mark.digests = nil
+				}
+			}
+			log.Debugf("the incremental replication continues from the execution %d started at %v", e.ID, e.StartTime)
+			return mark, nil
+		}
+		if len(executions) == 0 || page*watermarkPageSize >= total {
+			return nil, nil
+		}
+	}
+}
+
+// whether the images are fetched incrementally by the adapter on the server side
+func fetchesIncrementally(adapter adp.Adapter, mark *watermark) bool {
+	if mark == nil {
+		return false
+	}
+	_, ok := adapter.(adp.IncrementalImageRegistry)
+	return ok
+}
+
+// drop the tags of the image resources that are unchanged since the watermark of the
+// incremental replication, and the resources without any tag left are dropped too. If
+// the source adapter lists the push time of tags, the tags pushed before the watermark
+// are unchanged and the ones whose push time is unknown are kept. Otherwise the digests
+// of the tags are compared with the ones recorded by the execution of the watermark and
+// the current ones are recorded for the next execution. Nothing is dropped if the images
+// are fetched incrementally by the adapter already. Other resources are kept as they are
+func filterUnchangedResources(mgr execution.Manager, executionID int64, srcAdapter adp.Adapter,
+	resources []*model.Resource, mark *watermark, policy *model.Policy) ([]*model.Resource, error) {
+	if !policy.Incremental || fetchesIncrementally(srcAdapter, mark) {
+		return resources, nil
+	}
+	if lister, ok := srcAdapter.(adp.TagCreationTimeLister); ok {
+		if mark == nil {
+			return resources, nil
+		}
+		// the tags younger than the min age are dropped by the previous execution,
+		// so they are taken as changed even if they are pushed before it
+		minAge, err := getMinAge(policy)
+		if err != nil {
+			return nil, err
+		}
+		return filterTagsPushedBefore(lister, resources, mark.time.Add(-minAge))
+	}
+	registry, ok := srcAdapter.(adp.ImageRegistry)
+	if !ok {
+		return resources, nil
+	}
+	return filterTagsOfSameDigests(mgr, executionID, registry, resources, mark)
+}
+
+func filterTagsPushedBefore(lister adp.TagCreationTimeLister, resources []*model.Resource,
+	since time.Time) ([]*model.Resource, error) {
+	var result []*model.Resource
+	for _, resource := range resources {
+		if resource.Type != model.ResourceTypeImage || resource.Deleted ||
+			len(resource.Metadata.Vtags) == 0 {
+			result = append(result, resource)
+			continue
+		}
+		repository := resource.Metadata.Repository.Name
+		times, err := lister.ListTagCreationTimes(repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the creation time of tags under %s: %v", repository, err)
+		}
+		var tags []string
+		for _, tag := range resource.Metadata.Vtags {
+			pushTime, exist := times[tag]
+			if exist && pushTime.Before(since) {
+				log.Debugf("the tag %s:%s is pushed at %v before %v, skip", repository, tag, pushTime, since)
+				continue
+			}
+			tags = append(tags, tag)
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		resource.Metadata.Vtags = tags
+		result = append(result, resource)
+	}
+	log.Debug("filter the tags pushed before the watermark completed")
+	return result, nil
+}
+
+func filterTagsOfSameDigests(mgr execution.Manager, executionID int64, registry adp.ImageRegistry,
+	resources []*model.Resource, mark *watermark) ([]*model.Resource, error) {
+	var previous map[string]string
+	if mark != nil {
+		previous = mark.digests
+	}
+	current := map[string]string{}
+	var result []*model.Resource
+	for _, resource := range resources {
+		if resource.Type != model.ResourceTypeImage || resource.Deleted ||
+			len(resource.Metadata.Vtags) == 0 {
+			result = append(result, resource)
+			continue
+		}
+		repository := resource.Metadata.Repository.Name
+		var tags []string
+		for _, tag := range resource.Metadata.Vtags {
+			_, digest, err := registry.ManifestExist(repository, tag)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get the digest of %s:%s on the source registry: %v",
+					repository, tag, err)
+			}
+			key := repository + ":" + tag
+			current[key] = digest
+			if d, exist := previous[key]; exist && d == digest {
+				log.Debugf("the digest of %s is same as the one replicated before, skip", key)
+				continue
+			}
+			tags = append(tags, tag)
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		resource.Metadata.Vtags = tags
+		result = append(result, resource)
+	}
+	data, err := json.Marshal(current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the digests of the tags: %v", err)
+	}
+	if err = mgr.Update(&models.Execution{
+		ID:      executionID,
+		Digests: string(data),
+	}, models.ExecutionPropsName.Digests); err != nil {
+		return nil, fmt.Errorf("failed to record the digests of the tags for the execution %d: %v", executionID, err)
+	}
+	log.Debug("filter the tags of the same digests as the watermark completed")
+	return result, nil
+}
@@ -0,0 +1,216 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the adapter fetches the images pushed since the specified time on the server side
+type fakedIncrementalAdapter struct {
+	test.Adapter
+	since time.Time
+}
+
+func (f *fakedIncrementalAdapter) FetchImagesPushedSince(ctx context.Context, filters []*model.Filter, since time.Time) ([]*model.Resource, error) {
+	f.since = since
+	return nil, nil
+}
+
+func newIncrementalResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"fresh", "old", "unknown"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"old"},
+			},
+		},
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/harbor",
+				},
+				Vtags: []string{"1.0"},
+			},
+		},
+	}
+}
+
+func TestGetWatermark(t *testing.T) {
+	policy := &model.Policy{
+		ID: 1,
+	}
+	start := time.Now().Add(-time.Hour)
+	mgr := test.NewExecutionManager()
+	// the policy isn't incremental
+	mark, err := getWatermark(mgr, 5, policy)
+	require.Nil(t, err)
+	assert.Nil(t, mark)
+
+	// no execution succeeded
+	policy.Incremental = true
+	mark, err = getWatermark(mgr, 5, policy)
+	require.Nil(t, err)
+	assert.Nil(t, mark)
+
+	for _, execution := range []*models.Execution{
+		{Status: models.ExecutionStatusSucceed, Trigger: model.TriggerTypeScheduled, StartTime: start,
+			Digests: `{"library/hello-world:latest":"sha256:1"}`},
+		{Status: models.ExecutionStatusSucceed, Trigger: model.TriggerTypeScheduled, StartTime: start.Add(time.Minute)},
+		{Status: models.ExecutionStatusPartialSuccess, StartTime: start.Add(2 * time.Minute)},
+		{Status: models.ExecutionStatusSucceed, Trigger: model.TriggerTypeEventBased, StartTime: start.Add(3 * time.Minute)},
+		{Status: models.ExecutionStatusInProgress, StartTime: start.Add(4 * time.Minute)},
+	} {
+		execution.PolicyID = policy.ID
+		_, err = mgr.Create(execution)
+		require.Nil(t, err)
+	}
+	// the execution 2 deferred some resources
+	for _, task := range []*models.Task{
+		{ExecutionID: 2, Status: models.TaskStatusSucceed},
+		{ExecutionID: 2, Status: models.TaskStatusDeferred},
+	} {
+		_, err = mgr.CreateTask(task)
+		require.Nil(t, err)
+	}
+	mark, err = getWatermark(mgr, 5, policy)
+	require.Nil(t, err)
+	require.NotNil(t, mark)
+	assert.Equal(t, start, mark.time)
+	assert.Equal(t, map[string]string{"library/hello-world:latest": "sha256:1"}, mark.digests)
+
+	// the execution 2 replicated all the resources
+	require.Nil(t, mgr.RemoveTask(2))
+	mark, err = getWatermark(mgr, 5, policy)
+	require.Nil(t, err)
+	require.NotNil(t, mark)
+	assert.Equal(t, start.Add(time.Minute), mark.time)
+	assert.Nil(t, mark.digests)
+}
+
+func TestFetchResourcesIncrementally(t *testing.T) {
+	adapter := &fakedIncrementalAdapter{}
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+		},
+		Incremental: true,
+	}
+	since := time.Now().Add(-time.Hour)
+	_, _, err := fetchResources(context.Background(), adapter, policy, &watermark{time: since})
+	require.Nil(t, err)
+	assert.Equal(t, since, adapter.since)
+
+	// nothing is dropped by the flow
+	resources, err := filterUnchangedResources(newFakedExecutionManager(), 1, adapter,
+		newIncrementalResources(), &watermark{time: since}, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(resources))
+}
+
+func TestFilterUnchangedResourcesByPushTime(t *testing.T) {
+	policy := &model.Policy{
+		Incremental: true,
+	}
+	// no watermark
+	resources, err := filterUnchangedResources(newFakedExecutionManager(), 1, newPushTimeAdapter(),
+		newIncrementalResources(), nil, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(resources))
+
+	// the tags pushed before the watermark are dropped
+	mark := &watermark{time: time.Now().Add(-time.Minute)}
+	resources, err = filterUnchangedResources(newFakedExecutionManager(), 1, newPushTimeAdapter(),
+		newIncrementalResources(), mark, policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, []string{"fresh", "unknown"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, model.ResourceTypeChart, resources[1].Type)
+
+	// the tags held back by the min age are taken as changed
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeMinAge,
+			Value: float64(2 * 3600),
+		},
+	}
+	resources, err = filterUnchangedResources(newFakedExecutionManager(), 1, newPushTimeAdapter(),
+		newIncrementalResources(), mark, policy)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(resources))
+	assert.Equal(t, []string{"fresh", "old", "unknown"}, resources[0].Metadata.Vtags)
+}
+
+func TestFilterUnchangedResourcesByDigest(t *testing.T) {
+	policy := &model.Policy{
+		Incremental: true,
+	}
+	adapter := newDigestAdapter(map[string]string{
+		"library/hello-world:fresh":   "sha256:1",
+		"library/hello-world:old":     "sha256:2",
+		"library/hello-world:unknown": "sha256:3",
+		"library/busybox:old":         "sha256:4",
+	})
+	mgr := newFakedExecutionManager()
+	mark := &watermark{
+		time: time.Now(),
+		digests: map[string]string{
+			"library/hello-world:fresh": "sha256:0",
+			"library/hello-world:old":   "sha256:2",
+			"library/busybox:old":       "sha256:4",
+		},
+	}
+	resources, err := filterUnchangedResources(mgr, 1, adapter, newIncrementalResources(), mark, policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, []string{"fresh", "unknown"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, model.ResourceTypeChart, resources[1].Type)
+	// the current digests are recorded for the next execution
+	execution, err := mgr.Get(1)
+	require.Nil(t, err)
+	require.NotNil(t, execution)
+	assert.JSONEq(t, `{"library/hello-world:fresh":"sha256:1","library/hello-world:old":"sha256:2",
+		"library/hello-world:unknown":"sha256:3","library/busybox:old":"sha256:4"}`, execution.Digests)
+
+	// all the tags are kept if no digests are recorded before
+	resources, err = filterUnchangedResources(mgr, 1, adapter, newIncrementalResources(), nil, policy)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(resources))
+	assert.Equal(t, []string{"fresh", "old", "unknown"}, resources[0].Metadata.Vtags)
+}
@@ -0,0 +1,188 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// the interval of polling the status of the in-flight tasks
+var inFlightPollInterval = 5 * time.Second
+
+// the key of the queue holding the items of all the resource types in the "ordered" execution mode
+const orderedQueue model.ResourceType = "all the resources"
+
+// get the max count of the tasks of each resource type in flight at the same time,
+// 0 means no limit. It's 1 in the "ordered" execution mode, otherwise the smaller one
+// of the "MaxInFlightTasks" and the concurrency tuned adaptively if the policy
+// specifies the max concurrency
+func getInFlightLimit(policy *model.Policy) int {
+	if policy == nil {
+		return 0
+	}
+	if policy.ExecutionMode == model.ExecutionModeOrdered {
+		return 1
+	}
+	limit := policy.MaxInFlightTasks
+	if policy.MaxConcurrency > 0 {
+		concurrency := getConcurrencyController(policy).Concurrency()
+		if limit <= 0 || concurrency < limit {
+			limit = concurrency
+		}
+	}
+	return limit
+}
+
+// submit the items in batches, at most "limit" tasks of each resource type are in flight
+// at the same time and the next batch is released as the earlier tasks finish. In the
+// "ordered" execution mode, the items of all the resource types are submitted one by one
+// in their order instead. The items failed to be submitted are returned as the failed
+// results. When the context is cancelled, the queued items are marked as stopped and the
+// error of it is returned
+func submitInFlight(ctx context.Context, sched scheduler.Scheduler, executionMgr execution.Manager,
+	items []*scheduler.ScheduleItem, policy *model.Policy, limit int,
+	tracker *progressTracker) ([]*scheduler.ScheduleResult, error) {
+	ordered := policy.ExecutionMode == model.ExecutionModeOrdered
+	var types []model.ResourceType
+	queues := map[model.ResourceType][]*scheduler.ScheduleItem{}
+	inFlight := map[model.ResourceType]map[int64]struct{}{}
+	for _, item := range items {
+		t := item.SrcResource.Type
+		// all the items share one queue to keep their order
+		if ordered {
+			t = orderedQueue
+		}
+		if _, exist := queues[t]; !exist {
+			types = append(types, t)
+			inFlight[t] = map[int64]struct{}{}
+		}
+		queues[t] = append(queues[t], item)
+	}
+
+	var results []*scheduler.ScheduleResult
+	for {
+		if ctx.Err() != nil {
+			for _, t := range types {
+				stopQueuedTasks(executionMgr, queues[t])
+			}
+			return results, ctx.Err()
+		}
+		queued := 0
+		for _, t := range types {
+			capacity := limit - len(inFlight[t])
+			if capacity > len(queues[t]) {
+				capacity = len(queues[t])
+			}
+			if capacity > 0 {
+				batch := queues[t][:capacity]
+				queues[t] = queues[t][capacity:]
+				batchResults := submitBatch(sched, batch)
+				updateScheduledTasks(executionMgr, batchResults, tracker)
+				for _, result := range batchResults {
+					if result.Error == nil {
+						inFlight[t][result.TaskID] = struct{}{}
+					}
+					results = append(results, result)
+				}
+				log.Debugf("%d tasks of %s submitted, %d in flight, %d queued", len(batch), t,
+					len(inFlight[t]), len(queues[t]))
+			}
+			queued += len(queues[t])
+		}
+		if queued == 0 {
+			return results, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			continue
+		case <-time.After(inFlightPollInterval):
+		}
+		stopped := false
+		for _, t := range types {
+			if releaseFinishedTasks(executionMgr, inFlight[t]) {
+				stopped = true
+			}
+		}
+		// the tasks are stopped when the execution is stopped, so the queued
+		// ones are marked as stopped rather than being submitted
+		if stopped {
+			for _, t := range types {
+				stopQueuedTasks(executionMgr, queues[t])
+			}
+			return results, nil
+		}
+	}
+}
+
+// submit the batch of items, the items are returned as the failed results
+// if the batch fails to be submitted
+func submitBatch(sched scheduler.Scheduler, items []*scheduler.ScheduleItem) []*scheduler.ScheduleResult {
+	// the batch is small, so it's submitted entirely and the cancellation is
+	// checked between the batches
+	results, _, err := submit(context.Background(), sched, items)
+	if err == nil {
+		return results
+	}
+	results = nil
+	for _, item := range items {
+		results = append(results, &scheduler.ScheduleResult{
+			TaskID: item.TaskID,
+			Error:  err,
+		})
+	}
+	return results
+}
+
+// remove the finished tasks from the in-flight ones, returns
+// whether any of the tasks is stopped
+func releaseFinishedTasks(executionMgr execution.Manager, inFlight map[int64]struct{}) bool {
+	stopped := false
+	for id := range inFlight {
+		task, err := executionMgr.GetTask(id)
+		if err != nil {
+			log.Errorf("failed to get the task %d: %v", id, err)
+			continue
+		}
+		if task != nil && isTaskInFlight(task.Status) {
+			continue
+		}
+		if task != nil && task.Status == models.TaskStatusStopped {
+			stopped = true
+		}
+		delete(inFlight, id)
+	}
+	return stopped
+}
+
+func isTaskInFlight(status string) bool {
+	return status == models.TaskStatusInitialized || status == models.TaskStatusPending ||
+		status == models.TaskStatusInProgress
+}
+
+func stopQueuedTasks(executionMgr execution.Manager, items []*scheduler.ScheduleItem) {
+	for _, item := range items {
+		if err := executionMgr.UpdateTaskStatus(item.TaskID, models.TaskStatusStopped, models.TaskStatusInitialized); err != nil {
+			log.Errorf("failed to update the task status %d: %v", item.TaskID, err)
+		}
+	}
+}
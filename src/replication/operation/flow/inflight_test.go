@@ -0,0 +1,255 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// records the sizes of the batches submitted
+type fakedBatchScheduler struct {
+	fakedScheduler
+	batches []int
+}
+
+func (f *fakedBatchScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	f.batches = append(f.batches, len(items))
+	return f.fakedScheduler.Schedule(items)
+}
+
+// the submitted tasks turn into the status "finalStatus" once they are polled
+// as if they are finished by the job service
+type fakedInFlightExecutionManager struct {
+	*test.ExecutionManager
+	finalStatus string
+}
+
+// returns the manager with the 28 initialized tasks of the items
+func newInFlightExecutionManager(finalStatus string) *fakedInFlightExecutionManager {
+	return &fakedInFlightExecutionManager{
+		ExecutionManager: newFakedExecutionManagerWithTasks(28),
+		finalStatus:      finalStatus,
+	}
+}
+
+func (f *fakedInFlightExecutionManager) GetTask(id int64) (*models.Task, error) {
+	task, err := f.ExecutionManager.GetTask(id)
+	if err != nil || task == nil {
+		return task, err
+	}
+	if task.Status == models.TaskStatusPending && len(f.finalStatus) > 0 {
+		if err = f.UpdateTaskStatus(id, f.finalStatus, models.TaskStatusPending); err != nil {
+			return nil, err
+		}
+		task.Status = f.finalStatus
+	}
+	return task, nil
+}
+
+func newInFlightItems() []*scheduler.ScheduleItem {
+	var items []*scheduler.ScheduleItem
+	for i := 1; i <= 28; i++ {
+		resourceType := model.ResourceTypeImage
+		if i > 25 {
+			resourceType = model.ResourceTypeChart
+		}
+		items = append(items, &scheduler.ScheduleItem{
+			TaskID: int64(i),
+			SrcResource: &model.Resource{
+				Type: resourceType,
+			},
+		})
+	}
+	return items
+}
+
+func TestScheduleInFlight(t *testing.T) {
+	interval := inFlightPollInterval
+	inFlightPollInterval = time.Millisecond
+	defer func() {
+		inFlightPollInterval = interval
+	}()
+
+	sched := &fakedBatchScheduler{}
+	mgr := newInFlightExecutionManager(models.TaskStatusSucceed)
+	sum := &summary{}
+	n, err := schedule(context.Background(), sched, mgr, newInFlightItems(), &model.Policy{MaxInFlightTasks: 10}, sum)
+	require.Nil(t, err)
+	assert.Equal(t, 28, n)
+	assert.Equal(t, 28, sum.Submitted)
+	// the images are submitted in 3 batches and the charts are limited separately
+	assert.Equal(t, []int{10, 3, 10, 5}, sched.batches)
+}
+
+func TestScheduleInFlightStopped(t *testing.T) {
+	interval := inFlightPollInterval
+	inFlightPollInterval = time.Millisecond
+	defer func() {
+		inFlightPollInterval = interval
+	}()
+
+	sched := &fakedBatchScheduler{}
+	mgr := newInFlightExecutionManager(models.TaskStatusStopped)
+	n, err := schedule(context.Background(), sched, mgr, newInFlightItems(), &model.Policy{MaxInFlightTasks: 10}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 13, n)
+	assert.Equal(t, []int{10, 3}, sched.batches)
+	// the queued tasks aren't submitted
+	for i := int64(11); i <= 25; i++ {
+		assert.Equal(t, models.TaskStatusStopped, updatedTaskStatuses(mgr.ExecutionManager)[i])
+	}
+}
+
+// cancels the context once the first batch is submitted
+type fakedCancellingScheduler struct {
+	fakedBatchScheduler
+	cancel context.CancelFunc
+}
+
+func (f *fakedCancellingScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	f.cancel()
+	return f.fakedBatchScheduler.Schedule(items)
+}
+
+func TestScheduleCancelled(t *testing.T) {
+	// cancelled before the scheduling
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sched := &fakedBatchScheduler{}
+	mgr := newInFlightExecutionManager("")
+	n, err := schedule(ctx, sched, mgr, newInFlightItems(), nil, nil)
+	require.Equal(t, context.Canceled, err)
+	assert.True(t, IsCancelled(err))
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, len(sched.batches))
+	for i := int64(1); i <= 28; i++ {
+		assert.Equal(t, models.TaskStatusStopped, updatedTaskStatuses(mgr.ExecutionManager)[i])
+	}
+
+	// cancelled after the first batch is submitted
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	cancelling := &fakedCancellingScheduler{cancel: cancel}
+	mgr = newInFlightExecutionManager("")
+	sum := &summary{}
+	n, err = schedule(ctx, cancelling, mgr, newInFlightItems(), nil, sum)
+	require.Equal(t, context.Canceled, err)
+	assert.Equal(t, 10, n)
+	assert.Equal(t, 10, sum.Submitted)
+	assert.Equal(t, []int{10}, cancelling.batches)
+	for i := int64(1); i <= 10; i++ {
+		assert.Equal(t, models.TaskStatusPending, updatedTaskStatuses(mgr.ExecutionManager)[i])
+	}
+	for i := int64(11); i <= 28; i++ {
+		assert.Equal(t, models.TaskStatusStopped, updatedTaskStatuses(mgr.ExecutionManager)[i])
+	}
+}
+
+func TestScheduleInFlightCancelled(t *testing.T) {
+	interval := inFlightPollInterval
+	inFlightPollInterval = time.Hour
+	defer func() {
+		inFlightPollInterval = interval
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched := &fakedCancellingScheduler{cancel: cancel}
+	mgr := newInFlightExecutionManager(models.TaskStatusSucceed)
+	// the cancellation interrupts the waiting for the in-flight tasks
+	n, err := schedule(ctx, sched, mgr, newInFlightItems(), &model.Policy{MaxInFlightTasks: 10}, nil)
+	require.Equal(t, context.Canceled, err)
+	assert.Equal(t, 13, n)
+	assert.Equal(t, []int{10, 3}, sched.batches)
+	for i := int64(11); i <= 25; i++ {
+		assert.Equal(t, models.TaskStatusStopped, updatedTaskStatuses(mgr.ExecutionManager)[i])
+	}
+}
+
+// records the order of the submitted tasks and the max count of them in flight
+type fakedOrderScheduler struct {
+	fakedBatchScheduler
+	mgr         *fakedInFlightExecutionManager
+	taskIDs     []int64
+	maxInFlight int
+}
+
+func (f *fakedOrderScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	for _, item := range items {
+		f.taskIDs = append(f.taskIDs, item.TaskID)
+	}
+	inFlight := len(items)
+	for _, task := range f.mgr.Tasks() {
+		if task.Status == models.TaskStatusPending {
+			inFlight++
+		}
+	}
+	if inFlight > f.maxInFlight {
+		f.maxInFlight = inFlight
+	}
+	return f.fakedBatchScheduler.Schedule(items)
+}
+
+func TestScheduleOrdered(t *testing.T) {
+	interval := inFlightPollInterval
+	inFlightPollInterval = time.Millisecond
+	defer func() {
+		inFlightPollInterval = interval
+	}()
+
+	mgr := newInFlightExecutionManager(models.TaskStatusSucceed)
+	sched := &fakedOrderScheduler{mgr: mgr}
+	items := newInFlightItems()
+	sum := &summary{}
+	// the "MaxInFlightTasks" is ignored in the ordered mode
+	policy := &model.Policy{
+		ExecutionMode:    model.ExecutionModeOrdered,
+		MaxInFlightTasks: 10,
+	}
+	n, err := schedule(context.Background(), sched, mgr, items, policy, sum)
+	require.Nil(t, err)
+	assert.Equal(t, 28, n)
+	assert.Equal(t, 28, sum.Submitted)
+	// the images and charts are submitted one by one in their order
+	require.Equal(t, 28, len(sched.taskIDs))
+	for i, item := range items {
+		assert.Equal(t, item.TaskID, sched.taskIDs[i])
+		assert.Equal(t, 1, sched.batches[i])
+	}
+	assert.Equal(t, 1, sched.maxInFlight)
+}
+
+func TestScheduleParallel(t *testing.T) {
+	mgr := newInFlightExecutionManager(models.TaskStatusSucceed)
+	sched := &fakedOrderScheduler{mgr: mgr}
+	sum := &summary{}
+	policy := &model.Policy{
+		ExecutionMode: model.ExecutionModeParallel,
+	}
+	n, err := schedule(context.Background(), sched, mgr, newInFlightItems(), policy, sum)
+	require.Nil(t, err)
+	assert.Equal(t, 28, n)
+	// all the tasks are in flight at the same time without waiting for the earlier ones
+	assert.Equal(t, 28, sched.maxInFlight)
+}
@@ -0,0 +1,99 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// selects the latest N tags specified by the "latest_tags" filter. The tags are ordered
+// by the push time or semver according to the filter. When ordering by the push time, the
+// tags whose push time is unknown are treated as the oldest ones, and the semver ordering
+// is used if the source adapter cannot list the push time of the tags
+type latestTagsSelector struct {
+	count int
+	// nil if the tags are ordered by semver
+	lister adp.TagCreationTimeLister
+}
+
+func newLatestTagsSelector(filter *model.Filter, srcAdapter adp.Adapter) (TagSelector, error) {
+	latest, err := filter.GetLatestTags()
+	if err != nil {
+		return nil, err
+	}
+	if latest.Count <= 0 {
+		return nil, fmt.Errorf("%d is not a valid count of latest tags", latest.Count)
+	}
+	selector := &latestTagsSelector{
+		count: latest.Count,
+	}
+	if latest.OrderBy != model.LatestTagsOrderBySemver {
+		lister, ok := srcAdapter.(adp.TagCreationTimeLister)
+		if ok {
+			selector.lister = lister
+		} else {
+			log.Warningf("the source adapter doesn't support listing the push time of tags, order the tags by semver to keep the latest %d tags", latest.Count)
+		}
+	}
+	return selector, nil
+}
+
+func (l *latestTagsSelector) Select(resource *model.Resource) ([]string, error) {
+	if len(resource.Metadata.Vtags) <= l.count {
+		return resource.Metadata.Vtags, nil
+	}
+	var times map[string]time.Time
+	// only the push time of the image tags can be listed
+	if l.lister != nil && resource.Type == model.ResourceTypeImage {
+		var err error
+		repository := resource.Metadata.Repository.Name
+		times, err = l.lister.ListTagCreationTimes(repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the creation time of tags under %s: %v", repository, err)
+		}
+	}
+	return keepLatestTags(resource.Metadata.Vtags, times, l.count), nil
+}
+
+func (l *latestTagsSelector) Describe() string {
+	return fmt.Sprintf("one of the latest %d tags", l.count)
+}
+
+// keep the latest "count" tags ordered by the push time, the semver ordering is
+// used if the push time is equal or unknown
+func keepLatestTags(tags []string, times map[string]time.Time, count int) []string {
+	sorted := make([]string, len(tags))
+	copy(sorted, tags)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return isNewerTag(sorted[i], sorted[j], times)
+	})
+	kept := map[string]struct{}{}
+	for _, tag := range sorted[:count] {
+		kept[tag] = struct{}{}
+	}
+	var result []string
+	for _, tag := range tags {
+		if _, exist := kept[tag]; exist {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
@@ -0,0 +1,127 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the tag "1.0.0" is the newest one and the tag "2.0.0" is the oldest one,
+// the push time of other tags is unknown
+func newLatestPushTimeAdapter() *fakedTagTimeAdapter {
+	return &fakedTagTimeAdapter{
+		times: map[string]map[string]time.Time{
+			"*": {
+				"1.0.0":  time.Now(),
+				"1.1.0":  time.Now().Add(-time.Minute),
+				"2.0.0":  time.Now().Add(-time.Hour),
+				"latest": time.Now().Add(-time.Second),
+			},
+		},
+	}
+}
+
+func newLatestResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"2.0.0", "1.0.0", "dev", "1.1.0", "latest", "1.2.0"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"1.0.0"},
+			},
+		},
+	}
+}
+
+func TestSelectLatestTags(t *testing.T) {
+	// no latest tags filter
+	resources, err := selectTags(newLatestPushTimeAdapter(), newLatestResources(), &model.Policy{})
+	require.Nil(t, err)
+	assert.Equal(t, 6, len(resources[0].Metadata.Vtags))
+
+	// order by the push time, the original order of the tags is kept
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type: model.FilterTypeLatestTags,
+				Value: map[string]interface{}{
+					"count":    float64(3),
+					"order_by": model.LatestTagsOrderByPushTime,
+				},
+			},
+		},
+	}
+	resources, err = selectTags(newLatestPushTimeAdapter(), newLatestResources(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, []string{"1.0.0", "1.1.0", "latest"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, []string{"1.0.0"}, resources[1].Metadata.Vtags)
+
+	// the tags whose push time is unknown are the oldest ones
+	policy.Filters[0].Value = &model.LatestTags{Count: 5}
+	resources, err = selectTags(newLatestPushTimeAdapter(), newLatestResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2.0.0", "1.0.0", "1.1.0", "latest", "1.2.0"}, resources[0].Metadata.Vtags)
+
+	// order by semver
+	policy.Filters[0].Value = &model.LatestTags{Count: 3, OrderBy: model.LatestTagsOrderBySemver}
+	resources, err = selectTags(newLatestPushTimeAdapter(), newLatestResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2.0.0", "1.1.0", "1.2.0"}, resources[0].Metadata.Vtags)
+
+	// fall back to the semver ordering if the adapter cannot list the push time
+	policy.Filters[0].Value = &model.LatestTags{Count: 3}
+	resources, err = selectTags(newFakedAdapter(), newLatestResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"2.0.0", "1.1.0", "1.2.0"}, resources[0].Metadata.Vtags)
+
+	// invalid filter value
+	policy.Filters[0].Value = &model.LatestTags{Count: 0}
+	_, err = selectTags(newFakedAdapter(), newLatestResources(), policy)
+	assert.NotNil(t, err)
+}
+
+func TestKeepLatestTagsWithTies(t *testing.T) {
+	bulk := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	times := map[string]time.Time{
+		"1.0.0":  bulk,
+		"1.1.0":  bulk,
+		"1.2.0":  bulk,
+		"latest": bulk,
+		"0.9.0":  bulk.Add(time.Hour),
+	}
+	// the tags pushed at the same time are selected by semver and then lexically,
+	// whatever the order returned by the registry is
+	assert.Equal(t, []string{"0.9.0", "1.2.0", "1.1.0"},
+		keepLatestTags([]string{"0.9.0", "1.2.0", "latest", "1.1.0", "1.0.0"}, times, 3))
+	assert.Equal(t, []string{"1.1.0", "1.2.0", "0.9.0"},
+		keepLatestTags([]string{"1.0.0", "1.1.0", "latest", "1.2.0", "0.9.0"}, times, 3))
+}
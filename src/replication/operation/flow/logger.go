@@ -0,0 +1,41 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"io"
+	"os"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// the output of the loggers of the flows, replaced in the tests
+var logOutput io.Writer = os.Stdout
+
+// returns the logger used by the flow for its stage logging. The log level of the
+// policy overrides the global one for the execution only, the global logger is
+// returned if the policy doesn't specify a valid log level
+func newFlowLogger(policy *model.Policy) *log.Logger {
+	if policy == nil || len(policy.LogLevel) == 0 {
+		return log.DefaultLogger()
+	}
+	level, err := log.ParseLevel(policy.LogLevel)
+	if err != nil {
+		log.Warningf("invalid log level %s of the policy %d, use the global one", policy.LogLevel, policy.ID)
+		return log.DefaultLogger()
+	}
+	return log.New(logOutput, log.NewTextFormatter(), level)
+}
@@ -0,0 +1,67 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewFlowLogger(t *testing.T) {
+	assert.Equal(t, log.DefaultLogger(), newFlowLogger(nil))
+	assert.Equal(t, log.DefaultLogger(), newFlowLogger(&model.Policy{}))
+	assert.Equal(t, log.DefaultLogger(), newFlowLogger(&model.Policy{LogLevel: "verbose"}))
+	assert.NotEqual(t, log.DefaultLogger(), newFlowLogger(&model.Policy{LogLevel: "debug"}))
+}
+
+func TestRunOfCopyFlowWithLogLevel(t *testing.T) {
+	output := logOutput
+	defer func() {
+		logOutput = output
+	}()
+	run := func(level string) string {
+		buf := &bytes.Buffer{}
+		logOutput = buf
+		policy := &model.Policy{
+			SrcRegistry: &model.Registry{
+				Type: model.RegistryTypeHarbor,
+			},
+			DestRegistry: &model.Registry{
+				Type: model.RegistryTypeHarbor,
+			},
+			LogLevel: level,
+		}
+		flow := NewCopyFlow(newFakedExecutionManager(), &fakedScheduler{}, 1, policy)
+		_, err := flow.Run(nil)
+		require.Nil(t, err)
+		return buf.String()
+	}
+
+	// the debug lines of the stages are logged for the policy with debug level
+	out := run("debug")
+	assert.Contains(t, out, "[DEBUG]")
+	assert.Contains(t, out, "2 resources fetched for the execution 1")
+	assert.Contains(t, out, "2 tasks created for the execution 1")
+
+	// but not for the one with info level
+	out = run("info")
+	assert.NotContains(t, out, "[DEBUG]")
+	assert.NotContains(t, out, "resources fetched")
+}
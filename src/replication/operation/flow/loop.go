@@ -0,0 +1,70 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// refuse to replicate the resources onto themselves: when the source and destination
+// registries are the same endpoint, the resource whose repository and tags are mapped
+// unchanged(e.g. no destination namespace is set) would be copied onto itself and the
+// deletion of it would delete the source
+func checkSelfReplication(policy *model.Policy, srcResources, dstResources []*model.Resource) error {
+	if !isSameEndpoint(policy.SrcRegistry, policy.DestRegistry) {
+		return nil
+	}
+	for i, src := range srcResources {
+		dst := dstResources[i]
+		if src.Metadata.Repository.Name != dst.Metadata.Repository.Name ||
+			!isSameTags(src.Metadata.Vtags, dst.Metadata.Vtags) {
+			continue
+		}
+		return fmt.Errorf("the source and destination registries are the same endpoint %s and the resource %s "+
+			"is mapped onto itself, set the destination namespace of the policy to avoid the self-replication",
+			policy.SrcRegistry.URL, getResourceName(src))
+	}
+	return nil
+}
+
+// whether the registries are the same endpoint, the scheme, case of the host,
+// default port and trailing slash are ignored
+func isSameEndpoint(r1, r2 *model.Registry) bool {
+	if r1 == nil || r2 == nil {
+		return false
+	}
+	e1, ok1 := normalizeEndpoint(r1.URL)
+	e2, ok2 := normalizeEndpoint(r2.URL)
+	return ok1 && ok2 && e1 == e2
+}
+
+func normalizeEndpoint(endpoint string) (string, bool) {
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || len(u.Host) == 0 {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = host + ":" + port
+	}
+	return host + strings.TrimRight(u.Path, "/"), true
+}
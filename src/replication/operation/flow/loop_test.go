@@ -0,0 +1,105 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsSameEndpoint(t *testing.T) {
+	cases := []struct {
+		url1 string
+		url2 string
+		same bool
+	}{
+		{"https://harbor.local", "https://harbor.local", true},
+		{"https://Harbor.Local/", "harbor.local", true},
+		{"http://harbor.local:80", "https://harbor.local:443", true},
+		{"https://harbor.local:8443", "https://harbor.local", false},
+		{"https://harbor.local", "https://mirror.local", false},
+		{"https://harbor.local/a", "https://harbor.local/b", false},
+		{"", "", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.same, isSameEndpoint(&model.Registry{URL: c.url1}, &model.Registry{URL: c.url2}))
+	}
+	assert.False(t, isSameEndpoint(nil, &model.Registry{URL: "https://harbor.local"}))
+}
+
+func TestCheckSelfReplication(t *testing.T) {
+	newPolicy := func(srcURL, dstURL string) *model.Policy {
+		return &model.Policy{
+			SrcRegistry: &model.Registry{
+				URL: srcURL,
+			},
+			DestRegistry: &model.Registry{
+				URL: dstURL,
+			},
+		}
+	}
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+
+	// the same endpoint with the identity mapping
+	policy := newPolicy("https://harbor.local", "https://harbor.local/")
+	err := checkSelfReplication(policy, resources, assembleDestinationResources(resources, policy))
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "library/hello-world")
+
+	// the destination namespace is set
+	policy.DestNamespace = "mirror"
+	err = checkSelfReplication(policy, resources, assembleDestinationResources(resources, policy))
+	assert.Nil(t, err)
+
+	// different endpoints
+	policy = newPolicy("https://harbor.local", "https://mirror.local")
+	err = checkSelfReplication(policy, resources, assembleDestinationResources(resources, policy))
+	assert.Nil(t, err)
+}
+
+func TestRunOfCopyFlowWithSelfReplication(t *testing.T) {
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+			URL:  "https://harbor.local",
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+			URL:  "https://harbor.local",
+		},
+	}
+	scheduler := &fakedScheduler{}
+	flow := NewCopyFlow(newFakedExecutionManager(), scheduler, 1, policy)
+	_, err := flow.Run(nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "self-replication")
+
+	// nothing is replicated by the plan either
+	_, err = BuildPlan(scheduler, policy)
+	assert.NotNil(t, err)
+}
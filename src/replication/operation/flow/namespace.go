@@ -0,0 +1,113 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"sync"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+// namespacePreparingScheduler does the prepare work for pushing(e.g. creating the namespace)
+// of each destination namespace just in time before submitting its first item, so the items
+// of the prepared namespaces are submitted without waiting for the other namespaces
+type namespacePreparingScheduler struct {
+	scheduler.Scheduler
+	adapter adp.Adapter
+	// namespace -> the destination resources under the namespace
+	resources map[string][]*model.Resource
+	lock      sync.Mutex
+	// namespace -> the result of the prepare work
+	prepared map[string]error
+}
+
+func newNamespacePreparingScheduler(sched scheduler.Scheduler, adapter adp.Adapter,
+	dstResources []*model.Resource) *namespacePreparingScheduler {
+	resources := map[string][]*model.Resource{}
+	for _, resource := range dstResources {
+		namespace := getNamespace(resource)
+		resources[namespace] = append(resources[namespace], resource)
+	}
+	return &namespacePreparingScheduler{
+		Scheduler: sched,
+		adapter:   adapter,
+		resources: resources,
+		prepared:  map[string]error{},
+	}
+}
+
+// Schedule submits the consecutive items of the same namespace together once the namespace
+// is prepared, the items are failed if the prepare work of their namespace fails
+func (n *namespacePreparingScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	var results []*scheduler.ScheduleResult
+	for start := 0; start < len(items); {
+		namespace := getNamespace(items[start].DstResource)
+		end := start + 1
+		for end < len(items) && getNamespace(items[end].DstResource) == namespace {
+			end++
+		}
+		batch := items[start:end]
+		start = end
+
+		err := n.prepare(namespace, batch)
+		var rs []*scheduler.ScheduleResult
+		if err == nil {
+			rs, err = n.Scheduler.Schedule(batch)
+		}
+		if err != nil {
+			for _, item := range batch {
+				rs = append(rs, &scheduler.ScheduleResult{
+					TaskID: item.TaskID,
+					Error:  err,
+				})
+			}
+		}
+		results = append(results, rs...)
+	}
+	return results, nil
+}
+
+// do the prepare work for the namespace only once, the result is cached
+func (n *namespacePreparingScheduler) prepare(namespace string, items []*scheduler.ScheduleItem) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	if err, exist := n.prepared[namespace]; exist {
+		return err
+	}
+	resources := n.resources[namespace]
+	if len(resources) == 0 {
+		for _, item := range items {
+			resources = append(resources, item.DstResource)
+		}
+	}
+	err := prepareForPush(n.adapter, resources)
+	if err != nil {
+		log.Errorf("failed to do the prepare work for the namespace %s: %v", namespace, err)
+	}
+	n.prepared[namespace] = err
+	return err
+}
+
+func getNamespace(resource *model.Resource) string {
+	if resource == nil || resource.Metadata == nil || resource.Metadata.Repository == nil {
+		return ""
+	}
+	namespace, _ := util.ParseNamespace(resource.Metadata.Repository.Name)
+	return namespace
+}
@@ -0,0 +1,136 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// records the prepare work into the events shared with the scheduler
+type fakedPreparingAdapter struct {
+	test.Adapter
+	events  *[]string
+	failure string
+}
+
+func (f *fakedPreparingAdapter) PrepareForPush(resources []*model.Resource) error {
+	*f.events = append(*f.events, "prepare "+strings.Join(getResourceNames(resources), ","))
+	if len(resources) > 0 && getNamespace(resources[0]) == f.failure {
+		return errors.New("failed to create the namespace")
+	}
+	return nil
+}
+
+// records the submitted items into the events shared with the adapter
+type fakedEventScheduler struct {
+	fakedScheduler
+	events *[]string
+}
+
+func (f *fakedEventScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	var names []string
+	for _, item := range items {
+		names = append(names, item.DstResource.Metadata.Repository.Name)
+	}
+	*f.events = append(*f.events, "schedule "+strings.Join(names, ","))
+	return f.fakedScheduler.Schedule(items)
+}
+
+func newNamespaceItems() ([]*scheduler.ScheduleItem, []*model.Resource) {
+	var items []*scheduler.ScheduleItem
+	var resources []*model.Resource
+	for i, name := range []string{"ns1/a", "ns1/b", "ns2/c"} {
+		resource := &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+			},
+		}
+		items = append(items, &scheduler.ScheduleItem{
+			TaskID:      int64(i + 1),
+			SrcResource: resource,
+			DstResource: resource,
+		})
+		resources = append(resources, resource)
+	}
+	return items, resources
+}
+
+func TestNamespacePreparingScheduler(t *testing.T) {
+	events := []string{}
+	items, resources := newNamespaceItems()
+	sched := newNamespacePreparingScheduler(&fakedEventScheduler{events: &events},
+		&fakedPreparingAdapter{events: &events}, resources)
+	// the items are submitted one by one
+	for _, item := range items {
+		results, err := sched.Schedule([]*scheduler.ScheduleItem{item})
+		require.Nil(t, err)
+		require.Equal(t, 1, len(results))
+		assert.Nil(t, results[0].Error)
+	}
+	// the namespace is prepared only once before its first item,
+	// but not before the items of the other namespaces
+	assert.Equal(t, []string{
+		"prepare ns1/a,ns1/b",
+		"schedule ns1/a",
+		"schedule ns1/b",
+		"prepare ns2/c",
+		"schedule ns2/c",
+	}, events)
+
+	// the items are submitted together
+	events = []string{}
+	items, resources = newNamespaceItems()
+	sched = newNamespacePreparingScheduler(&fakedEventScheduler{events: &events},
+		&fakedPreparingAdapter{events: &events}, resources)
+	results, err := sched.Schedule(items)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(results))
+	assert.Equal(t, []string{
+		"prepare ns1/a,ns1/b",
+		"schedule ns1/a,ns1/b",
+		"prepare ns2/c",
+		"schedule ns2/c",
+	}, events)
+}
+
+func TestNamespacePreparingSchedulerWithFailure(t *testing.T) {
+	events := []string{}
+	items, resources := newNamespaceItems()
+	sched := newNamespacePreparingScheduler(&fakedEventScheduler{events: &events},
+		&fakedPreparingAdapter{events: &events, failure: "ns1"}, resources)
+	results, err := sched.Schedule(items)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(results))
+	// the items of the namespace failed to be prepared aren't submitted
+	assert.NotNil(t, results[0].Error)
+	assert.NotNil(t, results[1].Error)
+	assert.Nil(t, results[2].Error)
+	assert.Equal(t, []string{
+		"prepare ns1/a,ns1/b",
+		"prepare ns2/c",
+		"schedule ns2/c",
+	}, events)
+}
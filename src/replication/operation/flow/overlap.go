@@ -0,0 +1,117 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+)
+
+// the interval of checking whether the previous executions of the policy finish
+// when the new execution is queued
+var overlapCheckInterval = 10 * time.Second
+
+// guard the execution against the previous executions of the policy still running
+// according to the concurrent execution policy. Only the executions created before
+// this one are checked, so the executions started at the same time don't wait for
+// each other. The skipped execution is marked with the status "Skipped" and the queued
+// one stops waiting with the error of the context once it's done. Returns whether the
+// flow can go on
+func guardConcurrentExecutions(ctx context.Context, executionMgr execution.Manager, executionID int64,
+	policy *model.Policy) (bool, error) {
+	switch policy.ConcurrentExecution {
+	case model.ConcurrentExecutionSkip:
+		running, err := getRunningExecution(executionMgr, executionID, policy.ID)
+		if err != nil {
+			return false, err
+		}
+		if running == 0 {
+			return true, nil
+		}
+		markExecutionOverlapped(executionMgr, executionID,
+			fmt.Sprintf("skipped as the execution %d of the policy is still running", running))
+		log.Infof("the execution %d of the policy %d is still running, skip the execution %d",
+			running, policy.ID, executionID)
+		return false, nil
+	case model.ConcurrentExecutionQueue:
+		for {
+			running, err := getRunningExecution(executionMgr, executionID, policy.ID)
+			if err != nil {
+				return false, err
+			}
+			if running == 0 {
+				return true, nil
+			}
+			stopped, err := isExecutionStopped(executionMgr, executionID)
+			if err != nil {
+				return false, err
+			}
+			if stopped {
+				log.Debugf("the queued execution %d is stopped, stop the flow", executionID)
+				return false, nil
+			}
+			log.Debugf("the execution %d of the policy %d is still running, the execution %d is queued",
+				running, policy.ID, executionID)
+			select {
+			case <-ctx.Done():
+				log.Debugf("the queued execution %d is cancelled, stop the flow", executionID)
+				return false, ctx.Err()
+			case <-time.After(overlapCheckInterval):
+			}
+		}
+	default:
+		return true, nil
+	}
+}
+
+// returns the ID of one execution of the policy created before the specified
+// execution and still running, 0 if there is none
+func getRunningExecution(executionMgr execution.Manager, executionID, policyID int64) (int64, error) {
+	_, executions, err := executionMgr.List(&models.ExecutionQuery{
+		PolicyID: policyID,
+		Statuses: []string{models.ExecutionStatusInProgress},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to list the running executions of the policy %d: %v", policyID, err)
+	}
+	for _, e := range executions {
+		// the status of the execution is refreshed by the tasks when listing,
+		// so check it again
+		if e.ID < executionID && e.Status == models.ExecutionStatusInProgress {
+			return e.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// mark the execution skipped by the running one, it has no task
+func markExecutionOverlapped(mgr execution.Manager, id int64, message string) {
+	err := mgr.Update(
+		&models.Execution{
+			ID:         id,
+			Status:     models.ExecutionStatusSkipped,
+			StatusText: message,
+			EndTime:    time.Now(),
+		}, "Status", "StatusText", "EndTime")
+	if err != nil {
+		log.Errorf("failed to update the execution %d: %v", id, err)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the executions 1 and 2 of the policy 1 are created, the execution 1 keeps
+// running for the specified times of listing
+type fakedRunningExecutionManager struct {
+	*test.ExecutionManager
+	running int
+	listed  int
+}
+
+func newRunningExecutionManager(running int) *fakedRunningExecutionManager {
+	mgr := test.NewExecutionManager()
+	for i := 0; i < 2; i++ {
+		mgr.Create(&models.Execution{
+			PolicyID: 1,
+			Status:   models.ExecutionStatusInProgress,
+		})
+	}
+	return &fakedRunningExecutionManager{
+		ExecutionManager: mgr,
+		running:          running,
+	}
+}
+
+func (f *fakedRunningExecutionManager) List(query ...*models.ExecutionQuery) (int64, []*models.Execution, error) {
+	f.listed++
+	if f.listed > f.running {
+		f.Update(&models.Execution{
+			ID:     1,
+			Status: models.ExecutionStatusSucceed,
+		}, "Status")
+	}
+	return f.ExecutionManager.List(query...)
+}
+
+func newConcurrentExecutionPolicy(concurrentExecution string) *model.Policy {
+	return &model.Policy{
+		ID: 1,
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		ConcurrentExecution: concurrentExecution,
+	}
+}
+
+func TestRunOfCopyFlowWithConcurrentExecutionSkipped(t *testing.T) {
+	mgr := newRunningExecutionManager(1)
+	n, err := NewCopyFlow(mgr, &fakedScheduler{}, 2, newConcurrentExecutionPolicy(model.ConcurrentExecutionSkip)).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	execution, err := mgr.Get(2)
+	require.Nil(t, err)
+	assert.Equal(t, models.ExecutionStatusSkipped, execution.Status)
+	assert.Contains(t, execution.StatusText, "execution 1 of the policy is still running")
+	assert.Equal(t, 0, len(mgr.Tasks()))
+
+	// the execution created before the running one isn't skipped
+	mgr = newRunningExecutionManager(1)
+	n, err = NewCopyFlow(mgr, &fakedScheduler{}, 1, newConcurrentExecutionPolicy(model.ConcurrentExecutionSkip)).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestRunOfCopyFlowWithConcurrentExecutionQueued(t *testing.T) {
+	interval := overlapCheckInterval
+	overlapCheckInterval = time.Millisecond
+	defer func() {
+		overlapCheckInterval = interval
+	}()
+
+	// the execution is queued until the running one finishes
+	mgr := newRunningExecutionManager(2)
+	n, err := NewCopyFlow(mgr, &fakedScheduler{}, 2, newConcurrentExecutionPolicy(model.ConcurrentExecutionQueue)).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 3, mgr.listed)
+
+	// the queued execution stops waiting once the context is cancelled
+	overlapCheckInterval = time.Hour
+	mgr = newRunningExecutionManager(100)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+	start := time.Now()
+	n, err = NewCopyFlow(mgr, &fakedScheduler{}, 2, newConcurrentExecutionPolicy(model.ConcurrentExecutionQueue)).Run(ctx)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 0, n)
+	assert.True(t, time.Since(start) < time.Minute)
+}
+
+func TestRunOfCopyFlowWithConcurrentExecutionAllowed(t *testing.T) {
+	mgr := newRunningExecutionManager(1)
+	n, err := NewCopyFlow(mgr, &fakedScheduler{}, 2, newConcurrentExecutionPolicy("")).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0, mgr.listed)
+}
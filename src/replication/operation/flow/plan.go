@@ -0,0 +1,327 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// Plan contains the items that would be scheduled by one execution of the copy
+// flow. It can be exported for reviewing and executed later exactly as reviewed
+type Plan struct {
+	PolicyID int64                     `json:"policy_id"`
+	Items    []*scheduler.ScheduleItem `json:"items"`
+	// the decisions made by the filters, only recorded when the policy traces the filters
+	FilterDecisions []*FilterDecision `json:"filter_decisions,omitempty"`
+	// the resource types and namespaces failed to be fetched, only recorded when
+	// the policy fetches the resources in best effort
+	FetchFailures []string `json:"fetch_failures,omitempty"`
+}
+
+// BuildPlan runs the stages of the copy flow as a dry run: the resources are
+// fetched, filtered and assembled as the copy flow does, but no task is created.
+// The pre-copy webhook, quota and byte budget are applied when the plan is executed, and
+// the plan always covers all the resources no matter whether the policy is incremental
+func BuildPlan(sched scheduler.Scheduler, policy *model.Policy) (*Plan, error) {
+	srcAdapter, dstAdapter, err := initialize(policy)
+	if err != nil {
+		return nil, err
+	}
+	srcResources, failures, err := fetchResources(context.Background(), srcAdapter, policy, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkSrcNamespaces(srcAdapter, policy, srcResources); err != nil {
+		return nil, err
+	}
+	var trace *FilterTrace
+	if policy.TraceFilters {
+		trace = &FilterTrace{}
+	}
+	srcResources, err = traceFilterResources(srcResources, policy.Filters, trace, nil)
+	if err != nil {
+		return nil, err
+	}
+	srcResources = filterEmptyRepositories(srcResources, policy)
+	srcResources, err = filterDormantResources(srcAdapter, srcResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	srcResources, err = selectTags(srcAdapter, srcResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	srcResources, err = filterByPlatform(srcAdapter, srcResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	srcResources, err = filterByVisibility(srcResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	srcResources, err = filterByPullCount(srcResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{
+		PolicyID: policy.ID,
+		Items:    []*scheduler.ScheduleItem{},
+	}
+	if trace != nil {
+		plan.FilterDecisions = trace.Decisions
+	}
+	for _, failure := range failures {
+		plan.FetchFailures = append(plan.FetchFailures, failure.String())
+	}
+	if len(srcResources) == 0 {
+		return plan, nil
+	}
+
+	srcResources = assembleSourceResources(srcResources, policy)
+	dstResources := assembleDestinationResources(srcResources, policy)
+	if err = checkSelfReplication(policy, srcResources, dstResources); err != nil {
+		return nil, err
+	}
+	srcResources, dstResources, err = filterUnmodifiedResources(srcAdapter, dstAdapter,
+		srcResources, dstResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	srcResources, dstResources, err = limitTagFanOut(srcAdapter, dstAdapter,
+		srcResources, dstResources, policy)
+	if err != nil {
+		return nil, err
+	}
+	if len(srcResources) == 0 {
+		return plan, nil
+	}
+	items, err := preprocess(sched, srcResources, dstResources)
+	if err != nil {
+		return nil, err
+	}
+	plan.Items = items
+	return plan, nil
+}
+
+// Export the plan as JSON. The registries of the resources carrying the credentials
+// aren't exported, the ones of the policy are used when executing the plan
+func (p *Plan) Export(w io.Writer) error {
+	plan := &Plan{
+		PolicyID:        p.PolicyID,
+		Items:           []*scheduler.ScheduleItem{},
+		FilterDecisions: p.FilterDecisions,
+		FetchFailures:   p.FetchFailures,
+	}
+	for _, item := range p.Items {
+		src, dst := *item.SrcResource, *item.DstResource
+		src.Registry, dst.Registry = nil, nil
+		plan.Items = append(plan.Items, &scheduler.ScheduleItem{
+			SrcResource: &src,
+			DstResource: &dst,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(plan)
+}
+
+// ImportPlan imports the plan exported by "Export"
+func ImportPlan(r io.Reader) (*Plan, error) {
+	plan := &Plan{}
+	if err := json.NewDecoder(r).Decode(plan); err != nil {
+		return nil, fmt.Errorf("failed to decode the plan: %v", err)
+	}
+	return plan, nil
+}
+
+type planFlow struct {
+	executionID  int64
+	policy       *model.Policy
+	plan         *Plan
+	executionMgr execution.Manager
+	scheduler    scheduler.Scheduler
+	logger       *log.Logger
+}
+
+// NewPlanFlow returns an instance of the flow which executes the plan without fetching
+// the resources again. The plan is validated against the current policy first
+func NewPlanFlow(executionMgr execution.Manager, scheduler scheduler.Scheduler,
+	executionID int64, policy *model.Policy, plan *Plan) Flow {
+	return &planFlow{
+		executionMgr: executionMgr,
+		scheduler:    scheduler,
+		executionID:  executionID,
+		policy:       policy,
+		plan:         plan,
+		logger:       newFlowLogger(policy),
+	}
+}
+
+func (p *planFlow) Run(param interface{}) (int, error) {
+	sum := newSummary()
+	n, err := p.run(getContext(param), sum)
+	sum.emit(p.executionMgr, p.executionID, err)
+	return n, err
+}
+
+func (p *planFlow) run(ctx context.Context, sum *summary) (int, error) {
+	proceed, err := guardConcurrentExecutions(ctx, p.executionMgr, p.executionID, p.policy)
+	if err != nil || !proceed {
+		return 0, err
+	}
+	items, err := validatePlan(p.plan, p.policy)
+	if err != nil {
+		return 0, err
+	}
+	sum.Fetched, sum.Filtered = len(items), len(items)
+	p.logger.Debugf("%d items of the plan validated for the execution %d", len(items), p.executionID)
+	if len(items) == 0 {
+		markExecutionSuccess(p.executionMgr, p.executionID, "no resources need to be replicated")
+		p.logger.Infof("no resources need to be replicated for the execution %d, skip", p.executionID)
+		return 0, nil
+	}
+
+	srcAdapter, dstAdapter, err := initialize(p.policy)
+	if err != nil {
+		return 0, err
+	}
+	var dstResources []*model.Resource
+	for _, item := range items {
+		dstResources = append(dstResources, item.DstResource)
+	}
+	sched := p.scheduler
+	if p.policy.LazyNamespaceCreation {
+		sched = newNamespacePreparingScheduler(p.scheduler, dstAdapter, dstResources)
+	} else if err = prepareForPush(dstAdapter, dstResources); err != nil {
+		return 0, err
+	}
+	items, err = rotateDeferredRepositories(p.executionMgr, p.executionID, items, p.policy)
+	if err != nil {
+		return 0, err
+	}
+	items, err = createTasks(p.executionMgr, p.executionID, items, p.policy.TagsPerTask)
+	if err != nil {
+		return 0, err
+	}
+	created := len(items)
+	sum.Created = created
+	p.logger.Debugf("%d tasks created for the execution %d", created, p.executionID)
+	items, sum.Failed = validateByWebhook(p.executionMgr, items, p.policy)
+	items = applyNamespaceRepositoryCap(p.executionMgr, items, p.policy)
+	items, err = applyNamespaceQuota(srcAdapter, dstAdapter, p.executionMgr, items)
+	if err != nil {
+		return 0, err
+	}
+	var bytes int64
+	items, bytes, err = applyByteBudget(srcAdapter, dstAdapter, p.executionMgr, items, p.policy)
+	if err != nil {
+		return 0, err
+	}
+	sum.estimateBytes(p.policy, bytes)
+	sum.Skipped = created - len(items) - sum.Failed
+	if len(items) == 0 {
+		p.logger.Infof("no tasks of the execution %d need to be submitted, skip", p.executionID)
+		return 0, nil
+	}
+	p.logger.Debugf("%d tasks of the execution %d to be submitted", len(items), p.executionID)
+
+	if err = checkDestinationHealth(dstAdapter, p.executionMgr, items, p.policy); err != nil {
+		sum.Failed += len(items)
+		return len(items), err
+	}
+	return schedule(ctx, sched, p.executionMgr, items, p.policy, sum)
+}
+
+// validate the plan against the current policy: the source resources must still match
+// the filters and the destination resources must be the ones assembled by the policy.
+// The filters depending on the state of the registries(e.g. "modified") aren't checked.
+// Returns the items filled with the registries of the policy
+func validatePlan(plan *Plan, policy *model.Policy) ([]*scheduler.ScheduleItem, error) {
+	if plan == nil {
+		return nil, fmt.Errorf("empty plan")
+	}
+	if plan.PolicyID != policy.ID {
+		return nil, fmt.Errorf("the plan is built for the policy %d rather than %d", plan.PolicyID, policy.ID)
+	}
+	var items []*scheduler.ScheduleItem
+	for i, item := range plan.Items {
+		if item == nil || !isValidPlanResource(item.SrcResource) || !isValidPlanResource(item.DstResource) {
+			return nil, fmt.Errorf("the item %d of the plan is invalid", i)
+		}
+		// the filters modify the tags of the resource, so apply them on a copy
+		src := copyResource(item.SrcResource)
+		resources, err := filterResources([]*model.Resource{src}, policy.Filters)
+		if err != nil {
+			return nil, err
+		}
+		resources, err = filterByVisibility(resources, policy)
+		if err != nil {
+			return nil, err
+		}
+		if len(resources) == 0 || len(src.Metadata.Vtags) != len(item.SrcResource.Metadata.Vtags) {
+			return nil, fmt.Errorf("the source resource %s of the plan doesn't match the policy",
+				getResourceName(item.SrcResource))
+		}
+		src = assembleSourceResources(resources, policy)[0]
+		dst := assembleDestinationResources([]*model.Resource{src}, policy)[0]
+		if err = checkSelfReplication(policy, []*model.Resource{src}, []*model.Resource{dst}); err != nil {
+			return nil, err
+		}
+		if !isSameTags(src.Metadata.Vtags, item.SrcResource.Metadata.Vtags) ||
+			dst.Metadata.Repository.Name != item.DstResource.Metadata.Repository.Name ||
+			!isSameTags(dst.Metadata.Vtags, item.DstResource.Metadata.Vtags) {
+			return nil, fmt.Errorf("the destination resource %s of the plan doesn't match the policy",
+				getResourceName(item.DstResource))
+		}
+		items = append(items, &scheduler.ScheduleItem{
+			SrcResource: src,
+			DstResource: dst,
+		})
+	}
+	return items, nil
+}
+
+func isValidPlanResource(resource *model.Resource) bool {
+	return resource != nil && resource.Metadata != nil && resource.Metadata.Repository != nil
+}
+
+func isSameTags(tags1, tags2 []string) bool {
+	if len(tags1) != len(tags2) {
+		return false
+	}
+	for i := range tags1 {
+		if tags1[i] != tags2[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// make a copy of the resource whose tags can be modified independently
+func copyResource(resource *model.Resource) *model.Resource {
+	res := *resource
+	metadata := *resource.Metadata
+	metadata.Vtags = append([]string(nil), resource.Metadata.Vtags...)
+	res.Metadata = &metadata
+	return &res
+}
@@ -0,0 +1,133 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPlanPolicy() *model.Policy {
+	return &model.Policy{
+		ID: 1,
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+			Credential: &model.Credential{
+				AccessKey:    "admin",
+				AccessSecret: "source-secret",
+			},
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+			Credential: &model.Credential{
+				AccessKey:    "admin",
+				AccessSecret: "destination-secret",
+			},
+		},
+		DestNamespace: "mirror",
+	}
+}
+
+func TestExportAndImportPlan(t *testing.T) {
+	policy := newPlanPolicy()
+	plan, err := BuildPlan(&fakedScheduler{}, policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(plan.Items))
+	assert.Equal(t, int64(1), plan.PolicyID)
+
+	buffer := &bytes.Buffer{}
+	require.Nil(t, plan.Export(buffer))
+	// the credentials aren't exported
+	assert.NotContains(t, buffer.String(), "secret")
+	// the plan itself isn't modified by the exporting
+	assert.NotNil(t, plan.Items[0].SrcResource.Registry)
+
+	imported, err := ImportPlan(buffer)
+	require.Nil(t, err)
+	assert.Equal(t, plan.PolicyID, imported.PolicyID)
+	require.Equal(t, len(plan.Items), len(imported.Items))
+	assert.Equal(t, "mirror/hello-world", imported.Items[0].DstResource.Metadata.Repository.Name)
+	for i, item := range imported.Items {
+		assert.Equal(t, plan.Items[i].SrcResource.Type, item.SrcResource.Type)
+		assert.Equal(t, plan.Items[i].SrcResource.Metadata.Repository.Name, item.SrcResource.Metadata.Repository.Name)
+		assert.Equal(t, plan.Items[i].DstResource.Metadata.Repository.Name, item.DstResource.Metadata.Repository.Name)
+		assert.Equal(t, plan.Items[i].DstResource.Metadata.Vtags, item.DstResource.Metadata.Vtags)
+	}
+
+	// invalid JSON
+	_, err = ImportPlan(bytes.NewBufferString("{"))
+	assert.NotNil(t, err)
+}
+
+func TestRunOfPlanFlow(t *testing.T) {
+	policy := newPlanPolicy()
+	plan, err := BuildPlan(&fakedScheduler{}, policy)
+	require.Nil(t, err)
+	buffer := &bytes.Buffer{}
+	require.Nil(t, plan.Export(buffer))
+	imported, err := ImportPlan(buffer)
+	require.Nil(t, err)
+
+	flow := NewPlanFlow(newFakedExecutionManager(), &fakedScheduler{}, 1, policy, imported)
+	n, err := flow.Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+}
+
+func TestValidatePlan(t *testing.T) {
+	policy := newPlanPolicy()
+	newPlan := func() *Plan {
+		plan, err := BuildPlan(&fakedScheduler{}, newPlanPolicy())
+		require.Nil(t, err)
+		return plan
+	}
+
+	// pass, the registries are filled with the ones of the policy
+	items, err := validatePlan(newPlan(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	assert.Equal(t, policy.SrcRegistry, items[0].SrcResource.Registry)
+	assert.Equal(t, policy.DestRegistry, items[0].DstResource.Registry)
+
+	// nil plan
+	_, err = validatePlan(nil, policy)
+	assert.NotNil(t, err)
+
+	// built for another policy
+	plan := newPlan()
+	plan.PolicyID = 2
+	_, err = validatePlan(plan, policy)
+	assert.NotNil(t, err)
+
+	// the destination is tampered
+	plan = newPlan()
+	plan.Items[0].DstResource.Metadata.Repository.Name = "others/hello-world"
+	_, err = validatePlan(plan, policy)
+	assert.NotNil(t, err)
+
+	// the policy is changed and the resources don't match the filters anymore
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: "release-*",
+		},
+	}
+	_, err = validatePlan(newPlan(), policy)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,127 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// keep only the sub-manifests of the platforms specified by the "platform" filter for the
+// multi-arch images. The digests of the selected sub-manifests are recorded in the
+// "ExtendedInfo" of the resources, so the reduced manifest lists are copied. The tags
+// having no matched platform are dropped, the single-arch images are kept as they are
+func filterByPlatform(srcAdapter adp.Adapter, resources []*model.Resource,
+	policy *model.Policy) ([]*model.Resource, error) {
+	var platformFilter *model.Filter
+	var platforms []string
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypePlatform {
+			continue
+		}
+		var err error
+		platforms, err = filter.GetPlatforms()
+		if err != nil {
+			return nil, err
+		}
+		platformFilter = filter
+		break
+	}
+	if platformFilter == nil || len(platforms) == 0 {
+		return resources, nil
+	}
+	registry, ok := srcAdapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, fmt.Errorf("the source adapter doesn't implement the ImageRegistry interface, cannot apply the platform filter")
+	}
+	var result []*model.Resource
+	for _, resource := range resources {
+		// only the images have the platforms
+		if resource.Type != model.ResourceTypeImage || !platformFilter.AppliesTo(resource.Type) ||
+			resource.Deleted || resource.Metadata == nil || len(resource.Metadata.Vtags) == 0 {
+			result = append(result, resource)
+			continue
+		}
+		repository := resource.Metadata.Repository.Name
+		selected := map[string][]string{}
+		var tags []string
+		for _, tag := range resource.Metadata.Vtags {
+			manifest, _, err := registry.PullManifest(repository, tag, []string{
+				schema1.MediaTypeManifest,
+				schema2.MediaTypeManifest,
+				manifestlist.MediaTypeManifestList,
+				adp.MediaTypeOCIManifest,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to pull the manifest of %s:%s: %v", repository, tag, err)
+			}
+			list, ok := manifest.(*manifestlist.DeserializedManifestList)
+			if !ok {
+				// the single-arch image
+				tags = append(tags, tag)
+				continue
+			}
+			digests := selectPlatforms(list, platforms)
+			if len(digests) == 0 {
+				log.Debugf("none of the platforms of %s:%s matches %v, skip it", repository, tag, platforms)
+				continue
+			}
+			selected[tag] = digests
+			tags = append(tags, tag)
+		}
+		if len(tags) == 0 {
+			continue
+		}
+		resource.Metadata.Vtags = tags
+		if len(selected) > 0 {
+			// the "ExtendedInfo" may be shared, don't change it in place
+			info := map[string]interface{}{}
+			for k, v := range resource.ExtendedInfo {
+				info[k] = v
+			}
+			info[model.ExtendedInfoPlatformDigests] = selected
+			resource.ExtendedInfo = info
+		}
+		result = append(result, resource)
+	}
+	return result, nil
+}
+
+// returns the digests of the sub-manifests of the manifest list matching the platforms
+func selectPlatforms(list *manifestlist.DeserializedManifestList, platforms []string) []string {
+	var digests []string
+	for _, descriptor := range list.Manifests {
+		platform := strings.ToLower(descriptor.Platform.OS + "/" + descriptor.Platform.Architecture)
+		variant := platform
+		if len(descriptor.Platform.Variant) > 0 {
+			variant = platform + "/" + strings.ToLower(descriptor.Platform.Variant)
+		}
+		for _, p := range platforms {
+			// "os/arch" matches all the variants
+			if p == platform || p == variant {
+				digests = append(digests, descriptor.Digest.String())
+				break
+			}
+		}
+	}
+	return digests
+}
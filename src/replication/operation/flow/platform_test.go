@@ -0,0 +1,156 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	amd64Digest   = "sha256:0000000000000000000000000000000000000000000000000000000000000001"
+	arm64Digest   = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+	s390xDigest   = "sha256:0000000000000000000000000000000000000000000000000000000000000003"
+	windowsDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000004"
+)
+
+func newManifestDescriptor(dgt, os, arch, variant string) manifestlist.ManifestDescriptor {
+	return manifestlist.ManifestDescriptor{
+		Descriptor: distribution.Descriptor{
+			MediaType: schema2.MediaTypeManifest,
+			Size:      1024,
+			Digest:    digest.Digest(dgt),
+		},
+		Platform: manifestlist.PlatformSpec{
+			OS:           os,
+			Architecture: arch,
+			Variant:      variant,
+		},
+	}
+}
+
+// the tag "multi" is the multi-arch image of linux/amd64, linux/arm64/v8 and linux/s390x,
+// the tag "windows" is the one of windows/amd64 and other tags are single-arch images
+func newPlatformAdapter(t *testing.T) *test.Adapter {
+	adapter := &test.Adapter{}
+	addManifestList := func(repository, tag string, descriptors ...manifestlist.ManifestDescriptor) {
+		list, err := manifestlist.FromDescriptors(descriptors)
+		require.Nil(t, err)
+		mediaType, payload, err := list.Payload()
+		require.Nil(t, err)
+		adapter.AddManifest(repository, tag, mediaType, payload)
+	}
+	addManifestList("library/hello-world", "multi",
+		newManifestDescriptor(amd64Digest, "linux", "amd64", ""),
+		newManifestDescriptor(arm64Digest, "linux", "arm64", "v8"),
+		newManifestDescriptor(s390xDigest, "linux", "s390x", ""))
+	for _, repository := range []string{"library/hello-world", "library/windows"} {
+		addManifestList(repository, "windows", newManifestDescriptor(windowsDigest, "windows", "amd64", ""))
+	}
+	manifest, err := schema2.FromStruct(schema2.Manifest{Versioned: schema2.SchemaVersion})
+	require.Nil(t, err)
+	mediaType, payload, err := manifest.Payload()
+	require.Nil(t, err)
+	adapter.AddManifest("library/hello-world", "single", mediaType, payload)
+	return adapter
+}
+
+func TestFilterByPlatform(t *testing.T) {
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"multi", "windows", "single"},
+				},
+				ExtendedInfo: map[string]interface{}{
+					model.ExtendedInfoPublic: true,
+				},
+			},
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/windows",
+					},
+					Vtags: []string{"windows"},
+				},
+			},
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/harbor",
+					},
+					Vtags: []string{"1.0"},
+				},
+			},
+		}
+	}
+
+	// no platform filter
+	policy := &model.Policy{}
+	resources, err := filterByPlatform(newPlatformAdapter(t), newResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(resources))
+	assert.Nil(t, resources[0].GetPlatformDigests())
+
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypePlatform,
+			Value: []interface{}{"linux/amd64", "Linux/ARM64"},
+		},
+	}
+	origin := newResources()
+	info := origin[0].ExtendedInfo
+	resources, err = filterByPlatform(newPlatformAdapter(t), origin, policy)
+	require.Nil(t, err)
+	// the image without the matched platform is dropped, the chart is kept
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, "library/hello-world:[multi,single]", getResourceName(resources[0]))
+	assert.Equal(t, map[string][]string{
+		"multi": {amd64Digest, arm64Digest},
+	}, resources[0].GetPlatformDigests())
+	public, _ := resources[0].IsPublic()
+	assert.True(t, public)
+	// the origin "ExtendedInfo" isn't changed
+	_, exist := info[model.ExtendedInfoPlatformDigests]
+	assert.False(t, exist)
+	assert.Equal(t, "library/harbor:[1.0]", getResourceName(resources[1]))
+
+	// the variant is matched
+	policy.Filters[0].Value = []string{"linux/arm64/v7"}
+	resources, err = filterByPlatform(newPlatformAdapter(t), newResources(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, "library/hello-world:[single]", getResourceName(resources[0]))
+	policy.Filters[0].Value = []string{"linux/arm64/v8"}
+	resources, err = filterByPlatform(newPlatformAdapter(t), newResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, map[string][]string{
+		"multi": {arm64Digest},
+	}, resources[0].GetPlatformDigests())
+}
@@ -0,0 +1,85 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+// Progress is the progress of scheduling the tasks of an execution
+type Progress struct {
+	// the count of all tasks being scheduled
+	Total int
+	// the count of tasks submitted successfully(moved to pending)
+	Scheduled int
+	// the count of tasks failed to be submitted
+	Failed int
+	// the count of tasks not submitted yet
+	Remaining int
+}
+
+// ProgressFunc is called with the current progress every time a task is submitted
+// or fails to be submitted, e.g. to drive the progress bar of the execution
+type ProgressFunc func(*Progress)
+
+// track the progress of scheduling and report it to the callbacks,
+// the nil tracker does nothing
+type progressTracker struct {
+	progress  Progress
+	callbacks []ProgressFunc
+}
+
+// returns nil if there is no callback, so the tracker is free if unused
+func newProgressTracker(total int, callbacks []ProgressFunc) *progressTracker {
+	var funcs []ProgressFunc
+	for _, callback := range callbacks {
+		if callback != nil {
+			funcs = append(funcs, callback)
+		}
+	}
+	if len(funcs) == 0 {
+		return nil
+	}
+	return &progressTracker{
+		progress: Progress{
+			Total:     total,
+			Remaining: total,
+		},
+		callbacks: funcs,
+	}
+}
+
+func (p *progressTracker) scheduled() {
+	if p == nil {
+		return
+	}
+	p.progress.Scheduled++
+	p.report()
+}
+
+func (p *progressTracker) failed() {
+	if p == nil {
+		return
+	}
+	p.progress.Failed++
+	p.report()
+}
+
+func (p *progressTracker) report() {
+	if p.progress.Remaining > 0 {
+		p.progress.Remaining--
+	}
+	for _, callback := range p.callbacks {
+		// pass a copy, so the callbacks cannot change the tracked progress
+		progress := p.progress
+		callback(&progress)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the task 2 fails to be submitted
+type fakedPartlyFailingScheduler struct {
+	fakedScheduler
+}
+
+func (f *fakedPartlyFailingScheduler) Schedule(items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, error) {
+	results, err := f.fakedScheduler.Schedule(items)
+	for _, result := range results {
+		if result.TaskID == 2 {
+			result.Error = errors.New("error")
+		}
+	}
+	return results, err
+}
+
+func TestScheduleWithProgress(t *testing.T) {
+	items := []*scheduler.ScheduleItem{
+		{TaskID: 1, SrcResource: &model.Resource{}, DstResource: &model.Resource{}},
+		{TaskID: 2, SrcResource: &model.Resource{}, DstResource: &model.Resource{}},
+		{TaskID: 3, SrcResource: &model.Resource{}, DstResource: &model.Resource{}},
+	}
+	var progresses []Progress
+	n, err := schedule(context.Background(), &fakedPartlyFailingScheduler{}, newFakedExecutionManager(), items, nil, nil,
+		func(progress *Progress) {
+			progresses = append(progresses, *progress)
+		})
+	require.Nil(t, err)
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []Progress{
+		{Total: 3, Scheduled: 1, Failed: 0, Remaining: 2},
+		{Total: 3, Scheduled: 1, Failed: 1, Remaining: 1},
+		{Total: 3, Scheduled: 2, Failed: 1, Remaining: 0},
+	}, progresses)
+
+	// the nil callback is ignored
+	n, err = schedule(context.Background(), &fakedScheduler{}, newFakedExecutionManager(), items, nil, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestScheduleInFlightWithProgress(t *testing.T) {
+	interval := inFlightPollInterval
+	inFlightPollInterval = time.Millisecond
+	defer func() {
+		inFlightPollInterval = interval
+	}()
+
+	mgr := newInFlightExecutionManager(models.TaskStatusSucceed)
+	calls := 0
+	var last *Progress
+	_, err := schedule(context.Background(), &fakedBatchScheduler{}, mgr, newInFlightItems(), &model.Policy{MaxInFlightTasks: 10},
+		nil, func(progress *Progress) {
+			calls++
+			last = progress
+		})
+	require.Nil(t, err)
+	assert.Equal(t, 28, calls)
+	assert.Equal(t, &Progress{Total: 28, Scheduled: 28, Remaining: 0}, last)
+}
@@ -0,0 +1,67 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// drop the resources whose repositories are pulled less than the threshold
+// specified by the pull count filter
+func filterByPullCount(resources []*model.Resource, policy *model.Policy) ([]*model.Resource, error) {
+	var pullCountFilter *model.Filter
+	var threshold int64
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypePullCount {
+			continue
+		}
+		count, err := filter.GetPullCount()
+		if err != nil || count < 0 {
+			return nil, fmt.Errorf("%v is not a valid pull count", filter.Value)
+		}
+		pullCountFilter, threshold = filter, count
+		break
+	}
+	if pullCountFilter == nil {
+		return resources, nil
+	}
+	var result []*model.Resource
+	for _, resource := range resources {
+		// the filter scoped to other resource types is ignored
+		if !pullCountFilter.AppliesTo(resource.Type) {
+			result = append(result, resource)
+			continue
+		}
+		count, known := resource.GetPullCount()
+		if !known {
+			if policy.IncludeUnknownPullCount {
+				result = append(result, resource)
+			} else {
+				log.Debugf("the pull count of %s is unknown, skip", getResourceName(resource))
+			}
+			continue
+		}
+		if count >= threshold {
+			result = append(result, resource)
+		} else {
+			log.Debugf("the pull count %d of %s is below %d, skip", count, getResourceName(resource), threshold)
+		}
+	}
+	log.Debug("filter resources by pull count completed")
+	return result, nil
+}
@@ -0,0 +1,105 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPullCountResource(name string, resourceType model.ResourceType, count interface{}) *model.Resource {
+	resource := &model.Resource{
+		Type: resourceType,
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: name,
+			},
+			Vtags: []string{"latest"},
+		},
+	}
+	if count != nil {
+		resource.ExtendedInfo = map[string]interface{}{
+			model.ExtendedInfoPullCount: count,
+		}
+	}
+	return resource
+}
+
+func newPullCountResources() []*model.Resource {
+	return []*model.Resource{
+		newPullCountResource("library/hello-world", model.ResourceTypeImage, int64(100)),
+		newPullCountResource("team/app", model.ResourceTypeImage, int64(10)),
+		newPullCountResource("library/harbor", model.ResourceTypeChart, int64(1)),
+		newPullCountResource("unknown/app", model.ResourceTypeImage, nil),
+	}
+}
+
+func TestFilterByPullCount(t *testing.T) {
+	// no pull count filter
+	policy := &model.Policy{}
+	resources, err := filterByPullCount(newPullCountResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(resources))
+
+	// above the threshold
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypePullCount,
+			Value: float64(50),
+		},
+	}
+	resources, err = filterByPullCount(newPullCountResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"library/hello-world"}, getResourceNames(resources))
+
+	// the resource whose pull count equals to the threshold is kept
+	policy.Filters[0].Value = 10
+	resources, err = filterByPullCount(newPullCountResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"library/hello-world", "team/app"}, getResourceNames(resources))
+
+	// include the resources whose pull count is unknown
+	policy.IncludeUnknownPullCount = true
+	resources, err = filterByPullCount(newPullCountResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"library/hello-world", "team/app", "unknown/app"}, getResourceNames(resources))
+
+	// the filter is scoped to images
+	policy.IncludeUnknownPullCount = false
+	policy.Filters[0].Scope = model.ResourceTypeImage
+	resources, err = filterByPullCount(newPullCountResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"library/hello-world", "team/app", "library/harbor"}, getResourceNames(resources))
+
+	// invalid pull count
+	policy.Filters[0].Value = "10"
+	_, err = filterByPullCount(newPullCountResources(), policy)
+	assert.NotNil(t, err)
+}
+
+func TestFilterResourcesWithPullCountFilter(t *testing.T) {
+	// the pull count filter is ignored by "filterResources"
+	resources, err := filterResources(newPullCountResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypePullCount,
+			Value: float64(50),
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(resources))
+}
@@ -0,0 +1,84 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+// check the quota of the destination namespaces before submitting the items: the items
+// of the namespace whose remaining quota cannot hold them are marked as "over quota" and
+// the items of other namespaces proceed. Nothing is checked if the destination adapter
+// doesn't implement the "QuotaChecker" interface
+func applyNamespaceQuota(srcAdapter, dstAdapter adp.Adapter, executionMgr execution.Manager,
+	items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleItem, error) {
+	checker, ok := dstAdapter.(adp.QuotaChecker)
+	if !ok || len(items) == 0 {
+		return items, nil
+	}
+
+	// the total size of the items per destination namespace
+	sizes := map[string]int64{}
+	for _, item := range items {
+		size, err := getResourceSize(srcAdapter, item.SrcResource)
+		if err != nil {
+			return nil, err
+		}
+		namespace, _ := util.ParseNamespace(item.DstResource.Metadata.Repository.Name)
+		sizes[namespace] += size
+	}
+
+	overQuota := map[string]bool{}
+	for namespace, size := range sizes {
+		if size == 0 {
+			continue
+		}
+		remaining, err := checker.GetRemainingQuota(namespace)
+		if err != nil {
+			// the replication isn't blocked by the failure of the pre-check,
+			// the destination registry rejects the pushing if the quota is exceeded
+			log.Warningf("failed to get the remaining quota of the namespace %s, skip the quota pre-check: %v",
+				namespace, err)
+			continue
+		}
+		if remaining >= 0 && size > remaining {
+			log.Infof("copying %d bytes into the namespace %s exceeds its remaining quota %d, skip the tasks",
+				size, namespace, remaining)
+			overQuota[namespace] = true
+		}
+	}
+	if len(overQuota) == 0 {
+		return items, nil
+	}
+
+	var result []*scheduler.ScheduleItem
+	for _, item := range items {
+		namespace, _ := util.ParseNamespace(item.DstResource.Metadata.Repository.Name)
+		if !overQuota[namespace] {
+			result = append(result, item)
+			continue
+		}
+		if err := executionMgr.UpdateTaskStatus(item.TaskID, models.TaskStatusOverQuota,
+			models.TaskStatusInitialized); err != nil {
+			log.Errorf("failed to update the task status %d: %v", item.TaskID, err)
+		}
+	}
+	return result, nil
+}
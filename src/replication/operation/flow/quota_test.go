@@ -0,0 +1,131 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the namespaces not in the map have no quota limit
+type fakedQuotaAdapter struct {
+	test.Adapter
+	quotas map[string]int64
+}
+
+func (f *fakedQuotaAdapter) GetRemainingQuota(namespace string) (int64, error) {
+	if namespace == "broken" {
+		return 0, errors.New("error")
+	}
+	remaining, exist := f.quotas[namespace]
+	if !exist {
+		return -1, nil
+	}
+	return remaining, nil
+}
+
+// every item is 110 bytes
+func newQuotaItems(dstRepositories ...string) []*scheduler.ScheduleItem {
+	items := []*scheduler.ScheduleItem{}
+	for i, repository := range dstRepositories {
+		items = append(items, &scheduler.ScheduleItem{
+			TaskID: int64(i + 1),
+			SrcResource: &model.Resource{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: repository,
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+			DstResource: &model.Resource{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: repository,
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+		})
+	}
+	return items
+}
+
+func TestApplyNamespaceQuota(t *testing.T) {
+	var resources []*model.Resource
+	for _, item := range newQuotaItems("library/hello-world", "library/busybox", "team/hello-world", "team/busybox",
+		"team/a/hello-world", "team/b/busybox", "others/busybox", "broken/busybox") {
+		resources = append(resources, item.SrcResource)
+	}
+	srcAdapter := newSizedAdapter(t, resources...)
+	mgr := newFakedExecutionManagerWithTasks(5)
+
+	// the destination adapter doesn't support the quota
+	items, err := applyNamespaceQuota(srcAdapter, newFakedAdapter(), mgr,
+		newQuotaItems("library/hello-world", "team/hello-world"))
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(items))
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+
+	// the namespace "team" is over quota, others proceed
+	dstAdapter := &fakedQuotaAdapter{
+		quotas: map[string]int64{
+			"library": 1000,
+			"team":    200,
+		},
+	}
+	items, err = applyNamespaceQuota(srcAdapter, dstAdapter, mgr,
+		newQuotaItems("library/hello-world", "team/hello-world", "team/busybox", "others/busybox", "broken/busybox"))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(items))
+	assert.Equal(t, int64(1), items[0].TaskID)
+	assert.Equal(t, int64(4), items[1].TaskID)
+	assert.Equal(t, int64(5), items[2].TaskID)
+	assert.Equal(t, map[int64]string{
+		2: models.TaskStatusOverQuota,
+		3: models.TaskStatusOverQuota,
+	}, updatedTaskStatuses(mgr))
+
+	// all over quota
+	mgr = newFakedExecutionManagerWithTasks(5)
+	dstAdapter.quotas = map[string]int64{
+		"library": 0,
+	}
+	items, err = applyNamespaceQuota(srcAdapter, dstAdapter, mgr,
+		newQuotaItems("library/hello-world", "library/busybox"))
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(items))
+	assert.Equal(t, 2, len(updatedTaskStatuses(mgr)))
+
+	// the nested repositories are counted against the quota of the project
+	mgr = newFakedExecutionManagerWithTasks(5)
+	dstAdapter.quotas = map[string]int64{
+		"team": 200,
+	}
+	items, err = applyNamespaceQuota(srcAdapter, dstAdapter, mgr,
+		newQuotaItems("team/a/hello-world", "team/b/busybox"))
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(items))
+	assert.Equal(t, 2, len(updatedTaskStatuses(mgr)))
+}
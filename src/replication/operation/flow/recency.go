@@ -0,0 +1,82 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// get the value of the "max_inactivity" filter of the policy, returns 0 if it isn't set
+func getMaxInactivity(policy *model.Policy) (time.Duration, error) {
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypeMaxInactivity {
+			continue
+		}
+		return filter.GetMaxInactivity()
+	}
+	return 0, nil
+}
+
+// drop the image resources whose repositories have no tag pushed within the max
+// inactivity specified by the "max_inactivity" filter. The newest push time is
+// computed across all the tags of the repository rather than the filtered ones,
+// and the resources whose push time is unknown are kept. Other resources are kept
+// as they are
+func filterDormantResources(srcAdapter adp.Adapter, resources []*model.Resource,
+	policy *model.Policy) ([]*model.Resource, error) {
+	maxInactivity, err := getMaxInactivity(policy)
+	if err != nil {
+		return nil, err
+	}
+	if maxInactivity <= 0 {
+		return resources, nil
+	}
+	lister, ok := srcAdapter.(adp.TagCreationTimeLister)
+	if !ok {
+		return nil, fmt.Errorf("the source adapter doesn't support listing the push time of tags, cannot apply the max inactivity filter")
+	}
+	now := time.Now()
+	var result []*model.Resource
+	for _, resource := range resources {
+		if resource.Type != model.ResourceTypeImage || resource.Deleted {
+			result = append(result, resource)
+			continue
+		}
+		repository := resource.Metadata.Repository.Name
+		times, err := lister.ListTagCreationTimes(repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the creation time of tags under %s: %v", repository, err)
+		}
+		var newest time.Time
+		for _, pushTime := range times {
+			if pushTime.After(newest) {
+				newest = pushTime
+			}
+		}
+		if !newest.IsZero() && now.Sub(newest) > maxInactivity {
+			log.Debugf("the newest tag of %s is pushed at %v which is older than %v, skip",
+				repository, newest, maxInactivity)
+			continue
+		}
+		result = append(result, resource)
+	}
+	log.Debug("filter dormant resources completed")
+	return result, nil
+}
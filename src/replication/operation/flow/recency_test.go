@@ -0,0 +1,90 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the newest tag of "library/active" is pushed one hour ago and the one of
+// "library/dormant" is pushed 30 days ago, the push time of other repositories
+// is unknown
+func newRecencyAdapter() *fakedTagTimeAdapter {
+	return &fakedTagTimeAdapter{
+		times: map[string]map[string]time.Time{
+			"library/active": {
+				"old":    time.Now().Add(-60 * 24 * time.Hour),
+				"latest": time.Now().Add(-time.Hour),
+			},
+			"library/dormant": {
+				"old":    time.Now().Add(-60 * 24 * time.Hour),
+				"latest": time.Now().Add(-30 * 24 * time.Hour),
+			},
+			"*": {},
+		},
+	}
+}
+
+func newRecencyResources() []*model.Resource {
+	var resources []*model.Resource
+	for _, name := range []string{"library/active", "library/dormant", "library/unknown"} {
+		resources = append(resources, &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+				// only the old tag is selected, the newest push time is computed
+				// across the whole repository
+				Vtags: []string{"old"},
+			},
+		})
+	}
+	return resources
+}
+
+func TestFilterDormantResources(t *testing.T) {
+	adapter := newRecencyAdapter()
+
+	// no max inactivity filter
+	resources, err := filterDormantResources(adapter, newRecencyResources(), &model.Policy{})
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(resources))
+
+	// the dormant repository is dropped
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeMaxInactivity,
+				Value: float64(7 * 24 * 3600),
+			},
+		},
+	}
+	resources, err = filterDormantResources(adapter, newRecencyResources(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, "library/active", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"old"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, "library/unknown", resources[1].Metadata.Repository.Name)
+
+	// the adapter cannot list the push time of tags
+	_, err = filterDormantResources(newFakedAdapter(), newRecencyResources(), policy)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,133 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// snapshot the tags of the image resources fetched from the source registry indexed by
+// the repository. It's taken before the filters of the flow are applied, so the tags
+// dropped by them(e.g. the young ones) aren't taken as removed from the source
+func snapshotSourceTags(resources []*model.Resource, policy *model.Policy) map[string]map[string]struct{} {
+	if !policy.PropagateTagDeletion {
+		return nil
+	}
+	tags := map[string]map[string]struct{}{}
+	for _, resource := range resources {
+		if resource.Type != model.ResourceTypeImage || resource.Deleted ||
+			resource.Metadata == nil || resource.Metadata.Repository == nil {
+			continue
+		}
+		repository := resource.Metadata.Repository.Name
+		if _, exist := tags[repository]; !exist {
+			tags[repository] = map[string]struct{}{}
+		}
+		for _, tag := range resource.Metadata.Vtags {
+			tags[repository][normalizeTag(tag, policy.TagNormalization)] = struct{}{}
+		}
+	}
+	return tags
+}
+
+// detect the tags that exist under the destination repositories but are removed from the
+// source ones and return the items deleting them from the destination registry. Only the
+// tags matching the filters of the policy are deleted, so the resources out of the scope
+// of the policy are never touched. The tags already deleted by the "deleting" items(e.g.
+// the expired ones) are excluded
+func detectRemovedTags(dstAdapter adp.Adapter, srcResources, dstResources []*model.Resource,
+	sourceTags map[string]map[string]struct{}, deleting []*scheduler.ScheduleItem,
+	policy *model.Policy) ([]*scheduler.ScheduleItem, error) {
+	if !policy.PropagateTagDeletion || sourceTags == nil {
+		return nil, nil
+	}
+	lister, ok := dstAdapter.(adp.TagLister)
+	if !ok {
+		return nil, fmt.Errorf("the destination adapter doesn't support listing the tags, cannot propagate the deletion of tags")
+	}
+	deleted := map[string]map[string]struct{}{}
+	for _, item := range deleting {
+		repository := item.DstResource.Metadata.Repository.Name
+		if _, exist := deleted[repository]; !exist {
+			deleted[repository] = map[string]struct{}{}
+		}
+		for _, tag := range item.DstResource.Metadata.Vtags {
+			deleted[repository][tag] = struct{}{}
+		}
+	}
+	visited := map[string]struct{}{}
+	var items []*scheduler.ScheduleItem
+	for i, srcResource := range srcResources {
+		dstResource := dstResources[i]
+		if dstResource.Type != model.ResourceTypeImage || dstResource.Deleted {
+			continue
+		}
+		repository := dstResource.Metadata.Repository.Name
+		if _, exist := visited[repository]; exist {
+			continue
+		}
+		visited[repository] = struct{}{}
+		tags, err := lister.ListTag(repository)
+		if err != nil {
+			if isNotFoundError(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to list the tags under %s: %v", repository, err)
+		}
+		existing := sourceTags[srcResource.Metadata.Repository.Name]
+		var removed []string
+		for _, tag := range tags {
+			if _, exist := existing[tag]; exist {
+				continue
+			}
+			if _, exist := deleted[repository][tag]; exist {
+				continue
+			}
+			removed = append(removed, tag)
+		}
+		if len(removed) == 0 {
+			continue
+		}
+		// the removed tags are filtered as the source ones, the ones out of the scope are kept
+		filtered, err := traceFilterResources([]*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: srcResource.Metadata.Repository.Name,
+					},
+					Vtags: removed,
+				},
+			},
+		}, policy.Filters, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(filtered) == 0 || len(filtered[0].Metadata.Vtags) == 0 {
+			continue
+		}
+		removed = filtered[0].Metadata.Vtags
+		sort.Strings(removed)
+		log.Infof("the tags %v of %s are removed from the source, delete them from the destination", removed, repository)
+		items = append(items, newDeletionItem(dstResource, removed, policy))
+	}
+	return items, nil
+}
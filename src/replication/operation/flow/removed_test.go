@@ -0,0 +1,136 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"net/http"
+	"testing"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the destination repository "library/hello-world" has the tags "v1.0", "v2.0",
+// "v0.9" and "dev", other repositories don't exist
+type fakedRemovalAdapter struct {
+	test.Adapter
+}
+
+func (f *fakedRemovalAdapter) ListTag(repository string) ([]string, error) {
+	if repository != "library/hello-world" {
+		return nil, &common_http.Error{Code: http.StatusNotFound}
+	}
+	return []string{"v1.0", "v2.0", "v0.9", "dev"}, nil
+}
+
+func newRemovalResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"v1.0"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"1.0"},
+			},
+		},
+	}
+}
+
+func TestSnapshotSourceTags(t *testing.T) {
+	policy := &model.Policy{}
+	assert.Nil(t, snapshotSourceTags(newRemovalResources(), policy))
+
+	policy.PropagateTagDeletion = true
+	policy.TagNormalization = model.TagNormalizationLowercase
+	resources := newRemovalResources()
+	resources[0].Metadata.Vtags = []string{"V1.0", "latest"}
+	resources = append(resources, &model.Resource{
+		Type: model.ResourceTypeImage,
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: "library/hello-world",
+			},
+			Vtags: []string{"v2.0"},
+		},
+	})
+	tags := snapshotSourceTags(resources, policy)
+	assert.Equal(t, map[string]map[string]struct{}{
+		"library/hello-world": {"v1.0": {}, "latest": {}, "v2.0": {}},
+		"library/busybox":     {"1.0": {}},
+	}, tags)
+}
+
+func TestDetectRemovedTags(t *testing.T) {
+	policy := &model.Policy{
+		DestRegistry: &model.Registry{},
+	}
+	src := newRemovalResources()
+	dst := assembleDestinationResources(src, policy)
+
+	// not enabled
+	items, err := detectRemovedTags(&fakedRemovalAdapter{}, src, dst,
+		snapshotSourceTags(src, policy), nil, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(items))
+
+	// the tags removed from the source are deleted from the destination
+	policy.PropagateTagDeletion = true
+	sourceTags := snapshotSourceTags(src, policy)
+	items, err = detectRemovedTags(&fakedRemovalAdapter{}, src, dst, sourceTags, nil, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+	assert.True(t, items[0].DstResource.Deleted)
+	assert.Equal(t, policy.DestRegistry, items[0].DstResource.Registry)
+	assert.Equal(t, "library/hello-world:[dev,v0.9,v2.0]", getResourceName(items[0].DstResource))
+
+	// the tags out of the scope of the filters and the ones already being deleted are kept
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: "v*",
+		},
+	}
+	expired := []*scheduler.ScheduleItem{
+		newDeletionItem(dst[0], []string{"v0.9"}, policy),
+	}
+	items, err = detectRemovedTags(&fakedRemovalAdapter{}, src, dst, sourceTags, expired, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, "library/hello-world:[v2.0]", getResourceName(items[0].DstResource))
+
+	// none of the removed tags matches the filters
+	policy.Filters[0].Value = "release-*"
+	items, err = detectRemovedTags(&fakedRemovalAdapter{}, src, dst, sourceTags, nil, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(items))
+
+	// the adapter cannot list the tags
+	_, err = detectRemovedTags(newFakedAdapter(), src, dst, sourceTags, nil, policy)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,166 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+)
+
+// the max times one resource is requeued because of timeout or rate
+// limit if it isn't specified by the policy
+const defaultMaxTimeoutRequeues = 3
+
+// the statuses of the tasks requeued to the next execution
+var retryableTaskStatuses = []string{models.TaskStatusTimedOut, models.TaskStatusRateLimited}
+
+// requeue the resources of the tasks timed out or rate limited in the previous execution of
+// the policy into the current one. The resources fetched by the current execution are requeued
+// naturally and aren't added again. As the retryable resource is always requeued into the
+// next execution, the count of its retries is the count of the consecutive previous
+// executions where it's timed out or rate limited, the resources retried more than the
+// max requeues of the policy aren't requeued anymore
+func requeueRetryableResources(executionMgr execution.Manager, executionID int64,
+	policy *model.Policy, resources []*model.Resource) ([]*model.Resource, error) {
+	if policy.TaskDeadline <= 0 && !policy.RateLimitAsSkip {
+		return resources, nil
+	}
+	maxRequeues := policy.MaxTimeoutRequeues
+	if maxRequeues <= 0 {
+		maxRequeues = defaultMaxTimeoutRequeues
+	}
+	// the executions are sorted by the start time in descending order, at most
+	// "maxRequeues+1" previous executions are needed to count the timeouts
+	_, executions, err := executionMgr.List(&models.ExecutionQuery{
+		PolicyID: policy.ID,
+		Pagination: models.Pagination{
+			Page: 1,
+			Size: int64(maxRequeues + 2),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the executions of the policy %d: %v", policy.ID, err)
+	}
+	var previous []*models.Execution
+	for _, e := range executions {
+		if e.ID != executionID {
+			previous = append(previous, e)
+		}
+	}
+	if len(previous) == 0 {
+		return resources, nil
+	}
+
+	// the retryable tasks of every previous execution, indexed by the source resource
+	var latest []*models.Task
+	var retryable []map[string]*models.Task
+	for i, e := range previous {
+		_, tasks, err := executionMgr.ListTasks(&models.TaskQuery{
+			ExecutionID: e.ID,
+			Statuses:    retryableTaskStatuses,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the retryable tasks of the execution %d: %v", e.ID, err)
+		}
+		if i == 0 {
+			if len(tasks) == 0 {
+				return resources, nil
+			}
+			latest = tasks
+		}
+		m := map[string]*models.Task{}
+		for _, task := range tasks {
+			m[task.SrcResource] = task
+		}
+		retryable = append(retryable, m)
+	}
+
+	for _, task := range latest {
+		if task.Operation == "deletion" {
+			continue
+		}
+		retries := 0
+		for _, m := range retryable {
+			if _, exist := m[task.SrcResource]; !exist {
+				break
+			}
+			retries++
+		}
+		if retries > maxRequeues {
+			log.Warningf("the resource %s is timed out or rate limited %d times, give up requeuing it",
+				task.SrcResource, retries)
+			continue
+		}
+		resource, err := parseTaskResource(task)
+		if err != nil {
+			log.Warningf("cannot requeue the task %d: %v", task.ID, err)
+			continue
+		}
+		if containsResource(resources, resource) {
+			continue
+		}
+		log.Infof("requeue the resource %s %s in the execution %d", task.SrcResource,
+			strings.ToLower(task.Status), previous[0].ID)
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
+
+// rebuild the source resource of the task from its name generated by "getResourceName"
+func parseTaskResource(task *models.Task) (*model.Resource, error) {
+	repository, tags, err := parseResourceName(task.SrcResource)
+	if err != nil {
+		return nil, err
+	}
+	return &model.Resource{
+		Type: model.ResourceType(task.ResourceType),
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: repository,
+			},
+			Vtags: tags,
+		},
+	}, nil
+}
+
+// whether the resources contain the resource with the same type, repository and tags
+func containsResource(resources []*model.Resource, resource *model.Resource) bool {
+	for _, res := range resources {
+		if res.Type != resource.Type || res.Metadata == nil || res.Metadata.Repository == nil ||
+			res.Metadata.Repository.Name != resource.Metadata.Repository.Name {
+			continue
+		}
+		tags := map[string]struct{}{}
+		for _, tag := range res.Metadata.Vtags {
+			tags[tag] = struct{}{}
+		}
+		contained := true
+		for _, tag := range resource.Metadata.Vtags {
+			if _, exist := tags[tag]; !exist {
+				contained = false
+				break
+			}
+		}
+		if contained {
+			return true
+		}
+	}
+	return false
+}
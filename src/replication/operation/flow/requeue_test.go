@@ -0,0 +1,200 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// set the status of the task of the resource in the execution, e.g. what the hook does
+func setTaskStatus(t *testing.T, mgr *test.ExecutionManager, executionID int64, resource, status string) {
+	_, tasks, err := mgr.ListTasks(&models.TaskQuery{
+		ExecutionID: executionID,
+	})
+	require.Nil(t, err)
+	for _, task := range tasks {
+		if task.SrcResource == resource {
+			require.Nil(t, mgr.UpdateTaskStatus(task.ID, status))
+		}
+	}
+}
+
+func newRequeueResource(repository string, tags ...string) *model.Resource {
+	return &model.Resource{
+		Type: model.ResourceTypeImage,
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: repository,
+			},
+			Vtags: tags,
+		},
+	}
+}
+
+// run the event based execution of the resource and returns the scheduled items
+func runRequeueExecution(t *testing.T, mgr *test.ExecutionManager, policy *model.Policy,
+	resource *model.Resource) []string {
+	id, err := mgr.Create(&models.Execution{
+		PolicyID: policy.ID,
+		Status:   models.ExecutionStatusInProgress,
+	})
+	require.Nil(t, err)
+	sched := &fakedRecordingScheduler{}
+	_, err = NewCopyFlow(mgr, sched, id, policy, resource).Run(nil)
+	require.Nil(t, err)
+	var names []string
+	for _, item := range sched.items {
+		names = append(names, getResourceName(item.SrcResource))
+	}
+	return names
+}
+
+func TestRequeueTimedOutResources(t *testing.T) {
+	mgr := test.NewExecutionManager()
+	policy := &model.Policy{
+		ID: 1,
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		TaskDeadline: 60,
+	}
+
+	names := runRequeueExecution(t, mgr, policy, newRequeueResource("library/hello-world", "latest"))
+	assert.Equal(t, []string{"library/hello-world:[latest]"}, names)
+	// the task exceeds the deadline
+	setTaskStatus(t, mgr, 1, "library/hello-world:[latest]", models.TaskStatusTimedOut)
+
+	// the timed out resource is requeued into the next execution
+	names = runRequeueExecution(t, mgr, policy, newRequeueResource("library/busybox", "1.0"))
+	assert.Equal(t, []string{"library/busybox:[1.0]", "library/hello-world:[latest]"}, names)
+	setTaskStatus(t, mgr, 2, "library/busybox:[1.0]", models.TaskStatusSucceed)
+	setTaskStatus(t, mgr, 2, "library/hello-world:[latest]", models.TaskStatusSucceed)
+
+	// it succeeds and isn't requeued anymore
+	names = runRequeueExecution(t, mgr, policy, newRequeueResource("library/busybox", "1.1"))
+	assert.Equal(t, []string{"library/busybox:[1.1]"}, names)
+}
+
+func TestRequeueTimedOutResourcesBounded(t *testing.T) {
+	mgr := test.NewExecutionManager()
+	policy := &model.Policy{
+		ID:                 1,
+		TaskDeadline:       60,
+		MaxTimeoutRequeues: 2,
+	}
+	// the resource is timed out in the consecutive executions
+	timeout := func() {
+		id, err := mgr.Create(&models.Execution{PolicyID: policy.ID})
+		require.Nil(t, err)
+		_, err = mgr.CreateTask(&models.Task{
+			ExecutionID:  id,
+			ResourceType: string(model.ResourceTypeImage),
+			SrcResource:  "library/hello-world:[1.0,2.0]",
+			Operation:    "copy",
+			Status:       models.TaskStatusTimedOut,
+		})
+		require.Nil(t, err)
+	}
+
+	timeout()
+	resources, err := requeueRetryableResources(mgr, 100, policy, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(resources))
+	assert.Equal(t, "library/hello-world", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"1.0", "2.0"}, resources[0].Metadata.Vtags)
+
+	// the resource being fetched by the current execution isn't added again
+	resources, err = requeueRetryableResources(mgr, 100, policy,
+		[]*model.Resource{newRequeueResource("library/hello-world", "1.0", "2.0", "3.0")})
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(resources))
+
+	timeout()
+	resources, err = requeueRetryableResources(mgr, 100, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(resources))
+
+	// timed out more than the max requeues, give up
+	timeout()
+	resources, err = requeueRetryableResources(mgr, 100, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(resources))
+
+	// no deadline
+	policy.TaskDeadline = 0
+	resources, err = requeueRetryableResources(mgr, 100, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(resources))
+}
+
+func TestRequeueRateLimitedResources(t *testing.T) {
+	mgr := test.NewExecutionManager()
+	policy := &model.Policy{
+		ID: 1,
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		RateLimitAsSkip: true,
+	}
+
+	names := runRequeueExecution(t, mgr, policy, newRequeueResource("library/hello-world", "latest"))
+	assert.Equal(t, []string{"library/hello-world:[latest]"}, names)
+	// the task is rate limited by the registry, it's skipped rather than failed
+	setTaskStatus(t, mgr, 1, "library/hello-world:[latest]", models.TaskStatusRateLimited)
+	assert.True(t, models.IsTaskSkipped(mgr.Tasks()[0].Status))
+
+	// the rate limited resource is requeued into the next execution
+	names = runRequeueExecution(t, mgr, policy, newRequeueResource("library/busybox", "1.0"))
+	assert.Equal(t, []string{"library/busybox:[1.0]", "library/hello-world:[latest]"}, names)
+	setTaskStatus(t, mgr, 2, "library/hello-world:[latest]", models.TaskStatusRateLimited)
+
+	// not requeued if the policy doesn't handle the rate limit as skip
+	policy.RateLimitAsSkip = false
+	names = runRequeueExecution(t, mgr, policy, newRequeueResource("library/busybox", "1.1"))
+	assert.Equal(t, []string{"library/busybox:[1.1]"}, names)
+}
+
+func TestParseTaskResource(t *testing.T) {
+	resource, err := parseTaskResource(&models.Task{
+		ResourceType: string(model.ResourceTypeChart),
+		SrcResource:  "library/harbor:[1.0]",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, model.ResourceTypeChart, resource.Type)
+	assert.Equal(t, "library/harbor:[1.0]", getResourceName(resource))
+
+	// the tags are truncated
+	_, err = parseTaskResource(&models.Task{
+		SrcResource: "library/hello-world:[1,2,3,4,5 ... 6 in total]",
+	})
+	assert.NotNil(t, err)
+	// no tags
+	_, err = parseTaskResource(&models.Task{
+		SrcResource: "library/hello-world",
+	})
+	assert.NotNil(t, err)
+}
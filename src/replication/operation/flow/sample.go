@@ -0,0 +1,108 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// estimate of the full run extrapolated from the sampled dry run
+type estimate struct {
+	// the count of the repositories sampled and the total count
+	SampledRepositories int
+	TotalRepositories   int
+	// the estimated resources, tasks and bytes of the full run
+	Resources int64
+	Tasks     int64
+	Bytes     int64
+}
+
+func (e *estimate) String() string {
+	return fmt.Sprintf("sampled %d of %d repositories, estimated resources: %d, tasks: %d, bytes: %d",
+		e.SampledRepositories, e.TotalRepositories, e.Resources, e.Tasks, e.Bytes)
+}
+
+// sample the repositories of the resources for the dry run: the first N ones in the
+// order they're fetched, or N random ones if "random" is set. All the resources of a
+// sampled repository are kept. Returns the sampled resources and the total count of
+// the repositories
+func sampleResources(resources []*model.Resource, size int, random bool) ([]*model.Resource, int) {
+	var repositories []string
+	indexes := map[string]int{}
+	for _, resource := range resources {
+		repository := resource.Metadata.GetResourceName()
+		if _, exist := indexes[repository]; exist {
+			continue
+		}
+		indexes[repository] = len(repositories)
+		repositories = append(repositories, repository)
+	}
+	total := len(repositories)
+	if size <= 0 || size >= total {
+		return resources, total
+	}
+	sampled := map[int]struct{}{}
+	if random {
+		for _, i := range rand.Perm(total)[:size] {
+			sampled[i] = struct{}{}
+		}
+	} else {
+		for i := 0; i < size; i++ {
+			sampled[i] = struct{}{}
+		}
+	}
+	var result []*model.Resource
+	for _, resource := range resources {
+		if _, exist := sampled[indexes[resource.Metadata.GetResourceName()]]; exist {
+			result = append(result, resource)
+		}
+	}
+	return result, total
+}
+
+// extrapolate the value got from the sampled repositories to all the repositories
+func extrapolate(value int64, sampled, total int) int64 {
+	if sampled <= 0 {
+		return 0
+	}
+	return int64(math.Round(float64(value) * float64(total) / float64(sampled)))
+}
+
+// estimate the full run from the items and tasks previewed for the sampled repositories,
+// the bytes are the sizes of the blobs referenced by the sampled source resources
+func newEstimate(srcAdapter adp.Adapter, items []*scheduler.ScheduleItem, tasks int,
+	sampled, total int) (*estimate, error) {
+	var bytes int64
+	for _, item := range items {
+		size, err := getResourceSize(srcAdapter, item.SrcResource)
+		if err != nil {
+			return nil, err
+		}
+		bytes += size
+	}
+	return &estimate{
+		SampledRepositories: sampled,
+		TotalRepositories:   total,
+		Resources:           extrapolate(int64(len(items)), sampled, total),
+		Tasks:               extrapolate(int64(tasks), sampled, total),
+		Bytes:               extrapolate(bytes, sampled, total),
+	}, nil
+}
@@ -0,0 +1,115 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// one image resource of each repository plus a chart resource sharing
+// the name of the first repository
+func newSampleResources(count int) []*model.Resource {
+	var resources []*model.Resource
+	for i := 0; i < count; i++ {
+		resources = append(resources, &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: fmt.Sprintf("library/image%d", i),
+				},
+				Vtags: []string{"1.0", "2.0"},
+			},
+		})
+	}
+	return append(resources, &model.Resource{
+		Type: model.ResourceTypeChart,
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: "library/image0",
+			},
+			Vtags: []string{"1.0"},
+		},
+	})
+}
+
+func TestSampleResources(t *testing.T) {
+	// no sampling
+	resources, total := sampleResources(newSampleResources(10), 0, false)
+	assert.Equal(t, 11, len(resources))
+	assert.Equal(t, 10, total)
+	resources, total = sampleResources(newSampleResources(10), 20, false)
+	assert.Equal(t, 11, len(resources))
+	assert.Equal(t, 10, total)
+
+	// the first N repositories, all the resources of them are kept
+	resources, total = sampleResources(newSampleResources(10), 2, false)
+	assert.Equal(t, 10, total)
+	require.Equal(t, 3, len(resources))
+	assert.Equal(t, "library/image0", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, "library/image1", resources[1].Metadata.Repository.Name)
+	assert.Equal(t, model.ResourceTypeChart, resources[2].Type)
+
+	// random repositories
+	resources, total = sampleResources(newSampleResources(10), 4, true)
+	assert.Equal(t, 10, total)
+	repositories := map[string]struct{}{}
+	for _, resource := range resources {
+		repositories[resource.Metadata.Repository.Name] = struct{}{}
+	}
+	assert.Equal(t, 4, len(repositories))
+}
+
+func TestExtrapolate(t *testing.T) {
+	cases := []struct {
+		value    int64
+		sampled  int
+		total    int
+		expected int64
+	}{
+		{value: 0, sampled: 10, total: 100, expected: 0},
+		{value: 25, sampled: 10, total: 100, expected: 250},
+		{value: 7, sampled: 3, total: 10, expected: 23},
+		{value: 5, sampled: 5, total: 5, expected: 5},
+		{value: 5, sampled: 0, total: 5, expected: 0},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, extrapolate(c.value, c.sampled, c.total))
+	}
+}
+
+func TestNewEstimate(t *testing.T) {
+	// 4 of 10 repositories sampled, each with 2 tags previewed as 2 tasks
+	var items []*scheduler.ScheduleItem
+	for _, resource := range newSampleResources(4)[:4] {
+		items = append(items, &scheduler.ScheduleItem{
+			SrcResource: resource,
+		})
+	}
+	est, err := newEstimate(newSizedAdapter(t, newSampleResources(4)[:4]...), items, 8, 4, 10)
+	require.Nil(t, err)
+	assert.Equal(t, 4, est.SampledRepositories)
+	assert.Equal(t, 10, est.TotalRepositories)
+	assert.Equal(t, int64(10), est.Resources)
+	assert.Equal(t, int64(20), est.Tasks)
+	// each repository has 2 configs(10 bytes) and 1 shared layer(100 bytes)
+	assert.Equal(t, int64(1200), est.Bytes)
+	assert.Equal(t, "sampled 4 of 10 repositories, estimated resources: 10, tasks: 20, bytes: 1200", est.String())
+}
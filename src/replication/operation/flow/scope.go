@@ -0,0 +1,84 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// whether the namespace is covered by the credential of the source registry,
+// all the namespaces are covered if the policy doesn't declare them
+func inCredentialScope(policy *model.Policy, namespace string) bool {
+	if len(policy.CredentialNamespaces) == 0 {
+		return true
+	}
+	for _, ns := range policy.CredentialNamespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// limit the fetching to the namespaces covered by the credential of the source registry.
+// The namespaces specified by the name filters but out of the scope are dropped, and
+// the fetching without any specific namespace is split into the covered namespaces
+func scopeNamespaceFilters(list []*namespaceFilters, policy *model.Policy) []*namespaceFilters {
+	if len(policy.CredentialNamespaces) == 0 {
+		return list
+	}
+	var result []*namespaceFilters
+	for _, nsFilters := range list {
+		namespaces := []string{nsFilters.namespace}
+		if len(nsFilters.namespace) == 0 {
+			namespaces = getSrcNamespaces(policy)
+		}
+		if len(namespaces) == 0 {
+			for _, namespace := range policy.CredentialNamespaces {
+				result = append(result, &namespaceFilters{
+					namespace: namespace,
+					filters:   withNamespace(nsFilters.filters, namespace),
+				})
+			}
+			continue
+		}
+		for _, namespace := range namespaces {
+			if inCredentialScope(policy, namespace) {
+				result = append(result, nsFilters)
+				break
+			}
+			log.Debugf("the namespace %s isn't covered by the credential of the source registry, skip", namespace)
+		}
+	}
+	return result
+}
+
+// drop the resources under the namespaces that aren't covered by the credential of
+// the source registry, e.g. the ones specified by the events
+func filterByCredentialScope(resources []*model.Resource, policy *model.Policy) []*model.Resource {
+	if len(policy.CredentialNamespaces) == 0 {
+		return resources
+	}
+	var result []*model.Resource
+	for _, resource := range resources {
+		if namespace := getNamespace(resource); !inCredentialScope(policy, namespace) {
+			log.Debugf("the namespace %s isn't covered by the credential of the source registry, skip", namespace)
+			continue
+		}
+		result = append(result, resource)
+	}
+	return result
+}
@@ -0,0 +1,108 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the adapter only grants the access to "ns1" and "ns2"
+func newScopedCredentialAdapter() *fakedNamespaceFetchingAdapter {
+	return &fakedNamespaceFetchingAdapter{
+		granted: map[string]bool{
+			"ns1": true,
+			"ns2": true,
+		},
+	}
+}
+
+func TestFetchResourcesInCredentialScope(t *testing.T) {
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+			{
+				Type:  model.FilterTypeName,
+				Value: "{ns1,ns2,ns3}/**",
+			},
+		},
+		CredentialNamespaces: []string{"ns1", "ns2"},
+	}
+	// the namespaces out of the scope aren't fetched
+	adapter := newScopedCredentialAdapter()
+	resources, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ns1/hello-world", "ns2/hello-world"}, getResourceNames(resources))
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, adapter.fetched)
+
+	// the only namespace specified is out of the scope
+	policy.Filters[1].Value = "ns3/**"
+	adapter = newScopedCredentialAdapter()
+	resources, _, err = fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(resources))
+	assert.Equal(t, 0, len(adapter.fetched))
+
+	// no namespace is specified, only the ones in the scope are fetched
+	policy.Filters = policy.Filters[:1]
+	adapter = newScopedCredentialAdapter()
+	resources, _, err = fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"ns1/hello-world", "ns2/hello-world"}, getResourceNames(resources))
+	assert.ElementsMatch(t, []string{"ns1", "ns2"}, adapter.fetched)
+
+	// the default namespace out of the scope isn't fetched
+	policy.IncludeDefaultSrcNamespace = true
+	adapter = newScopedCredentialAdapter()
+	units, err := getFetchUnits(adapter, policy, []model.ResourceType{model.ResourceTypeImage}, "library", nil)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(units))
+	assert.Equal(t, "image of the namespace ns1", units[0].name)
+	assert.Equal(t, "image of the namespace ns2", units[1].name)
+}
+
+func TestFilterByCredentialScope(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "ns1/hello-world",
+				},
+			},
+		},
+		{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "ns3/hello-world",
+				},
+			},
+		},
+	}
+	// no scope
+	result := filterByCredentialScope(resources, &model.Policy{})
+	assert.Equal(t, 2, len(result))
+
+	result = filterByCredentialScope(resources, &model.Policy{
+		CredentialNamespaces: []string{"ns1", "ns2"},
+	})
+	assert.Equal(t, []string{"ns1/hello-world"}, getResourceNames(result))
+}
@@ -0,0 +1,152 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/util"
+)
+
+// TagSelector orders the tags of a resource and selects the ones to be replicated,
+// the strategies selecting the tags by their versions or push time implement it
+type TagSelector interface {
+	// Select returns the selected tags of the resource, the selected tags
+	// remain in their original order
+	Select(resource *model.Resource) ([]string, error)
+	// Describe returns what the selected tags are, e.g. "one of the latest 3 tags",
+	// it is used in the trace and logs
+	Describe() string
+}
+
+// creates the tag selector by the filter, a nil selector selects all the tags
+type tagSelectorCreator func(filter *model.Filter, srcAdapter adp.Adapter) (TagSelector, error)
+
+type tagSelectorFactory struct {
+	filterType model.FilterType
+	// the selectors needing the source adapter are applied by "selectTags" after the
+	// repository level filters, the others are applied by "filterResources"
+	needsAdapter bool
+	create       tagSelectorCreator
+}
+
+// the tag selectors of the filter types, the ones applied by "selectTags" are applied
+// in this order. Register the new strategies here to make them available in the flows
+var tagSelectorFactories = []*tagSelectorFactory{
+	{
+		filterType: model.FilterTypeLatestPatch,
+		create:     newLatestPatchSelector,
+	},
+	{
+		filterType:   model.FilterTypeMinAge,
+		needsAdapter: true,
+		create:       newMinAgeSelector,
+	},
+	{
+		filterType:   model.FilterTypeLatestTags,
+		needsAdapter: true,
+		create:       newLatestTagsSelector,
+	},
+}
+
+// returns the tag selector factory of the filter type, nil if the type has none
+func getTagSelectorFactory(filterType model.FilterType) *tagSelectorFactory {
+	for _, factory := range tagSelectorFactories {
+		if factory.filterType == filterType {
+			return factory
+		}
+	}
+	return nil
+}
+
+// apply the tag selectors needing the source adapter, only the first filter of each
+// type is applied. The resources without any tag selected are dropped, and the deleted
+// ones, the ones without any tag or out of the scope of the filter are kept as they are
+func selectTags(srcAdapter adp.Adapter, resources []*model.Resource,
+	policy *model.Policy) ([]*model.Resource, error) {
+	for _, factory := range tagSelectorFactories {
+		if !factory.needsAdapter {
+			continue
+		}
+		for _, filter := range policy.Filters {
+			if filter.Type != factory.filterType {
+				continue
+			}
+			selector, err := factory.create(filter, srcAdapter)
+			if err != nil {
+				return nil, err
+			}
+			if selector != nil {
+				resources, err = applyTagSelector(selector, filter, resources)
+				if err != nil {
+					return nil, err
+				}
+			}
+			break
+		}
+	}
+	log.Debug("select tags completed")
+	return resources, nil
+}
+
+func applyTagSelector(selector TagSelector, filter *model.Filter,
+	resources []*model.Resource) ([]*model.Resource, error) {
+	var result []*model.Resource
+	for _, resource := range resources {
+		if !filter.AppliesTo(resource.Type) || resource.Deleted ||
+			resource.Metadata == nil || len(resource.Metadata.Vtags) == 0 {
+			result = append(result, resource)
+			continue
+		}
+		tags, err := selector.Select(resource)
+		if err != nil {
+			return nil, err
+		}
+		if len(tags) == 0 {
+			log.Debugf("no tag of %s is %s, skip", getResourceName(resource), selector.Describe())
+			continue
+		}
+		resource.Metadata.Vtags = tags
+		log.Debugf("keep the tags of %s which are %s", getResourceName(resource), selector.Describe())
+		result = append(result, resource)
+	}
+	return result, nil
+}
+
+// selects the latest patch version of each minor version
+type latestPatchSelector struct {
+	keepNonSemver bool
+}
+
+func newLatestPatchSelector(filter *model.Filter, srcAdapter adp.Adapter) (TagSelector, error) {
+	keepNonSemver, ok := filter.Value.(bool)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a valid bool", filter.Value)
+	}
+	return &latestPatchSelector{
+		keepNonSemver: keepNonSemver,
+	}, nil
+}
+
+func (l *latestPatchSelector) Select(resource *model.Resource) ([]string, error) {
+	return util.LatestPatchPerMinor(resource.Metadata.Vtags, l.keepNonSemver), nil
+}
+
+func (l *latestPatchSelector) Describe() string {
+	return "the latest patch of its minor version"
+}
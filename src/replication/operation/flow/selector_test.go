@@ -0,0 +1,199 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// selects the tags with the prefix specified by the filter
+type fakedPrefixSelector struct {
+	prefix string
+}
+
+func (f *fakedPrefixSelector) Select(resource *model.Resource) ([]string, error) {
+	var tags []string
+	for _, tag := range resource.Metadata.Vtags {
+		if strings.HasPrefix(tag, f.prefix) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (f *fakedPrefixSelector) Describe() string {
+	return fmt.Sprintf("prefixed with %s", f.prefix)
+}
+
+// selects the tags whose push time is known
+type fakedKnownPushTimeSelector struct {
+	lister adp.TagCreationTimeLister
+}
+
+func (f *fakedKnownPushTimeSelector) Select(resource *model.Resource) ([]string, error) {
+	times, err := f.lister.ListTagCreationTimes(resource.Metadata.Repository.Name)
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	for _, tag := range resource.Metadata.Vtags {
+		if _, exist := times[tag]; exist {
+			tags = append(tags, tag)
+		}
+	}
+	return tags, nil
+}
+
+func (f *fakedKnownPushTimeSelector) Describe() string {
+	return "pushed at a known time"
+}
+
+const (
+	fakedFilterTypePrefix        model.FilterType = "prefix"
+	fakedFilterTypeKnownPushTime model.FilterType = "known_push_time"
+)
+
+func registerFakedTagSelectors() func() {
+	factories := tagSelectorFactories
+	tagSelectorFactories = append([]*tagSelectorFactory{
+		{
+			filterType: fakedFilterTypePrefix,
+			create: func(filter *model.Filter, srcAdapter adp.Adapter) (TagSelector, error) {
+				return &fakedPrefixSelector{prefix: filter.Value.(string)}, nil
+			},
+		},
+		{
+			filterType:   fakedFilterTypeKnownPushTime,
+			needsAdapter: true,
+			create: func(filter *model.Filter, srcAdapter adp.Adapter) (TagSelector, error) {
+				return &fakedKnownPushTimeSelector{lister: srcAdapter.(adp.TagCreationTimeLister)}, nil
+			},
+		},
+	}, factories...)
+	return func() {
+		tagSelectorFactories = factories
+	}
+}
+
+func TestGetTagSelectorFactory(t *testing.T) {
+	assert.Nil(t, getTagSelectorFactory(model.FilterTypeName))
+	factory := getTagSelectorFactory(model.FilterTypeLatestPatch)
+	require.NotNil(t, factory)
+	assert.False(t, factory.needsAdapter)
+	factory = getTagSelectorFactory(model.FilterTypeLatestTags)
+	require.NotNil(t, factory)
+	assert.True(t, factory.needsAdapter)
+}
+
+func TestSelectTagsWithMultipleSelectors(t *testing.T) {
+	// the young tags are dropped before selecting the latest ones,
+	// no matter what the order of the filters is
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeLatestTags,
+				Value: &model.LatestTags{Count: 1},
+			},
+			{
+				Type:  model.FilterTypeMinAge,
+				Value: float64(120),
+			},
+		},
+	}
+	resources, err := selectTags(newPushTimeAdapter(), newAgeResources(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, "library/hello-world", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"old"}, resources[0].Metadata.Vtags)
+	// the chart is out of the scope of the min age filter
+	assert.Equal(t, "library/harbor", resources[1].Metadata.Repository.Name)
+	assert.Equal(t, []string{"fresh"}, resources[1].Metadata.Vtags)
+}
+
+func TestPluggedTagSelectors(t *testing.T) {
+	defer registerFakedTagSelectors()()
+
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+			{
+				Type:  fakedFilterTypePrefix,
+				Value: "o",
+			},
+			{
+				Type: fakedFilterTypeKnownPushTime,
+			},
+		},
+	}
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"fresh", "old", "other"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"fresh"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/alpine",
+				},
+				Vtags: []string{"other"},
+			},
+		},
+	}
+
+	// the selector needing no adapter is applied by the filters
+	trace := &FilterTrace{}
+	resources, err := traceFilterResources(resources, policy.Filters, trace, nil)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(resources))
+	assert.Equal(t, []string{"old", "other"}, resources[0].Metadata.Vtags)
+	assert.Equal(t, []string{"other"}, resources[1].Metadata.Vtags)
+	decisions := trace.Explain("library/busybox")
+	require.NotEmpty(t, decisions)
+	last := decisions[len(decisions)-1]
+	assert.False(t, last.Accepted)
+	assert.Equal(t, "no tag is prefixed with o", last.Reason)
+
+	// and the one needing the adapter is applied in the same pipeline afterwards
+	resources, err = selectTags(newPushTimeAdapter(), resources, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(resources))
+	assert.Equal(t, "library/hello-world", resources[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"old"}, resources[0].Metadata.Vtags)
+}
@@ -15,6 +15,7 @@
 package flow
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -22,6 +23,7 @@ import (
 
 	"github.com/goharbor/harbor/src/common/utils/log"
 	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/classifier"
 	"github.com/goharbor/harbor/src/replication/dao/models"
 	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/operation/execution"
@@ -34,12 +36,23 @@ func initialize(policy *model.Policy) (adp.Adapter, adp.Adapter, error) {
 	var srcAdapter, dstAdapter adp.Adapter
 	var err error
 
+	// the registries carry the User-Agent and max manifest size of the policy, so they are
+	// honored by both the adapters created here and the ones created by the replication jobs
+	if len(policy.UserAgent) > 0 {
+		policy.SrcRegistry.UserAgent = policy.UserAgent
+		policy.DestRegistry.UserAgent = policy.UserAgent
+	}
+	if policy.MaxManifestSize > 0 {
+		policy.SrcRegistry.MaxManifestSize = policy.MaxManifestSize
+		policy.DestRegistry.MaxManifestSize = policy.MaxManifestSize
+	}
+
 	// create the source registry adapter
 	srcFactory, err := adp.GetFactory(policy.SrcRegistry.Type)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get adapter factory for registry type %s: %v", policy.SrcRegistry.Type, err)
 	}
-	srcAdapter, err = srcFactory(policy.SrcRegistry)
+	srcAdapter, err = createAdapter(srcFactory, policy.SrcRegistry, policy)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create adapter for source registry %s: %v", policy.SrcRegistry.URL, err)
 	}
@@ -49,174 +62,588 @@ func initialize(policy *model.Policy) (adp.Adapter, adp.Adapter, error) {
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to get adapter factory for registry type %s: %v", policy.DestRegistry.Type, err)
 	}
-	dstAdapter, err = dstFactory(policy.DestRegistry)
+	dstAdapter, err = createAdapter(dstFactory, policy.DestRegistry, policy)
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to create adapter for destination registry %s: %v", policy.DestRegistry.URL, err)
 	}
+
+	// probe the registries right after creating the adapters, so the unreachable or
+	// unauthorized registries are reported clearly rather than failing the fetching
+	if err = checkRegistryHealth(srcAdapter); err != nil {
+		return nil, nil, fmt.Errorf("source registry %s unreachable: %v", policy.SrcRegistry.URL, err)
+	}
+	if err = checkRegistryHealth(dstAdapter); err != nil {
+		return nil, nil, fmt.Errorf("destination registry %s unreachable: %v", policy.DestRegistry.URL, err)
+	}
 	log.Debug("replication flow initialization completed")
 	return srcAdapter, dstAdapter, nil
 }
 
-// fetch resources from the source registry
-func fetchResources(adapter adp.Adapter, policy *model.Policy) ([]*model.Resource, error) {
+// fetch resources from the source registry, only the images pushed since the
+// watermark are fetched if it's specified and the adapter supports
+func fetchResources(ctx context.Context, adapter adp.Adapter, policy *model.Policy,
+	mark *watermark) ([]*model.Resource, []*fetchFailure, error) {
 	var resTypes []model.ResourceType
-	var filters []*model.Filter
 	for _, filter := range policy.Filters {
 		if filter.Type != model.FilterTypeResource {
-			filters = append(filters, filter)
 			continue
 		}
-		resTypes = append(resTypes, filter.Value.(model.ResourceType))
+		resourceType, err := filter.GetResourceType()
+		if err != nil {
+			return nil, nil, err
+		}
+		resTypes = append(resTypes, resourceType)
 	}
 	if len(resTypes) == 0 {
 		info, err := adapter.Info()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get the adapter info: %v", err)
+			return nil, nil, fmt.Errorf("failed to get the adapter info: %v", err)
+		}
+		// fetching nothing silently hides the misconfigured adapter
+		if len(info.SupportedResourceTypes) == 0 {
+			return nil, nil, fmt.Errorf("the adapter of the registry type %s supports no resource types, check the configuration of the adapter",
+				info.Type)
 		}
 		resTypes = append(resTypes, info.SupportedResourceTypes...)
 	}
 
-	resources := []*model.Resource{}
-	// convert the adapter to different interfaces according to its required resource types
-	for _, typ := range resTypes {
-		var res []*model.Resource
-		var err error
-		if typ == model.ResourceTypeImage {
-			// images
-			reg, ok := adapter.(adp.ImageRegistry)
-			if !ok {
-				return nil, fmt.Errorf("the adapter doesn't implement the ImageRegistry interface")
-			}
-			res, err = reg.FetchImages(filters)
-		} else if typ == model.ResourceTypeChart {
-			// charts
-			reg, ok := adapter.(adp.ChartRegistry)
-			if !ok {
-				return nil, fmt.Errorf("the adapter doesn't implement the ChartRegistry interface")
+	defaultNamespace := getDefaultSrcNamespace(adapter, policy)
+	units, err := getFetchUnits(adapter, policy, resTypes, defaultNamespace, mark)
+	if err != nil {
+		return nil, nil, err
+	}
+	resources, failures, err := fetchConcurrently(ctx, units, getFetchConcurrency(policy),
+		getFetchTimeout(policy), policy.BestEffortFetch)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the default namespace may be fetched by the other units as well
+	if len(defaultNamespace) > 0 {
+		resources = dedupResources(resources)
+	}
+	if err = checkResourceCount(resources, policy); err != nil {
+		return nil, nil, err
+	}
+
+	log.Debug("fetch resources from the source registry completed")
+	return resources, failures, nil
+}
+
+// get the specific source namespaces from the name filters, e.g.
+// "library/**" -> [library], "{library,harbor}/**" -> [library,harbor]
+// "**" -> [], "hello-world" -> []
+func getSrcNamespaces(policy *model.Policy) []string {
+	namespaces := []string{}
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypeName || !filter.IsGlob() || filter.IsExclusion() {
+			continue
+		}
+		pattern, ok := filter.Value.(string)
+		if !ok {
+			continue
+		}
+		components := strings.SplitN(pattern, "/", 2)
+		if len(components) < 2 {
+			continue
+		}
+		names, ok := util.IsSpecificPathComponent(components[0])
+		if !ok {
+			continue
+		}
+		namespaces = append(namespaces, names...)
+	}
+	return namespaces
+}
+
+// check the existence of the source namespaces when the strict mode is enabled.
+// If the adapter cannot check the namespace, the namespace without any fetched
+// resources is treated as nonexistent
+func checkSrcNamespaces(adapter adp.Adapter, policy *model.Policy, resources []*model.Resource) error {
+	if !policy.StrictSrcNamespace {
+		return nil
+	}
+	checker, _ := adapter.(adp.NamespaceChecker)
+	for _, namespace := range getSrcNamespaces(policy) {
+		// the namespaces out of the scope of the credential are never fetched
+		if !inCredentialScope(policy, namespace) {
+			continue
+		}
+		var exist bool
+		if checker != nil {
+			var err error
+			exist, err = checker.NamespaceExist(namespace)
+			if err != nil {
+				return fmt.Errorf("failed to check the existence of the source namespace %s: %v", namespace, err)
 			}
-			res, err = reg.FetchCharts(filters)
 		} else {
-			return nil, fmt.Errorf("unsupported resource type %s", typ)
+			for _, resource := range resources {
+				if resource.Metadata == nil || resource.Metadata.Repository == nil {
+					continue
+				}
+				if strings.HasPrefix(resource.Metadata.Repository.Name, namespace+"/") {
+					exist = true
+					break
+				}
+			}
 		}
-		if err != nil {
-			return nil, fmt.Errorf("failed to fetch %s: %v", typ, err)
+		if !exist {
+			return fmt.Errorf("the namespace %s doesn't exist in the source registry", namespace)
 		}
-		resources = append(resources, res...)
-		log.Debugf("fetch %s completed", typ)
 	}
-
-	log.Debug("fetch resources from the source registry completed")
-	return resources, nil
+	log.Debug("check the source namespaces completed")
+	return nil
 }
 
-// apply the filters to the resources and returns the filtered resources
+// apply the filters to the resources and returns the filtered resources, the
+// filters only apply to the resources of the types in their scopes
 func filterResources(resources []*model.Resource, filters []*model.Filter) ([]*model.Resource, error) {
+	return traceFilterResources(resources, filters, nil, nil)
+}
+
+// whether any digest filter applies to the resource type, the tag filters are
+// ignored by the resources of the type if so
+func hasDigestFilter(filters []*model.Filter, resourceType model.ResourceType) bool {
+	for _, filter := range filters {
+		if filter.Type == model.FilterTypeDigest && filter.AppliesTo(resourceType) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns the first label of the resource which is one of the expected labels
+func matchLabels(expected, labels []string) (string, bool) {
+	for _, label := range labels {
+		for _, e := range expected {
+			if label == e {
+				return label, true
+			}
+		}
+	}
+	return "", false
+}
+
+// the same as "filterResources", the decisions made by the filters are recorded
+// into the trace if it isn't nil, and the count of the resources dropped by each
+// type of the filters is accumulated into "dropped" if it isn't nil
+func traceFilterResources(resources []*model.Resource, filters []*model.Filter,
+	trace *FilterTrace, dropped map[model.FilterType]int) ([]*model.Resource, error) {
+	// parse the patterns of the name and tag filters only once
+	matchers := map[*model.Filter]util.Matcher{}
+	for _, filter := range filters {
+		if filter.Type != model.FilterTypeName && filter.Type != model.FilterTypeTag {
+			continue
+		}
+		matcher, err := filter.GetMatcher()
+		if err != nil {
+			return nil, err
+		}
+		matchers[filter] = matcher
+	}
+	// and the digests of the digest filters
+	digests := map[*model.Filter]map[string]struct{}{}
+	for _, filter := range filters {
+		if filter.Type != model.FilterTypeDigest {
+			continue
+		}
+		values, err := filter.GetDigests()
+		if err != nil {
+			return nil, err
+		}
+		digests[filter] = map[string]struct{}{}
+		for _, value := range values {
+			digests[filter][value] = struct{}{}
+		}
+	}
 	var res []*model.Resource
 	for _, resource := range resources {
 		match := true
+		name := ""
+		if resource.Metadata != nil && resource.Metadata.Repository != nil {
+			name = resource.Metadata.Repository.Name
+		}
+		pinned := hasDigestFilter(filters, resource.Type)
+		// declared outside of the loop to keep the filter dropping the resource
+		var filter *model.Filter
 	FILTER_LOOP:
-		for _, filter := range filters {
+		for _, filter = range filters {
+			// the filter scoped to other resource types is ignored
+			if !filter.AppliesTo(resource.Type) {
+				trace.accept(name, "", filter, "the filter is scoped to %s", filter.Scope)
+				continue
+			}
 			switch filter.Type {
 			case model.FilterTypeResource:
-				resourceType, ok := filter.Value.(model.ResourceType)
-				if !ok {
-					return nil, fmt.Errorf("%v is not a valid string", filter.Value)
+				resourceType, err := filter.GetResourceType()
+				if err != nil {
+					return nil, err
 				}
-				if model.ResourceType(resourceType) != resource.Type {
+				if resourceType != resource.Type {
+					trace.reject(name, "", filter, "the resource type %s doesn't match %s", resource.Type, resourceType)
 					match = false
 					break FILTER_LOOP
 				}
+				trace.accept(name, "", filter, "the resource type %s matches", resource.Type)
 			case model.FilterTypeName:
-				pattern, ok := filter.Value.(string)
-				if !ok {
-					return nil, fmt.Errorf("%v is not a valid string", filter.Value)
-				}
+				pattern := filter.Value.(string)
 				if resource.Metadata == nil {
+					trace.reject(name, "", filter, "the resource has no metadata")
 					match = false
 					break FILTER_LOOP
 				}
-				m, err := util.Match(pattern, resource.Metadata.Repository.Name)
+				m, err := matchers[filter].Match(resource.Metadata.Repository.Name)
 				if err != nil {
 					return nil, err
 				}
+				if filter.IsExclusion() {
+					if m {
+						trace.reject(name, "", filter, "the repository name matches the excluded pattern %s", pattern)
+						match = false
+						break FILTER_LOOP
+					}
+					trace.accept(name, "", filter, "the repository name doesn't match the excluded pattern %s", pattern)
+					continue
+				}
 				if !m {
+					trace.reject(name, "", filter, "the repository name doesn't match the pattern %s", pattern)
 					match = false
 					break FILTER_LOOP
 				}
+				trace.accept(name, "", filter, "the repository name matches the pattern %s", pattern)
 			case model.FilterTypeTag:
-				pattern, ok := filter.Value.(string)
-				if !ok {
-					return nil, fmt.Errorf("%v is not a valid string", filter.Value)
+				pattern := filter.Value.(string)
+				// the digests pin the tags no matter what they are
+				if pinned {
+					trace.accept(name, "", filter, "the tag filter is ignored as the digest filter is present")
+					continue
 				}
 				if resource.Metadata == nil {
+					trace.reject(name, "", filter, "the resource has no metadata")
 					match = false
 					break FILTER_LOOP
 				}
 				var versions []string
 				for _, version := range resource.Metadata.Vtags {
-					m, err := util.Match(pattern, version)
+					m, err := matchers[filter].Match(version)
 					if err != nil {
 						return nil, err
 					}
-					if m {
+					switch {
+					case filter.IsExclusion() && m:
+						trace.reject(name, version, filter, "the tag matches the excluded pattern %s", pattern)
+					case filter.IsExclusion():
+						trace.accept(name, version, filter, "the tag doesn't match the excluded pattern %s", pattern)
 						versions = append(versions, version)
+					case m:
+						trace.accept(name, version, filter, "the tag matches the pattern %s", pattern)
+						versions = append(versions, version)
+					default:
+						trace.reject(name, version, filter, "the tag doesn't match the pattern %s", pattern)
 					}
 				}
 				if len(versions) == 0 {
+					if filter.IsExclusion() {
+						trace.reject(name, "", filter, "all tags match the excluded pattern %s", pattern)
+					} else {
+						trace.reject(name, "", filter, "no tag matches the pattern %s", pattern)
+					}
 					match = false
 					break FILTER_LOOP
 				}
 				// NOTE: the property "Vtags" of the origin resource struct is overrided here
 				resource.Metadata.Vtags = versions
 			case model.FilterTypeLabel:
-				// TODO add support to label
+				labels, err := filter.GetLabels()
+				if err != nil {
+					return nil, err
+				}
+				// no label specified in the filter, the same as the adapters do
+				if len(labels) == 0 {
+					trace.accept(name, "", filter, "no label is specified")
+					continue
+				}
+				if resource.Metadata == nil || len(resource.Metadata.Labels) == 0 {
+					trace.reject(name, "", filter, "the resource has no labels")
+					match = false
+					break FILTER_LOOP
+				}
+				label, m := matchLabels(labels, resource.Metadata.Labels)
+				if !m {
+					trace.reject(name, "", filter, "the resource has none of the labels %v", labels)
+					match = false
+					break FILTER_LOOP
+				}
+				trace.accept(name, "", filter, "the resource has the label %s", label)
+			case model.FilterTypeDigest:
+				if resource.Metadata == nil || len(resource.Metadata.Digests) == 0 {
+					trace.reject(name, "", filter, "the digests of the resource are unknown")
+					match = false
+					break FILTER_LOOP
+				}
+				var versions []string
+				for _, version := range resource.Metadata.Vtags {
+					digest := resource.Metadata.Digests[version]
+					if _, exist := digests[filter][digest]; exist {
+						trace.accept(name, version, filter, "the digest %s matches", digest)
+						versions = append(versions, version)
+					} else {
+						trace.reject(name, version, filter, "the digest %s doesn't match", digest)
+					}
+				}
+				if len(versions) == 0 {
+					trace.reject(name, "", filter, "no tag has the specified digests")
+					match = false
+					break FILTER_LOOP
+				}
+				resource.Metadata.Vtags = versions
+			case model.FilterTypeModified:
+				// the destination registry is needed to apply this filter,
+				// it is applied by "filterUnmodifiedResources"
+			case model.FilterTypeMaxInactivity:
+				// the push time of all the tags of the repositories is needed
+				// to apply this filter, it is applied by "filterDormantResources"
+			case model.FilterTypeVisibility:
+				// the option of the policy for the unknown visibility is needed to
+				// apply this filter, it is applied by "filterByVisibility"
+			case model.FilterTypePullCount:
+				// the option of the policy for the unknown pull count is needed to
+				// apply this filter, it is applied by "filterByPullCount"
+			case model.FilterTypePlatform:
+				// the manifests of the tags are needed to apply this filter,
+				// it is applied by "filterByPlatform"
 			default:
-				return nil, fmt.Errorf("unsupportted filter type: %v", filter.Type)
+				factory := getTagSelectorFactory(filter.Type)
+				if factory == nil {
+					return nil, fmt.Errorf("unsupportted filter type: %v", filter.Type)
+				}
+				// the source adapter is needed to apply this filter,
+				// it is applied by "selectTags"
+				if factory.needsAdapter {
+					continue
+				}
+				if resource.Metadata == nil {
+					trace.reject(name, "", filter, "the resource has no metadata")
+					match = false
+					break FILTER_LOOP
+				}
+				selector, err := factory.create(filter, nil)
+				if err != nil {
+					return nil, err
+				}
+				if selector == nil {
+					trace.accept(name, "", filter, "all the tags are selected")
+					continue
+				}
+				versions, err := selector.Select(resource)
+				if err != nil {
+					return nil, err
+				}
+				if trace != nil {
+					kept := map[string]struct{}{}
+					for _, version := range versions {
+						kept[version] = struct{}{}
+					}
+					for _, version := range resource.Metadata.Vtags {
+						if _, exist := kept[version]; exist {
+							trace.accept(name, version, filter, "the tag is %s", selector.Describe())
+						} else {
+							trace.reject(name, version, filter, "the tag isn't %s", selector.Describe())
+						}
+					}
+				}
+				if len(versions) == 0 {
+					trace.reject(name, "", filter, "no tag is %s", selector.Describe())
+					match = false
+					break FILTER_LOOP
+				}
+				resource.Metadata.Vtags = versions
 			}
 		}
 		if match {
 			res = append(res, resource)
+		} else if dropped != nil {
+			dropped[filter.Type]++
 		}
 	}
 	log.Debug("filter resources completed")
 	return res, nil
 }
 
-// assemble the source resources by filling the registry information
+// assemble the source resources by filling the registry information,
+// the duplicate tags of the resources are removed
 func assembleSourceResources(resources []*model.Resource,
 	policy *model.Policy) []*model.Resource {
 	for _, resource := range resources {
 		resource.Registry = policy.SrcRegistry
+		if resource.Metadata != nil {
+			resource.Metadata.Vtags = dedupTags(resource.Metadata.Vtags)
+		}
 	}
 	log.Debug("assemble the source resources completed")
 	return resources
 }
 
-// assemble the destination resources by filling the metadata, registry and override properties
+// assemble the destination resources by filling the metadata, registry and the copy options
 func assembleDestinationResources(resources []*model.Resource,
 	policy *model.Policy) []*model.Resource {
 	var result []*model.Resource
 	for _, resource := range resources {
+		name := resource.Metadata.Repository.Name
+		if policy.StripLibraryNamespace {
+			name = stripLibraryNamespace(name)
+		}
+		var repository string
+		if len(policy.NamespaceMappings) > 0 {
+			repository = mapNamespace(name, policy.NamespaceMappings)
+		} else {
+			repository = replaceNamespace(name, policy.DestNamespace)
+		}
+		namespace, _ := util.ParseNamespace(repository)
 		res := &model.Resource{
-			Type:         resource.Type,
-			Registry:     policy.DestRegistry,
-			ExtendedInfo: resource.ExtendedInfo,
-			Deleted:      resource.Deleted,
-			Override:     policy.Override,
+			Type:            resource.Type,
+			Registry:        policy.DestRegistry,
+			ExtendedInfo:    resource.ExtendedInfo,
+			Deleted:         resource.Deleted,
+			Override:        policy.GetOverride(namespace),
+			Move:            policy.Move,
+			BlobIdleTimeout: policy.BlobIdleTimeout,
+			BlobBufferSize:  policy.BlobBufferSize,
+			TagConcurrency:  policy.TagConcurrency,
+			TaskDeadline:    policy.TaskDeadline,
+			RateLimitAsSkip: policy.RateLimitAsSkip,
+			Signer:          policy.Signer,
+			SigningFailure:  policy.SigningFailure,
+
+			ManifestPushRetries: policy.ManifestPushRetries,
+			ConfigBlobFirst:     policy.ConfigBlobFirst,
+			MaxBlobUploads:      policy.MaxDestinationBlobUploads,
+			BandwidthLimit:      policy.BandwidthLimit,
+			ProjectSettings:     policy.DestProjectSettings,
+
+			MediaTypeTranslation:      policy.MediaTypeTranslation,
+			CleanupOrphanedSignatures: policy.CleanupOrphanedSignatures,
 		}
 		res.Metadata = &model.ResourceMetadata{
 			Repository: &model.Repository{
-				Name:     replaceNamespace(resource.Metadata.Repository.Name, policy.DestNamespace),
+				Name:     repository,
 				Metadata: resource.Metadata.Repository.Metadata,
 			},
 			Vtags: resource.Metadata.Vtags,
 		}
+		if len(policy.TagNormalization) > 0 && resource.Type == model.ResourceTypeImage {
+			// NOTE: the source and destination resources share the same "Vtags", set them separately
+			resource.Metadata.Vtags, res.Metadata.Vtags = normalizeTags(resource.Metadata.GetResourceName(),
+				resource.Metadata.Vtags, policy.TagNormalization)
+		}
 		result = append(result, res)
 	}
 	log.Debug("assemble the destination resources completed")
 	return result
 }
 
+// check whether the "modified" filter is enabled in the policy
+func isModifiedFilterEnabled(policy *model.Policy) bool {
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypeModified {
+			continue
+		}
+		if enabled, ok := filter.Value.(bool); ok && enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// compare the digests of the image tags between the source and destination registries,
+// only the tags whose digests differ(or don't exist on the destination registry) are kept.
+// The resources without any divergent tag are dropped. Only works for the image resources,
+// other resources are kept as they are
+func filterUnmodifiedResources(srcAdapter, dstAdapter adp.Adapter, srcResources,
+	dstResources []*model.Resource, policy *model.Policy) ([]*model.Resource, []*model.Resource, error) {
+	if !isModifiedFilterEnabled(policy) {
+		return srcResources, dstResources, nil
+	}
+	srcRegistry, ok := srcAdapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, nil, fmt.Errorf("the source adapter doesn't implement the ImageRegistry interface")
+	}
+	dstRegistry, ok := dstAdapter.(adp.ImageRegistry)
+	if !ok {
+		return nil, nil, fmt.Errorf("the destination adapter doesn't implement the ImageRegistry interface")
+	}
+	var srcResult, dstResult []*model.Resource
+	for i, srcResource := range srcResources {
+		dstResource := dstResources[i]
+		if srcResource.Type != model.ResourceTypeImage || srcResource.Deleted ||
+			len(srcResource.Metadata.Vtags) == 0 {
+			srcResult = append(srcResult, srcResource)
+			dstResult = append(dstResult, dstResource)
+			continue
+		}
+		srcTags, dstTags, err := getModifiedTags(srcRegistry, dstRegistry, srcResource, dstResource)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(srcTags) == 0 {
+			continue
+		}
+		// NOTE: the source and destination resources share the same "Vtags", set them separately
+		srcResource.Metadata.Vtags = srcTags
+		dstResource.Metadata.Vtags = dstTags
+		srcResult = append(srcResult, srcResource)
+		dstResult = append(dstResult, dstResource)
+	}
+	log.Debug("filter unmodified resources completed")
+	return srcResult, dstResult, nil
+}
+
+// get the resources that are dropped by the filtering which keeps the same
+// instances of the resources
+func getDroppedResources(srcResources, dstResources,
+	kept []*model.Resource) ([]*model.Resource, []*model.Resource) {
+	keptSet := map[*model.Resource]struct{}{}
+	for _, resource := range kept {
+		keptSet[resource] = struct{}{}
+	}
+	var srcResult, dstResult []*model.Resource
+	for i, resource := range srcResources {
+		if _, exist := keptSet[resource]; exist {
+			continue
+		}
+		srcResult = append(srcResult, resource)
+		dstResult = append(dstResult, dstResources[i])
+	}
+	return srcResult, dstResult
+}
+
+// get the tags of the image resource whose digests differ between the source and destination registries
+func getModifiedTags(srcRegistry, dstRegistry adp.ImageRegistry, srcResource,
+	dstResource *model.Resource) ([]string, []string, error) {
+	srcRepository := srcResource.Metadata.Repository.Name
+	dstRepository := dstResource.Metadata.Repository.Name
+	var srcTags, dstTags []string
+	for i, srcTag := range srcResource.Metadata.Vtags {
+		dstTag := dstResource.Metadata.Vtags[i]
+		_, srcDigest, err := srcRegistry.ManifestExist(srcRepository, srcTag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the digest of %s:%s on the source registry: %v",
+				srcRepository, srcTag, err)
+		}
+		exist, dstDigest, err := dstRegistry.ManifestExist(dstRepository, dstTag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get the digest of %s:%s on the destination registry: %v",
+				dstRepository, dstTag, err)
+		}
+		if exist && srcDigest == dstDigest {
+			log.Debugf("the digests of %s:%s and %s:%s are same, skip", srcRepository, srcTag, dstRepository, dstTag)
+			continue
+		}
+		srcTags = append(srcTags, srcTag)
+		dstTags = append(dstTags, dstTag)
+	}
+	return srcTags, dstTags, nil
+}
+
 // do the prepare work for pushing/uploading the resources: create the namespace or repository
 func prepareForPush(adapter adp.Adapter, resources []*model.Resource) error {
 	if err := adapter.PrepareForPush(resources); err != nil {
@@ -226,7 +653,11 @@ func prepareForPush(adapter adp.Adapter, resources []*model.Resource) error {
 	return nil
 }
 
-// preprocess
+// preprocess the resources into the schedule items. Only the copies of the source
+// resources are scheduled here, the destination-only tags are left untouched by them.
+// The deletions of the copy flow, i.e. the tags removed from the source when the policy
+// propagates the tag deletion and the ones expired by the TTL, are scheduled separately
+// by "detectRemovedTags" and "expireDestinationTags"
 func preprocess(scheduler scheduler.Scheduler, srcResources, dstResources []*model.Resource) ([]*scheduler.ScheduleItem, error) {
 	items, err := scheduler.Preprocess(srcResources, dstResources)
 	if err != nil {
@@ -236,21 +667,20 @@ func preprocess(scheduler scheduler.Scheduler, srcResources, dstResources []*mod
 	return items, nil
 }
 
-// create task records in database
-func createTasks(mgr execution.Manager, executionID int64, items []*scheduler.ScheduleItem) error {
+// create task records in database, the items whose resources have more tags than
+// tagsPerTask are split into several items first and each of them gets its own task.
+// Returns the items which the tasks are created for
+func createTasks(mgr execution.Manager, executionID int64, items []*scheduler.ScheduleItem,
+	tagsPerTask int) ([]*scheduler.ScheduleItem, error) {
+	items = splitItemsByTags(items, tagsPerTask)
 	for _, item := range items {
-		operation := "copy"
-		if item.DstResource.Deleted {
-			operation = "deletion"
-		}
-
 		task := &models.Task{
 			ExecutionID:  executionID,
 			Status:       models.TaskStatusInitialized,
 			ResourceType: string(item.SrcResource.Type),
 			SrcResource:  getResourceName(item.SrcResource),
 			DstResource:  getResourceName(item.DstResource),
-			Operation:    operation,
+			Operation:    getOperation(item),
 		}
 
 		id, err := mgr.CreateTask(task)
@@ -258,42 +688,124 @@ func createTasks(mgr execution.Manager, executionID int64, items []*scheduler.Sc
 			// if failed to create the task for one of the items,
 			// the whole execution is marked as failure and all
 			// the items will not be submitted
-			return fmt.Errorf("failed to create task records for the execution %d: %v", executionID, err)
+			return nil, fmt.Errorf("failed to create task records for the execution %d: %v", executionID, err)
 		}
 
 		item.TaskID = id
 		log.Debugf("task record %d for the execution %d created", id, executionID)
 	}
-	return nil
+	return items, nil
 }
 
-// schedule the replication tasks and update the task's status
-// returns the count of tasks which have been scheduled and the error
-func schedule(scheduler scheduler.Scheduler, executionMgr execution.Manager, items []*scheduler.ScheduleItem) (int, error) {
-	results, err := scheduler.Schedule(items)
+func getOperation(item *scheduler.ScheduleItem) string {
+	if item.DstResource.Deleted {
+		return "deletion"
+	}
+	if item.DstResource.Move {
+		return "move"
+	}
+	return "copy"
+}
+
+// create the task records for the resources that are skipped intentionally(e.g. the
+// resources which aren't modified), the tasks are marked as "skipped" directly and
+// won't be submitted. Returns the count of the skipped tasks
+func createSkippedTasks(mgr execution.Manager, executionID int64, srcResources,
+	dstResources []*model.Resource) (int, error) {
+	var items []*scheduler.ScheduleItem
+	for i, srcResource := range srcResources {
+		items = append(items, &scheduler.ScheduleItem{
+			SrcResource: srcResource,
+			DstResource: dstResources[i],
+		})
+	}
+	items, err := createTasks(mgr, executionID, items, 0)
 	if err != nil {
-		return 0, fmt.Errorf("failed to schedule the tasks: %v", err)
+		return 0, err
+	}
+	for _, item := range items {
+		if err := mgr.UpdateTaskStatus(item.TaskID, models.TaskStatusSkipped, models.TaskStatusInitialized); err != nil {
+			log.Errorf("failed to update the task status %d: %v", item.TaskID, err)
+		}
+	}
+	return len(items), nil
+}
+
+// schedule the replication tasks and update the task's status, the outcome is
+// recorded into the summary if it is provided and the progress is reported to the
+// optional progress callbacks as each task moves from initialized to pending(or failed).
+// When the context is cancelled, the items not submitted yet are marked as stopped and
+// the error of the context is returned, the submitted ones are left as they are.
+// returns the count of tasks which have been scheduled and the error
+func schedule(ctx context.Context, sched scheduler.Scheduler, executionMgr execution.Manager,
+	items []*scheduler.ScheduleItem, policy *model.Policy, sum *summary, progress ...ProgressFunc) (int, error) {
+	tracker := newProgressTracker(len(items), progress)
+	var results []*scheduler.ScheduleResult
+	var cancelled error
+	if limit := getInFlightLimit(policy); limit > 0 {
+		results, cancelled = submitInFlight(ctx, sched, executionMgr, items, policy, limit, tracker)
+	} else {
+		var rest []*scheduler.ScheduleItem
+		var err error
+		results, rest, err = submit(ctx, sched, items)
+		if err != nil {
+			return 0, fmt.Errorf("failed to schedule the tasks: %v", err)
+		}
+		updateScheduledTasks(executionMgr, results, tracker)
+		if len(rest) > 0 {
+			stopQueuedTasks(executionMgr, rest)
+			cancelled = ctx.Err()
+		}
 	}
 
-	allFailed := true
 	n := len(results)
+	failed := 0
+	for _, result := range results {
+		if result.Error != nil {
+			failed++
+		}
+	}
+	if sum != nil {
+		sum.Submitted, sum.Failed = n-failed, sum.Failed+failed
+	}
+	if cancelled != nil {
+		log.Infof("the scheduling is cancelled after %d of %d tasks are submitted", n, len(items))
+		return n, cancelled
+	}
+	// if all the tasks are failed, return err
+	if failed == n {
+		return n, errors.New("all tasks are failed")
+	}
+	return n, nil
+}
+
+// update the status of the tasks according to the results of the submission, the
+// progress is reported as each task is updated
+func updateScheduledTasks(executionMgr execution.Manager, results []*scheduler.ScheduleResult,
+	tracker *progressTracker) {
 	for _, result := range results {
 		// if the task is failed to be submitted, update the status of the
 		// task as failure
 		if result.Error != nil {
 			log.Errorf("failed to schedule the task %d: %v", result.TaskID, result.Error)
-			if err = executionMgr.UpdateTaskStatus(result.TaskID, models.TaskStatusFailed); err != nil {
+			if err := executionMgr.UpdateTaskStatus(result.TaskID, models.TaskStatusFailed); err != nil {
 				log.Errorf("failed to update the task status %d: %v", result.TaskID, err)
 			}
+			if err := executionMgr.UpdateTask(&models.Task{
+				ID:            result.TaskID,
+				ErrorCategory: string(classifier.Categorize(result.Error)),
+			}, models.TaskPropsName.ErrorCategory); err != nil {
+				log.Errorf("failed to update the error category of task %d: %v", result.TaskID, err)
+			}
+			tracker.failed()
 			continue
 		}
-		allFailed = false
 		// if the task is submitted successfully, update the status, job ID and start time
-		if err = executionMgr.UpdateTaskStatus(result.TaskID, models.TaskStatusPending, models.TaskStatusInitialized); err != nil {
+		if err := executionMgr.UpdateTaskStatus(result.TaskID, models.TaskStatusPending, models.TaskStatusInitialized); err != nil {
 			log.Errorf("failed to update the task status %d: %v", result.TaskID, err)
 		}
 		now := time.Now()
-		if err = executionMgr.UpdateTask(&models.Task{
+		if err := executionMgr.UpdateTask(&models.Task{
 			ID:        result.TaskID,
 			JobID:     result.JobID,
 			StartTime: &now,
@@ -301,12 +813,38 @@ func schedule(scheduler scheduler.Scheduler, executionMgr execution.Manager, ite
 			log.Errorf("failed to update the task %d: %v", result.TaskID, err)
 		}
 		log.Debugf("the task %d scheduled", result.TaskID)
+		tracker.scheduled()
 	}
-	// if all the tasks are failed, return err
-	if allFailed {
-		return n, errors.New("all tasks are failed")
+}
+
+// the count of the items submitted in one batch when the scheduling can be cancelled
+var scheduleBatchSize = 10
+
+// submit the items to the scheduler. The items are submitted in batches when
+// the context can be cancelled, so that the cancellation takes effect between
+// the batches, and the ones not submitted are returned
+func submit(ctx context.Context, sched scheduler.Scheduler,
+	items []*scheduler.ScheduleItem) ([]*scheduler.ScheduleResult, []*scheduler.ScheduleItem, error) {
+	if ctx.Done() == nil {
+		results, err := sched.Schedule(items)
+		return results, nil, err
 	}
-	return n, nil
+	var results []*scheduler.ScheduleResult
+	for i := 0; i < len(items); i += scheduleBatchSize {
+		if ctx.Err() != nil {
+			return results, items[i:], nil
+		}
+		end := i + scheduleBatchSize
+		if end > len(items) {
+			end = len(items)
+		}
+		rs, err := sched.Schedule(items[i:end])
+		if err != nil {
+			return nil, nil, err
+		}
+		results = append(results, rs...)
+	}
+	return results, nil, nil
 }
 
 // check whether the execution is stopped
@@ -336,19 +874,147 @@ func getResourceName(res *model.Resource) string {
 	}
 
 	if len(meta.Vtags) <= 5 {
-		return meta.Repository.Name + ":[" + strings.Join(meta.Vtags, ",") + "]"
+		return meta.Repository.Name + ":[" + joinTags(meta.Vtags) + "]"
 	}
 
-	return fmt.Sprintf("%s:[%s ... %d in total]", meta.GetResourceName(), strings.Join(meta.Vtags[:5], ","), len(meta.Vtags))
+	return fmt.Sprintf("%s:[%s ... %d in total]", meta.GetResourceName(), joinTags(meta.Vtags[:5]), len(meta.Vtags))
 }
 
+// the separators(",", "[" and "]") and the backslash in the tags are escaped with a backslash,
+// so the tags without them are joined as before and the old names are still parsable
+var tagEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "[", `\[`, "]", `\]`)
+
+func joinTags(tags []string) string {
+	escaped := make([]string, len(tags))
+	for i, tag := range tags {
+		escaped[i] = tagEscaper.Replace(tag)
+	}
+	return strings.Join(escaped, ",")
+}
+
+// parse the name generated by "getResourceName" back to the repository and tags,
+// the names with the truncated tags can't be parsed
+func parseResourceName(name string) (string, []string, error) {
+	i := strings.Index(name, ":[")
+	if i <= 0 || !strings.HasSuffix(name, "]") {
+		return "", nil, fmt.Errorf("the tags of the resource %s are unknown", name)
+	}
+	var tags []string
+	var tag strings.Builder
+	escaped := false
+	for _, c := range name[i+2 : len(name)-1] {
+		switch {
+		case escaped:
+			tag.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ',':
+			tags = append(tags, tag.String())
+			tag.Reset()
+		case c == '[' || c == ']':
+			return "", nil, fmt.Errorf("invalid resource name %s: unescaped %c", name, c)
+		default:
+			tag.WriteRune(c)
+		}
+	}
+	if escaped {
+		return "", nil, fmt.Errorf("invalid resource name %s: incomplete escape", name)
+	}
+	tags = append(tags, tag.String())
+	if strings.HasSuffix(tags[len(tags)-1], " in total") && strings.Contains(tags[len(tags)-1], " ... ") {
+		return "", nil, fmt.Errorf("the tags of the resource %s are unknown", name)
+	}
+	return name[:i], tags, nil
+}
+
+// only the leading namespace segment(before the first "/") is replaced
 // repository:c namespace:n -> n/c
 // repository:b/c namespace:n -> n/c
-// repository:a/b/c namespace:n -> n/c
+// repository:a/b/c namespace:n -> n/b/c
 func replaceNamespace(repository string, namespace string) string {
 	if len(namespace) == 0 {
 		return repository
 	}
-	_, rest := util.ParseRepository(repository)
+	rest := repository[strings.Index(repository, "/")+1:]
 	return fmt.Sprintf("%s/%s", namespace, rest)
 }
+
+// map the repository by the first matched namespace mapping, the repository
+// is returned as it is if no mapping matches it
+func mapNamespace(repository string, mappings []*model.NamespaceMapping) string {
+	for _, mapping := range mappings {
+		if !strings.HasSuffix(mapping.From, "*") {
+			if repository == mapping.From {
+				return mapping.To
+			}
+			continue
+		}
+		prefix := strings.TrimSuffix(mapping.From, "*")
+		if !strings.HasPrefix(repository, prefix) || len(repository) == len(prefix) {
+			continue
+		}
+		return strings.TrimSuffix(mapping.To, "*") + strings.TrimPrefix(repository, prefix)
+	}
+	return repository
+}
+
+// normalize the tags and return the source tags and the normalized destination tags
+// mapped by index. When several source tags are normalized to the same destination
+// tag, they collide and only the first one is kept
+func normalizeTags(repository string, tags []string, normalization string) ([]string, []string) {
+	if len(tags) == 0 {
+		return tags, tags
+	}
+	srcTags := make([]string, 0, len(tags))
+	dstTags := make([]string, 0, len(tags))
+	normalized := map[string]string{}
+	for _, tag := range tags {
+		dstTag := normalizeTag(tag, normalization)
+		if origin, exist := normalized[dstTag]; exist {
+			log.Warningf("the tags %s and %s of %s collide after being normalized to %s, skip %s",
+				origin, tag, repository, dstTag, tag)
+			continue
+		}
+		normalized[dstTag] = tag
+		srcTags = append(srcTags, tag)
+		dstTags = append(dstTags, dstTag)
+	}
+	return srcTags, dstTags
+}
+
+// normalize the tag by the normalization of the policy
+func normalizeTag(tag string, normalization string) string {
+	if normalization == model.TagNormalizationLowercase {
+		return strings.ToLower(tag)
+	}
+	return tag
+}
+
+// remove the duplicate tags and keep the order in which they're first seen
+func dedupTags(tags []string) []string {
+	if len(tags) <= 1 {
+		return tags
+	}
+	seen := map[string]struct{}{}
+	deduped := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if _, exist := seen[tag]; exist {
+			continue
+		}
+		seen[tag] = struct{}{}
+		deduped = append(deduped, tag)
+	}
+	return deduped
+}
+
+// repository:library/c -> c
+// repository:b/c -> b/c
+// repository:library/b/c -> b/c
+func stripLibraryNamespace(repository string) string {
+	namespace, rest := util.ParseNamespace(repository)
+	if namespace != "library" {
+		return repository
+	}
+	return rest
+}
@@ -15,12 +15,13 @@
 package flow
 
 import (
-	"io"
+	"context"
 	"os"
+	"strings"
 	"testing"
 
-	"github.com/docker/distribution"
 	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/adapter/test"
 	"github.com/goharbor/harbor/src/replication/config"
 	"github.com/goharbor/harbor/src/replication/dao/models"
 	"github.com/goharbor/harbor/src/replication/model"
@@ -29,89 +30,37 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func fakedAdapterFactory(*model.Registry) (adapter.Adapter, error) {
-	return &fakedAdapter{}, nil
-}
-
-type fakedAdapter struct{}
-
-func (f *fakedAdapter) Info() (*model.RegistryInfo, error) {
-	return &model.RegistryInfo{
-		Type: model.RegistryTypeHarbor,
-		SupportedResourceTypes: []model.ResourceType{
-			model.ResourceTypeImage,
-			model.ResourceTypeChart,
-		},
-		SupportedTriggers: []model.TriggerType{model.TriggerTypeManual},
-	}, nil
-}
-
-func (f *fakedAdapter) PrepareForPush([]*model.Resource) error {
-	return nil
-}
-func (f *fakedAdapter) HealthCheck() (model.HealthStatus, error) {
-	return model.Healthy, nil
-}
-func (f *fakedAdapter) FetchImages(filters []*model.Filter) ([]*model.Resource, error) {
-	return []*model.Resource{
-		{
-			Type: model.ResourceTypeImage,
-			Metadata: &model.ResourceMetadata{
-				Repository: &model.Repository{
-					Name: "library/hello-world",
+// returns the adapter with the image "library/hello-world:latest" and the chart "library/harbor:0.2.0"
+func newFakedAdapter() *test.Adapter {
+	return &test.Adapter{
+		Images: []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"latest"},
 				},
-				Vtags: []string{"latest"},
+				Override: false,
 			},
-			Override: false,
 		},
-	}, nil
-}
-
-func (f *fakedAdapter) ManifestExist(repository, reference string) (exist bool, digest string, err error) {
-	return false, "", nil
-}
-func (f *fakedAdapter) PullManifest(repository, reference string, accepttedMediaTypes []string) (manifest distribution.Manifest, digest string, err error) {
-	return nil, "", nil
-}
-func (f *fakedAdapter) PushManifest(repository, reference, mediaType string, payload []byte) error {
-	return nil
-}
-func (f *fakedAdapter) DeleteManifest(repository, digest string) error {
-	return nil
-}
-func (f *fakedAdapter) BlobExist(repository, digest string) (exist bool, err error) {
-	return false, nil
-}
-func (f *fakedAdapter) PullBlob(repository, digest string) (size int64, blob io.ReadCloser, err error) {
-	return 0, nil, nil
-}
-func (f *fakedAdapter) PushBlob(repository, digest string, size int64, blob io.Reader) error {
-	return nil
-}
-func (f *fakedAdapter) FetchCharts(filters []*model.Filter) ([]*model.Resource, error) {
-	return []*model.Resource{
-		{
-			Type: model.ResourceTypeChart,
-			Metadata: &model.ResourceMetadata{
-				Repository: &model.Repository{
-					Name: "library/harbor",
+		Charts: []*model.Resource{
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/harbor",
+					},
+					Vtags: []string{"0.2.0"},
 				},
-				Vtags: []string{"0.2.0"},
 			},
 		},
-	}, nil
-}
-func (f *fakedAdapter) ChartExist(name, version string) (bool, error) {
-	return false, nil
-}
-func (f *fakedAdapter) DownloadChart(name, version string) (io.ReadCloser, error) {
-	return nil, nil
-}
-func (f *fakedAdapter) UploadChart(name, version string, chart io.Reader) error {
-	return nil
+	}
 }
-func (f *fakedAdapter) DeleteChart(name, version string) error {
-	return nil
+
+func fakedAdapterFactory(*model.Registry) (adapter.Adapter, error) {
+	return newFakedAdapter(), nil
 }
 
 type fakedScheduler struct{}
@@ -140,75 +89,780 @@ func (f *fakedScheduler) Stop(id string) error {
 	return nil
 }
 
-type fakedExecutionManager struct {
-	taskID int64
+// returns the in-memory execution manager with the execution 1 in progress
+func newFakedExecutionManager() *test.ExecutionManager {
+	mgr := test.NewExecutionManager()
+	mgr.Create(&models.Execution{
+		Status: models.ExecutionStatusInProgress,
+	})
+	return mgr
 }
 
-func (f *fakedExecutionManager) Create(*models.Execution) (int64, error) {
-	return 1, nil
+// returns the in-memory execution manager with the execution 1 in progress
+// and its "n" initialized tasks whose IDs are from 1 to n
+func newFakedExecutionManagerWithTasks(n int) *test.ExecutionManager {
+	mgr := newFakedExecutionManager()
+	for i := 0; i < n; i++ {
+		mgr.CreateTask(&models.Task{
+			ExecutionID: 1,
+			Status:      models.TaskStatusInitialized,
+		})
+	}
+	return mgr
 }
-func (f *fakedExecutionManager) List(...*models.ExecutionQuery) (int64, []*models.Execution, error) {
-	return 0, nil, nil
+
+// returns the statuses of the tasks updated indexed by the task ID
+func updatedTaskStatuses(mgr *test.ExecutionManager) map[int64]string {
+	statuses := map[int64]string{}
+	for _, task := range mgr.Tasks() {
+		if len(mgr.TaskStatuses(task.ID)) > 0 {
+			statuses[task.ID] = task.Status
+		}
+	}
+	return statuses
 }
-func (f *fakedExecutionManager) Get(int64) (*models.Execution, error) {
-	return &models.Execution{}, nil
+
+func TestMain(m *testing.M) {
+	url := "https://registry.harbor.local"
+	config.Config = &config.Configuration{
+		CoreURL: url,
+	}
+	if err := adapter.RegisterFactory(model.RegistryTypeHarbor, fakedAdapterFactory); err != nil {
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
 }
-func (f *fakedExecutionManager) Update(*models.Execution, ...string) error {
-	return nil
+
+func TestInitialize(t *testing.T) {
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+	}
+	_, _, err := initialize(policy)
+	require.Nil(t, err)
+	assert.Equal(t, "", policy.SrcRegistry.UserAgent)
+
+	// the registries carry the User-Agent of the policy
+	policy.UserAgent = "mirror/policy01"
+	_, _, err = initialize(policy)
+	require.Nil(t, err)
+	assert.Equal(t, "mirror/policy01", policy.SrcRegistry.UserAgent)
+	assert.Equal(t, "mirror/policy01", policy.DestRegistry.UserAgent)
+
+	// the registries carry the max manifest size of the policy
+	policy.MaxManifestSize = 1024
+	_, _, err = initialize(policy)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1024), policy.SrcRegistry.MaxManifestSize)
+	assert.Equal(t, int64(1024), policy.DestRegistry.MaxManifestSize)
 }
-func (f *fakedExecutionManager) Remove(int64) error {
-	return nil
+
+func TestFetchResources(t *testing.T) {
+	adapter := newFakedAdapter()
+	policy := &model.Policy{}
+	resources, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(resources))
+
+	// the value of resource filter can be both string and ResourceType
+	for _, value := range []interface{}{"image", model.ResourceTypeImage} {
+		policy.Filters = []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: value,
+			},
+		}
+		resources, _, err = fetchResources(context.Background(), adapter, policy, nil)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(resources))
+		assert.Equal(t, model.ResourceTypeImage, resources[0].Type)
+	}
+
+	// invalid value
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeResource,
+			Value: 1,
+		},
+	}
+	_, _, err = fetchResources(context.Background(), adapter, policy, nil)
+	assert.NotNil(t, err)
 }
-func (f *fakedExecutionManager) RemoveAll(int64) error {
-	return nil
+
+func TestFetchResourcesWithNoSupportedResourceTypes(t *testing.T) {
+	// the adapter supports no resource types, e.g. misconfigured
+	adapter := newFakedAdapter()
+	adapter.RegistryInfo = &model.RegistryInfo{
+		Type: model.RegistryTypeHarbor,
+	}
+	policy := &model.Policy{}
+	_, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "supports no resource types")
+
+	// the resource types specified by the filter are fetched
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeResource,
+			Value: model.ResourceTypeImage,
+		},
+	}
+	resources, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(resources))
 }
-func (f *fakedExecutionManager) CreateTask(*models.Task) (int64, error) {
-	f.taskID++
-	id := f.taskID
-	return id, nil
+
+func TestFetchResourcesWithScopedFilters(t *testing.T) {
+	adapter := &test.Adapter{}
+	imageFilter := &model.Filter{
+		Type:  model.FilterTypeName,
+		Value: "library/**",
+		Scope: model.ResourceTypeImage,
+	}
+	tagFilter := &model.Filter{
+		Type:  model.FilterTypeTag,
+		Value: "v*",
+	}
+	policy := &model.Policy{
+		Filters: []*model.Filter{imageFilter, tagFilter},
+	}
+	_, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []*model.Filter{imageFilter, tagFilter}, adapter.Filters("FetchImages"))
+	assert.Equal(t, []*model.Filter{tagFilter}, adapter.Filters("FetchCharts"))
 }
-func (f *fakedExecutionManager) ListTasks(...*models.TaskQuery) (int64, []*models.Task, error) {
-	return 0, nil, nil
+
+func TestFetchResourcesWithRegexFilters(t *testing.T) {
+	adapter := &test.Adapter{}
+	nameFilter := &model.Filter{
+		Type:  model.FilterTypeName,
+		Value: `^library/.+$`,
+		Mode:  model.FilterModeRegex,
+	}
+	tagFilter := &model.Filter{
+		Type:  model.FilterTypeTag,
+		Value: "v*",
+	}
+	policy := &model.Policy{
+		Filters: []*model.Filter{nameFilter, tagFilter},
+	}
+	// the regular expressions aren't passed to the adapters
+	_, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []*model.Filter{tagFilter}, adapter.Filters("FetchImages"))
+	assert.Equal(t, 0, len(getSrcNamespaces(policy)))
+
+	// neither are the exclusions
+	adapter = &test.Adapter{}
+	policy.Filters = []*model.Filter{
+		{
+			Type:       model.FilterTypeName,
+			Value:      "library/**",
+			Decoration: model.FilterDecorationExcludes,
+		},
+		tagFilter,
+	}
+	_, _, err = fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []*model.Filter{tagFilter}, adapter.Filters("FetchImages"))
+	assert.Equal(t, 0, len(getSrcNamespaces(policy)))
+
+	// neither are the semver ranges
+	adapter = &test.Adapter{}
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: ">=1.4.0 <2.0.0",
+			Mode:  model.FilterModeSemver,
+		},
+	}
+	_, _, err = fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(adapter.Filters("FetchImages")))
 }
-func (f *fakedExecutionManager) GetTask(int64) (*models.Task, error) {
-	return nil, nil
+
+type fakedNamespaceCheckerAdapter struct {
+	test.Adapter
+	namespaces []string
 }
-func (f *fakedExecutionManager) UpdateTask(*models.Task, ...string) error {
-	return nil
+
+func (f *fakedNamespaceCheckerAdapter) NamespaceExist(namespace string) (bool, error) {
+	for _, ns := range f.namespaces {
+		if ns == namespace {
+			return true, nil
+		}
+	}
+	return false, nil
 }
-func (f *fakedExecutionManager) UpdateTaskStatus(int64, string, ...string) error {
-	return nil
+
+func TestGetSrcNamespaces(t *testing.T) {
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeName,
+				Value: "{library,harbor}/**",
+			},
+			{
+				Type:  model.FilterTypeName,
+				Value: "hello-world",
+			},
+			{
+				Type:  model.FilterTypeName,
+				Value: "lib*/**",
+			},
+			{
+				Type:  model.FilterTypeTag,
+				Value: "test/**",
+			},
+		},
+	}
+	assert.Equal(t, []string{"library", "harbor"}, getSrcNamespaces(policy))
 }
-func (f *fakedExecutionManager) RemoveTask(int64) error {
-	return nil
+
+func TestCheckSrcNamespaces(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeName,
+				Value: "libary/**",
+			},
+		},
+	}
+	// lenient mode
+	assert.Nil(t, checkSrcNamespaces(newFakedAdapter(), policy, resources))
+
+	// strict mode, the adapter cannot check the namespace
+	policy.StrictSrcNamespace = true
+	err := checkSrcNamespaces(newFakedAdapter(), policy, resources)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "libary")
+
+	// strict mode, the adapter can check the namespace
+	adapter := &fakedNamespaceCheckerAdapter{
+		namespaces: []string{"library"},
+	}
+	err = checkSrcNamespaces(adapter, policy, nil)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "libary")
+
+	policy.Filters[0].Value = "library/**"
+	assert.Nil(t, checkSrcNamespaces(adapter, policy, nil))
+	assert.Nil(t, checkSrcNamespaces(newFakedAdapter(), policy, resources))
 }
-func (f *fakedExecutionManager) RemoveAllTasks(int64) error {
-	return nil
+
+func TestFilterResources(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+				// TODO test labels
+				Labels: nil,
+			},
+			Deleted:  true,
+			Override: true,
+		},
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/harbor",
+				},
+				Vtags: []string{"0.2.0", "0.3.0"},
+				// TODO test labels
+				Labels: nil,
+			},
+			Deleted:  true,
+			Override: true,
+		},
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/mysql",
+				},
+				Vtags: []string{"1.0"},
+				// TODO test labels
+				Labels: nil,
+			},
+			Deleted:  true,
+			Override: true,
+		},
+	}
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeResource,
+			Value: model.ResourceTypeChart,
+		},
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/*",
+		},
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/harbor",
+		},
+		{
+			Type:  model.FilterTypeTag,
+			Value: "0.2.?",
+		},
+	}
+	res, err := filterResources(resources, filters)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(res))
+	assert.Equal(t, "library/harbor", res[0].Metadata.Repository.Name)
+	assert.Equal(t, 1, len(res[0].Metadata.Vtags))
+	assert.Equal(t, "0.2.0", res[0].Metadata.Vtags[0])
 }
-func (f *fakedExecutionManager) GetTaskLog(int64) ([]byte, error) {
-	return nil, nil
+
+func TestFilterResourcesByResourceType(t *testing.T) {
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/harbor",
+					},
+					Vtags: []string{"0.2.0"},
+				},
+			},
+		}
+	}
+	// the value of resource filter can be both string and ResourceType
+	for _, value := range []interface{}{"chart", model.ResourceTypeChart} {
+		res, err := filterResources(newResources(), []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: value,
+			},
+		})
+		require.Nil(t, err)
+		require.Equal(t, 1, len(res))
+		assert.Equal(t, "library/harbor", res[0].Metadata.Repository.Name)
+	}
+
+	// invalid value
+	_, err := filterResources(newResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeResource,
+			Value: 1,
+		},
+	})
+	assert.NotNil(t, err)
 }
 
-func TestMain(m *testing.M) {
-	url := "https://registry.harbor.local"
-	config.Config = &config.Configuration{
-		CoreURL: url,
+func TestFilterResourcesWithScope(t *testing.T) {
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "charts/harbor",
+					},
+					Vtags: []string{"0.2.0"},
+				},
+			},
+		}
 	}
-	if err := adapter.RegisterFactory(model.RegistryTypeHarbor, fakedAdapterFactory); err != nil {
-		os.Exit(1)
+
+	// the image-scoped name filter doesn't affect the charts
+	res, err := filterResources(newResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/**",
+			Scope: model.ResourceTypeImage,
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(res))
+
+	// different name patterns per resource type
+	res, err = filterResources(newResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/busybox",
+			Scope: model.ResourceTypeImage,
+		},
+		{
+			Type:  model.FilterTypeName,
+			Value: "charts/**",
+			Scope: model.ResourceTypeChart,
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "charts/harbor", res[0].Metadata.Repository.Name)
+
+	// the unscoped filter applies to all
+	res, err = filterResources(newResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/**",
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "library/hello-world", res[0].Metadata.Repository.Name)
+}
+
+func TestFilterResourcesByLatestPatch(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"1.4.8", "latest", "1.5.2", "1.4.9", "1.5.3"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
 	}
-	os.Exit(m.Run())
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeLatestPatch,
+			Value: false,
+		},
+	}
+	res, err := filterResources(resources, filters)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "library/hello-world", res[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"1.4.9", "1.5.3"}, res[0].Metadata.Vtags)
+
+	// invalid filter value
+	_, err = filterResources(resources, []*model.Filter{
+		{
+			Type:  model.FilterTypeLatestPatch,
+			Value: "true",
+		},
+	})
+	assert.NotNil(t, err)
 }
 
-func TestFetchResources(t *testing.T) {
-	adapter := &fakedAdapter{}
-	policy := &model.Policy{}
-	resources, err := fetchResources(adapter, policy)
+func TestFilterResourcesByLabel(t *testing.T) {
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags:  []string{"latest"},
+					Labels: []string{"prod", "stable"},
+				},
+			},
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/busybox",
+					},
+					Vtags:  []string{"latest"},
+					Labels: []string{"dev"},
+				},
+			},
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/alpine",
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+		}
+	}
+	cases := []struct {
+		value        interface{}
+		repositories []string
+		err          bool
+	}{
+		// any of the labels matches
+		{[]string{"stable", "test"}, []string{"library/hello-world"}, false},
+		{[]string{"prod", "dev"}, []string{"library/hello-world", "library/busybox"}, false},
+		// no label matches
+		{[]string{"test"}, nil, false},
+		// the value got from JSON
+		{[]interface{}{"dev"}, []string{"library/busybox"}, false},
+		// no label specified
+		{[]string{}, []string{"library/hello-world", "library/busybox", "library/alpine"}, false},
+		// invalid value
+		{"prod", nil, true},
+		{[]interface{}{1}, nil, true},
+	}
+	for _, c := range cases {
+		res, err := filterResources(newResources(), []*model.Filter{
+			{
+				Type:  model.FilterTypeLabel,
+				Value: c.value,
+			},
+		})
+		require.Equal(t, c.err, err != nil)
+		var repositories []string
+		for _, r := range res {
+			repositories = append(repositories, r.Metadata.Repository.Name)
+		}
+		assert.Equal(t, c.repositories, repositories)
+	}
+
+	// the resource without metadata doesn't match
+	res, err := filterResources([]*model.Resource{{Type: model.ResourceTypeImage}}, []*model.Filter{
+		{
+			Type:  model.FilterTypeLabel,
+			Value: []string{"prod"},
+		},
+	})
 	require.Nil(t, err)
-	assert.Equal(t, 2, len(resources))
+	assert.Equal(t, 0, len(res))
+}
+
+func TestFilterResourcesByDigest(t *testing.T) {
+	digest1 := "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+	digest2 := "sha256:2222222222222222222222222222222222222222222222222222222222222222"
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"1.0", "2.0", "latest"},
+					Digests: map[string]string{
+						"1.0":    digest1,
+						"2.0":    digest2,
+						"latest": digest2,
+					},
+				},
+			},
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/busybox",
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/harbor",
+					},
+					Vtags: []string{"1.0", "2.0"},
+				},
+			},
+		}
+	}
+	// the tags of the digests are kept and the tag filter is ignored by the images,
+	// the resource whose digests are unknown is dropped
+	res, err := filterResources(newResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: "1.0",
+		},
+		{
+			Type:  model.FilterTypeDigest,
+			Value: []interface{}{digest2},
+			Scope: model.ResourceTypeImage,
+		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, "library/hello-world", res[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"2.0", "latest"}, res[0].Metadata.Vtags)
+	// the tag filter still applies to the charts
+	assert.Equal(t, "library/harbor", res[1].Metadata.Repository.Name)
+	assert.Equal(t, []string{"1.0"}, res[1].Metadata.Vtags)
+
+	// no tag has the digest
+	res, err = filterResources(newResources()[:1], []*model.Filter{
+		{
+			Type:  model.FilterTypeDigest,
+			Value: "sha256:3333333333333333333333333333333333333333333333333333333333333333",
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(res))
+
+	// invalid digest
+	_, err = filterResources(newResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeDigest,
+			Value: "abc",
+		},
+	})
+	require.NotNil(t, err)
+}
+
+func TestFetchResourcesWithDigestFilter(t *testing.T) {
+	adapter := &test.Adapter{}
+	tagFilter := &model.Filter{
+		Type:  model.FilterTypeTag,
+		Value: "v*",
+	}
+	policy := &model.Policy{
+		Filters: []*model.Filter{
+			tagFilter,
+			{
+				Type:  model.FilterTypeDigest,
+				Value: "sha256:1111111111111111111111111111111111111111111111111111111111111111",
+				Scope: model.ResourceTypeImage,
+			},
+		},
+	}
+	_, _, err := fetchResources(context.Background(), adapter, policy, nil)
+	require.Nil(t, err)
+	// the tag filter isn't passed to fetch the images pinned by the digests
+	assert.Equal(t, 0, len(adapter.Filters("FetchImages")))
+	assert.Equal(t, []*model.Filter{tagFilter}, adapter.Filters("FetchCharts"))
+}
+
+func TestFilterResourcesByRegex(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "team-alpha/service-1",
+				},
+				Vtags: []string{"v1.0", "v1.1-rc", "latest"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "team-gamma/service-2",
+				},
+				Vtags: []string{"v1.0"},
+			},
+		},
+	}
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: `^team-(alpha|beta)/service-\d+$`,
+			Mode:  model.FilterModeRegex,
+		},
+		{
+			Type:  model.FilterTypeTag,
+			Value: `^v\d+\.\d+$`,
+			Mode:  model.FilterModeRegex,
+		},
+	}
+	res, err := filterResources(resources, filters)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "team-alpha/service-1", res[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"v1.0"}, res[0].Metadata.Vtags)
+
+	// invalid regular expression
+	_, err = filterResources(resources, []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "team-(alpha",
+			Mode:  model.FilterModeRegex,
+		},
+	})
+	assert.NotNil(t, err)
+}
+
+func TestFilterResourcesBySemver(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"1.3.9", "1.4.0", "v1.5.2", "2.0.0-rc1", "2.0.0", "latest"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"latest", "stable"},
+			},
+		},
+	}
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: ">=1.4.0 <2.0.0",
+			Mode:  model.FilterModeSemver,
+		},
+	}
+	res, err := filterResources(resources, filters)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "library/hello-world", res[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"1.4.0", "v1.5.2", "2.0.0-rc1"}, res[0].Metadata.Vtags)
+
+	// invalid semver range
+	_, err = filterResources(resources, []*model.Filter{
+		{
+			Type:  model.FilterTypeTag,
+			Value: ">=1.x.y",
+			Mode:  model.FilterModeSemver,
+		},
+	})
+	assert.NotNil(t, err)
 }
 
-func TestFilterResources(t *testing.T) {
+func TestFilterResourcesWithExclusion(t *testing.T) {
 	resources := []*model.Resource{
 		{
 			Type: model.ResourceTypeImage,
@@ -216,64 +870,185 @@ func TestFilterResources(t *testing.T) {
 				Repository: &model.Repository{
 					Name: "library/hello-world",
 				},
+				Vtags: []string{"latest", "1.0-rc", "1.0"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
 				Vtags: []string{"latest"},
-				// TODO test labels
-				Labels: nil,
 			},
-			Deleted:  true,
-			Override: true,
 		},
 		{
-			Type: model.ResourceTypeChart,
+			Type: model.ResourceTypeImage,
 			Metadata: &model.ResourceMetadata{
 				Repository: &model.Repository{
-					Name: "library/harbor",
+					Name: "library/alpine",
 				},
-				Vtags: []string{"0.2.0", "0.3.0"},
-				// TODO test labels
-				Labels: nil,
+				Vtags: []string{"3.0-rc"},
 			},
-			Deleted:  true,
-			Override: true,
 		},
 		{
-			Type: model.ResourceTypeChart,
+			Type: model.ResourceTypeImage,
 			Metadata: &model.ResourceMetadata{
 				Repository: &model.Repository{
-					Name: "library/mysql",
+					Name: "test/nginx",
 				},
-				Vtags: []string{"1.0"},
-				// TODO test labels
-				Labels: nil,
+				Vtags: []string{"latest"},
 			},
-			Deleted:  true,
-			Override: true,
 		},
 	}
 	filters := []*model.Filter{
 		{
-			Type:  model.FilterTypeResource,
-			Value: model.ResourceTypeChart,
+			Type:       model.FilterTypeName,
+			Value:      "test/**",
+			Decoration: model.FilterDecorationExcludes,
 		},
 		{
-			Type:  model.FilterTypeName,
-			Value: "library/*",
+			Type:       model.FilterTypeName,
+			Value:      "library/busybox",
+			Decoration: model.FilterDecorationExcludes,
 		},
 		{
-			Type:  model.FilterTypeName,
-			Value: "library/harbor",
+			Type:       model.FilterTypeTag,
+			Value:      "*-rc",
+			Decoration: model.FilterDecorationExcludes,
+		},
+	}
+	trace := &FilterTrace{}
+	res, err := traceFilterResources(resources, filters, trace, nil)
+	require.Nil(t, err)
+	// the excluded tags are pruned and the resource whose tags are all excluded is removed
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "library/hello-world", res[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"latest", "1.0"}, res[0].Metadata.Vtags)
+	var rejected []string
+	for _, decision := range trace.Decisions {
+		if !decision.Accepted && len(decision.Tag) > 0 {
+			rejected = append(rejected, decision.Resource+":"+decision.Tag)
+		}
+	}
+	assert.Equal(t, []string{"library/hello-world:1.0-rc", "library/alpine:3.0-rc"}, rejected)
+
+	// the exclusion works together with the inclusion
+	res, err = filterResources([]*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"1.0", "1.0-rc", "latest"},
+			},
 		},
+	}, []*model.Filter{
 		{
 			Type:  model.FilterTypeTag,
-			Value: "0.2.?",
+			Value: "1.*",
+		},
+		{
+			Type:       model.FilterTypeTag,
+			Value:      "*-rc",
+			Decoration: model.FilterDecorationExcludes,
 		},
+	})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, []string{"1.0"}, res[0].Metadata.Vtags)
+}
+
+// returns the adapter whose tags reference the digests according to the "digests"
+// map which is keyed by "repository:tag", the manifest doesn't exist if not found
+func newDigestAdapter(digests map[string]string) *test.Adapter {
+	adapter := &test.Adapter{}
+	for reference, digest := range digests {
+		i := strings.LastIndex(reference, ":")
+		adapter.AddTag(reference[:i], reference[i+1:], digest)
 	}
-	res, err := filterResources(resources, filters)
+	return adapter
+}
+
+func TestFilterUnmodifiedResources(t *testing.T) {
+	srcAdapter := newDigestAdapter(map[string]string{
+		"library/hello-world:1.0": "sha256:1",
+		"library/hello-world:2.0": "sha256:2",
+		"library/hello-world:3.0": "sha256:3",
+		"library/busybox:latest":  "sha256:4",
+	})
+	dstAdapter := newDigestAdapter(map[string]string{
+		// same digest
+		"harbor/hello-world:1.0": "sha256:1",
+		// divergent digest
+		"harbor/hello-world:2.0": "sha256:0",
+		// "harbor/hello-world:3.0" doesn't exist
+		"harbor/busybox:latest": "sha256:4",
+	})
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/hello-world",
+					},
+					Vtags: []string{"1.0", "2.0", "3.0"},
+				},
+			},
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/busybox",
+					},
+					Vtags: []string{"latest"},
+				},
+			},
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/harbor",
+					},
+					Vtags: []string{"0.2.0"},
+				},
+			},
+		}
+	}
+	policy := &model.Policy{
+		DestNamespace: "harbor",
+	}
+
+	// the filter isn't enabled
+	srcResources := newResources()
+	dstResources := assembleDestinationResources(srcResources, policy)
+	src, dst, err := filterUnmodifiedResources(srcAdapter, dstAdapter, srcResources, dstResources, policy)
 	require.Nil(t, err)
-	assert.Equal(t, 1, len(res))
-	assert.Equal(t, "library/harbor", res[0].Metadata.Repository.Name)
-	assert.Equal(t, 1, len(res[0].Metadata.Vtags))
-	assert.Equal(t, "0.2.0", res[0].Metadata.Vtags[0])
+	assert.Equal(t, 3, len(src))
+	assert.Equal(t, 3, len(dst))
+
+	// the filter is enabled
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeModified,
+			Value: true,
+		},
+	}
+	srcResources = newResources()
+	dstResources = assembleDestinationResources(srcResources, policy)
+	src, dst, err = filterUnmodifiedResources(srcAdapter, dstAdapter, srcResources, dstResources, policy)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(src))
+	require.Equal(t, 2, len(dst))
+	assert.Equal(t, "library/hello-world", src[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"2.0", "3.0"}, src[0].Metadata.Vtags)
+	assert.Equal(t, "harbor/hello-world", dst[0].Metadata.Repository.Name)
+	assert.Equal(t, []string{"2.0", "3.0"}, dst[0].Metadata.Vtags)
+	// the chart is kept
+	assert.Equal(t, model.ResourceTypeChart, src[1].Type)
+	assert.Equal(t, "harbor/harbor", dst[1].Metadata.Repository.Name)
 }
 
 func TestAssembleSourceResources(t *testing.T) {
@@ -299,6 +1074,33 @@ func TestAssembleSourceResources(t *testing.T) {
 	assert.Equal(t, int64(1), res[0].Registry.ID)
 }
 
+func TestAssembleSourceResourcesWithDuplicateTags(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"2.0", "latest", "1.0", "latest", "2.0"},
+			},
+		},
+	}
+	res := assembleSourceResources(resources, &model.Policy{})
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, []string{"2.0", "latest", "1.0"}, res[0].Metadata.Vtags)
+	assert.Equal(t, "library/hello-world:[2.0,latest,1.0]", getResourceName(res[0]))
+
+	dst := assembleDestinationResources(res, &model.Policy{})
+	assert.Equal(t, []string{"2.0", "latest", "1.0"}, dst[0].Metadata.Vtags)
+}
+
+func TestDedupTags(t *testing.T) {
+	assert.Nil(t, dedupTags(nil))
+	assert.Equal(t, []string{"latest"}, dedupTags([]string{"latest"}))
+	assert.Equal(t, []string{"b", "a", "c"}, dedupTags([]string{"b", "a", "b", "c", "a"}))
+}
+
 func TestAssembleDestinationResources(t *testing.T) {
 	resources := []*model.Resource{
 		{
@@ -325,6 +1127,158 @@ func TestAssembleDestinationResources(t *testing.T) {
 	assert.Equal(t, "latest", res[0].Metadata.Vtags[0])
 }
 
+func TestAssembleDestinationResourcesWithNamespaceOverrides(t *testing.T) {
+	newResource := func(name string) *model.Resource {
+		return &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+				Vtags: []string{"latest"},
+			},
+		}
+	}
+	resources := []*model.Resource{
+		newResource("dev/app"),
+		newResource("release/app"),
+		newResource("test/app"),
+	}
+	policy := &model.Policy{
+		DestRegistry: &model.Registry{},
+		Override:     true,
+		NamespaceOverrides: map[string]bool{
+			"release": false,
+		},
+	}
+	// the protected namespace is never overridden
+	res := assembleDestinationResources(resources, policy)
+	require.Equal(t, 3, len(res))
+	assert.True(t, res[0].Override)
+	assert.False(t, res[1].Override)
+	assert.True(t, res[2].Override)
+
+	// the setting of the destination namespace rather than the source one is used
+	policy.Override = false
+	policy.DestNamespace = "release"
+	policy.NamespaceOverrides = map[string]bool{
+		"dev":     true,
+		"release": true,
+	}
+	res = assembleDestinationResources(resources[:1], policy)
+	require.Equal(t, 1, len(res))
+	assert.Equal(t, "release/app", res[0].Metadata.Repository.Name)
+	assert.True(t, res[0].Override)
+}
+
+func TestAssembleDestinationResourcesStripLibraryNamespace(t *testing.T) {
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/nginx",
+					},
+				},
+			},
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "bitnami/nginx",
+					},
+				},
+			},
+		}
+	}
+	policy := &model.Policy{
+		DestRegistry: &model.Registry{},
+	}
+
+	// strip disabled
+	res := assembleDestinationResources(newResources(), policy)
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, "library/nginx", res[0].Metadata.Repository.Name)
+	assert.Equal(t, "bitnami/nginx", res[1].Metadata.Repository.Name)
+
+	// strip enabled
+	policy.StripLibraryNamespace = true
+	res = assembleDestinationResources(newResources(), policy)
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, "nginx", res[0].Metadata.Repository.Name)
+	assert.Equal(t, "bitnami/nginx", res[1].Metadata.Repository.Name)
+
+	// strip enabled with the destination namespace
+	policy.DestNamespace = "mirror"
+	res = assembleDestinationResources(newResources(), policy)
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, "mirror/nginx", res[0].Metadata.Repository.Name)
+	assert.Equal(t, "mirror/nginx", res[1].Metadata.Repository.Name)
+}
+
+func TestAssembleDestinationResourcesNormalizeTags(t *testing.T) {
+	newResources := func() []*model.Resource {
+		return []*model.Resource{
+			{
+				Type: model.ResourceTypeImage,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/nginx",
+					},
+					Vtags: []string{"V1.0", "latest", "v1.0", "RC"},
+				},
+			},
+			{
+				Type: model.ResourceTypeChart,
+				Metadata: &model.ResourceMetadata{
+					Repository: &model.Repository{
+						Name: "library/harbor",
+					},
+					Vtags: []string{"1.0.0-RC"},
+				},
+			},
+		}
+	}
+	policy := &model.Policy{
+		DestRegistry: &model.Registry{},
+	}
+
+	// normalization disabled
+	src := newResources()
+	res := assembleDestinationResources(src, policy)
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, []string{"V1.0", "latest", "v1.0", "RC"}, res[0].Metadata.Vtags)
+
+	// lowercase, "v1.0" collides with "V1.0" and is dropped, the charts aren't normalized
+	policy.TagNormalization = model.TagNormalizationLowercase
+	src = newResources()
+	res = assembleDestinationResources(src, policy)
+	require.Equal(t, 2, len(res))
+	assert.Equal(t, []string{"V1.0", "latest", "RC"}, src[0].Metadata.Vtags)
+	assert.Equal(t, []string{"v1.0", "latest", "rc"}, res[0].Metadata.Vtags)
+	assert.Equal(t, []string{"1.0.0-RC"}, src[1].Metadata.Vtags)
+	assert.Equal(t, []string{"1.0.0-RC"}, res[1].Metadata.Vtags)
+}
+
+func TestNormalizeTags(t *testing.T) {
+	// empty
+	srcTags, dstTags := normalizeTags("library/nginx", nil, model.TagNormalizationLowercase)
+	assert.Equal(t, 0, len(srcTags))
+	assert.Equal(t, 0, len(dstTags))
+
+	// no collision
+	srcTags, dstTags = normalizeTags("library/nginx", []string{"Latest", "1.0"}, model.TagNormalizationLowercase)
+	assert.Equal(t, []string{"Latest", "1.0"}, srcTags)
+	assert.Equal(t, []string{"latest", "1.0"}, dstTags)
+
+	// collisions, only the first one is kept
+	srcTags, dstTags = normalizeTags("library/nginx", []string{"latest", "Latest", "LATEST", "1.0"},
+		model.TagNormalizationLowercase)
+	assert.Equal(t, []string{"latest", "1.0"}, srcTags)
+	assert.Equal(t, []string{"latest", "1.0"}, dstTags)
+}
+
 func TestPreprocess(t *testing.T) {
 	scheduler := &fakedScheduler{}
 	srcResources := []*model.Resource{
@@ -357,21 +1311,84 @@ func TestPreprocess(t *testing.T) {
 }
 
 func TestCreateTasks(t *testing.T) {
-	mgr := &fakedExecutionManager{}
+	mgr := newFakedExecutionManager()
 	items := []*scheduler.ScheduleItem{
 		{
 			SrcResource: &model.Resource{},
 			DstResource: &model.Resource{},
 		},
 	}
-	err := createTasks(mgr, 1, items)
+	items, err := createTasks(mgr, 1, items, 0)
 	require.Nil(t, err)
 	assert.Equal(t, int64(1), items[0].TaskID)
 }
 
+func TestCreateSkippedTasks(t *testing.T) {
+	mgr := newFakedExecutionManager()
+	// nothing to skip
+	n, err := createSkippedTasks(mgr, 1, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+	n, err = createSkippedTasks(mgr, 1, resources, resources)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, map[int64]string{
+		1: models.TaskStatusSkipped,
+		2: models.TaskStatusSkipped,
+	}, updatedTaskStatuses(mgr))
+}
+
+func TestGetDroppedResources(t *testing.T) {
+	newResource := func(name string) *model.Resource {
+		return &model.Resource{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+			},
+		}
+	}
+	src := []*model.Resource{newResource("library/a"), newResource("library/b"), newResource("library/c")}
+	dst := []*model.Resource{newResource("harbor/a"), newResource("harbor/b"), newResource("harbor/c")}
+	droppedSrc, droppedDst := getDroppedResources(src, dst, []*model.Resource{src[1]})
+	require.Equal(t, 2, len(droppedSrc))
+	require.Equal(t, 2, len(droppedDst))
+	assert.Equal(t, "library/a", droppedSrc[0].Metadata.Repository.Name)
+	assert.Equal(t, "harbor/a", droppedDst[0].Metadata.Repository.Name)
+	assert.Equal(t, "library/c", droppedSrc[1].Metadata.Repository.Name)
+	assert.Equal(t, "harbor/c", droppedDst[1].Metadata.Repository.Name)
+
+	// nothing is dropped
+	droppedSrc, droppedDst = getDroppedResources(src, dst, src)
+	assert.Equal(t, 0, len(droppedSrc))
+	assert.Equal(t, 0, len(droppedDst))
+}
+
 func TestSchedule(t *testing.T) {
 	sched := &fakedScheduler{}
-	mgr := &fakedExecutionManager{}
+	mgr := newFakedExecutionManager()
 	items := []*scheduler.ScheduleItem{
 		{
 			SrcResource: &model.Resource{},
@@ -379,7 +1396,7 @@ func TestSchedule(t *testing.T) {
 			TaskID:      1,
 		},
 	}
-	n, err := schedule(sched, mgr, items)
+	n, err := schedule(context.Background(), sched, mgr, items, nil, nil)
 	require.Nil(t, err)
 	assert.Equal(t, 1, n)
 }
@@ -400,9 +1417,202 @@ func TestReplaceNamespace(t *testing.T) {
 	namespace = "n"
 	result = replaceNamespace(repository, namespace)
 	assert.Equal(t, "n/c", result)
-	// repository contains more than one "/"
+	// repository contains more than one "/", only the leading segment is replaced
 	repository = "a/b/c"
 	namespace = "n"
 	result = replaceNamespace(repository, namespace)
-	assert.Equal(t, "n/c", result)
+	assert.Equal(t, "n/b/c", result)
+	// the namespace equals a later path segment
+	repository = "prod/prod-api"
+	namespace = "n"
+	result = replaceNamespace(repository, namespace)
+	assert.Equal(t, "n/prod-api", result)
+	repository = "prod/prod/prod"
+	namespace = "n"
+	result = replaceNamespace(repository, namespace)
+	assert.Equal(t, "n/prod/prod", result)
+	// the destination namespace equals a later path segment
+	repository = "dev/team/prod"
+	namespace = "prod"
+	result = replaceNamespace(repository, namespace)
+	assert.Equal(t, "prod/team/prod", result)
+}
+
+func TestMapNamespace(t *testing.T) {
+	mappings := []*model.NamespaceMapping{
+		{From: "library/base/*", To: "base/*"},
+		{From: "library/*", To: "mirror/library/*"},
+		{From: "lib/*", To: "l/*"},
+		{From: "team-a/*", To: "a/*"},
+		{From: "team-b/app", To: "b/application"},
+	}
+	cases := []struct {
+		repository string
+		expected   string
+	}{
+		// the first matched rule wins for the overlapping prefixes
+		{"library/base/alpine", "base/alpine"},
+		{"library/nginx", "mirror/library/nginx"},
+		// the prefix matches the whole namespace only
+		{"lib/nginx", "l/nginx"},
+		{"libraryx/nginx", "libraryx/nginx"},
+		// the namespace string in the rest of the name isn't replaced
+		{"library/library", "mirror/library/library"},
+		{"team-a/team-a/team-a", "a/team-a/team-a"},
+		// the exact match
+		{"team-b/app", "b/application"},
+		{"team-b/app2", "team-b/app2"},
+		// the unmatched repositories keep their names
+		{"library", "library"},
+		{"others/nginx", "others/nginx"},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, mapNamespace(c.repository, mappings), c.repository)
+	}
+}
+
+func TestAssembleDestinationResourcesWithNamespaceMappings(t *testing.T) {
+	resources := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/nginx",
+				},
+				Vtags: []string{"library", "library-1.0"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "team-a/app",
+				},
+				Vtags: []string{"team-a"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "others/app",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+	}
+	policy := &model.Policy{
+		DestRegistry: &model.Registry{},
+		NamespaceMappings: []*model.NamespaceMapping{
+			{From: "library/*", To: "mirror/library/*"},
+			{From: "team-a/*", To: "a/*"},
+		},
+	}
+	res := assembleDestinationResources(resources, policy)
+	require.Equal(t, 3, len(res))
+	assert.Equal(t, "mirror/library/nginx", res[0].Metadata.Repository.Name)
+	// the tags containing the namespace string are kept as they are
+	assert.Equal(t, []string{"library", "library-1.0"}, res[0].Metadata.Vtags)
+	assert.Equal(t, "a/app", res[1].Metadata.Repository.Name)
+	assert.Equal(t, []string{"team-a"}, res[1].Metadata.Vtags)
+	assert.Equal(t, "others/app", res[2].Metadata.Repository.Name)
+}
+
+func TestStripLibraryNamespace(t *testing.T) {
+	assert.Equal(t, "nginx", stripLibraryNamespace("library/nginx"))
+	assert.Equal(t, "nginx", stripLibraryNamespace("nginx"))
+	assert.Equal(t, "bitnami/nginx", stripLibraryNamespace("bitnami/nginx"))
+	assert.Equal(t, "b/c", stripLibraryNamespace("library/b/c"))
+}
+
+func TestParseResourceName(t *testing.T) {
+	newResource := func(tags ...string) *model.Resource {
+		return &model.Resource{
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: tags,
+			},
+		}
+	}
+	// the tags with the separators and the backslash
+	tags := []string{"build[1,2]", `a\b`, "", `c,\`}
+	name := getResourceName(newResource(tags...))
+	assert.Equal(t, `library/hello-world:[build\[1\,2\],a\\b,,c\,\\]`, name)
+	repository, parsed, err := parseResourceName(name)
+	require.Nil(t, err)
+	assert.Equal(t, "library/hello-world", repository)
+	assert.Equal(t, tags, parsed)
+
+	// the names generated before the escape was introduced
+	repository, parsed, err = parseResourceName("library/hello-world:[1.0,latest]")
+	require.Nil(t, err)
+	assert.Equal(t, "library/hello-world", repository)
+	assert.Equal(t, []string{"1.0", "latest"}, parsed)
+
+	// invalid names
+	for _, name := range []string{
+		"library/hello-world",
+		"library/hello-world:[a]b]",
+		`library/hello-world:[a\]`,
+		"library/hello-world:[1,2,3,4,5 ... 6 in total]",
+	} {
+		_, _, err = parseResourceName(name)
+		assert.NotNil(t, err, name)
+	}
+}
+
+func TestFilterResourcesDropped(t *testing.T) {
+	newImage := func(name string, tags ...string) *model.Resource {
+		return &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: name,
+				},
+				Vtags: tags,
+			},
+		}
+	}
+	resources := []*model.Resource{
+		newImage("library/hello-world", "1.0", "latest"),
+		newImage("library/busybox", "latest"),
+		newImage("test/hello-world", "1.0"),
+		newImage("test/busybox", "1.0"),
+		newImage("library/alpine", "1.1"),
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/harbor",
+				},
+				Vtags: []string{"1.0"},
+			},
+		},
+	}
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeResource,
+			Value: model.ResourceTypeImage,
+		},
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/**",
+		},
+		{
+			Type:  model.FilterTypeTag,
+			Value: "1.*",
+		},
+	}
+	dropped := map[model.FilterType]int{}
+	res, err := traceFilterResources(resources, filters, nil, dropped)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(res))
+	// the resource is counted by the first filter dropping it only
+	assert.Equal(t, map[model.FilterType]int{
+		model.FilterTypeResource: 1,
+		model.FilterTypeName:     2,
+		model.FilterTypeTag:      1,
+	}, dropped)
 }
@@ -0,0 +1,148 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+)
+
+// summary records the outcome of one run of the flow. As the tasks are run by
+// the jobservice asynchronously, the outcome of the tasks is the one of submitting,
+// the succeeded and failed ones are counted by the task status hook when they finish
+type summary struct {
+	startTime time.Time
+	// the count of resources fetched from the source registry
+	Fetched int
+	// the count of resources left after filtering
+	Filtered int
+	// the count of resources dropped by each type of the filters applied
+	// by "filterResources"
+	Dropped map[model.FilterType]int
+	// the count of tasks created, including the skipped ones
+	Created int
+	// the count of tasks submitted successfully
+	Submitted int
+	// the count of tasks failed to be submitted, including the ones
+	// failed to be validated by the pre-copy webhook
+	Failed int
+	// the count of tasks skipped intentionally: the resources aren't modified,
+	// or the tasks are denied by the pre-copy webhook or deferred by the byte budget
+	Skipped int
+	// the count of tasks recorded by the dry run, they would be submitted
+	// in a real execution
+	Previewed int
+	// the bytes of the submitted tasks estimated by the byte budget of the
+	// policy, nil as the bytes are unknown if the policy has no byte budget
+	EstimatedBytes *int64
+	// the elapsed time of the run
+	Elapsed time.Duration
+	// the estimate of the full run, only set by the sampled dry run
+	Estimate *estimate
+}
+
+func newSummary() *summary {
+	return &summary{
+		startTime: time.Now(),
+		Dropped:   map[model.FilterType]int{},
+	}
+}
+
+// merge the counts of the tasks of the other summary
+func (s *summary) merge(other *summary) {
+	s.Created += other.Created
+	s.Submitted += other.Submitted
+	s.Failed += other.Failed
+	s.Skipped += other.Skipped
+	s.Previewed += other.Previewed
+}
+
+// record the bytes of the submitted tasks estimated by the byte budget of the policy
+func (s *summary) estimateBytes(policy *model.Policy, bytes int64) {
+	if policy != nil && policy.MaxBytesPerExecution > 0 {
+		s.EstimatedBytes = &bytes
+	}
+}
+
+func (s *summary) String() string {
+	str := fmt.Sprintf("resources fetched: %d, filtered: %d, tasks created: %d, submitted: %d, failed to submit: %d, skipped: %d",
+		s.Fetched, s.Filtered, s.Created, s.Submitted, s.Failed, s.Skipped)
+	if s.EstimatedBytes != nil {
+		str = fmt.Sprintf("%s, estimated bytes: %d", str, *s.EstimatedBytes)
+	}
+	return fmt.Sprintf("%s, elapsed: %s", str, s.Elapsed)
+}
+
+// emit the summary into the log once the run ends and store it into the execution.
+// The outcome of the tasks is counted by the execution when they finish
+func (s *summary) emit(mgr execution.Manager, executionID int64, err error) {
+	s.Elapsed = time.Since(s.startTime).Round(time.Millisecond)
+	if err != nil {
+		log.Infof("the execution %d failed: %v, summary: %s", executionID, err, s)
+	} else {
+		log.Infof("the execution %d completed, summary: %s", executionID, s)
+	}
+	data, e := json.Marshal(&models.ExecutionSummary{
+		Fetched:        s.Fetched,
+		Filtered:       s.Filtered,
+		Created:        s.Created,
+		Submitted:      s.Submitted,
+		FailedToSubmit: s.Failed,
+		Skipped:        s.Skipped,
+		EstimatedBytes: s.EstimatedBytes,
+		Elapsed:        int64(s.Elapsed / time.Millisecond),
+	})
+	if e != nil {
+		log.Errorf("failed to marshal the summary of the execution %d: %v", executionID, e)
+		return
+	}
+	if e = mgr.Update(&models.Execution{
+		ID:          executionID,
+		SummaryText: string(data),
+	}, models.ExecutionPropsName.Summary); e != nil {
+		log.Errorf("failed to store the summary of the execution %d: %v", executionID, e)
+	}
+}
+
+// record the count of the resources dropped by each type of the filters into the execution,
+// it helps to diagnose the filters dropping more resources than expected. The failure of
+// recording is only logged as it doesn't affect the replication
+func recordFilterSummary(mgr execution.Manager, executionID int64, dropped map[model.FilterType]int) {
+	if len(dropped) == 0 {
+		return
+	}
+	summary := map[string]int{}
+	for filterType, count := range dropped {
+		summary[string(filterType)] = count
+	}
+	data, err := json.Marshal(summary)
+	if err != nil {
+		log.Errorf("failed to marshal the filter summary of the execution %d: %v", executionID, err)
+		return
+	}
+	log.Infof("the filters of the execution %d: %s", executionID, models.FormatFilterSummary(summary))
+	if err = mgr.Update(&models.Execution{
+		ID:                executionID,
+		FilterSummaryText: string(data),
+	}, models.ExecutionPropsName.FilterSummary); err != nil {
+		log.Errorf("failed to record the filter summary of the execution %d: %v", executionID, err)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getFakedExecution(t *testing.T, mgr *test.ExecutionManager) *models.Execution {
+	execution, err := mgr.Get(1)
+	require.Nil(t, err)
+	require.NotNil(t, execution)
+	return execution
+}
+
+func TestSummaryOfCopyFlow(t *testing.T) {
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+		},
+	}
+	// only the image is fetched as the resource filter is specified
+	sum := newSummary()
+	flow := NewCopyFlow(newFakedExecutionManager(), &fakedScheduler{}, 1, policy).(*copyFlow)
+	n, err := flow.run(context.Background(), sum)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, sum.Fetched)
+	assert.Equal(t, 1, sum.Filtered)
+	assert.Equal(t, 1, sum.Created)
+	assert.Equal(t, 1, sum.Submitted)
+	assert.Equal(t, 0, sum.Failed)
+	assert.Equal(t, 0, sum.Skipped)
+
+	// one of the tasks is failed to be submitted
+	policy.Filters = nil
+	sum = newSummary()
+	flow = NewCopyFlow(newFakedExecutionManager(), &fakedFailingScheduler{failFrom: 1}, 1, policy).(*copyFlow)
+	n, err = flow.run(context.Background(), sum)
+	require.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 2, sum.Fetched)
+	assert.Equal(t, 2, sum.Filtered)
+	assert.Equal(t, 2, sum.Created)
+	assert.Equal(t, 1, sum.Submitted)
+	assert.Equal(t, 1, sum.Failed)
+	assert.Equal(t, 0, sum.Skipped)
+}
+
+func TestSummaryOfCopyFlowSkippedAndFailed(t *testing.T) {
+	server := newPreCopyWebhookServer()
+	defer server.Close()
+
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		PreCopyWebhookURL: server.URL,
+	}
+	var resources []*model.Resource
+	for _, item := range newWebhookItems() {
+		resources = append(resources, item.SrcResource)
+	}
+	mgr := newFakedExecutionManager()
+	sum := newSummary()
+	flow := NewCopyFlow(mgr, &fakedScheduler{}, 1, policy, resources...).(*copyFlow)
+	n, err := flow.run(context.Background(), sum)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 3, sum.Created)
+	assert.Equal(t, 1, sum.Submitted)
+	// the denied task is skipped while the one failed by the webhook is failure
+	assert.Equal(t, 1, sum.Skipped)
+	assert.Equal(t, 1, sum.Failed)
+	assert.Equal(t, models.TaskStatusDenied, updatedTaskStatuses(mgr)[2])
+	assert.Equal(t, models.TaskStatusFailed, updatedTaskStatuses(mgr)[3])
+}
+
+// returns the summary stored into the execution 1
+func getStoredSummary(t *testing.T, mgr *test.ExecutionManager) *models.ExecutionSummary {
+	summary := &models.ExecutionSummary{}
+	require.Nil(t, json.Unmarshal([]byte(getFakedExecution(t, mgr).SummaryText), summary))
+	return summary
+}
+
+func TestEmitSummary(t *testing.T) {
+	// the summary is stored even if the run fails, the status text is kept as it is
+	mgr := newFakedExecutionManager()
+	sum := newSummary()
+	sum.Fetched = 2
+	sum.emit(mgr, 1, errors.New("error"))
+	assert.Equal(t, "", getFakedExecution(t, mgr).StatusText)
+	assert.Equal(t, 2, getStoredSummary(t, mgr).Fetched)
+
+	// the bytes are unknown without the byte budget
+	sum = &summary{
+		Fetched:   3,
+		Filtered:  2,
+		Created:   2,
+		Submitted: 1,
+		Skipped:   1,
+	}
+	sum.estimateBytes(&model.Policy{}, 0)
+	sum.emit(mgr, 1, nil)
+	assert.Equal(t, "resources fetched: 3, filtered: 2, tasks created: 2, submitted: 1, failed to submit: 0, skipped: 1, elapsed: "+
+		sum.Elapsed.String(), sum.String())
+	assert.Equal(t, &models.ExecutionSummary{
+		Fetched:   3,
+		Filtered:  2,
+		Created:   2,
+		Submitted: 1,
+		Skipped:   1,
+		Elapsed:   int64(sum.Elapsed / time.Millisecond),
+	}, getStoredSummary(t, mgr))
+	assert.NotContains(t, getFakedExecution(t, mgr).SummaryText, "estimated_bytes")
+
+	// the bytes are estimated by the byte budget
+	sum.estimateBytes(&model.Policy{MaxBytesPerExecution: 2048}, 1024)
+	sum.emit(mgr, 1, nil)
+	assert.Equal(t, "resources fetched: 3, filtered: 2, tasks created: 2, submitted: 1, failed to submit: 0, skipped: 1, estimated bytes: 1024, elapsed: "+
+		sum.Elapsed.String(), sum.String())
+	bytes := getStoredSummary(t, mgr).EstimatedBytes
+	require.NotNil(t, bytes)
+	assert.Equal(t, int64(1024), *bytes)
+}
+
+func TestRecordFilterSummary(t *testing.T) {
+	// nothing is recorded if no resource is dropped
+	mgr := newFakedExecutionManager()
+	recordFilterSummary(mgr, 1, map[model.FilterType]int{})
+	assert.Equal(t, "", getFakedExecution(t, mgr).FilterSummaryText)
+
+	recordFilterSummary(mgr, 1, map[model.FilterType]int{
+		model.FilterTypeName: 120,
+		model.FilterTypeTag:  45,
+	})
+	summary := map[string]int{}
+	require.Nil(t, json.Unmarshal([]byte(getFakedExecution(t, mgr).FilterSummaryText), &summary))
+	assert.Equal(t, "name filter dropped 120, tag filter dropped 45", models.FormatFilterSummary(summary))
+}
+
+func TestFilterSummaryOfCopyFlow(t *testing.T) {
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+		},
+	}
+	// the chart is dropped by the resource filter
+	sum := newSummary()
+	flow := NewCopyFlow(newFakedExecutionManager(), &fakedScheduler{}, 1, policy).(*copyFlow)
+	flow.resources = []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/harbor",
+				},
+				Vtags: []string{"1.0"},
+			},
+		},
+	}
+	_, err := flow.run(context.Background(), sum)
+	require.Nil(t, err)
+	assert.Equal(t, map[model.FilterType]int{model.FilterTypeResource: 1}, sum.Dropped)
+}
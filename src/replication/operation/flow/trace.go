@@ -0,0 +1,93 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// FilterDecision is the decision made by one filter for the resource or one tag
+// of it. The decision without the tag is made for the whole resource
+type FilterDecision struct {
+	Resource string           `json:"resource"`
+	Tag      string           `json:"tag,omitempty"`
+	Filter   model.FilterType `json:"filter"`
+	Value    interface{}      `json:"value"`
+	Accepted bool             `json:"accepted"`
+	Reason   string           `json:"reason"`
+}
+
+// FilterTrace records the decisions made by the filters in order, it explains why
+// a resource or tag is replicated or not. All the methods are no-op on a nil trace,
+// so tracing costs nothing when it's off
+type FilterTrace struct {
+	Decisions []*FilterDecision `json:"decisions"`
+}
+
+// Explain returns the decisions made for the resource, including the ones for its tags
+func (f *FilterTrace) Explain(resource string) []*FilterDecision {
+	if f == nil {
+		return nil
+	}
+	var decisions []*FilterDecision
+	for _, decision := range f.Decisions {
+		if decision.Resource == resource {
+			decisions = append(decisions, decision)
+		}
+	}
+	return decisions
+}
+
+func (f *FilterTrace) accept(resource, tag string, filter *model.Filter, format string, args ...interface{}) {
+	f.record(resource, tag, filter, true, format, args...)
+}
+
+func (f *FilterTrace) reject(resource, tag string, filter *model.Filter, format string, args ...interface{}) {
+	f.record(resource, tag, filter, false, format, args...)
+}
+
+func (f *FilterTrace) record(resource, tag string, filter *model.Filter, accepted bool,
+	format string, args ...interface{}) {
+	if f == nil {
+		return
+	}
+	f.Decisions = append(f.Decisions, &FilterDecision{
+		Resource: resource,
+		Tag:      tag,
+		Filter:   filter.Type,
+		Value:    filter.Value,
+		Accepted: accepted,
+		Reason:   fmt.Sprintf(format, args...),
+	})
+}
+
+// log the decisions of the execution one per line
+func (f *FilterTrace) emit(executionID int64) {
+	if f == nil {
+		return
+	}
+	for _, decision := range f.Decisions {
+		data, err := json.Marshal(decision)
+		if err != nil {
+			log.Errorf("failed to encode the filter decision: %v", err)
+			continue
+		}
+		log.Infof("the filter decision of the execution %d: %s", executionID, string(data))
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTraceResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"1.0", "dev"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "team/app",
+				},
+				Vtags: []string{"1.0"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"dev"},
+			},
+		},
+	}
+}
+
+func TestTraceFilterResources(t *testing.T) {
+	filters := []*model.Filter{
+		{
+			Type:  model.FilterTypeName,
+			Value: "library/*",
+		},
+		{
+			Type:  model.FilterTypeTag,
+			Value: "1.*",
+		},
+	}
+	trace := &FilterTrace{}
+	resources, err := traceFilterResources(newTraceResources(), filters, trace, nil)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(resources))
+	assert.Equal(t, []string{"1.0"}, resources[0].Metadata.Vtags)
+
+	// the repository name is rejected
+	decisions := trace.Explain("team/app")
+	require.Equal(t, 1, len(decisions))
+	assert.False(t, decisions[0].Accepted)
+	assert.Equal(t, model.FilterTypeName, decisions[0].Filter)
+	assert.Equal(t, "the repository name doesn't match the pattern library/*", decisions[0].Reason)
+
+	// no tag is accepted
+	decisions = trace.Explain("library/busybox")
+	require.Equal(t, 3, len(decisions))
+	assert.True(t, decisions[0].Accepted)
+	assert.Equal(t, "dev", decisions[1].Tag)
+	assert.False(t, decisions[1].Accepted)
+	assert.Equal(t, "the tag doesn't match the pattern 1.*", decisions[1].Reason)
+	assert.Equal(t, "", decisions[2].Tag)
+	assert.False(t, decisions[2].Accepted)
+	assert.Equal(t, "no tag matches the pattern 1.*", decisions[2].Reason)
+
+	// one of the tags is dropped
+	decisions = trace.Explain("library/hello-world")
+	require.Equal(t, 3, len(decisions))
+	assert.Equal(t, "1.0", decisions[1].Tag)
+	assert.True(t, decisions[1].Accepted)
+	assert.Equal(t, "dev", decisions[2].Tag)
+	assert.False(t, decisions[2].Accepted)
+
+	// the results are the same without tracing
+	untraced, err := filterResources(newTraceResources(), filters)
+	require.Nil(t, err)
+	assert.Equal(t, resources, untraced)
+}
+
+func TestTraceLatestPatchFilter(t *testing.T) {
+	resources := newTraceResources()
+	resources[0].Metadata.Vtags = []string{"1.0.1", "1.0.2"}
+	trace := &FilterTrace{}
+	_, err := traceFilterResources(resources[:1], []*model.Filter{
+		{
+			Type:  model.FilterTypeLatestPatch,
+			Value: false,
+		},
+	}, trace, nil)
+	require.Nil(t, err)
+	decisions := trace.Explain("library/hello-world")
+	require.Equal(t, 2, len(decisions))
+	assert.Equal(t, "1.0.1", decisions[0].Tag)
+	assert.False(t, decisions[0].Accepted)
+	assert.Equal(t, "the tag isn't the latest patch of its minor version", decisions[0].Reason)
+	assert.True(t, decisions[1].Accepted)
+}
+
+func TestNilFilterTrace(t *testing.T) {
+	var trace *FilterTrace
+	trace.reject("library/hello-world", "", &model.Filter{Type: model.FilterTypeName}, "rejected")
+	assert.Nil(t, trace.Explain("library/hello-world"))
+	trace.emit(1)
+}
+
+func TestBuildPlanWithFilterTrace(t *testing.T) {
+	policy := &model.Policy{
+		ID: 1,
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		Filters: []*model.Filter{
+			{
+				Type:  model.FilterTypeResource,
+				Value: model.ResourceTypeImage,
+			},
+		},
+	}
+	// off by default
+	plan, err := BuildPlan(&fakedScheduler{}, policy)
+	require.Nil(t, err)
+	assert.Nil(t, plan.FilterDecisions)
+
+	policy.TraceFilters = true
+	plan, err = BuildPlan(&fakedScheduler{}, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(plan.FilterDecisions))
+	assert.True(t, plan.FilterDecisions[0].Accepted)
+	assert.Equal(t, "library/hello-world", plan.FilterDecisions[0].Resource)
+}
@@ -0,0 +1,165 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+// expire the tags under the destination repositories of the image resources which are
+// pushed to the destination registry earlier than the TTL of the policy, regardless of
+// whether they're still present at the source. Returns the resources with the expired
+// tags excluded from the copy and the items deleting the expired tags, only the
+// destination registry is touched by the deletion
+func expireDestinationTags(dstAdapter adp.Adapter, srcResources, dstResources []*model.Resource,
+	policy *model.Policy) ([]*model.Resource, []*model.Resource, []*scheduler.ScheduleItem, error) {
+	if policy.DestinationTagTTL <= 0 {
+		return srcResources, dstResources, nil, nil
+	}
+	lister, ok := dstAdapter.(adp.TagCreationTimeLister)
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("the destination adapter doesn't support listing the push time of tags, cannot apply the tag TTL")
+	}
+	ttl := time.Duration(policy.DestinationTagTTL) * time.Second
+	now := time.Now()
+	// the expired tags indexed by the destination repository
+	expired := map[string]map[string]struct{}{}
+	var items []*scheduler.ScheduleItem
+	var srcResult, dstResult []*model.Resource
+	for i, srcResource := range srcResources {
+		dstResource := dstResources[i]
+		if dstResource.Type != model.ResourceTypeImage || dstResource.Deleted {
+			srcResult = append(srcResult, srcResource)
+			dstResult = append(dstResult, dstResource)
+			continue
+		}
+		repository := dstResource.Metadata.Repository.Name
+		tags, exist := expired[repository]
+		if !exist {
+			times, err := lister.ListTagCreationTimes(repository)
+			if err != nil && !isNotFoundError(err) {
+				return nil, nil, nil, fmt.Errorf("failed to list the creation time of tags under %s: %v", repository, err)
+			}
+			tags = map[string]struct{}{}
+			var names []string
+			for tag, pushTime := range times {
+				if now.Sub(pushTime) > ttl {
+					tags[tag] = struct{}{}
+					names = append(names, tag)
+				}
+			}
+			expired[repository] = tags
+			if len(names) > 0 {
+				sort.Strings(names)
+				log.Debugf("the tags %v of %s are expired after the TTL %v", names, repository, ttl)
+				items = append(items, newDeletionItem(dstResource, names, policy))
+			}
+		}
+		// the expired tags aren't copied again by this execution
+		var srcTags, dstTags []string
+		for j, tag := range dstResource.Metadata.Vtags {
+			if _, exist := tags[tag]; exist {
+				continue
+			}
+			srcTags = append(srcTags, srcResource.Metadata.Vtags[j])
+			dstTags = append(dstTags, tag)
+		}
+		if len(dstResource.Metadata.Vtags) > 0 && len(dstTags) == 0 {
+			continue
+		}
+		if len(dstTags) != len(dstResource.Metadata.Vtags) {
+			srcResource.Metadata.Vtags = srcTags
+			dstMetadata := *dstResource.Metadata
+			dstMetadata.Vtags = dstTags
+			dstResource.Metadata = &dstMetadata
+		}
+		srcResult = append(srcResult, srcResource)
+		dstResult = append(dstResult, dstResource)
+	}
+	return srcResult, dstResult, items, nil
+}
+
+// the item deleting the tags(e.g. the expired ones) of the destination repository. The
+// source resource only names what is deleted, it isn't touched by the deletion
+func newDeletionItem(dstResource *model.Resource, tags []string, policy *model.Policy) *scheduler.ScheduleItem {
+	newResource := func(registry *model.Registry) *model.Resource {
+		return &model.Resource{
+			Type: dstResource.Type,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: dstResource.Metadata.Repository.Name,
+				},
+				Vtags: tags,
+			},
+			Registry: registry,
+			Deleted:  true,
+		}
+	}
+	return &scheduler.ScheduleItem{
+		SrcResource: newResource(policy.SrcRegistry),
+		DstResource: newResource(policy.DestRegistry),
+	}
+}
+
+// the repository doesn't exist on the destination registry yet
+func isNotFoundError(err error) bool {
+	e, ok := err.(*common_http.Error)
+	return ok && e.Code == http.StatusNotFound
+}
+
+// create and submit the tasks deleting the tags from the destination registry(e.g. the
+// expired ones). Failing to submit them doesn't fail the execution, the copy goes on
+// and the tags are deleted next time, but the cancellation of the context stops the execution
+func scheduleDeletions(ctx context.Context, sched scheduler.Scheduler, executionMgr execution.Manager,
+	executionID int64, items []*scheduler.ScheduleItem, policy *model.Policy) (*summary, error) {
+	sum := &summary{}
+	if len(items) == 0 {
+		return sum, nil
+	}
+	items, err := createTasks(executionMgr, executionID, items, policy.TagsPerTask)
+	if err != nil {
+		return nil, err
+	}
+	sum.Created = len(items)
+	if _, err := schedule(ctx, sched, executionMgr, items, policy, sum); err != nil {
+		if IsCancelled(err) {
+			return nil, err
+		}
+		sum.Failed = sum.Created - sum.Submitted
+		log.Errorf("failed to schedule the tasks deleting the destination tags for the execution %d: %v", executionID, err)
+	}
+	return sum, nil
+}
+
+// record the tasks deleting the destination tags for the dry run rather than submitting them
+func previewDeletions(executionMgr execution.Manager, executionID int64,
+	items []*scheduler.ScheduleItem, policy *model.Policy) (*summary, error) {
+	n, err := createDryRunTasks(executionMgr, executionID, items, policy.TagsPerTask)
+	if err != nil {
+		return nil, err
+	}
+	return &summary{Created: n, Previewed: n}, nil
+}
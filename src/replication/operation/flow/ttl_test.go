@@ -0,0 +1,136 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the destination repository "library/hello-world" has the fresh tag "latest" and
+// the tags "old" and "dst-only" pushed two days ago, other repositories don't exist
+func newExpiryAdapter() *fakedTagTimeAdapter {
+	adapter := &fakedTagTimeAdapter{
+		times: map[string]map[string]time.Time{
+			"library/hello-world": {
+				"latest":   time.Now().Add(-time.Minute),
+				"old":      time.Now().Add(-48 * time.Hour),
+				"dst-only": time.Now().Add(-48 * time.Hour),
+			},
+		},
+	}
+	adapter.Images = newFakedAdapter().Images
+	adapter.Charts = newFakedAdapter().Charts
+	return adapter
+}
+
+func newExpiryResources() ([]*model.Resource, []*model.Resource) {
+	src := []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest", "old"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"1.0"},
+			},
+		},
+	}
+	return src, assembleDestinationResources(src, &model.Policy{})
+}
+
+func TestExpireDestinationTags(t *testing.T) {
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: "faked-expiry",
+		},
+	}
+
+	// no TTL
+	src, dst := newExpiryResources()
+	srcResult, dstResult, items, err := expireDestinationTags(newExpiryAdapter(), src, dst, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(srcResult))
+	assert.Equal(t, 2, len(dstResult))
+	assert.Equal(t, 0, len(items))
+
+	// the tags older than the TTL are deleted from the destination registry, including the
+	// one still present at the source and the destination-only one, the fresh one is kept
+	policy.DestinationTagTTL = 24 * 3600
+	src, dst = newExpiryResources()
+	srcResult, dstResult, items, err = expireDestinationTags(newExpiryAdapter(), src, dst, policy)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+	assert.True(t, items[0].DstResource.Deleted)
+	assert.Equal(t, policy.DestRegistry, items[0].DstResource.Registry)
+	assert.Equal(t, "library/hello-world:[dst-only,old]", getResourceName(items[0].DstResource))
+	// the expired tag isn't copied again
+	require.Equal(t, 2, len(srcResult))
+	require.Equal(t, 2, len(dstResult))
+	assert.Equal(t, []string{"latest"}, srcResult[0].Metadata.Vtags)
+	assert.Equal(t, []string{"latest"}, dstResult[0].Metadata.Vtags)
+	assert.Equal(t, []string{"1.0"}, srcResult[1].Metadata.Vtags)
+
+	// the adapter cannot list the push time of tags
+	src, dst = newExpiryResources()
+	_, _, _, err = expireDestinationTags(newFakedAdapter(), src, dst, policy)
+	assert.NotNil(t, err)
+}
+
+func TestRunOfCopyFlowWithDestinationTagTTL(t *testing.T) {
+	require.Nil(t, adapter.RegisterFactory("faked-expiry", func(*model.Registry) (adapter.Adapter, error) {
+		return newExpiryAdapter(), nil
+	}))
+	sched := &fakedRecordingScheduler{}
+	policy := &model.Policy{
+		SrcRegistry: &model.Registry{
+			Type: model.RegistryTypeHarbor,
+		},
+		DestRegistry: &model.Registry{
+			Type: "faked-expiry",
+		},
+		DestinationTagTTL: 24 * 3600,
+	}
+	n, err := NewCopyFlow(newFakedExecutionManager(), sched, 1, policy).Run(nil)
+	require.Nil(t, err)
+	assert.Equal(t, 3, n)
+	require.Equal(t, 3, len(sched.items))
+
+	// the deletion only touches the destination registry
+	deletion := sched.items[0]
+	assert.True(t, deletion.DstResource.Deleted)
+	assert.Equal(t, model.RegistryType("faked-expiry"), deletion.DstResource.Registry.Type)
+	assert.Equal(t, "library/hello-world:[dst-only,old]", getResourceName(deletion.DstResource))
+	for _, item := range sched.items[1:] {
+		assert.False(t, item.DstResource.Deleted)
+	}
+}
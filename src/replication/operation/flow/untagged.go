@@ -0,0 +1,60 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	adp "github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// append the digests of the untagged manifests into the "Vtags" of the image resources
+// if the policy includes the untagged manifests, so they are replicated by digest as the
+// tags. The untagged manifests aren't affected by the tag filters
+func appendUntaggedManifests(srcAdapter adp.Adapter, resources []*model.Resource,
+	policy *model.Policy) ([]*model.Resource, error) {
+	if !policy.IncludeUntagged {
+		return resources, nil
+	}
+	lister, ok := srcAdapter.(adp.UntaggedManifestLister)
+	if !ok {
+		return nil, fmt.Errorf("the source adapter doesn't support listing the untagged manifests")
+	}
+	for _, resource := range resources {
+		if resource.Type != model.ResourceTypeImage || resource.Deleted {
+			continue
+		}
+		repository := resource.Metadata.Repository.Name
+		digests, err := lister.ListUntaggedManifests(repository)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the untagged manifests under %s: %v", repository, err)
+		}
+		existing := map[string]struct{}{}
+		for _, tag := range resource.Metadata.Vtags {
+			existing[tag] = struct{}{}
+		}
+		for _, digest := range digests {
+			if _, exist := existing[digest]; exist {
+				continue
+			}
+			existing[digest] = struct{}{}
+			resource.Metadata.Vtags = append(resource.Metadata.Vtags, digest)
+		}
+		log.Debugf("%d untagged manifests found under %s", len(digests), repository)
+	}
+	return resources, nil
+}
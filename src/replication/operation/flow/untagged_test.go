@@ -0,0 +1,101 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the repository "library/hello-world" contains one untagged manifest
+type fakedUntaggedAdapter struct {
+	test.Adapter
+}
+
+func (f *fakedUntaggedAdapter) ListUntaggedManifests(repository string) ([]string, error) {
+	if repository == "library/hello-world" {
+		return []string{"sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"}, nil
+	}
+	return nil, nil
+}
+
+func newUntaggedResources() []*model.Resource {
+	return []*model.Resource{
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+		{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/busybox",
+				},
+				Vtags: []string{"latest"},
+			},
+		},
+		{
+			Type: model.ResourceTypeChart,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: "library/hello-world",
+				},
+				Vtags: []string{"1.0.0"},
+			},
+		},
+	}
+}
+
+func TestAppendUntaggedManifests(t *testing.T) {
+	adapter := &fakedUntaggedAdapter{}
+
+	// exclude mode
+	resources, err := appendUntaggedManifests(adapter, newUntaggedResources(), &model.Policy{})
+	require.Nil(t, err)
+	require.Equal(t, 3, len(resources))
+	assert.Equal(t, []string{"latest"}, resources[0].Metadata.Vtags)
+
+	// include mode, the untagged manifest is replicated by digest
+	policy := &model.Policy{
+		IncludeUntagged: true,
+	}
+	resources, err = appendUntaggedManifests(adapter, newUntaggedResources(), policy)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(resources))
+	assert.Equal(t, []string{"latest", "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"},
+		resources[0].Metadata.Vtags)
+	assert.Equal(t, []string{"latest"}, resources[1].Metadata.Vtags)
+	assert.Equal(t, []string{"1.0.0"}, resources[2].Metadata.Vtags)
+
+	// the digest already listed isn't appended again
+	resources = newUntaggedResources()
+	resources[0].Metadata.Vtags = []string{"sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"}
+	resources, err = appendUntaggedManifests(adapter, resources, policy)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(resources[0].Metadata.Vtags))
+
+	// the adapter cannot list the untagged manifests
+	_, err = appendUntaggedManifests(newFakedAdapter(), newUntaggedResources(), policy)
+	assert.NotNil(t, err)
+}
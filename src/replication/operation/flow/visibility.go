@@ -0,0 +1,67 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"fmt"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// keep only the resources whose repositories have the visibility specified by the
+// "visibility" filter. The visibility is supplied by the adapters via the "ExtendedInfo"
+// of the resources, the ones whose visibility is unknown are kept only when the
+// "IncludeUnknownVisibility" of the policy is set
+func filterByVisibility(resources []*model.Resource, policy *model.Policy) ([]*model.Resource, error) {
+	var visibilityFilter *model.Filter
+	var visibility string
+	for _, filter := range policy.Filters {
+		if filter.Type != model.FilterTypeVisibility {
+			continue
+		}
+		value, ok := filter.Value.(string)
+		if !ok || (value != model.VisibilityPublic && value != model.VisibilityPrivate) {
+			return nil, fmt.Errorf("%v is not a valid visibility", filter.Value)
+		}
+		visibilityFilter, visibility = filter, value
+		break
+	}
+	if visibilityFilter == nil {
+		return resources, nil
+	}
+	var result []*model.Resource
+	for _, resource := range resources {
+		// the filter scoped to other resource types is ignored
+		if !visibilityFilter.AppliesTo(resource.Type) {
+			result = append(result, resource)
+			continue
+		}
+		public, known := resource.IsPublic()
+		if !known {
+			if policy.IncludeUnknownVisibility {
+				result = append(result, resource)
+			} else {
+				log.Debugf("the visibility of %s is unknown, skip", getResourceName(resource))
+			}
+			continue
+		}
+		if public == (visibility == model.VisibilityPublic) {
+			result = append(result, resource)
+		}
+	}
+	log.Debug("filter resources by visibility completed")
+	return result, nil
+}
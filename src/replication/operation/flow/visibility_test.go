@@ -0,0 +1,113 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newVisibilityResource(name string, resourceType model.ResourceType, public interface{}) *model.Resource {
+	resource := &model.Resource{
+		Type: resourceType,
+		Metadata: &model.ResourceMetadata{
+			Repository: &model.Repository{
+				Name: name,
+			},
+			Vtags: []string{"latest"},
+		},
+	}
+	if public != nil {
+		resource.ExtendedInfo = map[string]interface{}{
+			model.ExtendedInfoPublic: public,
+		}
+	}
+	return resource
+}
+
+func newVisibilityResources() []*model.Resource {
+	return []*model.Resource{
+		newVisibilityResource("library/hello-world", model.ResourceTypeImage, true),
+		newVisibilityResource("team/app", model.ResourceTypeImage, false),
+		newVisibilityResource("library/harbor", model.ResourceTypeChart, true),
+		newVisibilityResource("unknown/app", model.ResourceTypeImage, nil),
+	}
+}
+
+func getResourceNames(resources []*model.Resource) []string {
+	var names []string
+	for _, resource := range resources {
+		names = append(names, resource.Metadata.Repository.Name)
+	}
+	return names
+}
+
+func TestFilterByVisibility(t *testing.T) {
+	// no visibility filter
+	policy := &model.Policy{}
+	resources, err := filterByVisibility(newVisibilityResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(resources))
+
+	// public only
+	policy.Filters = []*model.Filter{
+		{
+			Type:  model.FilterTypeVisibility,
+			Value: model.VisibilityPublic,
+		},
+	}
+	resources, err = filterByVisibility(newVisibilityResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"library/hello-world", "library/harbor"}, getResourceNames(resources))
+
+	// private only
+	policy.Filters[0].Value = model.VisibilityPrivate
+	resources, err = filterByVisibility(newVisibilityResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"team/app"}, getResourceNames(resources))
+
+	// include the resources whose visibility is unknown
+	policy.IncludeUnknownVisibility = true
+	resources, err = filterByVisibility(newVisibilityResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"team/app", "unknown/app"}, getResourceNames(resources))
+
+	// the filter is scoped to images
+	policy.IncludeUnknownVisibility = false
+	policy.Filters[0].Scope = model.ResourceTypeImage
+	resources, err = filterByVisibility(newVisibilityResources(), policy)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"team/app", "library/harbor"}, getResourceNames(resources))
+
+	// invalid visibility
+	policy.Filters[0].Value = "internal"
+	_, err = filterByVisibility(newVisibilityResources(), policy)
+	assert.NotNil(t, err)
+}
+
+func TestFilterResourcesWithVisibilityFilter(t *testing.T) {
+	// the visibility filter is ignored by "filterResources"
+	resources, err := filterResources(newVisibilityResources(), []*model.Filter{
+		{
+			Type:  model.FilterTypeVisibility,
+			Value: model.VisibilityPublic,
+		},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(resources))
+}
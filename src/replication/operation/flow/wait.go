@@ -0,0 +1,126 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+)
+
+var (
+	// the interval of polling the status of the tasks
+	waitInterval = 5 * time.Second
+	// the page size used to list the tasks
+	waitPageSize int64 = 100
+)
+
+// Result is the aggregate result of the tasks of one execution
+type Result struct {
+	// the status of the execution: "Succeed", "Failed", "Stopped", "PartialSuccess"
+	// or "InProgress" if the tasks aren't finished before the deadline
+	Status      string
+	Total       int
+	Succeed     int
+	Failed      int
+	Stopped     int
+	Skipped     int
+	FetchFailed int
+	InProgress  int
+}
+
+// RunAndWait runs the flow and then blocks until all the tasks of the execution
+// reach the terminal states or the context is done. The context cancels the flow
+// as well. When the context is done before the tasks finish, the result got by the
+// last polling is returned along with the error of the context
+func RunAndWait(ctx context.Context, flow Flow, executionMgr execution.Manager,
+	executionID int64) (*Result, error) {
+	if _, err := flow.Run(ctx); err != nil {
+		return nil, err
+	}
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+	for {
+		result, err := getResult(executionMgr, executionID)
+		if err != nil {
+			return nil, err
+		}
+		if result.Status != models.ExecutionStatusInProgress {
+			log.Debugf("all tasks of the execution %d finished: %s", executionID, result.Status)
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// list all tasks of the execution and aggregate their statuses
+func getResult(executionMgr execution.Manager, executionID int64) (*Result, error) {
+	result := &Result{}
+	for page := int64(1); ; page++ {
+		total, tasks, err := executionMgr.ListTasks(&models.TaskQuery{
+			ExecutionID: executionID,
+			Pagination: models.Pagination{
+				Page: page,
+				Size: waitPageSize,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, task := range tasks {
+			if models.IsTaskSkipped(task.Status) {
+				result.Skipped++
+				continue
+			}
+			switch task.Status {
+			case models.TaskStatusSucceed:
+				result.Succeed++
+			case models.TaskStatusFailed:
+				result.Failed++
+			case models.TaskStatusStopped:
+				result.Stopped++
+			case models.TaskStatusFetchFailed:
+				result.FetchFailed++
+			default:
+				result.InProgress++
+			}
+		}
+		result.Total += len(tasks)
+		if len(tasks) == 0 || int64(result.Total) >= total {
+			break
+		}
+	}
+
+	switch {
+	case result.InProgress > 0:
+		result.Status = models.ExecutionStatusInProgress
+	case result.Failed > 0:
+		result.Status = models.ExecutionStatusFailed
+	case result.Stopped > 0:
+		result.Status = models.ExecutionStatusStopped
+	case result.FetchFailed > 0:
+		result.Status = models.ExecutionStatusPartialSuccess
+	default:
+		result.Status = models.ExecutionStatusSucceed
+	}
+	return result, nil
+}
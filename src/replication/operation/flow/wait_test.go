@@ -0,0 +1,149 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/replication/adapter/test"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the tasks of the execution 1 move to the next status every time when they are listed
+type fakedTransitionExecutionManager struct {
+	*test.ExecutionManager
+	transitions [][]string
+	listed      int
+}
+
+func newTransitionExecutionManager(transitions [][]string) *fakedTransitionExecutionManager {
+	mgr := newFakedExecutionManager()
+	for _, statuses := range transitions {
+		mgr.CreateTask(&models.Task{
+			ExecutionID: 1,
+			Status:      statuses[0],
+		})
+	}
+	return &fakedTransitionExecutionManager{
+		ExecutionManager: mgr,
+		transitions:      transitions,
+	}
+}
+
+func (f *fakedTransitionExecutionManager) ListTasks(query ...*models.TaskQuery) (int64, []*models.Task, error) {
+	total, tasks, err := f.ExecutionManager.ListTasks(query...)
+	if err != nil {
+		return 0, nil, err
+	}
+	// count the polling after the last page is listed
+	if q := query[0]; q.Page*q.Size >= total {
+		f.listed++
+		for i, statuses := range f.transitions {
+			if f.listed < len(statuses) {
+				f.UpdateTaskStatus(int64(i+1), statuses[f.listed])
+			}
+		}
+	}
+	return total, tasks, nil
+}
+
+func TestRunAndWait(t *testing.T) {
+	interval, size := waitInterval, waitPageSize
+	defer func() {
+		waitInterval, waitPageSize = interval, size
+	}()
+	waitInterval = time.Millisecond
+	waitPageSize = 2
+
+	// all tasks succeed
+	mgr := newTransitionExecutionManager([][]string{
+		{models.TaskStatusPending, models.TaskStatusInProgress, models.TaskStatusSucceed},
+		{models.TaskStatusInProgress, models.TaskStatusSucceed},
+		{models.TaskStatusSucceed},
+	})
+	result, err := RunAndWait(context.Background(), &fakedFlow{}, mgr, 1)
+	require.Nil(t, err)
+	assert.Equal(t, 3, mgr.listed)
+	assert.Equal(t, &Result{
+		Status:  models.ExecutionStatusSucceed,
+		Total:   3,
+		Succeed: 3,
+	}, result)
+
+	// the tasks end with a mix of terminal statuses
+	mgr = newTransitionExecutionManager([][]string{
+		{models.TaskStatusInProgress, models.TaskStatusFailed},
+		{models.TaskStatusInProgress, models.TaskStatusSucceed},
+		{models.TaskStatusDeferred},
+	})
+	result, err = RunAndWait(context.Background(), &fakedFlow{}, mgr, 1)
+	require.Nil(t, err)
+	assert.Equal(t, &Result{
+		Status:  models.ExecutionStatusFailed,
+		Total:   3,
+		Succeed: 1,
+		Failed:  1,
+		Skipped: 1,
+	}, result)
+
+	// the skipped tasks don't fail the execution
+	mgr = newTransitionExecutionManager([][]string{
+		{models.TaskStatusInProgress, models.TaskStatusSucceed},
+		{models.TaskStatusSkipped},
+		{models.TaskStatusDenied},
+	})
+	result, err = RunAndWait(context.Background(), &fakedFlow{}, mgr, 1)
+	require.Nil(t, err)
+	assert.Equal(t, &Result{
+		Status:  models.ExecutionStatusSucceed,
+		Total:   3,
+		Succeed: 1,
+		Skipped: 2,
+	}, result)
+
+	// some resources failed to be fetched
+	mgr = newTransitionExecutionManager([][]string{
+		{models.TaskStatusInProgress, models.TaskStatusSucceed},
+		{models.TaskStatusFetchFailed},
+	})
+	result, err = RunAndWait(context.Background(), &fakedFlow{}, mgr, 1)
+	require.Nil(t, err)
+	assert.Equal(t, &Result{
+		Status:      models.ExecutionStatusPartialSuccess,
+		Total:       2,
+		Succeed:     1,
+		FetchFailed: 1,
+	}, result)
+
+	// timeout
+	mgr = newTransitionExecutionManager([][]string{
+		{models.TaskStatusSucceed},
+		{models.TaskStatusInProgress},
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	result, err = RunAndWait(ctx, &fakedFlow{}, mgr, 1)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, &Result{
+		Status:     models.ExecutionStatusInProgress,
+		Total:      2,
+		Succeed:    1,
+		InProgress: 1,
+	}, result)
+}
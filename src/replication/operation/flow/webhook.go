@@ -0,0 +1,127 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/execution"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+)
+
+var webhookClient = &http.Client{
+	Timeout: 30 * time.Second,
+}
+
+// PreCopyRequest is sent to the pre-copy webhook for every resource to be copied
+type PreCopyRequest struct {
+	PolicyID      int64    `json:"policy_id"`
+	PolicyName    string   `json:"policy_name"`
+	ResourceType  string   `json:"resource_type"`
+	SrcRepository string   `json:"src_repository"`
+	DstRepository string   `json:"dst_repository"`
+	Vtags         []string `json:"vtags"`
+}
+
+// PreCopyResponse is returned by the pre-copy webhook to approve or deny the copy
+type PreCopyResponse struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// ask the pre-copy webhook of the policy to approve the items one by one, the denied ones
+// are marked as "denied" and won't be submitted. If the webhook fails, the item is approved
+// when the policy is fail-open, otherwise it is marked as failure. Returns the approved items
+// and the count of the failed ones
+func validateByWebhook(executionMgr execution.Manager, items []*scheduler.ScheduleItem,
+	policy *model.Policy) ([]*scheduler.ScheduleItem, int) {
+	if policy == nil || len(policy.PreCopyWebhookURL) == 0 {
+		return items, 0
+	}
+	var approved []*scheduler.ScheduleItem
+	failed := 0
+	for _, item := range items {
+		resp, err := callPreCopyWebhook(policy.PreCopyWebhookURL, &PreCopyRequest{
+			PolicyID:      policy.ID,
+			PolicyName:    policy.Name,
+			ResourceType:  string(item.SrcResource.Type),
+			SrcRepository: item.SrcResource.Metadata.Repository.Name,
+			DstRepository: item.DstResource.Metadata.Repository.Name,
+			Vtags:         item.SrcResource.Metadata.Vtags,
+		})
+		status := ""
+		switch {
+		case err != nil && policy.PreCopyWebhookFailOpen:
+			log.Warningf("failed to call the pre-copy webhook for the task %d, approve it as the webhook is fail-open: %v",
+				item.TaskID, err)
+		case err != nil:
+			log.Errorf("failed to call the pre-copy webhook for the task %d: %v", item.TaskID, err)
+			status = models.TaskStatusFailed
+			failed++
+		case !resp.Approved:
+			log.Infof("the task %d is denied by policy webhook: %s", item.TaskID, resp.Reason)
+			status = models.TaskStatusDenied
+		}
+		if len(status) == 0 {
+			approved = append(approved, item)
+			continue
+		}
+		if err = executionMgr.UpdateTaskStatus(item.TaskID, status, models.TaskStatusInitialized); err != nil {
+			log.Errorf("failed to update the task status %d: %v", item.TaskID, err)
+		}
+	}
+	log.Debug("validate the tasks by the pre-copy webhook completed")
+	return approved, failed
+}
+
+func callPreCopyWebhook(url string, request *PreCopyRequest) (*PreCopyResponse, error) {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, &common_http.Error{
+			Code:    resp.StatusCode,
+			Message: string(body),
+		}
+	}
+	response := &PreCopyResponse{}
+	if err = json.Unmarshal(body, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,137 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/scheduler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the webhook denies the resources under the namespace "private" and
+// fails for the ones under the namespace "error"
+func newPreCopyWebhookServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &PreCopyRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp := &PreCopyResponse{
+			Approved: true,
+		}
+		switch req.SrcRepository {
+		case "error/hello-world":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "private/hello-world":
+			resp.Approved = false
+			resp.Reason = "private images cannot be copied"
+		}
+		data, _ := json.Marshal(resp)
+		w.Write(data)
+	}))
+}
+
+func newWebhookItems() []*scheduler.ScheduleItem {
+	items := []*scheduler.ScheduleItem{}
+	for i, namespace := range []string{"library", "private", "error"} {
+		resource := &model.Resource{
+			Type: model.ResourceTypeImage,
+			Metadata: &model.ResourceMetadata{
+				Repository: &model.Repository{
+					Name: namespace + "/hello-world",
+				},
+				Vtags: []string{"latest"},
+			},
+		}
+		items = append(items, &scheduler.ScheduleItem{
+			TaskID:      int64(i + 1),
+			SrcResource: resource,
+			DstResource: resource,
+		})
+	}
+	return items
+}
+
+func TestValidateByWebhook(t *testing.T) {
+	server := newPreCopyWebhookServer()
+	defer server.Close()
+
+	// no webhook
+	mgr := newFakedExecutionManagerWithTasks(3)
+	items, failed := validateByWebhook(mgr, newWebhookItems(), &model.Policy{})
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+
+	// fail-closed
+	items, failed = validateByWebhook(mgr, newWebhookItems(), &model.Policy{
+		PreCopyWebhookURL: server.URL,
+	})
+	require.Equal(t, 1, len(items))
+	assert.Equal(t, 1, failed)
+	assert.Equal(t, int64(1), items[0].TaskID)
+	assert.Equal(t, map[int64]string{
+		2: models.TaskStatusDenied,
+		3: models.TaskStatusFailed,
+	}, updatedTaskStatuses(mgr))
+
+	// fail-open
+	mgr = newFakedExecutionManagerWithTasks(3)
+	items, failed = validateByWebhook(mgr, newWebhookItems(), &model.Policy{
+		PreCopyWebhookURL:      server.URL,
+		PreCopyWebhookFailOpen: true,
+	})
+	require.Equal(t, 2, len(items))
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, int64(1), items[0].TaskID)
+	assert.Equal(t, int64(3), items[1].TaskID)
+	assert.Equal(t, map[int64]string{
+		2: models.TaskStatusDenied,
+	}, updatedTaskStatuses(mgr))
+}
+
+func TestValidateByUnreachableWebhook(t *testing.T) {
+	server := newPreCopyWebhookServer()
+	url := server.URL
+	server.Close()
+
+	// fail-closed
+	mgr := newFakedExecutionManagerWithTasks(3)
+	items, failed := validateByWebhook(mgr, newWebhookItems(), &model.Policy{
+		PreCopyWebhookURL: url,
+	})
+	assert.Equal(t, 0, len(items))
+	assert.Equal(t, 3, failed)
+	assert.Equal(t, 3, len(updatedTaskStatuses(mgr)))
+
+	// fail-open
+	mgr = newFakedExecutionManagerWithTasks(3)
+	items, failed = validateByWebhook(mgr, newWebhookItems(), &model.Policy{
+		PreCopyWebhookURL:      url,
+		PreCopyWebhookFailOpen: true,
+	})
+	assert.Equal(t, 3, len(items))
+	assert.Equal(t, 0, failed)
+	assert.Equal(t, 0, len(updatedTaskStatuses(mgr)))
+}
@@ -0,0 +1,45 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hook
+
+import (
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/operation"
+	"github.com/goharbor/harbor/src/replication/operation/flow"
+)
+
+// whether the task with the status is finished, i.e. its job runs no more
+func taskFinished(status string) bool {
+	return status == models.TaskStatusSucceed || status == models.TaskStatusFailed ||
+		status == models.TaskStatusStopped || models.IsTaskSkipped(status)
+}
+
+// handle the execution once all its tasks finish, the final counts of its tasks are
+// known then. The failure is only logged as the status of the task is updated already
+func handleFinishedExecution(ctl operation.Controller, executionID int64) {
+	// the execution is marked as finished when getting it after all its tasks finish
+	execution, err := ctl.GetExecution(executionID)
+	if err != nil {
+		log.Errorf("failed to get the execution %d: %v", executionID, err)
+		return
+	}
+	if execution == nil || !models.IsExecutionFinished(execution.Status) {
+		return
+	}
+	log.Infof("the execution %d finished, %s", execution.ID, models.FormatTaskOutcome(execution))
+	// the concurrency of the next execution is tuned by the error rate of this one
+	flow.ReportExecutionOutcome(execution.PolicyID, execution.ID, execution.Succeed, execution.Failed)
+}
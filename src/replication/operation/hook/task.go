@@ -15,8 +15,12 @@
 package hook
 
 import (
+	"strings"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
 	"github.com/goharbor/harbor/src/jobservice/job"
 	"github.com/goharbor/harbor/src/replication/dao/models"
+	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/goharbor/harbor/src/replication/operation"
 )
 
@@ -37,5 +41,75 @@ func UpdateTask(ctl operation.Controller, id int64, status string) error {
 	case job.SuccessStatus:
 		s = models.TaskStatusSucceed
 	}
-	return ctl.UpdateTaskStatus(id, s)
+	return updateTaskStatus(ctl, id, s)
+}
+
+// the statuses that the task can be updated from to the status. The status
+// changes arrive out of order, so only the task in the status of the lower
+// precedence is updated, e.g. the late "Running" of the job doesn't override
+// the finished task, and the status changes of the job are ignored once the
+// task is timed out or rate limited as it has been requeued to the next execution
+var previousStatuses = map[string][]string{
+	models.TaskStatusPending:     {models.TaskStatusInitialized},
+	models.TaskStatusInProgress:  {models.TaskStatusInitialized, models.TaskStatusPending},
+	models.TaskStatusStopped:     {models.TaskStatusInitialized, models.TaskStatusPending, models.TaskStatusInProgress},
+	models.TaskStatusFailed:      {models.TaskStatusInitialized, models.TaskStatusPending, models.TaskStatusInProgress},
+	models.TaskStatusSucceed:     {models.TaskStatusInitialized, models.TaskStatusPending, models.TaskStatusInProgress},
+	models.TaskStatusTimedOut:    {models.TaskStatusInitialized, models.TaskStatusPending, models.TaskStatusInProgress},
+	models.TaskStatusRateLimited: {models.TaskStatusInitialized, models.TaskStatusPending, models.TaskStatusInProgress},
+}
+
+// update the status of the task if it is in one of the previous statuses. The
+// update is conditional on the previous statuses as well, so the status changed
+// concurrently to the one of the higher precedence isn't overridden
+func updateTaskStatus(ctl operation.Controller, id int64, status string) error {
+	previous := previousStatuses[status]
+	task, err := ctl.GetTask(id)
+	if err != nil {
+		return err
+	}
+	if task != nil && len(previous) > 0 && !contains(previous, task.Status) {
+		log.Debugf("the task %d is %s already, skip updating it to %s", id, task.Status, status)
+		return nil
+	}
+	if err = ctl.UpdateTaskStatus(id, status, previous...); err != nil {
+		return err
+	}
+	// the execution finishes with its last task
+	if task != nil && taskFinished(status) {
+		handleFinishedExecution(ctl, task.ExecutionID)
+	}
+	return nil
+}
+
+func contains(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckInTask handles the check in message of the task, the task is marked as
+// timed out when its job checks in that the task exceeds the deadline, and as
+// rate limited when its job checks in that the task is rate limited. The category
+// of the error failing the task is recorded when its job checks in the failure
+func CheckInTask(ctl operation.Controller, id int64, checkIn string) error {
+	if strings.HasPrefix(checkIn, model.TaskCheckInFailurePrefix) {
+		return ctl.UpdateTask(&models.Task{
+			ID:            id,
+			ErrorCategory: strings.TrimPrefix(checkIn, model.TaskCheckInFailurePrefix),
+		}, models.TaskPropsName.ErrorCategory)
+	}
+	status := ""
+	switch checkIn {
+	case model.TaskCheckInTimedOut:
+		status = models.TaskStatusTimedOut
+	case model.TaskCheckInRateLimited:
+		status = models.TaskStatusRateLimited
+	default:
+		return nil
+	}
+	return updateTaskStatus(ctl, id, status)
 }
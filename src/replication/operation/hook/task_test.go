@@ -15,6 +15,7 @@
 package hook
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/goharbor/harbor/src/jobservice/job"
@@ -25,12 +26,20 @@ import (
 )
 
 type fakedOperationController struct {
-	status string
+	status          string
+	statusCondition []string
+	errorCategory   string
+	execution       *models.Execution
+	// whether the execution is updated
+	updated bool
 }
 
 func (f *fakedOperationController) StartReplication(*model.Policy, *model.Resource, model.TriggerType) (int64, error) {
 	return 0, nil
 }
+func (f *fakedOperationController) StartDryRun(*model.Policy) (int64, error) {
+	return 0, nil
+}
 func (f *fakedOperationController) StopReplication(int64) error {
 	return nil
 }
@@ -38,18 +47,36 @@ func (f *fakedOperationController) ListExecutions(...*models.ExecutionQuery) (in
 	return 0, nil, nil
 }
 func (f *fakedOperationController) GetExecution(int64) (*models.Execution, error) {
-	return nil, nil
+	return f.execution, nil
+}
+func (f *fakedOperationController) UpdateExecution(execution *models.Execution, props ...string) error {
+	f.updated = true
+	return nil
 }
 func (f *fakedOperationController) ListTasks(...*models.TaskQuery) (int64, []*models.Task, error) {
 	return 0, nil, nil
 }
-func (f *fakedOperationController) GetTask(int64) (*models.Task, error) {
-	return nil, nil
+func (f *fakedOperationController) GetTask(id int64) (*models.Task, error) {
+	if len(f.status) == 0 {
+		return nil, nil
+	}
+	return &models.Task{
+		ID:     id,
+		Status: f.status,
+	}, nil
 }
 func (f *fakedOperationController) UpdateTaskStatus(id int64, status string, statusCondition ...string) error {
+	f.statusCondition = statusCondition
+	if len(statusCondition) > 0 && !contains(statusCondition, f.status) {
+		return errors.New("the status of the task isn't updated")
+	}
 	f.status = status
 	return nil
 }
+func (f *fakedOperationController) UpdateTask(task *models.Task, props ...string) error {
+	f.errorCategory = task.ErrorCategory
+	return nil
+}
 func (f *fakedOperationController) GetTaskLog(int64) ([]byte, error) {
 	return nil, nil
 }
@@ -87,8 +114,93 @@ func TestUpdateTask(t *testing.T) {
 	}
 
 	for _, c := range cases {
+		mgr.status = models.TaskStatusInitialized
 		err := UpdateTask(mgr, 1, c.inputStatus)
 		require.Nil(t, err)
 		assert.Equal(t, c.expectedStatus, mgr.status)
 	}
 }
+
+func TestUpdateTaskWithPrecedence(t *testing.T) {
+	mgr := &fakedOperationController{
+		status: models.TaskStatusInProgress,
+	}
+	// the update is conditional on the statuses of the lower precedence
+	require.Nil(t, UpdateTask(mgr, 1, job.SuccessStatus.String()))
+	assert.Equal(t, models.TaskStatusSucceed, mgr.status)
+	assert.Equal(t, []string{models.TaskStatusInitialized, models.TaskStatusPending,
+		models.TaskStatusInProgress}, mgr.statusCondition)
+
+	// the late "Running" doesn't override the finished task
+	require.Nil(t, UpdateTask(mgr, 1, job.RunningStatus.String()))
+	assert.Equal(t, models.TaskStatusSucceed, mgr.status)
+
+	// the check in arriving before the "Running" of the job
+	mgr.status = models.TaskStatusPending
+	require.Nil(t, CheckInTask(mgr, 1, model.TaskCheckInTimedOut))
+	assert.Equal(t, models.TaskStatusTimedOut, mgr.status)
+	require.Nil(t, UpdateTask(mgr, 1, job.RunningStatus.String()))
+	assert.Equal(t, models.TaskStatusTimedOut, mgr.status)
+
+	// the late check in doesn't override the finished or deferred task
+	for _, status := range []string{models.TaskStatusFailed, models.TaskStatusDeferred} {
+		mgr.status = status
+		require.Nil(t, CheckInTask(mgr, 1, model.TaskCheckInRateLimited))
+		assert.Equal(t, status, mgr.status)
+	}
+}
+
+func TestCheckInTask(t *testing.T) {
+	mgr := &fakedOperationController{
+		status: models.TaskStatusInProgress,
+	}
+	// the unknown check in message is ignored
+	require.Nil(t, CheckInTask(mgr, 1, "unknown"))
+	assert.Equal(t, models.TaskStatusInProgress, mgr.status)
+
+	require.Nil(t, CheckInTask(mgr, 1, model.TaskCheckInTimedOut))
+	assert.Equal(t, models.TaskStatusTimedOut, mgr.status)
+	assert.Equal(t, []string{models.TaskStatusInitialized, models.TaskStatusPending,
+		models.TaskStatusInProgress}, mgr.statusCondition)
+
+	// the status of the job doesn't override the timed out task
+	require.Nil(t, UpdateTask(mgr, 1, job.SuccessStatus.String()))
+	assert.Equal(t, models.TaskStatusTimedOut, mgr.status)
+
+	mgr.status = models.TaskStatusInProgress
+	require.Nil(t, CheckInTask(mgr, 1, model.TaskCheckInRateLimited))
+	assert.Equal(t, models.TaskStatusRateLimited, mgr.status)
+	assert.Equal(t, []string{models.TaskStatusInitialized, models.TaskStatusPending,
+		models.TaskStatusInProgress}, mgr.statusCondition)
+
+	// the status of the job doesn't override the rate limited task
+	require.Nil(t, UpdateTask(mgr, 1, job.ErrorStatus.String()))
+	assert.Equal(t, models.TaskStatusRateLimited, mgr.status)
+
+	// the category of the failure is recorded without changing the status
+	require.Nil(t, CheckInTask(mgr, 1, model.TaskCheckInFailurePrefix+"auth"))
+	assert.Equal(t, "auth", mgr.errorCategory)
+	assert.Equal(t, models.TaskStatusRateLimited, mgr.status)
+}
+
+func TestUpdateTaskOfFinishedExecution(t *testing.T) {
+	mgr := &fakedOperationController{
+		status: models.TaskStatusInProgress,
+		execution: &models.Execution{
+			ID:          1,
+			Status:      models.ExecutionStatusInProgress,
+			SummaryText: `{"created":2,"submitted":2}`,
+			Succeed:     1,
+			InProgress:  1,
+		},
+	}
+	require.Nil(t, UpdateTask(mgr, 1, job.SuccessStatus.String()))
+
+	// the outcome of the tasks is counted by the execution itself, the summary
+	// stored by the flow is kept as it is
+	mgr.status = models.TaskStatusInProgress
+	mgr.execution.Status = models.ExecutionStatusFailed
+	mgr.execution.InProgress, mgr.execution.Failed = 0, 1
+	require.Nil(t, UpdateTask(mgr, 2, job.ErrorStatus.String()))
+	assert.False(t, mgr.updated)
+}
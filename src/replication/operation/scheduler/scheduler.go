@@ -39,9 +39,9 @@ func NewScheduler(js cjob.Client) Scheduler {
 
 // ScheduleItem is an item that can be scheduled
 type ScheduleItem struct {
-	TaskID      int64 // used as the param in the hook
-	SrcResource *model.Resource
-	DstResource *model.Resource
+	TaskID      int64           `json:"task_id,omitempty"` // used as the param in the hook
+	SrcResource *model.Resource `json:"src_resource"`
+	DstResource *model.Resource `json:"dst_resource"`
 }
 
 // ScheduleResult is the result of the schedule for one item
@@ -20,6 +20,7 @@ import (
 	"github.com/goharbor/harbor/src/common/job"
 	"github.com/goharbor/harbor/src/common/utils/log"
 	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/goharbor/harbor/src/replication/operation/flow"
 	"github.com/goharbor/harbor/src/replication/policy"
 	"github.com/goharbor/harbor/src/replication/policy/manager"
 	"github.com/goharbor/harbor/src/replication/policy/scheduler"
@@ -102,7 +103,12 @@ func (c *controller) Remove(policyID int64) error {
 			return err
 		}
 	}
-	return c.Controller.Remove(policyID)
+	if err = c.Controller.Remove(policyID); err != nil {
+		return err
+	}
+	// the concurrency tuned for the policy is useless once it's removed
+	flow.RemoveConcurrencyController(policyID)
+	return nil
 }
 
 func isScheduledTrigger(policy *model.Policy) bool {
@@ -15,6 +15,7 @@
 package manager
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -30,23 +31,57 @@ import (
 
 var errNilPolicyModel = errors.New("nil policy model")
 
+// the properties of the policy stored in their own columns, all the others are
+// stored together as the options in JSON
+var columnProperties = []string{"id", "name", "description", "creator", "src_registry",
+	"dest_registry", "dest_namespace", "filters", "trigger", "deletion", "override",
+	"enabled", "creation_time", "update_time"}
+
+// encode the properties of the policy which have no columns of their own into JSON
+func marshalOptions(policy *model.Policy) (string, error) {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return "", err
+	}
+	options := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// keep the precision of the big numbers, e.g. the byte budget
+	decoder.UseNumber()
+	if err = decoder.Decode(&options); err != nil {
+		return "", err
+	}
+	for _, property := range columnProperties {
+		delete(options, property)
+	}
+	data, err = json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
 func convertFromPersistModel(policy *persist_models.RepPolicy) (*model.Policy, error) {
 	if policy == nil {
 		return nil, nil
 	}
 
-	ply := model.Policy{
-		ID:            policy.ID,
-		Name:          policy.Name,
-		Description:   policy.Description,
-		Creator:       policy.Creator,
-		DestNamespace: policy.DestNamespace,
-		Deletion:      policy.ReplicateDeletion,
-		Override:      policy.Override,
-		Enabled:       policy.Enabled,
-		CreationTime:  policy.CreationTime,
-		UpdateTime:    policy.UpdateTime,
+	ply := model.Policy{}
+	// the options don't contain the properties stored in the columns
+	if len(policy.Options) > 0 {
+		if err := json.Unmarshal([]byte(policy.Options), &ply); err != nil {
+			return nil, fmt.Errorf("failed to parse the options of the policy %d: %v", policy.ID, err)
+		}
 	}
+	ply.ID = policy.ID
+	ply.Name = policy.Name
+	ply.Description = policy.Description
+	ply.Creator = policy.Creator
+	ply.DestNamespace = policy.DestNamespace
+	ply.Deletion = policy.ReplicateDeletion
+	ply.Override = policy.Override
+	ply.Enabled = policy.Enabled
+	ply.CreationTime = policy.CreationTime
+	ply.UpdateTime = policy.UpdateTime
 	if policy.SrcRegistryID > 0 {
 		ply.SrcRegistry = &model.Registry{
 			ID: policy.SrcRegistryID,
@@ -115,6 +150,12 @@ func convertToPersistModel(policy *model.Policy) (*persist_models.RepPolicy, err
 		ply.Filters = string(filters)
 	}
 
+	options, err := marshalOptions(policy)
+	if err != nil {
+		return nil, err
+	}
+	ply.Options = options
+
 	return ply, nil
 }
 
@@ -199,10 +240,13 @@ func (m *DefaultManager) Remove(policyID int64) error {
 }
 
 type filter struct {
-	Type    model.FilterType `json:"type"`
-	Value   interface{}      `json:"value"`
-	Kind    string           `json:"kind"`
-	Pattern string           `json:"pattern"`
+	Type       model.FilterType   `json:"type"`
+	Value      interface{}        `json:"value"`
+	Scope      model.ResourceType `json:"scope"`
+	Mode       model.FilterMode   `json:"mode"`
+	Decoration string             `json:"decoration"`
+	Kind       string             `json:"kind"`
+	Pattern    string             `json:"pattern"`
 }
 
 type trigger struct {
@@ -230,8 +274,11 @@ func parseFilters(str string) ([]*model.Filter, error) {
 	filters := []*model.Filter{}
 	for _, item := range items {
 		filter := &model.Filter{
-			Type:  item.Type,
-			Value: item.Value,
+			Type:       item.Type,
+			Value:      item.Value,
+			Scope:      item.Scope,
+			Mode:       item.Mode,
+			Decoration: item.Decoration,
 		}
 		// keep backwards compatibility
 		if len(filter.Type) == 0 {
@@ -269,7 +316,11 @@ func parseFilters(str string) ([]*model.Filter, error) {
 		// convert the type of value from string to model.ResourceType if the filter
 		// is a resource type filter
 		if filter.Type == model.FilterTypeResource {
-			filter.Value = (model.ResourceType)(filter.Value.(string))
+			resourceType, err := filter.GetResourceType()
+			if err != nil {
+				return nil, err
+			}
+			filter.Value = resourceType
 		}
 		if filter.Type == model.FilterTypeLabel {
 			labels := []string{}
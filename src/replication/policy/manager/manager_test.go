@@ -211,6 +211,12 @@ func TestParseFilters(t *testing.T) {
 	require.Equal(t, 1, len(filters))
 	assert.Equal(t, model.FilterTypeName, filters[0].Type)
 	assert.Equal(t, "library/hello-world", filters[0].Value.(string))
+	// contains the scope
+	str = `[{"type":"name","value":"library/**","scope":"chart"}]`
+	filters, err = parseFilters(str)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(filters))
+	assert.Equal(t, model.ResourceTypeChart, filters[0].Scope)
 	// contains "kind" from previous versions
 	str = `[{"kind":"repository","value":"hello-world"},{"type":"name","value":"library/**"}]`
 	filters, err = parseFilters(str)
@@ -264,3 +270,59 @@ func TestParseTrigger(t *testing.T) {
 	assert.Equal(t, model.TriggerTypeScheduled, trigger.Type)
 	assert.Equal(t, "0 0 0 * * *", trigger.Settings.Cron)
 }
+
+func TestConvertOptions(t *testing.T) {
+	policy := &model.Policy{
+		ID:   1,
+		Name: "policy",
+		SrcRegistry: &model.Registry{
+			ID:         1,
+			Credential: &model.Credential{AccessSecret: "secret"},
+		},
+		DestRegistry: &model.Registry{
+			ID: 2,
+		},
+		Enabled: true,
+		NamespaceMappings: []*model.NamespaceMapping{
+			{From: "library/*", To: "mirror/library/*"},
+		},
+		NamespaceOverrides:          map[string]bool{"library": false},
+		MaxBytesPerExecution:        1<<62 + 1,
+		MaxRepositoriesPerNamespace: 10,
+		TagConcurrency:              4,
+		TaskDeadline:                3600,
+		DestinationTagTTL:           86400,
+		ExecutionMode:               model.ExecutionModeOrdered,
+		IncludeUntagged:             true,
+	}
+	m, err := convertToPersistModel(policy)
+	require.Nil(t, err)
+	// the properties stored in the columns aren't duplicated in the options
+	assert.NotContains(t, m.Options, `"name"`)
+	assert.NotContains(t, m.Options, "secret")
+
+	p, err := convertFromPersistModel(m)
+	require.Nil(t, err)
+	assert.Equal(t, policy.NamespaceMappings, p.NamespaceMappings)
+	assert.Equal(t, policy.NamespaceOverrides, p.NamespaceOverrides)
+	assert.Equal(t, policy.MaxBytesPerExecution, p.MaxBytesPerExecution)
+	assert.Equal(t, policy.MaxRepositoriesPerNamespace, p.MaxRepositoriesPerNamespace)
+	assert.Equal(t, policy.TagConcurrency, p.TagConcurrency)
+	assert.Equal(t, policy.TaskDeadline, p.TaskDeadline)
+	assert.Equal(t, policy.DestinationTagTTL, p.DestinationTagTTL)
+	assert.Equal(t, policy.ExecutionMode, p.ExecutionMode)
+	assert.True(t, p.IncludeUntagged)
+	assert.Equal(t, "policy", p.Name)
+	assert.True(t, p.Enabled)
+	// only the IDs of the registries are kept
+	assert.Nil(t, p.SrcRegistry.Credential)
+
+	// the policies stored before the options are introduced
+	p, err = convertFromPersistModel(&persist_models.RepPolicy{
+		ID:   1,
+		Name: "policy",
+	})
+	require.Nil(t, err)
+	assert.Equal(t, "policy", p.Name)
+	assert.Equal(t, 0, p.MaxRepositoriesPerNamespace)
+}
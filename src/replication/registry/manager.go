@@ -15,6 +15,7 @@
 package registry
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/goharbor/harbor/src/common/utils"
@@ -247,6 +248,12 @@ func fromDaoModel(registry *models.Registry) (*model.Registry, error) {
 		UpdateTime:   registry.UpdateTime,
 	}
 
+	if len(registry.ResolveOverride) != 0 {
+		if err := json.Unmarshal([]byte(registry.ResolveOverride), &r.ResolveOverride); err != nil {
+			return nil, err
+		}
+	}
+
 	if len(registry.AccessKey) != 0 {
 		credentialType := registry.CredentialType
 		if len(credentialType) == 0 {
@@ -281,6 +288,14 @@ func toDaoModel(registry *model.Registry) (*models.Registry, error) {
 		UpdateTime:   registry.UpdateTime,
 	}
 
+	if len(registry.ResolveOverride) != 0 {
+		data, err := json.Marshal(registry.ResolveOverride)
+		if err != nil {
+			return nil, err
+		}
+		m.ResolveOverride = string(data)
+	}
+
 	if registry.Credential != nil && len(registry.Credential.AccessKey) != 0 {
 		credentialType := registry.Credential.Type
 		if len(credentialType) == 0 {
@@ -17,10 +17,36 @@ package registry
 import (
 	"testing"
 
+	"github.com/goharbor/harbor/src/replication/model"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewDefaultManager(t *testing.T) {
 	mgr := NewDefaultManager()
 	assert.NotNil(t, mgr)
 }
+
+func TestConvertResolveOverride(t *testing.T) {
+	registry := &model.Registry{
+		URL: "https://registry.harbor.local",
+		ResolveOverride: map[string]string{
+			"registry.harbor.local": "10.0.0.1",
+		},
+	}
+	m, err := toDaoModel(registry)
+	require.Nil(t, err)
+	assert.Equal(t, `{"registry.harbor.local":"10.0.0.1"}`, m.ResolveOverride)
+
+	r, err := fromDaoModel(m)
+	require.Nil(t, err)
+	assert.Equal(t, registry.ResolveOverride, r.ResolveOverride)
+
+	// no override
+	m, err = toDaoModel(&model.Registry{})
+	require.Nil(t, err)
+	assert.Equal(t, "", m.ResolveOverride)
+	r, err = fromDaoModel(m)
+	require.Nil(t, err)
+	assert.Nil(t, r.ResolveOverride)
+}
@@ -71,7 +71,7 @@ func (t *transfer) Transfer(src *model.Resource, dst *model.Resource) error {
 		version: dst.Metadata.Vtags[0],
 	}
 	// copy the chart from source registry to the destination
-	return t.copy(srcChart, dstChart, dst.Override)
+	return t.copy(srcChart, dstChart, dst.Override, dst.Move)
 }
 
 func (t *transfer) initialize(src, dst *model.Resource) error {
@@ -125,7 +125,10 @@ func (t *transfer) shouldStop() bool {
 	return isStopped
 }
 
-func (t *transfer) copy(src, dst *chart, override bool) error {
+// copy the chart from the source registry to the destination, if "move" is set,
+// the source chart is deleted once it is uploaded. The failure of deleting the
+// source chart doesn't undo the copy, but is reported as the error
+func (t *transfer) copy(src, dst *chart, override, move bool) error {
 	if t.shouldStop() {
 		return nil
 	}
@@ -166,6 +169,21 @@ func (t *transfer) copy(src, dst *chart, override bool) error {
 	t.logger.Infof("copy %s:%s(source registry) to %s:%s(destination registry) completed",
 		src.name, src.version, dst.name, dst.version)
 
+	if move {
+		return t.deleteSource(src)
+	}
+	return nil
+}
+
+// delete the chart on the source registry after it is moved to the destination registry
+func (t *transfer) deleteSource(chart *chart) error {
+	t.logger.Infof("deleting the chart %s:%s on the source registry...", chart.name, chart.version)
+	if err := t.src.DeleteChart(chart.name, chart.version); err != nil {
+		t.logger.Errorf("the chart %s:%s is copied, but failed to delete it on the source registry: %v",
+			chart.name, chart.version, err)
+		return err
+	}
+	t.logger.Infof("delete the chart %s:%s on the source registry completed", chart.name, chart.version)
 	return nil
 }
 
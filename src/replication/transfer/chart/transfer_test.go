@@ -16,6 +16,8 @@ package chart
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
 	"testing"
@@ -29,7 +31,7 @@ import (
 
 type fakeRegistry struct{}
 
-func (f *fakeRegistry) FetchCharts(filters []*model.Filter) ([]*model.Resource, error) {
+func (f *fakeRegistry) FetchCharts(ctx context.Context, filters []*model.Filter) ([]*model.Resource, error) {
 	return []*model.Resource{
 		{
 			Type: model.ResourceTypeChart,
@@ -96,10 +98,67 @@ func TestCopy(t *testing.T) {
 		name:    "dest/harbor",
 		version: "0.2.0",
 	}
-	err := transfer.copy(src, dst, true)
+	err := transfer.copy(src, dst, true, false)
 	assert.Nil(t, err)
 }
 
+// the registry records the deleted charts and fails to delete the chart "library/broken"
+type fakeMoveRegistry struct {
+	fakeRegistry
+	deleted []string
+}
+
+func (f *fakeMoveRegistry) ChartExist(name, version string) (bool, error) {
+	return false, nil
+}
+func (f *fakeMoveRegistry) DeleteChart(name, version string) error {
+	if name == "library/broken" {
+		return errors.New("permission denied")
+	}
+	f.deleted = append(f.deleted, name+":"+version)
+	return nil
+}
+
+func TestCopyWithMove(t *testing.T) {
+	stopFunc := func() bool { return false }
+	src := &fakeMoveRegistry{}
+	transfer := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: stopFunc,
+		src:       src,
+		dst:       &fakeRegistry{},
+	}
+	dst := &chart{
+		name:    "dest/harbor",
+		version: "0.2.0",
+	}
+
+	// the chart is deleted on the source registry after being copied
+	err := transfer.copy(&chart{
+		name:    "library/harbor",
+		version: "0.2.0",
+	}, dst, true, true)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"library/harbor:0.2.0"}, src.deleted)
+
+	// the chart exists on the destination registry and isn't overridden,
+	// the source isn't deleted
+	src.deleted = nil
+	err = transfer.copy(&chart{
+		name:    "library/harbor",
+		version: "0.2.0",
+	}, dst, false, true)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(src.deleted))
+
+	// the copy succeeds but the deletion fails
+	err = transfer.copy(&chart{
+		name:    "library/broken",
+		version: "0.2.0",
+	}, dst, true, true)
+	assert.NotNil(t, err)
+}
+
 func TestDelete(t *testing.T) {
 	stopFunc := func() bool { return false }
 	transfer := &transfer{
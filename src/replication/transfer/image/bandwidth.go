@@ -0,0 +1,123 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// the limiters of the bandwidth indexed by the execution, they're shared by the
+// copy tasks of the same execution running in the same process
+var bandwidthLimiters = &executionBandwidthLimiters{}
+
+type executionBandwidthLimiters struct {
+	sync.Mutex
+	limiters map[int64]*bandwidthLimiter
+	refs     map[int64]int
+}
+
+// get the limiter of the execution with the bandwidth in MB/s, it's created if it
+// doesn't exist. Each "get" must be paired with a "put" once the task completes
+func (e *executionBandwidthLimiters) get(executionID int64, limit int) *bandwidthLimiter {
+	e.Lock()
+	defer e.Unlock()
+	if e.limiters == nil {
+		e.limiters = map[int64]*bandwidthLimiter{}
+		e.refs = map[int64]int{}
+	}
+	limiter, exist := e.limiters[executionID]
+	if !exist {
+		limiter = newBandwidthLimiter(int64(limit) * 1024 * 1024)
+		e.limiters[executionID] = limiter
+	}
+	e.refs[executionID]++
+	return limiter
+}
+
+// put releases the limiter got by "get", it's removed when no task uses it
+func (e *executionBandwidthLimiters) put(executionID int64) {
+	e.Lock()
+	defer e.Unlock()
+	e.refs[executionID]--
+	if e.refs[executionID] <= 0 {
+		delete(e.refs, executionID)
+		delete(e.limiters, executionID)
+	}
+}
+
+// bandwidthLimiter keeps the aggregate throughput of its readers under the bytes per
+// second: each read is scheduled after the ones before it have been paid for
+type bandwidthLimiter struct {
+	lock           sync.Mutex
+	bytesPerSecond int64
+	// the max bytes of one read, it keeps the waits short so the idle timeout
+	// of the blobs isn't hit by the throttling
+	chunk int
+	next  time.Time
+}
+
+func newBandwidthLimiter(bytesPerSecond int64) *bandwidthLimiter {
+	chunk := int(bytesPerSecond / 10)
+	if chunk < 1 {
+		chunk = 1
+	}
+	return &bandwidthLimiter{
+		bytesPerSecond: bytesPerSecond,
+		chunk:          chunk,
+	}
+}
+
+// reserve the bandwidth of n bytes and return the duration to wait before using it
+func (b *bandwidthLimiter) reserve(n int) time.Duration {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	now := time.Now()
+	if b.next.Before(now) {
+		b.next = now
+	}
+	wait := b.next.Sub(now)
+	b.next = b.next.Add(time.Duration(int64(n) * int64(time.Second) / b.bytesPerSecond))
+	return wait
+}
+
+// throttledReader waits for the bandwidth of the bytes read from the underlying reader
+type throttledReader struct {
+	reader  io.ReadCloser
+	limiter *bandwidthLimiter
+}
+
+func newThrottledReader(reader io.ReadCloser, limiter *bandwidthLimiter) *throttledReader {
+	return &throttledReader{
+		reader:  reader,
+		limiter: limiter,
+	}
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > t.limiter.chunk {
+		p = p[:t.limiter.chunk]
+	}
+	n, err := t.reader.Read(p)
+	if n > 0 {
+		time.Sleep(t.limiter.reserve(n))
+	}
+	return n, err
+}
+
+func (t *throttledReader) Close() error {
+	return t.reader.Close()
+}
@@ -0,0 +1,61 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBandwidthLimiters(t *testing.T) {
+	limiters := &executionBandwidthLimiters{}
+	a := limiters.get(1, 10)
+	assert.Equal(t, int64(10*1024*1024), a.bytesPerSecond)
+	assert.True(t, a == limiters.get(1, 10))
+	assert.False(t, a == limiters.get(2, 10))
+
+	// the limiter is kept until all the tasks of the execution complete
+	limiters.put(1)
+	assert.True(t, a == limiters.get(1, 10))
+	limiters.put(1)
+	limiters.put(1)
+	assert.False(t, a == limiters.get(1, 10))
+}
+
+func TestThrottledReader(t *testing.T) {
+	// 1MB/s shared by two readers reading 200KB each, the first chunk isn't waited
+	limiter := newBandwidthLimiter(1000 * 1000)
+	start := time.Now()
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			data := ioutil.NopCloser(bytes.NewReader(make([]byte, 200*1000)))
+			reader := newThrottledReader(data, limiter)
+			n, err := io.Copy(ioutil.Discard, reader)
+			assert.Nil(t, err)
+			assert.Equal(t, int64(200*1000), n)
+		}()
+	}
+	wg.Wait()
+	assert.True(t, time.Since(start) >= 250*time.Millisecond)
+}
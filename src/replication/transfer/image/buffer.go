@@ -0,0 +1,51 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bufio"
+	"io"
+)
+
+// the default size of the buffer reading the blobs from the source registry. It's
+// larger than the 32 KiB used by "io.Copy" as the fewer and larger reads give better
+// throughput for the big layers on the high-latency links
+const defaultBlobBufferSize = 1024 * 1024
+
+// bufferedReader reads the underlying reader in chunks of the buffer size. Only
+// "Read" is exposed on purpose: the "WriteTo" of "bufio.Reader" would be picked by
+// "io.Copy" and hand the underlying reader to the writer directly, bypassing the buffer
+type bufferedReader struct {
+	reader *bufio.Reader
+	closer io.Closer
+}
+
+func newBufferedReader(reader io.ReadCloser, size int) *bufferedReader {
+	if size <= 0 {
+		size = defaultBlobBufferSize
+	}
+	return &bufferedReader{
+		reader: bufio.NewReaderSize(reader, size),
+		closer: reader,
+	}
+}
+
+func (b *bufferedReader) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *bufferedReader) Close() error {
+	return b.closer.Close()
+}
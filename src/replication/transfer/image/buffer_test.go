@@ -0,0 +1,118 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the reader serves the specified bytes and records the max size of the reads,
+// every read takes the latency to simulate the round trip of the high-latency links
+type sizeRecordingReader struct {
+	remaining int
+	latency   time.Duration
+	maxRead   int
+}
+
+func (s *sizeRecordingReader) Read(p []byte) (int, error) {
+	if s.remaining == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > s.maxRead {
+		s.maxRead = len(p)
+	}
+	if s.latency > 0 {
+		time.Sleep(s.latency)
+	}
+	n := len(p)
+	if n > s.remaining {
+		n = s.remaining
+	}
+	s.remaining -= n
+	return n, nil
+}
+
+func (s *sizeRecordingReader) Close() error {
+	return nil
+}
+
+// the source serves the blob with the size recording reader
+type fakeBufferRegistry struct {
+	fakeRegistry
+	reader *sizeRecordingReader
+	pushed int64
+}
+
+func (f *fakeBufferRegistry) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	f.reader = &sizeRecordingReader{remaining: 4 * 1024 * 1024}
+	return int64(f.reader.remaining), f.reader, nil
+}
+
+func (f *fakeBufferRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	// "ioutil.Discard" reads with its own small buffer
+	n, err := io.Copy(ioutil.Discard, blob)
+	f.pushed = n
+	return err
+}
+
+func TestCopyBlobWithBufferSize(t *testing.T) {
+	cases := []struct {
+		bufferSize int
+		maxRead    int
+	}{
+		{0, defaultBlobBufferSize},
+		{64 * 1024, 64 * 1024},
+		{2 * 1024 * 1024, 2 * 1024 * 1024},
+	}
+	for _, c := range cases {
+		registry := &fakeBufferRegistry{}
+		tr := &transfer{
+			logger:     log.DefaultLogger(),
+			isStopped:  func() bool { return false },
+			src:        registry,
+			dst:        registry,
+			bufferSize: c.bufferSize,
+		}
+		require.Nil(t, tr.copyBlob("source", "destination", "sha256:1"))
+		assert.Equal(t, int64(4*1024*1024), registry.pushed)
+		assert.Equal(t, c.maxRead, registry.reader.maxRead)
+	}
+}
+
+func BenchmarkBufferedReader(b *testing.B) {
+	const size = 8 * 1024 * 1024
+	for _, bufferSize := range []int{32 * 1024, defaultBlobBufferSize} {
+		b.Run(fmt.Sprintf("%dKiB", bufferSize/1024), func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				reader := &sizeRecordingReader{
+					remaining: size,
+					latency:   100 * time.Microsecond,
+				}
+				if _, err := io.Copy(ioutil.Discard, newBufferedReader(reader, bufferSize)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
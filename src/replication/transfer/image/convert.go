@@ -0,0 +1,240 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/opencontainers/go-digest"
+)
+
+// the properties of the v1 image which shouldn't be in the image config
+var v1OnlyProperties = []string{"id", "parent", "Size", "parent_id", "layer_id", "throwaway"}
+
+// the history of the schema1 manifest
+type v1Compatibility struct {
+	Created         time.Time `json:"created"`
+	Author          string    `json:"author,omitempty"`
+	Comment         string    `json:"comment,omitempty"`
+	ThrowAway       bool      `json:"throwaway,omitempty"`
+	ContainerConfig struct {
+		Cmd []string `json:"Cmd,omitempty"`
+	} `json:"container_config,omitempty"`
+}
+
+// the history of the image config
+type history struct {
+	Created    time.Time `json:"created"`
+	Author     string    `json:"author,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+type rootFS struct {
+	Type    string          `json:"type"`
+	DiffIDs []digest.Digest `json:"diff_ids"`
+}
+
+// the schema2 manifest converted from the schema1 one and its image config
+type conversion struct {
+	manifest distribution.Manifest
+	config   []byte
+}
+
+// the count of the conversions kept by the cache
+const conversionCacheSize = 256
+
+// conversionCache keeps the recent conversions indexed by the digests of the source
+// schema1 manifests. The conversion pulls all the layers of the image, so the reruns
+// reuse the cached one to check the existence on the destination registry cheaply.
+// The oldest conversion is evicted once the cache is full
+type conversionCache struct {
+	sync.Mutex
+	keys        []string
+	conversions map[string]*conversion
+}
+
+func (c *conversionCache) get(key string) *conversion {
+	c.Lock()
+	defer c.Unlock()
+	return c.conversions[key]
+}
+
+func (c *conversionCache) add(key string, conv *conversion) {
+	c.Lock()
+	defer c.Unlock()
+	if c.conversions == nil {
+		c.conversions = map[string]*conversion{}
+	}
+	if _, exist := c.conversions[key]; exist {
+		return
+	}
+	if len(c.keys) >= conversionCacheSize {
+		delete(c.conversions, c.keys[0])
+		c.keys = c.keys[1:]
+	}
+	c.keys = append(c.keys, key)
+	c.conversions[key] = conv
+}
+
+var conversions = &conversionCache{}
+
+// convert the schema1 manifest whose digest is specified to schema2, the conversion
+// done before is reused. The image config built from the history of the manifest is
+// returned along with the converted manifest, it should be pushed to the destination
+// registry before the manifest
+func (t *transfer) convertSchema1Manifest(manifest *schema1.SignedManifest, dgt, srcRepo string) (
+	distribution.Manifest, []byte, error) {
+	if len(dgt) == 0 {
+		return t.doConvertSchema1Manifest(manifest, srcRepo)
+	}
+	if conv := conversions.get(dgt); conv != nil {
+		t.logger.Debugf("the schema1 manifest %s of %s is converted before, reuse it", dgt, srcRepo)
+		return conv.manifest, conv.config, nil
+	}
+	converted, config, err := t.doConvertSchema1Manifest(manifest, srcRepo)
+	if err != nil {
+		return nil, nil, err
+	}
+	conversions.add(dgt, &conversion{
+		manifest: converted,
+		config:   config,
+	})
+	return converted, config, nil
+}
+
+// convert the schema1 manifest to schema2: the layers are pulled from the source
+// registry to calculate the diff IDs, and the image config is built from the
+// history of the manifest
+func (t *transfer) doConvertSchema1Manifest(manifest *schema1.SignedManifest, srcRepo string) (
+	distribution.Manifest, []byte, error) {
+	t.logger.Infof("converting the schema1 manifest of %s to schema2...", srcRepo)
+	if len(manifest.FSLayers) != len(manifest.History) || len(manifest.History) == 0 {
+		return nil, nil, fmt.Errorf("the length of fs layers and history of the schema1 manifest don't match")
+	}
+
+	var layers []distribution.Descriptor
+	var histories []history
+	diffIDs := []digest.Digest{}
+	// the layers of schema1 manifest are in reverse order
+	for i := len(manifest.History) - 1; i >= 0; i-- {
+		v1 := &v1Compatibility{}
+		if err := json.Unmarshal([]byte(manifest.History[i].V1Compatibility), v1); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal the history of the schema1 manifest: %v", err)
+		}
+		histories = append(histories, history{
+			Created:    v1.Created,
+			Author:     v1.Author,
+			CreatedBy:  strings.Join(v1.ContainerConfig.Cmd, " "),
+			Comment:    v1.Comment,
+			EmptyLayer: v1.ThrowAway,
+		})
+		if v1.ThrowAway {
+			continue
+		}
+		blobSum := manifest.FSLayers[i].BlobSum.String()
+		size, diffID, err := t.getDiffID(srcRepo, blobSum)
+		if err != nil {
+			return nil, nil, err
+		}
+		layers = append(layers, distribution.Descriptor{
+			MediaType: schema2.MediaTypeLayer,
+			Size:      size,
+			Digest:    manifest.FSLayers[i].BlobSum,
+		})
+		diffIDs = append(diffIDs, diffID)
+	}
+
+	// the v1 image of the top layer is used as the base of the image config
+	config := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(manifest.History[0].V1Compatibility), &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal the history of the schema1 manifest: %v", err)
+	}
+	for _, property := range v1OnlyProperties {
+		delete(config, property)
+	}
+	config["rootfs"] = &rootFS{
+		Type:    "layers",
+		DiffIDs: diffIDs,
+	}
+	config["history"] = histories
+	payload, err := json.Marshal(config)
+	if err != nil {
+		return nil, nil, err
+	}
+	converted, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: schema2.SchemaVersion,
+		Config: distribution.Descriptor{
+			MediaType: schema2.MediaTypeImageConfig,
+			Size:      int64(len(payload)),
+			Digest:    digest.FromBytes(payload),
+		},
+		Layers: layers,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	t.logger.Infof("the schema1 manifest of %s converted", srcRepo)
+	return converted, payload, nil
+}
+
+// push the image config of the converted manifest to the destination registry
+func (t *transfer) pushConvertedConfig(dstRepo string, config []byte) error {
+	dgt := digest.FromBytes(config)
+	exist, err := t.dst.BlobExist(dstRepo, dgt.String())
+	if err != nil {
+		t.logger.Errorf("failed to check the existence of the image config %s: %v", dgt, err)
+		return err
+	}
+	if exist {
+		return nil
+	}
+	if err = t.dst.PushBlob(dstRepo, dgt.String(), int64(len(config)), bytes.NewReader(config)); err != nil {
+		t.logger.Errorf("failed to push the image config %s: %v", dgt, err)
+		return err
+	}
+	return nil
+}
+
+// pull the layer from the source registry and calculate the digest of its uncompressed content
+func (t *transfer) getDiffID(repository, blobSum string) (int64, digest.Digest, error) {
+	size, data, err := t.src.PullBlob(repository, blobSum)
+	if err != nil {
+		t.logger.Errorf("failed to pulling the blob %s: %v", blobSum, err)
+		return 0, "", err
+	}
+	defer data.Close()
+	reader, err := gzip.NewReader(data)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to decompress the blob %s: %v", blobSum, err)
+	}
+	defer reader.Close()
+	digester := digest.Canonical.Digester()
+	if _, err = io.Copy(digester.Hash(), reader); err != nil {
+		return 0, "", fmt.Errorf("failed to calculate the diff ID of the blob %s: %v", blobSum, err)
+	}
+	return size, digester.Digest(), nil
+}
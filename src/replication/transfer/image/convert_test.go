@@ -0,0 +1,224 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest"
+	"github.com/docker/distribution/manifest/schema1"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/docker/libtrust"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the source registry only serves the schema1 manifest and the destination
+// registry records the manifest and blobs pushed
+type fakeSchema1Registry struct {
+	manifest  *schema1.SignedManifest
+	blobs     map[string][]byte
+	mediaType string
+	payload   []byte
+	pushed    int
+	pulled    int
+}
+
+func (f *fakeSchema1Registry) FetchImages(context.Context, []*model.Filter) ([]*model.Resource, error) {
+	return nil, nil
+}
+func (f *fakeSchema1Registry) ManifestExist(repository, reference string) (bool, string, error) {
+	if len(f.payload) == 0 {
+		return false, "", nil
+	}
+	return true, digest.FromBytes(f.payload).String(), nil
+}
+func (f *fakeSchema1Registry) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	return f.manifest, "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7", nil
+}
+func (f *fakeSchema1Registry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	f.mediaType = mediaType
+	f.payload = payload
+	f.pushed++
+	return nil
+}
+func (f *fakeSchema1Registry) DeleteManifest(repository, reference string) error {
+	return nil
+}
+func (f *fakeSchema1Registry) BlobExist(repository, digest string) (bool, error) {
+	_, exist := f.blobs[digest]
+	return exist, nil
+}
+func (f *fakeSchema1Registry) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	f.pulled++
+	data := f.blobs[digest]
+	return int64(len(data)), ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+func (f *fakeSchema1Registry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	data, err := ioutil.ReadAll(blob)
+	if err != nil {
+		return err
+	}
+	f.blobs[digest] = data
+	return nil
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	_, err := w.Write(data)
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestCopySchema1Image(t *testing.T) {
+	base := gzipBytes(t, []byte("base layer"))
+	top := gzipBytes(t, []byte("top layer"))
+	empty := gzipBytes(t, []byte{})
+	// the layers and histories of schema1 manifest are in reverse order
+	m := &schema1.Manifest{
+		Versioned: manifest.Versioned{
+			SchemaVersion: 1,
+		},
+		Name:         "library/hello-world",
+		Tag:          "latest",
+		Architecture: "amd64",
+		FSLayers: []schema1.FSLayer{
+			{BlobSum: digest.FromBytes(empty)},
+			{BlobSum: digest.FromBytes(top)},
+			{BlobSum: digest.FromBytes(base)},
+		},
+		History: []schema1.History{
+			{V1Compatibility: `{"id":"3","parent":"2","created":"2019-01-03T00:00:00Z","throwaway":true,"container_config":{"Cmd":["/bin/sh","-c","#(nop) CMD [\"/hello\"]"]},"config":{"Cmd":["/hello"]},"architecture":"amd64","os":"linux"}`},
+			{V1Compatibility: `{"id":"2","parent":"1","created":"2019-01-02T00:00:00Z","container_config":{"Cmd":["/bin/sh","-c","#(nop) COPY file:abc in / "]}}`},
+			{V1Compatibility: `{"id":"1","created":"2019-01-01T00:00:00Z","container_config":{"Cmd":["/bin/sh","-c","#(nop) ADD file:def in / "]}}`},
+		},
+	}
+	key, err := libtrust.GenerateECP256PrivateKey()
+	require.Nil(t, err)
+	signed, err := schema1.Sign(m, key)
+	require.Nil(t, err)
+
+	src := &fakeSchema1Registry{
+		manifest: signed,
+		blobs: map[string][]byte{
+			digest.FromBytes(base).String():  base,
+			digest.FromBytes(top).String():   top,
+			digest.FromBytes(empty).String(): empty,
+		},
+	}
+	dst := &fakeSchema1Registry{
+		blobs: map[string][]byte{},
+	}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: func() bool { return false },
+		src:       src,
+		dst:       dst,
+	}
+	origin := conversions
+	conversions = &conversionCache{}
+	defer func() {
+		conversions = origin
+	}()
+	_, err = tr.copyImage("library/hello-world", "latest", "library/hello-world", "latest", true)
+	require.Nil(t, err)
+
+	// a valid schema2 manifest is pushed
+	require.Equal(t, schema2.MediaTypeManifest, dst.mediaType)
+	converted, _, err := distribution.UnmarshalManifest(dst.mediaType, dst.payload)
+	require.Nil(t, err)
+	mani, ok := converted.(*schema2.DeserializedManifest)
+	require.True(t, ok)
+	// the throwaway layer is dropped and the order is reversed
+	require.Equal(t, 2, len(mani.Layers))
+	assert.Equal(t, digest.FromBytes(base), mani.Layers[0].Digest)
+	assert.Equal(t, digest.FromBytes(top), mani.Layers[1].Digest)
+	assert.Equal(t, base, dst.blobs[mani.Layers[0].Digest.String()])
+	assert.Equal(t, top, dst.blobs[mani.Layers[1].Digest.String()])
+
+	// the image config is pushed
+	payload, exist := dst.blobs[mani.Config.Digest.String()]
+	require.True(t, exist)
+	assert.Equal(t, mani.Config.Digest, digest.FromBytes(payload))
+	config := &struct {
+		ID           string    `json:"id"`
+		Architecture string    `json:"architecture"`
+		RootFS       rootFS    `json:"rootfs"`
+		History      []history `json:"history"`
+		Config       struct {
+			Cmd []string `json:"Cmd"`
+		} `json:"config"`
+	}{}
+	require.Nil(t, json.Unmarshal(payload, config))
+	assert.Equal(t, "", config.ID)
+	assert.Equal(t, "amd64", config.Architecture)
+	assert.Equal(t, []string{"/hello"}, config.Config.Cmd)
+	assert.Equal(t, "layers", config.RootFS.Type)
+	assert.Equal(t, []digest.Digest{
+		digest.FromBytes([]byte("base layer")),
+		digest.FromBytes([]byte("top layer")),
+	}, config.RootFS.DiffIDs)
+	require.Equal(t, 3, len(config.History))
+	assert.False(t, config.History[0].EmptyLayer)
+	assert.True(t, config.History[2].EmptyLayer)
+	assert.Equal(t, "/bin/sh -c #(nop) ADD file:def in / ", config.History[0].CreatedBy)
+
+	// the converted image already exists on the destination registry, skip without
+	// pulling the layers again as the conversion is cached
+	pulled := src.pulled
+	dgt, err := tr.copyImage("library/hello-world", "latest", "library/hello-world", "latest", false)
+	require.Nil(t, err)
+	assert.Equal(t, digest.FromBytes(dst.payload).String(), dgt)
+	assert.Equal(t, 1, dst.pushed)
+	assert.Equal(t, pulled, src.pulled)
+
+	// the image is copied to another destination with the cached conversion
+	other := &fakeSchema1Registry{
+		blobs: map[string][]byte{},
+	}
+	tr.dst = other
+	_, err = tr.copyImage("library/hello-world", "latest", "library/hello-world", "latest", false)
+	require.Nil(t, err)
+	assert.Equal(t, dst.payload, other.payload)
+	assert.Equal(t, payload, other.blobs[mani.Config.Digest.String()])
+}
+
+func TestConversionCache(t *testing.T) {
+	cache := &conversionCache{}
+	assert.Nil(t, cache.get("sha256:1"))
+	for i := 0; i <= conversionCacheSize; i++ {
+		cache.add(fmt.Sprintf("sha256:%d", i), &conversion{
+			config: []byte{byte(i)},
+		})
+	}
+	// the oldest conversion is evicted
+	assert.Nil(t, cache.get("sha256:0"))
+	require.NotNil(t, cache.get("sha256:1"))
+	assert.Equal(t, []byte{1}, cache.get("sha256:1").config)
+	assert.Equal(t, conversionCacheSize, len(cache.conversions))
+}
@@ -0,0 +1,62 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"sync"
+)
+
+// the in-flight copy of one blob
+type blobCopy struct {
+	done chan struct{}
+	err  error
+}
+
+// blobDeduplicator makes sure the blob shared by the tags copied concurrently
+// is copied only once at a time: the callers coming during the copy wait for it
+// and share its result. The zero value is ready to use
+type blobDeduplicator struct {
+	sync.Mutex
+	copies map[string]*blobCopy
+}
+
+// do calls the "copy" for the key unless the copy of the same key is in
+// flight, in which case it waits for the in-flight one and returns its result
+func (b *blobDeduplicator) do(key string, copy func() error) error {
+	b.Lock()
+	if c, exist := b.copies[key]; exist {
+		b.Unlock()
+		<-c.done
+		return c.err
+	}
+	if b.copies == nil {
+		b.copies = map[string]*blobCopy{}
+	}
+	c := &blobCopy{
+		done: make(chan struct{}),
+	}
+	b.copies[key] = c
+	b.Unlock()
+
+	c.err = copy()
+
+	// the copied blob is skipped by the subsequent copies as it exists on
+	// the destination registry, so only the in-flight copies are kept
+	b.Lock()
+	delete(b.copies, key)
+	b.Unlock()
+	close(c.done)
+	return c.err
+}
@@ -0,0 +1,149 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobDeduplicator(t *testing.T) {
+	dedup := &blobDeduplicator{}
+	release := make(chan struct{})
+	calls := 0
+	copy := func() error {
+		calls++
+		<-release
+		return errors.New("error")
+	}
+
+	// the concurrent copies of the same blob share the result of the in-flight one
+	wg := &sync.WaitGroup{}
+	errs := make([]error, 3)
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = dedup.do("library/hello-world@sha256:1", copy)
+		}(i)
+	}
+	// wait until all the callers come
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, 1, calls)
+	for _, err := range errs {
+		assert.NotNil(t, err)
+	}
+
+	// the copy done isn't kept
+	require.NotNil(t, dedup.do("library/hello-world@sha256:1", copy))
+	assert.Equal(t, 2, calls)
+}
+
+// all the tags reference the same blobs. The blobs pushed are recorded and
+// the pulling of manifests is slow, so the copies of the tags overlap
+type fakeConcurrentRegistry struct {
+	fakeRegistry
+	lock        sync.Mutex
+	blobs       map[string]bool
+	pulls       map[string]int
+	inflight    int
+	maxInflight int
+}
+
+func (f *fakeConcurrentRegistry) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	f.lock.Lock()
+	f.inflight++
+	if f.inflight > f.maxInflight {
+		f.maxInflight = f.inflight
+	}
+	f.lock.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	f.lock.Lock()
+	f.inflight--
+	f.lock.Unlock()
+	return f.fakeRegistry.PullManifest(repository, reference, accepttedMediaTypes)
+}
+
+func (f *fakeConcurrentRegistry) BlobExist(repository, digest string) (bool, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.blobs[digest], nil
+}
+
+func (f *fakeConcurrentRegistry) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	f.lock.Lock()
+	f.pulls[digest]++
+	f.lock.Unlock()
+	// slow down the pulling so the copies of other tags come during it
+	time.Sleep(50 * time.Millisecond)
+	return 1, ioutil.NopCloser(bytes.NewReader([]byte{'a'})), nil
+}
+
+func (f *fakeConcurrentRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.blobs[digest] = true
+	return nil
+}
+
+func TestCopyWithTagConcurrency(t *testing.T) {
+	registry := &fakeConcurrentRegistry{
+		blobs: map[string]bool{},
+		pulls: map[string]int{},
+	}
+	tr := &transfer{
+		logger:         log.DefaultLogger(),
+		isStopped:      func() bool { return false },
+		src:            registry,
+		dst:            registry,
+		tagConcurrency: 3,
+	}
+	tags := []string{"t1", "t2", "t3", "t4", "t5", "t6"}
+	src := &repository{
+		repository: "source",
+		tags:       tags,
+	}
+	dst := &repository{
+		repository: "destination",
+		tags:       tags,
+	}
+	require.Nil(t, tr.copy(src, dst, true, false))
+
+	// the tags are copied concurrently within the bound
+	assert.True(t, registry.maxInflight > 1)
+	assert.True(t, registry.maxInflight <= 3)
+	// all the tags are copied
+	for _, tag := range tags {
+		_, exist := registry.manifests["destination:"+tag]
+		assert.True(t, exist)
+	}
+	// the shared blobs are pulled only once
+	assert.Equal(t, 4, len(registry.pulls))
+	for digest, count := range registry.pulls {
+		assert.Equal(t, 1, count, "the blob %s is pulled %d times", digest, count)
+	}
+}
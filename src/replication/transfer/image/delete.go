@@ -0,0 +1,73 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"github.com/goharbor/harbor/src/replication/adapter"
+)
+
+// delete the tag from the registry and return whether it is deleted. The registries
+// that can't delete only the tag delete the manifest by digest, which removes all the
+// tags referring to it, so the deletion is skipped if any tag not in "deleting" refers
+// to the same manifest
+func (t *transfer) deleteTag(registry adapter.ImageRegistry, repository, tag string, deleting []string) (bool, error) {
+	if deleter, ok := registry.(adapter.TagDeleter); ok {
+		if err := deleter.DeleteTag(repository, tag); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	lister, ok := registry.(adapter.TagLister)
+	if !ok {
+		t.logger.Warningf("the registry supports neither deleting the tag nor listing the tags, skip deleting %s:%s as other tags may refer to the same manifest",
+			repository, tag)
+		return false, nil
+	}
+	exist, digest, err := registry.ManifestExist(repository, tag)
+	if err != nil {
+		return false, err
+	}
+	if !exist {
+		t.logger.Infof("the image %s:%s doesn't exist, skip deleting it", repository, tag)
+		return false, nil
+	}
+	tags, err := lister.ListTag(repository)
+	if err != nil {
+		return false, err
+	}
+	excluded := map[string]struct{}{}
+	for _, d := range deleting {
+		excluded[d] = struct{}{}
+	}
+	for _, other := range tags {
+		if _, exist := excluded[other]; exist || other == tag {
+			continue
+		}
+		exist, dgt, err := registry.ManifestExist(repository, other)
+		if err != nil {
+			return false, err
+		}
+		if exist && dgt == digest {
+			t.logger.Warningf("the tag %s:%s refers to the same manifest %s, skip deleting %s:%s as the manifest and all its tags would be deleted",
+				repository, other, digest, repository, tag)
+			return false, nil
+		}
+	}
+	if err = registry.DeleteManifest(repository, digest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
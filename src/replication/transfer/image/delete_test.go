@@ -0,0 +1,74 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"testing"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the registry deletes only the tag
+type fakedTagDeletingRegistry struct {
+	fakedTagRegistry
+}
+
+func (f *fakedTagDeletingRegistry) DeleteTag(repository, tag string) error {
+	delete(f.tags, tag)
+	return nil
+}
+
+func TestDeleteTag(t *testing.T) {
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: func() bool { return false },
+	}
+	newTags := func() map[string]string {
+		return map[string]string{
+			"1.0":    subjectA,
+			"latest": subjectA,
+			"2.0":    subjectB,
+		}
+	}
+
+	// the manifest is referred by another tag not being deleted, skip
+	registry := &fakedTagRegistry{tags: newTags()}
+	deleted, err := tr.deleteTag(registry, "destination", "1.0", []string{"1.0"})
+	require.Nil(t, err)
+	assert.False(t, deleted)
+	assert.Equal(t, 3, len(registry.tags))
+
+	// all the tags referring to the manifest are being deleted
+	deleted, err = tr.deleteTag(registry, "destination", "1.0", []string{"1.0", "latest"})
+	require.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, map[string]string{"2.0": subjectB}, registry.tags)
+
+	// the registry supports deleting only the tag
+	tagDeleting := &fakedTagDeletingRegistry{
+		fakedTagRegistry: fakedTagRegistry{tags: newTags()},
+	}
+	deleted, err = tr.deleteTag(tagDeleting, "destination", "1.0", []string{"1.0"})
+	require.Nil(t, err)
+	assert.True(t, deleted)
+	assert.Equal(t, map[string]string{"latest": subjectA, "2.0": subjectB}, tagDeleting.tags)
+
+	// the registry can neither delete only the tag nor list the tags, skip
+	deleted, err = tr.deleteTag(&fakeRegistry{}, "destination", "1.0", []string{"1.0"})
+	require.Nil(t, err)
+	assert.False(t, deleted)
+}
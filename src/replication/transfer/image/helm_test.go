@@ -0,0 +1,196 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ociManifest struct {
+	mediaType string
+	payload   []byte
+}
+
+// an in-memory OCI registry, the manifests are only served to the
+// clients accepting their media types as the OCI registries do
+type ociRegistry struct {
+	sync.Mutex
+	manifests map[string]*ociManifest
+	blobs     map[string][]byte
+	pushed    int
+}
+
+func newOCIRegistry() *ociRegistry {
+	return &ociRegistry{
+		manifests: map[string]*ociManifest{},
+		blobs:     map[string][]byte{},
+	}
+}
+
+func (o *ociRegistry) putManifest(repository, reference, mediaType string, payload []byte) string {
+	dgt := digest.FromBytes(payload).String()
+	manifest := &ociManifest{
+		mediaType: mediaType,
+		payload:   payload,
+	}
+	o.manifests[repository+":"+reference] = manifest
+	o.manifests[repository+":"+dgt] = manifest
+	return dgt
+}
+
+func (o *ociRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	o.Lock()
+	defer o.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/upload/"):
+		data, _ := ioutil.ReadAll(r.Body)
+		o.blobs[r.URL.Query().Get("digest")] = data
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/manifests/"):
+		i := strings.Index(path, "/manifests/")
+		repository, reference := path[:i], path[i+len("/manifests/"):]
+		if r.Method == http.MethodPut {
+			payload, _ := ioutil.ReadAll(r.Body)
+			o.pushed++
+			w.Header().Set("Docker-Content-Digest",
+				o.putManifest(repository, reference, r.Header.Get("Content-Type"), payload))
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		manifest, exist := o.manifests[repository+":"+reference]
+		if !exist || !strings.Contains(strings.Join(r.Header["Accept"], ","), manifest.mediaType) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.Header().Set("Docker-Content-Digest", digest.FromBytes(manifest.payload).String())
+		if r.Method == http.MethodGet {
+			w.Write(manifest.payload)
+		}
+	case strings.HasSuffix(path, "/blobs/uploads/"):
+		w.Header().Set("Location", "/upload/"+path)
+		w.WriteHeader(http.StatusAccepted)
+	case strings.Contains(path, "/blobs/"):
+		blob, exist := o.blobs[path[strings.Index(path, "/blobs/")+len("/blobs/"):]]
+		if !exist {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		if r.Method == http.MethodGet {
+			w.Write(blob)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// the default image registry doesn't implement the "FetchImages" of ImageRegistry
+type ociImageRegistry struct {
+	*adapter.DefaultImageRegistry
+}
+
+func (o *ociImageRegistry) FetchImages(context.Context, []*model.Filter) ([]*model.Resource, error) {
+	return nil, nil
+}
+
+func TestCopyHelmChartStoredAsOCIArtifact(t *testing.T) {
+	config := []byte(`{"name":"mychart","version":"0.1.0","apiVersion":"v2"}`)
+	content := []byte("chart")
+	manifest, err := json.Marshal(&adapter.OCIManifest{
+		SchemaVersion: 2,
+		Config: distribution.Descriptor{
+			MediaType: adapter.MediaTypeHelmChartConfig,
+			Digest:    digest.FromBytes(config),
+			Size:      int64(len(config)),
+		},
+		Layers: []distribution.Descriptor{
+			{
+				MediaType: adapter.MediaTypeHelmChartContent,
+				Digest:    digest.FromBytes(content),
+				Size:      int64(len(content)),
+			},
+		},
+	})
+	require.Nil(t, err)
+
+	src := newOCIRegistry()
+	src.blobs[digest.FromBytes(config).String()] = config
+	src.blobs[digest.FromBytes(content).String()] = content
+	dgt := src.putManifest("charts/mychart", "0.1.0", adapter.MediaTypeOCIManifest, manifest)
+	dst := newOCIRegistry()
+	srcServer := httptest.NewServer(src)
+	defer srcServer.Close()
+	dstServer := httptest.NewServer(dst)
+	defer dstServer.Close()
+
+	srcRegistry, err := adapter.NewDefaultImageRegistry(&model.Registry{URL: srcServer.URL})
+	require.Nil(t, err)
+	dstRegistry, err := adapter.NewDefaultImageRegistry(&model.Registry{URL: dstServer.URL})
+	require.Nil(t, err)
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: func() bool { return false },
+		src:       &ociImageRegistry{srcRegistry},
+		dst:       &ociImageRegistry{dstRegistry},
+	}
+	pulled, _, err := srcRegistry.PullManifest("charts/mychart", "0.1.0", []string{adapter.MediaTypeOCIManifest})
+	require.Nil(t, err)
+	assert.True(t, adapter.IsHelmChart(pulled))
+
+	err = tr.copy(&repository{
+		repository: "charts/mychart",
+		tags:       []string{"0.1.0"},
+	}, &repository{
+		repository: "mirror/mychart",
+		tags:       []string{"0.1.0"},
+	}, false, false)
+	require.Nil(t, err)
+	// the chart content and config are copied and the manifest keeps its digest
+	assert.Equal(t, config, dst.blobs[digest.FromBytes(config).String()])
+	assert.Equal(t, content, dst.blobs[digest.FromBytes(content).String()])
+	copied, exist := dst.manifests["mirror/mychart:0.1.0"]
+	require.True(t, exist)
+	assert.Equal(t, adapter.MediaTypeOCIManifest, copied.mediaType)
+	assert.Equal(t, dgt, digest.FromBytes(copied.payload).String())
+
+	// the chart already exists on the destination registry, it isn't pushed again
+	err = tr.copy(&repository{
+		repository: "charts/mychart",
+		tags:       []string{"0.1.0"},
+	}, &repository{
+		repository: "mirror/mychart",
+		tags:       []string{"0.1.0"},
+	}, false, false)
+	require.Nil(t, err)
+	assert.Equal(t, 1, dst.pushed)
+}
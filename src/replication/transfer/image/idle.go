@@ -0,0 +1,89 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// the count of the retries when the copy of the blob is aborted by the idle timeout
+const idleTimeoutRetries = 2
+
+var errIdleTimeout = errors.New("no bytes transferred within the idle timeout")
+
+// idleTimeoutReader closes the underlying reader to abort the transfer
+// if no bytes are read within the timeout. It covers both the stalled source
+// (the read blocks) and the stalled destination (nobody reads the stream or
+// no response after reading it), the "aborted" is closed when the timeout is
+// reached as closing the reader doesn't unblock the writer of the destination
+type idleTimeoutReader struct {
+	reader   io.ReadCloser
+	timeout  time.Duration
+	timer    *time.Timer
+	lock     sync.Mutex
+	timedOut bool
+	aborted  chan struct{}
+}
+
+func newIdleTimeoutReader(reader io.ReadCloser, timeout time.Duration) *idleTimeoutReader {
+	r := &idleTimeoutReader{
+		reader:  reader,
+		timeout: timeout,
+		aborted: make(chan struct{}),
+	}
+	r.timer = time.AfterFunc(timeout, r.abort)
+	return r
+}
+
+func (i *idleTimeoutReader) abort() {
+	i.lock.Lock()
+	// the timer may be reset by the read racing with the firing
+	if i.timedOut {
+		i.lock.Unlock()
+		return
+	}
+	i.timedOut = true
+	close(i.aborted)
+	i.lock.Unlock()
+	i.reader.Close()
+}
+
+func (i *idleTimeoutReader) isTimedOut() bool {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+	return i.timedOut
+}
+
+func (i *idleTimeoutReader) Read(p []byte) (int, error) {
+	n, err := i.reader.Read(p)
+	if i.isTimedOut() {
+		return n, errIdleTimeout
+	}
+	if n > 0 {
+		i.timer.Reset(i.timeout)
+	}
+	return n, err
+}
+
+func (i *idleTimeoutReader) Close() error {
+	// the underlying reader is closed by the abort if the timer fired already
+	if !i.timer.Stop() {
+		return nil
+	}
+	return i.reader.Close()
+}
@@ -0,0 +1,185 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the reader returns one byte and then stalls until being closed
+type stalledReader struct {
+	once   sync.Once
+	closed chan struct{}
+	read   bool
+}
+
+func newStalledReader() *stalledReader {
+	return &stalledReader{
+		closed: make(chan struct{}),
+	}
+}
+
+func (s *stalledReader) Read(p []byte) (int, error) {
+	if !s.read {
+		s.read = true
+		p[0] = 'a'
+		return 1, nil
+	}
+	<-s.closed
+	return 0, errors.New("read on closed reader")
+}
+
+func (s *stalledReader) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestIdleTimeoutReader(t *testing.T) {
+	// stalled
+	stalled := newStalledReader()
+	reader := newIdleTimeoutReader(stalled, 100*time.Millisecond)
+	start := time.Now()
+	_, err := ioutil.ReadAll(reader)
+	assert.Equal(t, errIdleTimeout, err)
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Nil(t, reader.Close())
+
+	// completed
+	reader = newIdleTimeoutReader(ioutil.NopCloser(&fixedReader{data: []byte("abc")}), time.Second)
+	data, err := ioutil.ReadAll(reader)
+	require.Nil(t, err)
+	assert.Equal(t, "abc", string(data))
+	assert.Nil(t, reader.Close())
+	assert.False(t, reader.isTimedOut())
+}
+
+type fixedReader struct {
+	data []byte
+}
+
+func (f *fixedReader) Read(p []byte) (int, error) {
+	if len(f.data) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, f.data)
+	f.data = f.data[n:]
+	return n, nil
+}
+
+// the source stalls for the first "stalls" pulls
+type fakeStalledRegistry struct {
+	fakeRegistry
+	stalls int
+	pulls  int
+}
+
+func (f *fakeStalledRegistry) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	f.pulls++
+	if f.pulls <= f.stalls {
+		return 2, newStalledReader(), nil
+	}
+	return 2, ioutil.NopCloser(&fixedReader{data: []byte("ab")}), nil
+}
+
+func (f *fakeStalledRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	_, err := ioutil.ReadAll(blob)
+	return err
+}
+
+func TestCopyBlobWithIdleTimeout(t *testing.T) {
+	stopFunc := func() bool { return false }
+
+	// aborted and retried
+	registry := &fakeStalledRegistry{stalls: 1}
+	tr := &transfer{
+		logger:      log.DefaultLogger(),
+		isStopped:   stopFunc,
+		src:         registry,
+		dst:         registry,
+		idleTimeout: 100 * time.Millisecond,
+	}
+	require.Nil(t, tr.copyBlob("source", "destination", "sha256:1"))
+	assert.Equal(t, 2, registry.pulls)
+
+	// always stalled, fail after the retries
+	registry = &fakeStalledRegistry{stalls: idleTimeoutRetries + 1}
+	tr.src = registry
+	tr.dst = registry
+	assert.Equal(t, errIdleTimeout, tr.copyBlob("source", "destination", "sha256:1"))
+	assert.Equal(t, idleTimeoutRetries+1, registry.pulls)
+}
+
+// the destination reads the blob but doesn't respond for the first "stalls" pushes
+type fakeStalledDestinationRegistry struct {
+	fakeRegistry
+	stalls  int
+	pushes  int
+	release chan struct{}
+}
+
+func (f *fakeStalledDestinationRegistry) PullBlob(repository, digest string) (int64, io.ReadCloser, error) {
+	return 2, ioutil.NopCloser(&fixedReader{data: []byte("ab")}), nil
+}
+
+func (f *fakeStalledDestinationRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	f.Lock()
+	f.pushes++
+	stalled := f.pushes <= f.stalls
+	f.Unlock()
+	if _, err := ioutil.ReadAll(blob); err != nil {
+		return err
+	}
+	if stalled {
+		<-f.release
+		return errors.New("connection reset by peer")
+	}
+	return nil
+}
+
+func TestCopyBlobWithIdleTimeoutOfDestination(t *testing.T) {
+	registry := &fakeStalledDestinationRegistry{
+		stalls:  1,
+		release: make(chan struct{}),
+	}
+	defer close(registry.release)
+	tr := &transfer{
+		logger:      log.DefaultLogger(),
+		isStopped:   func() bool { return false },
+		src:         registry,
+		dst:         registry,
+		idleTimeout: 100 * time.Millisecond,
+	}
+
+	// aborted and retried
+	start := time.Now()
+	require.Nil(t, tr.copyBlob("source", "destination", "sha256:1"))
+	assert.True(t, time.Since(start) < 5*time.Second)
+	assert.Equal(t, 2, registry.pushes)
+
+	// always stalled, fail after the retries
+	registry.pushes = 0
+	registry.stalls = idleTimeoutRetries + 1
+	assert.Equal(t, errIdleTimeout, tr.copyBlob("source", "destination", "sha256:2"))
+	assert.Equal(t, idleTimeoutRetries+1, registry.pushes)
+}
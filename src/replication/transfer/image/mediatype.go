@@ -0,0 +1,126 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"encoding/json"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	godigest "github.com/opencontainers/go-digest"
+)
+
+// the media types of the OCI image config and layers
+const (
+	mediaTypeOCIImageConfig           = "application/vnd.oci.image.config.v1+json"
+	mediaTypeOCILayer                 = "application/vnd.oci.image.layer.v1.tar+gzip"
+	mediaTypeOCINondistributableLayer = "application/vnd.oci.image.layer.nondistributable.v1.tar+gzip"
+)
+
+// the OCI equivalents of the Docker media types of the config and layers, the bytes
+// of the blobs are the same so their digests are kept after the translation
+var dockerToOCIMediaTypes = map[string]string{
+	schema2.MediaTypeImageConfig:  mediaTypeOCIImageConfig,
+	schema2.MediaTypeLayer:        mediaTypeOCILayer,
+	schema2.MediaTypeForeignLayer: mediaTypeOCINondistributableLayer,
+}
+
+var ociToDockerMediaTypes = map[string]string{
+	mediaTypeOCIImageConfig:           schema2.MediaTypeImageConfig,
+	mediaTypeOCILayer:                 schema2.MediaTypeLayer,
+	mediaTypeOCINondistributableLayer: schema2.MediaTypeForeignLayer,
+}
+
+// translate the media types of the manifest and the blobs it references into the
+// Docker or OCI equivalents specified by the "target". Only the image manifests whose
+// media types all have the equivalents are translated, the OCI artifacts(with the
+// subject or artifact type) and the manifests with annotations which the Docker
+// manifest cannot carry are kept as they are. Returns whether the manifest is
+// translated, the translated one has a different digest as its payload is changed
+func translateMediaTypes(manifest distribution.Manifest, target string) (distribution.Manifest, bool, error) {
+	switch target {
+	case model.MediaTypeTranslationDocker:
+		m, ok := manifest.(*adapter.OCIManifest)
+		if !ok || m.Subject != nil || len(m.ArtifactType) > 0 || len(m.Annotations) > 0 {
+			return manifest, false, nil
+		}
+		config, layers, ok := translateDescriptors(m.Config, m.Layers, ociToDockerMediaTypes)
+		if !ok {
+			return manifest, false, nil
+		}
+		translated, err := schema2.FromStruct(schema2.Manifest{
+			Versioned: schema2.SchemaVersion,
+			Config:    config,
+			Layers:    layers,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		return translated, true, nil
+	case model.MediaTypeTranslationOCI:
+		m, ok := manifest.(*schema2.DeserializedManifest)
+		if !ok {
+			return manifest, false, nil
+		}
+		config, layers, ok := translateDescriptors(m.Config, m.Layers, dockerToOCIMediaTypes)
+		if !ok {
+			return manifest, false, nil
+		}
+		payload, err := json.Marshal(&adapter.OCIManifest{
+			SchemaVersion: 2,
+			MediaType:     adapter.MediaTypeOCIManifest,
+			Config:        config,
+			Layers:        layers,
+		})
+		if err != nil {
+			return nil, false, err
+		}
+		translated, _, err := distribution.UnmarshalManifest(adapter.MediaTypeOCIManifest, payload)
+		if err != nil {
+			return nil, false, err
+		}
+		return translated, true, nil
+	}
+	return manifest, false, nil
+}
+
+// translate the media types of the config and layers by the mapping, returns false
+// if any of them has no equivalent
+func translateDescriptors(config distribution.Descriptor, layers []distribution.Descriptor,
+	mapping map[string]string) (distribution.Descriptor, []distribution.Descriptor, bool) {
+	mediaType, ok := mapping[config.MediaType]
+	if !ok {
+		return config, nil, false
+	}
+	config.MediaType = mediaType
+	translated := make([]distribution.Descriptor, 0, len(layers))
+	for _, layer := range layers {
+		mediaType, ok := mapping[layer.MediaType]
+		if !ok {
+			return config, nil, false
+		}
+		layer.MediaType = mediaType
+		translated = append(translated, layer)
+	}
+	return config, translated, true
+}
+
+// whether the reference is a digest rather than a tag
+func isDigest(reference string) bool {
+	_, err := godigest.Parse(reference)
+	return err == nil
+}
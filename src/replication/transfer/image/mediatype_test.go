@@ -0,0 +1,167 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/adapter"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	ociConfigDigest = "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"
+	ociLayerDigest  = "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f"
+)
+
+func newOCIManifest(t *testing.T, layerMediaType string, subject *distribution.Descriptor) distribution.Manifest {
+	payload, err := json.Marshal(&adapter.OCIManifest{
+		SchemaVersion: 2,
+		MediaType:     adapter.MediaTypeOCIManifest,
+		Config: distribution.Descriptor{
+			MediaType: mediaTypeOCIImageConfig,
+			Size:      7023,
+			Digest:    ociConfigDigest,
+		},
+		Layers: []distribution.Descriptor{
+			{
+				MediaType: layerMediaType,
+				Size:      32654,
+				Digest:    ociLayerDigest,
+			},
+		},
+		Subject: subject,
+	})
+	require.Nil(t, err)
+	manifest, _, err := distribution.UnmarshalManifest(adapter.MediaTypeOCIManifest, payload)
+	require.Nil(t, err)
+	return manifest
+}
+
+func TestTranslateMediaTypes(t *testing.T) {
+	// OCI to Docker, the digests of the blobs are kept
+	manifest, translated, err := translateMediaTypes(newOCIManifest(t, mediaTypeOCILayer, nil), model.MediaTypeTranslationDocker)
+	require.Nil(t, err)
+	require.True(t, translated)
+	m, ok := manifest.(*schema2.DeserializedManifest)
+	require.True(t, ok)
+	assert.Equal(t, schema2.MediaTypeImageConfig, m.Config.MediaType)
+	assert.Equal(t, ociConfigDigest, m.Config.Digest.String())
+	require.Equal(t, 1, len(m.Layers))
+	assert.Equal(t, schema2.MediaTypeLayer, m.Layers[0].MediaType)
+	assert.Equal(t, ociLayerDigest, m.Layers[0].Digest.String())
+
+	// and back to OCI
+	manifest, translated, err = translateMediaTypes(m, model.MediaTypeTranslationOCI)
+	require.Nil(t, err)
+	require.True(t, translated)
+	o, ok := manifest.(*adapter.OCIManifest)
+	require.True(t, ok)
+	assert.Equal(t, mediaTypeOCIImageConfig, o.Config.MediaType)
+	require.Equal(t, 1, len(o.Layers))
+	assert.Equal(t, mediaTypeOCILayer, o.Layers[0].MediaType)
+
+	// the layer without Docker equivalent isn't translated
+	original := newOCIManifest(t, "application/vnd.oci.image.layer.v1.tar+zstd", nil)
+	manifest, translated, err = translateMediaTypes(original, model.MediaTypeTranslationDocker)
+	require.Nil(t, err)
+	assert.False(t, translated)
+	assert.True(t, original == manifest)
+
+	// the OCI artifact isn't translated
+	original = newOCIManifest(t, mediaTypeOCILayer, &distribution.Descriptor{
+		MediaType: schema2.MediaTypeManifest,
+		Digest:    "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7",
+	})
+	_, translated, err = translateMediaTypes(original, model.MediaTypeTranslationDocker)
+	require.Nil(t, err)
+	assert.False(t, translated)
+
+	// the manifest is already the target one
+	_, translated, err = translateMediaTypes(m, model.MediaTypeTranslationDocker)
+	require.Nil(t, err)
+	assert.False(t, translated)
+}
+
+// the source registry serves the OCI image manifest
+type fakeOCIRegistry struct {
+	fakeRegistry
+	manifest distribution.Manifest
+}
+
+func (f *fakeOCIRegistry) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	digest, err := manifestDigest(f.manifest)
+	return f.manifest, digest, err
+}
+
+// the destination registry records the media types of the pushed manifests
+type fakeMediaTypeRecordingRegistry struct {
+	fakeRegistry
+	mediaTypes map[string]string
+}
+
+func (f *fakeMediaTypeRecordingRegistry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	if f.mediaTypes == nil {
+		f.mediaTypes = map[string]string{}
+	}
+	f.mediaTypes[reference] = mediaType
+	return f.fakeRegistry.PushManifest(repository, reference, mediaType, payload)
+}
+
+func TestCopyImageWithMediaTypeTranslation(t *testing.T) {
+	src := &fakeOCIRegistry{
+		manifest: newOCIManifest(t, mediaTypeOCILayer, nil),
+	}
+	srcDigest, err := manifestDigest(src.manifest)
+	require.Nil(t, err)
+	dst := &fakeMediaTypeRecordingRegistry{}
+	tr := &transfer{
+		logger:               log.DefaultLogger(),
+		isStopped:            func() bool { return false },
+		src:                  src,
+		dst:                  dst,
+		mediaTypeTranslation: model.MediaTypeTranslationDocker,
+	}
+
+	// the OCI media types are translated to the Docker ones on push, the
+	// digest of the manifest is recomputed
+	digest, err := tr.copyImage("source", "a1", "destination", "a1", true)
+	require.Nil(t, err)
+	assert.Equal(t, schema2.MediaTypeManifest, dst.mediaTypes["a1"])
+	assert.NotEqual(t, srcDigest, digest)
+	assert.Equal(t, digest, dst.manifests["destination:a1"])
+
+	// the manifest copied by digest isn't translated
+	_, err = tr.copyImage("source", srcDigest, "destination", srcDigest, true)
+	require.Nil(t, err)
+	assert.Equal(t, adapter.MediaTypeOCIManifest, dst.mediaTypes[srcDigest])
+	assert.Equal(t, srcDigest, dst.manifests["destination:"+srcDigest])
+
+	// no translation
+	dst = &fakeMediaTypeRecordingRegistry{}
+	tr.dst = dst
+	tr.mediaTypeTranslation = ""
+	digest, err = tr.copyImage("source", "a1", "destination", "a1", true)
+	require.Nil(t, err)
+	assert.Equal(t, adapter.MediaTypeOCIManifest, dst.mediaTypes["a1"])
+	assert.Equal(t, srcDigest, digest)
+	assert.Equal(t, srcDigest, dst.manifests["destination:a1"])
+}
@@ -0,0 +1,74 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"regexp"
+
+	"github.com/goharbor/harbor/src/replication/adapter"
+)
+
+// the tags of the signatures, attestations and SBOMs attached by cosign, e.g.
+// "sha256-<hex>.sig", the subject image is the manifest of the digest "sha256:<hex>"
+var cosignTagPattern = regexp.MustCompile(`^(sha256)-([a-f0-9]{64})\.(sig|att|sbom)$`)
+
+// returns the digest of the subject image if the tag is attached by cosign
+func getCosignSubject(tag string) (string, bool) {
+	matches := cosignTagPattern.FindStringSubmatch(tag)
+	if matches == nil {
+		return "", false
+	}
+	return matches[1] + ":" + matches[2], true
+}
+
+// delete the cosign tags under the repository on the destination registry whose subject
+// images no longer exist. The tags whose subjects cannot be checked are kept, and the
+// failure of the cleanup is only logged as the deletion itself is done
+func (t *transfer) cleanupOrphans(repository string) {
+	if t.shouldStop() {
+		return
+	}
+	lister, ok := t.dst.(adapter.TagLister)
+	if !ok {
+		t.logger.Warningf("the destination registry doesn't support listing the tags, skip cleaning up the orphaned signatures under %s", repository)
+		return
+	}
+	tags, err := lister.ListTag(repository)
+	if err != nil {
+		t.logger.Warningf("failed to list the tags under %s on the destination registry, skip cleaning up the orphaned signatures: %v",
+			repository, err)
+		return
+	}
+	for _, tag := range tags {
+		subject, ok := getCosignSubject(tag)
+		if !ok {
+			continue
+		}
+		exist, _, err := t.dst.ManifestExist(repository, subject)
+		if err != nil {
+			t.logger.Warningf("failed to check the existence of the subject %s of %s:%s, keep it: %v",
+				subject, repository, tag, err)
+			continue
+		}
+		if exist {
+			continue
+		}
+		if err = t.dst.DeleteManifest(repository, tag); err != nil {
+			t.logger.Warningf("failed to delete the orphaned signature %s:%s: %v", repository, tag, err)
+			continue
+		}
+		t.logger.Infof("the orphaned signature %s:%s of the deleted image %s is deleted", repository, tag, subject)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	subjectA = "sha256:aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	subjectB = "sha256:bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	subjectC = "sha256:cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+)
+
+func cosignTag(subject, suffix string) string {
+	return strings.Replace(subject, ":", "-", 1) + "." + suffix
+}
+
+// the tags under the repository "destination" indexed by the names, the values are
+// the digests. The existence of the digest "brokenDigest" cannot be checked
+type fakedTagRegistry struct {
+	fakeRegistry
+	tags         map[string]string
+	brokenDigest string
+}
+
+func (f *fakedTagRegistry) ListTag(repository string) ([]string, error) {
+	var tags []string
+	for tag := range f.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+func (f *fakedTagRegistry) ManifestExist(repository, reference string) (bool, string, error) {
+	if reference == f.brokenDigest {
+		return false, "", errors.New("error")
+	}
+	for tag, digest := range f.tags {
+		if tag == reference || digest == reference {
+			return true, digest, nil
+		}
+	}
+	return false, "", nil
+}
+
+// the manifest deleted by digest removes all the tags referring to it
+func (f *fakedTagRegistry) DeleteManifest(repository, reference string) error {
+	for tag, digest := range f.tags {
+		if tag == reference || digest == reference {
+			delete(f.tags, tag)
+		}
+	}
+	return nil
+}
+
+func newFakedTagRegistry() *fakedTagRegistry {
+	return &fakedTagRegistry{
+		tags: map[string]string{
+			"1.0":                      subjectA,
+			"2.0":                      subjectB,
+			cosignTag(subjectA, "sig"): "sha256:01",
+			cosignTag(subjectA, "att"): "sha256:02",
+			cosignTag(subjectB, "sig"): "sha256:03",
+		},
+	}
+}
+
+func TestGetCosignSubject(t *testing.T) {
+	subject, ok := getCosignSubject(cosignTag(subjectA, "sig"))
+	require.True(t, ok)
+	assert.Equal(t, subjectA, subject)
+	subject, ok = getCosignSubject(cosignTag(subjectB, "sbom"))
+	require.True(t, ok)
+	assert.Equal(t, subjectB, subject)
+
+	for _, tag := range []string{"latest", "sha256-abc.sig", cosignTag(subjectA, "txt"), "v" + cosignTag(subjectA, "sig")} {
+		_, ok = getCosignSubject(tag)
+		assert.False(t, ok, tag)
+	}
+}
+
+func TestDeleteWithOrphanedSignaturesCleanup(t *testing.T) {
+	repo := &repository{
+		repository: "destination",
+		tags:       []string{"1.0"},
+	}
+
+	// the signatures are left by default
+	dst := newFakedTagRegistry()
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: func() bool { return false },
+		dst:       dst,
+	}
+	require.Nil(t, tr.delete(repo))
+	tags, _ := dst.ListTag("destination")
+	assert.Equal(t, []string{"2.0", cosignTag(subjectA, "att"), cosignTag(subjectA, "sig"), cosignTag(subjectB, "sig")}, tags)
+
+	// the orphaned signatures of the deleted image are cleaned up, the one
+	// of the image still existing is kept
+	dst = newFakedTagRegistry()
+	tr.dst = dst
+	tr.cleanupOrphanedSignatures = true
+	require.Nil(t, tr.delete(repo))
+	tags, _ = dst.ListTag("destination")
+	assert.Equal(t, []string{"2.0", cosignTag(subjectB, "sig")}, tags)
+
+	// the signature whose subject cannot be checked is kept
+	dst = newFakedTagRegistry()
+	dst.tags[cosignTag(subjectC, "sig")] = "sha256:04"
+	dst.brokenDigest = subjectC
+	tr.dst = dst
+	require.Nil(t, tr.delete(repo))
+	tags, _ = dst.ListTag("destination")
+	assert.Equal(t, []string{"2.0", cosignTag(subjectB, "sig"), cosignTag(subjectC, "sig")}, tags)
+
+	// the destination registry cannot list the tags
+	tr.dst = &fakeRegistry{}
+	assert.Nil(t, tr.delete(repo))
+}
@@ -0,0 +1,116 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+)
+
+// copy the manifest list reduced to the sub-manifests specified by the digests, which
+// are selected by the platform filter. The sub-manifests are copied by digest before
+// pushing the reduced manifest list referencing them
+func (t *transfer) copyReducedManifestList(srcRepo, srcRef, dstRepo, dstRef string,
+	digests []string, override bool) (string, error) {
+	if t.shouldStop() {
+		return "", nil
+	}
+	t.logger.Infof("copying %s:%s(source registry) reduced to the manifests %v to %s:%s(destination registry)...",
+		srcRepo, srcRef, digests, dstRepo, dstRef)
+	manifest, _, err := t.src.PullManifest(srcRepo, srcRef, []string{manifestlist.MediaTypeManifestList})
+	if err != nil {
+		t.logger.Errorf("failed to pull the manifest list of %s:%s: %v", srcRepo, srcRef, err)
+		return "", err
+	}
+	list, ok := manifest.(*manifestlist.DeserializedManifestList)
+	if !ok {
+		err = fmt.Errorf("the manifest of %s:%s isn't a manifest list anymore", srcRepo, srcRef)
+		t.logger.Errorf(err.Error())
+		return "", err
+	}
+	selected := map[string]struct{}{}
+	for _, digest := range digests {
+		selected[digest] = struct{}{}
+	}
+	var descriptors []manifestlist.ManifestDescriptor
+	for _, descriptor := range list.Manifests {
+		if _, exist := selected[descriptor.Digest.String()]; exist {
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+	if len(descriptors) == 0 {
+		err = fmt.Errorf("none of the manifests %v is referenced by the manifest list of %s:%s anymore",
+			digests, srcRepo, srcRef)
+		t.logger.Errorf(err.Error())
+		return "", err
+	}
+	reduced, err := manifestlist.FromDescriptors(descriptors)
+	if err != nil {
+		t.logger.Errorf("failed to build the reduced manifest list of %s:%s: %v", srcRepo, srcRef, err)
+		return "", err
+	}
+	digest, err := manifestDigest(reduced)
+	if err != nil {
+		return "", err
+	}
+
+	exist, digest2, err := t.exist(dstRepo, dstRef)
+	if err != nil {
+		return "", err
+	}
+	if exist {
+		if digest == digest2 {
+			t.logger.Infof("the image %s:%s already exists on the destination registry, skip", dstRepo, dstRef)
+			return digest, nil
+		}
+		if !override {
+			t.logger.Warningf("the same name image %s:%s exists on the destination registry, but the \"override\" is set to false, skip",
+				dstRepo, dstRef)
+			return "", nil
+		}
+	}
+
+	for _, descriptor := range descriptors {
+		if err = t.copyPlatformManifest(descriptor.Descriptor, srcRepo, dstRepo); err != nil {
+			return "", err
+		}
+	}
+	pushed, err := t.pushManifest(reduced, dstRepo, dstRef)
+	if err != nil {
+		return "", err
+	}
+	t.logger.Infof("copy %s:%s(source registry) reduced to the manifests %v to %s:%s(destination registry) completed",
+		srcRepo, srcRef, digests, dstRepo, dstRef)
+	return pushed, nil
+}
+
+// copy the sub-manifest of the manifest list by digest, the content not matching the
+// digest referenced by the manifest list isn't accepted
+func (t *transfer) copyPlatformManifest(descriptor distribution.Descriptor, srcRepo, dstRepo string) error {
+	digest := descriptor.Digest.String()
+	pushed, err := t.copyImage(srcRepo, digest, dstRepo, digest, true)
+	if err != nil {
+		return err
+	}
+	if len(pushed) > 0 && pushed != digest {
+		err = fmt.Errorf("the digest %s of the manifest copied from %s doesn't match the digest %s referenced by the manifest list, the source may be corrupt",
+			pushed, srcRepo, digest)
+		t.logger.Errorf(err.Error())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
+	"github.com/docker/distribution/manifest/schema2"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the registry serves the manifest list of linux/amd64 referencing the child manifest
+// by "childDigest" and linux/arm64 referencing a corrupt child
+type fakePlatformListRegistry struct {
+	fakeRegistry
+	childDigest string
+}
+
+const corruptChildDigest = "sha256:0000000000000000000000000000000000000000000000000000000000000002"
+
+func (f *fakePlatformListRegistry) descriptors() []manifestlist.ManifestDescriptor {
+	return []manifestlist.ManifestDescriptor{
+		{
+			Descriptor: distribution.Descriptor{
+				MediaType: schema2.MediaTypeManifest,
+				Size:      1024,
+				Digest:    digest.Digest(f.childDigest),
+			},
+			Platform: manifestlist.PlatformSpec{
+				OS:           "linux",
+				Architecture: "amd64",
+			},
+		},
+		{
+			Descriptor: distribution.Descriptor{
+				MediaType: schema2.MediaTypeManifest,
+				Size:      1024,
+				Digest:    corruptChildDigest,
+			},
+			Platform: manifestlist.PlatformSpec{
+				OS:           "linux",
+				Architecture: "arm64",
+			},
+		},
+	}
+}
+
+func (f *fakePlatformListRegistry) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	if strings.HasPrefix(reference, "sha256:") {
+		return f.fakeRegistry.PullManifest(repository, reference, accepttedMediaTypes)
+	}
+	list, err := manifestlist.FromDescriptors(f.descriptors())
+	return list, "", err
+}
+
+func TestCopyReducedManifestList(t *testing.T) {
+	child, _, err := (&fakeRegistry{}).PullManifest("source", "sha256:any", nil)
+	require.Nil(t, err)
+	childDigest, err := manifestDigest(child)
+	require.Nil(t, err)
+	src := &fakePlatformListRegistry{childDigest: childDigest}
+
+	// only the selected platform is copied
+	dst := &fakeRegistry{}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: func() bool { return false },
+		src:       src,
+		dst:       dst,
+		platformDigests: map[string][]string{
+			"latest": {childDigest},
+		},
+	}
+	pushed, err := tr.copyImage("source", "latest", "destination", "latest", true)
+	require.Nil(t, err)
+	reduced, err := manifestlist.FromDescriptors(src.descriptors()[:1])
+	require.Nil(t, err)
+	expected, err := manifestDigest(reduced)
+	require.Nil(t, err)
+	assert.Equal(t, expected, pushed)
+	assert.Equal(t, map[string]string{
+		"destination:" + childDigest: childDigest,
+		"destination:latest":         expected,
+	}, dst.manifests)
+
+	// the reduced manifest list already exists
+	pushed, err = tr.copyImage("source", "latest", "destination", "latest", true)
+	require.Nil(t, err)
+	assert.Equal(t, expected, pushed)
+
+	// the selected child doesn't match its digest
+	tr.dst = &fakeRegistry{}
+	tr.platformDigests["latest"] = []string{corruptChildDigest}
+	_, err = tr.copyImage("source", "latest", "destination", "latest", true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "the source may be corrupt")
+
+	// the selected child isn't referenced by the manifest list anymore
+	tr.platformDigests["latest"] = []string{"sha256:0000000000000000000000000000000000000000000000000000000000000009"}
+	_, err = tr.copyImage("source", "latest", "destination", "latest", true)
+	assert.NotNil(t, err)
+}
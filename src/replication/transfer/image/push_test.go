@@ -0,0 +1,111 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/manifest/schema2"
+	common_http "github.com/goharbor/harbor/src/common/http"
+	"github.com/goharbor/harbor/src/common/utils/log"
+	godigest "github.com/opencontainers/go-digest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the destination registry returns the errors one by one when pushing the
+// manifest, the manifest is stored even though the error is returned if
+// "storeOnError" is set
+type fakeFlakyRegistry struct {
+	fakeSchema1Registry
+	errs         []error
+	storeOnError bool
+	pushes       int
+	stored       string
+}
+
+func (f *fakeFlakyRegistry) ManifestExist(repository, reference string) (bool, string, error) {
+	return len(f.stored) > 0, f.stored, nil
+}
+
+func (f *fakeFlakyRegistry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	f.pushes++
+	var err error
+	if f.pushes <= len(f.errs) {
+		err = f.errs[f.pushes-1]
+	}
+	if err == nil || f.storeOnError {
+		f.stored = godigest.FromBytes(payload).String()
+	}
+	return err
+}
+
+func newFlakyTransfer(registry *fakeFlakyRegistry, retries int) *transfer {
+	return &transfer{
+		logger:              log.DefaultLogger(),
+		isStopped:           func() bool { return false },
+		dst:                 registry,
+		manifestPushRetries: retries,
+	}
+}
+
+func TestPushManifestWithRetry(t *testing.T) {
+	interval := manifestPushRetryInterval
+	manifestPushRetryInterval = time.Millisecond
+	defer func() {
+		manifestPushRetryInterval = interval
+	}()
+	manifest, err := schema2.FromStruct(schema2.Manifest{})
+	require.Nil(t, err)
+	_, payload, err := manifest.Payload()
+	require.Nil(t, err)
+	badGateway := &common_http.Error{Code: http.StatusBadGateway}
+
+	// 502 is returned but the manifest exists, no redundant push
+	registry := &fakeFlakyRegistry{
+		errs:         []error{badGateway},
+		storeOnError: true,
+	}
+	digest, err := newFlakyTransfer(registry, 3).pushManifest(manifest, "library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.Equal(t, godigest.FromBytes(payload).String(), digest)
+	assert.Equal(t, 1, registry.pushes)
+
+	// 502 is returned and the manifest doesn't exist, retry
+	registry = &fakeFlakyRegistry{
+		errs: []error{badGateway, badGateway},
+	}
+	_, err = newFlakyTransfer(registry, 3).pushManifest(manifest, "library/hello-world", "latest")
+	require.Nil(t, err)
+	assert.Equal(t, 3, registry.pushes)
+
+	// no retry
+	registry = &fakeFlakyRegistry{
+		errs: []error{badGateway},
+	}
+	_, err = newFlakyTransfer(registry, 0).pushManifest(manifest, "library/hello-world", "latest")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, registry.pushes)
+
+	// the client error isn't retried
+	registry = &fakeFlakyRegistry{
+		errs: []error{&common_http.Error{Code: http.StatusBadRequest}},
+	}
+	_, err = newFlakyTransfer(registry, 3).pushManifest(manifest, "library/hello-world", "latest")
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, registry.pushes)
+}
@@ -0,0 +1,85 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/goharbor/harbor/src/replication/model"
+)
+
+// Signer signs the image copied to the destination registry, e.g. by cosign or
+// Notary, so the downstream consumers can verify it
+type Signer interface {
+	// Sign the manifest specified by the digest which is tagged by the tag
+	// under the repository of the registry
+	Sign(registry *model.Registry, repository, tag, digest string) error
+}
+
+var (
+	signersLock sync.RWMutex
+	signers     = map[string]Signer{}
+)
+
+// RegisterSigner registers the signer with the name, the name is referenced
+// by the "Signer" of the replication policy
+func RegisterSigner(name string, signer Signer) error {
+	if len(name) == 0 {
+		return fmt.Errorf("empty signer name")
+	}
+	if signer == nil {
+		return fmt.Errorf("empty signer")
+	}
+	signersLock.Lock()
+	defer signersLock.Unlock()
+	if _, exist := signers[name]; exist {
+		return fmt.Errorf("signer %s already exists", name)
+	}
+	signers[name] = signer
+	return nil
+}
+
+func getSigner(name string) (Signer, error) {
+	signersLock.RLock()
+	defer signersLock.RUnlock()
+	signer, exist := signers[name]
+	if !exist {
+		return nil, fmt.Errorf("signer %s not found", name)
+	}
+	return signer, nil
+}
+
+// sign the copied image on the destination registry, the failure is returned
+// only when the signing failure policy is "fail", otherwise it's logged
+func (t *transfer) sign(repository, tag, digest string) error {
+	if len(t.signer) == 0 || t.shouldStop() {
+		return nil
+	}
+	t.logger.Infof("signing the image %s:%s(%s) on the destination registry...", repository, tag, digest)
+	signer, err := getSigner(t.signer)
+	if err == nil {
+		err = signer.Sign(t.dstRegistry, repository, tag, digest)
+	}
+	if err != nil {
+		if t.signingFailure == model.SigningFailureFail {
+			return fmt.Errorf("the image %s:%s is copied, but failed to sign it: %v", repository, tag, err)
+		}
+		t.logger.Warningf("the image %s:%s is copied, but failed to sign it: %v", repository, tag, err)
+		return nil
+	}
+	t.logger.Infof("the image %s:%s(%s) signed", repository, tag, digest)
+	return nil
+}
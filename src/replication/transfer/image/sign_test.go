@@ -0,0 +1,119 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// records the images signed, the signing of the tag "unsignable" fails
+type mockSigner struct {
+	sync.Mutex
+	signed []string
+}
+
+func (m *mockSigner) Sign(registry *model.Registry, repository, tag, digest string) error {
+	if tag == "unsignable" {
+		return errors.New("failed to sign")
+	}
+	m.Lock()
+	defer m.Unlock()
+	m.signed = append(m.signed, registry.Name+"/"+repository+":"+tag+"@"+digest)
+	return nil
+}
+
+func TestRegisterSigner(t *testing.T) {
+	assert.NotNil(t, RegisterSigner("", &mockSigner{}))
+	assert.NotNil(t, RegisterSigner("nil", nil))
+	require.Nil(t, RegisterSigner("duplicated", &mockSigner{}))
+	assert.NotNil(t, RegisterSigner("duplicated", &mockSigner{}))
+	_, err := getSigner("not_exist")
+	assert.NotNil(t, err)
+}
+
+func TestCopyWithSigner(t *testing.T) {
+	signer := &mockSigner{}
+	require.Nil(t, RegisterSigner("mock", signer))
+	dst := &fakeRegistry{
+		manifests: map[string]string{
+			"destination:b3": "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: func() bool { return false },
+		src:       &fakeRegistry{},
+		dst:       dst,
+		signer:    "mock",
+		dstRegistry: &model.Registry{
+			Name: "mirror",
+		},
+	}
+
+	// every copied image is signed, "b3" isn't overridden and signed
+	err := tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a1", "a2", "a3"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b1", "b2", "b3"},
+	}, false, false)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(signer.signed))
+	assert.Contains(t, signer.signed[0], "mirror/destination:b1@sha256:")
+	assert.Contains(t, signer.signed[1], "mirror/destination:b2@sha256:")
+
+	// the signing failure is only logged by default
+	signer.signed = nil
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a4", "a5"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"unsignable", "b5"},
+	}, true, false)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(signer.signed))
+
+	// the signing failure fails the task
+	tr.signingFailure = model.SigningFailureFail
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a6"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"unsignable"},
+	}, true, false)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "failed to sign")
+
+	// the signer isn't registered
+	tr.signer = "not_exist"
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a7"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b7"},
+	}, true, false)
+	assert.NotNil(t, err)
+}
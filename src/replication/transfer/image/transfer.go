@@ -17,19 +17,27 @@ package image
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/docker/distribution/manifest/manifestlist"
 
 	"github.com/docker/distribution"
 	"github.com/docker/distribution/manifest/schema1"
 	"github.com/docker/distribution/manifest/schema2"
+	common_http "github.com/goharbor/harbor/src/common/http"
 	"github.com/goharbor/harbor/src/common/utils/log"
 	"github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
 	trans "github.com/goharbor/harbor/src/replication/transfer"
+	godigest "github.com/opencontainers/go-digest"
 )
 
+// the interval between the retries of pushing the manifest
+var manifestPushRetryInterval = time.Second
+
 func init() {
 	if err := trans.RegisterFactory(model.ResourceTypeImage, factory); err != nil {
 		log.Errorf("failed to register transfer factory: %v", err)
@@ -53,6 +61,35 @@ type transfer struct {
 	isStopped trans.StopFunc
 	src       adapter.ImageRegistry
 	dst       adapter.ImageRegistry
+	// the copy of the blob is aborted if no bytes are transferred within it
+	idleTimeout time.Duration
+	// the size of the buffer reading the blobs, the default one is used if it's <= 0
+	bufferSize int
+	// the count of the tags copied concurrently
+	tagConcurrency int
+	// the count of retries when pushing a manifest gets 5xx
+	manifestPushRetries int
+	// push and check the config blob before copying the layers
+	configBlobFirst bool
+	// the digests of the sub-manifests selected by the platform filter indexed by
+	// the source tags, the reduced manifest lists are copied for these tags
+	platformDigests map[string][]string
+	// the max count of the blobs uploaded concurrently to the destination registry
+	// by all the tasks in the process, no limit if <= 0
+	maxBlobUploads int
+	// the limiter of the bandwidth shared by the tasks of the same execution, no limit if nil
+	bandwidth *bandwidthLimiter
+	// the media types translated into: "docker" or "oci", no translation if it's empty
+	mediaTypeTranslation string
+	// deduplicate the concurrent copies of the blobs shared by the tags
+	blobs blobDeduplicator
+	// the name of the signer signing the copied images on the destination
+	// registry and the way handling the signing failure
+	signer         string
+	signingFailure string
+	dstRegistry    *model.Registry
+	// delete the cosign signatures whose subject images no longer exist after the deletion
+	cleanupOrphanedSignatures bool
 }
 
 func (t *transfer) Transfer(src *model.Resource, dst *model.Resource) error {
@@ -63,6 +100,7 @@ func (t *transfer) Transfer(src *model.Resource, dst *model.Resource) error {
 
 	// delete the repository on destination registry
 	if dst.Deleted {
+		t.cleanupOrphanedSignatures = dst.CleanupOrphanedSignatures
 		return t.delete(&repository{
 			repository: dst.Metadata.GetResourceName(),
 			tags:       dst.Metadata.Vtags,
@@ -77,8 +115,23 @@ func (t *transfer) Transfer(src *model.Resource, dst *model.Resource) error {
 		repository: dst.Metadata.GetResourceName(),
 		tags:       dst.Metadata.Vtags,
 	}
+	t.idleTimeout = time.Duration(dst.BlobIdleTimeout) * time.Second
+	t.bufferSize = dst.BlobBufferSize
+	t.tagConcurrency = dst.TagConcurrency
+	t.manifestPushRetries = dst.ManifestPushRetries
+	t.configBlobFirst = dst.ConfigBlobFirst
+	t.maxBlobUploads = dst.MaxBlobUploads
+	if dst.BandwidthLimit > 0 {
+		t.bandwidth = bandwidthLimiters.get(dst.ExecutionID, dst.BandwidthLimit)
+		defer bandwidthLimiters.put(dst.ExecutionID)
+	}
+	t.platformDigests = src.GetPlatformDigests()
+	t.signer = dst.Signer
+	t.signingFailure = dst.SigningFailure
+	t.mediaTypeTranslation = dst.MediaTypeTranslation
+	t.dstRegistry = dst.Registry
 	// copy the repository from source registry to the destination
-	return t.copy(srcRepo, dstRepo, dst.Override)
+	return t.copy(srcRepo, dstRepo, dst.Override, dst.Move)
 }
 
 func (t *transfer) initialize(src *model.Resource, dst *model.Resource) error {
@@ -132,18 +185,86 @@ func (t *transfer) shouldStop() bool {
 	return isStopped
 }
 
-func (t *transfer) copy(src *repository, dst *repository, override bool) error {
+// copy the images from the source registry to the destination, if "move" is set,
+// the source tags are deleted once they are copied and verified. The failure of
+// deleting the source tag doesn't undo the copy, but is reported as the error
+func (t *transfer) copy(src *repository, dst *repository, override, move bool) error {
 	srcRepo := src.repository
 	dstRepo := dst.repository
 	t.logger.Infof("copying %s:[%s](source registry) to %s:[%s](destination registry)...",
 		srcRepo, strings.Join(src.tags, ","), dstRepo, strings.Join(dst.tags, ","))
-	var err error
+	// the verification of the copied image runs in background, so the copy
+	// of the next image can start before the previous one is verified
+	errs := make([]error, len(src.tags))
+	// the digests of the copied images, it's empty if the image isn't copied
+	digests := make([]string, len(src.tags))
+	verifier := t.startVerifier(errs)
+	// the tags are copied concurrently if the tag concurrency > 1
+	concurrency := t.tagConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	tokens := make(chan struct{}, concurrency)
+	wg := &sync.WaitGroup{}
 	for i := range src.tags {
-		if e := t.copyImage(srcRepo, src.tags[i], dstRepo, dst.tags[i], override); e != nil {
-			t.logger.Errorf(e.Error())
-			err = e
+		tokens <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			digest, err := t.copyImage(srcRepo, src.tags[i], dstRepo, dst.tags[i], override)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			digests[i] = digest
+			verifier.submit(&verification{
+				index:      i,
+				repository: dstRepo,
+				reference:  dst.tags[i],
+				digest:     digest,
+			})
+		}(i)
+	}
+	wg.Wait()
+	verifier.wait()
+
+	// sign the copied and verified images
+	for i := range src.tags {
+		if errs[i] != nil || len(digests[i]) == 0 {
+			continue
+		}
+		errs[i] = t.sign(dstRepo, dst.tags[i], digests[i])
+	}
+
+	if move {
+		// only the tags copied are deleted on the source registry
+		var moved []string
+		for i := range src.tags {
+			if errs[i] != nil || len(digests[i]) == 0 {
+				continue
+			}
+			moved = append(moved, src.tags[i])
+		}
+		for i := range src.tags {
+			if errs[i] != nil || len(digests[i]) == 0 {
+				continue
+			}
+			errs[i] = t.deleteSource(srcRepo, src.tags[i], moved)
 		}
 	}
+
+	var err error
+	for i, e := range errs {
+		if e == nil {
+			continue
+		}
+		t.logger.Errorf("failed to replicate %s:%s(source registry) to %s:%s(destination registry): %v",
+			srcRepo, src.tags[i], dstRepo, dst.tags[i], e)
+		err = e
+	}
 	if err != nil {
 		return err
 	}
@@ -153,55 +274,183 @@ func (t *transfer) copy(src *repository, dst *repository, override bool) error {
 	return nil
 }
 
-func (t *transfer) copyImage(srcRepo, srcRef, dstRepo, dstRef string, override bool) error {
+// copy the image from the source registry to the destination and return the
+// digest of the manifest expected on the destination registry. The returned
+// digest is empty if the image isn't copied
+func (t *transfer) copyImage(srcRepo, srcRef, dstRepo, dstRef string, override bool) (string, error) {
+	if digests := t.platformDigests[srcRef]; len(digests) > 0 {
+		return t.copyReducedManifestList(srcRepo, srcRef, dstRepo, dstRef, digests, override)
+	}
 	t.logger.Infof("copying %s:%s(source registry) to %s:%s(destination registry)...",
 		srcRepo, srcRef, dstRepo, dstRef)
 	// pull the manifest from the source registry
 	manifest, digest, err := t.pullManifest(srcRepo, srcRef)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	// the manifests copied by digest(e.g. the ones referenced by the manifest lists)
+	// are never translated as the digests must be kept
+	translated := false
+	if len(t.mediaTypeTranslation) > 0 && manifest != nil && !isDigest(dstRef) {
+		if manifest, translated, err = translateMediaTypes(manifest, t.mediaTypeTranslation); err != nil {
+			t.logger.Errorf("failed to translate the media types of %s:%s: %v", srcRepo, srcRef, err)
+			return "", err
+		}
+		if translated {
+			t.logger.Infof("the media types of %s:%s are translated to the %s ones, its referrers aren't copied",
+				srcRepo, srcRef, t.mediaTypeTranslation)
+		}
+	}
+	// the schema1 manifest isn't supported by the modern registries, convert it to schema2.
+	// The conversion is done before checking the existence as the digest of the converted
+	// manifest is the one expected on the destination registry, the conversion is cached
+	// by the source digest so the reruns don't pull the layers again
+	var convertedConfig []byte
+	if m, ok := manifest.(*schema1.SignedManifest); ok {
+		if manifest, convertedConfig, err = t.convertSchema1Manifest(m, digest, srcRepo); err != nil {
+			t.logger.Errorf("failed to convert the schema1 manifest of %s:%s: %v", srcRepo, srcRef, err)
+			return "", err
+		}
+		translated = true
+	}
+	// the referrers of the translated manifest aren't copied as their subject
+	// digest doesn't exist on the destination registry
+	referred := digest
+	if translated {
+		if digest, err = manifestDigest(manifest); err != nil {
+			return "", err
+		}
+		referred = ""
 	}
 
 	// check the existence of the image on the destination registry
 	exist, digest2, err := t.exist(dstRepo, dstRef)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if exist {
 		// the same image already exists
 		if digest == digest2 {
 			t.logger.Infof("the image %s:%s already exists on the destination registry, skip",
 				dstRepo, dstRef)
-			return nil
+			// the referrers may be attached after the image was replicated
+			if err = t.copyReferrers(srcRepo, dstRepo, referred); err != nil {
+				return "", err
+			}
+			return digest, nil
 		}
 		// the same name image exists, but not allowed to override
 		if !override {
 			t.logger.Warningf("the same name image %s:%s exists on the destination registry, but the \"override\" is set to false, skip",
 				dstRepo, dstRef)
-			return nil
+			return "", nil
 		}
 		// the same name image exists, but allowed to override
 		t.logger.Warningf("the same name image %s:%s exists on the destination registry and the \"override\" is set to true, continue...",
 			dstRepo, dstRef)
 	}
 
+	// the image config of the converted manifest doesn't exist on the source registry
+	if convertedConfig != nil {
+		if err = t.pushConvertedConfig(dstRepo, convertedConfig); err != nil {
+			return "", err
+		}
+	}
+
+	// the blobs uploaded by the previous attempt are skipped by the existence check
+	// of each blob, so the retry resumes from pushing the manifest
+	references := manifest.References()
+	if t.configBlobFirst {
+		if references, err = t.copyConfigFirst(manifest, references, srcRepo, dstRepo); err != nil {
+			return "", err
+		}
+	}
+
 	// copy contents between the source and destination registries
-	for _, content := range manifest.References() {
+	for _, content := range references {
 		if err = t.copyContent(content, srcRepo, dstRepo); err != nil {
-			return err
+			return "", err
 		}
 	}
 
 	// push the manifest to the destination registry
-	if err := t.pushManifest(manifest, dstRepo, dstRef); err != nil {
-		return err
+	pushed, err := t.pushManifest(manifest, dstRepo, dstRef)
+	if err != nil {
+		return "", err
+	}
+
+	// copy the OCI artifacts attached to the manifest
+	if err := t.copyReferrers(srcRepo, dstRepo, referred); err != nil {
+		return "", err
 	}
 
 	t.logger.Infof("copy %s:%s(source registry) to %s:%s(destination registry) completed",
 		srcRepo, srcRef, dstRepo, dstRef)
+	return pushed, nil
+}
+
+// copy the referrers(the OCI artifacts whose "subject" is the manifest specified
+// by the digest) from the source registry to the destination. The referrers are
+// copied by digest, so the "subject" relationship is kept at the destination
+func (t *transfer) copyReferrers(srcRepo, dstRepo, digest string) error {
+	if t.shouldStop() {
+		return nil
+	}
+	registry, ok := t.src.(adapter.ReferrerRegistry)
+	if !ok || len(digest) == 0 {
+		return nil
+	}
+	referrers, err := registry.ListReferrers(srcRepo, digest)
+	if err != nil {
+		t.logger.Errorf("failed to list the referrers of %s@%s: %v", srcRepo, digest, err)
+		return err
+	}
+	for _, referrer := range referrers {
+		dgt := referrer.Digest.String()
+		t.logger.Infof("copying the referrer %s(artifact type: %s) of %s@%s...",
+			dgt, referrer.MediaType, srcRepo, digest)
+		if _, err = t.copyImage(srcRepo, dgt, dstRepo, dgt, true); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// copy the config blob of the schema2 manifest before the layers and check whether the
+// destination registry accepts it, so the copy fails before transferring any layer if the
+// config is rejected. Returns the references left to be copied
+func (t *transfer) copyConfigFirst(manifest distribution.Manifest, references []distribution.Descriptor,
+	srcRepo, dstRepo string) ([]distribution.Descriptor, error) {
+	m, ok := manifest.(*schema2.DeserializedManifest)
+	if !ok || t.shouldStop() {
+		return references, nil
+	}
+	digest := m.Config.Digest.String()
+	t.logger.Infof("copying the config blob %s before the layers...", digest)
+	if err := t.copyBlob(srcRepo, dstRepo, digest); err != nil {
+		t.logger.Errorf("the config blob %s is rejected by the destination registry, skip copying the layers: %v", digest, err)
+		return nil, err
+	}
+	exist, err := t.dst.BlobExist(dstRepo, digest)
+	if err != nil {
+		t.logger.Errorf("failed to check the existence of the config blob %s on the destination registry: %v", digest, err)
+		return nil, err
+	}
+	if !exist {
+		err = fmt.Errorf("the config blob %s isn't accepted by the destination registry", digest)
+		t.logger.Errorf("%v, skip copying the layers", err)
+		return nil, err
+	}
+	var result []distribution.Descriptor
+	for _, reference := range references {
+		if reference.Digest != m.Config.Digest {
+			result = append(result, reference)
+		}
+	}
+	return result, nil
+}
+
 // copy the content from source registry to destination according to its media type
 func (t *transfer) copyContent(content distribution.Descriptor, srcRepo, dstRepo string) error {
 	digest := content.Digest.String()
@@ -210,9 +459,10 @@ func (t *transfer) copyContent(content distribution.Descriptor, srcRepo, dstRepo
 	// the contents it contains are a few manifests
 	case schema2.MediaTypeManifest:
 		// as using digest as the reference, so set the override to true directly
-		return t.copyImage(srcRepo, digest, dstRepo, digest, true)
+		_, err := t.copyImage(srcRepo, digest, dstRepo, digest, true)
+		return err
 	// handle foreign layer
-	case schema2.MediaTypeForeignLayer:
+	case schema2.MediaTypeForeignLayer, mediaTypeOCINondistributableLayer:
 		t.logger.Infof("the layer %s is a foreign layer, skip", digest)
 		return nil
 	// copy layer or image config
@@ -223,11 +473,18 @@ func (t *transfer) copyContent(content distribution.Descriptor, srcRepo, dstRepo
 	}
 }
 
-// copy the layer or image config from the source registry to destination
+// copy the layer or image config from the source registry to destination, the blob
+// being copied by another tag concurrently isn't copied again
 func (t *transfer) copyBlob(srcRepo, dstRepo, digest string) error {
 	if t.shouldStop() {
 		return nil
 	}
+	return t.blobs.do(dstRepo+"@"+digest, func() error {
+		return t.copyBlobOnce(srcRepo, dstRepo, digest)
+	})
+}
+
+func (t *transfer) copyBlobOnce(srcRepo, dstRepo, digest string) error {
 	t.logger.Infof("copying the blob %s...", digest)
 	exist, err := t.dst.BlobExist(dstRepo, digest)
 	if err != nil {
@@ -239,17 +496,66 @@ func (t *transfer) copyBlob(srcRepo, dstRepo, digest string) error {
 		return nil
 	}
 
+	for i := 0; ; i++ {
+		err = t.transferBlob(srcRepo, dstRepo, digest)
+		if err != errIdleTimeout || i >= idleTimeoutRetries || t.shouldStop() {
+			break
+		}
+		t.logger.Warningf("the copy of blob %s is stalled for %v, retrying...", digest, t.idleTimeout)
+	}
+	if err != nil {
+		return err
+	}
+	t.logger.Infof("copy the blob %s completed", digest)
+	return nil
+}
+
+// pull the blob from the source registry and push it to the destination, the
+// transfer is aborted with "errIdleTimeout" if it stalls longer than the idle timeout
+func (t *transfer) transferBlob(srcRepo, dstRepo, digest string) error {
+	// the blob is streamed from the source to the destination, so the upload
+	// is occupied during the whole transfer
+	if t.maxBlobUploads > 0 && t.dstRegistry != nil {
+		limiter := uploadLimiters.get(t.dstRegistry.URL)
+		limiter.acquire(t.maxBlobUploads)
+		defer limiter.release()
+	}
 	size, data, err := t.src.PullBlob(srcRepo, digest)
 	if err != nil {
 		t.logger.Errorf("failed to pulling the blob %s: %v", digest, err)
 		return err
 	}
+	var reader *idleTimeoutReader
+	// receiving from the nil channel blocks forever, so the push is never abandoned without the timeout
+	var aborted chan struct{}
+	if t.idleTimeout > 0 {
+		reader = newIdleTimeoutReader(data, t.idleTimeout)
+		data = reader
+		aborted = reader.aborted
+	}
+	data = newBufferedReader(data, t.bufferSize)
+	if t.bandwidth != nil {
+		data = newThrottledReader(data, t.bandwidth)
+	}
 	defer data.Close()
-	if err = t.dst.PushBlob(dstRepo, digest, size, data); err != nil {
+	// the push blocked by the stalled destination isn't unblocked by closing the source,
+	// so it's abandoned once the idle timeout is reached
+	pushed := make(chan error, 1)
+	go func() {
+		pushed <- t.dst.PushBlob(dstRepo, digest, size, data)
+	}()
+	select {
+	case err = <-pushed:
+	case <-aborted:
+		err = errIdleTimeout
+	}
+	if err != nil {
+		if reader != nil && reader.isTimedOut() {
+			err = errIdleTimeout
+		}
 		t.logger.Errorf("failed to pushing the blob %s: %v", digest, err)
 		return err
 	}
-	t.logger.Infof("copy the blob %s completed", digest)
 	return nil
 }
 
@@ -263,12 +569,16 @@ func (t *transfer) pullManifest(repository, reference string) (
 		schema1.MediaTypeManifest,
 		schema2.MediaTypeManifest,
 		manifestlist.MediaTypeManifestList,
+		adapter.MediaTypeOCIManifest,
 	})
 	if err != nil {
 		t.logger.Errorf("failed to pull the manifest of image %s:%s: %v", repository, reference, err)
 		return nil, "", err
 	}
 	t.logger.Infof("the manifest of image %s:%s pulled", repository, reference)
+	if adapter.IsHelmChart(manifest) {
+		t.logger.Infof("the artifact %s:%s is a Helm chart stored as an OCI artifact", repository, reference)
+	}
 
 	// this is a solution to work around that harbor doesn't support manifest list
 	return t.handleManifest(manifest, repository, digest)
@@ -286,7 +596,8 @@ func (t *transfer) handleManifest(manifest distribution.Manifest, repository, di
 	// manifest
 	if mediaType == schema1.MediaTypeManifest ||
 		mediaType == schema1.MediaTypeSignedManifest ||
-		mediaType == schema2.MediaTypeManifest {
+		mediaType == schema2.MediaTypeManifest ||
+		mediaType == adapter.MediaTypeOCIManifest {
 		return manifest, digest, nil
 	}
 	// manifest list
@@ -310,7 +621,37 @@ func (t *transfer) handleManifest(manifest distribution.Manifest, repository, di
 		digest = manifest.References()[0].Digest.String()
 		t.logger.Infof("no manifest(architecture: amd64, os: linux) found, using the first one: %s", digest)
 	}
-	return t.pullManifest(repository, digest)
+	child, childDigest, err := t.pullManifest(repository, digest)
+	if err != nil || child == nil {
+		return child, childDigest, err
+	}
+	// the corrupt source may serve the content not matching the digest referenced
+	// by the manifest list, never copy the mismatched content to the destination
+	computed, err := manifestDigest(child)
+	if err != nil {
+		t.logger.Errorf("failed to compute the digest of the manifest %s@%s: %v", repository, digest, err)
+		return nil, "", err
+	}
+	if computed != digest {
+		err = fmt.Errorf("the digest %s of the manifest pulled from %s doesn't match the digest %s referenced by the manifest list, the source may be corrupt",
+			computed, repository, digest)
+		t.logger.Errorf(err.Error())
+		return nil, "", err
+	}
+	return child, childDigest, nil
+}
+
+// compute the digest of the manifest, the one of the schema1 manifest is
+// computed from its canonical payload without the signatures
+func manifestDigest(manifest distribution.Manifest) (string, error) {
+	if m, ok := manifest.(*schema1.SignedManifest); ok {
+		return godigest.FromBytes(m.Canonical).String(), nil
+	}
+	_, payload, err := manifest.Payload()
+	if err != nil {
+		return "", err
+	}
+	return godigest.FromBytes(payload).String(), nil
 }
 
 func (t *transfer) exist(repository, tag string) (bool, string, error) {
@@ -323,24 +664,66 @@ func (t *transfer) exist(repository, tag string) (bool, string, error) {
 	return exist, digest, nil
 }
 
-func (t *transfer) pushManifest(manifest distribution.Manifest, repository, tag string) error {
+func (t *transfer) pushManifest(manifest distribution.Manifest, repository, tag string) (string, error) {
 	if t.shouldStop() {
-		return nil
+		return "", nil
 	}
 	t.logger.Infof("pushing the manifest of image %s:%s ...", repository, tag)
 	mediaType, payload, err := manifest.Payload()
 	if err != nil {
 		t.logger.Errorf("failed to push manifest of image %s:%s: %v",
 			repository, tag, err)
-		return err
+		return "", err
 	}
-	if err := t.dst.PushManifest(repository, tag, mediaType, payload); err != nil {
+	digest := godigest.FromBytes(payload).String()
+	for i := 0; ; i++ {
+		err = t.dst.PushManifest(repository, tag, mediaType, payload)
+		if err == nil || !isServerError(err) || i >= t.manifestPushRetries || t.shouldStop() {
+			break
+		}
+		// the manifest may be pushed successfully on the server side even though
+		// 5xx is returned, check it before retrying to avoid pushing it twice
+		exist, dgt, e := t.dst.ManifestExist(repository, tag)
+		if e == nil && exist && dgt == digest {
+			t.logger.Infof("got %v when pushing the manifest of image %s:%s, but it exists on the destination registry",
+				err, repository, tag)
+			err = nil
+			break
+		}
+		t.logger.Warningf("failed to push manifest of image %s:%s, retrying...: %v", repository, tag, err)
+		time.Sleep(manifestPushRetryInterval)
+	}
+	if err != nil {
 		t.logger.Errorf("failed to push manifest of image %s:%s: %v",
 			repository, tag, err)
-		return err
+		return "", err
 	}
 	t.logger.Infof("the manifest of image %s:%s pushed",
 		repository, tag)
+	return digest, nil
+}
+
+// whether the error is a 5xx returned by the registry
+func isServerError(err error) bool {
+	e, ok := err.(*common_http.Error)
+	return ok && e.Code >= http.StatusInternalServerError
+}
+
+// delete the tag on the source registry after it is moved to the destination registry,
+// the "moved" are all the tags moved together with it
+func (t *transfer) deleteSource(repository, tag string, moved []string) error {
+	if t.shouldStop() {
+		return nil
+	}
+	t.logger.Infof("deleting the image %s:%s on the source registry...", repository, tag)
+	deleted, err := t.deleteTag(t.src, repository, tag, moved)
+	if err != nil {
+		return fmt.Errorf("the image %s:%s is copied, but failed to delete it on the source registry: %v",
+			repository, tag, err)
+	}
+	if deleted {
+		t.logger.Infof("delete the image %s:%s on the source registry completed", repository, tag)
+	}
 	return nil
 }
 
@@ -362,12 +745,18 @@ func (t *transfer) delete(repo *repository) error {
 				repository, tag)
 			continue
 		}
-		if err := t.dst.DeleteManifest(repository, tag); err != nil {
+		deleted, err := t.deleteTag(t.dst, repository, tag, repo.tags)
+		if err != nil {
 			t.logger.Errorf("failed to delete the manifest of image %s:%s on the destination registry: %v",
 				repository, tag, err)
 			return err
 		}
-		t.logger.Infof("the manifest of image %s:%s is deleted", repository, tag)
+		if deleted {
+			t.logger.Infof("the manifest of image %s:%s is deleted", repository, tag)
+		}
+	}
+	if t.cleanupOrphanedSignatures {
+		t.cleanupOrphans(repository)
 	}
 	return nil
 }
@@ -16,27 +16,45 @@ package image
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/docker/distribution"
+	"github.com/docker/distribution/manifest/manifestlist"
 	"github.com/docker/distribution/manifest/schema2"
 	"github.com/goharbor/harbor/src/common/utils/log"
 	pkg_registry "github.com/goharbor/harbor/src/common/utils/registry"
+	"github.com/goharbor/harbor/src/replication/adapter"
 	"github.com/goharbor/harbor/src/replication/model"
 	trans "github.com/goharbor/harbor/src/replication/transfer"
+	"github.com/opencontainers/go-digest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
-type fakeRegistry struct{}
+// the registry records the digests of the pushed manifests
+type fakeRegistry struct {
+	sync.Mutex
+	manifests map[string]string
+}
 
-func (f *fakeRegistry) FetchImages([]*model.Filter) ([]*model.Resource, error) {
+func (f *fakeRegistry) FetchImages(context.Context, []*model.Filter) ([]*model.Resource, error) {
 	return nil, nil
 }
 
 func (f *fakeRegistry) ManifestExist(repository, reference string) (bool, string, error) {
+	f.Lock()
+	defer f.Unlock()
+	if digest, exist := f.manifests[repository+":"+reference]; exist {
+		return true, digest, nil
+	}
 	if repository == "destination" && reference == "b1" {
 		return true, "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7", nil
 	}
@@ -78,6 +96,12 @@ func (f *fakeRegistry) PullManifest(repository, reference string, accepttedMedia
 	return mani, "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7", nil
 }
 func (f *fakeRegistry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	f.Lock()
+	defer f.Unlock()
+	if f.manifests == nil {
+		f.manifests = map[string]string{}
+	}
+	f.manifests[repository+":"+reference] = digest.FromBytes(payload).String()
 	return nil
 }
 func (f *fakeRegistry) DeleteManifest(repository, reference string) error {
@@ -136,10 +160,406 @@ func TestCopy(t *testing.T) {
 		tags:       []string{"b1", "b2"},
 	}
 	override := true
-	err := tr.copy(src, dst, override)
+	err := tr.copy(src, dst, override, false)
 	require.Nil(t, err)
 }
 
+// the registry records the pushed blobs and rejects the config blob if "rejectConfig" is set
+type fakeConfigRejectingRegistry struct {
+	fakeRegistry
+	rejectConfig bool
+	blobs        []string
+}
+
+func (f *fakeConfigRejectingRegistry) BlobExist(repository, digest string) (bool, error) {
+	for _, blob := range f.blobs {
+		if blob == digest {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (f *fakeConfigRejectingRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	if f.rejectConfig && digest == "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7" {
+		return errors.New("invalid image config")
+	}
+	f.blobs = append(f.blobs, digest)
+	return nil
+}
+
+func TestCopyConfigBlobFirst(t *testing.T) {
+	config := "sha256:b5b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7"
+	newTransfer := func(dst adapter.ImageRegistry) *transfer {
+		return &transfer{
+			logger:          log.DefaultLogger(),
+			isStopped:       func() bool { return false },
+			src:             &fakeRegistry{},
+			dst:             dst,
+			configBlobFirst: true,
+		}
+	}
+
+	// the config blob is pushed first and then the layers
+	registry := &fakeConfigRejectingRegistry{}
+	_, err := newTransfer(registry).copyImage("source", "a1", "destination", "a1", true)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(registry.blobs))
+	assert.Equal(t, config, registry.blobs[0])
+
+	// the config blob is rejected, no layer is transferred
+	registry = &fakeConfigRejectingRegistry{
+		rejectConfig: true,
+	}
+	_, err = newTransfer(registry).copyImage("source", "a1", "destination", "a1", true)
+	require.NotNil(t, err)
+	assert.Equal(t, 0, len(registry.blobs))
+	assert.Equal(t, 0, len(registry.manifests))
+}
+
+// the verification of "t1" waits until the copy of "t2" starts, the
+// manifest of "mismatch" is changed and "lost" is lost after being pushed
+type fakePipelineRegistry struct {
+	fakeRegistry
+	t2Pushed chan struct{}
+}
+
+func (f *fakePipelineRegistry) ManifestExist(repository, reference string) (bool, string, error) {
+	exist, digest, err := f.fakeRegistry.ManifestExist(repository, reference)
+	if err != nil || !exist {
+		return exist, digest, err
+	}
+	switch reference {
+	case "t1":
+		select {
+		case <-f.t2Pushed:
+		case <-time.After(10 * time.Second):
+			return false, "", errors.New("the copy of t2 isn't started during the verification of t1")
+		}
+	case "mismatch":
+		return true, "sha256:0000000000000000000000000000000000000000000000000000000000000000", nil
+	}
+	return exist, digest, nil
+}
+func (f *fakePipelineRegistry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	switch reference {
+	case "t2":
+		close(f.t2Pushed)
+	case "lost":
+		return nil
+	}
+	return f.fakeRegistry.PushManifest(repository, reference, mediaType, payload)
+}
+
+func TestCopyWithVerification(t *testing.T) {
+	stopFunc := func() bool { return false }
+	registry := &fakePipelineRegistry{
+		t2Pushed: make(chan struct{}),
+	}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: stopFunc,
+		src:       &fakeRegistry{},
+		dst:       registry,
+	}
+
+	// all verified
+	src := &repository{
+		repository: "source",
+		tags:       []string{"t1", "t2", "t3"},
+	}
+	dst := &repository{
+		repository: "destination",
+		tags:       []string{"t1", "t2", "t3"},
+	}
+	require.Nil(t, tr.copy(src, dst, true, false))
+
+	// the failures are attributed to the images that fail the verification
+	src = &repository{
+		repository: "source",
+		tags:       []string{"a1", "a2", "a3", "a4"},
+	}
+	dst = &repository{
+		repository: "destination",
+		tags:       []string{"t3", "mismatch", "lost", "t4"},
+	}
+	errs := make([]error, len(dst.tags))
+	verifier := tr.startVerifier(errs)
+	for i := range src.tags {
+		digest, err := tr.copyImage(src.repository, src.tags[i], dst.repository, dst.tags[i], true)
+		require.Nil(t, err)
+		verifier.submit(&verification{
+			index:      i,
+			repository: dst.repository,
+			reference:  dst.tags[i],
+			digest:     digest,
+		})
+	}
+	verifier.wait()
+	assert.Nil(t, errs[0])
+	require.NotNil(t, errs[1])
+	assert.Contains(t, errs[1].Error(), "destination:mismatch")
+	require.NotNil(t, errs[2])
+	assert.Contains(t, errs[2].Error(), "destination:lost")
+	assert.Nil(t, errs[3])
+
+	err := tr.copy(src, dst, true, false)
+	require.NotNil(t, err)
+}
+
+// the registry records the deleted tags and fails to delete the tag "broken"
+// the source registry records the tags deleted, the manifest of the tag "broken"
+// cannot be deleted
+type fakeMoveRegistry struct {
+	fakedTagRegistry
+	deleted []string
+}
+
+func (f *fakeMoveRegistry) DeleteManifest(repository, reference string) error {
+	if reference == f.tags["broken"] {
+		return errors.New("permission denied")
+	}
+	var deleted []string
+	for tag, digest := range f.tags {
+		if digest == reference {
+			deleted = append(deleted, repository+":"+tag)
+		}
+	}
+	sort.Strings(deleted)
+	f.deleted = append(f.deleted, deleted...)
+	return f.fakedTagRegistry.DeleteManifest(repository, reference)
+}
+
+func TestCopyWithMove(t *testing.T) {
+	stopFunc := func() bool { return false }
+	src := &fakeMoveRegistry{
+		fakedTagRegistry: fakedTagRegistry{
+			tags: map[string]string{
+				"a1":     "sha256:01",
+				"a2":     "sha256:02",
+				"a3":     "sha256:03",
+				"broken": "sha256:04",
+				"a4":     "sha256:05",
+				"a6":     "sha256:06",
+				"a7":     "sha256:07",
+				"a8":     "sha256:07",
+			},
+		},
+	}
+	dst := &fakeRegistry{
+		manifests: map[string]string{
+			"destination:b3": "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+		},
+	}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: stopFunc,
+		src:       src,
+		dst:       dst,
+	}
+
+	// the tags are deleted on the source registry after being copied, "b1" already
+	// exists on the destination registry and is treated as copied
+	err := tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a1", "a2"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b1", "b2"},
+	}, true, true)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"source:a1", "source:a2"}, src.deleted)
+
+	// the different image exists and isn't overridden, the source isn't deleted
+	src.deleted = nil
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a3"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b3"},
+	}, false, true)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(src.deleted))
+
+	// the copy succeeds but the deletion fails
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"broken", "a4"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b4", "b5"},
+	}, true, true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "source:broken is copied, but failed to delete it")
+	// the copy isn't undone
+	exist, _, err := dst.ManifestExist("destination", "b4")
+	require.Nil(t, err)
+	assert.True(t, exist)
+	assert.Equal(t, []string{"source:a4"}, src.deleted)
+
+	// not move
+	src.deleted = nil
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a6"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b6"},
+	}, true, false)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(src.deleted))
+
+	// the tag sharing the manifest with the tag not moved isn't deleted
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a7"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b7"},
+	}, true, true)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(src.deleted))
+
+	// the tags sharing the manifest are moved together
+	err = tr.copy(&repository{
+		repository: "source",
+		tags:       []string{"a7", "a8"},
+	}, &repository{
+		repository: "destination",
+		tags:       []string{"b7", "b8"},
+	}, true, true)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"source:a7", "source:a8"}, src.deleted)
+}
+
+// the registry has two referrers attached to the manifest "sha256:c6b2..."
+type fakeReferrerRegistry struct {
+	fakeRegistry
+	pushed []string
+}
+
+func (f *fakeReferrerRegistry) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	manifest, digest, err := f.fakeRegistry.PullManifest(repository, reference, accepttedMediaTypes)
+	if err != nil {
+		return nil, "", err
+	}
+	// the referrers are pulled by digest
+	if strings.HasPrefix(reference, "sha256:") {
+		digest = reference
+	}
+	return manifest, digest, nil
+}
+func (f *fakeReferrerRegistry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	f.pushed = append(f.pushed, reference)
+	return f.fakeRegistry.PushManifest(repository, reference, mediaType, payload)
+}
+func (f *fakeReferrerRegistry) ListReferrers(repository, digest string) ([]distribution.Descriptor, error) {
+	if digest != "sha256:c6b2b2c507a0944348e0303114d8d93aaaa081732b86451d9bce1f432a537bc7" {
+		return nil, nil
+	}
+	return []distribution.Descriptor{
+		{
+			MediaType: adapter.MediaTypeOCIManifest,
+			Digest:    "sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f",
+		},
+		{
+			MediaType: adapter.MediaTypeOCIManifest,
+			Digest:    "sha256:3c3a4604a545cdc127456d94e421cd355bca5b528f4a9c1905b15da2eb4a4c6b",
+		},
+	}, nil
+}
+
+func TestCopyReferrers(t *testing.T) {
+	stopFunc := func() bool { return false }
+	registry := &fakeReferrerRegistry{}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: stopFunc,
+		src:       registry,
+		dst:       registry,
+	}
+
+	src := &repository{
+		repository: "source",
+		tags:       []string{"a1"},
+	}
+	dst := &repository{
+		repository: "destination",
+		tags:       []string{"b2"},
+	}
+	err := tr.copy(src, dst, true, false)
+	require.Nil(t, err)
+	// the referrers are pushed by digest after the subject image
+	assert.Equal(t, []string{
+		"b2",
+		"sha256:e692418e4cbaf90ca69d05a66403747baa33ee08806650b51fab815ad7fc331f",
+		"sha256:3c3a4604a545cdc127456d94e421cd355bca5b528f4a9c1905b15da2eb4a4c6b",
+	}, registry.pushed)
+}
+
+// the registry serves a manifest list referencing the child manifest by "childDigest"
+type fakeManifestListRegistry struct {
+	fakeRegistry
+	childDigest string
+}
+
+func (f *fakeManifestListRegistry) PullManifest(repository, reference string, accepttedMediaTypes []string) (distribution.Manifest, string, error) {
+	if strings.HasPrefix(reference, "sha256:") {
+		return f.fakeRegistry.PullManifest(repository, reference, accepttedMediaTypes)
+	}
+	list := `{
+		"schemaVersion": 2,
+		"mediaType": "application/vnd.docker.distribution.manifest.list.v2+json",
+		"manifests": [
+			{
+				"mediaType": "application/vnd.docker.distribution.manifest.v2+json",
+				"size": 1024,
+				"digest": "` + f.childDigest + `",
+				"platform": {
+					"architecture": "amd64",
+					"os": "linux"
+				}
+			}
+		]
+	}`
+	manifest, _, err := pkg_registry.UnMarshal(manifestlist.MediaTypeManifestList, []byte(list))
+	if err != nil {
+		return nil, "", err
+	}
+	return manifest, digest.FromString(list).String(), nil
+}
+
+func TestCopyManifestListWithCorruptChild(t *testing.T) {
+	stopFunc := func() bool { return false }
+	child, _, err := (&fakeRegistry{}).PullManifest("source", "sha256:any", nil)
+	require.Nil(t, err)
+	childDigest, err := manifestDigest(child)
+	require.Nil(t, err)
+
+	// the child matches the digest referenced by the manifest list
+	dst := &fakeRegistry{}
+	tr := &transfer{
+		logger:    log.DefaultLogger(),
+		isStopped: stopFunc,
+		src:       &fakeManifestListRegistry{childDigest: childDigest},
+		dst:       dst,
+	}
+	_, err = tr.copyImage("source", "latest", "destination", "latest", true)
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(dst.manifests))
+
+	// the child doesn't match the digest referenced by the manifest list
+	dst = &fakeRegistry{}
+	tr.src = &fakeManifestListRegistry{
+		childDigest: "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+	}
+	tr.dst = dst
+	_, err = tr.copyImage("source", "latest", "destination", "latest", true)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "doesn't match the digest sha256:0000000000000000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, 0, len(dst.manifests))
+}
+
 func TestDelete(t *testing.T) {
 	stopFunc := func() bool { return false }
 	tr := &transfer{
@@ -155,3 +575,50 @@ func TestDelete(t *testing.T) {
 	err := tr.delete(repo)
 	require.Nil(t, err)
 }
+
+// the registry records the pushed blobs and fails the push of the manifest
+// until "tagFailures" is exhausted
+type fakeResumableRegistry struct {
+	fakeConfigRejectingRegistry
+	tagFailures int
+	blobPushes  int
+}
+
+func (f *fakeResumableRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	f.blobPushes++
+	return f.fakeConfigRejectingRegistry.PushBlob(repository, digest, size, blob)
+}
+func (f *fakeResumableRegistry) PushManifest(repository, reference, mediaType string, payload []byte) error {
+	if f.tagFailures > 0 {
+		f.tagFailures--
+		return errors.New("connection reset by peer")
+	}
+	return f.fakeConfigRejectingRegistry.PushManifest(repository, reference, mediaType, payload)
+}
+
+func TestCopyImageResumedFromPushingManifest(t *testing.T) {
+	registry := &fakeResumableRegistry{
+		tagFailures: 1,
+	}
+	newTransfer := func() *transfer {
+		return &transfer{
+			logger:          log.DefaultLogger(),
+			isStopped:       func() bool { return false },
+			src:             &fakeRegistry{},
+			dst:             registry,
+			configBlobFirst: true,
+		}
+	}
+
+	// the blobs are uploaded but the manifest fails to be pushed
+	_, err := newTransfer().copyImage("source", "a1", "destination", "a1", true)
+	require.NotNil(t, err)
+	assert.Equal(t, 4, registry.blobPushes)
+	assert.Equal(t, 0, len(registry.manifests))
+
+	// the retry only pushes the manifest
+	digest, err := newTransfer().copyImage("source", "a1", "destination", "a1", true)
+	require.Nil(t, err)
+	assert.Equal(t, 4, registry.blobPushes)
+	assert.Equal(t, digest, registry.manifests["destination:a1"])
+}
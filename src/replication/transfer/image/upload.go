@@ -0,0 +1,76 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"sync"
+)
+
+// the limiters of the concurrent blob uploads indexed by the destination registry,
+// they're shared by all the copy tasks running in the same process
+var uploadLimiters = &destinationUploadLimiters{}
+
+type destinationUploadLimiters struct {
+	sync.Mutex
+	limiters map[string]*uploadLimiter
+}
+
+// get the limiter of the destination registry, it's created if it doesn't exist
+func (d *destinationUploadLimiters) get(destination string) *uploadLimiter {
+	d.Lock()
+	defer d.Unlock()
+	if d.limiters == nil {
+		d.limiters = map[string]*uploadLimiter{}
+	}
+	limiter, exist := d.limiters[destination]
+	if !exist {
+		limiter = newUploadLimiter()
+		d.limiters[destination] = limiter
+	}
+	return limiter
+}
+
+// uploadLimiter limits the count of the blobs uploaded concurrently to one destination
+// registry. The limit is given by each caller rather than the limiter, so the tasks
+// of the policies with different limits targeting the same registry all respect theirs
+type uploadLimiter struct {
+	lock     sync.Mutex
+	cond     *sync.Cond
+	inFlight int
+}
+
+func newUploadLimiter() *uploadLimiter {
+	limiter := &uploadLimiter{}
+	limiter.cond = sync.NewCond(&limiter.lock)
+	return limiter
+}
+
+// acquire waits until less than "limit" uploads are in flight and occupies one
+func (u *uploadLimiter) acquire(limit int) {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	for u.inFlight >= limit {
+		u.cond.Wait()
+	}
+	u.inFlight++
+}
+
+// release frees the upload occupied by "acquire"
+func (u *uploadLimiter) release() {
+	u.lock.Lock()
+	defer u.lock.Unlock()
+	u.inFlight--
+	u.cond.Broadcast()
+}
@@ -0,0 +1,106 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goharbor/harbor/src/common/utils/log"
+	"github.com/goharbor/harbor/src/replication/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the registry records the max count of the blobs pushed concurrently
+type fakeUploadCountingRegistry struct {
+	fakeRegistry
+	lock        sync.Mutex
+	inFlight    int
+	maxInFlight int
+}
+
+func (f *fakeUploadCountingRegistry) PushBlob(repository, digest string, size int64, blob io.Reader) error {
+	f.lock.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.lock.Unlock()
+	time.Sleep(10 * time.Millisecond)
+	f.lock.Lock()
+	f.inFlight--
+	f.lock.Unlock()
+	return nil
+}
+
+func TestUploadLimiters(t *testing.T) {
+	limiters := &destinationUploadLimiters{}
+	a := limiters.get("https://a.com")
+	assert.True(t, a == limiters.get("https://a.com"))
+	assert.False(t, a == limiters.get("https://b.com"))
+
+	a.acquire(1)
+	acquired := make(chan struct{})
+	go func() {
+		a.acquire(1)
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the upload is acquired over the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	a.release()
+	<-acquired
+	a.release()
+}
+
+func TestTransferBlobWithDestinationUploadLimit(t *testing.T) {
+	run := func(limit int, url string) int {
+		dst := &fakeUploadCountingRegistry{}
+		var wg sync.WaitGroup
+		// the tasks copying the blobs to the same destination concurrently
+		for i := 0; i < 4; i++ {
+			tr := &transfer{
+				logger:         log.DefaultLogger(),
+				isStopped:      func() bool { return false },
+				src:            &fakeRegistry{},
+				dst:            dst,
+				maxBlobUploads: limit,
+				dstRegistry: &model.Registry{
+					URL: url,
+				},
+			}
+			for j := 0; j < 3; j++ {
+				wg.Add(1)
+				go func(i, j int) {
+					defer wg.Done()
+					require.Nil(t, tr.transferBlob("source", "destination", fmt.Sprintf("sha256:%d%d", i, j)))
+				}(i, j)
+			}
+		}
+		wg.Wait()
+		return dst.maxInFlight
+	}
+
+	// the uploads to the destination never exceed the limit
+	assert.True(t, run(2, "https://limited.com") <= 2)
+	// no limit
+	assert.True(t, run(0, "https://unlimited.com") > 2)
+}
@@ -0,0 +1,98 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package image
+
+import (
+	"fmt"
+	"sync"
+)
+
+// the max count of the copied images waiting for the verification, the copy
+// of the next image is blocked when the pipeline is full
+const verificationPipelineSize = 2
+
+// verification describes the manifest expected on the destination registry
+// after copying the image at "index"
+type verification struct {
+	index      int
+	repository string
+	reference  string
+	digest     string
+}
+
+// verifier verifies the copied images in background and records the result
+// into the error slot of the corresponding image
+type verifier struct {
+	transfer      *transfer
+	errs          []error
+	verifications chan *verification
+	wg            sync.WaitGroup
+}
+
+func (t *transfer) startVerifier(errs []error) *verifier {
+	v := &verifier{
+		transfer:      t,
+		errs:          errs,
+		verifications: make(chan *verification, verificationPipelineSize),
+	}
+	v.wg.Add(1)
+	go func() {
+		defer v.wg.Done()
+		for vf := range v.verifications {
+			v.errs[vf.index] = t.verify(vf.repository, vf.reference, vf.digest)
+		}
+	}()
+	return v
+}
+
+// submit the verification, no manifest is pushed if the digest is empty
+// and nothing need to be verified
+func (v *verifier) submit(vf *verification) {
+	if len(vf.digest) == 0 {
+		return
+	}
+	v.verifications <- vf
+}
+
+// wait until all the submitted verifications are done
+func (v *verifier) wait() {
+	close(v.verifications)
+	v.wg.Wait()
+}
+
+// verify the manifest of the image on the destination registry has the expected digest
+func (t *transfer) verify(repository, reference, digest string) error {
+	if t.shouldStop() {
+		return nil
+	}
+	t.logger.Infof("verifying the manifest of image %s:%s on the destination registry...", repository, reference)
+	exist, dgt, err := t.exist(repository, reference)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return fmt.Errorf("the manifest of image %s:%s isn't found on the destination registry after copying", repository, reference)
+	}
+	// some registries don't return the digest, the manifest cannot be verified
+	if len(dgt) == 0 {
+		t.logger.Warningf("the digest of image %s:%s isn't returned by the destination registry, skip the verification", repository, reference)
+		return nil
+	}
+	if dgt != digest {
+		return fmt.Errorf("the digest of image %s:%s on the destination registry is %s, expected %s", repository, reference, dgt, digest)
+	}
+	t.logger.Infof("the manifest of image %s:%s verified", repository, reference)
+	return nil
+}
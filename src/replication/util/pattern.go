@@ -16,6 +16,7 @@ package util
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/bmatcuk/doublestar"
@@ -29,6 +30,46 @@ func Match(pattern, str string) (bool, error) {
 	return doublestar.Match(pattern, str)
 }
 
+// Matcher matches the strings against one pattern which is parsed only once
+type Matcher interface {
+	Match(str string) (bool, error)
+}
+
+// NewGlobMatcher returns a Matcher which matches the strings as "Match" does
+func NewGlobMatcher(pattern string) Matcher {
+	return &globMatcher{
+		pattern: pattern,
+	}
+}
+
+type globMatcher struct {
+	pattern string
+}
+
+func (g *globMatcher) Match(str string) (bool, error) {
+	return Match(g.pattern, str)
+}
+
+// NewRegexMatcher returns a Matcher which matches the strings against the regular
+// expression. The expression isn't anchored, use "^" and "$" to match the whole string
+func NewRegexMatcher(pattern string) (Matcher, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression %s: %v", pattern, err)
+	}
+	return &regexMatcher{
+		re: re,
+	}, nil
+}
+
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+func (r *regexMatcher) Match(str string) (bool, error) {
+	return r.re.MatchString(str), nil
+}
+
 // IsSpecificPath checks whether the input path is a specified string
 // If it is, the function returns a string array that parsed from the input path
 // A specified string means we can get a specific string array after parsing it
@@ -176,3 +176,31 @@ func TestIsSpecificPath(t *testing.T) {
 		}
 	}
 }
+
+func TestMatcher(t *testing.T) {
+	glob := NewGlobMatcher("library/*")
+	m, err := glob.Match("library/hello-world")
+	require.Nil(t, err)
+	assert.True(t, m)
+
+	regex, err := NewRegexMatcher(`^team-(alpha|beta)/service-\d+$`)
+	require.Nil(t, err)
+	cases := []struct {
+		str   string
+		match bool
+	}{
+		{"team-alpha/service-1", true},
+		{"team-beta/service-23", true},
+		{"team-gamma/service-1", false},
+		{"team-alpha/service-x", false},
+		{"team-alpha/service-1/sub", false},
+	}
+	for _, c := range cases {
+		m, err := regex.Match(c.str)
+		require.Nil(t, err)
+		assert.Equal(t, c.match, m)
+	}
+
+	_, err = NewRegexMatcher("team-(alpha")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,196 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/goharbor/harbor/src/common/utils/log"
+)
+
+// the full semantic version "major.minor.patch" with the optional pre-release and build
+// metadata defined by https://semver.org, the "v" prefix common for the tags is allowed
+var strictSemver = regexp.MustCompile(`^v?(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(-(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(\.(0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*)?` +
+	`(\+[0-9a-zA-Z-]+(\.[0-9a-zA-Z-]+)*)?$`)
+
+// parse the tag as the semantic version strictly, the partial versions(e.g. "1.4"
+// or "v1") aren't coerced into the full ones as their patch lines are unknown
+func parseStrictSemver(tag string) (*semver.Version, error) {
+	if !strictSemver.MatchString(tag) {
+		return nil, fmt.Errorf("%s isn't a full semantic version", tag)
+	}
+	return semver.NewVersion(tag)
+}
+
+// LatestPatchPerMinor groups the semver tags by "major.minor" and keeps only the
+// tag with the highest version in each group, e.g. [1.4.8, 1.4.9, 1.5.3] -> [1.4.9, 1.5.3].
+// The tags are parsed strictly, only the full versions with the optional "v" prefix are
+// semver tags, e.g. "1.4" and "v1" are non-semver ones. The non-semver tags are kept if
+// "keepNonSemver" is true, otherwise they are dropped. The order of the input tags is
+// kept in the result
+func LatestPatchPerMinor(tags []string, keepNonSemver bool) []string {
+	latest := map[string]*semver.Version{}
+	versions := make([]*semver.Version, len(tags))
+	for i, tag := range tags {
+		version, err := parseStrictSemver(tag)
+		if err != nil {
+			continue
+		}
+		versions[i] = version
+		line := fmt.Sprintf("%d.%d", version.Major(), version.Minor())
+		if l, exist := latest[line]; !exist || version.GreaterThan(l) {
+			latest[line] = version
+		}
+	}
+
+	result := []string{}
+	for i, tag := range tags {
+		version := versions[i]
+		if version == nil {
+			if keepNonSemver {
+				result = append(result, tag)
+			}
+			continue
+		}
+		line := fmt.Sprintf("%d.%d", version.Major(), version.Minor())
+		// compare the pointer to keep only the first one when the versions are equal
+		if latest[line] == version {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// IsNewerVersion returns whether the version "a" is newer than "b". The semver tags are
+// compared according to the semver precedence and are newer than the non-semver tags,
+// the non-semver tags and the equal versions(e.g. "v1.0" and "1.0.0") are compared lexically
+func IsNewerVersion(a, b string) bool {
+	va, erra := semver.NewVersion(a)
+	vb, errb := semver.NewVersion(b)
+	switch {
+	case erra == nil && errb == nil:
+		if !va.Equal(vb) {
+			return va.GreaterThan(vb)
+		}
+		return a > b
+	case erra == nil:
+		return true
+	case errb == nil:
+		return false
+	default:
+		return a > b
+	}
+}
+
+// the space between the operator and the version is allowed, e.g. ">= 1.4.0"
+var semverOperatorSpace = regexp.MustCompile(`(>=|<=|!=|>|<|=)\s+`)
+
+// NewSemverMatcher returns a Matcher which matches the semver tags against the range, e.g.
+// ">=1.4.0 <2.0.0" or ">=1.4.0, <2.0.0 || >=3.0.0". The comparisons separated by spaces or
+// commas are ANDed and the groups separated by "||" are ORed. The pre-release versions are
+// compared according to the semver precedence, so "2.0.0-rc1" is in the range "<2.0.0".
+// The tags that aren't semantic versions never match
+func NewSemverMatcher(constraint string) (Matcher, error) {
+	matcher := &semverMatcher{}
+	for _, group := range strings.Split(constraint, "||") {
+		group = semverOperatorSpace.ReplaceAllString(strings.TrimSpace(group), "$1")
+		fields := strings.FieldsFunc(group, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("invalid semver range %s: empty comparison", constraint)
+		}
+		comparisons := []*semverComparison{}
+		for _, field := range fields {
+			comparison, err := parseSemverComparison(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid semver range %s: %v", constraint, err)
+			}
+			comparisons = append(comparisons, comparison)
+		}
+		matcher.groups = append(matcher.groups, comparisons)
+	}
+	return matcher, nil
+}
+
+type semverComparison struct {
+	operator string
+	version  *semver.Version
+}
+
+func parseSemverComparison(str string) (*semverComparison, error) {
+	operator := ""
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(str, op) {
+			operator = op
+			break
+		}
+	}
+	version, err := semver.NewVersion(strings.TrimPrefix(str, operator))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", str, err)
+	}
+	return &semverComparison{
+		operator: operator,
+		version:  version,
+	}, nil
+}
+
+func (s *semverComparison) check(version *semver.Version) bool {
+	result := version.Compare(s.version)
+	switch s.operator {
+	case ">=":
+		return result >= 0
+	case "<=":
+		return result <= 0
+	case "!=":
+		return result != 0
+	case ">":
+		return result > 0
+	case "<":
+		return result < 0
+	default:
+		return result == 0
+	}
+}
+
+type semverMatcher struct {
+	groups [][]*semverComparison
+}
+
+func (s *semverMatcher) Match(str string) (bool, error) {
+	version, err := semver.NewVersion(str)
+	if err != nil {
+		log.Debugf("%s isn't a semantic version, skip it: %v", str, err)
+		return false, nil
+	}
+	for _, group := range s.groups {
+		matched := true
+		for _, comparison := range group {
+			if !comparison.check(version) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,146 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestPatchPerMinor(t *testing.T) {
+	cases := []struct {
+		tags          []string
+		keepNonSemver bool
+		result        []string
+	}{
+		{
+			tags:   nil,
+			result: []string{},
+		},
+		// multiple patches across two minor lines
+		{
+			tags:   []string{"1.4.8", "1.5.1", "1.4.9", "1.5.3", "1.4.0", "1.5.2"},
+			result: []string{"1.4.9", "1.5.3"},
+		},
+		// the pre-release is lower than the release
+		{
+			tags:   []string{"1.4.9-rc1", "1.4.9", "1.4.10-rc1"},
+			result: []string{"1.4.10-rc1"},
+		},
+		// the equal versions, keep the first one
+		{
+			tags:   []string{"v1.4.9", "1.4.9"},
+			result: []string{"v1.4.9"},
+		},
+		// drop the non-semver tags
+		{
+			tags:   []string{"latest", "1.4.8", "1.4.9"},
+			result: []string{"1.4.9"},
+		},
+		// keep the non-semver tags
+		{
+			tags:          []string{"latest", "1.4.8", "1.4.9"},
+			keepNonSemver: true,
+			result:        []string{"latest", "1.4.9"},
+		},
+		// the partial versions aren't coerced into the full ones
+		{
+			tags:          []string{"1.4", "v1", "1.4.8", "01.4.9", "1.4.8.1"},
+			keepNonSemver: true,
+			result:        []string{"1.4", "v1", "1.4.8", "01.4.9", "1.4.8.1"},
+		},
+		{
+			tags:   []string{"1.4", "v1", "1.4.8", "01.4.9", "1.4.8.1"},
+			result: []string{"1.4.8"},
+		},
+		// the build metadata is allowed but ignored by the precedence
+		{
+			tags:   []string{"1.4.8", "1.4.9+build.1", "1.4.9+build.2"},
+			result: []string{"1.4.9+build.1"},
+		},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.result, LatestPatchPerMinor(c.tags, c.keepNonSemver))
+	}
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	cases := []struct {
+		a, b  string
+		newer bool
+	}{
+		{"1.10.0", "1.9.0", true},
+		{"1.9.0", "v1.10.0", false},
+		{"2.0.0", "2.0.0-rc1", true},
+		{"1.0.0", "1.0.0", false},
+		// the equal versions are compared lexically
+		{"v1.0.0", "1.0.0", true},
+		{"1.0.0", "v1.0.0", false},
+		// the semver tags are newer than the non-semver ones
+		{"0.0.1", "latest", true},
+		{"latest", "0.0.1", false},
+		// the non-semver tags are compared lexically
+		{"stable", "latest", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.newer, IsNewerVersion(c.a, c.b), "%s vs %s", c.a, c.b)
+	}
+}
+
+func TestSemverMatcher(t *testing.T) {
+	matcher, err := NewSemverMatcher(">=1.4.0 <2.0.0 || >= 3.0.0, !=3.1.0")
+	require.Nil(t, err)
+	cases := []struct {
+		str   string
+		match bool
+	}{
+		{"1.3.9", false},
+		{"1.4.0", true},
+		{"v1.9.9", true},
+		// the pre-release is lower than the release
+		{"2.0.0-rc1", true},
+		{"2.0.0", false},
+		{"2.5.0", false},
+		{"3.0.0", true},
+		{"3.1.0", false},
+		{"3.2.0", true},
+		// not semantic versions
+		{"latest", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		m, err := matcher.Match(c.str)
+		require.Nil(t, err)
+		assert.Equal(t, c.match, m, c.str)
+	}
+
+	// the pre-release is compared by the precedence
+	matcher, err = NewSemverMatcher(">1.0.0-alpha.1")
+	require.Nil(t, err)
+	m, err := matcher.Match("1.0.0-beta")
+	require.Nil(t, err)
+	assert.True(t, m)
+	m, err = matcher.Match("1.0.0-alpha")
+	require.Nil(t, err)
+	assert.False(t, m)
+
+	// invalid ranges
+	for _, constraint := range []string{"", ">=1.4.0 ||", "~>1.x.y", ">=latest"} {
+		_, err = NewSemverMatcher(constraint)
+		assert.NotNil(t, err, constraint)
+	}
+}
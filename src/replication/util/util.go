@@ -41,3 +41,20 @@ func ParseRepository(repository string) (string, string) {
 	}
 	return repository[:index], repository[index+1:]
 }
+
+// ParseNamespace parses the "repository" provided into two parts: namespace and the rest
+// the string before the first "/" is the namespace part, this is the project for
+// the nested repositories of Harbor
+// c -> [,c]
+// b/c -> [b,c]
+// a/b/c -> [a,b/c]
+func ParseNamespace(repository string) (string, string) {
+	if len(repository) == 0 {
+		return "", ""
+	}
+	index := strings.Index(repository, "/")
+	if index == -1 {
+		return "", repository
+	}
+	return repository[:index], repository[index+1:]
+}
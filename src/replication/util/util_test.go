@@ -49,3 +49,22 @@ func TestParseRepository(t *testing.T) {
 	assert.Equal(t, "a/b", namespace)
 	assert.Equal(t, "c", rest)
 }
+
+func TestParseNamespace(t *testing.T) {
+	// empty repository
+	namespace, rest := ParseNamespace("")
+	assert.Equal(t, "", namespace)
+	assert.Equal(t, "", rest)
+	// repository contains no "/"
+	namespace, rest = ParseNamespace("c")
+	assert.Equal(t, "", namespace)
+	assert.Equal(t, "c", rest)
+	// repository contains only one "/"
+	namespace, rest = ParseNamespace("b/c")
+	assert.Equal(t, "b", namespace)
+	assert.Equal(t, "c", rest)
+	// nested repository
+	namespace, rest = ParseNamespace("library/a/b")
+	assert.Equal(t, "library", namespace)
+	assert.Equal(t, "a/b", rest)
+}